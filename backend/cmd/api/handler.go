@@ -1,10 +1,16 @@
 package api
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
 	authUsecase "ga03-backend/internal/auth/usecase"
 	emailUsecase "ga03-backend/internal/email/usecase"
 	"ga03-backend/pkg/config"
 	gemini "ga03-backend/pkg/gemini"
+	"ga03-backend/pkg/health"
 	"ga03-backend/pkg/sse"
 
 	"github.com/gin-gonic/gin"
@@ -15,11 +21,12 @@ type Handler struct {
 	emailUsecase emailUsecase.EmailUsecase
 	sseManager   *sse.Manager
 	config       *config.Config
+	health       *health.Checker
 }
 
-func NewHandler(authUsecase authUsecase.AuthUsecase, emailUsecase emailUsecase.EmailUsecase, sseManager *sse.Manager, cfg *config.Config) *Handler {
+func NewHandler(authUsecase authUsecase.AuthUsecase, emailUsecase emailUsecase.EmailUsecase, sseManager *sse.Manager, cfg *config.Config, healthChecker *health.Checker) *Handler {
 	// Khởi tạo GeminiService từ API key trong config
-	geminiSvc := gemini.NewGeminiService(cfg.GeminiApiKey)
+	geminiSvc := gemini.NewGeminiService(cfg.GeminiApiKey, cfg.GeminiModel, cfg.GeminiTemperature, cfg.GeminiMaxTokens)
 	// Gán GeminiService vào emailUsecase qua interface
 	emailUsecase.SetGeminiService(geminiSvc)
 	return &Handler{
@@ -27,12 +34,21 @@ func NewHandler(authUsecase authUsecase.AuthUsecase, emailUsecase emailUsecase.E
 		emailUsecase: emailUsecase,
 		sseManager:   sseManager,
 		config:       cfg,
+		health:       healthChecker,
 	}
 }
 
-func (h *Handler) Start(addr string) error {
+// Start serves HTTP on addr until ctx is done, then shuts the server down, giving
+// in-flight requests up to ShutdownTimeout to finish. SSE streams never finish on
+// their own, so they are closed as the shutdown begins.
+func (h *Handler) Start(ctx context.Context, addr string) error {
 	r := gin.Default()
 	gin.SetMode(gin.ReleaseMode)
+	// Gin trusts every X-Forwarded-For by default, which would let clients pick the
+	// IP the rate limits key on
+	if err := r.SetTrustedProxies(h.config.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
 
 	// CORS middleware
 	r.Use(func(c *gin.Context) {
@@ -56,7 +72,27 @@ func (h *Handler) Start(addr string) error {
 	})
 
 	// Setup routes
-	SetupRoutes(r, h.authUsecase, h.emailUsecase, h.sseManager, h.config)
+	SetupRoutes(r, h.authUsecase, h.emailUsecase, h.sseManager, h.config, h.health)
+
+	srv := &http.Server{Addr: addr, Handler: r}
+	srv.RegisterOnShutdown(h.sseManager.Shutdown)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
 
-	return r.Run(addr)
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down server, waiting up to %s for open requests", h.config.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), h.config.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown cut short: %v", err)
+	}
+	return nil
 }
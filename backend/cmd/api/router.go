@@ -1,59 +1,143 @@
 package api
 
 import (
+	"net/http"
+
 	"ga03-backend/internal/auth/delivery"
 	authUsecase "ga03-backend/internal/auth/usecase"
 	emailDelivery "ga03-backend/internal/email/delivery"
 	emailUsecase "ga03-backend/internal/email/usecase"
 	"ga03-backend/pkg/config"
+	"ga03-backend/pkg/health"
+	"ga03-backend/pkg/imageproxy"
+	"ga03-backend/pkg/ratelimit"
 	"ga03-backend/pkg/sse"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(r *gin.Engine, authUsecase authUsecase.AuthUsecase, emailUsecase emailUsecase.EmailUsecase, sseManager *sse.Manager, cfg *config.Config) {
-	authHandler := delivery.NewAuthHandler(authUsecase)
-	emailHandler := emailDelivery.NewEmailHandler(emailUsecase)
+func SetupRoutes(r *gin.Engine, authUsecase authUsecase.AuthUsecase, emailUsecase emailUsecase.EmailUsecase, sseManager *sse.Manager, cfg *config.Config, healthChecker *health.Checker) {
+	authHandler := delivery.NewAuthHandler(authUsecase, cfg)
+	imageProxy := imageproxy.NewProxy(cfg.ImageProxySecret, cfg.PublicURL, int64(cfg.ImageProxyMaxBytes), int64(cfg.ImageProxyCacheMax), cfg.ImageProxyCacheTTL)
+	emailHandler := emailDelivery.NewEmailHandler(emailUsecase, sseManager, imageProxy)
+	// Short-term throttle shared by all AI endpoints
+	aiLimiter := ratelimit.NewLimiter(cfg.AIRateLimit, cfg.AIRateWindow)
+	// Per-IP throttle on credential endpoints against brute force and mail flooding
+	authLimit := delivery.IPRateLimitMiddleware(ratelimit.NewLimiter(cfg.AuthRateLimit, cfg.AuthRateWindow))
+	// Per-user throttle on the email API, which fans out to the mail providers
+	emailLimit := delivery.RateLimitMiddleware(ratelimit.NewLimiter(cfg.EmailRateLimit, cfg.EmailRateWindow))
+
+	// Probes for orchestrators and load balancers, outside /api and without auth
+	r.GET("/healthz", healthChecker.Live)
+	r.GET("/readyz", healthChecker.Ready)
 
 	api := r.Group("/api")
 	{
 		// SSE endpoint
+		// IMAP IDLE watchers follow the streams, see main.go
 		api.GET("/events", delivery.AuthMiddleware(authUsecase), func(c *gin.Context) {
-			userID := c.GetString("userID")
-			sseManager.ServeHTTP(c, userID)
+			sseManager.ServeHTTP(c, c.GetString("userID"))
+		})
+
+		// Optional features enabled by the server's configuration
+		api.GET("/config", func(c *gin.Context) {
+			c.JSON(http.StatusOK, cfg.Features())
 		})
 
+		// Remote images in email bodies; authorized by the signed URL, not a token
+		api.GET("/emails/:id/proxy-image", emailHandler.ProxyImage)
+
 		// Auth routes
 		auth := api.Group("/auth")
 		{
-			auth.POST("/login", authHandler.Login)
-			auth.POST("/imap", authHandler.IMAPLogin)
-			auth.POST("/register", authHandler.Register)
-			auth.POST("/google", authHandler.GoogleSignIn)
+			auth.POST("/login", authLimit, authHandler.Login)
+			auth.POST("/imap", authLimit, authHandler.IMAPLogin)
+			auth.POST("/register", authLimit, authHandler.Register)
+			auth.POST("/google", authLimit, authHandler.GoogleSignIn)
 			auth.POST("/refresh", authHandler.RefreshToken)
 			auth.GET("/me", delivery.AuthMiddleware(authUsecase), authHandler.Me)
 			auth.POST("/logout", authHandler.Logout)
+			auth.POST("/logout-all", delivery.AuthMiddleware(authUsecase), authHandler.LogoutAll)
+			auth.GET("/signature", delivery.AuthMiddleware(authUsecase), authHandler.GetSignature)
+			auth.PUT("/signature", delivery.AuthMiddleware(authUsecase), authHandler.UpdateSignature)
+			auth.GET("/accounts", delivery.AuthMiddleware(authUsecase), authHandler.ListLinkedAccounts)
+			auth.POST("/accounts/imap", authLimit, delivery.AuthMiddleware(authUsecase), authHandler.LinkImapAccount)
+			auth.POST("/accounts/google", authLimit, delivery.AuthMiddleware(authUsecase), authHandler.LinkGoogleAccount)
+			auth.DELETE("/accounts/:id", delivery.AuthMiddleware(authUsecase), authHandler.UnlinkAccount)
+			auth.GET("/sessions", delivery.AuthMiddleware(authUsecase), authHandler.ListSessions)
+			auth.DELETE("/sessions/:id", delivery.AuthMiddleware(authUsecase), authHandler.RevokeSession)
+			auth.POST("/forgot-password", authLimit, authHandler.ForgotPassword)
+			auth.POST("/reset-password", authLimit, authHandler.ResetPassword)
+			auth.POST("/change-password", authLimit, delivery.AuthMiddleware(authUsecase), authHandler.ChangePassword)
 		}
 
+		// Recipient autocomplete
+		api.GET("/contacts", delivery.AuthMiddleware(authUsecase), emailLimit, emailHandler.SearchContacts)
+
 		// Email routes (protected)
 		emails := api.Group("/emails")
-		emails.Use(delivery.AuthMiddleware(authUsecase))
+		emails.Use(delivery.AuthMiddleware(authUsecase), emailLimit, delivery.GmailScopeMiddleware(), emailHandler.ResolveEmailIDs())
 		{
 			emails.GET("/mailboxes", emailHandler.GetAllMailboxes)
 			emails.GET("/mailboxes/:id", emailHandler.GetMailboxByID)
 			emails.GET("/mailboxes/:id/emails", emailHandler.GetEmailsByMailbox)
+			emails.POST("/mailboxes/:id/sync", emailHandler.SyncMailbox)
+			emails.GET("/changes", emailHandler.GetChanges)
 			emails.GET("/status/:status", emailHandler.GetEmailsByStatus) // Kanban status API
+			emails.GET("/stats", emailHandler.GetStats)
+			emails.GET("/unified", emailHandler.GetUnifiedInbox)
+			emails.GET("/account/status", emailHandler.GetAccountStatus)
+			emails.POST("/kanban/batch", emailHandler.BatchUpdateKanbanStatus)
+			emails.POST("/batch", emailHandler.BatchModify)
+			emails.POST("/prefetch", emailHandler.PrefetchEmails)
+			emails.POST("/export/pdf", emailHandler.ExportEmailsPDF)
+			emails.GET("/gmail/filters", emailHandler.ListGmailFilters)
+			emails.POST("/gmail/filters", emailHandler.CreateGmailFilter)
+			emails.DELETE("/gmail/filters/:filterId", emailHandler.DeleteGmailFilter)
+			emails.GET("/threads/:id", emailHandler.GetThread)
+			emails.GET("/threads/:id/participants", emailHandler.GetThreadParticipants)
+			emails.GET("/threads/:id/search", emailHandler.SearchThread)
+			emails.PATCH("/threads/:id/read", emailHandler.MarkThreadAsRead)
+			emails.PATCH("/threads/:id/unread", emailHandler.MarkThreadAsUnread)
+			emails.PATCH("/threads/:id/star", emailHandler.ToggleThreadStar)
+			emails.POST("/threads/:id/trash", emailHandler.TrashThread)
 			emails.GET("/:id", emailHandler.GetEmailByID)
-			emails.GET("/:id/summary", emailHandler.SummarizeEmail)
+			emails.GET("/:id/summary", delivery.RateLimitMiddleware(aiLimiter), emailHandler.SummarizeEmail)
+			emails.GET("/:id/categorize", delivery.RateLimitMiddleware(aiLimiter), emailHandler.CategorizeEmail)
+			emails.GET("/:id/suggest-replies", delivery.RateLimitMiddleware(aiLimiter), emailHandler.SuggestReplies)
+			emails.GET("/:id/translate", delivery.RateLimitMiddleware(aiLimiter), emailHandler.TranslateEmail)
+			emails.GET("/:id/invite", emailHandler.GetInvite)
+			emails.POST("/:id/invite/respond", emailHandler.RespondToInvite)
 			emails.GET("/:id/attachments/:attachmentId", emailHandler.GetAttachment)
 			emails.PATCH("/:id/read", emailHandler.MarkAsRead)
 			emails.PATCH("/:id/unread", emailHandler.MarkAsUnread)
 			emails.PATCH("/:id/star", emailHandler.ToggleStar)
+			emails.PATCH("/:id/important", emailHandler.ToggleImportant)
 			emails.PATCH("/:id/mailbox", emailHandler.MoveEmailToMailbox)
+			emails.PATCH("/:id/status", emailHandler.UpdateKanbanStatus)
 			emails.POST("/:id/snooze", emailHandler.SnoozeEmail)
 			emails.POST("/send", emailHandler.SendEmail)
+			// Gin needs the same wildcard name at each position, so the pending send ID is :id
+			emails.POST("/:id/cancel-send", emailHandler.CancelSend)
+			emails.POST("/preview", emailHandler.PreviewEmail)
+			emails.POST("/schedule", emailHandler.ScheduleEmail)
+			emails.GET("/scheduled", emailHandler.ListScheduledEmails)
+			emails.DELETE("/scheduled/:scheduledId", emailHandler.CancelScheduledEmail)
+			emails.POST("/:id/reply", emailHandler.ReplyEmail)
+			emails.POST("/:id/reply/preview", emailHandler.PreviewReply)
+			emails.POST("/:id/resend", emailHandler.ResendEmail)
+			emails.PUT("/settings/reply", emailHandler.UpdateReplySettings)
 			emails.POST("/:id/trash", emailHandler.TrashEmail)
 			emails.POST("/:id/archive", emailHandler.ArchiveEmail)
+			emails.POST("/:id/spam", emailHandler.MarkAsSpam)
+			emails.POST("/:id/not-spam", emailHandler.MarkNotSpam)
+			emails.DELETE("/:id", emailHandler.DeleteEmail)
+			emails.POST("/trash/empty", emailHandler.EmptyTrash)
+			emails.POST("/drafts", emailHandler.SaveDraft)
+			emails.PUT("/drafts/:id", emailHandler.UpdateDraft)
+			emails.DELETE("/drafts/:id", emailHandler.DeleteDraft)
+			emails.POST("/drafts/:id/send", emailHandler.SendDraft)
+			emails.POST("/:id/unsubscribe", emailHandler.Unsubscribe)
 			emails.POST("/watch", emailHandler.WatchMailbox)
 		}
 	}
@@ -0,0 +1,56 @@
+package delivery
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"ga03-backend/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const refreshCookieName = "refresh_token"
+
+// refreshCookie holds the attributes of the refresh token cookie
+type refreshCookie struct {
+	domain   string
+	secure   bool
+	sameSite http.SameSite
+	maxAge   int
+}
+
+func newRefreshCookie(cfg *config.Config) refreshCookie {
+	cookie := refreshCookie{
+		domain: cfg.CookieDomain,
+		secure: cfg.CookieSecure,
+		// The cookie lives exactly as long as the refresh token it carries
+		maxAge: int(cfg.JWTRefreshExpiry.Seconds()),
+	}
+
+	switch strings.ToLower(cfg.CookieSameSite) {
+	case "lax":
+		cookie.sameSite = http.SameSiteLaxMode
+	case "strict":
+		cookie.sameSite = http.SameSiteStrictMode
+	default:
+		cookie.sameSite = http.SameSiteNoneMode
+	}
+
+	// Browsers drop SameSite=None cookies that aren't Secure
+	if cookie.sameSite == http.SameSiteNoneMode && !cookie.secure {
+		log.Printf("COOKIE_SAMESITE=none requires COOKIE_SECURE=true, using lax")
+		cookie.sameSite = http.SameSiteLaxMode
+	}
+	return cookie
+}
+
+func (h *AuthHandler) setRefreshCookie(c *gin.Context, token string) {
+	c.SetSameSite(h.cookie.sameSite)
+	c.SetCookie(refreshCookieName, token, h.cookie.maxAge, "/", h.cookie.domain, h.cookie.secure, true)
+}
+
+func (h *AuthHandler) clearRefreshCookie(c *gin.Context) {
+	c.SetSameSite(h.cookie.sameSite)
+	c.SetCookie(refreshCookieName, "", -1, "/", h.cookie.domain, h.cookie.secure, true)
+}
@@ -0,0 +1,112 @@
+package delivery
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ga03-backend/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNewRefreshCookie(t *testing.T) {
+	tests := []struct {
+		name         string
+		secure       bool
+		sameSite     string
+		wantSameSite http.SameSite
+	}{
+		{"production defaults", true, "none", http.SameSiteNoneMode},
+		{"lax", true, "Lax", http.SameSiteLaxMode},
+		{"strict", false, "strict", http.SameSiteStrictMode},
+		{"unknown value", true, "whatever", http.SameSiteNoneMode},
+		// Browsers drop SameSite=None cookies that aren't Secure
+		{"none over http", false, "none", http.SameSiteLaxMode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cookie := newRefreshCookie(&config.Config{
+				CookieDomain:     "example.com",
+				CookieSecure:     tt.secure,
+				CookieSameSite:   tt.sameSite,
+				JWTRefreshExpiry: 7 * 24 * time.Hour,
+			})
+			want := refreshCookie{domain: "example.com", secure: tt.secure, sameSite: tt.wantSameSite, maxAge: 7 * 24 * 3600}
+			if cookie != want {
+				t.Errorf("newRefreshCookie() = %+v, want %+v", cookie, want)
+			}
+		})
+	}
+}
+
+// responseCookie runs set against a recorder and returns the refresh cookie it sent
+func responseCookie(t *testing.T, set func(c *gin.Context)) *http.Cookie {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	set(c)
+
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == refreshCookieName {
+			return cookie
+		}
+	}
+	t.Fatalf("no %s cookie in %q", refreshCookieName, w.Header().Values("Set-Cookie"))
+	return nil
+}
+
+func TestSetRefreshCookie(t *testing.T) {
+	h := NewAuthHandler(nil, &config.Config{
+		CookieDomain:     "app.example.com",
+		CookieSecure:     true,
+		CookieSameSite:   "strict",
+		JWTRefreshExpiry: time.Hour,
+	})
+
+	cookie := responseCookie(t, func(c *gin.Context) { h.setRefreshCookie(c, "token") })
+	if cookie.Value != "token" || cookie.MaxAge != 3600 || cookie.Path != "/" || cookie.Domain != "app.example.com" {
+		t.Errorf("cookie = %+v, want the token for an hour on app.example.com", cookie)
+	}
+	if !cookie.Secure || !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode {
+		t.Errorf("cookie Secure = %v, HttpOnly = %v, SameSite = %v, want a secure, HTTP-only, strict cookie", cookie.Secure, cookie.HttpOnly, cookie.SameSite)
+	}
+
+	cleared := responseCookie(t, h.clearRefreshCookie)
+	if cleared.Value != "" || cleared.MaxAge >= 0 || cleared.Domain != "app.example.com" || cleared.SameSite != http.SameSiteStrictMode {
+		t.Errorf("cleared cookie = %+v, want an expired cookie with the same attributes", cleared)
+	}
+}
+
+func TestSetRefreshCookieLocalHTTP(t *testing.T) {
+	h := NewAuthHandler(nil, &config.Config{CookieSecure: false, CookieSameSite: "none", JWTRefreshExpiry: time.Hour})
+
+	cookie := responseCookie(t, func(c *gin.Context) { h.setRefreshCookie(c, "token") })
+	if cookie.Secure || cookie.SameSite != http.SameSiteLaxMode || cookie.Domain != "" {
+		t.Errorf("cookie = %+v, want a host-only lax cookie usable over http", cookie)
+	}
+}
+
+func TestRefreshCookieMaxAgeTracksExpiry(t *testing.T) {
+	tests := []struct {
+		env  string
+		want int
+	}{
+		{"", 7 * 24 * 3600},
+		{"24h", 24 * 3600},
+		{"720h", 30 * 24 * 3600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			t.Setenv("JWT_REFRESH_EXPIRY", tt.env)
+			h := NewAuthHandler(nil, config.Load())
+
+			cookie := responseCookie(t, func(c *gin.Context) { h.setRefreshCookie(c, "token") })
+			if cookie.MaxAge != tt.want {
+				t.Errorf("cookie MaxAge = %d, want %d to match the refresh token", cookie.MaxAge, tt.want)
+			}
+		})
+	}
+}
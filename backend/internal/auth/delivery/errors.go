@@ -0,0 +1,72 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	"ga03-backend/internal/auth/usecase"
+	"ga03-backend/pkg/apierror"
+	"ga03-backend/pkg/imap"
+	"ga03-backend/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Errors the handlers detect themselves
+var (
+	errNotAuthenticated = errors.New("not authenticated")
+	errInvalidUserData  = errors.New("invalid user data")
+)
+
+// authErrors maps the auth usecase's errors to responses. Anything not listed is
+// answered with a generic 500 by apierror.Respond.
+var authErrors = []apierror.Mapping{
+	{Err: errNotAuthenticated, Status: http.StatusUnauthorized, Code: apierror.CodeUnauthorized},
+	{Err: errInvalidUserData, Status: http.StatusUnauthorized, Code: apierror.CodeUnauthorized},
+	{Err: authdomain.ErrUserNotFound, Status: http.StatusNotFound, Code: apierror.CodeNotFound},
+	{Err: usecase.ErrInvalidCredentials, Status: http.StatusUnauthorized, Code: apierror.CodeUnauthorized},
+	{Err: usecase.ErrUseGoogleSignIn, Status: http.StatusUnauthorized, Code: apierror.CodeUnauthorized},
+	{Err: usecase.ErrEmailTaken, Status: http.StatusConflict, Code: apierror.CodeConflict},
+	{Err: usecase.ErrInvalidRefreshToken, Status: http.StatusUnauthorized, Code: apierror.CodeUnauthorized},
+	{Err: usecase.ErrRefreshTokenExpired, Status: http.StatusUnauthorized, Code: apierror.CodeUnauthorized},
+	{Err: usecase.ErrRefreshTokenReused, Status: http.StatusUnauthorized, Code: apierror.CodeUnauthorized},
+	{Err: usecase.ErrRefreshConflict, Status: http.StatusConflict, Code: apierror.CodeConflict},
+	{Err: usecase.ErrGoogleVerification, Status: http.StatusUnauthorized, Code: apierror.CodeUnauthorized},
+	{Err: usecase.ErrGoogleEmailUnverified, Status: http.StatusForbidden, Code: apierror.CodeForbidden},
+	{Err: usecase.ErrSessionNotFound, Status: http.StatusNotFound, Code: apierror.CodeNotFound},
+	{Err: usecase.ErrInvalidResetToken, Status: http.StatusBadRequest, Code: apierror.CodeBadRequest},
+	{Err: usecase.ErrWrongPassword, Status: http.StatusUnauthorized, Code: apierror.CodeUnauthorized},
+	{Err: usecase.ErrNoPassword, Status: http.StatusBadRequest, Code: apierror.CodeBadRequest},
+	{Err: usecase.ErrMailNotConfigured, Status: http.StatusServiceUnavailable, Code: apierror.CodeUnavailable},
+	{Err: usecase.ErrLinkedAccountNotFound, Status: http.StatusNotFound, Code: apierror.CodeNotFound},
+	{Err: usecase.ErrLinkPrimaryAccount, Status: http.StatusConflict, Code: apierror.CodeConflict},
+	{Err: usecase.ErrGoogleServerRequired, Status: http.StatusBadRequest, Code: apierror.CodeBadRequest},
+	{Err: usecase.ErrIMAPServerMismatch, Status: http.StatusConflict, Code: apierror.CodeConflict},
+	{Err: usecase.ErrGmailScopeRequired, Status: http.StatusForbidden, Code: apierror.CodeForbidden},
+	{Err: imap.ErrAuthFailed, Status: http.StatusUnauthorized, Code: apierror.CodeUnauthorized},
+	{Err: imap.ErrAppPasswordRequired, Status: http.StatusUnauthorized, Code: apierror.CodeUnauthorized},
+	{Err: imap.ErrServerUnreachable, Status: http.StatusBadGateway, Code: apierror.CodeProviderFailure},
+}
+
+// respondError writes err as a {error, code} response. overrides are checked first,
+// for handlers where an error means something else than usual.
+func respondError(c *gin.Context, err error, overrides ...apierror.Mapping) {
+	var limitErr *ratelimit.LimitError
+	if errors.As(err, &limitErr) {
+		RespondTooManyRequests(c, limitErr.RetryAfter)
+		return
+	}
+	apierror.Respond(c, err, append(overrides, authErrors...))
+}
+
+// respondBadRequest writes a 400 for a request the handler itself rejected
+func respondBadRequest(c *gin.Context, message string) {
+	apierror.Write(c, http.StatusBadRequest, apierror.CodeBadRequest, message)
+}
+
+// respondInvalidBody writes a 400 for a body that didn't bind. The binding error
+// names Go types and fields, so it isn't passed on.
+func respondInvalidBody(c *gin.Context) {
+	respondBadRequest(c, "invalid request body")
+}
@@ -1,59 +1,72 @@
 package delivery
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 
+	authdomain "ga03-backend/internal/auth/domain"
 	authdto "ga03-backend/internal/auth/dto"
 	"ga03-backend/internal/auth/usecase"
+	"ga03-backend/pkg/apierror"
+	"ga03-backend/pkg/config"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AuthHandler struct {
 	authUsecase usecase.AuthUsecase
+	cookie      refreshCookie
 }
 
-func NewAuthHandler(authUsecase usecase.AuthUsecase) *AuthHandler {
+func NewAuthHandler(authUsecase usecase.AuthUsecase, cfg *config.Config) *AuthHandler {
 	return &AuthHandler{
 		authUsecase: authUsecase,
+		cookie:      newRefreshCookie(cfg),
 	}
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req authdto.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondInvalidBody(c)
 		return
 	}
 
-	result, err := h.authUsecase.Login(&req)
+	result, err := h.authUsecase.Login(&req, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.SetSameSite(http.SameSiteNoneMode)
-	c.SetCookie("refresh_token", result.RefreshToken, 7*24*3600, "/", "", true, true)
+	h.setRefreshCookie(c, result.RefreshToken)
 	result.RefreshToken = ""
 
 	c.JSON(http.StatusOK, result)
 }
 
+// clientInfo describes the requesting device for the session list
+func clientInfo(c *gin.Context) authdto.ClientInfo {
+	return authdto.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+}
+
 func (h *AuthHandler) IMAPLogin(c *gin.Context) {
 	var req authdto.ImapLoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondInvalidBody(c)
 		return
 	}
 
-	result, err := h.authUsecase.IMAPLogin(&req)
+	result, err := h.authUsecase.IMAPLogin(&req, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.SetSameSite(http.SameSiteNoneMode)
-	c.SetCookie("refresh_token", result.RefreshToken, 7*24*3600, "/", "", true, true)
+	h.setRefreshCookie(c, result.RefreshToken)
 	result.RefreshToken = ""
 
 	c.JSON(http.StatusOK, result)
@@ -62,18 +75,17 @@ func (h *AuthHandler) IMAPLogin(c *gin.Context) {
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req authdto.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondInvalidBody(c)
 		return
 	}
 
-	result, err := h.authUsecase.Register(&req)
+	result, err := h.authUsecase.Register(&req, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.SetSameSite(http.SameSiteNoneMode)
-	c.SetCookie("refresh_token", result.RefreshToken, 7*24*3600, "/", "", true, true)
+	h.setRefreshCookie(c, result.RefreshToken)
 	result.RefreshToken = ""
 
 	c.JSON(http.StatusOK, result)
@@ -82,25 +94,24 @@ func (h *AuthHandler) Register(c *gin.Context) {
 func (h *AuthHandler) GoogleSignIn(c *gin.Context) {
 	var req authdto.GoogleSignInRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondInvalidBody(c)
 		return
 	}
 
-	result, err := h.authUsecase.GoogleSignIn(req.Code, req.Scope)
+	result, err := h.authUsecase.GoogleSignIn(req.Code, req.Scope, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.SetSameSite(http.SameSiteNoneMode)
-	c.SetCookie("refresh_token", result.RefreshToken, 7*24*3600, "/", "", true, true)
+	h.setRefreshCookie(c, result.RefreshToken)
 	result.RefreshToken = ""
 
 	c.JSON(http.StatusOK, result)
 }
 
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
-	refreshToken, err := c.Cookie("refresh_token")
+	refreshToken, err := c.Cookie(refreshCookieName)
 	if err != nil || refreshToken == "" {
 		var req authdto.RefreshTokenRequest
 		if err := c.ShouldBindJSON(&req); err == nil {
@@ -109,18 +120,24 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	}
 
 	if refreshToken == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh token required"})
+		respondBadRequest(c, "refresh token required")
 		return
 	}
 
-	result, err := h.authUsecase.RefreshToken(refreshToken)
+	result, err := h.authUsecase.RefreshToken(refreshToken, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		if errors.Is(err, usecase.ErrRefreshTokenReused) {
+			// The session is gone; tell the client to sign in again rather than retry
+			h.clearRefreshCookie(c)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "code": apierror.CodeUnauthorized, "reason": "refresh_token_reused"})
+			return
+		}
+		// The token names a user that no longer exists, which is no reason for a 404
+		respondError(c, err, apierror.Mapping{Err: authdomain.ErrUserNotFound, Status: http.StatusUnauthorized, Code: apierror.CodeUnauthorized})
 		return
 	}
 
-	c.SetSameSite(http.SameSiteNoneMode)
-	c.SetCookie("refresh_token", result.RefreshToken, 7*24*3600, "/", "", true, true)
+	h.setRefreshCookie(c, result.RefreshToken)
 	result.RefreshToken = ""
 
 	c.JSON(http.StatusOK, result)
@@ -130,7 +147,7 @@ func (h *AuthHandler) Me(c *gin.Context) {
 	// Get user from context (set by AuthMiddleware)
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		respondError(c, errNotAuthenticated)
 		return
 	}
 
@@ -138,7 +155,7 @@ func (h *AuthHandler) Me(c *gin.Context) {
 }
 
 func (h *AuthHandler) Logout(c *gin.Context) {
-	refreshToken, err := c.Cookie("refresh_token")
+	refreshToken, err := c.Cookie(refreshCookieName)
 	if err != nil || refreshToken == "" {
 		var req authdto.RefreshTokenRequest
 		if err := c.ShouldBindJSON(&req); err == nil {
@@ -150,8 +167,178 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		_ = h.authUsecase.Logout(refreshToken)
 	}
 
-	c.SetSameSite(http.SameSiteNoneMode)
-	c.SetCookie("refresh_token", "", -1, "/", "", true, true)
+	h.clearRefreshCookie(c)
 
 	c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
 }
+
+func (h *AuthHandler) GetSignature(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"signature": userData.Signature})
+}
+
+func (h *AuthHandler) UpdateSignature(c *gin.Context) {
+	var req authdto.SignatureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidBody(c)
+		return
+	}
+
+	if err := h.authUsecase.UpdateSignature(c.GetString("userID"), req.Signature); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"signature": strings.TrimSpace(req.Signature)})
+}
+
+// LogoutAll signs the user out on every device
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	if err := h.authUsecase.LogoutAll(c.GetString("userID")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	h.clearRefreshCookie(c)
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out of all sessions"})
+}
+
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	// The refresh cookie identifies which session is this one
+	current, _ := c.Cookie(refreshCookieName)
+
+	sessions, err := h.authUsecase.ListSessions(c.GetString("userID"), current)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	if err := h.authUsecase.RevokeSession(c.GetString("userID"), c.Param("id")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
+}
+
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req authdto.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidBody(c)
+		return
+	}
+
+	if err := h.authUsecase.RequestPasswordReset(req.Email); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Same answer whether or not the account exists
+	c.JSON(http.StatusOK, gin.H{"message": "if an account exists for this email, a reset link has been sent"})
+}
+
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req authdto.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidBody(c)
+		return
+	}
+
+	if err := h.authUsecase.ResetPassword(req.Token, req.Password); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password has been reset"})
+}
+
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req authdto.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidBody(c)
+		return
+	}
+
+	result, err := h.authUsecase.ChangePassword(c.GetString("userID"), req.OldPassword, req.NewPassword, clientInfo(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Other sessions are signed out; this one continues with the new refresh token
+	h.setRefreshCookie(c, result.RefreshToken)
+	result.RefreshToken = ""
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *AuthHandler) ListLinkedAccounts(c *gin.Context) {
+	accounts, err := h.authUsecase.ListLinkedAccounts(c.GetString("userID"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"accounts": accounts})
+}
+
+func (h *AuthHandler) LinkImapAccount(c *gin.Context) {
+	var req authdto.LinkImapAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidBody(c)
+		return
+	}
+
+	account, err := h.authUsecase.LinkImapAccount(c.GetString("userID"), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, account)
+}
+
+func (h *AuthHandler) LinkGoogleAccount(c *gin.Context) {
+	var req authdto.LinkGoogleAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidBody(c)
+		return
+	}
+
+	account, err := h.authUsecase.LinkGoogleAccount(c.GetString("userID"), &req)
+	if err != nil {
+		if errors.Is(err, usecase.ErrGmailScopeRequired) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": apierror.CodeForbidden, "reason": "gmail_scope_required", "required_scopes": usecase.GmailScopes})
+			return
+		}
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, account)
+}
+
+func (h *AuthHandler) UnlinkAccount(c *gin.Context) {
+	if err := h.authUsecase.UnlinkAccount(c.GetString("userID"), c.Param("id")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "account unlinked"})
+}
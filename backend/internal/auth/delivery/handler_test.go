@@ -0,0 +1,73 @@
+package delivery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	authdto "ga03-backend/internal/auth/dto"
+	"ga03-backend/internal/auth/usecase"
+	"ga03-backend/pkg/config"
+	"ga03-backend/pkg/imap"
+	"ga03-backend/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeAuthUsecase stands in for the auth usecase. It embeds the interface, so a call
+// a test didn't expect panics instead of silently succeeding.
+type fakeAuthUsecase struct {
+	usecase.AuthUsecase
+	imapErr error
+}
+
+func (f *fakeAuthUsecase) IMAPLogin(*authdto.ImapLoginRequest, authdto.ClientInfo) (*authdto.TokenResponse, error) {
+	return nil, f.imapErr
+}
+
+func TestIMAPLoginErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantMsg    string
+	}{
+		{"wrong password", fmt.Errorf("%w (%v)", imap.ErrAuthFailed, "[AUTHENTICATIONFAILED] Invalid credentials"), http.StatusUnauthorized, imap.ErrAuthFailed.Error()},
+		{"app password required", fmt.Errorf("%w (%v)", imap.ErrAppPasswordRequired, "Application-specific password required"), http.StatusUnauthorized, imap.ErrAppPasswordRequired.Error()},
+		{"server unreachable", fmt.Errorf("%w: %v", imap.ErrServerUnreachable, "connection refused"), http.StatusBadGateway, imap.ErrServerUnreachable.Error()},
+		{"too many attempts", &ratelimit.LimitError{RetryAfter: 30 * time.Second}, http.StatusTooManyRequests, "too many requests, please slow down"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewAuthHandler(&fakeAuthUsecase{imapErr: tt.err}, &config.Config{})
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.POST("/auth/imap", h.IMAPLogin)
+
+			body := `{"email":"me@example.com","password":"typo","imapServer":"imap.example.com","imapPort":993}`
+			req := httptest.NewRequest(http.MethodPost, "/auth/imap", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body)
+			}
+			var resp struct {
+				Error string `json:"error"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			// The server's own wording stays in the log
+			if resp.Error != tt.wantMsg {
+				t.Errorf("error = %q, want %q", resp.Error, tt.wantMsg)
+			}
+			if w.Header().Get("Set-Cookie") != "" {
+				t.Error("a failed login set a session cookie")
+			}
+		})
+	}
+}
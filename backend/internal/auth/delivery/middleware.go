@@ -1,10 +1,16 @@
 package delivery
 
 import (
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	authdomain "ga03-backend/internal/auth/domain"
 	"ga03-backend/internal/auth/usecase"
+	"ga03-backend/pkg/apierror"
+	"ga03-backend/pkg/ratelimit"
 
 	"github.com/gin-gonic/gin"
 )
@@ -27,14 +33,14 @@ func AuthMiddleware(authUsecase usecase.AuthUsecase) gin.HandlerFunc {
 		}
 
 		if token == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "authorization header or token query parameter required"})
+			apierror.Write(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "authorization header or token query parameter required")
 			c.Abort()
 			return
 		}
 
 		user, err := authUsecase.ValidateToken(token)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+			apierror.Write(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "invalid or expired token")
 			c.Abort()
 			return
 		}
@@ -44,3 +50,58 @@ func AuthMiddleware(authUsecase usecase.AuthUsecase) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// GmailScopeMiddleware rejects Google users who signed in without granting mail access,
+// so they get a clear answer instead of a Gmail API failure. It must run after AuthMiddleware.
+func GmailScopeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if value, ok := c.Get("user"); ok {
+			if user, ok := value.(*authdomain.User); ok && user.Provider == "google" && !user.HasGmailScope {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error":           "gmail access not granted, please sign in with Google again and allow it",
+					"code":            apierror.CodeForbidden,
+					"reason":          "gmail_scope_required",
+					"required_scopes": usecase.GmailScopes,
+				})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// RateLimitMiddleware throttles each authenticated user with limiter. It must run after
+// AuthMiddleware, which sets the userID it keys on.
+func RateLimitMiddleware(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return rateLimit(limiter, func(c *gin.Context) string { return c.GetString("userID") })
+}
+
+// IPRateLimitMiddleware throttles each client IP with limiter, for endpoints used
+// before the caller is signed in. The client IP only comes from X-Forwarded-For when
+// the engine trusts the proxy that sent it, see config.TrustedProxies.
+func IPRateLimitMiddleware(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return rateLimit(limiter, func(c *gin.Context) string { return c.ClientIP() })
+}
+
+func rateLimit(limiter *ratelimit.Limiter, key func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, retryAfter := limiter.Allow(key(c))
+		if !allowed {
+			RespondTooManyRequests(c, retryAfter)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RespondTooManyRequests answers 429 with a Retry-After header of at least a second
+func RespondTooManyRequests(c *gin.Context, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many requests, please slow down", "code": apierror.CodeRateLimited, "retry_after": seconds})
+}
@@ -0,0 +1,91 @@
+package delivery
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"ga03-backend/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimitMiddlewareThrottlesRapidSummaries(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("userID", c.GetHeader("X-User"))
+		c.Next()
+	})
+	summaries := 0
+	r.GET("/emails/:id/summary", RateLimitMiddleware(ratelimit.NewLimiter(3, time.Minute)), func(c *gin.Context) {
+		summaries++
+		c.JSON(http.StatusOK, gin.H{"summary": "Lunch at noon"})
+	})
+
+	summarize := func(userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/emails/e1/summary", nil)
+		req.Header.Set("X-User", userID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := range 3 {
+		if w := summarize("u1"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200", i+1, w.Code)
+		}
+	}
+	w := summarize("u1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+	if seconds, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || seconds < 1 || seconds > 60 {
+		t.Errorf("Retry-After = %q, want seconds within the window", w.Header().Get("Retry-After"))
+	}
+	if summaries != 3 {
+		t.Errorf("the handler ran %d times, want 3", summaries)
+	}
+
+	// Another user has their own window
+	if w := summarize("u2"); w.Code != http.StatusOK {
+		t.Errorf("another user got status %d, want 200", w.Code)
+	}
+}
+
+func TestIPRateLimitMiddlewareForwardedFor(t *testing.T) {
+	// httptest requests come from 192.0.2.1
+	tests := []struct {
+		name    string
+		proxies []string
+		want    int
+	}{
+		{"untrusted peer can't pick its IP", nil, http.StatusTooManyRequests},
+		{"trusted proxy forwards client IPs", []string{"192.0.2.1"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			if err := r.SetTrustedProxies(tt.proxies); err != nil {
+				t.Fatal(err)
+			}
+			r.POST("/auth/login", IPRateLimitMiddleware(ratelimit.NewLimiter(2, time.Minute)), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			var w *httptest.ResponseRecorder
+			for i := range 3 {
+				req := httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+				req.Header.Set("X-Forwarded-For", "203.0.113."+strconv.Itoa(i+1))
+				w = httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+			}
+			if w.Code != tt.want {
+				t.Errorf("third request with a new X-Forwarded-For: status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
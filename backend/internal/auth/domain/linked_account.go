@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// PrimaryAccountID stands for the user's own account wherever linked accounts are listed
+const PrimaryAccountID = "primary"
+
+// LinkedAccount is another mailbox attached to a user, read alongside their own account
+// in the unified inbox. Credentials are kept like the User's: Google tokens as issued,
+// IMAP passwords encrypted with the configured key ring.
+type LinkedAccount struct {
+	ID           string    `json:"id" gorm:"primaryKey"`
+	UserID       string    `json:"-" gorm:"uniqueIndex:idx_linked_accounts_user_email"`
+	Email        string    `json:"email" gorm:"uniqueIndex:idx_linked_accounts_user_email"`
+	DisplayName  string    `json:"display_name"`
+	Provider     string    `json:"provider"` // "google" or "imap"
+	AccessToken  string    `json:"-"`
+	RefreshToken string    `json:"-"`
+	TokenExpiry  time.Time `json:"-"`
+	ImapServer   string    `json:"imap_server,omitempty"`
+	ImapPort     int       `json:"imap_port,omitempty"`
+	ImapPassword string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
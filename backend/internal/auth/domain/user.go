@@ -1,6 +1,12 @@
 package domain
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+// ErrUserNotFound is returned when a user ID or email names no account
+var ErrUserNotFound = errors.New("user not found")
 
 type User struct {
 	ID           string    `json:"id" gorm:"primaryKey"`
@@ -12,18 +18,53 @@ type User struct {
 	AccessToken  string    `json:"-"` // Google access token (not returned in JSON)
 	RefreshToken string    `json:"-"` // Google refresh token (not returned in JSON)
 	TokenExpiry  time.Time `json:"-"` // When the access token expires
+	WatchExpiration time.Time `json:"-"` // When the Gmail push watch expires
+	LastHistoryID   uint64    `json:"-"` // Gmail history ID notifications have been processed up to
+	HasGmailScope   bool      `json:"has_gmail_scope"` // Whether the Google grant includes mail access
 	
 	// IMAP specific fields
 	ImapServer   string    `json:"imap_server,omitempty"`
 	ImapPort     int       `json:"imap_port,omitempty"`
 	ImapPassword string    `json:"-"` // Store IMAP password (should be encrypted in production)
 
+	// SMTP server for IMAP accounts; derived from ImapServer when empty
+	SmtpServer string `json:"smtp_server,omitempty"`
+	SmtpPort   int    `json:"smtp_port,omitempty"`
+	SmtpTLS    string `json:"smtp_tls,omitempty"` // "tls" (implicit, 465) or "starttls" (587); empty picks by port
+
+	// Reply compose defaults
+	ReplyTopPost      bool `json:"reply_top_post"`
+	ReplyOmitOriginal bool `json:"reply_omit_original"`
+	ArchiveOnReply    bool `json:"archive_on_reply"`
+
+	Signature                  string `json:"signature,omitempty"`
+	SignatureFirstInThreadOnly bool   `json:"signature_first_in_thread_only"`
+	StripQuotedSignatures      bool   `json:"strip_quoted_signatures"`
+
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+// RefreshToken is one signed-in session; a user has one per device or browser.
+// Refreshing replaces Token but keeps the session's ID, which is also the token
+// family: every refresh token issued for the session carries it as family_id.
 type RefreshToken struct {
-	Token     string    `json:"token" gorm:"primaryKey"`
-	UserID    string    `json:"user_id" gorm:"uniqueIndex"`
+	ID            string    `json:"id" gorm:"primaryKey"`
+	Token         string    `json:"-" gorm:"uniqueIndex"`
+	PreviousToken string    `json:"-"` // The token Token replaced, accepted briefly for concurrent refreshes
+	RotatedAt     time.Time `json:"-"`
+	UserID        string    `json:"user_id" gorm:"index"`
+	UserAgent     string    `json:"user_agent"`
+	IPAddress     string    `json:"ip_address"`
+	CreatedAt     time.Time `json:"created_at"`
+	LastUsedAt    time.Time `json:"last_used_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// UsedResetToken records a redeemed password reset token so it can't be replayed.
+// Rows can be dropped once ExpiresAt has passed, since the token no longer verifies.
+type UsedResetToken struct {
+	JTI       string    `json:"jti" gorm:"primaryKey"`
+	UserID    string    `json:"user_id" gorm:"index"`
 	ExpiresAt time.Time `json:"expires_at"`
 }
@@ -1,6 +1,10 @@
 package dto
 
-import authdomain "ga03-backend/internal/auth/domain"
+import (
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+)
 
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
@@ -18,20 +22,81 @@ type GoogleSignInRequest struct {
 	Scope       []string `json:"scope" binding:"required"`
 }
 
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+type SignatureRequest struct {
+	Signature string `json:"signature" binding:"max=10000"`
+}
+
+type LinkImapAccountRequest struct {
+	Email       string `json:"email" binding:"required,email"`
+	Password    string `json:"password" binding:"required"`
+	ImapServer  string `json:"imapServer" binding:"required"`
+	ImapPort    int    `json:"imapPort" binding:"required"`
+	DisplayName string `json:"displayName"`
+}
+
+type LinkGoogleAccountRequest struct {
+	Code        string   `json:"code" binding:"required"`
+	Scope       []string `json:"scope"`
+	DisplayName string   `json:"displayName"`
+}
+
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
 type ImapLoginRequest struct {
 	Email      string `json:"email" binding:"required,email"`
-	Password   string `json:"password" binding:"required"`
+	Password   string `json:"password" binding:"required_without=AccessToken"`
 	ImapServer string `json:"imapServer" binding:"required"`
 	ImapPort   int    `json:"imapPort" binding:"required"`
+
+	// Optional OAuth2 access token; when set it signs in with XOAUTH2 instead of the password
+	AccessToken string `json:"accessToken"`
+
+	// Optional; the SMTP server is derived from the IMAP server when empty
+	SmtpServer string `json:"smtpServer"`
+	SmtpPort   int    `json:"smtpPort"`
+	SmtpTLS    string `json:"smtpTls" binding:"omitempty,oneof=tls starttls"`
+}
+
+// ClientInfo describes the device a session was started or last refreshed from
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
 }
 
 type TokenResponse struct {
 	AccessToken  string              `json:"access_token"`
 	RefreshToken string              `json:"refresh_token"`
 	User         *authdomain.User    `json:"user"`
+
+	// Set when a Google sign-in didn't grant mail access; the client should ask
+	// for RequiredScopes again (incremental consent) before opening the inbox
+	NeedsGmailConsent bool     `json:"needs_gmail_consent,omitempty"`
+	RequiredScopes    []string `json:"required_scopes,omitempty"`
 }
 
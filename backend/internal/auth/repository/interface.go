@@ -1,6 +1,10 @@
 package repository
 
-import authdomain "ga03-backend/internal/auth/domain"
+import (
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+)
 
 // UserRepository defines the interface for user repository operations
 type UserRepository interface {
@@ -8,9 +12,20 @@ type UserRepository interface {
 	FindByEmail(email string) (*authdomain.User, error)
 	FindByID(id string) (*authdomain.User, error)
 	Update(user *authdomain.User) error
+	FindWithImapPassword() ([]*authdomain.User, error)
+	FindWatchesExpiringBefore(t time.Time) ([]*authdomain.User, error)
+	UseResetToken(token *authdomain.UsedResetToken) (bool, error)
 	SaveRefreshToken(token *authdomain.RefreshToken) error
+	UpdateRefreshToken(token *authdomain.RefreshToken, current string) (bool, error)
 	FindRefreshToken(token string) (*authdomain.RefreshToken, error)
+	FindRefreshTokenByID(id string) (*authdomain.RefreshToken, error)
+	FindRefreshTokensByUser(userID string) ([]*authdomain.RefreshToken, error)
 	DeleteRefreshToken(token string) error
+	DeleteRefreshTokenByID(userID, id string) (bool, error)
 	DeleteRefreshTokensByUser(userId string) error
-	ReplaceRefreshToken(token *authdomain.RefreshToken) error
+	SaveLinkedAccount(account *authdomain.LinkedAccount) error
+	FindLinkedAccounts(userID string) ([]*authdomain.LinkedAccount, error)
+	FindLinkedAccount(userID, id string) (*authdomain.LinkedAccount, error)
+	FindLinkedWithImapPassword() ([]*authdomain.LinkedAccount, error)
+	DeleteLinkedAccount(userID, id string) (bool, error)
 }
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	authdomain "ga03-backend/internal/auth/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MigrateLegacyRefreshTokens moves a refresh_tokens table from before sessions, keyed by
+// the token with one row per user, to session IDs. Every stored token gets an ID, so
+// users stay signed in. Run it before AutoMigrate adds the remaining session columns;
+// it reports whether there was anything to migrate.
+func MigrateLegacyRefreshTokens(db *gorm.DB) (bool, error) {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&authdomain.RefreshToken{}) || migrator.HasColumn(&authdomain.RefreshToken{}, "ID") {
+		return false, nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("ALTER TABLE refresh_tokens ADD COLUMN id text").Error; err != nil {
+			return err
+		}
+
+		var tokens []string
+		if err := tx.Table("refresh_tokens").Pluck("token", &tokens).Error; err != nil {
+			return err
+		}
+		for _, token := range tokens {
+			if err := tx.Exec("UPDATE refresh_tokens SET id = ? WHERE token = ?", uuid.New().String(), token).Error; err != nil {
+				return err
+			}
+		}
+
+		// The token stops being the key, and a user may now hold several sessions
+		for _, stmt := range []string{
+			"ALTER TABLE refresh_tokens DROP CONSTRAINT IF EXISTS refresh_tokens_pkey",
+			"ALTER TABLE refresh_tokens ADD PRIMARY KEY (id)",
+			"DROP INDEX IF EXISTS idx_refresh_tokens_user_id",
+		} {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return err == nil, err
+}
+
+// BackfillLegacySessions dates the sessions MigrateLegacyRefreshTokens carried over,
+// whose new columns AutoMigrate left empty
+func BackfillLegacySessions(db *gorm.DB) error {
+	return db.Exec("UPDATE refresh_tokens SET created_at = NOW(), last_used_at = NOW() WHERE created_at IS NULL").Error
+}
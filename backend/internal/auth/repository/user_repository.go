@@ -9,6 +9,7 @@ import (
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // userRepository implements UserRepository interface
@@ -59,8 +60,58 @@ func (r *userRepository) Update(user *authdomain.User) error {
 	return r.db.Save(user).Error
 }
 
+// FindWithImapPassword returns every user with a stored (encrypted) IMAP password
+func (r *userRepository) FindWithImapPassword() ([]*authdomain.User, error) {
+	var users []*authdomain.User
+	err := r.db.Where("imap_password <> ''").Find(&users).Error
+	return users, err
+}
+
+// FindWatchesExpiringBefore returns Google users whose Gmail watch lapses before t.
+// Users that signed out have no refresh token and are skipped.
+func (r *userRepository) FindWatchesExpiringBefore(t time.Time) ([]*authdomain.User, error) {
+	var users []*authdomain.User
+	err := r.db.Where("watch_expiration > ? AND watch_expiration < ? AND refresh_token <> ''", time.Time{}, t).Find(&users).Error
+	return users, err
+}
+
+// UseResetToken records a reset token as redeemed. It returns false if it already was.
+func (r *userRepository) UseResetToken(token *authdomain.UsedResetToken) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(token)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// SaveRefreshToken starts a session, dropping the user's expired ones on the way
 func (r *userRepository) SaveRefreshToken(token *authdomain.RefreshToken) error {
-	return r.db.Create(token).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND expires_at < ?", token.UserID, time.Now()).Delete(&authdomain.RefreshToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+// UpdateRefreshToken stores a rotated session, but only if its token is still current.
+// It returns false if another request rotated or revoked the session first.
+func (r *userRepository) UpdateRefreshToken(token *authdomain.RefreshToken, current string) (bool, error) {
+	result := r.db.Model(&authdomain.RefreshToken{}).
+		Where("id = ? AND token = ?", token.ID, current).
+		Updates(map[string]interface{}{
+			"token":          token.Token,
+			"previous_token": token.PreviousToken,
+			"rotated_at":     token.RotatedAt,
+			"user_agent":     token.UserAgent,
+			"ip_address":     token.IPAddress,
+			"last_used_at":   token.LastUsedAt,
+			"expires_at":     token.ExpiresAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
 }
 
 func (r *userRepository) FindRefreshToken(token string) (*authdomain.RefreshToken, error) {
@@ -75,35 +126,96 @@ func (r *userRepository) FindRefreshToken(token string) (*authdomain.RefreshToke
 	return &refreshToken, nil
 }
 
+func (r *userRepository) FindRefreshTokenByID(id string) (*authdomain.RefreshToken, error) {
+	var refreshToken authdomain.RefreshToken
+	err := r.db.Where("id = ?", id).First(&refreshToken).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &refreshToken, nil
+}
+
+// FindRefreshTokensByUser returns the user's unexpired sessions, most recently used first
+func (r *userRepository) FindRefreshTokensByUser(userID string) ([]*authdomain.RefreshToken, error) {
+	var tokens []*authdomain.RefreshToken
+	err := r.db.Where("user_id = ? AND expires_at > ?", userID, time.Now()).Order("last_used_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
 func (r *userRepository) DeleteRefreshToken(token string) error {
 	return r.db.Where("token = ?", token).Delete(&authdomain.RefreshToken{}).Error
 }
 
+// DeleteRefreshTokenByID ends one of the user's sessions. It returns false if the user has no such session.
+func (r *userRepository) DeleteRefreshTokenByID(userID, id string) (bool, error) {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&authdomain.RefreshToken{})
+	return result.RowsAffected > 0, result.Error
+}
+
 func (r *userRepository) DeleteRefreshTokensByUser(userID string) error {
 	return r.db.Where("user_id = ?", userID).Delete(&authdomain.RefreshToken{}).Error
 }
 
-// ReplaceRefreshToken replaces any existing refresh tokens for the user and inserts the new one.
-// We implement this as a transaction: delete existing tokens for user_id then insert the new token.
-// This avoids requiring an ON CONFLICT unique constraint on user_id.
-func (r *userRepository) ReplaceRefreshToken(token *authdomain.RefreshToken) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Where("user_id = ?", token.UserID).Delete(&authdomain.RefreshToken{}).Error; err != nil {
-			return err
-		}
-		if err := tx.Create(token).Error; err != nil {
-			return err
+// SaveLinkedAccount creates or updates a linked account; one without an ID is new
+func (r *userRepository) SaveLinkedAccount(account *authdomain.LinkedAccount) error {
+	account.UpdatedAt = time.Now()
+	if account.ID == "" {
+		account.ID = uuid.New().String()
+		account.CreatedAt = account.UpdatedAt
+		return r.db.Create(account).Error
+	}
+	return r.db.Save(account).Error
+}
+
+func (r *userRepository) FindLinkedAccounts(userID string) ([]*authdomain.LinkedAccount, error) {
+	var accounts []*authdomain.LinkedAccount
+	err := r.db.Where("user_id = ?", userID).Order("created_at").Find(&accounts).Error
+	return accounts, err
+}
+
+func (r *userRepository) FindLinkedAccount(userID, id string) (*authdomain.LinkedAccount, error) {
+	var account authdomain.LinkedAccount
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&account).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
 		}
-		return nil
-	})
+		return nil, err
+	}
+	return &account, nil
+}
+
+// FindLinkedWithImapPassword returns every linked account with a stored (encrypted) IMAP password
+func (r *userRepository) FindLinkedWithImapPassword() ([]*authdomain.LinkedAccount, error) {
+	var accounts []*authdomain.LinkedAccount
+	err := r.db.Where("imap_password <> ''").Find(&accounts).Error
+	return accounts, err
 }
 
-// HashPassword hashes a password using bcrypt
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+// DeleteLinkedAccount unlinks one of the user's accounts. It returns false if the user has no such account.
+func (r *userRepository) DeleteLinkedAccount(userID, id string) (bool, error) {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&authdomain.LinkedAccount{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// HashPassword hashes a password using bcrypt with the given cost
+func HashPassword(password string, cost int) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	return string(bytes), err
 }
 
+// NeedsRehash reports whether a hash was generated with a lower cost than the target
+func NeedsRehash(hash string, cost int) bool {
+	current, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false
+	}
+	return current < cost
+}
+
 // CheckPasswordHash compares a password with a hash
 func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
@@ -6,8 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	authdomain "ga03-backend/internal/auth/domain"
@@ -15,7 +17,8 @@ import (
 	"ga03-backend/internal/auth/repository"
 	"ga03-backend/pkg/config"
 	"ga03-backend/pkg/imap"
-	"ga03-backend/pkg/utils/crypto"
+	"ga03-backend/pkg/mailer"
+	"ga03-backend/pkg/ratelimit"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
@@ -23,57 +26,136 @@ import (
 	"golang.org/x/oauth2/google"
 )
 
+var (
+	// ErrInvalidCredentials is returned for an unknown email and a wrong password alike
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrUseGoogleSignIn is returned when a password login is tried on a Google or IMAP account
+	ErrUseGoogleSignIn = errors.New("please use Google Sign-In for this account")
+	// ErrEmailTaken is returned when registering an email that already has an account
+	ErrEmailTaken = errors.New("email already registered")
+	// ErrInvalidRefreshToken is returned for a refresh token that doesn't parse or verify
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenExpired is returned for a refresh token whose session has ended
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+	// ErrGoogleVerification is returned when a Google auth code can't be exchanged or verified
+	ErrGoogleVerification = errors.New("could not verify the Google account")
+	// ErrGoogleEmailUnverified is returned for Google accounts whose email Google hasn't verified
+	ErrGoogleEmailUnverified = errors.New("google email is not verified")
+	// ErrGoogleServerRequired is returned for an IMAP login with a Google token to a non-Google server
+	ErrGoogleServerRequired = errors.New("google sign-in only works with Gmail's IMAP and SMTP servers")
+	// ErrIMAPServerMismatch is returned when an IMAP login names a different server than the account uses
+	ErrIMAPServerMismatch = errors.New("this account is linked to a different mail server")
+)
+
 // authUsecase implements AuthUsecase interface
 type authUsecase struct {
-	userRepo repository.UserRepository
-	config   *config.Config
+	userRepo    repository.UserRepository
+	config      *config.Config
+	mailer      *mailer.Mailer
+	resetLimit  *ratelimit.Limiter // Reset emails per address, so the endpoint can't flood an inbox
+	loginLimit  *ratelimit.Limiter // Sign-in attempts per account, against guessing from many IPs
+	logoutHooks []func(userID string)
+	imapHooks   []func(userID string) // Called when a user's IMAP credentials change
 }
 
 // NewAuthUsecase creates a new instance of authUsecase
 func NewAuthUsecase(userRepo repository.UserRepository, cfg *config.Config) AuthUsecase {
 	return &authUsecase{
-		userRepo: userRepo,
-		config:   cfg,
+		userRepo:   userRepo,
+		config:     cfg,
+		mailer:     mailer.New(cfg.MailServer, cfg.MailPort, cfg.MailUsername, cfg.MailPassword, cfg.MailFrom),
+		resetLimit: ratelimit.NewLimiter(3, time.Hour),
+		loginLimit: ratelimit.NewLimiter(cfg.LoginRateLimit, cfg.LoginRateWindow),
 	}
 }
 
-func (u *authUsecase) Login(req *authdto.LoginRequest) (*authdto.TokenResponse, error) {
+func (u *authUsecase) Login(req *authdto.LoginRequest, client authdto.ClientInfo) (*authdto.TokenResponse, error) {
+	if err := u.allowLogin(req.Email); err != nil {
+		return nil, err
+	}
+
 	user, err := u.userRepo.FindByEmail(req.Email)
 	if err != nil {
 		return nil, err
 	}
 
 	if user == nil {
-		return nil, errors.New("invalid email or password")
+		return nil, ErrInvalidCredentials
 	}
 
 	if user.Provider != "email" {
-		return nil, errors.New("please use Google Sign-In for this account")
+		return nil, ErrUseGoogleSignIn
 	}
 
 	if !repository.CheckPasswordHash(req.Password, user.Password) {
-		return nil, errors.New("invalid email or password")
+		return nil, ErrInvalidCredentials
+	}
+
+	// Transparently upgrade hashes created with a lower bcrypt cost
+	if repository.NeedsRehash(user.Password, u.config.BcryptCost) {
+		if hashed, err := repository.HashPassword(req.Password, u.config.BcryptCost); err == nil {
+			user.Password = hashed
+			if err := u.userRepo.Update(user); err != nil {
+				fmt.Printf("Failed to upgrade password hash for user %s: %v\n", user.ID, err)
+			}
+		}
 	}
 
-	return u.generateTokens(user)
+	return u.generateTokens(user, client)
 }
 
-func (u *authUsecase) IMAPLogin(req *authdto.ImapLoginRequest) (*authdto.TokenResponse, error) {
-	// 1. Try to connect and login to IMAP server
-	client, err := imap.ConnectAndLogin(req.ImapServer, req.ImapPort, req.Email, req.Password)
-	if err != nil {
-		return nil, fmt.Errorf("IMAP authentication failed: %w", err)
+func (u *authUsecase) IMAPLogin(req *authdto.ImapLoginRequest, client authdto.ClientInfo) (*authdto.TokenResponse, error) {
+	if err := u.allowLogin(req.Email); err != nil {
+		return nil, err
 	}
-	defer client.Logout()
 
-	// 2. Check if user exists
+	// 1. Check if user exists
 	user, err := u.userRepo.FindByEmail(req.Email)
 	if err != nil {
 		return nil, err
 	}
 
-	// Encrypt password
-	encryptedPass, err := crypto.Encrypt(req.Password, u.config.EncryptionKey)
+	// Anyone can call this endpoint, so it must not let a caller point an existing
+	// account at a server they run, which would accept any password or token
+	if req.AccessToken != "" {
+		// The Google grant is only ever used with Google's servers, and only for the
+		// Google account the access token belongs to
+		if !imap.IsGoogleIMAPServer(req.ImapServer) || (req.SmtpServer != "" && !imap.IsGoogleSMTPServer(req.SmtpServer)) {
+			return nil, ErrGoogleServerRequired
+		}
+		if user == nil || user.RefreshToken == "" {
+			return nil, ErrUseGoogleSignIn
+		}
+		tokenInfo, err := googleUserInfo(req.AccessToken)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.EqualFold(tokenInfo.Email, user.Email) {
+			return nil, fmt.Errorf("%w: access token belongs to another account", ErrGoogleVerification)
+		}
+	} else if user != nil {
+		if user.RefreshToken != "" {
+			return nil, ErrUseGoogleSignIn
+		}
+		if !imap.SameServer(user.ImapServer, req.ImapServer) {
+			return nil, ErrIMAPServerMismatch
+		}
+	}
+
+	// 2. Try to connect and login to IMAP server, so bad credentials never get an account
+	imapClient, err := imap.ConnectAndLogin(req.ImapServer, req.ImapPort, req.Email, req.Password, req.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	defer imapClient.Logout()
+
+	// Encrypt password. An access token expires within the hour, so it isn't stored:
+	// later connections get fresh ones from the Google grant the user signed in with.
+	secret := req.Password
+	if req.AccessToken != "" {
+		secret = imap.GoogleGrantSecret()
+	}
+	encryptedPass, err := u.config.Keyring.Encrypt(secret)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt password: %w", err)
 	}
@@ -87,16 +169,25 @@ func (u *authUsecase) IMAPLogin(req *authdto.ImapLoginRequest) (*authdto.TokenRe
 			ImapServer:   req.ImapServer,
 			ImapPort:     req.ImapPort,
 			ImapPassword: encryptedPass, // Store encrypted password
+			SmtpServer:   req.SmtpServer,
+			SmtpPort:     req.SmtpPort,
+			SmtpTLS:      req.SmtpTLS,
 		}
 		if err := u.userRepo.Create(user); err != nil {
 			return nil, err
 		}
 	} else {
 		// Update existing user's IMAP credentials
-		// This allows users to update their password or server settings by logging in again
+		// This allows users to update their password or port by logging in again
 		user.ImapServer = req.ImapServer
 		user.ImapPort = req.ImapPort
 		user.ImapPassword = encryptedPass
+		// Keep stored SMTP settings when a login doesn't give any
+		if req.SmtpServer != "" {
+			user.SmtpServer = req.SmtpServer
+			user.SmtpPort = req.SmtpPort
+			user.SmtpTLS = req.SmtpTLS
+		}
 		
 		// If the user was previously a different provider, we might want to handle that
 		// For now, we just update the provider to imap if it wasn't
@@ -107,23 +198,35 @@ func (u *authUsecase) IMAPLogin(req *authdto.ImapLoginRequest) (*authdto.TokenRe
 		if err := u.userRepo.Update(user); err != nil {
 			return nil, err
 		}
+		for _, hook := range u.imapHooks {
+			hook(user.ID)
+		}
 	}
 
 	// 4. Generate tokens
-	return u.generateTokens(user)
+	return u.generateTokens(user, client)
 }
 
-func (u *authUsecase) Register(req *authdto.RegisterRequest) (*authdto.TokenResponse, error) {
+// allowLogin counts a sign-in attempt against the account it names. Every attempt
+// counts, not just failures, so a correct guess can't slip in once the limit is hit.
+func (u *authUsecase) allowLogin(email string) error {
+	if allowed, retryAfter := u.loginLimit.Allow(strings.ToLower(strings.TrimSpace(email))); !allowed {
+		return &ratelimit.LimitError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+func (u *authUsecase) Register(req *authdto.RegisterRequest, client authdto.ClientInfo) (*authdto.TokenResponse, error) {
 	existing, err := u.userRepo.FindByEmail(req.Email)
 	if err != nil {
 		return nil, err
 	}
 
 	if existing != nil {
-		return nil, errors.New("email already registered")
+		return nil, ErrEmailTaken
 	}
 
-	hashedPassword, err := repository.HashPassword(req.Password)
+	hashedPassword, err := repository.HashPassword(req.Password, u.config.BcryptCost)
 	if err != nil {
 		return nil, err
 	}
@@ -139,7 +242,7 @@ func (u *authUsecase) Register(req *authdto.RegisterRequest) (*authdto.TokenResp
 		return nil, err
 	}
 
-	return u.generateTokens(user)
+	return u.generateTokens(user, client)
 }
 
 // GoogleTokenInfo represents the response from Google's userinfo endpoint
@@ -151,7 +254,33 @@ type GoogleTokenInfo struct {
 	Sub           string `json:"sub"`
 }
 
-func (u *authUsecase) GoogleSignIn(code string, scope []string) (*authdto.TokenResponse, error) {
+// GmailScopes are the Google scopes that give the app mail access; granting any one is enough
+var GmailScopes = []string{
+	"https://www.googleapis.com/auth/gmail.modify",
+	"https://mail.google.com/",
+}
+
+func hasAnyScope(granted, wanted []string) bool {
+	for _, g := range granted {
+		for _, w := range wanted {
+			if g == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// grantsGmail reports whether a Google token came with mail access. It trusts the
+// scopes Google granted, not the ones the client says it asked for.
+func grantsGmail(token *oauth2.Token) bool {
+	granted, _ := token.Extra("scope").(string)
+	return hasAnyScope(strings.Fields(granted), GmailScopes)
+}
+
+// exchangeGoogleCode trades an auth code from the frontend for tokens and the
+// verified profile of the Google account that granted them
+func (u *authUsecase) exchangeGoogleCode(code string, scope []string) (*oauth2.Token, *GoogleTokenInfo, error) {
 	conf := &oauth2.Config{
         ClientID:     u.config.GoogleClientID,
         ClientSecret: u.config.GoogleClientSecret,
@@ -161,49 +290,69 @@ func (u *authUsecase) GoogleSignIn(code string, scope []string) (*authdto.TokenR
     }
 	token, err := conf.Exchange(context.Background(), code)
     if err != nil {
-        return nil, fmt.Errorf("google oauth exchange failed: %v", err)
+        return nil, nil, fmt.Errorf("%w: oauth exchange failed: %v", ErrGoogleVerification, err)
     }
-	accessToken := token.AccessToken
-    refreshToken := token.RefreshToken
-	tokenExpiry := token.Expiry
+	tokenInfo, err := googleUserInfo(token.AccessToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	return token, tokenInfo, nil
+}
 
+// googleUserInfo returns the verified profile of the Google account an access
+// token was issued to
+func googleUserInfo(accessToken string) (*GoogleTokenInfo, error) {
 	url := "https://www.googleapis.com/oauth2/v3/userinfo"
 	
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return nil, errors.New("failed to create request: " + err.Error())
+		return nil, fmt.Errorf("%w: failed to create request: %v", ErrGoogleVerification, err)
 	}
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, errors.New("failed to verify Google token: " + err.Error())
+		return nil, fmt.Errorf("%w: userinfo request failed: %v", ErrGoogleVerification, err)
 	}
 	defer resp.Body.Close()
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, errors.New("failed to read response body: " + err.Error())
+		return nil, fmt.Errorf("%w: failed to read response body: %v", ErrGoogleVerification, err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		errMsg := fmt.Sprintf("failed to verify Google token: status %d, body: %s", resp.StatusCode, string(bodyBytes))
 		fmt.Println("Error:", errMsg)
-		return nil, errors.New(errMsg)
+		return nil, fmt.Errorf("%w: %s", ErrGoogleVerification, errMsg)
 	}
 
 	fmt.Printf("Google UserInfo Response: %s\n", string(bodyBytes))
 
 	var tokenInfo GoogleTokenInfo
 	if err := json.Unmarshal(bodyBytes, &tokenInfo); err != nil {
-		return nil, errors.New("failed to decode Google token info: " + err.Error())
+		return nil, fmt.Errorf("%w: failed to decode Google token info: %v", ErrGoogleVerification, err)
 	}
 
 	// Verify that email is verified (Google returns "true" as string)
 	if tokenInfo.EmailVerified != true {
-		return nil, errors.New("google email is not verified")
+		return nil, ErrGoogleEmailUnverified
 	}
 
+	return &tokenInfo, nil
+}
+
+func (u *authUsecase) GoogleSignIn(code string, scope []string, client authdto.ClientInfo) (*authdto.TokenResponse, error) {
+	token, tokenInfo, err := u.exchangeGoogleCode(code, scope)
+	if err != nil {
+		return nil, err
+	}
+	accessToken := token.AccessToken
+	refreshToken := token.RefreshToken
+	tokenExpiry := token.Expiry
+
+	hasGmailScope := grantsGmail(token)
+
 	// Find or create user
 	user, err := u.userRepo.FindByEmail(tokenInfo.Email)
 	if err != nil {
@@ -220,6 +369,7 @@ func (u *authUsecase) GoogleSignIn(code string, scope []string) (*authdto.TokenR
 			AccessToken:  accessToken,
 			RefreshToken: refreshToken,
 			TokenExpiry: tokenExpiry,
+			HasGmailScope: hasGmailScope,
 		}
 		if err := u.userRepo.Create(user); err != nil {
 			fmt.Printf("Error creating user: %v\n", err)
@@ -232,7 +382,11 @@ func (u *authUsecase) GoogleSignIn(code string, scope []string) (*authdto.TokenR
 		user.Name = tokenInfo.Name
 		user.AvatarURL = tokenInfo.Picture
 		user.AccessToken = accessToken
-		user.RefreshToken = refreshToken
+		// Google only returns a refresh token on first consent; keep the stored one otherwise
+		if refreshToken != "" {
+			user.RefreshToken = refreshToken
+		}
+		user.HasGmailScope = hasGmailScope
 		if err := u.userRepo.Update(user); err != nil {
 			fmt.Printf("Error updating user: %v\n", err)
 			return nil, err
@@ -241,28 +395,37 @@ func (u *authUsecase) GoogleSignIn(code string, scope []string) (*authdto.TokenR
 	}
 
 	fmt.Println("Generating tokens...")
-	tokenResp, err := u.generateTokens(user)
+	tokenResp, err := u.generateTokens(user, client)
 	if err != nil {
 		fmt.Printf("Error generating tokens: %v\n", err)
 		return nil, err
 	}
 	fmt.Println("Tokens generated successfully")
+	if !hasGmailScope {
+		tokenResp.NeedsGmailConsent = true
+		tokenResp.RequiredScopes = GmailScopes
+	}
 	return tokenResp, nil
 }
 
-func (u *authUsecase) RefreshToken(refreshToken string) (*authdto.TokenResponse, error) {
+func (u *authUsecase) RefreshToken(refreshToken string, client authdto.ClientInfo) (*authdto.TokenResponse, error) {
 	// Verify refresh token
 	token, err := jwt.Parse(refreshToken, func(token *jwt.Token) (interface{}, error) {
 		return []byte(u.config.JWTSecret), nil
 	})
 
 	if err != nil || !token.Valid {
-		return nil, errors.New("invalid refresh token")
+		return nil, ErrInvalidRefreshToken
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return nil, errors.New("invalid token claims")
+		return nil, ErrInvalidRefreshToken
+	}
+
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return nil, ErrInvalidRefreshToken
 	}
 
 	// Check if token exists in repository
@@ -271,14 +434,16 @@ func (u *authUsecase) RefreshToken(refreshToken string) (*authdto.TokenResponse,
 		return nil, err
 	}
 
-	if storedToken == nil || storedToken.ExpiresAt.Before(time.Now()) {
-		return nil, errors.New("refresh token expired")
+	if storedToken == nil {
+		familyID, _ := claims["family_id"].(string)
+		storedToken, err = u.findRotatedSession(familyID, userID, refreshToken)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Get user
-	userID, ok := claims["user_id"].(string)
-	if !ok {
-		return nil, errors.New("invalid token claims")
+	if storedToken == nil || storedToken.ExpiresAt.Before(time.Now()) {
+		return nil, ErrRefreshTokenExpired
 	}
 
 	user, err := u.userRepo.FindByID(userID)
@@ -287,10 +452,63 @@ func (u *authUsecase) RefreshToken(refreshToken string) (*authdto.TokenResponse,
 	}
 
 	if user == nil {
-		return nil, errors.New("user not found")
+		return nil, authdomain.ErrUserNotFound
+	}
+
+	// Rotate the token inside the same session so it keeps its ID. The update only applies
+	// if nobody rotated the session since it was read. A request that loses that race
+	// isn't a reuse: it reloads the session, where its token is now the previous one.
+	for attempt := 0; attempt < 2; attempt++ {
+		current := storedToken.Token
+		resp, err := u.issueTokens(user, storedToken, client, func(session *authdomain.RefreshToken) error {
+			rotated, err := u.userRepo.UpdateRefreshToken(session, current)
+			if err == nil && !rotated {
+				return errRotationLost
+			}
+			return err
+		})
+		if !errors.Is(err, errRotationLost) {
+			return resp, err
+		}
+
+		storedToken, err = u.userRepo.FindRefreshTokenByID(storedToken.ID)
+		if err != nil {
+			return nil, err
+		}
+		if storedToken == nil {
+			return nil, ErrRefreshTokenExpired
+		}
+		// Rotated more than once meanwhile; still a race, so the session is kept
+		if storedToken.PreviousToken != refreshToken || time.Since(storedToken.RotatedAt) >= refreshReuseGrace {
+			break
+		}
+	}
+	return nil, ErrRefreshConflict
+}
+
+// findRotatedSession handles a validly signed refresh token that is no longer stored.
+// If the token was replaced a moment ago another tab is refreshing at the same time,
+// and its session is returned. Any older token of a live session means it leaked and
+// was used by someone else, so the whole session is revoked.
+func (u *authUsecase) findRotatedSession(familyID, userID, refreshToken string) (*authdomain.RefreshToken, error) {
+	if familyID == "" {
+		return nil, nil
+	}
+
+	session, err := u.userRepo.FindRefreshTokenByID(familyID)
+	if err != nil || session == nil || session.UserID != userID {
+		return nil, err
 	}
 
-	return u.generateTokens(user)
+	if session.PreviousToken == refreshToken && time.Since(session.RotatedAt) < refreshReuseGrace {
+		return session, nil
+	}
+
+	log.Printf("Refresh token reuse detected for user %s, revoking session %s", userID, session.ID)
+	if _, err := u.userRepo.DeleteRefreshTokenByID(userID, session.ID); err != nil {
+		return nil, err
+	}
+	return nil, ErrRefreshTokenReused
 }
 
 func (u *authUsecase) Logout(refreshToken string) error {
@@ -301,30 +519,67 @@ func (u *authUsecase) Logout(refreshToken string) error {
 	}
 
 	if token != nil {
-		// Get the user to check for Google OAuth
-		user, err := u.userRepo.FindByID(token.UserID)
-		if err == nil && user != nil && user.Provider == "google" && user.RefreshToken != "" {
-			// Revoke Google token
-			revokeURL := "https://oauth2.googleapis.com/revoke"
-			resp, err := http.PostForm(revokeURL, url.Values{"token": {user.RefreshToken}})
-			if err != nil {
-				fmt.Printf("Failed to revoke Google token: %v\n", err)
-			} else {
-				resp.Body.Close()
-
-				// Clear Google tokens from user record
-				user.AccessToken = ""
-				user.RefreshToken = ""
-				user.TokenExpiry = time.Time{}
-				u.userRepo.Update(user)
-			}
+		// Only give up Gmail access when this is the user's last session
+		sessions, err := u.userRepo.FindRefreshTokensByUser(token.UserID)
+		if err == nil && len(sessions) <= 1 {
+			u.revokeGoogleToken(token.UserID)
+		}
+	}
+
+	if token != nil {
+		for _, hook := range u.logoutHooks {
+			hook(token.UserID)
 		}
 	}
 
 	return u.userRepo.DeleteRefreshToken(refreshToken)
 }
 
-func (u *authUsecase) generateTokens(user *authdomain.User) (*authdto.TokenResponse, error) {
+// revokeGoogleToken revokes the user's Google grant and clears the stored tokens
+func (u *authUsecase) revokeGoogleToken(userID string) {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil || user == nil || user.Provider != "google" || user.RefreshToken == "" {
+		return
+	}
+
+	revokeURL := "https://oauth2.googleapis.com/revoke"
+	resp, err := http.PostForm(revokeURL, url.Values{"token": {user.RefreshToken}})
+	if err != nil {
+		fmt.Printf("Failed to revoke Google token: %v\n", err)
+		return
+	}
+	resp.Body.Close()
+
+	// Clear Google tokens from user record
+	user.AccessToken = ""
+	user.RefreshToken = ""
+	user.TokenExpiry = time.Time{}
+	u.userRepo.Update(user)
+}
+
+// OnLogout registers a function called with the user's ID when they log out
+func (u *authUsecase) OnLogout(fn func(userID string)) {
+	u.logoutHooks = append(u.logoutHooks, fn)
+}
+
+// OnImapCredentialsChange registers a function called with the user's ID when an IMAP
+// login replaces their stored server or credentials
+func (u *authUsecase) OnImapCredentialsChange(fn func(userID string)) {
+	u.imapHooks = append(u.imapHooks, fn)
+}
+
+// generateTokens signs the user in on a new session
+func (u *authUsecase) generateTokens(user *authdomain.User, client authdto.ClientInfo) (*authdto.TokenResponse, error) {
+	session := &authdomain.RefreshToken{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		CreatedAt: time.Now(),
+	}
+	return u.issueTokens(user, session, client, u.userRepo.SaveRefreshToken)
+}
+
+// issueTokens signs a new token pair for the session and stores it with save
+func (u *authUsecase) issueTokens(user *authdomain.User, session *authdomain.RefreshToken, client authdto.ClientInfo, save func(*authdomain.RefreshToken) error) (*authdto.TokenResponse, error) {
 	// Generate access token
 	accessToken, err := u.generateAccessToken(user)
 	if err != nil {
@@ -332,18 +587,21 @@ func (u *authUsecase) generateTokens(user *authdomain.User) (*authdto.TokenRespo
 	}
 
 	// Generate refresh token
-	refreshToken, err := u.generateRefreshToken(user)
+	refreshToken, err := u.generateRefreshToken(user, session.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Use repository ReplaceRefreshToken to atomically replace any existing token for this user
-	refreshTokenEntity := &authdomain.RefreshToken{
-		Token:     refreshToken,
-		UserID:    user.ID,
-		ExpiresAt: time.Now().Add(u.config.JWTRefreshExpiry),
+	if session.Token != "" {
+		session.PreviousToken = session.Token
+		session.RotatedAt = time.Now()
 	}
-	if err := u.userRepo.ReplaceRefreshToken(refreshTokenEntity); err != nil {
+	session.Token = refreshToken
+	session.UserAgent = client.UserAgent
+	session.IPAddress = client.IPAddress
+	session.LastUsedAt = time.Now()
+	session.ExpiresAt = time.Now().Add(u.config.JWTRefreshExpiry)
+	if err := save(session); err != nil {
 		return nil, err
 	}
 
@@ -366,12 +624,13 @@ func (u *authUsecase) generateAccessToken(user *authdomain.User) (string, error)
 	return token.SignedString([]byte(u.config.JWTSecret))
 }
 
-func (u *authUsecase) generateRefreshToken(user *authdomain.User) (string, error) {
+func (u *authUsecase) generateRefreshToken(user *authdomain.User, familyID string) (string, error) {
 	claims := jwt.MapClaims{
-		"user_id":  user.ID,
-		"token_id": uuid.New().String(),
-		"exp":      time.Now().Add(u.config.JWTRefreshExpiry).Unix(),
-		"iat":      time.Now().Unix(),
+		"user_id":   user.ID,
+		"token_id":  uuid.New().String(),
+		"family_id": familyID,
+		"exp":       time.Now().Add(u.config.JWTRefreshExpiry).Unix(),
+		"iat":       time.Now().Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -403,7 +662,7 @@ func (u *authUsecase) ValidateToken(tokenString string) (*authdomain.User, error
 	}
 
 	if user == nil {
-		return nil, errors.New("user not found")
+		return nil, authdomain.ErrUserNotFound
 	}
 
 	return user, nil
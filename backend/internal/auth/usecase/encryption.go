@@ -0,0 +1,105 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+
+	"ga03-backend/pkg/utils/crypto"
+)
+
+// CheckEncryptionKey decrypts one stored IMAP password per key generation, so a wrong
+// or missing key stops the server at startup instead of failing every IMAP request
+func (u *authUsecase) CheckEncryptionKey() error {
+	users, err := u.userRepo.FindWithImapPassword()
+	if err != nil {
+		return err
+	}
+
+	checked := make(map[int]bool)
+	for _, user := range users {
+		version, _ := crypto.Version(user.ImapPassword)
+		if checked[version] {
+			continue
+		}
+		checked[version] = true
+		if _, err := u.config.Keyring.Decrypt(user.ImapPassword); err != nil {
+			return fmt.Errorf("key version %d can't decrypt the IMAP password of user %s: %w", version, user.ID, err)
+		}
+	}
+
+	accounts, err := u.userRepo.FindLinkedWithImapPassword()
+	if err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		version, _ := crypto.Version(account.ImapPassword)
+		if checked[version] {
+			continue
+		}
+		checked[version] = true
+		if _, err := u.config.Keyring.Decrypt(account.ImapPassword); err != nil {
+			return fmt.Errorf("key version %d can't decrypt the IMAP password of linked account %s: %w", version, account.ID, err)
+		}
+	}
+	return nil
+}
+
+// RotateEncryptionKey re-encrypts every IMAP password, of users and linked accounts,
+// that isn't on the current key generation and returns how many it rewrote. Both keys
+// come from the configured key ring, so a running server can read either generation
+// while this runs. Passwords that fail are left as they are and reported together.
+func (u *authUsecase) RotateEncryptionKey() (int, error) {
+	users, err := u.userRepo.FindWithImapPassword()
+	if err != nil {
+		return 0, err
+	}
+
+	keyring := u.config.Keyring
+	rotated := 0
+	var errs []error
+	for _, user := range users {
+		if version, _ := crypto.Version(user.ImapPassword); version == keyring.CurrentVersion() {
+			continue
+		}
+		password, err := keyring.Decrypt(user.ImapPassword)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("user %s: %w", user.ID, err))
+			continue
+		}
+		if user.ImapPassword, err = keyring.Encrypt(password); err != nil {
+			errs = append(errs, fmt.Errorf("user %s: %w", user.ID, err))
+			continue
+		}
+		if err := u.userRepo.Update(user); err != nil {
+			errs = append(errs, fmt.Errorf("user %s: %w", user.ID, err))
+			continue
+		}
+		rotated++
+	}
+
+	accounts, err := u.userRepo.FindLinkedWithImapPassword()
+	if err != nil {
+		errs = append(errs, err)
+		return rotated, errors.Join(errs...)
+	}
+	for _, account := range accounts {
+		if version, _ := crypto.Version(account.ImapPassword); version == keyring.CurrentVersion() {
+			continue
+		}
+		password, err := keyring.Decrypt(account.ImapPassword)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("linked account %s: %w", account.ID, err))
+			continue
+		}
+		if account.ImapPassword, err = keyring.Encrypt(password); err != nil {
+			errs = append(errs, fmt.Errorf("linked account %s: %w", account.ID, err))
+			continue
+		}
+		if err := u.userRepo.SaveLinkedAccount(account); err != nil {
+			errs = append(errs, fmt.Errorf("linked account %s: %w", account.ID, err))
+			continue
+		}
+		rotated++
+	}
+	return rotated, errors.Join(errs...)
+}
@@ -0,0 +1,155 @@
+package usecase
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	"ga03-backend/internal/auth/repository"
+	"ga03-backend/pkg/config"
+)
+
+// fakeUsers is an in-memory UserRepository. It embeds the interface, so a call a test
+// didn't expect panics instead of silently succeeding.
+type fakeUsers struct {
+	repository.UserRepository
+	mu       sync.Mutex
+	users    map[string]*authdomain.User
+	sessions map[string]*authdomain.RefreshToken
+	updates  int
+
+	// beforeRotate runs inside UpdateRefreshToken before the compare, to play a
+	// request that rotates the session at the same moment
+	beforeRotate func(session *authdomain.RefreshToken)
+}
+
+func newFakeUsers(users ...*authdomain.User) *fakeUsers {
+	r := &fakeUsers{users: make(map[string]*authdomain.User), sessions: make(map[string]*authdomain.RefreshToken)}
+	for _, user := range users {
+		r.users[user.ID] = user
+	}
+	return r
+}
+
+func (r *fakeUsers) FindByID(id string) (*authdomain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeUsers) FindByEmail(email string) (*authdomain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUsers) Create(user *authdomain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if user.ID == "" {
+		user.ID = fmt.Sprintf("user-%d", len(r.users)+1)
+	}
+	copied := *user
+	r.users[user.ID] = &copied
+	return nil
+}
+
+func (r *fakeUsers) Update(user *authdomain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *user
+	r.users[user.ID] = &copied
+	r.updates++
+	return nil
+}
+
+func (r *fakeUsers) SaveRefreshToken(token *authdomain.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *token
+	r.sessions[token.ID] = &copied
+	return nil
+}
+
+func (r *fakeUsers) UpdateRefreshToken(token *authdomain.RefreshToken, current string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.sessions[token.ID]
+	if !ok {
+		return false, nil
+	}
+	if r.beforeRotate != nil {
+		r.beforeRotate(stored)
+	}
+	if stored.Token != current {
+		return false, nil
+	}
+	copied := *token
+	r.sessions[token.ID] = &copied
+	return true, nil
+}
+
+func (r *fakeUsers) FindRefreshToken(token string) (*authdomain.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, session := range r.sessions {
+		if session.Token == token {
+			copied := *session
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUsers) FindRefreshTokenByID(id string) (*authdomain.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (r *fakeUsers) DeleteRefreshTokenByID(userID, id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok || session.UserID != userID {
+		return false, nil
+	}
+	delete(r.sessions, id)
+	return true, nil
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		JWTSecret:        "test-secret",
+		JWTAccessExpiry:  time.Minute,
+		JWTRefreshExpiry: time.Hour,
+		BcryptCost:       4,
+	}
+}
+
+func newTestUsecase(t *testing.T, cfg *config.Config, users ...*authdomain.User) (*authUsecase, *fakeUsers) {
+	t.Helper()
+	if cfg == nil {
+		cfg = testConfig()
+	}
+	repo := newFakeUsers(users...)
+	return NewAuthUsecase(repo, cfg).(*authUsecase), repo
+}
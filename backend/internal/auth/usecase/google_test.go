@@ -0,0 +1,111 @@
+package usecase
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	authdto "ga03-backend/internal/auth/dto"
+)
+
+// fakeGoogle answers Google's token and userinfo endpoints. GoogleSignIn talks to
+// them through http.DefaultTransport, which is pointed at the fake for the test.
+type fakeGoogle struct {
+	mu           sync.Mutex
+	accessToken  string
+	refreshToken string // Only sent on first consent, like Google does
+}
+
+func (g *fakeGoogle) set(accessToken, refreshToken string) {
+	g.mu.Lock()
+	g.accessToken, g.refreshToken = accessToken, refreshToken
+	g.mu.Unlock()
+}
+
+func newFakeGoogle(t *testing.T) *fakeGoogle {
+	t.Helper()
+	g := &fakeGoogle{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/token":
+			resp := map[string]any{
+				"access_token": g.accessToken,
+				"token_type":   "Bearer",
+				"expires_in":   3600,
+				"scope":        "openid email https://mail.google.com/",
+			}
+			if g.refreshToken != "" {
+				resp["refresh_token"] = g.refreshToken
+			}
+			json.NewEncoder(w).Encode(resp)
+		case "/oauth2/v3/userinfo":
+			json.NewEncoder(w).Encode(GoogleTokenInfo{Email: "alice@gmail.com", Name: "Alice", EmailVerified: true})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	target, _ := url.Parse(server.URL)
+	saved := http.DefaultTransport
+	http.DefaultTransport = redirectTransport{target: target, next: &http.Transport{}}
+	t.Cleanup(func() { http.DefaultTransport = saved })
+	return g
+}
+
+type redirectTransport struct {
+	target *url.URL
+	next   http.RoundTripper
+}
+
+func (rt redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = rt.target.Scheme
+	req.URL.Host = rt.target.Host
+	return rt.next.RoundTrip(req)
+}
+
+func TestGoogleSignInKeepsRefreshToken(t *testing.T) {
+	google := newFakeGoogle(t)
+	uc, repo := newTestUsecase(t, nil)
+
+	stored := func() (accessToken, refreshToken string) {
+		user, err := repo.FindByEmail("alice@gmail.com")
+		if err != nil || user == nil {
+			t.Fatalf("FindByEmail() = %v, %v", user, err)
+		}
+		return user.AccessToken, user.RefreshToken
+	}
+
+	google.set("access-1", "refresh-1")
+	if _, err := uc.GoogleSignIn("code-1", nil, authdto.ClientInfo{}); err != nil {
+		t.Fatalf("first GoogleSignIn() error = %v", err)
+	}
+	if access, refresh := stored(); access != "access-1" || refresh != "refresh-1" {
+		t.Fatalf("after first consent stored (%q, %q), want both tokens", access, refresh)
+	}
+
+	// Signing in again returns no refresh token
+	google.set("access-2", "")
+	if _, err := uc.GoogleSignIn("code-2", nil, authdto.ClientInfo{}); err != nil {
+		t.Fatalf("second GoogleSignIn() error = %v", err)
+	}
+	if access, refresh := stored(); access != "access-2" || refresh != "refresh-1" {
+		t.Errorf("after re-sign-in stored (%q, %q), want the new access token and the kept refresh token", access, refresh)
+	}
+
+	// A fresh consent replaces it
+	google.set("access-3", "refresh-3")
+	if _, err := uc.GoogleSignIn("code-3", nil, authdto.ClientInfo{}); err != nil {
+		t.Fatalf("third GoogleSignIn() error = %v", err)
+	}
+	if _, refresh := stored(); refresh != "refresh-3" {
+		t.Errorf("after new consent stored refresh token %q, want refresh-3", refresh)
+	}
+}
@@ -0,0 +1,127 @@
+package usecase
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	authdto "ga03-backend/internal/auth/dto"
+	"ga03-backend/pkg/imap"
+	"ga03-backend/pkg/utils/crypto"
+
+	"github.com/emersion/go-imap/backend/memory"
+	"github.com/emersion/go-imap/server"
+)
+
+// imapServer starts an in-memory IMAP server that accepts "username" / "password"
+func imapServer(t *testing.T) (string, int) {
+	t.Helper()
+	srv := server.New(memory.New())
+	srv.AllowInsecureAuth = true
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(listener)
+	t.Cleanup(func() { srv.Close() })
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	return host, portNum
+}
+
+func TestIMAPLoginWrongPassword(t *testing.T) {
+	host, port := imapServer(t)
+	uc, repo := newTestUsecase(t, nil)
+
+	_, err := uc.IMAPLogin(&authdto.ImapLoginRequest{Email: "username", Password: "typo", ImapServer: host, ImapPort: port}, authdto.ClientInfo{})
+	if !errors.Is(err, imap.ErrAuthFailed) {
+		t.Fatalf("IMAPLogin() error = %v, want ErrAuthFailed", err)
+	}
+	if len(repo.users) != 0 || len(repo.sessions) != 0 {
+		t.Errorf("a rejected login left %d users and %d sessions", len(repo.users), len(repo.sessions))
+	}
+}
+
+func TestIMAPLoginKeepsAccountsOnTheirServer(t *testing.T) {
+	host, port := imapServer(t)
+	tests := []struct {
+		name string
+		user *authdomain.User
+		req  authdto.ImapLoginRequest
+		want error
+	}{
+		{
+			name: "google grant to another server",
+			user: &authdomain.User{ID: "u1", Email: "alice@gmail.com", Provider: "google", RefreshToken: "refresh"},
+			req:  authdto.ImapLoginRequest{Email: "alice@gmail.com", AccessToken: "token", ImapServer: host, ImapPort: port},
+			want: ErrGoogleServerRequired,
+		},
+		{
+			name: "google grant with another SMTP server",
+			user: &authdomain.User{ID: "u1", Email: "alice@gmail.com", Provider: "google", RefreshToken: "refresh"},
+			req:  authdto.ImapLoginRequest{Email: "alice@gmail.com", AccessToken: "token", ImapServer: "imap.gmail.com", ImapPort: 993, SmtpServer: host},
+			want: ErrGoogleServerRequired,
+		},
+		{
+			name: "access token of another account",
+			user: &authdomain.User{ID: "u1", Email: "bob@gmail.com", Provider: "google", RefreshToken: "refresh"},
+			req:  authdto.ImapLoginRequest{Email: "bob@gmail.com", AccessToken: "alice-token", ImapServer: "imap.gmail.com", ImapPort: 993},
+			want: ErrGoogleVerification,
+		},
+		{
+			name: "password login on a google account",
+			user: &authdomain.User{ID: "u1", Email: "username", Provider: "google", RefreshToken: "refresh"},
+			req:  authdto.ImapLoginRequest{Email: "username", Password: "password", ImapServer: host, ImapPort: port},
+			want: ErrUseGoogleSignIn,
+		},
+		{
+			name: "password account",
+			user: &authdomain.User{ID: "u1", Email: "username", Provider: "email", Password: "hash"},
+			req:  authdto.ImapLoginRequest{Email: "username", Password: "password", ImapServer: host, ImapPort: port},
+			want: ErrIMAPServerMismatch,
+		},
+		{
+			name: "imap account moved to another server",
+			user: &authdomain.User{ID: "u1", Email: "username", Provider: "imap", ImapServer: "imap.example.com", ImapPort: 993},
+			req:  authdto.ImapLoginRequest{Email: "username", Password: "password", ImapServer: host, ImapPort: port},
+			want: ErrIMAPServerMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newFakeGoogle(t) // userinfo says alice@gmail.com
+			saved := *tt.user
+			uc, repo := newTestUsecase(t, nil, tt.user)
+
+			_, err := uc.IMAPLogin(&tt.req, authdto.ClientInfo{})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("IMAPLogin() error = %v, want %v", err, tt.want)
+			}
+			if stored, _ := repo.FindByID("u1"); *stored != saved || len(repo.sessions) != 0 {
+				t.Errorf("a rejected login changed the account to %+v or left %d sessions", stored, len(repo.sessions))
+			}
+		})
+	}
+}
+
+func TestIMAPLoginUpdatesPasswordOnSameServer(t *testing.T) {
+	host, port := imapServer(t)
+	cfg := testConfig()
+	keyring, err := crypto.NewKeyring(1, "0123456789abcdef0123456789abcdef", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Keyring = keyring
+	uc, repo := newTestUsecase(t, cfg, &authdomain.User{ID: "u1", Email: "username", Provider: "imap", ImapServer: host, ImapPort: port})
+
+	if _, err := uc.IMAPLogin(&authdto.ImapLoginRequest{Email: "username", Password: "password", ImapServer: host, ImapPort: port}, authdto.ClientInfo{}); err != nil {
+		t.Fatalf("IMAPLogin() error = %v", err)
+	}
+	stored, _ := repo.FindByID("u1")
+	if secret, err := uc.config.Keyring.Decrypt(stored.ImapPassword); err != nil || secret != "password" {
+		t.Errorf("stored password = (%q, %v), want the new one", secret, err)
+	}
+}
@@ -7,11 +7,26 @@ import (
 
 // AuthUsecase defines the interface for authentication use cases
 type AuthUsecase interface {
-	Login(req *authdto.LoginRequest) (*authdto.TokenResponse, error)
-	IMAPLogin(req *authdto.ImapLoginRequest) (*authdto.TokenResponse, error)
-	Register(req *authdto.RegisterRequest) (*authdto.TokenResponse, error)
-	GoogleSignIn(code string, scope []string) (*authdto.TokenResponse, error)
-	RefreshToken(refreshToken string) (*authdto.TokenResponse, error)
+	Login(req *authdto.LoginRequest, client authdto.ClientInfo) (*authdto.TokenResponse, error)
+	IMAPLogin(req *authdto.ImapLoginRequest, client authdto.ClientInfo) (*authdto.TokenResponse, error)
+	Register(req *authdto.RegisterRequest, client authdto.ClientInfo) (*authdto.TokenResponse, error)
+	GoogleSignIn(code string, scope []string, client authdto.ClientInfo) (*authdto.TokenResponse, error)
+	RefreshToken(refreshToken string, client authdto.ClientInfo) (*authdto.TokenResponse, error)
 	Logout(refreshToken string) error
+	LogoutAll(userID string) error
+	ListSessions(userID, currentToken string) ([]*authdto.SessionResponse, error)
+	RevokeSession(userID, sessionID string) error
+	RequestPasswordReset(email string) error
+	ResetPassword(token, newPassword string) error
+	ChangePassword(userID, oldPassword, newPassword string, client authdto.ClientInfo) (*authdto.TokenResponse, error)
+	UpdateSignature(userID, signature string) error
+	LinkImapAccount(userID string, req *authdto.LinkImapAccountRequest) (*authdomain.LinkedAccount, error)
+	LinkGoogleAccount(userID string, req *authdto.LinkGoogleAccountRequest) (*authdomain.LinkedAccount, error)
+	ListLinkedAccounts(userID string) ([]*authdomain.LinkedAccount, error)
+	UnlinkAccount(userID, accountID string) error
+	OnLogout(fn func(userID string))
+	OnImapCredentialsChange(fn func(userID string))
 	ValidateToken(tokenString string) (*authdomain.User, error)
+	CheckEncryptionKey() error
+	RotateEncryptionKey() (int, error)
 }
@@ -0,0 +1,133 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	authdto "ga03-backend/internal/auth/dto"
+	"ga03-backend/pkg/imap"
+)
+
+var (
+	ErrLinkedAccountNotFound = errors.New("linked account not found")
+	ErrLinkPrimaryAccount    = errors.New("this is the account you are signed in with")
+	ErrGmailScopeRequired    = errors.New("gmail access not granted, please allow it to link this account")
+)
+
+// LinkImapAccount attaches an IMAP mailbox to the user after checking the credentials
+// work. Linking the same address again updates its credentials.
+func (u *authUsecase) LinkImapAccount(userID string, req *authdto.LinkImapAccountRequest) (*authdomain.LinkedAccount, error) {
+	imapClient, err := imap.ConnectAndLogin(req.ImapServer, req.ImapPort, req.Email, req.Password, "")
+	if err != nil {
+		return nil, err
+	}
+	defer imapClient.Logout()
+
+	encryptedPass, err := u.config.Keyring.Encrypt(req.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt password: %w", err)
+	}
+
+	account, err := u.linkedAccountFor(userID, req.Email)
+	if err != nil {
+		return nil, err
+	}
+	account.Provider = "imap"
+	account.ImapServer = req.ImapServer
+	account.ImapPort = req.ImapPort
+	account.ImapPassword = encryptedPass
+	account.AccessToken = ""
+	account.RefreshToken = ""
+	if req.DisplayName != "" || account.DisplayName == "" {
+		account.DisplayName = displayNameOr(req.DisplayName, req.Email)
+	}
+
+	if err := u.userRepo.SaveLinkedAccount(account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// LinkGoogleAccount attaches a Gmail account to the user from an auth code. The grant
+// must include mail access, since reading mail is all a linked account is for.
+func (u *authUsecase) LinkGoogleAccount(userID string, req *authdto.LinkGoogleAccountRequest) (*authdomain.LinkedAccount, error) {
+	token, tokenInfo, err := u.exchangeGoogleCode(req.Code, req.Scope)
+	if err != nil {
+		return nil, err
+	}
+	if !grantsGmail(token) {
+		return nil, ErrGmailScopeRequired
+	}
+
+	account, err := u.linkedAccountFor(userID, tokenInfo.Email)
+	if err != nil {
+		return nil, err
+	}
+	account.Provider = "google"
+	account.AccessToken = token.AccessToken
+	// Google only returns a refresh token on first consent; keep the stored one otherwise
+	if token.RefreshToken != "" {
+		account.RefreshToken = token.RefreshToken
+	}
+	account.TokenExpiry = token.Expiry
+	account.ImapServer = ""
+	account.ImapPort = 0
+	account.ImapPassword = ""
+	if req.DisplayName != "" || account.DisplayName == "" {
+		account.DisplayName = displayNameOr(req.DisplayName, tokenInfo.Name)
+	}
+
+	if err := u.userRepo.SaveLinkedAccount(account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// linkedAccountFor returns the user's linked account for email, or a new one to fill in
+func (u *authUsecase) linkedAccountFor(userID, email string) (*authdomain.LinkedAccount, error) {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, authdomain.ErrUserNotFound
+	}
+	if strings.EqualFold(user.Email, email) {
+		return nil, ErrLinkPrimaryAccount
+	}
+
+	accounts, err := u.userRepo.FindLinkedAccounts(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
+		if strings.EqualFold(account.Email, email) {
+			return account, nil
+		}
+	}
+	return &authdomain.LinkedAccount{UserID: userID, Email: strings.ToLower(email)}, nil
+}
+
+func (u *authUsecase) ListLinkedAccounts(userID string) ([]*authdomain.LinkedAccount, error) {
+	return u.userRepo.FindLinkedAccounts(userID)
+}
+
+func (u *authUsecase) UnlinkAccount(userID, accountID string) error {
+	found, err := u.userRepo.DeleteLinkedAccount(userID, accountID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrLinkedAccountNotFound
+	}
+	return nil
+}
+
+func displayNameOr(name, fallback string) string {
+	if name = strings.TrimSpace(name); name != "" {
+		return name
+	}
+	return fallback
+}
@@ -0,0 +1,154 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	authdto "ga03-backend/internal/auth/dto"
+	"ga03-backend/internal/auth/repository"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// resetPurpose marks password reset JWTs. They carry the user in "sub" rather than
+// "user_id", so ValidateToken never accepts one as an access token.
+const resetPurpose = "password_reset"
+
+// ErrInvalidResetToken covers expired, tampered and already used reset links
+var ErrInvalidResetToken = errors.New("invalid or expired reset link")
+
+// ErrWrongPassword means the current password given to ChangePassword didn't match
+var ErrWrongPassword = errors.New("current password is incorrect")
+
+// ErrNoPassword means the account signs in with Google or IMAP and has no password here
+var ErrNoPassword = errors.New("this account has no password to change")
+
+// ErrMailNotConfigured means reset links can't be sent because no mail server is set up
+var ErrMailNotConfigured = errors.New("password reset by email is not available")
+
+// RequestPasswordReset emails a reset link to an email/password account. It succeeds
+// for unknown addresses too, so the endpoint can't be used to probe for accounts.
+// Without a mail server it fails for every address, before looking any up.
+func (u *authUsecase) RequestPasswordReset(email string) error {
+	if !u.mailer.Configured() {
+		return ErrMailNotConfigured
+	}
+
+	user, err := u.userRepo.FindByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Provider != "email" {
+		return nil
+	}
+	if allowed, _ := u.resetLimit.Allow(user.ID); !allowed {
+		log.Printf("Too many password reset requests for %s, not sending another", user.Email)
+		return nil
+	}
+
+	claims := jwt.MapClaims{
+		"sub":     user.ID,
+		"jti":     uuid.New().String(),
+		"purpose": resetPurpose,
+		"exp":     time.Now().Add(u.config.PasswordResetExpiry).Unix(),
+		"iat":     time.Now().Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(u.config.JWTSecret))
+	if err != nil {
+		return err
+	}
+
+	link := u.config.FrontendURL + "/reset-password?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hi %s,\n\nUse this link to choose a new password. It expires in %s.\n\n%s\n\nIf you didn't ask for this, you can ignore this email.\n",
+		user.Name, u.config.PasswordResetExpiry, link)
+	if err := u.mailer.Send(user.Email, "Reset your password", body); err != nil {
+		return fmt.Errorf("failed to send reset email: %w", err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token. Each token works once, and
+// every session of the user is signed out.
+func (u *authUsecase) ResetPassword(tokenString, newPassword string) error {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(u.config.JWTSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return ErrInvalidResetToken
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != resetPurpose {
+		return ErrInvalidResetToken
+	}
+	userID, _ := claims["sub"].(string)
+	jti, _ := claims["jti"].(string)
+	expiresAt, err := claims.GetExpirationTime()
+	if userID == "" || jti == "" || err != nil || expiresAt == nil {
+		return ErrInvalidResetToken
+	}
+
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Provider != "email" {
+		return ErrInvalidResetToken
+	}
+
+	hashed, err := repository.HashPassword(newPassword, u.config.BcryptCost)
+	if err != nil {
+		return err
+	}
+
+	// Redeem the token before changing anything, so two concurrent uses can't both win
+	fresh, err := u.userRepo.UseResetToken(&authdomain.UsedResetToken{JTI: jti, UserID: userID, ExpiresAt: expiresAt.Time})
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidResetToken
+	}
+
+	user.Password = hashed
+	if err := u.userRepo.Update(user); err != nil {
+		return err
+	}
+	return u.userRepo.DeleteRefreshTokensByUser(userID)
+}
+
+// ChangePassword replaces the password after checking the current one, so a stolen
+// session alone can't change it. Every refresh token is revoked and the caller gets
+// a fresh pair to stay signed in.
+func (u *authUsecase) ChangePassword(userID, oldPassword, newPassword string, client authdto.ClientInfo) (*authdto.TokenResponse, error) {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, authdomain.ErrUserNotFound
+	}
+	if user.Provider != "email" || user.Password == "" {
+		return nil, ErrNoPassword
+	}
+	if !repository.CheckPasswordHash(oldPassword, user.Password) {
+		return nil, ErrWrongPassword
+	}
+
+	hashed, err := repository.HashPassword(newPassword, u.config.BcryptCost)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = hashed
+	if err := u.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	if err := u.userRepo.DeleteRefreshTokensByUser(userID); err != nil {
+		return nil, err
+	}
+	return u.generateTokens(user, client)
+}
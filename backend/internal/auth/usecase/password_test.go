@@ -0,0 +1,102 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	authdto "ga03-backend/internal/auth/dto"
+	"ga03-backend/internal/auth/repository"
+	"ga03-backend/pkg/ratelimit"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func emailUser(t *testing.T, password string, cost int) *authdomain.User {
+	t.Helper()
+	hashed, err := repository.HashPassword(password, cost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &authdomain.User{ID: "u1", Email: "u1@example.com", Provider: "email", Password: hashed}
+}
+
+// A hash made with a lower cost than configured is replaced on the next login
+func TestLoginUpgradesPasswordHash(t *testing.T) {
+	cfg := testConfig()
+	cfg.BcryptCost = bcrypt.MinCost + 1
+	uc, repo := newTestUsecase(t, cfg, emailUser(t, "correct horse", bcrypt.MinCost))
+
+	if _, err := uc.Login(&authdto.LoginRequest{Email: "u1@example.com", Password: "correct horse"}, authdto.ClientInfo{}); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	stored, _ := repo.FindByID("u1")
+	if cost, _ := bcrypt.Cost([]byte(stored.Password)); cost != cfg.BcryptCost {
+		t.Errorf("stored hash cost = %d, want %d", cost, cfg.BcryptCost)
+	}
+	if !repository.CheckPasswordHash("correct horse", stored.Password) {
+		t.Error("the upgraded hash doesn't match the password")
+	}
+}
+
+func TestLoginKeepsCurrentHash(t *testing.T) {
+	cfg := testConfig()
+	uc, repo := newTestUsecase(t, cfg, emailUser(t, "correct horse", cfg.BcryptCost))
+
+	if _, err := uc.Login(&authdto.LoginRequest{Email: "u1@example.com", Password: "correct horse"}, authdto.ClientInfo{}); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if repo.updates != 0 {
+		t.Errorf("user updated %d times, want the hash left alone", repo.updates)
+	}
+}
+
+func TestLoginWrongPasswordNoUpgrade(t *testing.T) {
+	cfg := testConfig()
+	cfg.BcryptCost = bcrypt.MinCost + 1
+	uc, repo := newTestUsecase(t, cfg, emailUser(t, "correct horse", bcrypt.MinCost))
+
+	if _, err := uc.Login(&authdto.LoginRequest{Email: "u1@example.com", Password: "wrong"}, authdto.ClientInfo{}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Login() error = %v, want ErrInvalidCredentials", err)
+	}
+	if repo.updates != 0 {
+		t.Error("a failed login rewrote the hash")
+	}
+}
+
+// Guesses are counted per account, so they can't be spread over many IPs
+func TestLoginLimitedPerAccount(t *testing.T) {
+	cfg := testConfig()
+	cfg.LoginRateLimit = 2
+	cfg.LoginRateWindow = time.Minute
+	other := emailUser(t, "other secret", bcrypt.MinCost)
+	other.ID, other.Email = "u2", "u2@example.com"
+	uc, _ := newTestUsecase(t, cfg, emailUser(t, "correct horse", bcrypt.MinCost), other)
+
+	for _, email := range []string{"u1@example.com", "U1@Example.com"} {
+		if _, err := uc.Login(&authdto.LoginRequest{Email: email, Password: "guess"}, authdto.ClientInfo{}); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("Login(%s) error = %v, want ErrInvalidCredentials", email, err)
+		}
+	}
+	_, err := uc.Login(&authdto.LoginRequest{Email: "u1@example.com", Password: "correct horse"}, authdto.ClientInfo{})
+	var limitErr *ratelimit.LimitError
+	if !errors.As(err, &limitErr) || limitErr.RetryAfter <= 0 {
+		t.Fatalf("Login() past the limit error = %v, want a LimitError with a retry time", err)
+	}
+
+	if _, err := uc.Login(&authdto.LoginRequest{Email: "u2@example.com", Password: "other secret"}, authdto.ClientInfo{}); err != nil {
+		t.Errorf("another account's Login() error = %v", err)
+	}
+}
+
+// Without a mail server the link can't go anywhere, so it isn't made at all
+func TestRequestPasswordResetWithoutMail(t *testing.T) {
+	uc, _ := newTestUsecase(t, testConfig(), emailUser(t, "correct horse", bcrypt.MinCost))
+
+	for _, email := range []string{"u1@example.com", "nobody@example.com"} {
+		if err := uc.RequestPasswordReset(email); !errors.Is(err, ErrMailNotConfigured) {
+			t.Errorf("RequestPasswordReset(%s) error = %v, want ErrMailNotConfigured", email, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package usecase
+
+import (
+	"errors"
+	"time"
+
+	authdto "ga03-backend/internal/auth/dto"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrRefreshTokenReused means a refresh token was presented after it had been
+	// rotated out; its session has been revoked and the user must sign in again
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+	// ErrRefreshConflict means other requests kept rotating the session while this one
+	// tried to; the client can retry with its newest token
+	ErrRefreshConflict = errors.New("session was refreshed at the same time, try again")
+)
+
+// errRotationLost is returned by a save that found the session already rotated
+var errRotationLost = errors.New("session rotated concurrently")
+
+// refreshReuseGrace is how long a rotated-out refresh token is still accepted, so
+// tabs refreshing at the same time with the shared cookie aren't taken for a breach
+const refreshReuseGrace = 30 * time.Second
+
+// ListSessions returns the user's active sessions. The one holding currentToken is
+// marked as current so the client can tell which device it is.
+func (u *authUsecase) ListSessions(userID, currentToken string) ([]*authdto.SessionResponse, error) {
+	sessions, err := u.userRepo.FindRefreshTokensByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*authdto.SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		result = append(result, &authdto.SessionResponse{
+			ID:         s.ID,
+			UserAgent:  s.UserAgent,
+			IPAddress:  s.IPAddress,
+			CreatedAt:  s.CreatedAt,
+			LastUsedAt: s.LastUsedAt,
+			ExpiresAt:  s.ExpiresAt,
+			Current:    currentToken != "" && s.Token == currentToken,
+		})
+	}
+	return result, nil
+}
+
+// RevokeSession signs out one of the user's sessions. Its access token stays valid
+// until it expires, but it can no longer be refreshed.
+func (u *authUsecase) RevokeSession(userID, sessionID string) error {
+	found, err := u.userRepo.DeleteRefreshTokenByID(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// LogoutAll signs the user out everywhere
+func (u *authUsecase) LogoutAll(userID string) error {
+	if err := u.userRepo.DeleteRefreshTokensByUser(userID); err != nil {
+		return err
+	}
+	u.revokeGoogleToken(userID)
+	for _, hook := range u.logoutHooks {
+		hook(userID)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	authdto "ga03-backend/internal/auth/dto"
+)
+
+// signIn starts a session for the user and returns its refresh token
+func signIn(t *testing.T, uc *authUsecase, user *authdomain.User) string {
+	t.Helper()
+	resp, err := uc.generateTokens(user, authdto.ClientInfo{})
+	if err != nil {
+		t.Fatalf("generateTokens() error = %v", err)
+	}
+	return resp.RefreshToken
+}
+
+func TestRefreshTokenRotates(t *testing.T) {
+	user := &authdomain.User{ID: "u1", Email: "u1@example.com", Provider: "email"}
+	uc, repo := newTestUsecase(t, nil, user)
+	token := signIn(t, uc, user)
+
+	resp, err := uc.RefreshToken(token, authdto.ClientInfo{})
+	if err != nil {
+		t.Fatalf("RefreshToken() error = %v", err)
+	}
+	if resp.RefreshToken == token {
+		t.Error("the refresh token wasn't rotated")
+	}
+	if stored, _ := repo.FindRefreshToken(resp.RefreshToken); stored == nil || stored.PreviousToken != token {
+		t.Errorf("stored session = %+v, want the new token replacing the old", stored)
+	}
+}
+
+// Another tab rotates the session between this request's read and its write. That
+// is a lost race, not a stolen token: the request retries and the session survives.
+func TestRefreshTokenLostRace(t *testing.T) {
+	user := &authdomain.User{ID: "u1", Email: "u1@example.com", Provider: "email"}
+	uc, repo := newTestUsecase(t, nil, user)
+	token := signIn(t, uc, user)
+
+	raced := false
+	repo.beforeRotate = func(session *authdomain.RefreshToken) {
+		if !raced {
+			raced = true
+			session.PreviousToken = session.Token
+			session.Token = "rotated-by-the-other-tab"
+			session.RotatedAt = time.Now()
+		}
+	}
+
+	resp, err := uc.RefreshToken(token, authdto.ClientInfo{})
+	if err != nil {
+		t.Fatalf("RefreshToken() error = %v, want the lost race retried", err)
+	}
+	stored, _ := repo.FindRefreshToken(resp.RefreshToken)
+	if stored == nil {
+		t.Fatal("the session was revoked")
+	}
+	if stored.PreviousToken != "rotated-by-the-other-tab" {
+		t.Errorf("rotated from %q, want the other tab's token", stored.PreviousToken)
+	}
+}
+
+func TestRefreshTokenKeepsLosing(t *testing.T) {
+	user := &authdomain.User{ID: "u1", Email: "u1@example.com", Provider: "email"}
+	uc, repo := newTestUsecase(t, nil, user)
+	token := signIn(t, uc, user)
+
+	rotations := 0
+	repo.beforeRotate = func(session *authdomain.RefreshToken) {
+		rotations++
+		session.PreviousToken = session.Token
+		session.Token = "busy-" + string(rune('a'+rotations))
+		session.RotatedAt = time.Now()
+	}
+
+	_, err := uc.RefreshToken(token, authdto.ClientInfo{})
+	if !errors.Is(err, ErrRefreshConflict) {
+		t.Fatalf("RefreshToken() error = %v, want ErrRefreshConflict", err)
+	}
+	if len(repo.sessions) != 1 {
+		t.Error("a lost race revoked the session")
+	}
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	user := &authdomain.User{ID: "u1", Email: "u1@example.com", Provider: "email"}
+	uc, repo := newTestUsecase(t, nil, user)
+	token := signIn(t, uc, user)
+
+	if _, err := uc.RefreshToken(token, authdto.ClientInfo{}); err != nil {
+		t.Fatalf("RefreshToken() error = %v", err)
+	}
+	// Past the grace window the old token can only be a leaked copy
+	for _, session := range repo.sessions {
+		session.RotatedAt = time.Now().Add(-2 * refreshReuseGrace)
+	}
+
+	if _, err := uc.RefreshToken(token, authdto.ClientInfo{}); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("RefreshToken() error = %v, want ErrRefreshTokenReused", err)
+	}
+	if len(repo.sessions) != 0 {
+		t.Error("the session wasn't revoked after reuse")
+	}
+}
@@ -0,0 +1,21 @@
+package usecase
+
+import (
+	"strings"
+
+	authdomain "ga03-backend/internal/auth/domain"
+)
+
+// UpdateSignature saves the user's plain text signature; an empty one turns signing off
+func (u *authUsecase) UpdateSignature(userID, signature string) error {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return authdomain.ErrUserNotFound
+	}
+
+	user.Signature = strings.TrimSpace(signature)
+	return u.userRepo.Update(user)
+}
@@ -0,0 +1,47 @@
+package delivery
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResolveEmailIDs rewrites a stable email ID in the :id path parameter of email and
+// thread routes to the provider-specific ID, so handlers accept either form. It must
+// run after AuthMiddleware.
+func (h *EmailHandler) ResolveEmailIDs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.FullPath()
+		if !strings.HasPrefix(path, "/api/emails/:id") && !strings.HasPrefix(path, "/api/emails/threads/:id") {
+			c.Next()
+			return
+		}
+
+		for i, param := range c.Params {
+			if param.Key != "id" {
+				continue
+			}
+			resolved, err := h.emailUsecase.ResolveEmailID(c.GetString("userID"), param.Value)
+			if err != nil {
+				respondError(c, err)
+				c.Abort()
+				return
+			}
+			c.Params[i].Value = resolved
+		}
+		c.Next()
+	}
+}
+
+// resolveEmailIDs maps a list of email IDs from a request body, see ResolveEmailIDs
+func (h *EmailHandler) resolveEmailIDs(userID string, ids []string) ([]string, error) {
+	resolved := make([]string, len(ids))
+	for i, id := range ids {
+		r, err := h.emailUsecase.ResolveEmailID(userID, id)
+		if err != nil {
+			return nil, err
+		}
+		resolved[i] = r
+	}
+	return resolved, nil
+}
@@ -0,0 +1,95 @@
+package delivery
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/internal/email/usecase"
+	"ga03-backend/pkg/apierror"
+	"ga03-backend/pkg/gemini"
+	"ga03-backend/pkg/imageproxy"
+	"ga03-backend/pkg/imap"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Errors the handlers detect themselves
+var (
+	errNotAuthenticated = errors.New("not authenticated")
+	errInvalidUserData  = errors.New("invalid user data")
+	errMailboxNotFound  = errors.New("mailbox not found")
+)
+
+// emailErrors maps the email usecase's errors to responses. Gmail and OAuth failures
+// are classified by apierror.Respond itself; anything else becomes a generic 500.
+var emailErrors = []apierror.Mapping{
+	{Err: errNotAuthenticated, Status: http.StatusUnauthorized, Code: apierror.CodeUnauthorized},
+	{Err: errInvalidUserData, Status: http.StatusUnauthorized, Code: apierror.CodeUnauthorized},
+	{Err: errMailboxNotFound, Status: http.StatusNotFound, Code: apierror.CodeNotFound},
+	{Err: authdomain.ErrUserNotFound, Status: http.StatusNotFound, Code: apierror.CodeNotFound},
+	{Err: emaildomain.ErrEmailNotFound, Status: http.StatusNotFound, Code: apierror.CodeNotFound},
+	{Err: emaildomain.ErrDraftNotFound, Status: http.StatusNotFound, Code: apierror.CodeNotFound},
+	{Err: emaildomain.ErrAttachmentNotFound, Status: http.StatusNotFound, Code: apierror.CodeNotFound},
+	{Err: emaildomain.ErrNotInTrash, Status: http.StatusConflict, Code: apierror.CodeConflict},
+	{Err: emaildomain.ErrInvalidLanguage, Status: http.StatusBadRequest, Code: apierror.CodeBadRequest},
+	{Err: emaildomain.ErrWatermarkExpired, Status: http.StatusGone, Code: apierror.CodeConflict},
+	{Err: emaildomain.ErrInvalidWatermark, Status: http.StatusBadRequest, Code: apierror.CodeBadRequest},
+	{Err: usecase.ErrUnifiedPageTooDeep, Status: http.StatusBadRequest, Code: apierror.CodeBadRequest},
+	{Err: usecase.ErrInvalidKanbanStatus, Status: http.StatusBadRequest, Code: apierror.CodeBadRequest},
+	{Err: usecase.ErrNoEmailIDs, Status: http.StatusBadRequest, Code: apierror.CodeBadRequest},
+	{Err: usecase.ErrTooManyEmailIDs, Status: http.StatusBadRequest, Code: apierror.CodeBadRequest},
+	{Err: usecase.ErrTooManyExportIDs, Status: http.StatusBadRequest, Code: apierror.CodeBadRequest},
+	{Err: usecase.ErrInvalidBatchAction, Status: http.StatusBadRequest, Code: apierror.CodeBadRequest},
+	{Err: usecase.ErrTooManyBatchIDs, Status: http.StatusBadRequest, Code: apierror.CodeBadRequest},
+	{Err: usecase.ErrMailboxRequired, Status: http.StatusBadRequest, Code: apierror.CodeBadRequest},
+	{Err: usecase.ErrPendingSendNotFound, Status: http.StatusNotFound, Code: apierror.CodeNotFound},
+	{Err: usecase.ErrSendNotCancellable, Status: http.StatusConflict, Code: apierror.CodeConflict},
+	{Err: usecase.ErrFilterScopeNotGranted, Status: http.StatusForbidden, Code: apierror.CodeReauthRequired},
+	{Err: usecase.ErrDeleteScopeNotGranted, Status: http.StatusForbidden, Code: apierror.CodeReauthRequired},
+	{Err: usecase.ErrSendScopeNotGranted, Status: http.StatusForbidden, Code: apierror.CodeReauthRequired},
+	{Err: usecase.ErrNoUnsubscribe, Status: http.StatusBadRequest, Code: apierror.CodeBadRequest},
+	{Err: usecase.ErrUnsubscribeNotOneClick, Status: http.StatusBadRequest, Code: apierror.CodeBadRequest},
+	{Err: usecase.ErrUnsubscribeBlocked, Status: http.StatusBadRequest, Code: apierror.CodeBadRequest},
+	{Err: usecase.ErrIMAPCredentialsUnusable, Status: http.StatusForbidden, Code: apierror.CodeReauthRequired},
+	{Err: usecase.ErrUnsubscribeFailed, Status: http.StatusBadGateway, Code: apierror.CodeProviderFailure},
+	{Err: gemini.ErrNotConfigured, Status: http.StatusServiceUnavailable, Code: apierror.CodeUnavailable},
+	// The stored IMAP password stopped working, e.g. it was changed elsewhere
+	{Err: imap.ErrAuthFailed, Status: http.StatusForbidden, Code: apierror.CodeReauthRequired},
+	{Err: imap.ErrAppPasswordRequired, Status: http.StatusForbidden, Code: apierror.CodeReauthRequired},
+	{Err: imap.ErrServerUnreachable, Status: http.StatusBadGateway, Code: apierror.CodeProviderFailure},
+	{Err: imageproxy.ErrInvalidSignature, Status: http.StatusForbidden, Code: apierror.CodeForbidden},
+	{Err: imageproxy.ErrBlockedURL, Status: http.StatusForbidden, Code: apierror.CodeForbidden},
+	{Err: imageproxy.ErrNotImage, Status: http.StatusUnsupportedMediaType, Code: apierror.CodeBadRequest},
+	{Err: imageproxy.ErrImageTooLarge, Status: http.StatusRequestEntityTooLarge, Code: apierror.CodeBadRequest},
+	{Err: imageproxy.ErrFetchFailed, Status: http.StatusBadGateway, Code: apierror.CodeProviderFailure},
+}
+
+// respondError writes err as a {error, code} response
+func respondError(c *gin.Context, err error) {
+	apierror.Respond(c, err, emailErrors)
+}
+
+// classifyError returns the message and code for an error reported inside a successful
+// response, such as a failed account check. Like respondError, it keeps provider
+// details out and logs errors it doesn't recognize.
+func classifyError(c *gin.Context, err error) (message, code string) {
+	status, code, message := apierror.Classify(err, emailErrors)
+	if status == http.StatusInternalServerError {
+		log.Printf("%s %s: %v", c.Request.Method, c.FullPath(), err)
+	}
+	return message, code
+}
+
+// respondBadRequest writes a 400 for a request the handler itself rejected
+func respondBadRequest(c *gin.Context, message string) {
+	apierror.Write(c, http.StatusBadRequest, apierror.CodeBadRequest, message)
+}
+
+// respondInvalidBody writes a 400 for a body that didn't bind. The binding error
+// names Go types and fields, so it isn't passed on.
+func respondInvalidBody(c *gin.Context) {
+	respondBadRequest(c, "invalid request body")
+}
@@ -0,0 +1,307 @@
+package delivery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/internal/email/usecase"
+	"ga03-backend/pkg/apierror"
+	"ga03-backend/pkg/imap"
+
+	"github.com/gin-gonic/gin"
+)
+
+func decodeError(t *testing.T, body []byte) (message, code string) {
+	t.Helper()
+	var resp struct {
+		Error string `json:"error"`
+		Code  string `json:"code"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("response %q isn't JSON: %v", body, err)
+	}
+	return resp.Error, resp.Code
+}
+
+func TestBatchUpdateKanbanStatusErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantMsg    string
+	}{
+		{"invalid status", fmt.Errorf("%w: %s", usecase.ErrInvalidKanbanStatus, "nope"), http.StatusBadRequest, apierror.CodeBadRequest, usecase.ErrInvalidKanbanStatus.Error()},
+		{"no ids", usecase.ErrNoEmailIDs, http.StatusBadRequest, apierror.CodeBadRequest, usecase.ErrNoEmailIDs.Error()},
+		{"too many ids", usecase.ErrTooManyEmailIDs, http.StatusBadRequest, apierror.CodeBadRequest, usecase.ErrTooManyEmailIDs.Error()},
+		{"internal failure", errors.New("pq: connection refused to 10.0.0.3"), http.StatusInternalServerError, apierror.CodeInternal, "internal server error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(&fakeUsecase{kanbanErr: tt.err})
+			w := serve(t, h.BatchUpdateKanbanStatus, http.MethodPost, "/emails/kanban/batch", "/emails/kanban/batch", `{"ids":["m1"],"status":"todo"}`)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if msg, code := decodeError(t, w.Body.Bytes()); msg != tt.wantMsg || code != tt.wantCode {
+				t.Errorf("body = (%q, %q), want (%q, %q)", msg, code, tt.wantMsg, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestBatchModifyErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantMsg    string
+	}{
+		{"invalid action", fmt.Errorf("%w: %s", usecase.ErrInvalidBatchAction, "explode"), http.StatusBadRequest, usecase.ErrInvalidBatchAction.Error()},
+		{"too many ids", usecase.ErrTooManyBatchIDs, http.StatusBadRequest, usecase.ErrTooManyBatchIDs.Error()},
+		{"move without mailbox", usecase.ErrMailboxRequired, http.StatusBadRequest, usecase.ErrMailboxRequired.Error()},
+		{"internal failure", errors.New("pq: connection refused to 10.0.0.3"), http.StatusInternalServerError, "internal server error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(&fakeUsecase{batchErr: tt.err})
+			w := serve(t, h.BatchModify, http.MethodPost, "/emails/batch", "/emails/batch", `{"action":"read","ids":["m1"]}`)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if msg, _ := decodeError(t, w.Body.Bytes()); msg != tt.wantMsg {
+				t.Errorf("error = %q, want %q", msg, tt.wantMsg)
+			}
+		})
+	}
+}
+
+// Each failed email of a batch gets the message and code an error response would
+func TestBatchModifyResultErrors(t *testing.T) {
+	uc := &fakeUsecase{batchResults: []*emaildomain.BatchResult{
+		{ID: "m1", Success: true},
+		{ID: "m2", Cause: fmt.Errorf("%w: dial tcp 10.0.0.3:993: i/o timeout", imap.ErrServerUnreachable)},
+		{ID: "m3", Cause: errors.New("googleapi: Error 500: backend imap.internal.example failed")},
+	}}
+	w := serve(t, newTestHandler(uc).BatchModify, http.MethodPost, "/emails/batch", "/emails/batch", `{"action":"read","ids":["a","b","c"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", w.Code, w.Body)
+	}
+
+	var resp struct {
+		Results []struct {
+			ID      string `json:"id"`
+			Success bool   `json:"success"`
+			Error   string `json:"error"`
+			Code    string `json:"code"`
+		} `json:"results"`
+		Failed int `json:"failed"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Failed != 2 || len(resp.Results) != 3 {
+		t.Fatalf("response = %+v, want 3 results with 2 failed", resp)
+	}
+	want := []struct{ id, msg, code string }{
+		{"a", "", ""},
+		{"b", imap.ErrServerUnreachable.Error(), apierror.CodeProviderFailure},
+		{"c", "internal server error", apierror.CodeInternal},
+	}
+	for i, r := range resp.Results {
+		if r.ID != want[i].id || r.Error != want[i].msg || r.Code != want[i].code {
+			t.Errorf("result %d = (%q, %q, %q), want (%q, %q, %q)", i, r.ID, r.Error, r.Code, want[i].id, want[i].msg, want[i].code)
+		}
+	}
+	for _, leak := range []string{"10.0.0.3", "imap.internal"} {
+		if strings.Contains(w.Body.String(), leak) {
+			t.Errorf("response %s leaks %q", w.Body, leak)
+		}
+	}
+}
+
+// A failed account check is reported in a 200, with the same messages and codes as
+// an error response and none of the provider's details
+func TestAccountStatusError(t *testing.T) {
+	tests := []struct {
+		name     string
+		cause    error
+		wantMsg  string
+		wantCode string
+	}{
+		{"healthy", nil, "", ""},
+		{"unreachable", fmt.Errorf("%w: dial tcp 10.0.0.3:993: i/o timeout", imap.ErrServerUnreachable), imap.ErrServerUnreachable.Error(), apierror.CodeProviderFailure},
+		{"unusable credentials", fmt.Errorf("%w: cipher: message authentication failed", usecase.ErrIMAPCredentialsUnusable), usecase.ErrIMAPCredentialsUnusable.Error(), apierror.CodeReauthRequired},
+		{"unexpected", errors.New("* BYE imap.internal.example overloaded"), "internal server error", apierror.CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(&fakeUsecase{accountCause: tt.cause})
+			w := serve(t, h.GetAccountStatus, http.MethodGet, "/emails/account/status", "/emails/account/status", "")
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200 (%s)", w.Code, w.Body)
+			}
+			var status struct {
+				Error     string `json:"error"`
+				ErrorCode string `json:"error_code"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+				t.Fatal(err)
+			}
+			if status.Error != tt.wantMsg || status.ErrorCode != tt.wantCode {
+				t.Errorf("error = (%q, %q), want (%q, %q)", status.Error, status.ErrorCode, tt.wantMsg, tt.wantCode)
+			}
+			for _, leak := range []string{"10.0.0.3", "cipher", "imap.internal"} {
+				if strings.Contains(w.Body.String(), leak) {
+					t.Errorf("response %s leaks %q", w.Body, leak)
+				}
+			}
+		})
+	}
+}
+
+func TestCancelSendErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantMsg    string
+	}{
+		{"not pending", usecase.ErrPendingSendNotFound, http.StatusNotFound, usecase.ErrPendingSendNotFound.Error()},
+		{"already sending", fmt.Errorf("%w, it is already %s", usecase.ErrSendNotCancellable, "sending"), http.StatusConflict, usecase.ErrSendNotCancellable.Error()},
+		{"internal failure", errors.New("pq: deadlock detected"), http.StatusInternalServerError, "internal server error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(&fakeUsecase{cancelErr: tt.err})
+			w := serve(t, h.CancelSend, http.MethodPost, "/emails/:id/cancel-send", "/emails/p1/cancel-send", "")
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if msg, _ := decodeError(t, w.Body.Bytes()); msg != tt.wantMsg {
+				t.Errorf("error = %q, want %q", msg, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestGetChangesErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		err        error
+		wantStatus int
+		wantMsg    string
+	}{
+		{"no since or watermark", "/emails/changes", nil, http.StatusBadRequest, "since or watermark is required"},
+		{"bad since", "/emails/changes?since=yesterday", nil, http.StatusBadRequest, "since must be an RFC3339 timestamp"},
+		{"expired watermark", "/emails/changes?watermark=5", emaildomain.ErrWatermarkExpired, http.StatusGone, emaildomain.ErrWatermarkExpired.Error()},
+		{"foreign watermark", "/emails/changes?watermark=x", emaildomain.ErrInvalidWatermark, http.StatusBadRequest, emaildomain.ErrInvalidWatermark.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(&fakeUsecase{changesErr: tt.err})
+			w := serve(t, h.GetChanges, http.MethodGet, "/emails/changes", tt.path, "")
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if msg, _ := decodeError(t, w.Body.Bytes()); msg != tt.wantMsg {
+				t.Errorf("error = %q, want %q", msg, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestGetChangesReturnsWatermark(t *testing.T) {
+	h := newTestHandler(&fakeUsecase{})
+	w := serve(t, h.GetChanges, http.MethodGet, "/emails/changes", "/emails/changes?watermark=w1", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", w.Code, w.Body)
+	}
+	var changes emaildomain.Changes
+	if err := json.Unmarshal(w.Body.Bytes(), &changes); err != nil {
+		t.Fatal(err)
+	}
+	if changes.Watermark != "w1+1" || len(changes.Added) != 1 {
+		t.Errorf("changes = %+v, want the usecase's result", changes)
+	}
+}
+
+// Errors the handlers detect themselves have the same {error, code} shape as the
+// usecase's, and don't pass on Go binding errors
+func TestHandlerErrorShape(t *testing.T) {
+	tests := []struct {
+		name       string
+		uc         *fakeUsecase
+		handler    func(h *EmailHandler) gin.HandlerFunc
+		method     string
+		route      string
+		path       string
+		body       string
+		wantStatus int
+		wantCode   string
+		wantMsg    string
+	}{
+		{
+			name:       "body that doesn't bind",
+			uc:         &fakeUsecase{},
+			handler:    func(h *EmailHandler) gin.HandlerFunc { return h.BatchUpdateKanbanStatus },
+			method:     http.MethodPost,
+			route:      "/emails/kanban/batch",
+			path:       "/emails/kanban/batch",
+			body:       `{"ids": "m1", "status": 7}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apierror.CodeBadRequest,
+			wantMsg:    "invalid request body",
+		},
+		{
+			name:       "email not found",
+			uc:         &fakeUsecase{missing: true},
+			handler:    func(h *EmailHandler) gin.HandlerFunc { return h.GetEmailByID },
+			method:     http.MethodGet,
+			route:      "/emails/:id",
+			path:       "/emails/m1",
+			wantStatus: http.StatusNotFound,
+			wantCode:   apierror.CodeNotFound,
+			wantMsg:    emaildomain.ErrEmailNotFound.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, tt.handler(newTestHandler(tt.uc)), tt.method, tt.route, tt.path, tt.body)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if msg, code := decodeError(t, w.Body.Bytes()); msg != tt.wantMsg || code != tt.wantCode {
+				t.Errorf("body = (%q, %q), want (%q, %q)", msg, code, tt.wantMsg, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHandlerErrorShapeNotAuthenticated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/emails/:id", newTestHandler(&fakeUsecase{}).GetEmailByID)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/emails/m1", nil))
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", w.Code)
+	}
+	if msg, code := decodeError(t, w.Body.Bytes()); code != apierror.CodeUnauthorized || msg == "" {
+		t.Errorf("body = (%q, %q), want a message and %q", msg, code, apierror.CodeUnauthorized)
+	}
+}
@@ -0,0 +1,191 @@
+package delivery
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/internal/email/usecase"
+	"ga03-backend/pkg/sse"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeUsecase stands in for the email usecase. It embeds the interface, so a call a
+// test didn't expect panics instead of silently succeeding.
+type fakeUsecase struct {
+	usecase.EmailUsecase
+	kanbanErr error
+	cancelErr error
+	batchErr  error
+
+	batchResults []*emaildomain.BatchResult // What BatchModify returns
+	calls     []string
+
+	streamed  []*emaildomain.Email // What StreamEmailsByMailbox emits
+	streamErr error
+
+	archiveOnReply bool // Whether ReplyEmail archives the original
+
+	changesErr error // What GetChanges fails with
+
+	attachmentErr error // What GetAttachment fails with
+
+	missing bool // GetEmailByID finds nothing
+
+	thread []*emaildomain.Email // What GetThread returns
+
+	accountCause error // The failure GetAccountStatus reports
+}
+
+func (f *fakeUsecase) ResolveEmailID(_, id string) (string, error) { return id, nil }
+
+func (f *fakeUsecase) BatchUpdateKanbanStatus(string, []string, string) error { return f.kanbanErr }
+
+func (f *fakeUsecase) BatchModify(string, string, []string, string) ([]*emaildomain.BatchResult, error) {
+	return f.batchResults, f.batchErr
+}
+
+func (f *fakeUsecase) GetEmailByID(_, id string) (*emaildomain.Email, error) {
+	f.calls = append(f.calls, "GetEmailByID")
+	if f.missing {
+		return nil, nil
+	}
+	return &emaildomain.Email{ID: id, Subject: "Lunch", Body: "Noon at the usual place?"}, nil
+}
+
+func (f *fakeUsecase) GetEmailMetadata(_, id string) (*emaildomain.Email, error) {
+	f.calls = append(f.calls, "GetEmailMetadata")
+	return &emaildomain.Email{ID: id, Subject: "Lunch"}, nil
+}
+
+func (f *fakeUsecase) GetAccountStatus(string) (*emaildomain.AccountStatus, error) {
+	return &emaildomain.AccountStatus{Provider: "imap", NeedsReauth: f.accountCause != nil, Cause: f.accountCause}, nil
+}
+
+func (f *fakeUsecase) GetThread(string, string) ([]*emaildomain.Email, error) {
+	return f.thread, nil
+}
+
+func (f *fakeUsecase) MarkEmailAsRead(string, string) error {
+	f.calls = append(f.calls, "MarkEmailAsRead")
+	return nil
+}
+
+func (f *fakeUsecase) StreamEmailsByMailbox(_, _ string, _, _ int, _ string, onEmail emaildomain.EmailFunc) (int, error) {
+	for _, email := range f.streamed {
+		onEmail(email)
+	}
+	return len(f.streamed), f.streamErr
+}
+
+func (f *fakeUsecase) CheckMissingAttachment(string, bool) string { return "" }
+
+func (f *fakeUsecase) ReplyEmail(string, string, string, string, bool, bool, []*multipart.FileHeader) (bool, error) {
+	f.calls = append(f.calls, "ReplyEmail")
+	return f.archiveOnReply, nil
+}
+
+func (f *fakeUsecase) GetChanges(_, watermark string, _ time.Time) (*emaildomain.Changes, error) {
+	if f.changesErr != nil {
+		return nil, f.changesErr
+	}
+	return &emaildomain.Changes{Added: []string{"m1"}, Modified: []string{}, Deleted: []string{}, Watermark: watermark + "+1"}, nil
+}
+
+// GetAttachment finds nothing, like the usecase for an account without a mail provider
+func (f *fakeUsecase) GetAttachment(string, string, string) (*emaildomain.Attachment, []byte, error) {
+	return nil, nil, f.attachmentErr
+}
+
+func (f *fakeUsecase) CancelSend(string, string) error { return f.cancelErr }
+
+// serve runs one request through handler, registered on route, as a signed-in user
+func serve(t *testing.T, handler gin.HandlerFunc, method, route, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Handle(method, route, func(c *gin.Context) {
+		c.Set("user", &authdomain.User{ID: "u1", Email: "u1@example.com", Provider: "google"})
+		handler(c)
+	})
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func newTestHandler(uc usecase.EmailUsecase) *EmailHandler {
+	return NewEmailHandler(uc, sse.NewManager(1, sse.OverflowDropOldest, 0, 0, 0), nil)
+}
+
+type streamEvent struct {
+	Type    string         `json:"type"`
+	Payload map[string]any `json:"payload"`
+}
+
+// listen opens an event stream for userID on the handler's SSE manager and returns
+// the events pushed to it
+func listen(t *testing.T, h *EmailHandler, userID string) <-chan streamEvent {
+	t.Helper()
+	go h.sseManager.Run()
+	t.Cleanup(h.sseManager.Shutdown)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/events", func(c *gin.Context) { h.sseManager.ServeHTTP(c, userID) })
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	events := make(chan streamEvent, 100)
+	scanner := bufio.NewScanner(resp.Body)
+	// The stream is registered once its greeting arrives
+	for scanner.Scan() && scanner.Text() != "" {
+	}
+	go func() {
+		defer close(events)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var event streamEvent
+			if json.Unmarshal([]byte(data), &event) == nil {
+				events <- event
+			}
+		}
+	}()
+	return events
+}
+
+// next returns the next event, failing the test if none arrives in time
+func next(t *testing.T, events <-chan streamEvent) streamEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event arrived")
+		return streamEvent{}
+	}
+}
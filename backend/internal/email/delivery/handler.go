@@ -2,398 +2,1892 @@ package delivery
 
 import (
 	"context"
+	"errors"
 	"log"
+	"mime"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
 	emaildto "ga03-backend/internal/email/dto"
 	"ga03-backend/internal/email/usecase"
+	"ga03-backend/pkg/apierror"
+	"ga03-backend/pkg/imageproxy"
+	"ga03-backend/pkg/pdf"
+	"ga03-backend/pkg/sse"
+	"ga03-backend/pkg/utils/mailutil"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type EmailHandler struct {
 	emailUsecase usecase.EmailUsecase
+	sseManager   *sse.Manager
+	imageProxy   *imageproxy.Proxy
 }
 
-// GET /emails/:id/summary
+// userContext returns the request context carrying the signed-in user for the AI use
+// cases, or answers 401 and returns false
+func userContext(c *gin.Context) (context.Context, bool) {
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return nil, false
+	}
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return nil, false
+	}
+	return usecase.WithUserID(c.Request.Context(), userData.ID), true
+}
+
+// GET /emails/:id/summary?force=true
 func (h *EmailHandler) SummarizeEmail(c *gin.Context) {
 	id := c.Param("id")
-	ctx := c.Request.Context()
+	ctx, ok := userContext(c)
+	if !ok {
+		return
+	}
+	summary, err := h.emailUsecase.SummarizeEmail(ctx, id, c.Query("force") == "true")
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"summary": summary})
+}
+
+// GET /emails/:id/categorize?apply=true
+func (h *EmailHandler) CategorizeEmail(c *gin.Context) {
+	id := c.Param("id")
+	ctx, ok := userContext(c)
+	if !ok {
+		return
+	}
+	category, err := h.emailUsecase.CategorizeEmail(ctx, id, c.Query("apply") == "true")
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, category)
+}
+
+// GET /emails/:id/suggest-replies
+func (h *EmailHandler) SuggestReplies(c *gin.Context) {
+	id := c.Param("id")
+	ctx, ok := userContext(c)
+	if !ok {
+		return
+	}
+	suggestions, err := h.emailUsecase.SuggestReplies(ctx, id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"suggestions": suggestions})
+}
+
+// GET /emails/:id/translate?lang=vi
+func (h *EmailHandler) TranslateEmail(c *gin.Context) {
+	id := c.Param("id")
+	lang := c.Query("lang")
+	if lang == "" {
+		respondBadRequest(c, "lang is required")
+		return
+	}
+	ctx, ok := userContext(c)
+	if !ok {
+		return
+	}
+	translation, err := h.emailUsecase.TranslateEmail(ctx, id, lang)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, translation)
+}
+
+// PATCH /emails/:id/mailbox
+func (h *EmailHandler) MoveEmailToMailbox(c *gin.Context) {
+	id := c.Param("id")
+	var req struct {
+		MailboxID string `json:"mailbox_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.MailboxID == "" {
+		respondBadRequest(c, "Missing mailbox_id")
+		return
+	}
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+	userID := userData.ID
+	if err := h.emailUsecase.MoveToMailbox(userID, id, req.MailboxID); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "email moved", "mailbox_id": req.MailboxID})
+}
+
+// PATCH /emails/:id/status
+func (h *EmailHandler) UpdateKanbanStatus(c *gin.Context) {
+	id := c.Param("id")
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Status == "" {
+		respondBadRequest(c, "Missing status")
+		return
+	}
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+	userID := userData.ID
+	if err := h.emailUsecase.UpdateKanbanStatus(userID, id, req.Status); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "status updated", "status": req.Status})
+}
+
+// GET /emails/gmail/filters
+func (h *EmailHandler) ListGmailFilters(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	filters, err := h.emailUsecase.ListGmailFilters(userData.ID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"filters": filters})
+}
+
+// POST /emails/gmail/filters
+func (h *EmailHandler) CreateGmailFilter(c *gin.Context) {
+	var filter emaildomain.MailFilter
+	if err := c.ShouldBindJSON(&filter); err != nil {
+		respondInvalidBody(c)
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	created, err := h.emailUsecase.CreateGmailFilter(userData.ID, &filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// DELETE /emails/gmail/filters/:filterId
+func (h *EmailHandler) DeleteGmailFilter(c *gin.Context) {
+	filterID := c.Param("filterId")
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	if err := h.emailUsecase.DeleteGmailFilter(userData.ID, filterID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "filter deleted"})
+}
+
+// POST /emails/prefetch
+// Warms the cache with the next emails in the list so the reading pane opens instantly
+func (h *EmailHandler) PrefetchEmails(c *gin.Context) {
+	var req emaildto.PrefetchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidBody(c)
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	ids, err := h.resolveEmailIDs(userData.ID, req.IDs)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	queued := h.emailUsecase.PrefetchEmails(userData.ID, ids)
+	c.JSON(http.StatusAccepted, gin.H{"queued": queued})
+}
+
+// GET /emails/:id/invite
+func (h *EmailHandler) GetInvite(c *gin.Context) {
+	id := c.Param("id")
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	event, err := h.emailUsecase.GetInvite(userData.ID, id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invite": event})
+}
+
+// POST /emails/:id/invite/respond
+func (h *EmailHandler) RespondToInvite(c *gin.Context) {
+	id := c.Param("id")
+
+	var req emaildto.InviteResponseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidBody(c)
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	if err := h.emailUsecase.RespondToInvite(userData.ID, id, req.Response); err != nil {
+		if errors.Is(err, usecase.ErrSendScopeNotGranted) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": apierror.CodeReauthRequired, "needs_reauth": true})
+			return
+		}
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "invite response sent", "response": req.Response})
+}
+
+// GET /emails/threads/:id
+// GetThread returns every message of a conversation, oldest first. The ID may also be
+// the ID of any email in the thread.
+func (h *EmailHandler) GetThread(c *gin.Context) {
+	threadID := c.Param("id")
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	emails, err := h.emailUsecase.GetThread(userData.ID, threadID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"thread_id": threadID, "emails": h.withRemoteImagesAll(emails, c.Query("images") == "load")})
+}
+
+// GET /emails/threads/:id/participants
+func (h *EmailHandler) GetThreadParticipants(c *gin.Context) {
+	threadID := c.Param("id")
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	participants, err := h.emailUsecase.GetThreadParticipants(userData.ID, threadID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"participants": participants})
+}
+
+// GET /emails/threads/:id/search?q=
+func (h *EmailHandler) SearchThread(c *gin.Context) {
+	threadID := c.Param("id")
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		respondBadRequest(c, "q is required")
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	matches, err := h.emailUsecase.SearchThread(userData.ID, threadID, query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"matches": matches})
+}
+
+// PATCH /emails/threads/:id/read
+func (h *EmailHandler) MarkThreadAsRead(c *gin.Context) {
+	threadID := c.Param("id")
+
 	user, exists := c.Get("user")
-	var userID string
-	if exists {
-		if u, ok := user.(*authdomain.User); ok {
-			userID = u.ID
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	if err := h.emailUsecase.MarkThreadAsRead(userData.ID, threadID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "thread marked as read"})
+}
+
+// PATCH /emails/threads/:id/unread
+func (h *EmailHandler) MarkThreadAsUnread(c *gin.Context) {
+	threadID := c.Param("id")
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	if err := h.emailUsecase.MarkThreadAsUnread(userData.ID, threadID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "thread marked as unread"})
+}
+
+// PATCH /emails/threads/:id/star
+func (h *EmailHandler) ToggleThreadStar(c *gin.Context) {
+	threadID := c.Param("id")
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	if err := h.emailUsecase.ToggleThreadStar(userData.ID, threadID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "thread star toggled"})
+}
+
+// POST /emails/threads/:id/trash
+func (h *EmailHandler) TrashThread(c *gin.Context) {
+	threadID := c.Param("id")
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	if err := h.emailUsecase.TrashThread(userData.ID, threadID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "thread moved to trash"})
+}
+
+// POST /emails/kanban/batch
+func (h *EmailHandler) BatchUpdateKanbanStatus(c *gin.Context) {
+	var req emaildto.KanbanBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidBody(c)
+		return
+	}
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+	userID := userData.ID
+	ids, err := h.resolveEmailIDs(userID, req.IDs)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := h.emailUsecase.BatchUpdateKanbanStatus(userID, ids, req.Status); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// One event for the whole selection so other tabs can update in a single pass
+	h.sseManager.SendToUser(userID, "kanban_updated", gin.H{
+		"ids":    req.IDs,
+		"status": req.Status,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "emails moved", "ids": req.IDs, "status": req.Status})
+}
+
+// POST /emails/batch
+// Applies one action to many emails. Responds 200 even when some IDs failed; each
+// result says whether its email was changed.
+func (h *EmailHandler) BatchModify(c *gin.Context) {
+	var req emaildto.BatchModifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidBody(c)
+		return
+	}
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+	userID := userData.ID
+
+	// Resolve one by one so an unknown stable ID only fails its own entry
+	ids := make([]string, len(req.IDs))
+	for i, id := range req.IDs {
+		ids[i] = id
+		if resolved, err := h.resolveEmailIDs(userID, []string{id}); err == nil {
+			ids[i] = resolved[0]
+		}
+	}
+
+	results, err := h.emailUsecase.BatchModify(userID, req.Action, ids, req.Mailbox)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	failed := 0
+	for i, result := range results {
+		// Report the IDs the client sent
+		result.ID = req.IDs[i]
+		if !result.Success {
+			failed++
+		}
+		if result.Cause != nil {
+			result.Error, result.Code = classifyError(c, result.Cause)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"action":    req.Action,
+		"results":   results,
+		"succeeded": len(results) - failed,
+		"failed":    failed,
+	})
+}
+
+// POST /emails/:id/snooze
+func (h *EmailHandler) SnoozeEmail(c *gin.Context) {
+	id := c.Param("id")
+	var req struct {
+		SnoozeUntil string `json:"snooze_until"` // ISO 8601 format
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.SnoozeUntil == "" {
+		respondBadRequest(c, "Missing snooze_until")
+		return
+	}
+
+	snoozeTime, err := time.Parse(time.RFC3339, req.SnoozeUntil)
+	if err != nil {
+		respondBadRequest(c, "Invalid date format. Use ISO 8601")
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+	userID := userData.ID
+
+	if err := h.emailUsecase.SnoozeEmail(userID, id, snoozeTime); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "email snoozed", "snooze_until": snoozeTime})
+}
+
+// POST /emails/:id/unsubscribe
+func (h *EmailHandler) Unsubscribe(c *gin.Context) {
+	id := c.Param("id")
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+	userID := userData.ID
+
+	if err := h.emailUsecase.Unsubscribe(userID, id); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "unsubscribed"})
+}
+
+// GET /emails/stats?days=7
+func (h *EmailHandler) GetStats(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+	userID := userData.ID
+
+	days := 0
+	if daysStr := c.Query("days"); daysStr != "" {
+		if parsed, err := strconv.Atoi(daysStr); err == nil && parsed > 0 {
+			days = parsed
+		}
+	}
+
+	stats, err := h.emailUsecase.GetStats(userID, days)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// GET /emails/unified?limit=20&offset=0
+// The inboxes of the user's own and linked accounts, newest first
+func (h *EmailHandler) GetUnifiedInbox(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	limit := 20
+	offset := 0
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if parsed, err := strconv.Atoi(offsetStr); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	inbox, err := h.emailUsecase.GetUnifiedInbox(userData.ID, limit, offset)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	view := *inbox
+	view.Emails = h.withRemoteImagesAll(inbox.Emails, c.Query("images") == "load")
+	c.JSON(http.StatusOK, view)
+}
+
+// GET /contacts?q=ali&limit=10
+// Recipient autocomplete from the addresses the user has mailed with
+func (h *EmailHandler) SearchContacts(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	contacts, err := h.emailUsecase.SearchContacts(userData.ID, c.Query("q"), limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"contacts": contacts})
+}
+
+// POST /emails/mailboxes/:id/sync
+func (h *EmailHandler) SyncMailbox(c *gin.Context) {
+	mailboxID := c.Param("id")
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+	userID := userData.ID
+
+	state, err := h.emailUsecase.SyncMailbox(userID, mailboxID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, state)
+}
+
+// GET /emails/changes?since=<RFC3339>&watermark=<opaque>
+// Lists email IDs added, modified or deleted since the watermark from a previous
+// call, or since the timestamp on first sync. Returns 410 when the watermark is too
+// old for the provider's history and the client must resync in full.
+func (h *EmailHandler) GetChanges(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	watermark := c.Query("watermark")
+	var since time.Time
+	if sinceStr := c.Query("since"); sinceStr != "" {
+		parsed, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			respondBadRequest(c, "since must be an RFC3339 timestamp")
+			return
+		}
+		since = parsed
+	}
+	if watermark == "" && since.IsZero() {
+		respondBadRequest(c, "since or watermark is required")
+		return
+	}
+
+	changes, err := h.emailUsecase.GetChanges(userData.ID, watermark, since)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, changes)
+}
+
+// GET /emails/account/status
+func (h *EmailHandler) GetAccountStatus(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+	userID := userData.ID
+
+	status, err := h.emailUsecase.GetAccountStatus(userID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if status.Cause != nil {
+		status.Error, status.ErrorCode = classifyError(c, status.Cause)
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func NewEmailHandler(emailUsecase usecase.EmailUsecase, sseManager *sse.Manager, imageProxy *imageproxy.Proxy) *EmailHandler {
+	return &EmailHandler{
+		emailUsecase: emailUsecase,
+		sseManager:   sseManager,
+		imageProxy:   imageProxy,
+	}
+}
+
+func (h *EmailHandler) GetAllMailboxes(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	userID := userData.ID
+
+	// ?tree=true nests folders by hierarchy; the flat list stays the default
+	getMailboxes := h.emailUsecase.GetAllMailboxes
+	if c.Query("tree") == "true" {
+		getMailboxes = h.emailUsecase.GetMailboxTree
+	}
+
+	mailboxes, err := getMailboxes(userID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, emaildto.MailboxesResponse{Mailboxes: mailboxes})
+}
+
+func (h *EmailHandler) GetMailboxByID(c *gin.Context) {
+	id := c.Param("id")
+	mailbox, err := h.emailUsecase.GetMailboxByID(id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if mailbox == nil {
+		respondError(c, errMailboxNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, mailbox)
+}
+
+func (h *EmailHandler) GetEmailsByMailbox(c *gin.Context) {
+	mailboxID := c.Param("id")
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	userID := userData.ID
+
+	limit := 20
+	offset := 0
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if parsed, err := strconv.Atoi(offsetStr); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	query := c.Query("q")
+	loadImages := c.Query("images") == "load"
+
+	// ?stream=true pushes each email over SSE as it is fetched ("email_loaded"),
+	// followed by a single "list_complete" event, so the UI can render progressively
+	if c.Query("stream") == "true" {
+		streamID := uuid.New().String()
+		go func() {
+			count := 0
+			total, err := h.emailUsecase.StreamEmailsByMailbox(userID, mailboxID, limit, offset, query, func(email *emaildomain.Email) {
+				count++
+				h.sseManager.SendToUser(userID, "email_loaded", gin.H{
+					"stream_id":  streamID,
+					"mailbox_id": mailboxID,
+					"email":      h.withRemoteImages(email, loadImages),
+				})
+			})
+
+			complete := gin.H{
+				"stream_id":  streamID,
+				"mailbox_id": mailboxID,
+				"count":      count,
+				"total":      total,
+				"limit":      limit,
+				"offset":     offset,
+			}
+			if err != nil {
+				log.Printf("Failed to stream emails for user %s: %v", userID, err)
+				// Same message and code a failed request would get, never the raw error
+				_, code, message := apierror.Classify(err, emailErrors)
+				complete["error"] = message
+				complete["code"] = code
+			}
+			h.sseManager.SendToUser(userID, "list_complete", complete)
+		}()
+
+		c.JSON(http.StatusAccepted, gin.H{"stream_id": streamID})
+		return
+	}
+
+	// ?pageToken= continues from a previous response's next_page_token (Gmail only)
+	emails, total, nextPageToken, err := h.emailUsecase.GetEmailsByMailbox(userID, mailboxID, limit, offset, query, c.Query("pageToken"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// ?priority=high|normal|low filters and ?sort=priority orders the page by sender priority
+	priority := c.Query("priority")
+	if priority != "" && priority != mailutil.PriorityHigh && priority != mailutil.PriorityNormal && priority != mailutil.PriorityLow {
+		respondBadRequest(c, "priority must be high, normal or low")
+		return
+	}
+	emails = applyPriorityView(emails, priority, c.Query("sort") == "priority")
+
+	c.JSON(http.StatusOK, emaildto.EmailsResponse{
+		Emails:        h.withRemoteImagesAll(emails, loadImages),
+		Limit:         limit,
+		Offset:        offset,
+		Total:         total,
+		NextPageToken: nextPageToken,
+	})
+}
+
+// POST /emails/export/pdf?images=load
+// Renders the given emails into one PDF. Remote images are only fetched with
+// images=load, through the image proxy.
+func (h *EmailHandler) ExportEmailsPDF(c *gin.Context) {
+	var req emaildto.ExportPDFRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidBody(c)
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	ids, err := h.resolveEmailIDs(userData.ID, req.IDs)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Remote images stay out unless asked for, and then come through the image proxy
+	var remoteImages pdf.ImageLoader
+	if c.Query("images") == "load" && h.imageProxy != nil {
+		ctx := c.Request.Context()
+		remoteImages = func(src string) ([]byte, error) {
+			img, err := h.imageProxy.Fetch(ctx, src)
+			if err != nil {
+				return nil, err
+			}
+			return img.Data, nil
+		}
+	}
+
+	data, err := h.emailUsecase.ExportEmailsPDF(userData.ID, ids, remoteImages)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=\"emails.pdf\"")
+	c.Data(http.StatusOK, "application/pdf", data)
+}
+
+// applyPriorityView filters a page of emails to one priority and/or orders it from high
+// to low priority. Emails of equal priority keep their original order.
+func applyPriorityView(emails []*emaildomain.Email, priority string, sortByPriority bool) []*emaildomain.Email {
+	if priority != "" {
+		filtered := make([]*emaildomain.Email, 0, len(emails))
+		for _, email := range emails {
+			p := email.Priority
+			if p == "" {
+				p = mailutil.PriorityNormal
+			}
+			if p == priority {
+				filtered = append(filtered, email)
+			}
+		}
+		emails = filtered
+	}
+	if sortByPriority {
+		sort.SliceStable(emails, func(i, j int) bool {
+			return mailutil.PriorityRank(emails[i].Priority) < mailutil.PriorityRank(emails[j].Priority)
+		})
+	}
+	return emails
+}
+
+func (h *EmailHandler) GetEmailByID(c *gin.Context) {
+	id := c.Param("id")
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	userID := userData.ID
+
+	// ?metadata=true returns headers/flags only, for quick peeks that shouldn't fetch the body
+	if c.Query("metadata") == "true" {
+		email, err := h.emailUsecase.GetEmailMetadata(userID, id)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		if email == nil {
+			respondError(c, emaildomain.ErrEmailNotFound)
+			return
+		}
+		c.JSON(http.StatusOK, email)
+		return
+	}
+
+	email, err := h.emailUsecase.GetEmailByID(userID, id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if email == nil {
+		respondError(c, emaildomain.ErrEmailNotFound)
+		return
+	}
+
+	// Mark as read when viewing
+	_ = h.emailUsecase.MarkEmailAsRead(userID, id)
+
+	c.JSON(http.StatusOK, h.withRemoteImages(email, c.Query("images") == "load"))
+}
+
+// withRemoteImages returns a copy of email whose remote images are blocked, or routed
+// through the image proxy when load is set, so opening a message doesn't reach the sender
+func (h *EmailHandler) withRemoteImages(email *emaildomain.Email, load bool) *emaildomain.Email {
+	if !email.IsHTML {
+		return email
+	}
+
+	view := *email
+	if load {
+		view.Body, _ = mailutil.RewriteRemoteImages(email.Body, func(src string) string {
+			return h.imageProxy.ProxyURL(email.ID, src)
+		})
+		return &view
+	}
+
+	view.Body, view.ImagesBlocked = mailutil.BlockRemoteImages(email.Body)
+	return &view
+}
+
+// withRemoteImagesAll applies withRemoteImages to every email of a list, so no
+// endpoint that returns bodies lets them load remote images by default
+func (h *EmailHandler) withRemoteImagesAll(emails []*emaildomain.Email, load bool) []*emaildomain.Email {
+	views := make([]*emaildomain.Email, len(emails))
+	for i, email := range emails {
+		views[i] = h.withRemoteImages(email, load)
+	}
+	return views
+}
+
+// GET /emails/:id/proxy-image?src=&sig=
+// Serves a remote image fetched server-side. The signature comes from the rewritten
+// email body, which is what makes the endpoint usable from <img> tags without a token.
+func (h *EmailHandler) ProxyImage(c *gin.Context) {
+	rawURL := c.Query("src")
+	if rawURL == "" {
+		respondBadRequest(c, "src is required")
+		return
+	}
+	if !h.imageProxy.Verify(c.Param("id"), rawURL, c.Query("sig")) {
+		respondError(c, imageproxy.ErrInvalidSignature)
+		return
+	}
+
+	img, err := h.imageProxy.Fetch(c.Request.Context(), rawURL)
+	if err != nil {
+		if errors.Is(err, imageproxy.ErrFetchFailed) {
+			log.Printf("Image proxy: %v", err)
+		}
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "default-src 'none'")
+	c.Data(http.StatusOK, img.ContentType, img.Data)
+}
+
+func (h *EmailHandler) MarkAsRead(c *gin.Context) {
+	id := c.Param("id")
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	userID := userData.ID
+
+	if err := h.emailUsecase.MarkEmailAsRead(userID, id); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email marked as read"})
+}
+
+func (h *EmailHandler) MarkAsUnread(c *gin.Context) {
+	id := c.Param("id")
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	userID := userData.ID
+
+	if err := h.emailUsecase.MarkEmailAsUnread(userID, id); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email marked as unread"})
+}
+
+func (h *EmailHandler) ToggleStar(c *gin.Context) {
+	id := c.Param("id")
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	userID := userData.ID
+
+	if err := h.emailUsecase.ToggleStar(userID, id); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email star toggled"})
+}
+
+func (h *EmailHandler) ToggleImportant(c *gin.Context) {
+	id := c.Param("id")
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	userID := userData.ID
+
+	if err := h.emailUsecase.ToggleImportant(userID, id); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email importance toggled"})
+}
+
+func (h *EmailHandler) SendEmail(c *gin.Context) {
+	var req emaildto.SendEmailRequest
+	if err := c.ShouldBind(&req); err != nil {
+		respondInvalidBody(c)
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	userID := userData.ID
+
+	// Ask the client to confirm instead of sending a message that forgot its attachment
+	if !req.Confirm {
+		if warning := h.emailUsecase.CheckMissingAttachment(req.Body, len(req.Files) > 0); warning != "" {
+			c.JSON(http.StatusConflict, gin.H{"warning": warning, "confirm_required": true})
+			return
 		}
 	}
-	ctx = context.WithValue(ctx, "userID", userID)
-	summary, err := h.emailUsecase.SummarizeEmail(ctx, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+
+	if req.Sign {
+		req.Body = mailutil.AppendSignature(req.Body, userData.Signature)
+	}
+
+	if req.Undo {
+		pendingID, sendAt, err := h.emailUsecase.SendEmailWithUndo(userID, req.FromName, req.To, req.Cc, req.Bcc, req.Subject, req.Body, req.Files)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		if pendingID != "" {
+			c.JSON(http.StatusAccepted, gin.H{"message": "email will be sent", "pending_send_id": pendingID, "send_at": sendAt})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "email sent successfully"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"summary": summary})
+
+	if err := h.emailUsecase.SendEmail(userID, req.FromName, req.To, req.Cc, req.Bcc, req.Subject, req.Body, req.Files); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email sent successfully"})
 }
 
-// PATCH /emails/:id/mailbox
-func (h *EmailHandler) MoveEmailToMailbox(c *gin.Context) {
-	id := c.Param("id")
-	var req struct {
-		MailboxID string `json:"mailbox_id"`
+// POST /emails/:id/cancel-send
+// Cancels a send made with undo=true while its undo window is still open
+func (h *EmailHandler) CancelSend(c *gin.Context) {
+	pendingID := c.Param("id")
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
 	}
-	if err := c.ShouldBindJSON(&req); err != nil || req.MailboxID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing mailbox_id"})
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	if err := h.emailUsecase.CancelSend(userData.ID, pendingID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "send cancelled"})
+}
+
+// POST /emails/schedule
+func (h *EmailHandler) ScheduleEmail(c *gin.Context) {
+	var req emaildto.ScheduleEmailRequest
+	if err := c.ShouldBind(&req); err != nil {
+		respondInvalidBody(c)
 		return
 	}
+
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		respondError(c, errNotAuthenticated)
 		return
 	}
+
 	userData, ok := user.(*authdomain.User)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user data"})
+		respondError(c, errInvalidUserData)
 		return
 	}
-	userID := userData.ID
-	if err := h.emailUsecase.MoveEmailToMailbox(userID, id, req.MailboxID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+
+	if !req.Confirm {
+		if warning := h.emailUsecase.CheckMissingAttachment(req.Body, len(req.Files) > 0); warning != "" {
+			c.JSON(http.StatusConflict, gin.H{"warning": warning, "confirm_required": true})
+			return
+		}
+	}
+
+	if req.Sign {
+		req.Body = mailutil.AppendSignature(req.Body, userData.Signature)
+	}
+
+	scheduled, err := h.emailUsecase.ScheduleEmail(userData.ID, req.FromName, req.To, req.Cc, req.Bcc, req.Subject, req.Body, req.Files, req.SendAt)
+	if err != nil {
+		respondInvalidBody(c)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "email moved", "mailbox_id": req.MailboxID})
+
+	c.JSON(http.StatusCreated, scheduled)
 }
 
-// POST /emails/:id/snooze
-func (h *EmailHandler) SnoozeEmail(c *gin.Context) {
-	id := c.Param("id")
-	var req struct {
-		SnoozeUntil string `json:"snooze_until"` // ISO 8601 format
+// GET /emails/scheduled
+func (h *EmailHandler) ListScheduledEmails(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
 	}
-	if err := c.ShouldBindJSON(&req); err != nil || req.SnoozeUntil == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing snooze_until"})
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
 		return
 	}
 
-	snoozeTime, err := time.Parse(time.RFC3339, req.SnoozeUntil)
+	scheduled, err := h.emailUsecase.ListScheduledEmails(userData.ID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use ISO 8601"})
+		respondError(c, err)
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{"scheduled": scheduled})
+}
+
+// DELETE /emails/scheduled/:scheduledId
+func (h *EmailHandler) CancelScheduledEmail(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		respondError(c, errNotAuthenticated)
 		return
 	}
+
 	userData, ok := user.(*authdomain.User)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user data"})
+		respondError(c, errInvalidUserData)
 		return
 	}
-	userID := userData.ID
 
-	if err := h.emailUsecase.SnoozeEmail(userID, id, snoozeTime); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.emailUsecase.CancelScheduledEmail(userData.ID, c.Param("scheduledId")); err != nil {
+		respondInvalidBody(c)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "email snoozed", "snooze_until": snoozeTime})
+
+	c.JSON(http.StatusOK, gin.H{"message": "scheduled email cancelled"})
 }
 
-func NewEmailHandler(emailUsecase usecase.EmailUsecase) *EmailHandler {
-	return &EmailHandler{
-		emailUsecase: emailUsecase,
+// POST /emails/:id/reply
+func (h *EmailHandler) ReplyEmail(c *gin.Context) {
+	id := c.Param("id")
+
+	var req emaildto.ReplyEmailRequest
+	if err := c.ShouldBind(&req); err != nil {
+		respondInvalidBody(c)
+		return
 	}
-}
 
-func (h *EmailHandler) GetAllMailboxes(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		respondError(c, errNotAuthenticated)
 		return
 	}
 
 	userData, ok := user.(*authdomain.User)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user data"})
+		respondError(c, errInvalidUserData)
 		return
 	}
 
 	userID := userData.ID
 
-	mailboxes, err := h.emailUsecase.GetAllMailboxes(userID)
+	if !req.Confirm {
+		if warning := h.emailUsecase.CheckMissingAttachment(req.Body, len(req.Files) > 0); warning != "" {
+			c.JSON(http.StatusConflict, gin.H{"warning": warning, "confirm_required": true})
+			return
+		}
+	}
+
+	archived, err := h.emailUsecase.ReplyEmail(userID, id, req.FromName, req.Body, req.PlainText, req.ReplyAll, req.Files)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, emaildto.MailboxesResponse{Mailboxes: mailboxes})
+	if archived {
+		h.sseManager.SendToUser(userID, "email_archived", gin.H{"id": id})
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "reply sent successfully", "archived": archived})
 }
 
-func (h *EmailHandler) GetMailboxByID(c *gin.Context) {
+// POST /emails/:id/resend
+func (h *EmailHandler) ResendEmail(c *gin.Context) {
 	id := c.Param("id")
-	mailbox, err := h.emailUsecase.GetMailboxByID(id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+
+	// All fields are optional, an empty body resends to the original recipients
+	var req emaildto.ResendEmailRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondInvalidBody(c)
+			return
+		}
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
 		return
 	}
 
-	if mailbox == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "mailbox not found"})
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
 		return
 	}
 
-	c.JSON(http.StatusOK, mailbox)
+	if err := h.emailUsecase.ResendEmail(userData.ID, id, req.To, req.Cc, req.Bcc, req.Confirm); err != nil {
+		if errors.Is(err, usecase.ErrTooManyRecipients) {
+			c.JSON(http.StatusConflict, gin.H{"warning": err.Error(), "confirm_required": true})
+			return
+		}
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email resent successfully"})
 }
 
-func (h *EmailHandler) GetEmailsByMailbox(c *gin.Context) {
-	mailboxID := c.Param("id")
+// POST /emails/:id/reply/preview
+func (h *EmailHandler) PreviewReply(c *gin.Context) {
+	id := c.Param("id")
+
+	var req emaildto.ReplyPreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidBody(c)
+		return
+	}
 
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		respondError(c, errNotAuthenticated)
 		return
 	}
 
 	userData, ok := user.(*authdomain.User)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user data"})
+		respondError(c, errInvalidUserData)
 		return
 	}
 
-	userID := userData.ID
+	body, err := h.emailUsecase.PreviewReply(userData.ID, id, req.Body, req.PlainText)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
 
-	limit := 20
-	offset := 0
+	c.JSON(http.StatusOK, gin.H{"body": body})
+}
 
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
-			limit = parsed
-		}
+// POST /emails/preview
+// Returns the message exactly as the send or reply endpoints would send it
+func (h *EmailHandler) PreviewEmail(c *gin.Context) {
+	var req emaildto.EmailPreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidBody(c)
+		return
 	}
 
-	if offsetStr := c.Query("offset"); offsetStr != "" {
-		if parsed, err := strconv.Atoi(offsetStr); err == nil && parsed >= 0 {
-			offset = parsed
-		}
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
 	}
 
-	query := c.Query("q")
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
 
-	emails, total, err := h.emailUsecase.GetEmailsByMailbox(userID, mailboxID, limit, offset, query)
+	replyToID := req.ReplyToID
+	if replyToID != "" {
+		ids, err := h.resolveEmailIDs(userData.ID, []string{replyToID})
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		replyToID = ids[0]
+	} else if req.Sign {
+		req.Body = mailutil.AppendSignature(req.Body, userData.Signature)
+	}
+
+	preview, err := h.emailUsecase.PreviewEmail(userData.ID, replyToID, req.FromName, req.To, req.Cc, req.Bcc, req.Subject, req.Body, req.PlainText, req.ReplyAll)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, emaildto.EmailsResponse{
-		Emails: emails,
-		Limit:  limit,
-		Offset: offset,
-		Total:  total,
-	})
+	c.JSON(http.StatusOK, preview)
 }
 
-func (h *EmailHandler) GetEmailByID(c *gin.Context) {
-	id := c.Param("id")
+// PUT /emails/settings/reply
+func (h *EmailHandler) UpdateReplySettings(c *gin.Context) {
+	var req emaildto.ReplySettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidBody(c)
+		return
+	}
 
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		respondError(c, errNotAuthenticated)
 		return
 	}
 
 	userData, ok := user.(*authdomain.User)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user data"})
+		respondError(c, errInvalidUserData)
 		return
 	}
 
-	userID := userData.ID
+	if err := h.emailUsecase.UpdateReplySettings(userData.ID, &req); err != nil {
+		respondError(c, err)
+		return
+	}
 
-	email, err := h.emailUsecase.GetEmailByID(userID, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	c.JSON(http.StatusOK, gin.H{"message": "reply settings updated"})
+}
+
+func (h *EmailHandler) TrashEmail(c *gin.Context) {
+	id := c.Param("id")
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
 		return
 	}
 
-	if email == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "email not found"})
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
 		return
 	}
 
-	// Mark as read when viewing
-	_ = h.emailUsecase.MarkEmailAsRead(userID, id)
+	userID := userData.ID
+
+	if err := h.emailUsecase.TrashEmail(userID, id); err != nil {
+		respondError(c, err)
+		return
+	}
 
-	c.JSON(http.StatusOK, email)
+	c.JSON(http.StatusOK, gin.H{"message": "email moved to trash"})
 }
 
-func (h *EmailHandler) MarkAsRead(c *gin.Context) {
+// DELETE /emails/:id
+// Permanently deletes an email; it must be in Trash or Spam first
+func (h *EmailHandler) DeleteEmail(c *gin.Context) {
 	id := c.Param("id")
 
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		respondError(c, errNotAuthenticated)
 		return
 	}
 
 	userData, ok := user.(*authdomain.User)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user data"})
+		respondError(c, errInvalidUserData)
 		return
 	}
 
-	userID := userData.ID
+	if err := h.emailUsecase.DeleteEmail(userData.ID, id); err != nil {
+		respondError(c, err)
+		return
+	}
 
-	if err := h.emailUsecase.MarkEmailAsRead(userID, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	c.JSON(http.StatusOK, gin.H{"message": "email deleted permanently"})
+}
+
+// POST /emails/trash/empty
+func (h *EmailHandler) EmptyTrash(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "email marked as read"})
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	deleted, err := h.emailUsecase.EmptyTrash(userData.ID)
+	if err != nil {
+		status, code, message := apierror.Classify(err, emailErrors)
+		c.JSON(status, gin.H{"error": message, "code": code, "deleted": deleted})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "trash emptied", "deleted": deleted})
 }
 
-func (h *EmailHandler) MarkAsUnread(c *gin.Context) {
-	id := c.Param("id")
+// SaveDraft creates a draft, or replaces the one named by draft_id
+func (h *EmailHandler) SaveDraft(c *gin.Context) {
+	var req emaildto.DraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidBody(c)
+		return
+	}
 
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		respondError(c, errNotAuthenticated)
 		return
 	}
 
 	userData, ok := user.(*authdomain.User)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user data"})
+		respondError(c, errInvalidUserData)
 		return
 	}
 
-	userID := userData.ID
-
-	if err := h.emailUsecase.MarkEmailAsUnread(userID, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	draftID, err := h.emailUsecase.SaveDraft(userData.ID, &req)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "email marked as unread"})
+	status := http.StatusCreated
+	if req.DraftID != "" {
+		status = http.StatusOK
+	}
+	c.JSON(status, gin.H{"draft_id": draftID})
 }
 
-func (h *EmailHandler) ToggleStar(c *gin.Context) {
-	id := c.Param("id")
+func (h *EmailHandler) UpdateDraft(c *gin.Context) {
+	draftID := c.Param("id")
+
+	var req emaildto.DraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidBody(c)
+		return
+	}
 
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		respondError(c, errNotAuthenticated)
 		return
 	}
 
 	userData, ok := user.(*authdomain.User)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user data"})
+		respondError(c, errInvalidUserData)
 		return
 	}
 
-	userID := userData.ID
+	// IMAP drafts get a new ID on every save
+	newID, err := h.emailUsecase.UpdateDraft(userData.ID, draftID, &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
 
-	if err := h.emailUsecase.ToggleStar(userID, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	c.JSON(http.StatusOK, gin.H{"draft_id": newID})
+}
+
+func (h *EmailHandler) DeleteDraft(c *gin.Context) {
+	draftID := c.Param("id")
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "email star toggled"})
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	if err := h.emailUsecase.DeleteDraft(userData.ID, draftID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "draft deleted"})
 }
 
-func (h *EmailHandler) SendEmail(c *gin.Context) {
-	var req emaildto.SendEmailRequest
-	if err := c.ShouldBind(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+func (h *EmailHandler) SendDraft(c *gin.Context) {
+	draftID := c.Param("id")
+
+	user, exists := c.Get("user")
+	if !exists {
+		respondError(c, errNotAuthenticated)
+		return
+	}
+
+	userData, ok := user.(*authdomain.User)
+	if !ok {
+		respondError(c, errInvalidUserData)
+		return
+	}
+
+	if err := h.emailUsecase.SendDraft(userData.ID, draftID); err != nil {
+		respondError(c, err)
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{"message": "draft sent"})
+}
+
+func (h *EmailHandler) ArchiveEmail(c *gin.Context) {
+	id := c.Param("id")
+
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		respondError(c, errNotAuthenticated)
 		return
 	}
 
 	userData, ok := user.(*authdomain.User)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user data"})
+		respondError(c, errInvalidUserData)
 		return
 	}
 
 	userID := userData.ID
 
-	if err := h.emailUsecase.SendEmail(userID, req.To, req.Cc, req.Bcc, req.Subject, req.Body, req.Files); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.emailUsecase.ArchiveEmail(userID, id); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "email sent successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "email archived"})
 }
 
-func (h *EmailHandler) TrashEmail(c *gin.Context) {
+// POST /emails/:id/spam
+func (h *EmailHandler) MarkAsSpam(c *gin.Context) {
 	id := c.Param("id")
 
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		respondError(c, errNotAuthenticated)
 		return
 	}
 
 	userData, ok := user.(*authdomain.User)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user data"})
+		respondError(c, errInvalidUserData)
 		return
 	}
 
 	userID := userData.ID
 
-	if err := h.emailUsecase.TrashEmail(userID, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.emailUsecase.MarkAsSpam(userID, id); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "email moved to trash"})
+	c.JSON(http.StatusOK, gin.H{"message": "email marked as spam"})
 }
 
-func (h *EmailHandler) ArchiveEmail(c *gin.Context) {
+// POST /emails/:id/not-spam
+func (h *EmailHandler) MarkNotSpam(c *gin.Context) {
 	id := c.Param("id")
 
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		respondError(c, errNotAuthenticated)
 		return
 	}
 
 	userData, ok := user.(*authdomain.User)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user data"})
+		respondError(c, errInvalidUserData)
 		return
 	}
 
 	userID := userData.ID
 
-	if err := h.emailUsecase.ArchiveEmail(userID, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.emailUsecase.MarkNotSpam(userID, id); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "email archived"})
+	c.JSON(http.StatusOK, gin.H{"message": "email moved to inbox"})
 }
 
 func (h *EmailHandler) WatchMailbox(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		respondError(c, errNotAuthenticated)
 		return
 	}
 
 	userData, ok := user.(*authdomain.User)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user data"})
+		respondError(c, errInvalidUserData)
 		return
 	}
 
@@ -405,7 +1899,7 @@ func (h *EmailHandler) WatchMailbox(c *gin.Context) {
 	err := h.emailUsecase.WatchMailbox(userID)
 	if err != nil {
 		log.Printf("Failed to watch mailbox for user %s: %v", userID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -419,26 +1913,41 @@ func (h *EmailHandler) GetAttachment(c *gin.Context) {
 
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		respondError(c, errNotAuthenticated)
 		return
 	}
 
 	userData, ok := user.(*authdomain.User)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user data"})
+		respondError(c, errInvalidUserData)
 		return
 	}
 
 	userID := userData.ID
 
 	attachment, data, err := h.emailUsecase.GetAttachment(userID, messageID, attachmentID)
+	if errors.Is(err, emaildomain.ErrAttachmentNotFound) || (err == nil && attachment == nil) {
+		respondError(c, emaildomain.ErrAttachmentNotFound)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.Header("Content-Disposition", "attachment; filename="+attachment.Name)
-	c.Data(http.StatusOK, attachment.MimeType, data)
+	// Quoted, and RFC 2231 encoded for non-ASCII names, so spaces, quotes or
+	// Vietnamese characters in the filename survive the download
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	mimeType := attachment.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	c.Header("Content-Disposition", disposition)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, mimeType, data)
 }
 
 // GET /emails/status/:status
@@ -447,12 +1956,12 @@ func (h *EmailHandler) GetEmailsByStatus(c *gin.Context) {
 
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		respondError(c, errNotAuthenticated)
 		return
 	}
 	userData, ok := user.(*authdomain.User)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user data"})
+		respondError(c, errInvalidUserData)
 		return
 	}
 	userID := userData.ID
@@ -472,12 +1981,12 @@ func (h *EmailHandler) GetEmailsByStatus(c *gin.Context) {
 
 	emails, total, err := h.emailUsecase.GetEmailsByStatus(userID, status, limit, offset)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, emaildto.EmailsResponse{
-		Emails: emails,
+		Emails: h.withRemoteImagesAll(emails, c.Query("images") == "load"),
 		Limit:  limit,
 		Offset: offset,
 		Total:  total,
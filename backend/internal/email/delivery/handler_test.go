@@ -0,0 +1,178 @@
+package delivery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	authrepo "ga03-backend/internal/auth/repository"
+	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/internal/email/repository"
+	"ga03-backend/internal/email/usecase"
+	"ga03-backend/pkg/apierror"
+	"ga03-backend/pkg/config"
+)
+
+func TestGetEmailByIDMetadataOnly(t *testing.T) {
+	uc := &fakeUsecase{}
+	w := serve(t, newTestHandler(uc).GetEmailByID, http.MethodGet, "/emails/:id", "/emails/m1?metadata=true", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", w.Code, w.Body)
+	}
+
+	var email map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &email); err != nil {
+		t.Fatal(err)
+	}
+	if email["subject"] != "Lunch" || email["body"] != "" {
+		t.Errorf("response = %v, want the metadata without a body", email)
+	}
+	if len(uc.calls) != 1 || uc.calls[0] != "GetEmailMetadata" {
+		t.Errorf("usecase calls = %v, want only GetEmailMetadata; a peek shouldn't fetch or mark the email read", uc.calls)
+	}
+}
+
+func TestStreamEmailsByMailbox(t *testing.T) {
+	uc := &fakeUsecase{streamed: []*emaildomain.Email{{ID: "m1"}, {ID: "m2"}, {ID: "m3"}}}
+	h := newTestHandler(uc)
+	events := listen(t, h, "u1")
+
+	w := serve(t, h.GetEmailsByMailbox, http.MethodGet, "/mailboxes/:id/emails", "/mailboxes/INBOX/emails?stream=true", "")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202 (%s)", w.Code, w.Body)
+	}
+	var accepted struct {
+		StreamID string `json:"stream_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &accepted)
+
+	for _, want := range uc.streamed {
+		event := next(t, events)
+		email, _ := event.Payload["email"].(map[string]any)
+		if event.Type != "email_loaded" || event.Payload["stream_id"] != accepted.StreamID || email["id"] != want.ID {
+			t.Fatalf("event = %+v, want email_loaded for %s", event, want.ID)
+		}
+	}
+	complete := next(t, events)
+	if complete.Type != "list_complete" || complete.Payload["count"] != float64(3) || complete.Payload["error"] != nil {
+		t.Errorf("event = %+v, want list_complete with count 3", complete)
+	}
+}
+
+func TestStreamEmailsByMailboxError(t *testing.T) {
+	uc := &fakeUsecase{streamErr: errors.New("googleapi: Error 500: backend 10.0.0.3 failed")}
+	h := newTestHandler(uc)
+	events := listen(t, h, "u1")
+
+	serve(t, h.GetEmailsByMailbox, http.MethodGet, "/mailboxes/:id/emails", "/mailboxes/INBOX/emails?stream=true", "")
+
+	complete := next(t, events)
+	if complete.Type != "list_complete" || complete.Payload["code"] != apierror.CodeInternal || complete.Payload["error"] != "internal server error" {
+		t.Errorf("event = %+v, want list_complete with a generic error", complete)
+	}
+}
+
+func TestBatchUpdateKanbanStatusSendsOneEvent(t *testing.T) {
+	h := newTestHandler(&fakeUsecase{})
+	events := listen(t, h, "u1")
+
+	w := serve(t, h.BatchUpdateKanbanStatus, http.MethodPost, "/emails/kanban/batch", "/emails/kanban/batch", `{"ids":["m1","m2","m3"],"status":"done"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", w.Code, w.Body)
+	}
+
+	event := next(t, events)
+	ids, _ := event.Payload["ids"].([]any)
+	if event.Type != "kanban_updated" || event.Payload["status"] != "done" || len(ids) != 3 {
+		t.Errorf("event = %+v, want one kanban_updated listing all three ids", event)
+	}
+	select {
+	case extra := <-events:
+		t.Errorf("got a second event %+v; the batch should send one", extra)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestReplyEmailArchiveEvent(t *testing.T) {
+	for _, archiveOnReply := range []bool{true, false} {
+		t.Run(fmt.Sprint("archive on reply ", archiveOnReply), func(t *testing.T) {
+			h := newTestHandler(&fakeUsecase{archiveOnReply: archiveOnReply})
+			events := listen(t, h, "u1")
+
+			w := serve(t, h.ReplyEmail, http.MethodPost, "/emails/:id/reply", "/emails/m1/reply", `{"body":"Sounds good"}`)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200 (%s)", w.Code, w.Body)
+			}
+			var resp struct {
+				Archived bool `json:"archived"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.Archived != archiveOnReply {
+				t.Errorf("archived = %v, want %v", resp.Archived, archiveOnReply)
+			}
+
+			select {
+			case event := <-events:
+				if !archiveOnReply || event.Type != "email_archived" || event.Payload["id"] != "m1" {
+					t.Errorf("event = %+v, want email_archived for m1 only when archiving", event)
+				}
+			case <-time.After(200 * time.Millisecond):
+				if archiveOnReply {
+					t.Error("no email_archived event after the reply archived the original")
+				}
+			}
+		})
+	}
+}
+
+func TestGetAttachmentNotFound(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		// Accounts without Gmail get no attachment and no error back
+		{"no attachment", nil},
+		{"unknown attachment", emaildomain.ErrAttachmentNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(&fakeUsecase{attachmentErr: tt.err})
+			w := serve(t, h.GetAttachment, http.MethodGet, "/emails/:id/attachments/:attachmentId", "/emails/m1/attachments/a1", "")
+
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusNotFound)
+			}
+			if msg, _ := decodeError(t, w.Body.Bytes()); msg != "attachment not found" {
+				t.Errorf("error = %q, want attachment not found", msg)
+			}
+			if w.Header().Get("Content-Disposition") != "" {
+				t.Error("a missing attachment was served as a download")
+			}
+		})
+	}
+}
+
+// localUsers finds every user as a local account, one without a mail provider
+type localUsers struct {
+	authrepo.UserRepository
+}
+
+func (localUsers) FindByID(id string) (*authdomain.User, error) {
+	return &authdomain.User{ID: id, Email: id + "@example.com", Provider: "email"}, nil
+}
+
+func TestGetThreadUnknownLocalEmail(t *testing.T) {
+	uc := usecase.NewEmailUsecase(repository.NewEmailRepository(), nil, nil, nil, nil, nil, localUsers{}, nil, nil, &config.Config{}, "")
+	w := serve(t, newTestHandler(uc).GetThread, http.MethodGet, "/emails/threads/:id", "/emails/threads/missing", "")
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404 (%s)", w.Code, w.Body)
+	}
+	if msg, code := decodeError(t, w.Body.Bytes()); msg != emaildomain.ErrEmailNotFound.Error() || code != apierror.CodeNotFound {
+		t.Errorf("body = (%q, %q), want the not-found error", msg, code)
+	}
+}
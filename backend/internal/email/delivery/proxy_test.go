@@ -0,0 +1,113 @@
+package delivery
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/pkg/imageproxy"
+	"ga03-backend/pkg/sse"
+)
+
+func TestProxyImageRequiresEmailSignature(t *testing.T) {
+	proxy := imageproxy.NewProxy("key", "http://api.test", 1024, 1024, time.Hour)
+	h := NewEmailHandler(&fakeUsecase{}, sse.NewManager(1, sse.OverflowDropOldest, 0, 0, 0), proxy)
+	const route = "/api/emails/:id/proxy-image"
+	src := "http://127.0.0.1/pixel.gif"
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantMsg    string
+	}{
+		{"no src", "/api/emails/e1/proxy-image", http.StatusBadRequest, "src is required"},
+		{"no signature", "/api/emails/e1/proxy-image?src=" + url.QueryEscape(src), http.StatusForbidden, imageproxy.ErrInvalidSignature.Error()},
+		{"another email's signature", "/api/emails/e2/proxy-image?src=" + url.QueryEscape(src) + "&sig=" + proxy.Sign("e1", src), http.StatusForbidden, imageproxy.ErrInvalidSignature.Error()},
+		// Signed, but the proxy still refuses to reach a private host
+		{"private host", "/api/emails/e1/proxy-image?src=" + url.QueryEscape(src) + "&sig=" + proxy.Sign("e1", src), http.StatusForbidden, imageproxy.ErrBlockedURL.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, h.ProxyImage, http.MethodGet, route, tt.path, "")
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body)
+			}
+			if msg, _ := decodeError(t, w.Body.Bytes()); msg != tt.wantMsg {
+				t.Errorf("error = %q, want %q", msg, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestRemoteImagesBlockedUntilLoaded(t *testing.T) {
+	proxy := imageproxy.NewProxy("key", "http://api.test", 1024, 1024, time.Hour)
+	h := NewEmailHandler(&fakeUsecase{}, sse.NewManager(1, sse.OverflowDropOldest, 0, 0, 0), proxy)
+	src := "https://tracker.test/open.gif"
+	email := &emaildomain.Email{ID: "e1", IsHTML: true, Body: `<p>Hi</p><img src="` + src + `"><img src="cid:logo">`}
+
+	blocked := h.withRemoteImages(email, false)
+	if !blocked.ImagesBlocked || strings.Contains(blocked.Body, "tracker.test") {
+		t.Errorf("by default got ImagesBlocked = %v, body %q, want the remote image blocked", blocked.ImagesBlocked, blocked.Body)
+	}
+	if !strings.Contains(blocked.Body, `src="cid:logo"`) {
+		t.Errorf("the inline image was blocked: %q", blocked.Body)
+	}
+
+	loaded := h.withRemoteImages(email, true)
+	if loaded.ImagesBlocked || !strings.Contains(loaded.Body, html.EscapeString(proxy.ProxyURL("e1", src))) {
+		t.Errorf("with load got ImagesBlocked = %v, body %q, want the image routed through the proxy", loaded.ImagesBlocked, loaded.Body)
+	}
+	if email.Body != `<p>Hi</p><img src="`+src+`"><img src="cid:logo">` {
+		t.Error("the original email was modified")
+	}
+
+	plain := &emaildomain.Email{ID: "e2", Body: "See " + src}
+	if got := h.withRemoteImages(plain, false); got.Body != plain.Body || got.ImagesBlocked {
+		t.Errorf("a plain text email was changed: %+v", got)
+	}
+}
+
+func TestThreadRemoteImagesBlockedUntilLoaded(t *testing.T) {
+	proxy := imageproxy.NewProxy("key", "http://api.test", 1024, 1024, time.Hour)
+	src := "https://tracker.test/open.gif"
+	uc := &fakeUsecase{thread: []*emaildomain.Email{
+		{ID: "e1", IsHTML: true, Body: `<p>Hi</p><img src="` + src + `">`},
+		{ID: "e2", IsHTML: true, Body: `<p>Re: Hi</p><img src="` + src + `?reply">`},
+	}}
+	h := NewEmailHandler(uc, sse.NewManager(1, sse.OverflowDropOldest, 0, 0, 0), proxy)
+
+	get := func(path string) []*emaildomain.Email {
+		t.Helper()
+		w := serve(t, h.GetThread, http.MethodGet, "/emails/threads/:id", path, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200 (%s)", w.Code, w.Body)
+		}
+		var resp struct {
+			Emails []*emaildomain.Email `json:"emails"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.Emails
+	}
+
+	for _, email := range get("/emails/threads/t1") {
+		if !email.ImagesBlocked || strings.Contains(email.Body, "tracker.test") {
+			t.Errorf("%s: by default got ImagesBlocked = %v, body %q, want the remote image blocked", email.ID, email.ImagesBlocked, email.Body)
+		}
+	}
+	for _, email := range get("/emails/threads/t1?images=load") {
+		if email.ImagesBlocked || !strings.Contains(email.Body, "http://api.test/api/emails/"+email.ID+"/proxy-image") {
+			t.Errorf("%s: with load got ImagesBlocked = %v, body %q, want the image routed through the proxy", email.ID, email.ImagesBlocked, email.Body)
+		}
+	}
+	if strings.Contains(uc.thread[0].Body, "proxy-image") {
+		t.Error("the usecase's emails were modified")
+	}
+}
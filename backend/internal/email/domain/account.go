@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+// AccountStatus is a consolidated health report for a user's connected mail account
+type AccountStatus struct {
+	Provider        string     `json:"provider"`
+	Email           string     `json:"email"`
+	Connected       bool       `json:"connected"`
+	NeedsReauth     bool       `json:"needs_reauth"`
+	Scopes          []string   `json:"scopes,omitempty"`
+	MissingScopes   []string   `json:"missing_scopes,omitempty"`
+	WatchActive     bool       `json:"watch_active"`
+	WatchExpiration *time.Time `json:"watch_expiration,omitempty"`
+	Error           string     `json:"error,omitempty"`
+	ErrorCode       string     `json:"error_code,omitempty"` // One of the apierror codes
+	CheckedAt       time.Time  `json:"checked_at"`
+	// Cause is the failure behind Error. Provider errors can carry hostnames, server
+	// banners and token details, so the handler turns it into Error and ErrorCode.
+	Cause error `json:"-"`
+}
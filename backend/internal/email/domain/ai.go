@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// EmailCategory is Gemini's classification of an email
+type EmailCategory struct {
+	EmailID  string `json:"email_id"`
+	Category string `json:"category"`         // work, personal, promotion, finance, urgent or uncategorized
+	Priority int    `json:"priority"`         // 1 (low) to 5 (urgent), 0 when uncategorized
+	Status   string `json:"status,omitempty"` // Kanban status it was moved to, if applied
+}
+
+// EmailSummary is a cached Gemini summary. Provider message IDs don't change, so a
+// summary stays valid for the email's lifetime.
+type EmailSummary struct {
+	UserID    string    `json:"user_id" gorm:"primaryKey"`
+	EmailID   string    `json:"email_id" gorm:"primaryKey"`
+	Summary   string    `json:"summary"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ErrInvalidLanguage means a translation target isn't a language code like "vi" or "pt-BR"
+var ErrInvalidLanguage = errors.New("invalid language code")
+
+// EmailTranslation is an email body translated by Gemini
+type EmailTranslation struct {
+	EmailID        string `json:"email_id"`
+	SourceLanguage string `json:"source_language"` // Detected language code of the original
+	TargetLanguage string `json:"target_language"`
+	Translation    string `json:"translation"`
+}
@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// Contact is an address the user has exchanged mail with, kept for recipient autocomplete
+type Contact struct {
+	UserID    string    `json:"-" gorm:"primaryKey"`
+	Email     string    `json:"email" gorm:"primaryKey"` // Lowercased
+	Name      string    `json:"name"`
+	LastSeen  time.Time `json:"last_seen"`
+	Frequency int       `json:"frequency"` // Messages seen to or from the address
+}
@@ -1,16 +1,32 @@
 package domain
 
-import "time"
+import (
+	"errors"
+	"time"
+
+	"ga03-backend/pkg/utils/ical"
+)
 
 type Mailbox struct {
 	ID    string `json:"id"`
 	Name  string `json:"name"`
 	Type  string `json:"type"`  // "inbox", "sent", "drafts", etc.
 	Count int    `json:"count"` // unread count for inbox
+
+	LastSyncedAt *time.Time `json:"last_synced_at,omitempty"`
+
+	// Folder hierarchy
+	Delimiter string     `json:"delimiter,omitempty"` // Hierarchy separator in Name, e.g. "/" or "."
+	ParentID  string     `json:"parent_id,omitempty"`
+	Depth     int        `json:"depth"`
+	Children  []*Mailbox `json:"children,omitempty"` // Only populated in tree mode
 }
 
 type Email struct {
 	ID          string       `json:"id"`
+	StableID    string       `json:"stable_id,omitempty"` // Survives moves between mailboxes, see pkg/utils/emailid
+	ThreadID    string       `json:"thread_id,omitempty"`
+	DraftID     string       `json:"draft_id,omitempty"` // Set on drafts, the ID to update or send them with
 	MailboxID   string       `json:"mailbox_id"`
 	Status      string       `json:"status"` // inbox, todo, done, snoozed
 	From        string       `json:"from"`
@@ -24,10 +40,73 @@ type Email struct {
 	IsRead      bool         `json:"is_read"`
 	IsStarred   bool         `json:"is_starred"`
 	IsImportant bool         `json:"is_important"`
+	Priority    string       `json:"priority,omitempty"` // high, normal or low, from the sender's headers
 	Attachments []Attachment `json:"attachments,omitempty"`
 	ReceivedAt  time.Time    `json:"received_at"`
 	CreatedAt   time.Time    `json:"created_at"`
 	SnoozedUntil *time.Time  `json:"snoozed_until,omitempty"`
+
+	// List-Unsubscribe info (RFC 2369 / RFC 8058)
+	UnsubscribeURL      string `json:"unsubscribe_url,omitempty"`
+	UnsubscribeMailto   string `json:"unsubscribe_mailto,omitempty"`
+	UnsubscribeOneClick bool   `json:"unsubscribe_one_click,omitempty"`
+
+	// Threading headers, used to keep replies in the same conversation
+	MessageID  string `json:"message_id,omitempty"`
+	References string `json:"-"`
+
+	// Which account the email came from in the unified inbox: "primary" or a linked account's ID
+	AccountID string `json:"account_id,omitempty"`
+
+	// Set when remote images were removed from an HTML body until the user loads them
+	ImagesBlocked bool `json:"images_blocked,omitempty"`
+
+	// Calendar invite (text/calendar part or .ics attachment)
+	IsInvite           bool        `json:"is_invite,omitempty"`
+	Invite             *ical.Event `json:"invite,omitempty"`
+	InviteAttachmentID string      `json:"-"` // Set when the event must be fetched as an attachment
+}
+
+// ErrNotInTrash guards permanent deletion: an email has to be trashed (or be spam) first
+var ErrNotInTrash = errors.New("only emails in trash or spam can be deleted permanently")
+
+// ErrEmailNotFound is returned when a message ID doesn't name a message in the mailbox
+var ErrEmailNotFound = errors.New("email not found")
+
+// ErrDraftNotFound is returned when a draft ID doesn't name a saved draft
+var ErrDraftNotFound = errors.New("draft not found")
+
+// ErrAttachmentNotFound is returned when an attachment ID doesn't name a part of the message
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+// ReplyHeaders link an outgoing message to the one it answers
+type ReplyHeaders struct {
+	InReplyTo  string // Message-ID of the original
+	References string // The original's References followed by its Message-ID
+	ThreadID   string // Provider thread to add the message to, if the provider has one
+}
+
+// ComposedEmail is an outgoing message as it is handed to the provider, after the
+// display name, signature and quoting have been applied
+type ComposedEmail struct {
+	FromName string `json:"from_name"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Cc       string `json:"cc,omitempty"`
+	Bcc      string `json:"bcc,omitempty"`
+	Subject  string `json:"subject"`
+	Body     string `json:"body"`
+}
+
+// BatchResult is the outcome of a batch action for one email
+type BatchResult struct {
+	ID      string `json:"id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	Code    string `json:"code,omitempty"` // One of the apierror codes
+	// Cause is why this email failed. The handler turns it into Error and Code, so
+	// provider details stay server-side.
+	Cause error `json:"-"`
 }
 
 type Attachment struct {
@@ -37,4 +116,22 @@ type Attachment struct {
 	MimeType  string `json:"mime_type"`
 	URL       string `json:"url,omitempty"`
 	ContentID string `json:"content_id,omitempty"`
+	IsInline  bool   `json:"is_inline"` // Embedded in the body (cid:) rather than attached by the sender
+}
+
+// Participant is an address seen in a conversation
+type Participant struct {
+	Email        string `json:"email"`
+	Name         string `json:"name,omitempty"`
+	IsSender     bool   `json:"is_sender"`
+	MessageCount int    `json:"message_count"`
+}
+
+// ThreadMatch is a message of a conversation that matched an in-thread search.
+// Snippet is HTML-escaped with the matched terms wrapped in <mark>.
+type ThreadMatch struct {
+	EmailID    string    `json:"email_id"`
+	From       string    `json:"from"`
+	ReceivedAt time.Time `json:"received_at"`
+	Snippet    string    `json:"snippet"`
 }
@@ -0,0 +1,30 @@
+package domain
+
+// MailFilter is a provider-neutral mail filter: messages matching Criteria get Action applied
+type MailFilter struct {
+	ID       string         `json:"id,omitempty"`
+	Criteria FilterCriteria `json:"criteria"`
+	Action   FilterAction   `json:"action"`
+}
+
+type FilterCriteria struct {
+	From          string `json:"from,omitempty"`
+	To            string `json:"to,omitempty"`
+	Subject       string `json:"subject,omitempty"`
+	Query         string `json:"query,omitempty"`         // Free-form search, provider syntax
+	NegatedQuery  string `json:"negated_query,omitempty"` // Messages matching this are excluded
+	HasAttachment bool   `json:"has_attachment,omitempty"`
+	SizeBytes     int64  `json:"size_bytes,omitempty"`
+	SizeCompare   string `json:"size_compare,omitempty"` // "larger" or "smaller"
+}
+
+type FilterAction struct {
+	AddLabels    []string `json:"add_labels,omitempty"`
+	RemoveLabels []string `json:"remove_labels,omitempty"`
+	Forward      string   `json:"forward,omitempty"`
+	MarkRead     bool     `json:"mark_read,omitempty"`
+	Archive      bool     `json:"archive,omitempty"`
+	Star         bool     `json:"star,omitempty"`
+	Important    bool     `json:"important,omitempty"`
+	Trash        bool     `json:"trash,omitempty"`
+}
@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// KanbanStatus is the Kanban column a user has put an email in
+type KanbanStatus struct {
+	UserID       string     `json:"user_id" gorm:"primaryKey"`
+	EmailID      string     `json:"email_id" gorm:"primaryKey"`
+	Status       string     `json:"status" gorm:"index"` // inbox, todo, done, snoozed
+	SnoozedUntil *time.Time `json:"snoozed_until,omitempty" gorm:"index"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
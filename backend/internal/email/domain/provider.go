@@ -3,6 +3,7 @@ package domain
 import (
 	"context"
 	"mime/multipart"
+	"time"
 
 	"golang.org/x/oauth2"
 )
@@ -10,19 +11,50 @@ import (
 // TokenUpdateFunc is a callback function that handles token updates
 type TokenUpdateFunc func(token *oauth2.Token) error
 
+// EmailFunc receives emails one at a time as a provider fetches them
+type EmailFunc func(email *Email)
+
 // MailProvider defines the interface for email service providers
 type MailProvider interface {
 	GetMailboxes(ctx context.Context, accessToken, refreshToken string, onTokenRefresh TokenUpdateFunc) ([]*Mailbox, error)
 	GetEmails(ctx context.Context, accessToken, refreshToken, mailboxID string, limit, offset int, query string, onTokenRefresh TokenUpdateFunc) ([]*Email, int, error)
+	GetEmailsPage(ctx context.Context, accessToken, refreshToken, mailboxID string, limit int, pageToken, query string, onTokenRefresh TokenUpdateFunc) ([]*Email, int, string, error)
+	StreamEmails(ctx context.Context, accessToken, refreshToken, mailboxID string, limit, offset int, query string, onEmail EmailFunc, onTokenRefresh TokenUpdateFunc) (int, error)
+	CountEmails(ctx context.Context, accessToken, refreshToken, query string, onTokenRefresh TokenUpdateFunc) (int, error)
 	GetEmailByID(ctx context.Context, accessToken, refreshToken, messageID string, onTokenRefresh TokenUpdateFunc) (*Email, error)
+	GetEmailMetadata(ctx context.Context, accessToken, refreshToken, messageID string, onTokenRefresh TokenUpdateFunc) (*Email, error)
+	GetThread(ctx context.Context, accessToken, refreshToken, threadID string, onTokenRefresh TokenUpdateFunc) ([]*Email, error)
+	GetChanges(ctx context.Context, accessToken, refreshToken, watermark string, since time.Time, onTokenRefresh TokenUpdateFunc) (*Changes, error)
+	GetNewMessages(ctx context.Context, accessToken, refreshToken string, startHistoryID uint64, onTokenRefresh TokenUpdateFunc) ([]*Email, uint64, error)
+	MarkThreadAsRead(ctx context.Context, accessToken, refreshToken, threadID string, onTokenRefresh TokenUpdateFunc) error
+	MarkThreadAsUnread(ctx context.Context, accessToken, refreshToken, threadID string, onTokenRefresh TokenUpdateFunc) error
+	ToggleThreadStar(ctx context.Context, accessToken, refreshToken, threadID string, onTokenRefresh TokenUpdateFunc) error
+	TrashThread(ctx context.Context, accessToken, refreshToken, threadID string, onTokenRefresh TokenUpdateFunc) error
 	GetAttachment(ctx context.Context, accessToken, refreshToken, messageID, attachmentID string, onTokenRefresh TokenUpdateFunc) (*Attachment, []byte, error)
-	SendEmail(ctx context.Context, accessToken, refreshToken, fromName, fromEmail, to, cc, bcc, subject, body string, files []*multipart.FileHeader, onTokenRefresh TokenUpdateFunc) error
+	SendEmail(ctx context.Context, accessToken, refreshToken, fromName, fromEmail, to, cc, bcc, subject, body string, files []*multipart.FileHeader, reply *ReplyHeaders, onTokenRefresh TokenUpdateFunc) error
+	SendRawEmail(ctx context.Context, accessToken, refreshToken string, raw []byte, onTokenRefresh TokenUpdateFunc) error
 	TrashEmail(ctx context.Context, accessToken, refreshToken, emailID string, onTokenRefresh TokenUpdateFunc) error
 	ArchiveEmail(ctx context.Context, accessToken, refreshToken, emailID string, onTokenRefresh TokenUpdateFunc) error
+	MoveEmail(ctx context.Context, accessToken, refreshToken, emailID, targetLabelID string, onTokenRefresh TokenUpdateFunc) error
+	MarkAsSpam(ctx context.Context, accessToken, refreshToken, emailID string, onTokenRefresh TokenUpdateFunc) error
+	MarkNotSpam(ctx context.Context, accessToken, refreshToken, emailID string, onTokenRefresh TokenUpdateFunc) error
+	DeleteEmail(ctx context.Context, accessToken, refreshToken, emailID string, onTokenRefresh TokenUpdateFunc) error
+	EmptyTrash(ctx context.Context, accessToken, refreshToken string, onTokenRefresh TokenUpdateFunc) (int, error)
+	CreateDraft(ctx context.Context, accessToken, refreshToken string, msg *ComposedEmail, onTokenRefresh TokenUpdateFunc) (string, error)
+	UpdateDraft(ctx context.Context, accessToken, refreshToken, draftID string, msg *ComposedEmail, onTokenRefresh TokenUpdateFunc) error
+	DeleteDraft(ctx context.Context, accessToken, refreshToken, draftID string, onTokenRefresh TokenUpdateFunc) error
+	SendDraft(ctx context.Context, accessToken, refreshToken, draftID string, onTokenRefresh TokenUpdateFunc) error
+	ListDraftIDs(ctx context.Context, accessToken, refreshToken string, onTokenRefresh TokenUpdateFunc) (map[string]string, error)
 	MarkAsRead(ctx context.Context, accessToken, refreshToken, messageID string, onTokenRefresh TokenUpdateFunc) error
 	MarkAsUnread(ctx context.Context, accessToken, refreshToken, messageID string, onTokenRefresh TokenUpdateFunc) error
 	ToggleStar(ctx context.Context, accessToken, refreshToken, messageID string, onTokenRefresh TokenUpdateFunc) error
-	Watch(ctx context.Context, accessToken, refreshToken string, topicName string, onTokenRefresh TokenUpdateFunc) error
+	ToggleImportant(ctx context.Context, accessToken, refreshToken, messageID string, onTokenRefresh TokenUpdateFunc) error
+	BatchModify(ctx context.Context, accessToken, refreshToken string, messageIDs, addLabels, removeLabels []string, onTokenRefresh TokenUpdateFunc) error
+	ListFilters(ctx context.Context, accessToken, refreshToken string, onTokenRefresh TokenUpdateFunc) ([]*MailFilter, error)
+	CreateFilter(ctx context.Context, accessToken, refreshToken string, filter *MailFilter, onTokenRefresh TokenUpdateFunc) (*MailFilter, error)
+	DeleteFilter(ctx context.Context, accessToken, refreshToken, filterID string, onTokenRefresh TokenUpdateFunc) error
+	Watch(ctx context.Context, accessToken, refreshToken string, topicName string, onTokenRefresh TokenUpdateFunc) (expiration time.Time, historyID uint64, err error)
 	Stop(ctx context.Context, accessToken, refreshToken string, onTokenRefresh TokenUpdateFunc) error
 	ValidateToken(ctx context.Context, accessToken, refreshToken string, onTokenRefresh TokenUpdateFunc) error
+	GetTokenScopes(ctx context.Context, accessToken string) ([]string, error)
 }
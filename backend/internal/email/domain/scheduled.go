@@ -0,0 +1,44 @@
+package domain
+
+import "time"
+
+// Scheduled email states
+const (
+	ScheduledPending   = "pending"
+	ScheduledSending   = "sending"
+	ScheduledSent      = "sent"
+	ScheduledFailed    = "failed" // Gave up after the last retry
+	ScheduledCancelled = "cancelled"
+)
+
+// ScheduledEmail is a composed message waiting to be sent at SendAt
+type ScheduledEmail struct {
+	ID            string                `json:"id" gorm:"primaryKey"`
+	UserID        string                `json:"-" gorm:"index"`
+	FromName      string                `json:"from_name"`
+	To            string                `json:"to"`
+	Cc            string                `json:"cc,omitempty"`
+	Bcc           string                `json:"bcc,omitempty"`
+	Subject       string                `json:"subject"`
+	Body          string                `json:"body"`
+	Attachments   []ScheduledAttachment `json:"attachments,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	SendAt        time.Time             `json:"send_at"`
+	Status        string                `json:"status" gorm:"index"`
+	Attempts      int                   `json:"attempts"`
+	NextAttemptAt time.Time             `json:"next_attempt_at" gorm:"index"` // SendAt, then pushed back after each failure
+	LastError     string                `json:"last_error,omitempty"`
+	UndoSend      bool                  `json:"undo_send"` // Queued by a send with an undo window rather than for a chosen time
+	SentAt        *time.Time            `json:"sent_at,omitempty"`
+	CreatedAt     time.Time             `json:"created_at"`
+	UpdatedAt     time.Time             `json:"updated_at"`
+}
+
+// ScheduledAttachment is a file stored with a scheduled email until it is sent
+type ScheduledAttachment struct {
+	ID               uint   `json:"-" gorm:"primaryKey"`
+	ScheduledEmailID string `json:"-" gorm:"index"`
+	Filename         string `json:"filename"`
+	ContentType      string `json:"content_type"`
+	Size             int64  `json:"size"`
+	Data             []byte `json:"-"`
+}
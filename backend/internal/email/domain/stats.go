@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// Stats is the aggregated dashboard view of a user's mailbox
+type Stats struct {
+	Days           int            `json:"days"`
+	Mailboxes      []*Mailbox     `json:"mailboxes"`
+	UnreadTotal    int            `json:"unread_total"`
+	ReceivedPerDay []DayCount     `json:"received_per_day"`
+	TopSenders     []SenderCount  `json:"top_senders"`
+	KanbanColumns  map[string]int `json:"kanban_columns"`
+	GeneratedAt    time.Time      `json:"generated_at"`
+}
+
+type DayCount struct {
+	Date  string `json:"date"` // YYYY-MM-DD
+	Count int    `json:"count"`
+}
+
+type SenderCount struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
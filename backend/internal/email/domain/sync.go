@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// MailboxSyncState tracks when a user's mailbox was last synced and how far
+type MailboxSyncState struct {
+	UserID       string    `json:"user_id" gorm:"primaryKey"`
+	MailboxID    string    `json:"mailbox_id" gorm:"primaryKey"`
+	LastSyncedAt time.Time `json:"last_synced_at"`
+	Watermark    string    `json:"watermark"` // ID of the newest email seen at last sync
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// ErrWatermarkExpired means the provider no longer has history back to the
+// watermark, so the client has to resync from scratch
+var ErrWatermarkExpired = errors.New("watermark expired, a full resync is needed")
+
+// ErrInvalidWatermark means the watermark wasn't produced by this account's provider
+var ErrInvalidWatermark = errors.New("invalid watermark")
+
+// Changes lists email IDs that changed since a watermark or timestamp. Watermark
+// is opaque and should be passed back on the next call. When Complete is false
+// only some kinds of change could be determined (e.g. new arrivals but not flag
+// changes or deletions) and the client should reconcile with a normal listing.
+type Changes struct {
+	Added     []string `json:"added"`
+	Modified  []string `json:"modified"`
+	Deleted   []string `json:"deleted"`
+	Watermark string   `json:"watermark"`
+	Complete  bool     `json:"complete"`
+}
@@ -0,0 +1,11 @@
+package domain
+
+// UnifiedInbox is a page of the inboxes of a user's own and linked accounts merged
+// newest first. Each email's AccountID says where it came from.
+type UnifiedInbox struct {
+	Emails         []*Email `json:"emails"`
+	Limit          int      `json:"limit"`
+	Offset         int      `json:"offset"`
+	Total          int      `json:"total"`                     // Sum of the inbox totals of the accounts that were read
+	FailedAccounts []string `json:"failed_accounts,omitempty"` // Accounts that couldn't be read, so their mail is missing
+}
@@ -3,6 +3,7 @@ package dto
 import (
 	emaildomain "ga03-backend/internal/email/domain"
 	"mime/multipart"
+	"time"
 )
 
 type MailboxesResponse struct {
@@ -10,18 +11,108 @@ type MailboxesResponse struct {
 }
 
 type EmailsResponse struct {
-	Emails []*emaildomain.Email `json:"emails"`
-	Limit  int                  `json:"limit"`
-	Offset int                  `json:"offset"`
-	Total  int                  `json:"total"`
+	Emails        []*emaildomain.Email `json:"emails"`
+	Limit         int                  `json:"limit"`
+	Offset        int                  `json:"offset"`
+	Total         int                  `json:"total"`
+	NextPageToken string               `json:"next_page_token,omitempty"` // Pass as pageToken to get the next page
 }
 
 type SendEmailRequest struct {
-	To      string                  `form:"to" binding:"required,email"`
-	Cc      string                  `form:"cc"`
-	Bcc     string                  `form:"bcc"`
-	Subject string                  `form:"subject"`
-	Body    string                  `form:"body"`
-	Files   []*multipart.FileHeader `form:"files"`
+	FromName string                  `form:"from_name"`
+	To       string                  `form:"to" binding:"required,email"`
+	Cc       string                  `form:"cc"`
+	Bcc      string                  `form:"bcc"`
+	Subject  string                  `form:"subject"`
+	Body     string                  `form:"body"`
+	Files    []*multipart.FileHeader `form:"files"`
+	Confirm  bool                    `form:"confirm"` // Send despite warnings
+	Undo     bool                    `form:"undo"`    // Hold the message for the undo window before sending
+	Sign     bool                    `form:"sign"`    // Append the user's saved signature to the body
 }
 
+type ScheduleEmailRequest struct {
+	SendEmailRequest
+	SendAt time.Time `form:"send_at" binding:"required"` // RFC 3339
+}
+
+type ReplyEmailRequest struct {
+	FromName  string                  `form:"from_name"`
+	Body      string                  `form:"body"`
+	PlainText bool                    `form:"plain_text"`
+	ReplyAll  bool                    `form:"reply_all"` // Also copy the original's other recipients
+	Files     []*multipart.FileHeader `form:"files"`
+	Confirm   bool                    `form:"confirm"` // Send despite warnings
+}
+
+type ResendEmailRequest struct {
+	To      string `json:"to"`
+	Cc      string `json:"cc"`
+	Bcc     string `json:"bcc"`
+	Confirm bool   `json:"confirm"` // Required to resend to a large recipient list
+}
+
+type ReplySettingsRequest struct {
+	TopPost         *bool `json:"top_post"`
+	IncludeOriginal *bool `json:"include_original"`
+	ArchiveOnReply  *bool `json:"archive_on_reply"`
+
+	Signature                  *string `json:"signature"`
+	SignatureFirstInThreadOnly *bool   `json:"signature_first_in_thread_only"`
+	StripQuotedSignatures      *bool   `json:"strip_quoted_signatures"`
+}
+
+type ReplyPreviewRequest struct {
+	Body      string `json:"body"`
+	PlainText bool   `json:"plain_text"`
+}
+
+// EmailPreviewRequest takes the compose fields; with reply_to_id set it previews a
+// reply to that email and To, Cc, Bcc and Subject are derived from it instead
+type EmailPreviewRequest struct {
+	FromName  string `json:"from_name"`
+	To        string `json:"to"`
+	Cc        string `json:"cc"`
+	Bcc       string `json:"bcc"`
+	Subject   string `json:"subject"`
+	Body      string `json:"body"`
+	ReplyToID string `json:"reply_to_id"`
+	PlainText bool   `json:"plain_text"`
+	ReplyAll  bool   `json:"reply_all"`
+	Sign      bool   `json:"sign"` // Append the saved signature to a new message; replies are signed by the reply settings
+}
+
+// DraftRequest is the content of a draft. Autosave can always POST it: with draft_id
+// set it replaces that draft instead of creating another.
+type DraftRequest struct {
+	DraftID  string `json:"draft_id"`
+	FromName string `json:"from_name"`
+	To       string `json:"to"`
+	Cc       string `json:"cc"`
+	Bcc      string `json:"bcc"`
+	Subject  string `json:"subject"`
+	Body     string `json:"body"`
+}
+
+type ExportPDFRequest struct {
+	IDs []string `json:"ids" binding:"required"`
+}
+
+type BatchModifyRequest struct {
+	Action  string   `json:"action" binding:"required"` // read, unread, star, unstar, trash, archive or move
+	IDs     []string `json:"ids" binding:"required"`
+	Mailbox string   `json:"mailbox"` // Target mailbox for move
+}
+
+type KanbanBatchRequest struct {
+	IDs    []string `json:"ids" binding:"required"`
+	Status string   `json:"status" binding:"required"`
+}
+
+type InviteResponseRequest struct {
+	Response string `json:"response" binding:"required,oneof=accept decline tentative"`
+}
+
+type PrefetchRequest struct {
+	IDs []string `json:"ids" binding:"required"`
+}
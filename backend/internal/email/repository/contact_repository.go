@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"strings"
+
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// contactRepository implements ContactRepository interface
+type contactRepository struct {
+	db *gorm.DB
+}
+
+// NewContactRepository creates a new instance of contactRepository
+func NewContactRepository(db *gorm.DB) ContactRepository {
+	return &contactRepository{
+		db: db,
+	}
+}
+
+func (r *contactRepository) GetContacts(userID string, emails []string) ([]*emaildomain.Contact, error) {
+	var contacts []*emaildomain.Contact
+	if len(emails) == 0 {
+		return contacts, nil
+	}
+	err := r.db.Where("user_id = ? AND email IN ?", userID, emails).Find(&contacts).Error
+	return contacts, err
+}
+
+// SaveContacts inserts contacts, replacing existing ones for the same user and address
+func (r *contactRepository) SaveContacts(contacts []*emaildomain.Contact) error {
+	if len(contacts) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&contacts).Error
+}
+
+// Search finds contacts whose address or name contains query, most used first
+func (r *contactRepository) Search(userID, query string, limit int) ([]*emaildomain.Contact, error) {
+	var contacts []*emaildomain.Contact
+	tx := r.db.Where("user_id = ?", userID)
+	if query = strings.TrimSpace(query); query != "" {
+		pattern := "%" + escapeLike(strings.ToLower(query)) + "%"
+		tx = tx.Where("email LIKE ? OR LOWER(name) LIKE ?", pattern, pattern)
+	}
+	err := tx.Order("frequency DESC, last_seen DESC").Limit(limit).Find(&contacts).Error
+	return contacts, err
+}
+
+// escapeLike makes s match literally inside a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
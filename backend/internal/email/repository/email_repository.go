@@ -6,6 +6,7 @@ import (
 	"time"
 
 	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/pkg/utils/emailid"
 
 	"github.com/google/uuid"
 )
@@ -17,6 +18,13 @@ type emailRepository struct {
 	mu        sync.RWMutex
 }
 
+// cloneEmail copies an email so callers can change fields without racing other
+// readers of the map; every change goes back through UpdateEmail
+func cloneEmail(email *emaildomain.Email) *emaildomain.Email {
+	clone := *email
+	return &clone
+}
+
 // NewEmailRepository creates a new instance of emailRepository
 func NewEmailRepository() EmailRepository {
 	repo := &emailRepository{
@@ -214,6 +222,7 @@ func (r *emailRepository) initMockEmails() {
 	}
 
 	for _, email := range emails {
+		email.StableID = emailid.New(emailid.ProviderLocal, email.ID)
 		r.emails[email.ID] = email
 	}
 
@@ -225,6 +234,12 @@ func (r *emailRepository) updateMailboxCounts() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	r.updateMailboxCountsLocked()
+}
+
+// updateMailboxCountsLocked recomputes unread counts from scratch, so it is safe to call
+// after any mutation. Caller must hold the write lock.
+func (r *emailRepository) updateMailboxCountsLocked() {
 	for _, mailbox := range r.mailboxes {
 		count := 0
 		for _, email := range r.emails {
@@ -242,7 +257,8 @@ func (r *emailRepository) GetAllMailboxes() ([]*emaildomain.Mailbox, error) {
 
 	result := make([]*emaildomain.Mailbox, 0, len(r.mailboxes))
 	for _, mb := range r.mailboxes {
-		result = append(result, mb)
+		clone := *mb
+		result = append(result, &clone)
 	}
 	return result, nil
 }
@@ -255,7 +271,8 @@ func (r *emailRepository) GetMailboxByID(id string) (*emaildomain.Mailbox, error
 	if !exists {
 		return nil, nil
 	}
-	return mailbox, nil
+	clone := *mailbox
+	return &clone, nil
 }
 
 func (r *emailRepository) GetEmailsByMailbox(mailboxID string, limit, offset int) ([]*emaildomain.Email, int, error) {
@@ -265,7 +282,7 @@ func (r *emailRepository) GetEmailsByMailbox(mailboxID string, limit, offset int
 	var result []*emaildomain.Email
 	for _, email := range r.emails {
 		if email.MailboxID == mailboxID {
-			result = append(result, email)
+			result = append(result, cloneEmail(email))
 		}
 	}
 
@@ -301,7 +318,7 @@ func (r *emailRepository) GetEmailByID(id string) (*emaildomain.Email, error) {
 	if !exists {
 		return nil, nil
 	}
-	return email, nil
+	return cloneEmail(email), nil
 }
 
 func (r *emailRepository) UpdateEmail(email *emaildomain.Email) error {
@@ -312,7 +329,9 @@ func (r *emailRepository) UpdateEmail(email *emaildomain.Email) error {
 		return nil
 	}
 
-	r.emails[email.ID] = email
+	r.emails[email.ID] = cloneEmail(email)
+	// Read state or mailbox may have changed, keep the badges in sync
+	r.updateMailboxCountsLocked()
 	return nil
 }
 
@@ -324,7 +343,7 @@ func (r *emailRepository) GetEmailsByStatus(status string, limit, offset int) ([
 	var result []*emaildomain.Email
 	for _, email := range r.emails {
 		if email.Status == status {
-			result = append(result, email)
+			result = append(result, cloneEmail(email))
 		}
 	}
 
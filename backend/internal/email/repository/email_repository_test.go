@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"testing"
+
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+func mailboxCount(t *testing.T, repo EmailRepository, id string) int {
+	t.Helper()
+	mailbox, err := repo.GetMailboxByID(id)
+	if err != nil || mailbox == nil {
+		t.Fatalf("GetMailboxByID(%q) = (%v, %v)", id, mailbox, err)
+	}
+	return mailbox.Count
+}
+
+func unreadInbox(t *testing.T, repo EmailRepository) *emaildomain.Email {
+	t.Helper()
+	inbox, _, _ := repo.GetEmailsByMailbox("inbox", 100, 0)
+	for _, email := range inbox {
+		if !email.IsRead {
+			return email
+		}
+	}
+	t.Fatal("the mock inbox has no unread email")
+	return nil
+}
+
+func TestMarkReadDecrementsMailboxCount(t *testing.T) {
+	repo := NewEmailRepository()
+	before := mailboxCount(t, repo, "inbox")
+
+	email := unreadInbox(t, repo)
+	email.IsRead = true
+	if err := repo.UpdateEmail(email); err != nil {
+		t.Fatal(err)
+	}
+	if got := mailboxCount(t, repo, "inbox"); got != before-1 {
+		t.Errorf("inbox count = %d after marking read, want %d", got, before-1)
+	}
+
+	// Updating again with the same state doesn't count it twice
+	repo.UpdateEmail(email)
+	if got := mailboxCount(t, repo, "inbox"); got != before-1 {
+		t.Errorf("inbox count = %d after a repeated update, want %d", got, before-1)
+	}
+}
+
+func TestMoveShiftsMailboxCounts(t *testing.T) {
+	repo := NewEmailRepository()
+	inboxBefore, archiveBefore := mailboxCount(t, repo, "inbox"), mailboxCount(t, repo, "archive")
+
+	email := unreadInbox(t, repo)
+	email.MailboxID = "archive"
+	repo.UpdateEmail(email)
+
+	if got := mailboxCount(t, repo, "inbox"); got != inboxBefore-1 {
+		t.Errorf("inbox count = %d, want %d", got, inboxBefore-1)
+	}
+	if got := mailboxCount(t, repo, "archive"); got != archiveBefore+1 {
+		t.Errorf("archive count = %d, want %d", got, archiveBefore+1)
+	}
+}
+
+// Callers get copies, so changing one without UpdateEmail leaves the counts alone
+func TestReturnedEmailsAreCopies(t *testing.T) {
+	repo := NewEmailRepository()
+	before := mailboxCount(t, repo, "inbox")
+
+	email := unreadInbox(t, repo)
+	email.IsRead = true
+	stored, _ := repo.GetEmailByID(email.ID)
+	if stored.IsRead {
+		t.Error("changing a returned email changed the stored one")
+	}
+	if got := mailboxCount(t, repo, "inbox"); got != before {
+		t.Errorf("inbox count = %d, want %d", got, before)
+	}
+}
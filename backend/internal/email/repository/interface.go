@@ -1,6 +1,10 @@
 package repository
 
-import emaildomain "ga03-backend/internal/email/domain"
+import (
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+)
 
 // EmailRepository defines the interface for email repository operations
 type EmailRepository interface {
@@ -11,3 +15,44 @@ type EmailRepository interface {
 	GetEmailByID(id string) (*emaildomain.Email, error)
 	UpdateEmail(email *emaildomain.Email) error
 }
+
+// SyncStateRepository persists per-mailbox sync status
+type SyncStateRepository interface {
+	GetSyncStates(userID string) ([]*emaildomain.MailboxSyncState, error)
+	GetSyncState(userID, mailboxID string) (*emaildomain.MailboxSyncState, error)
+	SaveSyncState(state *emaildomain.MailboxSyncState) error
+}
+
+// KanbanRepository persists the Kanban column of each user's emails
+type KanbanRepository interface {
+	GetStatuses(userID string) ([]*emaildomain.KanbanStatus, error)
+	GetStatus(userID, emailID string) (*emaildomain.KanbanStatus, error)
+	SaveStatuses(statuses []*emaildomain.KanbanStatus) error
+	GetDueSnoozed(now time.Time) ([]*emaildomain.KanbanStatus, error)
+	// GetStatusPage returns a page of one column, most recently moved first, and the column's size
+	GetStatusPage(userID, status string, limit, offset int) ([]*emaildomain.KanbanStatus, int, error)
+}
+
+// SummaryRepository caches Gemini summaries per user and email
+type SummaryRepository interface {
+	Get(userID, emailID string) (*emaildomain.EmailSummary, error)
+	Save(summary *emaildomain.EmailSummary) error
+}
+
+// ContactRepository stores the addresses each user has exchanged mail with
+type ContactRepository interface {
+	GetContacts(userID string, emails []string) ([]*emaildomain.Contact, error)
+	SaveContacts(contacts []*emaildomain.Contact) error
+	Search(userID, query string, limit int) ([]*emaildomain.Contact, error)
+}
+
+// ScheduledEmailRepository persists emails queued to be sent later
+type ScheduledEmailRepository interface {
+	Create(email *emaildomain.ScheduledEmail) error
+	GetByUser(userID string, statuses []string) ([]*emaildomain.ScheduledEmail, error)
+	GetByID(userID, id string) (*emaildomain.ScheduledEmail, error)
+	GetDue(now time.Time, limit int) ([]*emaildomain.ScheduledEmail, error)
+	Transition(id, from, to string) (bool, error)
+	Save(email *emaildomain.ScheduledEmail) error
+	ResetSending() error
+}
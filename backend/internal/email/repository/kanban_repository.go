@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// kanbanRepository implements KanbanRepository interface
+type kanbanRepository struct {
+	db *gorm.DB
+}
+
+// NewKanbanRepository creates a new instance of kanbanRepository
+func NewKanbanRepository(db *gorm.DB) KanbanRepository {
+	return &kanbanRepository{
+		db: db,
+	}
+}
+
+func (r *kanbanRepository) GetStatuses(userID string) ([]*emaildomain.KanbanStatus, error) {
+	var statuses []*emaildomain.KanbanStatus
+	err := r.db.Where("user_id = ?", userID).Find(&statuses).Error
+	return statuses, err
+}
+
+func (r *kanbanRepository) GetStatus(userID, emailID string) (*emaildomain.KanbanStatus, error) {
+	var status emaildomain.KanbanStatus
+	err := r.db.Where("user_id = ? AND email_id = ?", userID, emailID).First(&status).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &status, nil
+}
+
+// SaveStatuses upserts all statuses in a single transaction
+func (r *kanbanRepository) SaveStatuses(statuses []*emaildomain.KanbanStatus) error {
+	if len(statuses) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for _, status := range statuses {
+		status.UpdatedAt = now
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&statuses).Error
+	})
+}
+
+// GetDueSnoozed returns snoozed emails of all users whose snooze ended before now
+func (r *kanbanRepository) GetDueSnoozed(now time.Time) ([]*emaildomain.KanbanStatus, error) {
+	var statuses []*emaildomain.KanbanStatus
+	err := r.db.Where("status = ? AND snoozed_until IS NOT NULL AND snoozed_until <= ?", "snoozed", now).Find(&statuses).Error
+	return statuses, err
+}
+
+func (r *kanbanRepository) GetStatusPage(userID, status string, limit, offset int) ([]*emaildomain.KanbanStatus, int, error) {
+	query := r.db.Model(&emaildomain.KanbanStatus{}).Where("user_id = ? AND status = ?", userID, status)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var statuses []*emaildomain.KanbanStatus
+	// email_id breaks ties so pages don't overlap when a batch move gave many rows the same time
+	err := query.Order("updated_at DESC").Order("email_id ASC").Limit(limit).Offset(offset).Find(&statuses).Error
+	return statuses, int(total), err
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// scheduledRepository implements ScheduledEmailRepository interface
+type scheduledRepository struct {
+	db *gorm.DB
+}
+
+// NewScheduledEmailRepository creates a new instance of scheduledRepository
+func NewScheduledEmailRepository(db *gorm.DB) ScheduledEmailRepository {
+	return &scheduledRepository{
+		db: db,
+	}
+}
+
+// Create stores a scheduled email together with its attachments
+func (r *scheduledRepository) Create(email *emaildomain.ScheduledEmail) error {
+	return r.db.Create(email).Error
+}
+
+// GetByUser returns a user's scheduled emails in the given states, soonest first, without attachment data
+func (r *scheduledRepository) GetByUser(userID string, statuses []string) ([]*emaildomain.ScheduledEmail, error) {
+	var emails []*emaildomain.ScheduledEmail
+	err := r.db.
+		Preload("Attachments", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "scheduled_email_id", "filename", "content_type", "size")
+		}).
+		Where("user_id = ? AND status IN ?", userID, statuses).
+		Order("send_at").
+		Find(&emails).Error
+	return emails, err
+}
+
+// GetByID returns one of a user's scheduled emails with attachment data, or nil
+func (r *scheduledRepository) GetByID(userID, id string) (*emaildomain.ScheduledEmail, error) {
+	var email emaildomain.ScheduledEmail
+	err := r.db.Preload("Attachments").Where("user_id = ? AND id = ?", userID, id).First(&email).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &email, nil
+}
+
+// GetDue returns pending emails of all users whose next attempt is due
+func (r *scheduledRepository) GetDue(now time.Time, limit int) ([]*emaildomain.ScheduledEmail, error) {
+	var emails []*emaildomain.ScheduledEmail
+	err := r.db.Preload("Attachments").
+		Where("status = ? AND next_attempt_at <= ?", emaildomain.ScheduledPending, now).
+		Order("next_attempt_at").
+		Limit(limit).
+		Find(&emails).Error
+	return emails, err
+}
+
+// Transition moves an email from one state to another and reports whether it was still
+// in the from state, so only one caller can claim a pending email
+func (r *scheduledRepository) Transition(id, from, to string) (bool, error) {
+	result := r.db.Model(&emaildomain.ScheduledEmail{}).
+		Where("id = ? AND status = ?", id, from).
+		Update("status", to)
+	return result.RowsAffected > 0, result.Error
+}
+
+// Save updates the delivery state of an email
+func (r *scheduledRepository) Save(email *emaildomain.ScheduledEmail) error {
+	return r.db.Model(email).Omit(clause.Associations).Select("status", "attempts", "next_attempt_at", "last_error", "sent_at").Updates(email).Error
+}
+
+// ResetSending returns emails left in the sending state by an interrupted process to pending
+func (r *scheduledRepository) ResetSending() error {
+	return r.db.Model(&emaildomain.ScheduledEmail{}).
+		Where("status = ?", emaildomain.ScheduledSending).
+		Update("status", emaildomain.ScheduledPending).Error
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// summaryRepository implements SummaryRepository interface
+type summaryRepository struct {
+	db *gorm.DB
+}
+
+// NewSummaryRepository creates a new instance of summaryRepository
+func NewSummaryRepository(db *gorm.DB) SummaryRepository {
+	return &summaryRepository{
+		db: db,
+	}
+}
+
+func (r *summaryRepository) Get(userID, emailID string) (*emaildomain.EmailSummary, error) {
+	var summary emaildomain.EmailSummary
+	err := r.db.Where("user_id = ? AND email_id = ?", userID, emailID).First(&summary).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// Save stores a summary, replacing an earlier one for the same email
+func (r *summaryRepository) Save(summary *emaildomain.EmailSummary) error {
+	summary.CreatedAt = time.Now()
+	return r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(summary).Error
+}
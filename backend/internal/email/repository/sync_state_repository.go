@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// syncStateRepository implements SyncStateRepository interface
+type syncStateRepository struct {
+	db *gorm.DB
+}
+
+// NewSyncStateRepository creates a new instance of syncStateRepository
+func NewSyncStateRepository(db *gorm.DB) SyncStateRepository {
+	return &syncStateRepository{
+		db: db,
+	}
+}
+
+func (r *syncStateRepository) GetSyncStates(userID string) ([]*emaildomain.MailboxSyncState, error) {
+	var states []*emaildomain.MailboxSyncState
+	err := r.db.Where("user_id = ?", userID).Find(&states).Error
+	return states, err
+}
+
+func (r *syncStateRepository) GetSyncState(userID, mailboxID string) (*emaildomain.MailboxSyncState, error) {
+	var state emaildomain.MailboxSyncState
+	err := r.db.Where("user_id = ? AND mailbox_id = ?", userID, mailboxID).First(&state).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SaveSyncState upserts the sync state for a user's mailbox
+func (r *syncStateRepository) SaveSyncState(state *emaildomain.MailboxSyncState) error {
+	state.UpdatedAt = time.Now()
+	return r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(state).Error
+}
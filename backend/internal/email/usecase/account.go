@@ -0,0 +1,99 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+// requiredGmailScopes are the scopes the app needs to read and modify mail
+var requiredGmailScopes = []string{
+	"https://www.googleapis.com/auth/gmail.modify",
+}
+
+// ErrIMAPCredentialsUnusable means the stored IMAP password or grant can't be used to sign in
+var ErrIMAPCredentialsUnusable = errors.New("stored IMAP credentials could not be used")
+
+// GetAccountStatus checks the health of the user's mail connection. A failed check is
+// reported in the status's Cause, not returned.
+func (u *emailUsecase) GetAccountStatus(userID string) (*emaildomain.AccountStatus, error) {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, authdomain.ErrUserNotFound
+	}
+
+	status := &emaildomain.AccountStatus{
+		Provider:  user.Provider,
+		Email:     user.Email,
+		CheckedAt: time.Now(),
+	}
+	ctx := context.Background()
+
+	switch {
+	case user.Provider == "imap":
+		decryptedPass, err := u.imapSecret(user)
+		if err != nil {
+			status.NeedsReauth = true
+			status.Cause = fmt.Errorf("%w: %v", ErrIMAPCredentialsUnusable, err)
+			return status, nil
+		}
+		if err := u.imapProvider.ValidateCredentials(ctx, user.ImapServer, user.ImapPort, user.Email, decryptedPass); err != nil {
+			status.NeedsReauth = true
+			status.Cause = err
+			return status, nil
+		}
+		status.Connected = true
+
+	case user.AccessToken == "":
+		// Local accounts have no external connection to check
+		status.Connected = user.Provider == "email"
+		status.NeedsReauth = user.Provider == "google"
+
+	default:
+		if err := u.mailProvider.ValidateToken(ctx, user.AccessToken, user.RefreshToken, u.makeTokenUpdateCallback(userID)); err != nil {
+			status.NeedsReauth = true
+			status.Cause = err
+			return status, nil
+		}
+		status.Connected = true
+
+		// Reload to pick up a refreshed access token
+		if refreshed, err := u.userRepo.FindByID(userID); err == nil && refreshed != nil {
+			user = refreshed
+		}
+
+		scopes, err := u.mailProvider.GetTokenScopes(ctx, user.AccessToken)
+		if err != nil {
+			status.Cause = err
+		} else {
+			status.Scopes = scopes
+			granted := make(map[string]bool, len(scopes))
+			for _, scope := range scopes {
+				granted[scope] = true
+			}
+			for _, scope := range requiredGmailScopes {
+				if !granted[scope] {
+					status.MissingScopes = append(status.MissingScopes, scope)
+				}
+			}
+			if len(status.MissingScopes) > 0 {
+				status.NeedsReauth = true
+			}
+		}
+
+		if !user.WatchExpiration.IsZero() {
+			expiration := user.WatchExpiration
+			status.WatchExpiration = &expiration
+			status.WatchActive = expiration.After(time.Now())
+		}
+	}
+
+	return status, nil
+}
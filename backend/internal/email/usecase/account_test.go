@@ -0,0 +1,118 @@
+package usecase
+
+import (
+	"errors"
+	"net"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	"ga03-backend/pkg/imap"
+)
+
+func TestAccountStatusGmail(t *testing.T) {
+	user := gmailUser("u1")
+	user.WatchExpiration = time.Now().Add(24 * time.Hour)
+	uc, deps := newTestUsecase(t, nil, user)
+	deps.provider.scopes = append([]string{"openid"}, requiredGmailScopes...)
+
+	status, err := uc.GetAccountStatus("u1")
+	if err != nil {
+		t.Fatalf("GetAccountStatus() error = %v", err)
+	}
+	if !status.Connected || status.NeedsReauth || status.Provider != "google" {
+		t.Errorf("status = %+v, want connected without reauth", status)
+	}
+	if !status.WatchActive || status.WatchExpiration == nil || !slices.Equal(status.Scopes, deps.provider.scopes) {
+		t.Errorf("status = %+v, want an active watch and the granted scopes", status)
+	}
+}
+
+func TestAccountStatusNeedsReauth(t *testing.T) {
+	t.Run("revoked token", func(t *testing.T) {
+		uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+		deps.provider.tokenErr = errors.New("oauth2: token expired and refresh token is not set")
+
+		status, err := uc.GetAccountStatus("u1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.Connected || !status.NeedsReauth || status.Cause == nil {
+			t.Errorf("status = %+v, want needs_reauth with the error", status)
+		}
+	})
+
+	t.Run("missing scope", func(t *testing.T) {
+		user := gmailUser("u1")
+		user.WatchExpiration = time.Now().Add(-time.Hour)
+		uc, deps := newTestUsecase(t, nil, user)
+		deps.provider.scopes = []string{"https://www.googleapis.com/auth/gmail.readonly"}
+
+		status, err := uc.GetAccountStatus("u1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !status.Connected || !status.NeedsReauth || !slices.Equal(status.MissingScopes, requiredGmailScopes) {
+			t.Errorf("status = %+v, want needs_reauth listing the missing scopes", status)
+		}
+		if status.WatchActive {
+			t.Error("an expired watch is reported active")
+		}
+	})
+
+	t.Run("google account without a token", func(t *testing.T) {
+		user := gmailUser("u1")
+		user.AccessToken = ""
+		uc, _ := newTestUsecase(t, nil, user)
+
+		status, _ := uc.GetAccountStatus("u1")
+		if !status.NeedsReauth {
+			t.Errorf("status = %+v, want needs_reauth", status)
+		}
+	})
+}
+
+func TestAccountStatusLocal(t *testing.T) {
+	uc, _ := newTestUsecase(t, nil, &authdomain.User{ID: "local", Email: "local@example.com", Provider: "email"})
+
+	status, err := uc.GetAccountStatus("local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Connected || status.NeedsReauth {
+		t.Errorf("status = %+v, want a connected local account", status)
+	}
+}
+
+func TestAccountStatusIMAP(t *testing.T) {
+	cfg := testConfig(t)
+
+	// Nothing listens on the port once the listener is closed
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, portStr, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+	port, _ := strconv.Atoi(portStr)
+
+	password, _ := cfg.Keyring.Encrypt("hunter2")
+	unreachable := &authdomain.User{ID: "u1", Email: "u1@example.com", Provider: "imap", ImapServer: host, ImapPort: port, ImapPassword: password}
+	noGrant := grantUser(t, cfg, "u2")
+	noGrant.RefreshToken = ""
+	uc, _ := newTestUsecase(t, cfg, unreachable, noGrant)
+	uc.imapProvider = imap.NewService()
+
+	causes := map[string]error{"u1": imap.ErrServerUnreachable, "u2": ErrIMAPCredentialsUnusable}
+	for id, cause := range causes {
+		status, err := uc.GetAccountStatus(id)
+		if err != nil {
+			t.Fatalf("%s: GetAccountStatus() error = %v", id, err)
+		}
+		if status.Connected || !status.NeedsReauth || !errors.Is(status.Cause, cause) || status.Provider != "imap" {
+			t.Errorf("%s: status = %+v, want needs_reauth caused by %v", id, status, cause)
+		}
+	}
+}
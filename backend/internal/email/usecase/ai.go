@@ -0,0 +1,87 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/pkg/utils/mailutil"
+)
+
+// minTodoPriority is the priority from which categorized mail goes to the To Do column
+const minTodoPriority = 4
+
+// CategorizeEmail has Gemini classify an email. With apply, urgent or high priority
+// mail is moved to the To Do column; the board has no columns for other categories.
+func (u *emailUsecase) CategorizeEmail(ctx context.Context, emailID string, apply bool) (*emaildomain.EmailCategory, error) {
+	userID, email, err := u.aiEmail(ctx, emailID)
+	if err != nil {
+		return nil, err
+	}
+
+	category, priority, err := u.geminiService.CategorizeEmail(ctx, emailText(email))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &emaildomain.EmailCategory{EmailID: emailID, Category: category, Priority: priority}
+	if apply && (category == "urgent" || priority >= minTodoPriority) {
+		if err := u.UpdateKanbanStatus(userID, emailID, "todo"); err != nil {
+			return nil, err
+		}
+		result.Status = "todo"
+	}
+	return result, nil
+}
+
+// SuggestReplies has Gemini draft three short replies to an email
+func (u *emailUsecase) SuggestReplies(ctx context.Context, emailID string) ([]string, error) {
+	_, email, err := u.aiEmail(ctx, emailID)
+	if err != nil {
+		return nil, err
+	}
+	return u.geminiService.SuggestReplies(ctx, emailText(email))
+}
+
+// languageCodeRe matches BCP 47 style codes such as "vi", "en" or "pt-BR"; it also
+// keeps free text out of the translation prompt
+var languageCodeRe = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// TranslateEmail has Gemini translate an email's body, as plain text, into targetLang
+func (u *emailUsecase) TranslateEmail(ctx context.Context, emailID, targetLang string) (*emaildomain.EmailTranslation, error) {
+	if !languageCodeRe.MatchString(targetLang) {
+		return nil, emaildomain.ErrInvalidLanguage
+	}
+	_, email, err := u.aiEmail(ctx, emailID)
+	if err != nil {
+		return nil, err
+	}
+
+	source, translation, err := u.geminiService.TranslateEmail(ctx, plainBody(email), targetLang)
+	if err != nil {
+		return nil, err
+	}
+	return &emaildomain.EmailTranslation{
+		EmailID:        emailID,
+		SourceLanguage: source,
+		TargetLanguage: targetLang,
+		Translation:    translation,
+	}, nil
+}
+
+// emailText is the email as Gemini sees it: sender and subject, then the body as text
+func emailText(email *emaildomain.Email) string {
+	return fmt.Sprintf("From: %s\nSubject: %s\n\n%s", email.From, email.Subject, plainBody(email))
+}
+
+// styleScriptRe matches blocks whose content isn't readable text
+var styleScriptRe = regexp.MustCompile(`(?is)<(style|script|head)\b[^>]*>.*?</(style|script|head)>`)
+
+// plainBody returns the readable text of an email's body
+func plainBody(email *emaildomain.Email) string {
+	if email.IsHTML {
+		return mailutil.HTMLToText(styleScriptRe.ReplaceAllString(email.Body, ""))
+	}
+	return email.Body
+}
@@ -0,0 +1,185 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"github.com/emersion/go-imap"
+)
+
+const maxBatchModifySize = 500
+
+var (
+	// ErrInvalidBatchAction is returned for an action BatchModify doesn't know
+	ErrInvalidBatchAction = errors.New("invalid batch action")
+	// ErrTooManyBatchIDs is returned for a batch over maxBatchModifySize emails
+	ErrTooManyBatchIDs = fmt.Errorf("too many email ids, max %d", maxBatchModifySize)
+	// ErrMailboxRequired is returned for a batch move without a target mailbox
+	ErrMailboxRequired = errors.New("mailbox is required to move emails")
+)
+
+var validBatchActions = map[string]bool{
+	"read":    true,
+	"unread":  true,
+	"star":    true,
+	"unstar":  true,
+	"trash":   true,
+	"archive": true,
+	"move":    true,
+}
+
+// BatchModify applies one action to many emails and reports the outcome for each ID,
+// in the order given. Gmail label changes go out as a single BatchModify call and IMAP
+// as one STORE or COPY per mailbox, so a failure only marks the IDs it affected.
+// Unlike the single-email endpoint, "star" always stars; "unstar" removes it.
+func (u *emailUsecase) BatchModify(userID, action string, ids []string, target string) ([]*emaildomain.BatchResult, error) {
+	if !validBatchActions[action] {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidBatchAction, action)
+	}
+	if len(ids) == 0 {
+		return nil, ErrNoEmailIDs
+	}
+	if len(ids) > maxBatchModifySize {
+		return nil, ErrTooManyBatchIDs
+	}
+	if action == "move" && target == "" {
+		return nil, ErrMailboxRequired
+	}
+
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, authdomain.ErrUserNotFound
+	}
+
+	defer u.invalidateStats(userID)
+	defer u.publishMailboxCounts(userID)
+	for _, id := range ids {
+		u.prefetch.invalidate(userID, id)
+	}
+
+	var errs map[string]error
+	switch {
+	case user.Provider == "imap":
+		errs, err = u.batchModifyIMAP(user, action, ids, target)
+	case user.AccessToken == "":
+		errs = u.batchModifyLocal(action, ids, target)
+	default:
+		errs = u.batchModifyGmail(user, action, ids, target)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*emaildomain.BatchResult, 0, len(ids))
+	for _, id := range ids {
+		results = append(results, &emaildomain.BatchResult{ID: id, Success: errs[id] == nil, Cause: errs[id]})
+	}
+	return results, nil
+}
+
+func (u *emailUsecase) batchModifyIMAP(user *authdomain.User, action string, ids []string, target string) (map[string]error, error) {
+	decryptedPass, err := u.imapSecret(user)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	store := func(flag string, add bool) map[string]error {
+		return u.imapProvider.StoreFlags(ctx, user.ImapServer, user.ImapPort, user.Email, decryptedPass, ids, []interface{}{flag}, add)
+	}
+	move := func(mailboxID string) map[string]error {
+		return u.imapProvider.MoveEmails(ctx, user.ImapServer, user.ImapPort, user.Email, decryptedPass, ids, mailboxID)
+	}
+
+	switch action {
+	case "read":
+		return store(imap.SeenFlag, true), nil
+	case "unread":
+		return store(imap.SeenFlag, false), nil
+	case "star":
+		return store(imap.FlaggedFlag, true), nil
+	case "unstar":
+		return store(imap.FlaggedFlag, false), nil
+	case "trash":
+		return move("TRASH"), nil
+	case "archive":
+		return move("ALL"), nil
+	default:
+		return move(target), nil
+	}
+}
+
+func (u *emailUsecase) batchModifyGmail(user *authdomain.User, action string, ids []string, target string) map[string]error {
+	ctx := context.Background()
+	onTokenRefresh := u.makeTokenUpdateCallback(user.ID)
+	errs := make(map[string]error, len(ids))
+
+	// Gmail has no batch trash, and trashing through labels skips its 30-day cleanup
+	if action == "trash" {
+		for _, id := range ids {
+			errs[id] = u.mailProvider.TrashEmail(ctx, user.AccessToken, user.RefreshToken, id, onTokenRefresh)
+		}
+		return errs
+	}
+
+	var add, remove []string
+	switch action {
+	case "read":
+		remove = []string{"UNREAD"}
+	case "unread":
+		add = []string{"UNREAD"}
+	case "star":
+		add = []string{"STARRED"}
+	case "unstar":
+		remove = []string{"STARRED"}
+	case "archive":
+		remove = []string{"INBOX"}
+	case "move":
+		add = []string{target}
+		if target != "INBOX" {
+			remove = []string{"INBOX"}
+		}
+	}
+
+	// BatchModify is all or nothing
+	err := u.mailProvider.BatchModify(ctx, user.AccessToken, user.RefreshToken, ids, add, remove, onTokenRefresh)
+	for _, id := range ids {
+		errs[id] = err
+	}
+	return errs
+}
+
+func (u *emailUsecase) batchModifyLocal(action string, ids []string, target string) map[string]error {
+	errs := make(map[string]error, len(ids))
+	for _, id := range ids {
+		email, err := u.emailRepo.GetEmailByID(id)
+		if err != nil {
+			errs[id] = err
+			continue
+		}
+		if email == nil {
+			errs[id] = emaildomain.ErrEmailNotFound
+			continue
+		}
+		switch action {
+		case "read", "unread":
+			email.IsRead = action == "read"
+		case "star", "unstar":
+			email.IsStarred = action == "star"
+		case "move":
+			email.MailboxID = target
+		default:
+			// Local storage has no trash or archive, like the single-email actions
+			continue
+		}
+		errs[id] = u.emailRepo.UpdateEmail(email)
+	}
+	return errs
+}
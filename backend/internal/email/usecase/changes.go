@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+// localChangesScan bounds how many emails per mailbox are checked for local accounts
+const localChangesScan = 1000
+
+// GetChanges reports emails added, modified or deleted since watermark, a value
+// returned by a previous call. Without a watermark, since is used instead and only
+// arrivals after it can be reported, so the result is marked incomplete. Clients
+// should store the returned watermark and send it on the next call.
+func (u *emailUsecase) GetChanges(userID, watermark string, since time.Time) (*emaildomain.Changes, error) {
+	if watermark == "" && since.IsZero() {
+		return nil, fmt.Errorf("since or watermark is required")
+	}
+
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, authdomain.ErrUserNotFound
+	}
+
+	ctx := context.Background()
+	if user.Provider == "imap" {
+		decryptedPass, err := u.imapSecret(user)
+		if err != nil {
+			return nil, err
+		}
+		return u.imapProvider.GetChanges(ctx, user.ImapServer, user.ImapPort, user.Email, decryptedPass, watermark, since)
+	}
+
+	accessToken, refreshToken, err := u.getUserTokens(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if accessToken == "" {
+		return u.localChanges(watermark, since)
+	}
+
+	return u.mailProvider.GetChanges(ctx, accessToken, refreshToken, watermark, since, u.makeTokenUpdateCallback(userID))
+}
+
+// localChanges serves the sample mailbox, which keeps no history: its watermark is
+// a timestamp and only newly received emails are reported
+func (u *emailUsecase) localChanges(watermark string, since time.Time) (*emaildomain.Changes, error) {
+	if watermark != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, watermark)
+		if err != nil {
+			return nil, emaildomain.ErrInvalidWatermark
+		}
+		since = parsed
+	}
+
+	now := time.Now()
+	mailboxes, err := u.emailRepo.GetAllMailboxes()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := &emaildomain.Changes{
+		Added:     []string{},
+		Modified:  []string{},
+		Deleted:   []string{},
+		Watermark: now.UTC().Format(time.RFC3339Nano),
+	}
+	seen := make(map[string]bool)
+	for _, mailbox := range mailboxes {
+		emails, _, err := u.emailRepo.GetEmailsByMailbox(mailbox.ID, localChangesScan, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, email := range emails {
+			if seen[email.ID] || !email.ReceivedAt.After(since) || email.ReceivedAt.After(now) {
+				continue
+			}
+			seen[email.ID] = true
+			changes.Added = append(changes.Added, email.ID)
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,135 @@
+package usecase
+
+import (
+	"log"
+	"net/mail"
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/pkg/utils/mailutil"
+)
+
+const (
+	defaultContactResults = 10
+	maxContactResults     = 50
+)
+
+// contactSighting is one appearance of an address on a message
+type contactSighting struct {
+	address *mail.Address
+	at      time.Time
+}
+
+// SearchContacts returns the user's contacts matching query, ranked by how often
+// and how recently they were mailed
+func (u *emailUsecase) SearchContacts(userID, query string, limit int) ([]*emaildomain.Contact, error) {
+	if limit <= 0 {
+		limit = defaultContactResults
+	}
+	if limit > maxContactResults {
+		limit = maxContactResults
+	}
+	return u.contactRepo.Search(userID, query, limit)
+}
+
+// recordReceivedContacts adds the senders and recipients of fetched emails to the user's contacts
+func (u *emailUsecase) recordReceivedContacts(userID string, emails []*emaildomain.Email) {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil || user == nil {
+		return
+	}
+
+	var seen []contactSighting
+	for _, email := range emails {
+		from := mailutil.ParseAddresses([]string{email.From})
+		if len(from) == 1 && from[0].Name == "" {
+			from[0].Name = email.FromName
+		}
+		addrs := append(from, mailutil.ParseAddresses(email.To)...)
+		addrs = append(addrs, mailutil.ParseAddresses(email.Cc)...)
+		for _, addr := range addrs {
+			seen = append(seen, contactSighting{address: addr, at: email.ReceivedAt})
+		}
+	}
+	u.recordContacts(userID, user.Email, seen)
+}
+
+// recordSentContacts adds the recipients of a sent message to the user's contacts
+func (u *emailUsecase) recordSentContacts(user *authdomain.User, msg *emaildomain.ComposedEmail) {
+	now := time.Now()
+	var seen []contactSighting
+	for _, addr := range mailutil.ParseAddresses([]string{msg.To, msg.Cc, msg.Bcc}) {
+		seen = append(seen, contactSighting{address: addr, at: now})
+	}
+	u.recordContacts(user.ID, user.Email, seen)
+}
+
+// recordContacts merges sightings into the stored contacts. A sighting only counts
+// towards Frequency when it is newer than the contact's LastSeen, so listing the
+// same messages again doesn't inflate it.
+func (u *emailUsecase) recordContacts(userID, selfEmail string, seen []contactSighting) {
+	self := mailutil.NormalizeAddress(selfEmail)
+	byAddress := make(map[string][]contactSighting)
+	var addresses []string
+	for _, s := range seen {
+		address := mailutil.NormalizeAddress(s.address.Address)
+		if address == "" || address == self {
+			continue
+		}
+		if _, ok := byAddress[address]; !ok {
+			addresses = append(addresses, address)
+		}
+		byAddress[address] = append(byAddress[address], s)
+	}
+	if len(addresses) == 0 {
+		return
+	}
+
+	// Serialized so concurrent fetches can't both count the same message
+	u.contactsMu.Lock()
+	defer u.contactsMu.Unlock()
+
+	existing, err := u.contactRepo.GetContacts(userID, addresses)
+	if err != nil {
+		log.Printf("Failed to load contacts for %s: %v", userID, err)
+		return
+	}
+	contacts := make(map[string]*emaildomain.Contact, len(existing))
+	for _, c := range existing {
+		contacts[c.Email] = c
+	}
+
+	var changed []*emaildomain.Contact
+	for _, address := range addresses {
+		contact, ok := contacts[address]
+		if !ok {
+			contact = &emaildomain.Contact{UserID: userID, Email: address}
+		}
+		dirty := !ok
+		lastSeen := contact.LastSeen
+		for _, s := range byAddress[address] {
+			if s.at.After(lastSeen) {
+				contact.Frequency++
+				dirty = true
+				if s.at.After(contact.LastSeen) {
+					contact.LastSeen = s.at
+					if s.address.Name != "" {
+						contact.Name = s.address.Name
+					}
+				}
+			}
+			if contact.Name == "" && s.address.Name != "" {
+				contact.Name = s.address.Name
+				dirty = true
+			}
+		}
+		if dirty {
+			changed = append(changed, contact)
+		}
+	}
+
+	if err := u.contactRepo.SaveContacts(changed); err != nil {
+		log.Printf("Failed to save contacts for %s: %v", userID, err)
+	}
+}
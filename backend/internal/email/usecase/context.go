@@ -0,0 +1,17 @@
+package usecase
+
+import "context"
+
+// userIDKey is the context key for the signed-in user's ID
+type userIDKey struct{}
+
+// WithUserID returns a copy of ctx carrying the user the AI use cases act for
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// userIDFrom returns the user ID set by WithUserID, or "" when there is none
+func userIDFrom(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDKey{}).(string)
+	return userID
+}
@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	authdomain "ga03-backend/internal/auth/domain"
+)
+
+// Permanent deletion through the Gmail API needs the full mail scope
+const gmailFullScope = "https://mail.google.com/"
+
+// ErrDeleteScopeNotGranted is returned when the Gmail token can't delete mail permanently
+var ErrDeleteScopeNotGranted = errors.New("gmail delete permission not granted, please sign in with Google again")
+
+// DeleteEmail permanently deletes an email that is already in Trash or Spam
+func (u *emailUsecase) DeleteEmail(userID, id string) error {
+	defer u.invalidateStats(userID)
+	defer u.publishMailboxCounts(userID)
+	defer u.prefetch.invalidate(userID, id)
+
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return authdomain.ErrUserNotFound
+	}
+
+	ctx := context.Background()
+	if user.Provider == "imap" {
+		decryptedPass, err := u.imapSecret(user)
+		if err != nil {
+			return err
+		}
+		return u.imapProvider.DeleteEmail(ctx, user.ImapServer, user.ImapPort, user.Email, decryptedPass, id)
+	}
+
+	if user.AccessToken == "" {
+		return fmt.Errorf("permanent delete is not available for local storage")
+	}
+	if scopes, err := u.mailProvider.GetTokenScopes(ctx, user.AccessToken); err == nil && !hasAnyScope(scopes, []string{gmailFullScope}) {
+		return ErrDeleteScopeNotGranted
+	}
+	return u.mailProvider.DeleteEmail(ctx, user.AccessToken, user.RefreshToken, id, u.makeTokenUpdateCallback(userID))
+}
+
+// EmptyTrash permanently deletes everything in the user's trash and returns how many
+// emails were removed
+func (u *emailUsecase) EmptyTrash(userID string) (int, error) {
+	defer u.invalidateStats(userID)
+	defer u.publishMailboxCounts(userID)
+
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return 0, err
+	}
+	if user == nil {
+		return 0, authdomain.ErrUserNotFound
+	}
+
+	ctx := context.Background()
+	if user.Provider == "imap" {
+		decryptedPass, err := u.imapSecret(user)
+		if err != nil {
+			return 0, err
+		}
+		return u.imapProvider.EmptyTrash(ctx, user.ImapServer, user.ImapPort, user.Email, decryptedPass)
+	}
+
+	if user.AccessToken == "" {
+		return 0, fmt.Errorf("permanent delete is not available for local storage")
+	}
+	if scopes, err := u.mailProvider.GetTokenScopes(ctx, user.AccessToken); err == nil && !hasAnyScope(scopes, []string{gmailFullScope}) {
+		return 0, ErrDeleteScopeNotGranted
+	}
+	return u.mailProvider.EmptyTrash(ctx, user.AccessToken, user.RefreshToken, u.makeTokenUpdateCallback(userID))
+}
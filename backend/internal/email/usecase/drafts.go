@@ -0,0 +1,148 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+	emaildto "ga03-backend/internal/email/dto"
+)
+
+// SaveDraft creates a draft and returns its ID. With req.DraftID set it updates that
+// draft instead, so autosave can send the same request repeatedly.
+func (u *emailUsecase) SaveDraft(userID string, req *emaildto.DraftRequest) (string, error) {
+	if req.DraftID != "" {
+		return u.UpdateDraft(userID, req.DraftID, req)
+	}
+
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		return "", authdomain.ErrUserNotFound
+	}
+	defer u.publishMailboxCounts(userID)
+
+	msg := composeEmail(user, req.FromName, req.To, req.Cc, req.Bcc, req.Subject, req.Body)
+	ctx := context.Background()
+	if user.Provider == "imap" {
+		decryptedPass, err := u.imapSecret(user)
+		if err != nil {
+			return "", err
+		}
+		return u.imapProvider.SaveDraft(ctx, user.ImapServer, user.ImapPort, user.Email, decryptedPass, "", msg)
+	}
+
+	if user.AccessToken == "" {
+		return "", fmt.Errorf("drafts are not available for local storage")
+	}
+	return u.mailProvider.CreateDraft(ctx, user.AccessToken, user.RefreshToken, msg, u.makeTokenUpdateCallback(userID))
+}
+
+// UpdateDraft replaces a draft's content and returns its ID. Gmail keeps the ID; on
+// IMAP the draft is stored again and gets a new one.
+func (u *emailUsecase) UpdateDraft(userID, draftID string, req *emaildto.DraftRequest) (string, error) {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		return "", authdomain.ErrUserNotFound
+	}
+
+	msg := composeEmail(user, req.FromName, req.To, req.Cc, req.Bcc, req.Subject, req.Body)
+	ctx := context.Background()
+	if user.Provider == "imap" {
+		decryptedPass, err := u.imapSecret(user)
+		if err != nil {
+			return "", err
+		}
+		return u.imapProvider.SaveDraft(ctx, user.ImapServer, user.ImapPort, user.Email, decryptedPass, draftID, msg)
+	}
+
+	if user.AccessToken == "" {
+		return "", fmt.Errorf("drafts are not available for local storage")
+	}
+	if err := u.mailProvider.UpdateDraft(ctx, user.AccessToken, user.RefreshToken, draftID, msg, u.makeTokenUpdateCallback(userID)); err != nil {
+		return "", err
+	}
+	return draftID, nil
+}
+
+// DeleteDraft discards a draft
+func (u *emailUsecase) DeleteDraft(userID, draftID string) error {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return authdomain.ErrUserNotFound
+	}
+	defer u.publishMailboxCounts(userID)
+
+	ctx := context.Background()
+	if user.Provider == "imap" {
+		decryptedPass, err := u.imapSecret(user)
+		if err != nil {
+			return err
+		}
+		return u.imapProvider.DeleteDraft(ctx, user.ImapServer, user.ImapPort, user.Email, decryptedPass, draftID)
+	}
+
+	if user.AccessToken == "" {
+		return fmt.Errorf("drafts are not available for local storage")
+	}
+	return u.mailProvider.DeleteDraft(ctx, user.AccessToken, user.RefreshToken, draftID, u.makeTokenUpdateCallback(userID))
+}
+
+// SendDraft sends a draft as last saved and removes it from Drafts
+func (u *emailUsecase) SendDraft(userID, draftID string) error {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return authdomain.ErrUserNotFound
+	}
+	defer u.invalidateStats(userID)
+	defer u.publishMailboxCounts(userID)
+
+	ctx := context.Background()
+	if user.Provider == "imap" {
+		decryptedPass, err := u.imapSecret(user)
+		if err != nil {
+			return err
+		}
+		return u.imapProvider.SendDraft(ctx, user.ImapServer, user.ImapPort, user.Email, decryptedPass, smtpSettings(user), draftID)
+	}
+
+	if user.AccessToken == "" {
+		return fmt.Errorf("drafts are not available for local storage")
+	}
+	return u.mailProvider.SendDraft(ctx, user.AccessToken, user.RefreshToken, draftID, u.makeTokenUpdateCallback(userID))
+}
+
+// draftIDs returns a function that sets DraftID on emails listed from the drafts
+// mailbox. On IMAP a draft's email ID is its draft ID; Gmail needs a lookup. Local
+// storage has no drafts to act on.
+func (u *emailUsecase) draftIDs(user *authdomain.User) func(email *emaildomain.Email) {
+	if user.Provider == "imap" {
+		return func(email *emaildomain.Email) {
+			email.DraftID = email.ID
+		}
+	}
+	if user.AccessToken == "" {
+		return func(email *emaildomain.Email) {}
+	}
+
+	ids, err := u.mailProvider.ListDraftIDs(context.Background(), user.AccessToken, user.RefreshToken, u.makeTokenUpdateCallback(user.ID))
+	if err != nil {
+		log.Printf("Failed to list draft IDs for %s: %v", user.ID, err)
+	}
+	return func(email *emaildomain.Email) {
+		email.DraftID = ids[email.ID]
+	}
+}
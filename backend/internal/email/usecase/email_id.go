@@ -0,0 +1,42 @@
+package usecase
+
+import (
+	"context"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/pkg/utils/emailid"
+)
+
+// ResolveEmailID maps a stable email ID to the provider-specific ID the other methods
+// take. IDs that aren't stable are returned unchanged.
+func (u *emailUsecase) ResolveEmailID(userID, id string) (string, error) {
+	provider, key, ok := emailid.Parse(id)
+	if !ok {
+		return id, nil
+	}
+
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		return "", authdomain.ErrUserNotFound
+	}
+
+	if user.Provider == "imap" {
+		if provider != emailid.ProviderIMAP {
+			return "", emaildomain.ErrEmailNotFound
+		}
+		decryptedPass, err := u.imapSecret(user)
+		if err != nil {
+			return "", err
+		}
+		return u.imapProvider.FindByMessageID(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, key)
+	}
+
+	if provider != emailid.ProviderGmail && provider != emailid.ProviderLocal {
+		return "", emaildomain.ErrEmailNotFound
+	}
+	return key, nil
+}
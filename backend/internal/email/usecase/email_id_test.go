@@ -0,0 +1,50 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+
+	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/pkg/utils/emailid"
+)
+
+func TestResolveEmailID(t *testing.T) {
+	uc, _ := newTestUsecase(t, nil, gmailUser("u1"))
+
+	tests := []struct {
+		name    string
+		id      string
+		want    string
+		wantErr error
+	}{
+		{"stable gmail id", emailid.New(emailid.ProviderGmail, "18c2f0a1"), "18c2f0a1", nil},
+		{"stable local id", emailid.New(emailid.ProviderLocal, "6f1c2b9e"), "6f1c2b9e", nil},
+		{"provider id unchanged", "18c2f0a1", "18c2f0a1", nil},
+		{"imap id for a gmail user", emailid.New(emailid.ProviderIMAP, "<lunch@example.com>"), "", emaildomain.ErrEmailNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := uc.ResolveEmailID("u1", tt.id)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("ResolveEmailID(%q) = %q, %v, want %q, %v", tt.id, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveEmailIDRoundTrip(t *testing.T) {
+	uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+	deps.provider.emails["18c2f0a1"] = &emaildomain.Email{ID: "18c2f0a1", StableID: emailid.New(emailid.ProviderGmail, "18c2f0a1"), Subject: "Lunch"}
+
+	listed, err := uc.GetEmailByID("u1", "18c2f0a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := uc.ResolveEmailID("u1", listed.StableID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email, err := uc.GetEmailByID("u1", id); err != nil || email.Subject != "Lunch" {
+		t.Errorf("opening the resolved stable ID got %v, %v", email, err)
+	}
+}
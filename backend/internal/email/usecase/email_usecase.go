@@ -3,13 +3,20 @@ package usecase
 import (
 	"context"
 	"fmt"
+	authdomain "ga03-backend/internal/auth/domain"
 	authrepo "ga03-backend/internal/auth/repository"
 	emaildomain "ga03-backend/internal/email/domain"
 	"ga03-backend/internal/email/repository"
 	"ga03-backend/pkg/config"
+	"ga03-backend/pkg/gemini"
 	"ga03-backend/pkg/imap"
-	"ga03-backend/pkg/utils/crypto"
+	"ga03-backend/pkg/safehttp"
+	"ga03-backend/pkg/utils/mailutil"
+	"log"
 	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -18,29 +25,51 @@ import (
 // emailUsecase implements EmailUsecase interface
 type emailUsecase struct {
 	emailRepo     repository.EmailRepository
+	syncStateRepo repository.SyncStateRepository
+	kanbanRepo    repository.KanbanRepository
+	scheduledRepo repository.ScheduledEmailRepository
+	summaryRepo   repository.SummaryRepository
+	contactRepo   repository.ContactRepository
 	userRepo      authrepo.UserRepository
 	mailProvider  emaildomain.MailProvider // Gmail Provider
 	imapProvider  *imap.IMAPService        // IMAP Provider
 	config        *config.Config
 	topicName     string
-	geminiService interface {
-		SummarizeEmail(ctx context.Context, emailText string) (string, error)
-	}
-	kanbanStatus map[string]string // emailID -> status
+	geminiService GeminiService
+	kanbanStatus map[string]string // userID:emailID -> status, write-through cache of kanbanRepo
+	kanbanLoaded map[string]bool   // users whose statuses are in kanbanStatus
+	kanbanMu     sync.RWMutex
+	statsCache   map[string]*cachedStats
+	dayCounts    map[string]*cachedDayCount // userID:date -> Gmail's count of inbox mail that day
+	statsMu      sync.Mutex
+	mailboxCache map[string]*cachedMailboxes // userID -> mailbox list with unread counts
+	mailboxGen   map[string]uint64           // bumped on invalidation so a fetch in flight isn't cached
+	mailboxMu    sync.Mutex
+	prefetch     *prefetcher
+	idle         idleSessions
+	undoSends    undoSends
+	background   sync.WaitGroup // Jobs started by Start, waited on by Shutdown
+	notify       NotifyFunc
+	historyMu    sync.Mutex // Serializes Gmail history reads so an arrival is reported once
+	contactsMu   sync.Mutex
+	linkClient   *http.Client // For URLs senders put in mail; only reaches public hosts
 }
 
 // SetGeminiService allows wiring GeminiService after creation
-func (u *emailUsecase) SetGeminiService(svc interface {
-	SummarizeEmail(ctx context.Context, emailText string) (string, error)
-}) {
+func (u *emailUsecase) SetGeminiService(svc GeminiService) {
 	u.geminiService = svc
 }
 
 // NewEmailUsecase creates a new instance of emailUsecase
-func NewEmailUsecase(emailRepo repository.EmailRepository, userRepo authrepo.UserRepository, mailProvider emaildomain.MailProvider, imapProvider *imap.IMAPService, cfg *config.Config, topicName string) EmailUsecase {
+func NewEmailUsecase(emailRepo repository.EmailRepository, syncStateRepo repository.SyncStateRepository, kanbanRepo repository.KanbanRepository, scheduledRepo repository.ScheduledEmailRepository, summaryRepo repository.SummaryRepository, contactRepo repository.ContactRepository, userRepo authrepo.UserRepository, mailProvider emaildomain.MailProvider, imapProvider *imap.IMAPService, cfg *config.Config, topicName string) EmailUsecase {
 	// GeminiService cần được truyền vào khi khởi tạo
 	uc := &emailUsecase{
 		emailRepo:     emailRepo,
+		syncStateRepo: syncStateRepo,
+		kanbanRepo:    kanbanRepo,
+		scheduledRepo: scheduledRepo,
+		summaryRepo:   summaryRepo,
+		contactRepo:   contactRepo,
 		userRepo:      userRepo,
 		mailProvider:  mailProvider,
 		imapProvider:  imapProvider,
@@ -48,32 +77,105 @@ func NewEmailUsecase(emailRepo repository.EmailRepository, userRepo authrepo.Use
 		topicName:     topicName,
 		geminiService: nil, // cần set sau
 		kanbanStatus:  make(map[string]string),
+		kanbanLoaded:  make(map[string]bool),
+		statsCache:    make(map[string]*cachedStats),
+		dayCounts:     make(map[string]*cachedDayCount),
+		mailboxCache:  make(map[string]*cachedMailboxes),
+		mailboxGen:    make(map[string]uint64),
+		prefetch:      newPrefetcher(cfg.PrefetchWorkers),
+		idle:          idleSessions{sessions: make(map[string]*idleSession)},
+		undoSends:     undoSends{timers: make(map[string]*time.Timer)},
+		linkClient:    safehttp.NewClient(10*time.Second, 3),
 	}
-	uc.startSnoozeChecker()
 	return uc
 }
 
-func (u *emailUsecase) startSnoozeChecker() {
-	ticker := time.NewTicker(1 * time.Minute)
+// Start runs the background jobs (snooze wake-ups, scheduled sends, Gmail watch
+// renewal) until ctx is done
+func (u *emailUsecase) Start(ctx context.Context) {
+	u.startSnoozeChecker(ctx)
+	u.startScheduledSender(ctx)
+	u.startWatchRenewer(ctx)
+}
+
+// Shutdown stops the IMAP IDLE watchers and the undo-send timers still waiting, then
+// waits until the jobs and sends already running finish or ctx is done. Call it once
+// Start's context is cancelled. A send whose timer was stopped stays queued, and the
+// scheduled sender delivers it after the restart.
+func (u *emailUsecase) Shutdown(ctx context.Context) error {
+	u.stopAllIdle()
+	u.undoSends.stop()
+
+	done := make(chan struct{})
 	go func() {
-		for range ticker.C {
-			u.checkSnoozedEmails()
+		u.background.Wait()
+		u.undoSends.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// every runs job each interval until ctx is done. A run in progress is never cut
+// short; Shutdown waits for it.
+func (u *emailUsecase) every(ctx context.Context, interval time.Duration, job func()) {
+	u.background.Add(1)
+	go func() {
+		defer u.background.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				job()
+			}
 		}
 	}()
 }
 
+func (u *emailUsecase) startSnoozeChecker(ctx context.Context) {
+	u.every(ctx, time.Minute, u.checkSnoozedEmails)
+}
+
 func (u *emailUsecase) checkSnoozedEmails() {
+	now := time.Now()
+
+	// Provider emails keep their snooze in the Kanban table
+	due, err := u.kanbanRepo.GetDueSnoozed(now)
+	if err != nil {
+		log.Printf("Failed to load snoozed emails: %v", err)
+	}
+	for _, status := range due {
+		if err := u.setKanbanStatus(status.UserID, status.EmailID, "inbox", nil); err != nil {
+			log.Printf("Failed to wake email %s from snooze: %v", status.EmailID, err)
+			continue
+		}
+		u.invalidateStats(status.UserID)
+		fmt.Printf("Email %s woke up from snooze\n", status.EmailID)
+		if u.notify != nil {
+			u.notify(status.UserID, "email_unsnoozed", map[string]interface{}{
+				"email_id":      status.EmailID,
+				"snoozed_until": status.SnoozedUntil,
+				"status":        "inbox",
+			})
+		}
+	}
+
 	// Get snoozed emails from repo
 	emails, _, err := u.emailRepo.GetEmailsByStatus("snoozed", 1000, 0)
 	if err != nil {
 		return
 	}
 
-	now := time.Now()
 	for _, email := range emails {
 		if email.SnoozedUntil != nil && email.SnoozedUntil.Before(now) {
 			// Wake up!
-			u.kanbanStatus[email.ID] = "inbox"
 			email.Status = "inbox"
 			email.SnoozedUntil = nil
 			u.emailRepo.UpdateEmail(email)
@@ -83,8 +185,13 @@ func (u *emailUsecase) checkSnoozedEmails() {
 }
 
 func (u *emailUsecase) SnoozeEmail(userID, emailID string, snoozeUntil time.Time) error {
+	// Unread counts change, so cached stats are stale
+	defer u.invalidateStats(userID)
+
 	// Update local status
-	u.kanbanStatus[emailID] = "snoozed"
+	if err := u.setKanbanStatus(userID, emailID, "snoozed", &snoozeUntil); err != nil {
+		return err
+	}
 
 	// Also update the email object in repository if possible
 	email, err := u.emailRepo.GetEmailByID(emailID)
@@ -98,29 +205,53 @@ func (u *emailUsecase) SnoozeEmail(userID, emailID string, snoozeUntil time.Time
 }
 
 // Lấy summary email qua Gemini
-func (u *emailUsecase) SummarizeEmail(ctx context.Context, emailID string) (string, error) {
-	// Lấy userID từ context nếu có
-	var userID string
-	if v := ctx.Value("userID"); v != nil {
-		if s, ok := v.(string); ok {
-			userID = s
+// SummarizeEmail returns the cached summary of an email, asking Gemini on a miss or
+// when force is set
+func (u *emailUsecase) SummarizeEmail(ctx context.Context, emailID string, force bool) (string, error) {
+	userID := userIDFrom(ctx)
+	if !force {
+		cached, err := u.summaryRepo.Get(userID, emailID)
+		if err != nil {
+			log.Printf("Failed to read cached summary of %s: %v", emailID, err)
+		} else if cached != nil {
+			return cached.Summary, nil
 		}
 	}
 
-	user, err := u.userRepo.FindByID(userID)
+	_, email, err := u.aiEmail(ctx, emailID)
+	if err != nil {
+		return "", err
+	}
+	prompt := "Hãy tóm tắt nội dung email sau bằng tiếng Việt, chỉ nêu ý chính, không thêm nhận xét cá nhân: " + email.Body
+	summary, err := u.geminiService.SummarizeEmail(ctx, prompt)
 	if err != nil {
 		return "", err
 	}
+
+	if err := u.summaryRepo.Save(&emaildomain.EmailSummary{UserID: userID, EmailID: emailID, Summary: summary}); err != nil {
+		log.Printf("Failed to cache summary of %s: %v", emailID, err)
+	}
+	return summary, nil
+}
+
+// aiEmail loads an email for the Gemini features, for the user set with WithUserID
+func (u *emailUsecase) aiEmail(ctx context.Context, emailID string) (string, *emaildomain.Email, error) {
+	userID := userIDFrom(ctx)
+
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return "", nil, err
+	}
 	if user == nil {
-		return "", fmt.Errorf("user not found")
+		return "", nil, authdomain.ErrUserNotFound
 	}
 
 	var email *emaildomain.Email
 
 	if user.Provider == "imap" {
-		decryptedPass, err := crypto.Decrypt(user.ImapPassword, u.config.EncryptionKey)
+		decryptedPass, err := u.imapSecret(user)
 		if err != nil {
-			return "", fmt.Errorf("failed to decrypt password: %w", err)
+			return "", nil, err
 		}
 		email, err = u.imapProvider.GetEmailByID(ctx, user.ImapServer, user.ImapPort, user.Email, decryptedPass, emailID)
 	} else {
@@ -135,13 +266,12 @@ func (u *emailUsecase) SummarizeEmail(ctx context.Context, emailID string) (stri
 	}
 
 	if err != nil || email == nil {
-		return "", fmt.Errorf("Email not found")
+		return "", nil, fmt.Errorf("Email not found")
 	}
 	if u.geminiService == nil {
-		return "", fmt.Errorf("Gemini service not configured")
+		return "", nil, gemini.ErrNotConfigured
 	}
-	prompt := "Hãy tóm tắt nội dung email sau bằng tiếng Việt, chỉ nêu ý chính, không thêm nhận xét cá nhân: " + email.Body
-	return u.geminiService.SummarizeEmail(ctx, prompt)
+	return userID, email, nil
 }
 
 func (u *emailUsecase) getUserTokens(userID string) (string, string, error) {
@@ -176,19 +306,71 @@ func (u *emailUsecase) makeTokenUpdateCallback(userID string) emaildomain.TokenU
 }
 
 func (u *emailUsecase) GetAllMailboxes(userID string) ([]*emaildomain.Mailbox, error) {
+	mailboxes, err := u.getAllMailboxes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Attach last-synced timestamps so the UI can show freshness
+	states, err := u.syncStateRepo.GetSyncStates(userID)
+	if err != nil {
+		return nil, err
+	}
+	syncedAt := make(map[string]time.Time, len(states))
+	for _, state := range states {
+		syncedAt[state.MailboxID] = state.LastSyncedAt
+	}
+	for i, mb := range mailboxes {
+		// Copy so we never mutate mailboxes shared by the local repository
+		mailbox := *mb
+		if t, ok := syncedAt[mb.ID]; ok {
+			mailbox.LastSyncedAt = &t
+		}
+		mailboxes[i] = &mailbox
+	}
+
+	applyHierarchy(mailboxes)
+	return mailboxes, nil
+}
+
+// GetMailboxTree returns the user's mailboxes nested by folder hierarchy
+func (u *emailUsecase) GetMailboxTree(userID string) ([]*emaildomain.Mailbox, error) {
+	mailboxes, err := u.GetAllMailboxes(userID)
+	if err != nil {
+		return nil, err
+	}
+	return buildMailboxTree(mailboxes), nil
+}
+
+// getAllMailboxes returns the user's mailboxes with their unread counts, from the
+// cache when it was filled within mailboxCacheTTL
+func (u *emailUsecase) getAllMailboxes(userID string) ([]*emaildomain.Mailbox, error) {
+	if mailboxes, ok := u.cachedMailboxes(userID); ok {
+		return mailboxes, nil
+	}
+	gen := u.mailboxGeneration(userID)
+	mailboxes, err := u.fetchMailboxes(userID)
+	if err != nil {
+		return nil, err
+	}
+	u.cacheMailboxes(userID, gen, mailboxes)
+	return mailboxes, nil
+}
+
+func (u *emailUsecase) fetchMailboxes(userID string) ([]*emaildomain.Mailbox, error) {
 	user, err := u.userRepo.FindByID(userID)
 	if err != nil {
 		return nil, err
 	}
 	if user == nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, authdomain.ErrUserNotFound
 	}
 
 	// IMAP Handler
 	if user.Provider == "imap" {
-		decryptedPass, err := crypto.Decrypt(user.ImapPassword, u.config.EncryptionKey)
+		decryptedPass, err := u.imapSecret(user)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decrypt password: %w", err)
+			return nil, err
 		}
 		return u.imapProvider.GetMailboxes(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass)
 	}
@@ -212,45 +394,157 @@ func (u *emailUsecase) GetMailboxByID(id string) (*emaildomain.Mailbox, error) {
 	return u.emailRepo.GetMailboxByID(id)
 }
 
-func (u *emailUsecase) GetEmailsByMailbox(userID, mailboxID string, limit, offset int, query string) ([]*emaildomain.Email, int, error) {
+// GetEmailsByMailbox returns a page of emails. Gmail pages forward with pageToken and
+// returns the token of the next page; other providers page by offset and return "".
+func (u *emailUsecase) GetEmailsByMailbox(userID, mailboxID string, limit, offset int, query, pageToken string) ([]*emaildomain.Email, int, string, error) {
+	emails, total, nextPageToken, err := u.getEmailsByMailbox(userID, mailboxID, limit, offset, query, pageToken)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	if mailboxID == "DRAFT" {
+		if user, err := u.userRepo.FindByID(userID); err == nil && user != nil {
+			setDraftID := u.draftIDs(user)
+			for _, email := range emails {
+				setDraftID(email)
+			}
+		}
+	}
+
+	// Loading the unfiltered first page brings the mailbox up to date
+	if offset == 0 && pageToken == "" && query == "" {
+		if _, err := u.recordSync(userID, mailboxID, emails); err != nil {
+			log.Printf("Failed to record sync state for %s/%s: %v", userID, mailboxID, err)
+		}
+	}
+
+	go u.recordReceivedContacts(userID, emails)
+
+	return emails, total, nextPageToken, nil
+}
+
+// SyncMailbox refreshes the newest page of a mailbox and returns its updated sync state
+func (u *emailUsecase) SyncMailbox(userID, mailboxID string) (*emaildomain.MailboxSyncState, error) {
+	emails, _, _, err := u.getEmailsByMailbox(userID, mailboxID, 20, 0, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return u.recordSync(userID, mailboxID, emails)
+}
+
+func (u *emailUsecase) recordSync(userID, mailboxID string, emails []*emaildomain.Email) (*emaildomain.MailboxSyncState, error) {
+	state := &emaildomain.MailboxSyncState{
+		UserID:       userID,
+		MailboxID:    mailboxID,
+		LastSyncedAt: time.Now(),
+	}
+	if len(emails) > 0 {
+		state.Watermark = emails[0].ID
+	} else if previous, err := u.syncStateRepo.GetSyncState(userID, mailboxID); err == nil && previous != nil {
+		state.Watermark = previous.Watermark
+	}
+
+	if err := u.syncStateRepo.SaveSyncState(state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (u *emailUsecase) getEmailsByMailbox(userID, mailboxID string, limit, offset int, query, pageToken string) ([]*emaildomain.Email, int, string, error) {
 	user, err := u.userRepo.FindByID(userID)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
 	}
 	if user == nil {
-		return nil, 0, fmt.Errorf("user not found")
+		return nil, 0, "", authdomain.ErrUserNotFound
 	}
 
 	// IMAP Handler
 	if user.Provider == "imap" {
-		decryptedPass, err := crypto.Decrypt(user.ImapPassword, u.config.EncryptionKey)
+		decryptedPass, err := u.imapSecret(user)
 		if err != nil {
-			return nil, 0, fmt.Errorf("failed to decrypt password: %w", err)
+			return nil, 0, "", err
+		}
+		if strings.TrimSpace(query) != "" {
+			emails, total, err := u.imapProvider.SearchEmails(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, mailboxID, imap.ParseSearchQuery(query), limit, offset)
+			return emails, total, "", err
 		}
-		return u.imapProvider.GetEmails(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, mailboxID, limit, offset)
+		emails, total, err := u.imapProvider.GetEmails(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, mailboxID, limit, offset)
+		return emails, total, "", err
 	}
 
 	// Gmail Handler
 	accessToken, refreshToken, err := u.getUserTokens(userID)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
 	}
 
 	if accessToken == "" {
 		// Fallback to local storage if no access token
-		return u.emailRepo.GetEmailsByMailbox(mailboxID, limit, offset)
+		emails, total, err := u.emailRepo.GetEmailsByMailbox(mailboxID, limit, offset)
+		return emails, total, "", err
 	}
 
 	ctx := context.Background()
-	return u.mailProvider.GetEmails(ctx, accessToken, refreshToken, mailboxID, limit, offset, query, u.makeTokenUpdateCallback(userID))
+	// A plain offset is still honored for clients that don't send page tokens
+	if pageToken == "" && offset > 0 {
+		emails, total, err := u.mailProvider.GetEmails(ctx, accessToken, refreshToken, mailboxID, limit, offset, query, u.makeTokenUpdateCallback(userID))
+		return emails, total, "", err
+	}
+	return u.mailProvider.GetEmailsPage(ctx, accessToken, refreshToken, mailboxID, limit, pageToken, query, u.makeTokenUpdateCallback(userID))
+}
+
+// StreamEmailsByMailbox fetches a page of emails, passing each to onEmail as it arrives.
+// Only Gmail fetches messages one by one; other providers emit the page once loaded.
+func (u *emailUsecase) StreamEmailsByMailbox(userID, mailboxID string, limit, offset int, query string, onEmail emaildomain.EmailFunc) (int, error) {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return 0, err
+	}
+	if user == nil {
+		return 0, authdomain.ErrUserNotFound
+	}
+
+	if user.Provider != "imap" && user.AccessToken != "" {
+		if mailboxID == "DRAFT" {
+			setDraftID, emit := u.draftIDs(user), onEmail
+			onEmail = func(email *emaildomain.Email) {
+				setDraftID(email)
+				emit(email)
+			}
+		}
+		ctx := context.Background()
+		return u.mailProvider.StreamEmails(ctx, user.AccessToken, user.RefreshToken, mailboxID, limit, offset, query, onEmail, u.makeTokenUpdateCallback(userID))
+	}
+
+	emails, total, _, err := u.GetEmailsByMailbox(userID, mailboxID, limit, offset, query, "")
+	if err != nil {
+		return 0, err
+	}
+	for _, email := range emails {
+		onEmail(email)
+	}
+	return total, nil
 }
 
 func (u *emailUsecase) GetAttachment(userID, messageID, attachmentID string) (*emaildomain.Attachment, []byte, error) {
-	accessToken, refreshToken, err := u.getUserTokens(userID)
+	user, err := u.userRepo.FindByID(userID)
 	if err != nil {
 		return nil, nil, err
 	}
+	if user == nil {
+		return nil, nil, authdomain.ErrUserNotFound
+	}
+
+	if user.Provider == "imap" {
+		decryptedPass, err := u.imapSecret(user)
+		if err != nil {
+			return nil, nil, err
+		}
+		return u.imapProvider.GetAttachment(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, messageID, attachmentID)
+	}
 
+	accessToken, refreshToken := user.AccessToken, user.RefreshToken
 	if accessToken == "" {
 		return nil, nil, nil // Not supported for local storage yet
 	}
@@ -260,19 +554,26 @@ func (u *emailUsecase) GetAttachment(userID, messageID, attachmentID string) (*e
 }
 
 func (u *emailUsecase) GetEmailByID(userID, id string) (*emaildomain.Email, error) {
+	if email := u.prefetch.take(userID, id); email != nil {
+		return email, nil
+	}
+	return u.getEmailByID(userID, id)
+}
+
+func (u *emailUsecase) getEmailByID(userID, id string) (*emaildomain.Email, error) {
 	user, err := u.userRepo.FindByID(userID)
 	if err != nil {
 		return nil, err
 	}
 	if user == nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, authdomain.ErrUserNotFound
 	}
 
 	// IMAP Handler
 	if user.Provider == "imap" {
-		decryptedPass, err := crypto.Decrypt(user.ImapPassword, u.config.EncryptionKey)
+		decryptedPass, err := u.imapSecret(user)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decrypt password: %w", err)
+			return nil, err
 		}
 		return u.imapProvider.GetEmailByID(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, id)
 	}
@@ -292,20 +593,66 @@ func (u *emailUsecase) GetEmailByID(userID, id string) (*emaildomain.Email, erro
 	return u.mailProvider.GetEmailByID(ctx, accessToken, refreshToken, id, u.makeTokenUpdateCallback(userID))
 }
 
+// GetEmailMetadata returns an email's headers and flags without fetching its body
+func (u *emailUsecase) GetEmailMetadata(userID, id string) (*emaildomain.Email, error) {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, authdomain.ErrUserNotFound
+	}
+
+	// IMAP Handler
+	if user.Provider == "imap" {
+		decryptedPass, err := u.imapSecret(user)
+		if err != nil {
+			return nil, err
+		}
+		return u.imapProvider.GetEmailMetadata(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, id)
+	}
+
+	// Gmail Handler
+	accessToken, refreshToken, err := u.getUserTokens(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if accessToken == "" {
+		// Fallback to local storage if no access token
+		email, err := u.emailRepo.GetEmailByID(id)
+		if err != nil || email == nil {
+			return email, err
+		}
+		metadata := *email
+		metadata.Body = ""
+		metadata.Attachments = nil
+		return &metadata, nil
+	}
+
+	ctx := context.Background()
+	return u.mailProvider.GetEmailMetadata(ctx, accessToken, refreshToken, id, u.makeTokenUpdateCallback(userID))
+}
+
 func (u *emailUsecase) MarkEmailAsRead(userID, id string) error {
+	defer u.invalidateStats(userID)
+	defer u.publishMailboxCounts(userID)
+	// Drop any prefetched copy so the next open sees the new flags
+	defer u.prefetch.invalidate(userID, id)
+
 	user, err := u.userRepo.FindByID(userID)
 	if err != nil {
 		return err
 	}
 	if user == nil {
-		return fmt.Errorf("user not found")
+		return authdomain.ErrUserNotFound
 	}
 
 	// IMAP Handler
 	if user.Provider == "imap" {
-		decryptedPass, err := crypto.Decrypt(user.ImapPassword, u.config.EncryptionKey)
+		decryptedPass, err := u.imapSecret(user)
 		if err != nil {
-			return fmt.Errorf("failed to decrypt password: %w", err)
+			return err
 		}
 		return u.imapProvider.MarkAsRead(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, id)
 	}
@@ -333,19 +680,24 @@ func (u *emailUsecase) MarkEmailAsRead(userID, id string) error {
 }
 
 func (u *emailUsecase) MarkEmailAsUnread(userID, id string) error {
+	defer u.invalidateStats(userID)
+	defer u.publishMailboxCounts(userID)
+	// Drop any prefetched copy so the next open sees the new flags
+	defer u.prefetch.invalidate(userID, id)
+
 	user, err := u.userRepo.FindByID(userID)
 	if err != nil {
 		return err
 	}
 	if user == nil {
-		return fmt.Errorf("user not found")
+		return authdomain.ErrUserNotFound
 	}
 
 	// IMAP Handler
 	if user.Provider == "imap" {
-		decryptedPass, err := crypto.Decrypt(user.ImapPassword, u.config.EncryptionKey)
+		decryptedPass, err := u.imapSecret(user)
 		if err != nil {
-			return fmt.Errorf("failed to decrypt password: %w", err)
+			return err
 		}
 		return u.imapProvider.MarkAsUnread(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, id)
 	}
@@ -373,19 +725,21 @@ func (u *emailUsecase) MarkEmailAsUnread(userID, id string) error {
 }
 
 func (u *emailUsecase) ToggleStar(userID, id string) error {
+	defer u.prefetch.invalidate(userID, id)
+
 	user, err := u.userRepo.FindByID(userID)
 	if err != nil {
 		return err
 	}
 	if user == nil {
-		return fmt.Errorf("user not found")
+		return authdomain.ErrUserNotFound
 	}
 
 	// IMAP Handler
 	if user.Provider == "imap" {
-		decryptedPass, err := crypto.Decrypt(user.ImapPassword, u.config.EncryptionKey)
+		decryptedPass, err := u.imapSecret(user)
 		if err != nil {
-			return fmt.Errorf("failed to decrypt password: %w", err)
+			return err
 		}
 		return u.imapProvider.ToggleStar(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, id)
 	}
@@ -412,22 +766,103 @@ func (u *emailUsecase) ToggleStar(userID, id string) error {
 	return u.mailProvider.ToggleStar(ctx, accessToken, refreshToken, id, u.makeTokenUpdateCallback(userID))
 }
 
-func (u *emailUsecase) SendEmail(userID, to, cc, bcc, subject, body string, files []*multipart.FileHeader) error {
+func (u *emailUsecase) ToggleImportant(userID, id string) error {
+	defer u.prefetch.invalidate(userID, id)
+
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return authdomain.ErrUserNotFound
+	}
+
+	// IMAP Handler
+	if user.Provider == "imap" {
+		decryptedPass, err := u.imapSecret(user)
+		if err != nil {
+			return err
+		}
+		return u.imapProvider.ToggleImportant(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, id)
+	}
+
+	accessToken, refreshToken, err := u.getUserTokens(userID)
+	if err != nil {
+		return err
+	}
+
+	if accessToken == "" {
+		// Fallback to local storage if no access token
+		email, err := u.emailRepo.GetEmailByID(id)
+		if err != nil {
+			return err
+		}
+		if email == nil {
+			return nil
+		}
+		email.IsImportant = !email.IsImportant
+		return u.emailRepo.UpdateEmail(email)
+	}
+
+	ctx := context.Background()
+	return u.mailProvider.ToggleImportant(ctx, accessToken, refreshToken, id, u.makeTokenUpdateCallback(userID))
+}
+
+func (u *emailUsecase) SendEmail(userID, fromName, to, cc, bcc, subject, body string, files []*multipart.FileHeader) error {
+	return u.sendEmail(userID, fromName, to, cc, bcc, subject, body, files, nil)
+}
+
+// sendEmail sends a new message, or a reply when reply is set
+func (u *emailUsecase) sendEmail(userID, fromName, to, cc, bcc, subject, body string, files []*multipart.FileHeader, reply *emaildomain.ReplyHeaders) error {
 	user, err := u.userRepo.FindByID(userID)
 	if err != nil {
 		return err
 	}
 	if user == nil {
-		return fmt.Errorf("user not found")
+		return authdomain.ErrUserNotFound
 	}
+	return u.sendComposed(user, composeEmail(user, fromName, to, cc, bcc, subject, body), files, reply)
+}
+
+// composeEmail applies the user's defaults to a new message. Everything that is sent
+// goes through here, so previews built with it match the sent message.
+func composeEmail(user *authdomain.User, fromName, to, cc, bcc, subject, body string) *emaildomain.ComposedEmail {
+	// Fall back to the account name when no per-send display name is given
+	fromName = mailutil.SanitizeDisplayName(fromName)
+	if fromName == "" {
+		fromName = user.Name
+	}
+	return &emaildomain.ComposedEmail{
+		FromName: fromName,
+		From:     user.Email,
+		To:       to,
+		Cc:       cc,
+		Bcc:      bcc,
+		Subject:  subject,
+		Body:     body,
+	}
+}
+
+// smtpSettings returns the SMTP server an IMAP user configured, if any
+func smtpSettings(user *authdomain.User) imap.SMTPSettings {
+	return imap.SMTPSettings{Server: user.SmtpServer, Port: user.SmtpPort, TLS: user.SmtpTLS}
+}
+
+// sendComposed hands a composed message to the user's provider
+func (u *emailUsecase) sendComposed(user *authdomain.User, msg *emaildomain.ComposedEmail, files []*multipart.FileHeader, reply *emaildomain.ReplyHeaders) (err error) {
+	defer func() {
+		if err == nil {
+			u.recordSentContacts(user, msg)
+		}
+	}()
 
 	// IMAP Handler (SMTP)
 	if user.Provider == "imap" {
-		decryptedPass, err := crypto.Decrypt(user.ImapPassword, u.config.EncryptionKey)
+		decryptedPass, err := u.imapSecret(user)
 		if err != nil {
-			return fmt.Errorf("failed to decrypt password: %w", err)
+			return err
 		}
-		return u.imapProvider.SendEmail(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, to, subject, body)
+		return u.imapProvider.SendEmail(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, smtpSettings(user), msg.FromName, msg.To, msg.Cc, msg.Bcc, msg.Subject, msg.Body, files, reply)
 	}
 
 	if user.AccessToken == "" {
@@ -435,23 +870,40 @@ func (u *emailUsecase) SendEmail(userID, to, cc, bcc, subject, body string, file
 	}
 
 	ctx := context.Background()
-	return u.mailProvider.SendEmail(ctx, user.AccessToken, user.RefreshToken, user.Name, user.Email, to, cc, bcc, subject, body, files, u.makeTokenUpdateCallback(userID))
+	return u.mailProvider.SendEmail(ctx, user.AccessToken, user.RefreshToken, msg.FromName, user.Email, msg.To, msg.Cc, msg.Bcc, msg.Subject, msg.Body, files, reply, u.makeTokenUpdateCallback(user.ID))
+}
+
+// CheckMissingAttachment returns a warning when the body talks about an attachment
+// but no files are being sent
+func (u *emailUsecase) CheckMissingAttachment(body string, hasFiles bool) string {
+	if hasFiles {
+		return ""
+	}
+	phrase, found := mailutil.MentionsAttachment(body, u.config.AttachmentKeywords)
+	if !found {
+		return ""
+	}
+	return fmt.Sprintf("the message mentions %q but has no attachments", phrase)
 }
 
 func (u *emailUsecase) TrashEmail(userID, id string) error {
+	defer u.invalidateStats(userID)
+	defer u.publishMailboxCounts(userID)
+	defer u.prefetch.invalidate(userID, id)
+
 	user, err := u.userRepo.FindByID(userID)
 	if err != nil {
 		return err
 	}
 	if user == nil {
-		return fmt.Errorf("user not found")
+		return authdomain.ErrUserNotFound
 	}
 
 	// IMAP Handler
 	if user.Provider == "imap" {
-		decryptedPass, err := crypto.Decrypt(user.ImapPassword, u.config.EncryptionKey)
+		decryptedPass, err := u.imapSecret(user)
 		if err != nil {
-			return fmt.Errorf("failed to decrypt password: %w", err)
+			return err
 		}
 		return u.imapProvider.TrashEmail(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, id)
 	}
@@ -471,19 +923,22 @@ func (u *emailUsecase) TrashEmail(userID, id string) error {
 }
 
 func (u *emailUsecase) ArchiveEmail(userID, id string) error {
+	defer u.invalidateStats(userID)
+	defer u.prefetch.invalidate(userID, id)
+
 	user, err := u.userRepo.FindByID(userID)
 	if err != nil {
 		return err
 	}
 	if user == nil {
-		return fmt.Errorf("user not found")
+		return authdomain.ErrUserNotFound
 	}
 
 	// IMAP Handler
 	if user.Provider == "imap" {
-		decryptedPass, err := crypto.Decrypt(user.ImapPassword, u.config.EncryptionKey)
+		decryptedPass, err := u.imapSecret(user)
 		if err != nil {
-			return fmt.Errorf("failed to decrypt password: %w", err)
+			return err
 		}
 		return u.imapProvider.ArchiveEmail(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, id)
 	}
@@ -512,106 +967,94 @@ func (u *emailUsecase) WatchMailbox(userID string) error {
 		return nil
 	}
 	ctx := context.Background()
-	return u.mailProvider.Watch(ctx, accessToken, refreshToken, u.topicName, u.makeTokenUpdateCallback(userID))
+	expiration, historyID, err := u.mailProvider.Watch(ctx, accessToken, refreshToken, u.topicName, u.makeTokenUpdateCallback(userID))
+	if err != nil {
+		return err
+	}
+
+	// Reload since the token callback may have updated the user
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil || user == nil {
+		return err
+	}
+	user.WatchExpiration = expiration
+	// Keep an existing history ID so changes from before a renewal still get processed
+	if user.LastHistoryID == 0 {
+		user.LastHistoryID = historyID
+	}
+	return u.userRepo.Update(user)
 }
 
-// Move email to another mailbox (Kanban drag & drop)
-func (u *emailUsecase) MoveEmailToMailbox(userID, emailID, mailboxID string) error {
-	accessToken, _, err := u.getUserTokens(userID)
+// MoveToMailbox moves an email into another mailbox at the provider. Gmail swaps the
+// folder label, IMAP copies the message over and expunges the original.
+func (u *emailUsecase) MoveToMailbox(userID, emailID, mailboxID string) error {
+	defer u.invalidateStats(userID)
+	defer u.publishMailboxCounts(userID)
+	defer u.prefetch.invalidate(userID, emailID)
+
+	user, err := u.userRepo.FindByID(userID)
 	if err != nil {
 		return err
 	}
-	if accessToken == "" {
+	if user == nil {
+		return authdomain.ErrUserNotFound
+	}
+
+	// IMAP Handler
+	if user.Provider == "imap" {
+		decryptedPass, err := u.imapSecret(user)
+		if err != nil {
+			return err
+		}
+		return u.imapProvider.MoveEmail(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, emailID, mailboxID)
+	}
+
+	if user.AccessToken == "" {
 		// Fallback to local storage
 		email, err := u.emailRepo.GetEmailByID(emailID)
 		if err != nil {
 			return err
 		}
 		if email == nil {
-			return nil
+			return emaildomain.ErrEmailNotFound
 		}
 		email.MailboxID = mailboxID
 		return u.emailRepo.UpdateEmail(email)
 	}
-	// Nếu là email thật từ Gmail, lưu trạng thái Kanban vào map
-	u.kanbanStatus[emailID] = mailboxID // mailboxID ở đây là status Kanban
-	return nil
+
+	// Trash keeps Gmail's 30-day cleanup, and All Mail isn't a label
+	ctx := context.Background()
+	onTokenRefresh := u.makeTokenUpdateCallback(userID)
+	switch mailboxID {
+	case "TRASH":
+		return u.mailProvider.TrashEmail(ctx, user.AccessToken, user.RefreshToken, emailID, onTokenRefresh)
+	case "ALL":
+		return u.mailProvider.ArchiveEmail(ctx, user.AccessToken, user.RefreshToken, emailID, onTokenRefresh)
+	}
+	return u.mailProvider.MoveEmail(ctx, user.AccessToken, user.RefreshToken, emailID, mailboxID, onTokenRefresh)
 }
 
 // GetEmailsByStatus returns emails by status (for Kanban columns)
 func (u *emailUsecase) GetEmailsByStatus(userID, status string, limit, offset int) ([]*emaildomain.Email, int, error) {
+	if !validKanbanStatuses[status] {
+		return nil, 0, fmt.Errorf("%w: %s", ErrInvalidKanbanStatus, status)
+	}
+
 	user, err := u.userRepo.FindByID(userID)
 	if err != nil {
 		return nil, 0, err
 	}
 	if user == nil {
-		return nil, 0, fmt.Errorf("user not found")
-	}
-
-	// IMAP Handler
-	if user.Provider == "imap" {
-		decryptedPass, err := crypto.Decrypt(user.ImapPassword, u.config.EncryptionKey)
-		if err != nil {
-			return nil, 0, fmt.Errorf("failed to decrypt password: %w", err)
-		}
-		
-		// For IMAP, we fetch INBOX and filter by local Kanban status
-		// Note: This is inefficient for large mailboxes as we fetch then filter.
-		// A better approach would be to store Kanban status in DB for IMAP users too.
-		emails, total, err := u.imapProvider.GetEmails(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, "INBOX", limit, offset)
-		if err != nil {
-			return nil, 0, err
-		}
-
-		var filtered []*emaildomain.Email
-		if status == "inbox" {
-			for _, email := range emails {
-				s, ok := u.kanbanStatus[email.ID]
-				if !ok || s == "inbox" {
-					filtered = append(filtered, email)
-				}
-			}
-		} else {
-			for _, email := range emails {
-				if s, ok := u.kanbanStatus[email.ID]; ok && s == status {
-					filtered = append(filtered, email)
-				}
-			}
-		}
-		return filtered, total, nil
+		return nil, 0, authdomain.ErrUserNotFound
 	}
 
-	// Gmail Handler
-	accessToken, refreshToken, err := u.getUserTokens(userID)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	if accessToken == "" {
+	if user.Provider != "imap" && user.AccessToken == "" {
 		// Fallback to local storage if no access token
 		return u.emailRepo.GetEmailsByStatus(status, limit, offset)
 	}
 
-	ctx := context.Background()
-	// Chỉ lấy đúng số lượng email từ Gmail theo limit và offset truyền vào
-	emails, total, err := u.mailProvider.GetEmails(ctx, accessToken, refreshToken, "INBOX", limit, offset, "", u.makeTokenUpdateCallback(userID))
-	if err != nil {
-		return nil, 0, err
-	}
-	var filtered []*emaildomain.Email
 	if status == "inbox" {
-		for _, email := range emails {
-			s, ok := u.kanbanStatus[email.ID]
-			if !ok || s == "inbox" {
-				filtered = append(filtered, email)
-			}
-		}
-	} else {
-		for _, email := range emails {
-			if s, ok := u.kanbanStatus[email.ID]; ok && s == status {
-				filtered = append(filtered, email)
-			}
-		}
+		return u.getInboxColumn(userID, limit, offset)
 	}
-	return filtered, total, nil
+	return u.getStatusColumn(user, status, limit, offset)
 }
@@ -0,0 +1,159 @@
+package usecase
+
+import (
+	"strings"
+	"testing"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/pkg/config"
+)
+
+func TestGetEmailMetadataSkipsBody(t *testing.T) {
+	uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+	deps.provider.emails["m1"] = &emaildomain.Email{ID: "m1", Subject: "Lunch", Body: "Noon at the usual place?"}
+
+	email, err := uc.GetEmailMetadata("u1", "m1")
+	if err != nil {
+		t.Fatalf("GetEmailMetadata() error = %v", err)
+	}
+	if email.Subject != "Lunch" || email.Body != "" {
+		t.Errorf("email = (%q, %q), want the subject without a body", email.Subject, email.Body)
+	}
+	if deps.provider.bodyFetches != 0 {
+		t.Errorf("the full message was fetched %d times", deps.provider.bodyFetches)
+	}
+}
+
+func TestGetEmailMetadataLocal(t *testing.T) {
+	local := &authdomain.User{ID: "local", Email: "local@example.com", Provider: "email"}
+	uc, _ := newTestUsecase(t, nil, local)
+	inbox, _, _ := uc.emailRepo.GetEmailsByMailbox("inbox", 1, 0)
+	if len(inbox) == 0 {
+		t.Fatal("the local repository has no inbox emails")
+	}
+
+	email, err := uc.GetEmailMetadata("local", inbox[0].ID)
+	if err != nil {
+		t.Fatalf("GetEmailMetadata() error = %v", err)
+	}
+	if email.Body != "" || email.Attachments != nil || email.Subject != inbox[0].Subject {
+		t.Errorf("email = (%q, %q), want the subject without body or attachments", email.Subject, email.Body)
+	}
+	// The stored email keeps its body
+	if stored, _ := uc.emailRepo.GetEmailByID(inbox[0].ID); stored.Body == "" {
+		t.Error("stripping the body changed the stored email")
+	}
+}
+
+func TestSendEmailFromName(t *testing.T) {
+	tests := []struct {
+		name     string
+		fromName string
+		want     string
+	}{
+		{"per-send name", "Support Team", "Support Team"},
+		{"non-ASCII name", "Đội Hỗ trợ", "Đội Hỗ trợ"},
+		{"sanitized", "Support\r\nBcc: x@example.com", "SupportBcc: x@example.com"},
+		{"falls back to the account name", "", "Test User"},
+		{"blank falls back too", " \r\n ", "Test User"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+			if err := uc.SendEmail("u1", tt.fromName, "to@example.com", "", "", "Hi", "Hello", nil); err != nil {
+				t.Fatalf("SendEmail() error = %v", err)
+			}
+			if len(deps.provider.sent) != 1 || deps.provider.sent[0].fromName != tt.want {
+				t.Errorf("sent %+v, want from name %q", deps.provider.sent, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkEmailAsReadUpdatesLocalCount(t *testing.T) {
+	local := &authdomain.User{ID: "local", Email: "local@example.com", Provider: "email"}
+	uc, _ := newTestUsecase(t, nil, local)
+
+	inboxCount := func() int {
+		mailboxes, err := uc.GetAllMailboxes("local")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, mailbox := range mailboxes {
+			if mailbox.ID == "inbox" {
+				return mailbox.Count
+			}
+		}
+		t.Fatal("no inbox mailbox")
+		return 0
+	}
+	before := inboxCount()
+
+	inbox, _, _ := uc.emailRepo.GetEmailsByMailbox("inbox", 100, 0)
+	for _, email := range inbox {
+		if !email.IsRead {
+			if err := uc.MarkEmailAsRead("local", email.ID); err != nil {
+				t.Fatal(err)
+			}
+			break
+		}
+	}
+	if got := inboxCount(); got != before-1 {
+		t.Errorf("inbox count = %d after marking read, want %d", got, before-1)
+	}
+}
+
+// A provider's counts are cached, so a mutation has to drop them
+func TestMarkEmailAsReadInvalidatesProviderCounts(t *testing.T) {
+	uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+	deps.provider.mailboxes = []*emaildomain.Mailbox{{ID: "INBOX", Type: "inbox", Count: 3}}
+
+	if _, err := uc.GetAllMailboxes("u1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.GetStats("u1", 7); err != nil {
+		t.Fatal(err)
+	}
+
+	deps.provider.mailboxes = []*emaildomain.Mailbox{{ID: "INBOX", Type: "inbox", Count: 2}}
+	if err := uc.MarkEmailAsRead("u1", "m1"); err != nil {
+		t.Fatal(err)
+	}
+
+	mailboxes, _ := uc.GetAllMailboxes("u1")
+	if mailboxes[0].Count != 2 {
+		t.Errorf("inbox count = %d, want the provider's fresh count 2", mailboxes[0].Count)
+	}
+	uc.statsMu.Lock()
+	cached := len(uc.statsCache)
+	uc.statsMu.Unlock()
+	if cached != 0 {
+		t.Error("stats are still cached after marking an email read")
+	}
+}
+
+func TestCheckMissingAttachment(t *testing.T) {
+	uc, _ := newTestUsecase(t, &config.Config{AttachmentKeywords: []string{"attached", "đính kèm"}})
+
+	if warning := uc.CheckMissingAttachment("Em gửi file đính kèm.", false); !strings.Contains(warning, "đính kèm") {
+		t.Errorf("warning = %q, want one naming the phrase", warning)
+	}
+	if warning := uc.CheckMissingAttachment("See attached.", true); warning != "" {
+		t.Errorf("warning = %q with files attached, want none", warning)
+	}
+	if warning := uc.CheckMissingAttachment("Lunch at noon?", false); warning != "" {
+		t.Errorf("warning = %q, want none", warning)
+	}
+}
+
+// Without a Gmail token there is nothing to fetch from, and the handler answers 404
+func TestGetAttachmentLocal(t *testing.T) {
+	local := &authdomain.User{ID: "local", Email: "local@example.com", Provider: "email"}
+	uc, _ := newTestUsecase(t, nil, local)
+
+	attachment, data, err := uc.GetAttachment("local", "m1", "a1")
+	if attachment != nil || data != nil || err != nil {
+		t.Errorf("GetAttachment() = (%v, %d bytes, %v), want nothing", attachment, len(data), err)
+	}
+}
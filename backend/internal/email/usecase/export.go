@@ -0,0 +1,143 @@
+package usecase
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/pkg/pdf"
+)
+
+const maxExportEmails = 50
+
+// ErrTooManyExportIDs is returned for a PDF export over maxExportEmails emails
+var ErrTooManyExportIDs = fmt.Errorf("too many email ids, max %d", maxExportEmails)
+
+// errImageUnavailable leaves an image out of an export, showing its alt text instead
+var errImageUnavailable = errors.New("image not available")
+
+// ExportEmailsPDF renders the given emails into one PDF, each starting on a new page.
+// HTML bodies keep their layout and inline (cid:) images. Remote images are fetched
+// with remoteImages; when it's nil they are left out, as they are in the app until
+// the user chooses to load them.
+func (u *emailUsecase) ExportEmailsPDF(userID string, emailIDs []string, remoteImages pdf.ImageLoader) ([]byte, error) {
+	if len(emailIDs) == 0 {
+		return nil, ErrNoEmailIDs
+	}
+	if len(emailIDs) > maxExportEmails {
+		return nil, ErrTooManyExportIDs
+	}
+
+	doc := pdf.NewDocument()
+	for _, id := range emailIDs {
+		email, err := u.GetEmailByID(userID, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load email %s: %w", id, err)
+		}
+		if email == nil {
+			return nil, fmt.Errorf("%w: %s", emaildomain.ErrEmailNotFound, id)
+		}
+		doc.NewPage()
+		writeEmailPDF(doc, email, u.bodyImages(userID, id, email, remoteImages))
+	}
+	return doc.Bytes()
+}
+
+func writeEmailPDF(doc *pdf.Document, email *emaildomain.Email, images pdf.ImageLoader) {
+	subject := email.Subject
+	if subject == "" {
+		subject = "(no subject)"
+	}
+	doc.Heading(subject)
+
+	doc.Field("From", email.From)
+	if len(email.To) > 0 {
+		doc.Field("To", strings.Join(email.To, ", "))
+	}
+	if len(email.Cc) > 0 {
+		doc.Field("Cc", strings.Join(email.Cc, ", "))
+	}
+	doc.Field("Date", email.ReceivedAt.Format("Mon, 02 Jan 2006 15:04 MST"))
+
+	// Inline images are part of the body, not something the sender attached
+	var names []string
+	for _, att := range email.Attachments {
+		if att.Name != "" && !att.IsInline {
+			names = append(names, att.Name)
+		}
+	}
+	if len(names) > 0 {
+		doc.Field("Attachments", strings.Join(names, ", "))
+	}
+	doc.Rule()
+
+	if email.IsHTML {
+		doc.HTML(email.Body, images)
+		return
+	}
+	doc.Text(strings.TrimSpace(email.Body))
+}
+
+// bodyImages loads the images of an email body for its export: inline parts by
+// cid:, data: URIs, and http(s) images through remote when it's given
+func (u *emailUsecase) bodyImages(userID, emailID string, email *emaildomain.Email, remote pdf.ImageLoader) pdf.ImageLoader {
+	return func(src string) ([]byte, error) {
+		lower := strings.ToLower(src)
+		switch {
+		case strings.HasPrefix(lower, "cid:"):
+			contentID := strings.Trim(src[len("cid:"):], "<>")
+			if unescaped, err := url.PathUnescape(contentID); err == nil {
+				contentID = unescaped
+			}
+			for _, att := range email.Attachments {
+				if att.ContentID == "" || att.ContentID != contentID {
+					continue
+				}
+				_, data, err := u.GetAttachment(userID, emailID, att.ID)
+				if err != nil {
+					return nil, err
+				}
+				if data == nil {
+					return nil, errImageUnavailable
+				}
+				return data, nil
+			}
+			return nil, emaildomain.ErrAttachmentNotFound
+		case strings.HasPrefix(lower, "data:"):
+			return decodeDataURI(src)
+		case strings.HasPrefix(lower, "http://"), strings.HasPrefix(lower, "https://"), strings.HasPrefix(src, "//"):
+			if remote == nil {
+				return nil, errImageUnavailable
+			}
+			if strings.HasPrefix(src, "//") {
+				src = "https:" + src
+			}
+			return remote(src)
+		}
+		return nil, errImageUnavailable
+	}
+}
+
+// decodeDataURI returns the data of a data: URI, either base64 or percent-encoded
+func decodeDataURI(uri string) ([]byte, error) {
+	header, data, ok := strings.Cut(uri[len("data:"):], ",")
+	if !ok {
+		return nil, errImageUnavailable
+	}
+	if strings.HasSuffix(strings.ToLower(header), ";base64") {
+		// Bodies often wrap long URIs across lines
+		decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(data), ""))
+		if err != nil {
+			return nil, errImageUnavailable
+		}
+		return decoded, nil
+	}
+	decoded, err := url.PathUnescape(data)
+	if err != nil {
+		return nil, errImageUnavailable
+	}
+	return []byte(decoded), nil
+}
@@ -0,0 +1,210 @@
+package usecase
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"regexp"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/pkg/config"
+)
+
+var pdfStreamRe = regexp.MustCompile(`(?s)stream\r?\n(.*?)endstream`)
+
+// pdfContent returns a PDF with its compressed streams inflated, so its text can be searched
+func pdfContent(t *testing.T, out []byte) []byte {
+	t.Helper()
+	var content bytes.Buffer
+	for _, m := range pdfStreamRe.FindAllSubmatch(out, -1) {
+		r, err := zlib.NewReader(bytes.NewReader(m[1]))
+		if err != nil {
+			continue
+		}
+		io.Copy(&content, r)
+	}
+	return content.Bytes()
+}
+
+// shown is text as the PDF's embedded font writes it: UTF-16 with PDF string escapes
+func shown(text string) []byte {
+	var b bytes.Buffer
+	for _, u := range utf16.Encode([]rune(text)) {
+		for _, c := range []byte{byte(u >> 8), byte(u)} {
+			if c == '(' || c == ')' || c == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(c)
+		}
+	}
+	return b.Bytes()
+}
+
+func TestExportEmailsPDF(t *testing.T) {
+	uc, deps := newTestUsecase(t, &config.Config{}, gmailUser("u1"))
+	deps.provider.emails["m1"] = &emaildomain.Email{
+		ID:         "m1",
+		Subject:    "Hẹn ăn trưa",
+		From:       "alice@example.com",
+		To:         []string{"u1@example.com"},
+		ReceivedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		IsHTML:     true,
+		Body:       `<style>p { color: red }</style><p>Gặp nhau lúc 12 giờ nhé?</p>`,
+		Attachments: []emaildomain.Attachment{
+			{Name: "menu.pdf"},
+			{Name: "logo.png", ContentID: "logo", IsInline: true},
+		},
+	}
+
+	out, err := uc.ExportEmailsPDF("u1", []string{"m1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out, []byte("%PDF-")) {
+		t.Fatalf("export isn't a PDF: %q", out[:min(len(out), 20)])
+	}
+	if !bytes.Contains(out, []byte("/Count 1")) {
+		t.Error("export isn't a single page")
+	}
+	content := pdfContent(t, out)
+	for _, want := range []string{"Hẹn ăn trưa", "From: alice@example.com", "To: u1@example.com", "Attachments: menu.pdf", "Gặp nhau lúc 12 giờ nhé?"} {
+		if !bytes.Contains(content, shown(want)) {
+			t.Errorf("PDF lacks %q", want)
+		}
+	}
+	if bytes.Contains(content, shown("color: red")) {
+		t.Error("the style block was exported as text")
+	}
+	if bytes.Contains(content, shown("logo.png")) {
+		t.Error("an inline image was listed as an attachment")
+	}
+}
+
+func TestExportEmailsPDFOnePagePerEmail(t *testing.T) {
+	uc, deps := newTestUsecase(t, &config.Config{}, gmailUser("u1"))
+	deps.provider.emails["m1"] = &emaildomain.Email{ID: "m1", Subject: "First", Body: "One"}
+	deps.provider.emails["m2"] = &emaildomain.Email{ID: "m2", Body: "Two"}
+
+	out, err := uc.ExportEmailsPDF("u1", []string{"m1", "m2"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out, []byte("/Count 2")) {
+		t.Error("export doesn't have a page per email")
+	}
+	content := pdfContent(t, out)
+	for _, want := range []string{"First", "(no subject)"} {
+		if !bytes.Contains(content, shown(want)) {
+			t.Errorf("PDF lacks %q", want)
+		}
+	}
+}
+
+func TestExportEmailsPDFImages(t *testing.T) {
+	var logo bytes.Buffer
+	if err := png.Encode(&logo, image.NewRGBA(image.Rect(0, 0, 40, 20))); err != nil {
+		t.Fatal(err)
+	}
+	newUsecase := func(t *testing.T) *emailUsecase {
+		uc, deps := newTestUsecase(t, &config.Config{}, gmailUser("u1"))
+		deps.provider.attachments = map[string][]byte{"a1": logo.Bytes()}
+		deps.provider.emails["m1"] = &emaildomain.Email{
+			ID:     "m1",
+			IsHTML: true,
+			Body: `<p>Logo:</p><img src="cid:logo@example.com" alt="Logo">` +
+				`<img src="https://cdn.example.com/banner.png" alt="Banner">` +
+				`<img src="cid:gone" alt="Gone">`,
+			Attachments: []emaildomain.Attachment{{ID: "a1", Name: "logo.png", ContentID: "logo@example.com", IsInline: true}},
+		}
+		return uc
+	}
+
+	t.Run("remote images blocked", func(t *testing.T) {
+		out, err := newUsecase(t).ExportEmailsPDF("u1", []string{"m1"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content := pdfContent(t, out)
+		if n := bytes.Count(content, []byte(" Do Q")); n != 1 {
+			t.Errorf("PDF draws %d images, want only the inline logo", n)
+		}
+		for _, want := range []string{"[Banner]", "[Gone]"} {
+			if !bytes.Contains(content, shown(want)) {
+				t.Errorf("PDF lacks the alt text %q", want)
+			}
+		}
+	})
+
+	t.Run("remote images loaded", func(t *testing.T) {
+		var fetched []string
+		remote := func(src string) ([]byte, error) {
+			fetched = append(fetched, src)
+			return logo.Bytes(), nil
+		}
+		out, err := newUsecase(t).ExportEmailsPDF("u1", []string{"m1"}, remote)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := bytes.Count(pdfContent(t, out), []byte(" Do Q")); n != 2 {
+			t.Errorf("PDF draws %d images, want the logo and banner", n)
+		}
+		if len(fetched) != 1 || fetched[0] != "https://cdn.example.com/banner.png" {
+			t.Errorf("fetched %v, want only the remote banner", fetched)
+		}
+	})
+}
+
+func TestDecodeDataURI(t *testing.T) {
+	tests := []struct {
+		uri     string
+		want    string
+		wantErr bool
+	}{
+		{"data:image/png;base64,aGVs\n bG8=", "hello", false},
+		{"data:image/svg+xml,%3Csvg%3E", "<svg>", false},
+		{"data:image/png;base64,!!!", "", true},
+		{"data:image/png", "", true},
+	}
+	for _, tt := range tests {
+		got, err := decodeDataURI(tt.uri)
+		if (err != nil) != tt.wantErr || string(got) != tt.want {
+			t.Errorf("decodeDataURI(%q) = (%q, %v), want %q", tt.uri, got, err, tt.want)
+		}
+	}
+}
+
+func TestExportEmailsPDFRejects(t *testing.T) {
+	uc, _ := newTestUsecase(t, &config.Config{}, gmailUser("u1"))
+	tooMany := make([]string, maxExportEmails+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("m%d", i)
+	}
+
+	tests := []struct {
+		name    string
+		ids     []string
+		wantErr error
+	}{
+		{"no ids", nil, ErrNoEmailIDs},
+		{"too many", tooMany, ErrTooManyExportIDs},
+		{"unknown email", []string{"missing"}, emaildomain.ErrEmailNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := uc.ExportEmailsPDF("u1", tt.ids, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ExportEmailsPDF() error = %v, want %v", err, tt.wantErr)
+			}
+			if out != nil {
+				t.Error("a partial PDF was returned")
+			}
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package usecase
+
+import (
+	"context"
+	"mime/multipart"
+	"sync"
+	"testing"
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	authrepo "ga03-backend/internal/auth/repository"
+	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/internal/email/repository"
+	"ga03-backend/pkg/config"
+)
+
+// Test doubles for the usecase's dependencies. Each embeds the interface it fakes,
+// so a call to a method a test didn't expect panics instead of silently succeeding.
+
+type fakeUsers struct {
+	authrepo.UserRepository
+	mu    sync.Mutex
+	users map[string]*authdomain.User
+}
+
+func (r *fakeUsers) FindByID(id string) (*authdomain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeUsers) Update(user *authdomain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *user
+	r.users[user.ID] = &copied
+	return nil
+}
+
+type sentEmail struct {
+	fromName, to, cc, bcc, subject, body string
+	files                                []*multipart.FileHeader
+	reply                                *emaildomain.ReplyHeaders
+}
+
+type fakeProvider struct {
+	emaildomain.MailProvider
+	mu          sync.Mutex
+	emails      map[string]*emaildomain.Email
+	sent        []sentEmail
+	mailboxes   []*emaildomain.Mailbox
+	inbox       []*emaildomain.Email // What GetEmails returns, for any mailbox
+	bodyFetches int                  // GetEmailByID calls, which download the whole message
+	tokenErr    error                // What ValidateToken returns
+	scopes      []string             // What GetTokenScopes returns
+
+	threads     map[string][]*emaildomain.Email // What GetThread returns, by thread ID
+	attachments map[string][]byte               // What GetAttachment returns, by attachment ID
+	archived    []string                        // IDs passed to ArchiveEmail
+	sentRaw     [][]byte                        // Messages passed to SendRawEmail
+	filters     []*emaildomain.MailFilter       // What ListFilters returns
+	archiveErr  error
+
+	// counts answers CountEmails by query; countDelay slows each call so tests can
+	// see how many run at once
+	counts         map[string]int
+	countDelay     time.Duration
+	countQueries   []string
+	countRunning   int
+	countMaxAtOnce int
+
+	// fetchDelay slows GetEmailByID the same way for background fetches
+	fetchDelay     time.Duration
+	fetchRunning   int
+	fetchMaxAtOnce int
+}
+
+func (p *fakeProvider) GetMailboxes(context.Context, string, string, emaildomain.TokenUpdateFunc) ([]*emaildomain.Mailbox, error) {
+	return p.mailboxes, nil
+}
+
+func (p *fakeProvider) GetEmails(_ context.Context, _, _, _ string, limit, _ int, _ string, _ emaildomain.TokenUpdateFunc) ([]*emaildomain.Email, int, error) {
+	if len(p.inbox) > limit {
+		return p.inbox[:limit], len(p.inbox), nil
+	}
+	return p.inbox, len(p.inbox), nil
+}
+
+func (p *fakeProvider) CountEmails(_ context.Context, _, _, query string, _ emaildomain.TokenUpdateFunc) (int, error) {
+	p.mu.Lock()
+	p.countQueries = append(p.countQueries, query)
+	p.countRunning++
+	p.countMaxAtOnce = max(p.countMaxAtOnce, p.countRunning)
+	p.mu.Unlock()
+
+	time.Sleep(p.countDelay)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.countRunning--
+	return p.counts[query], nil
+}
+
+func (p *fakeProvider) GetEmailByID(_ context.Context, _, _, messageID string, _ emaildomain.TokenUpdateFunc) (*emaildomain.Email, error) {
+	p.mu.Lock()
+	p.bodyFetches++
+	p.fetchRunning++
+	p.fetchMaxAtOnce = max(p.fetchMaxAtOnce, p.fetchRunning)
+	p.mu.Unlock()
+
+	time.Sleep(p.fetchDelay)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fetchRunning--
+	email, ok := p.emails[messageID]
+	if !ok {
+		return nil, emaildomain.ErrEmailNotFound
+	}
+	copied := *email
+	return &copied, nil
+}
+
+func (p *fakeProvider) GetThread(_ context.Context, _, _, threadID string, _ emaildomain.TokenUpdateFunc) ([]*emaildomain.Email, error) {
+	thread, ok := p.threads[threadID]
+	if !ok {
+		return nil, emaildomain.ErrEmailNotFound
+	}
+	return thread, nil
+}
+
+func (p *fakeProvider) GetAttachment(_ context.Context, _, _, _, attachmentID string, _ emaildomain.TokenUpdateFunc) (*emaildomain.Attachment, []byte, error) {
+	data, ok := p.attachments[attachmentID]
+	if !ok {
+		return nil, nil, emaildomain.ErrAttachmentNotFound
+	}
+	return &emaildomain.Attachment{ID: attachmentID, Size: int64(len(data))}, data, nil
+}
+
+func (p *fakeProvider) GetEmailMetadata(_ context.Context, _, _, messageID string, _ emaildomain.TokenUpdateFunc) (*emaildomain.Email, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	email, ok := p.emails[messageID]
+	if !ok {
+		return nil, emaildomain.ErrEmailNotFound
+	}
+	copied := *email
+	copied.Body = ""
+	return &copied, nil
+}
+
+func (p *fakeProvider) MarkAsRead(context.Context, string, string, string, emaildomain.TokenUpdateFunc) error {
+	return nil
+}
+
+func (p *fakeProvider) MarkAsUnread(context.Context, string, string, string, emaildomain.TokenUpdateFunc) error {
+	return nil
+}
+
+func (p *fakeProvider) ArchiveEmail(_ context.Context, _, _, messageID string, _ emaildomain.TokenUpdateFunc) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.archiveErr != nil {
+		return p.archiveErr
+	}
+	p.archived = append(p.archived, messageID)
+	return nil
+}
+
+func (p *fakeProvider) ValidateToken(context.Context, string, string, emaildomain.TokenUpdateFunc) error {
+	return p.tokenErr
+}
+
+func (p *fakeProvider) GetTokenScopes(context.Context, string) ([]string, error) {
+	return p.scopes, nil
+}
+
+func (p *fakeProvider) SendEmail(_ context.Context, _, _, fromName, _, to, cc, bcc, subject, body string, files []*multipart.FileHeader, reply *emaildomain.ReplyHeaders, _ emaildomain.TokenUpdateFunc) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sent = append(p.sent, sentEmail{fromName: fromName, to: to, cc: cc, bcc: bcc, subject: subject, body: body, files: files, reply: reply})
+	return nil
+}
+
+func (p *fakeProvider) SendRawEmail(_ context.Context, _, _ string, raw []byte, _ emaildomain.TokenUpdateFunc) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sentRaw = append(p.sentRaw, raw)
+	return nil
+}
+
+func (p *fakeProvider) ListFilters(context.Context, string, string, emaildomain.TokenUpdateFunc) ([]*emaildomain.MailFilter, error) {
+	return p.filters, nil
+}
+
+type fakeContacts struct {
+	repository.ContactRepository
+}
+
+func (fakeContacts) GetContacts(string, []string) ([]*emaildomain.Contact, error) { return nil, nil }
+func (fakeContacts) SaveContacts([]*emaildomain.Contact) error                    { return nil }
+
+// fakeSyncStates keeps sync states in memory, keyed by user and mailbox
+type fakeSyncStates struct {
+	repository.SyncStateRepository
+	mu     sync.Mutex
+	states map[string]*emaildomain.MailboxSyncState
+}
+
+func (r *fakeSyncStates) GetSyncStates(userID string) ([]*emaildomain.MailboxSyncState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result []*emaildomain.MailboxSyncState
+	for _, state := range r.states {
+		if state.UserID == userID {
+			copied := *state
+			result = append(result, &copied)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeSyncStates) GetSyncState(userID, mailboxID string) (*emaildomain.MailboxSyncState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, ok := r.states[userID+":"+mailboxID]
+	if !ok {
+		return nil, nil
+	}
+	copied := *state
+	return &copied, nil
+}
+
+func (r *fakeSyncStates) SaveSyncState(state *emaildomain.MailboxSyncState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *state
+	r.states[state.UserID+":"+state.MailboxID] = &copied
+	return nil
+}
+
+// fakeKanban keeps Kanban statuses in memory, keyed like the table by user and email
+type fakeKanban struct {
+	repository.KanbanRepository
+	mu       sync.Mutex
+	statuses map[string]*emaildomain.KanbanStatus
+}
+
+func (r *fakeKanban) GetStatuses(userID string) ([]*emaildomain.KanbanStatus, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result []*emaildomain.KanbanStatus
+	for _, status := range r.statuses {
+		if status.UserID == userID {
+			copied := *status
+			result = append(result, &copied)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeKanban) SaveStatuses(statuses []*emaildomain.KanbanStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, status := range statuses {
+		copied := *status
+		r.statuses[kanbanKey(status.UserID, status.EmailID)] = &copied
+	}
+	return nil
+}
+
+func (r *fakeKanban) GetDueSnoozed(now time.Time) ([]*emaildomain.KanbanStatus, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var due []*emaildomain.KanbanStatus
+	for _, status := range r.statuses {
+		if status.Status == "snoozed" && status.SnoozedUntil != nil && status.SnoozedUntil.Before(now) {
+			copied := *status
+			due = append(due, &copied)
+		}
+	}
+	return due, nil
+}
+
+// gmailUser is a signed-in Google user, whose mail goes through the fake provider
+func gmailUser(id string) *authdomain.User {
+	return &authdomain.User{ID: id, Email: id + "@example.com", Name: "Test User", Provider: "google", AccessToken: "access", RefreshToken: "refresh"}
+}
+
+// testDeps are the fakes behind a usecase built by newTestUsecase
+type testDeps struct {
+	users    *fakeUsers
+	provider *fakeProvider
+	kanban   *fakeKanban
+	sync     *fakeSyncStates
+}
+
+func newTestUsecase(t *testing.T, cfg *config.Config, users ...*authdomain.User) (*emailUsecase, *testDeps) {
+	t.Helper()
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	deps := &testDeps{
+		users:    &fakeUsers{users: make(map[string]*authdomain.User)},
+		provider: &fakeProvider{emails: make(map[string]*emaildomain.Email)},
+		kanban:   &fakeKanban{statuses: make(map[string]*emaildomain.KanbanStatus)},
+		sync:     &fakeSyncStates{states: make(map[string]*emaildomain.MailboxSyncState)},
+	}
+	for _, user := range users {
+		deps.users.users[user.ID] = user
+	}
+	uc := NewEmailUsecase(repository.NewEmailRepository(), deps.sync, deps.kanban, nil, nil, fakeContacts{}, deps.users, deps.provider, nil, cfg, "").(*emailUsecase)
+	return uc, deps
+}
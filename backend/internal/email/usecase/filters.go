@@ -0,0 +1,63 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+const gmailSettingsScope = "https://www.googleapis.com/auth/gmail.settings.basic"
+
+// ErrFilterScopeNotGranted is returned when the Gmail token can't manage filters
+var ErrFilterScopeNotGranted = errors.New("gmail filter permission not granted, please sign in with Google again")
+
+// gmailFilterAccess returns the tokens to use for filter management, checking that the
+// user is a Gmail user who granted the settings scope
+func (u *emailUsecase) gmailFilterAccess(userID string) (string, string, error) {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return "", "", err
+	}
+	if user == nil {
+		return "", "", authdomain.ErrUserNotFound
+	}
+	if user.Provider == "imap" || user.AccessToken == "" {
+		return "", "", fmt.Errorf("gmail filters are only available for Google accounts")
+	}
+
+	scopes, err := u.mailProvider.GetTokenScopes(context.Background(), user.AccessToken)
+	if err == nil && !hasAnyScope(scopes, []string{gmailSettingsScope}) {
+		return "", "", ErrFilterScopeNotGranted
+	}
+	return user.AccessToken, user.RefreshToken, nil
+}
+
+// ListGmailFilters returns the user's Gmail filters
+func (u *emailUsecase) ListGmailFilters(userID string) ([]*emaildomain.MailFilter, error) {
+	accessToken, refreshToken, err := u.gmailFilterAccess(userID)
+	if err != nil {
+		return nil, err
+	}
+	return u.mailProvider.ListFilters(context.Background(), accessToken, refreshToken, u.makeTokenUpdateCallback(userID))
+}
+
+// CreateGmailFilter adds a filter to the user's Gmail settings
+func (u *emailUsecase) CreateGmailFilter(userID string, filter *emaildomain.MailFilter) (*emaildomain.MailFilter, error) {
+	accessToken, refreshToken, err := u.gmailFilterAccess(userID)
+	if err != nil {
+		return nil, err
+	}
+	return u.mailProvider.CreateFilter(context.Background(), accessToken, refreshToken, filter, u.makeTokenUpdateCallback(userID))
+}
+
+// DeleteGmailFilter removes a filter from the user's Gmail settings
+func (u *emailUsecase) DeleteGmailFilter(userID, filterID string) error {
+	accessToken, refreshToken, err := u.gmailFilterAccess(userID)
+	if err != nil {
+		return err
+	}
+	return u.mailProvider.DeleteFilter(context.Background(), accessToken, refreshToken, filterID, u.makeTokenUpdateCallback(userID))
+}
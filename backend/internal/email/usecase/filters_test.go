@@ -0,0 +1,41 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+func TestListGmailFilters(t *testing.T) {
+	uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+	deps.provider.scopes = []string{"openid", gmailSettingsScope}
+	deps.provider.filters = []*emaildomain.MailFilter{{ID: "f1", Criteria: emaildomain.FilterCriteria{From: "billing@example.com"}}}
+
+	filters, err := uc.ListGmailFilters("u1")
+	if err != nil {
+		t.Fatalf("ListGmailFilters() error = %v", err)
+	}
+	if len(filters) != 1 || filters[0].ID != "f1" {
+		t.Errorf("filters = %+v, want the provider's filters", filters)
+	}
+}
+
+func TestListGmailFiltersRejects(t *testing.T) {
+	t.Run("settings scope not granted", func(t *testing.T) {
+		uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+		deps.provider.scopes = []string{"https://www.googleapis.com/auth/gmail.modify"}
+
+		if _, err := uc.ListGmailFilters("u1"); !errors.Is(err, ErrFilterScopeNotGranted) {
+			t.Errorf("ListGmailFilters() error = %v, want ErrFilterScopeNotGranted", err)
+		}
+	})
+
+	t.Run("imap account", func(t *testing.T) {
+		uc, _ := newTestUsecase(t, nil, &authdomain.User{ID: "u1", Email: "u1@example.com", Provider: "imap"})
+		if _, err := uc.ListGmailFilters("u1"); err == nil {
+			t.Error("ListGmailFilters() error = nil for an IMAP account")
+		}
+	})
+}
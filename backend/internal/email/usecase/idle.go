@@ -0,0 +1,137 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+)
+
+// NotifyFunc pushes a real-time event to a user's connected clients
+type NotifyFunc func(userID, eventType string, payload interface{})
+
+// idleSessions tracks the IMAP IDLE watcher of each user, at most one per user
+type idleSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*idleSession
+}
+
+type idleSession struct {
+	cancel context.CancelFunc
+}
+
+// SetNotifier allows wiring the real-time event sink after creation
+func (u *emailUsecase) SetNotifier(notify NotifyFunc) {
+	u.notify = notify
+}
+
+// StartIdle starts watching an IMAP user's INBOX so new mail is pushed as an
+// "email_update" event. It does nothing for other providers or if a watcher already runs.
+// It returns right away; the user is looked up and connected in the background.
+func (u *emailUsecase) StartIdle(userID string) {
+	if u.notify == nil {
+		return
+	}
+
+	u.idle.mu.Lock()
+	defer u.idle.mu.Unlock()
+	if _, ok := u.idle.sessions[userID]; ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	session := &idleSession{cancel: cancel}
+	u.idle.sessions[userID] = session
+
+	go func() {
+		err := u.watchInbox(ctx, userID)
+		if err != nil && err != context.Canceled {
+			log.Printf("IMAP IDLE for user %s ended: %v", userID, err)
+		}
+
+		u.idle.mu.Lock()
+		defer u.idle.mu.Unlock()
+		// StopIdle may already have removed it, or a new session replaced it
+		if u.idle.sessions[userID] == session {
+			delete(u.idle.sessions, userID)
+		}
+	}()
+}
+
+// RestartIdle reconnects the user's IMAP IDLE watcher, for when their IMAP credentials
+// changed. A user without a running watcher isn't connected and is left alone.
+func (u *emailUsecase) RestartIdle(userID string) {
+	u.idle.mu.Lock()
+	_, running := u.idle.sessions[userID]
+	u.idle.mu.Unlock()
+
+	if running {
+		u.StopIdle(userID)
+		u.StartIdle(userID)
+	}
+}
+
+// watchInbox runs IDLE on an IMAP user's INBOX until ctx is cancelled
+func (u *emailUsecase) watchInbox(ctx context.Context, userID string) error {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return authdomain.ErrUserNotFound
+	}
+	if user.Provider != "imap" {
+		return nil
+	}
+	// Checked once up front so a broken credential is logged instead of retried forever
+	if _, err := u.imapSecret(user); err != nil {
+		return err
+	}
+
+	onNewMessage := func(mailbox string) {
+		u.invalidateStats(userID)
+		u.notify(userID, "email_update", map[string]interface{}{
+			"email":     user.Email,
+			"mailbox":   mailbox,
+			"timestamp": time.Now(),
+		})
+	}
+
+	// Read on every reconnect, so a Google token refreshed meanwhile is used
+	secret := func() (string, error) {
+		current, err := u.userRepo.FindByID(userID)
+		if err != nil {
+			return "", err
+		}
+		if current == nil {
+			return "", authdomain.ErrUserNotFound
+		}
+		return u.imapSecret(current)
+	}
+
+	return u.imapProvider.StartIdle(ctx, user.ImapServer, user.ImapPort, user.Email, secret, onNewMessage)
+}
+
+// StopIdle stops the user's IMAP IDLE watcher, if any
+func (u *emailUsecase) StopIdle(userID string) {
+	u.idle.mu.Lock()
+	session, ok := u.idle.sessions[userID]
+	delete(u.idle.sessions, userID)
+	u.idle.mu.Unlock()
+
+	if ok {
+		session.cancel()
+	}
+}
+
+// stopAllIdle stops every user's IMAP IDLE watcher, for shutdown
+func (u *emailUsecase) stopAllIdle() {
+	u.idle.mu.Lock()
+	defer u.idle.mu.Unlock()
+	for userID, session := range u.idle.sessions {
+		session.cancel()
+		delete(u.idle.sessions, userID)
+	}
+}
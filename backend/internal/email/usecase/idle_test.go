@@ -0,0 +1,51 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func idleRunning(uc *emailUsecase, userID string) bool {
+	uc.idle.mu.Lock()
+	defer uc.idle.mu.Unlock()
+	_, ok := uc.idle.sessions[userID]
+	return ok
+}
+
+func TestStartIdleSkipsOtherProviders(t *testing.T) {
+	uc, _ := newTestUsecase(t, nil, gmailUser("u1"))
+	uc.SetNotifier(func(string, string, interface{}) {})
+
+	uc.StartIdle("u1")
+	deadline := time.Now().Add(time.Second)
+	for idleRunning(uc, "u1") {
+		if time.Now().After(deadline) {
+			t.Fatal("a Gmail user kept an IMAP watcher")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStopAndRestartIdle(t *testing.T) {
+	uc, _ := newTestUsecase(t, nil)
+	uc.SetNotifier(func(string, string, interface{}) {})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	uc.idle.sessions["u1"] = &idleSession{cancel: cancel}
+
+	// Nobody is watching u2's inbox, so changed credentials don't start a watcher
+	uc.RestartIdle("u2")
+	if idleRunning(uc, "u2") {
+		t.Error("RestartIdle started a watcher for a user without one")
+	}
+
+	uc.StopIdle("u1")
+	if ctx.Err() == nil {
+		t.Error("StopIdle didn't cancel the watcher")
+	}
+	if idleRunning(uc, "u1") {
+		t.Error("StopIdle left the session behind")
+	}
+	uc.StopIdle("u1") // Stopping again is harmless
+}
@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	"ga03-backend/pkg/imap"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// googleEndpoint is where Google access tokens are refreshed; tests point it elsewhere
+var googleEndpoint = google.Endpoint
+
+// imapSecret returns what an IMAP user's connections log in with. That is the stored
+// password, or for users who signed in with Google, a current access token from
+// their stored grant. A refreshed token is saved like the Gmail provider's are.
+func (u *emailUsecase) imapSecret(user *authdomain.User) (string, error) {
+	secret, err := u.config.Keyring.Decrypt(user.ImapPassword)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt password: %w", err)
+	}
+	if !imap.IsGoogleGrantSecret(secret) {
+		return secret, nil
+	}
+	if user.RefreshToken == "" {
+		return "", fmt.Errorf("%w (no Google grant stored)", imap.ErrAuthFailed)
+	}
+	// Tokens from the grant can read all of the user's mail, so they only go to Google
+	if !imap.IsGoogleIMAPServer(user.ImapServer) || (user.SmtpServer != "" && !imap.IsGoogleSMTPServer(user.SmtpServer)) {
+		return "", fmt.Errorf("%w (Google grant used with a non-Google server)", imap.ErrAuthFailed)
+	}
+
+	stored := &oauth2.Token{
+		AccessToken:  user.AccessToken,
+		RefreshToken: user.RefreshToken,
+		Expiry:       user.TokenExpiry,
+	}
+	// A zero expiry means "never expires" to oauth2, but Google tokens always do
+	if stored.Expiry.IsZero() {
+		stored.Expiry = time.Now()
+	}
+	oauthConfig := &oauth2.Config{
+		ClientID:     u.config.GoogleClientID,
+		ClientSecret: u.config.GoogleClientSecret,
+		Endpoint:     googleEndpoint,
+	}
+	token, err := oauthConfig.TokenSource(context.Background(), stored).Token()
+	if err != nil {
+		return "", fmt.Errorf("%w (refreshing the Google token: %v)", imap.ErrAuthFailed, err)
+	}
+
+	if token.AccessToken != user.AccessToken {
+		if err := u.makeTokenUpdateCallback(user.ID)(token); err != nil {
+			return "", fmt.Errorf("failed to save refreshed token: %w", err)
+		}
+		user.AccessToken = token.AccessToken
+		if token.RefreshToken != "" {
+			user.RefreshToken = token.RefreshToken
+		}
+		user.TokenExpiry = token.Expiry
+	}
+	return imap.OAuthSecret(token.AccessToken), nil
+}
@@ -0,0 +1,122 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	"ga03-backend/pkg/config"
+	"ga03-backend/pkg/imap"
+	"ga03-backend/pkg/utils/crypto"
+
+	"golang.org/x/oauth2"
+)
+
+// grantUser is an IMAP user who signed in with a Google access token
+func grantUser(t *testing.T, cfg *config.Config, id string) *authdomain.User {
+	t.Helper()
+	secret, err := cfg.Keyring.Encrypt(imap.GoogleGrantSecret())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &authdomain.User{ID: id, Email: id + "@gmail.com", Provider: "imap", ImapServer: imap.GoogleIMAPServer, ImapPassword: secret, AccessToken: "old", RefreshToken: "refresh"}
+}
+
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	keyring, err := crypto.NewKeyring(1, "0123456789abcdef0123456789abcdef", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &config.Config{Keyring: keyring, GoogleClientID: "client", GoogleClientSecret: "secret"}
+}
+
+// fakeTokenEndpoint hands out "fresh-1", "fresh-2", ... for each refresh
+func fakeTokenEndpoint(t *testing.T) *atomic.Int32 {
+	t.Helper()
+	var refreshes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != "refresh" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		n := refreshes.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"fresh-%d","token_type":"Bearer","expires_in":3600}`, n)
+	}))
+	t.Cleanup(server.Close)
+
+	saved := googleEndpoint
+	googleEndpoint = oauth2.Endpoint{TokenURL: server.URL, AuthStyle: oauth2.AuthStyleInParams}
+	t.Cleanup(func() { googleEndpoint = saved })
+	return &refreshes
+}
+
+func TestImapSecretPassword(t *testing.T) {
+	cfg := testConfig(t)
+	refreshes := fakeTokenEndpoint(t)
+	encrypted, _ := cfg.Keyring.Encrypt("hunter2")
+	uc, _ := newTestUsecase(t, cfg)
+
+	secret, err := uc.imapSecret(&authdomain.User{ID: "u1", Provider: "imap", ImapPassword: encrypted})
+	if err != nil || secret != "hunter2" {
+		t.Errorf("imapSecret() = (%q, %v), want the password", secret, err)
+	}
+	if refreshes.Load() != 0 {
+		t.Error("a password account shouldn't refresh anything")
+	}
+}
+
+func TestImapSecretRefreshesGoogleGrant(t *testing.T) {
+	cfg := testConfig(t)
+	refreshes := fakeTokenEndpoint(t)
+	user := grantUser(t, cfg, "u1")
+	user.TokenExpiry = time.Now().Add(-time.Minute)
+	uc, deps := newTestUsecase(t, cfg, user)
+
+	secret, err := uc.imapSecret(user)
+	if err != nil {
+		t.Fatalf("imapSecret() error = %v", err)
+	}
+	if secret != imap.OAuthSecret("fresh-1") {
+		t.Errorf("imapSecret() = %q, want the refreshed token", secret)
+	}
+	stored, _ := deps.users.FindByID("u1")
+	if stored.AccessToken != "fresh-1" || stored.RefreshToken != "refresh" || !stored.TokenExpiry.After(time.Now()) {
+		t.Errorf("stored token = (%q, %q, %v), want the refreshed one saved", stored.AccessToken, stored.RefreshToken, stored.TokenExpiry)
+	}
+
+	// The saved token is still valid, so the next connection reuses it
+	secret, err = uc.imapSecret(stored)
+	if err != nil || secret != imap.OAuthSecret("fresh-1") {
+		t.Errorf("second imapSecret() = (%q, %v)", secret, err)
+	}
+	if n := refreshes.Load(); n != 1 {
+		t.Errorf("refreshed %d times, want 1", n)
+	}
+}
+
+func TestImapSecretGrantFailures(t *testing.T) {
+	cfg := testConfig(t)
+	fakeTokenEndpoint(t)
+
+	noGrant := grantUser(t, cfg, "u1")
+	noGrant.RefreshToken = ""
+	revoked := grantUser(t, cfg, "u2")
+	revoked.RefreshToken = "revoked"
+	// A grant pointed at someone else's server must never be handed a token
+	elsewhere := grantUser(t, cfg, "u3")
+	elsewhere.ImapServer = "imap.attacker.example"
+	uc, _ := newTestUsecase(t, cfg, noGrant, revoked, elsewhere)
+
+	for _, user := range []*authdomain.User{noGrant, revoked, elsewhere} {
+		if _, err := uc.imapSecret(user); !errors.Is(err, imap.ErrAuthFailed) {
+			t.Errorf("%s: imapSecret() error = %v, want ErrAuthFailed so the user is asked to sign in again", user.ID, err)
+		}
+	}
+}
@@ -3,6 +3,9 @@ package usecase
 import (
 	"context"
 	emaildomain "ga03-backend/internal/email/domain"
+	emaildto "ga03-backend/internal/email/dto"
+	"ga03-backend/pkg/pdf"
+	"ga03-backend/pkg/utils/ical"
 	"mime/multipart"
 	"time"
 )
@@ -10,22 +13,86 @@ import (
 // EmailUsecase defines the interface for email use cases
 type EmailUsecase interface {
 	GetAllMailboxes(userID string) ([]*emaildomain.Mailbox, error)
+	GetMailboxTree(userID string) ([]*emaildomain.Mailbox, error)
 	GetMailboxByID(id string) (*emaildomain.Mailbox, error)
-	GetEmailsByMailbox(userID, mailboxID string, limit, offset int, query string) ([]*emaildomain.Email, int, error)
+	GetEmailsByMailbox(userID, mailboxID string, limit, offset int, query, pageToken string) ([]*emaildomain.Email, int, string, error)
+	SyncMailbox(userID, mailboxID string) (*emaildomain.MailboxSyncState, error)
+	GetChanges(userID, watermark string, since time.Time) (*emaildomain.Changes, error)
+	StreamEmailsByMailbox(userID, mailboxID string, limit, offset int, query string, onEmail emaildomain.EmailFunc) (int, error)
 	GetEmailsByStatus(userID, status string, limit, offset int) ([]*emaildomain.Email, int, error)
 	GetEmailByID(userID, id string) (*emaildomain.Email, error)
+	ScheduleEmail(userID, fromName, to, cc, bcc, subject, body string, files []*multipart.FileHeader, sendAt time.Time) (*emaildomain.ScheduledEmail, error)
+	ListScheduledEmails(userID string) ([]*emaildomain.ScheduledEmail, error)
+	CancelScheduledEmail(userID, id string) error
+	ResolveEmailID(userID, id string) (string, error)
+	GetThread(userID, threadID string) ([]*emaildomain.Email, error)
+	GetThreadParticipants(userID, threadID string) ([]*emaildomain.Participant, error)
+	SearchThread(userID, threadID, query string) ([]*emaildomain.ThreadMatch, error)
+	MarkThreadAsRead(userID, threadID string) error
+	MarkThreadAsUnread(userID, threadID string) error
+	ToggleThreadStar(userID, threadID string) error
+	TrashThread(userID, threadID string) error
+	GetInvite(userID, emailID string) (*ical.Event, error)
+	RespondToInvite(userID, emailID, response string) error
+	ListGmailFilters(userID string) ([]*emaildomain.MailFilter, error)
+	CreateGmailFilter(userID string, filter *emaildomain.MailFilter) (*emaildomain.MailFilter, error)
+	DeleteGmailFilter(userID, filterID string) error
+	PrefetchEmails(userID string, emailIDs []string) int
+	GetEmailMetadata(userID, id string) (*emaildomain.Email, error)
 	GetAttachment(userID, messageID, attachmentID string) (*emaildomain.Attachment, []byte, error)
 	MarkEmailAsRead(userID, id string) error
 	MarkEmailAsUnread(userID, id string) error
 	ToggleStar(userID, id string) error
-	SendEmail(userID, to, cc, bcc, subject, body string, files []*multipart.FileHeader) error
+	ToggleImportant(userID, id string) error
+	SendEmail(userID, fromName, to, cc, bcc, subject, body string, files []*multipart.FileHeader) error
+	SendEmailWithUndo(userID, fromName, to, cc, bcc, subject, body string, files []*multipart.FileHeader) (string, time.Time, error)
+	CancelSend(userID, pendingSendID string) error
+	CheckMissingAttachment(body string, hasFiles bool) string
+	ResendEmail(userID, emailID, to, cc, bcc string, confirm bool) error
+	ReplyEmail(userID, emailID, fromName, body string, plainText, replyAll bool, files []*multipart.FileHeader) (bool, error)
+	PreviewReply(userID, emailID, body string, plainText bool) (string, error)
+	PreviewEmail(userID, replyToID, fromName, to, cc, bcc, subject, body string, plainText, replyAll bool) (*emaildomain.ComposedEmail, error)
+	UpdateReplySettings(userID string, req *emaildto.ReplySettingsRequest) error
 	TrashEmail(userID, id string) error
 	ArchiveEmail(userID, id string) error
+	MarkAsSpam(userID, id string) error
+	MarkNotSpam(userID, id string) error
+	DeleteEmail(userID, id string) error
+	EmptyTrash(userID string) (int, error)
+	SaveDraft(userID string, req *emaildto.DraftRequest) (string, error)
+	UpdateDraft(userID, draftID string, req *emaildto.DraftRequest) (string, error)
+	DeleteDraft(userID, draftID string) error
+	SendDraft(userID, draftID string) error
 	WatchMailbox(userID string) error
-	SummarizeEmail(ctx context.Context, emailID string) (string, error)
-	MoveEmailToMailbox(userID, emailID, mailboxID string) error
+	GetNewGmailMessages(userID string, historyID uint64) ([]*emaildomain.Email, error)
+	GetAccountStatus(userID string) (*emaildomain.AccountStatus, error)
+	SummarizeEmail(ctx context.Context, emailID string, force bool) (string, error)
+	CategorizeEmail(ctx context.Context, emailID string, apply bool) (*emaildomain.EmailCategory, error)
+	SuggestReplies(ctx context.Context, emailID string) ([]string, error)
+	TranslateEmail(ctx context.Context, emailID, targetLang string) (*emaildomain.EmailTranslation, error)
+	MoveToMailbox(userID, emailID, mailboxID string) error
+	UpdateKanbanStatus(userID, emailID, status string) error
+	BatchUpdateKanbanStatus(userID string, emailIDs []string, status string) error
+	BatchModify(userID, action string, ids []string, target string) ([]*emaildomain.BatchResult, error)
 	SnoozeEmail(userID, emailID string, snoozeUntil time.Time) error
-	SetGeminiService(svc interface {
-		SummarizeEmail(ctx context.Context, emailText string) (string, error)
-	})
+	Unsubscribe(userID, emailID string) error
+	GetStats(userID string, days int) (*emaildomain.Stats, error)
+	GetUnifiedInbox(userID string, limit, offset int) (*emaildomain.UnifiedInbox, error)
+	SearchContacts(userID, query string, limit int) ([]*emaildomain.Contact, error)
+	ExportEmailsPDF(userID string, emailIDs []string, remoteImages pdf.ImageLoader) ([]byte, error)
+	SetGeminiService(svc GeminiService)
+	SetNotifier(notify NotifyFunc)
+	StartIdle(userID string)
+	StopIdle(userID string)
+	RestartIdle(userID string)
+	Start(ctx context.Context)
+	Shutdown(ctx context.Context) error
+}
+
+// GeminiService is the AI backend, see pkg/gemini
+type GeminiService interface {
+	SummarizeEmail(ctx context.Context, emailText string) (string, error)
+	CategorizeEmail(ctx context.Context, emailText string) (category string, priority int, err error)
+	SuggestReplies(ctx context.Context, emailText string) ([]string, error)
+	TranslateEmail(ctx context.Context, text, targetLang string) (sourceLang, translation string, err error)
 }
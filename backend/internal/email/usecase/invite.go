@@ -0,0 +1,127 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	"ga03-backend/pkg/utils/ical"
+	"ga03-backend/pkg/utils/mailutil"
+)
+
+// GetInvite returns the calendar event carried by an email
+func (u *emailUsecase) GetInvite(userID, emailID string) (*ical.Event, error) {
+	email, err := u.GetEmailByID(userID, emailID)
+	if err != nil {
+		return nil, err
+	}
+	if email == nil || !email.IsInvite {
+		return nil, fmt.Errorf("email is not a calendar invite")
+	}
+	if email.Invite != nil {
+		return email.Invite, nil
+	}
+	if email.InviteAttachmentID == "" {
+		return nil, fmt.Errorf("failed to parse calendar invite")
+	}
+
+	// Large or attached .ics files aren't inlined in the message, fetch them separately
+	_, data, err := u.GetAttachment(userID, emailID, email.InviteAttachmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load calendar invite: %w", err)
+	}
+	event, err := ical.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse calendar invite: %w", err)
+	}
+	return event, nil
+}
+
+// ErrSendScopeNotGranted is returned when the Gmail token can't send mail on the user's behalf
+var ErrSendScopeNotGranted = errors.New("gmail send permission not granted, please sign in with Google again")
+
+// Scopes that allow sending through the Gmail API
+var gmailSendScopes = []string{
+	"https://mail.google.com/",
+	"https://www.googleapis.com/auth/gmail.modify",
+	"https://www.googleapis.com/auth/gmail.compose",
+	"https://www.googleapis.com/auth/gmail.send",
+}
+
+var inviteResponses = map[string]string{
+	"accept":    ical.PartStatAccepted,
+	"decline":   ical.PartStatDeclined,
+	"tentative": ical.PartStatTentative,
+}
+
+var inviteSubjectPrefixes = map[string]string{
+	ical.PartStatAccepted:  "Accepted",
+	ical.PartStatDeclined:  "Declined",
+	ical.PartStatTentative: "Tentative",
+}
+
+// RespondToInvite answers a calendar invite by emailing an iMIP METHOD:REPLY to the organizer
+func (u *emailUsecase) RespondToInvite(userID, emailID, response string) error {
+	partStat, ok := inviteResponses[strings.ToLower(response)]
+	if !ok {
+		return fmt.Errorf("invalid response %q, expected accept, decline or tentative", response)
+	}
+
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return authdomain.ErrUserNotFound
+	}
+
+	event, err := u.GetInvite(userID, emailID)
+	if err != nil {
+		return err
+	}
+	if event.UID == "" || event.Organizer == "" {
+		return fmt.Errorf("calendar invite has no organizer to reply to")
+	}
+
+	ics := ical.BuildReply(event, user.Email, user.Name, partStat, time.Now())
+	subject := fmt.Sprintf("%s: %s", inviteSubjectPrefixes[partStat], event.Summary)
+	text := fmt.Sprintf("%s has %s the invitation: %s", user.Email, strings.ToLower(partStat), event.Summary)
+	raw := ical.BuildIMIPMessage(mailutil.FormatAddress(user.Name, user.Email), user.Email, event.Organizer, subject, text, ics)
+
+	ctx := context.Background()
+
+	// IMAP Handler (SMTP)
+	if user.Provider == "imap" {
+		decryptedPass, err := u.imapSecret(user)
+		if err != nil {
+			return err
+		}
+		return u.imapProvider.SendRawEmail(ctx, user.ImapServer, user.ImapPort, user.Email, decryptedPass, smtpSettings(user), []string{event.Organizer}, raw)
+	}
+
+	if user.AccessToken == "" {
+		return fmt.Errorf("responding to invites is not supported for this account")
+	}
+
+	// Older sign-ins may only have read access, tell the user to reconnect instead of
+	// surfacing a raw 403 from Gmail
+	if scopes, err := u.mailProvider.GetTokenScopes(ctx, user.AccessToken); err == nil && !hasAnyScope(scopes, gmailSendScopes) {
+		return ErrSendScopeNotGranted
+	}
+
+	return u.mailProvider.SendRawEmail(ctx, user.AccessToken, user.RefreshToken, raw, u.makeTokenUpdateCallback(userID))
+}
+
+func hasAnyScope(granted, wanted []string) bool {
+	for _, g := range granted {
+		for _, w := range wanted {
+			if g == w {
+				return true
+			}
+		}
+	}
+	return false
+}
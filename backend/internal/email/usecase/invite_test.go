@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/mail"
+	"testing"
+
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+const planningICS = "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:planning@example.com\r\nSUMMARY:Planning\r\nORGANIZER:mailto:alice@example.com\r\nDTSTART:20240305T090000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+func TestGetInviteFetchesAttachedEvent(t *testing.T) {
+	uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+	deps.provider.emails["m1"] = &emaildomain.Email{ID: "m1", IsInvite: true, InviteAttachmentID: "att-1"}
+	deps.provider.attachments = map[string][]byte{"att-1": []byte(planningICS)}
+
+	event, err := uc.GetInvite("u1", "m1")
+	if err != nil {
+		t.Fatalf("GetInvite() error = %v", err)
+	}
+	if event.Summary != "Planning" || event.Organizer != "alice@example.com" {
+		t.Errorf("event = %+v, want Planning organized by alice", event)
+	}
+}
+
+func TestGetInviteNotAnInvite(t *testing.T) {
+	uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+	deps.provider.emails["m1"] = &emaildomain.Email{ID: "m1", Subject: "Lunch"}
+	deps.provider.emails["m2"] = &emaildomain.Email{ID: "m2", IsInvite: true, InviteAttachmentID: "att-2"}
+	deps.provider.attachments = map[string][]byte{"att-2": []byte("not a calendar")}
+
+	if _, err := uc.GetInvite("u1", "m1"); err == nil {
+		t.Error("GetInvite() error = nil for an email without an invite")
+	}
+	if _, err := uc.GetInvite("u1", "m2"); err == nil {
+		t.Error("GetInvite() error = nil for an unparseable attachment")
+	}
+}
+
+func TestRespondToInvite(t *testing.T) {
+	uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+	deps.provider.emails["m1"] = &emaildomain.Email{ID: "m1", IsInvite: true, InviteAttachmentID: "att-1"}
+	deps.provider.attachments = map[string][]byte{"att-1": []byte(planningICS)}
+	deps.provider.scopes = []string{"https://www.googleapis.com/auth/gmail.send"}
+
+	if err := uc.RespondToInvite("u1", "m1", "Tentative"); err != nil {
+		t.Fatalf("RespondToInvite() error = %v", err)
+	}
+	if len(deps.provider.sentRaw) != 1 {
+		t.Fatalf("sent %d raw messages, want 1", len(deps.provider.sentRaw))
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(deps.provider.sentRaw[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(msg.Body)
+	if msg.Header.Get("To") != "alice@example.com" || !bytes.Contains(body, []byte("text/calendar; method=REPLY")) {
+		t.Errorf("sent to %q:\n%s\nwant an iMIP reply to the organizer", msg.Header.Get("To"), body)
+	}
+}
+
+func TestRespondToInviteRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		scopes   []string
+		wantErr  error
+	}{
+		{"unknown response", "maybe", []string{"https://mail.google.com/"}, nil},
+		{"read-only sign-in", "accept", []string{"https://www.googleapis.com/auth/gmail.readonly"}, ErrSendScopeNotGranted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+			deps.provider.emails["m1"] = &emaildomain.Email{ID: "m1", IsInvite: true, InviteAttachmentID: "att-1"}
+			deps.provider.attachments = map[string][]byte{"att-1": []byte(planningICS)}
+			deps.provider.scopes = tt.scopes
+
+			err := uc.RespondToInvite("u1", "m1", tt.response)
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("RespondToInvite() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(deps.provider.sentRaw) != 0 {
+				t.Errorf("sent %d raw messages, want none", len(deps.provider.sentRaw))
+			}
+		})
+	}
+}
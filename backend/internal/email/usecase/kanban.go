@@ -0,0 +1,345 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+const (
+	maxKanbanBatchSize = 100
+	// inboxColumnPageSize is how many INBOX emails are read at a time to fill the inbox column
+	inboxColumnPageSize = 50
+	// maxInboxColumnScan caps how deep into INBOX the inbox column looks, so a board
+	// with many sorted emails still answers quickly
+	maxInboxColumnScan = 1000
+	// kanbanFetchWorkers bounds the concurrent Gmail requests for one column page
+	kanbanFetchWorkers = 5
+)
+
+var (
+	// ErrInvalidKanbanStatus is returned for a status that isn't a Kanban column
+	ErrInvalidKanbanStatus = errors.New("invalid kanban status")
+	// ErrNoEmailIDs is returned for a batch request without any emails
+	ErrNoEmailIDs = errors.New("no email ids given")
+	// ErrTooManyEmailIDs is returned for a batch update over maxKanbanBatchSize emails
+	ErrTooManyEmailIDs = fmt.Errorf("too many email ids, max %d", maxKanbanBatchSize)
+)
+
+// validKanbanStatuses are the columns of the Kanban board
+var validKanbanStatuses = map[string]bool{
+	"inbox":   true,
+	"todo":    true,
+	"done":    true,
+	"snoozed": true,
+}
+
+func kanbanKey(userID, emailID string) string {
+	return userID + ":" + emailID
+}
+
+// loadKanbanStatuses fills the cache with the user's stored statuses on first use
+func (u *emailUsecase) loadKanbanStatuses(userID string) {
+	u.kanbanMu.RLock()
+	loaded := u.kanbanLoaded[userID]
+	u.kanbanMu.RUnlock()
+	if loaded {
+		return
+	}
+
+	statuses, err := u.kanbanRepo.GetStatuses(userID)
+	if err != nil {
+		log.Printf("Failed to load kanban statuses for user %s: %v", userID, err)
+		return
+	}
+
+	u.kanbanMu.Lock()
+	defer u.kanbanMu.Unlock()
+	if u.kanbanLoaded[userID] {
+		return
+	}
+	for _, status := range statuses {
+		u.kanbanStatus[kanbanKey(userID, status.EmailID)] = status.Status
+	}
+	u.kanbanLoaded[userID] = true
+}
+
+func (u *emailUsecase) getKanbanStatus(userID, emailID string) (string, bool) {
+	u.loadKanbanStatuses(userID)
+
+	u.kanbanMu.RLock()
+	defer u.kanbanMu.RUnlock()
+	status, ok := u.kanbanStatus[kanbanKey(userID, emailID)]
+	return status, ok
+}
+
+// setKanbanStatus stores the status in the database, then in the cache
+// UpdateKanbanStatus moves one email to another Kanban column. Local storage keeps
+// the column as the email's mailbox; other accounts store it next to the provider.
+func (u *emailUsecase) UpdateKanbanStatus(userID, emailID, status string) error {
+	if !validKanbanStatuses[status] {
+		return fmt.Errorf("%w: %s", ErrInvalidKanbanStatus, status)
+	}
+	defer u.invalidateStats(userID)
+	defer u.publishMailboxCounts(userID)
+	defer u.prefetch.invalidate(userID, emailID)
+
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return authdomain.ErrUserNotFound
+	}
+	if user.Provider != "imap" && user.AccessToken == "" {
+		email, err := u.emailRepo.GetEmailByID(emailID)
+		if err != nil {
+			return err
+		}
+		if email == nil {
+			return emaildomain.ErrEmailNotFound
+		}
+		email.MailboxID = status
+		return u.emailRepo.UpdateEmail(email)
+	}
+	return u.setKanbanStatus(userID, emailID, status, nil)
+}
+
+func (u *emailUsecase) setKanbanStatus(userID, emailID, status string, snoozedUntil *time.Time) error {
+	return u.setKanbanStatuses(userID, []string{emailID}, status, snoozedUntil)
+}
+
+func (u *emailUsecase) setKanbanStatuses(userID string, emailIDs []string, status string, snoozedUntil *time.Time) error {
+	rows := make([]*emaildomain.KanbanStatus, 0, len(emailIDs))
+	for _, id := range emailIDs {
+		rows = append(rows, &emaildomain.KanbanStatus{
+			UserID:       userID,
+			EmailID:      id,
+			Status:       status,
+			SnoozedUntil: snoozedUntil,
+		})
+	}
+	if err := u.kanbanRepo.SaveStatuses(rows); err != nil {
+		return fmt.Errorf("failed to save kanban status: %w", err)
+	}
+
+	u.kanbanMu.Lock()
+	defer u.kanbanMu.Unlock()
+	for _, id := range emailIDs {
+		u.kanbanStatus[kanbanKey(userID, id)] = status
+	}
+	return nil
+}
+
+// BatchUpdateKanbanStatus moves several emails to a Kanban column at once.
+// Every id is checked before anything is written, so the update is all-or-nothing.
+func (u *emailUsecase) BatchUpdateKanbanStatus(userID string, emailIDs []string, status string) error {
+	if !validKanbanStatuses[status] {
+		return fmt.Errorf("%w: %s", ErrInvalidKanbanStatus, status)
+	}
+	if len(emailIDs) == 0 {
+		return ErrNoEmailIDs
+	}
+	if len(emailIDs) > maxKanbanBatchSize {
+		return ErrTooManyEmailIDs
+	}
+
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return authdomain.ErrUserNotFound
+	}
+
+	// Ownership: each email must be readable with the user's own credentials
+	ctx := context.Background()
+	var decryptedPass string
+	if user.Provider == "imap" {
+		decryptedPass, err = u.imapSecret(user)
+		if err != nil {
+			return err
+		}
+	}
+	for _, id := range emailIDs {
+		var found bool
+		switch {
+		case user.Provider == "imap":
+			email, err := u.imapProvider.GetEmailMetadata(ctx, user.ImapServer, user.ImapPort, user.Email, decryptedPass, id)
+			found = err == nil && email != nil
+		case user.AccessToken == "":
+			email, err := u.emailRepo.GetEmailByID(id)
+			found = err == nil && email != nil
+		default:
+			email, err := u.mailProvider.GetEmailMetadata(ctx, user.AccessToken, user.RefreshToken, id, u.makeTokenUpdateCallback(userID))
+			found = err == nil && email != nil
+		}
+		if !found {
+			return fmt.Errorf("email not found: %s", id)
+		}
+	}
+
+	defer u.invalidateStats(userID)
+
+	if err := u.setKanbanStatuses(userID, emailIDs, status, nil); err != nil {
+		return err
+	}
+
+	if user.Provider != "imap" && user.AccessToken == "" {
+		for _, id := range emailIDs {
+			email, err := u.emailRepo.GetEmailByID(id)
+			if err != nil || email == nil {
+				continue
+			}
+			email.Status = status
+			if err := u.emailRepo.UpdateEmail(email); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// getStatusColumn reads the todo, done or snoozed column from the stored statuses, so
+// pages are full and the total is exact wherever the emails sit in the mailbox. Emails
+// deleted at the provider since they were sorted are left out of the page.
+func (u *emailUsecase) getStatusColumn(user *authdomain.User, status string, limit, offset int) ([]*emaildomain.Email, int, error) {
+	statuses, total, err := u.kanbanRepo.GetStatusPage(user.ID, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	ids := make([]string, len(statuses))
+	for i, status := range statuses {
+		ids[i] = status.EmailID
+	}
+
+	byID, err := u.getEmailsByIDs(user, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	column := make([]*emaildomain.Email, 0, len(ids))
+	for _, id := range ids {
+		if email, ok := byID[id]; ok {
+			column = append(column, email)
+		}
+	}
+	return column, total, nil
+}
+
+// getEmailsByIDs fetches the given emails without marking them read. Emails that
+// can't be fetched are missing from the result; it only fails when none could be.
+func (u *emailUsecase) getEmailsByIDs(user *authdomain.User, ids []string) (map[string]*emaildomain.Email, error) {
+	if len(ids) == 0 {
+		return map[string]*emaildomain.Email{}, nil
+	}
+
+	if user.Provider == "imap" {
+		decryptedPass, err := u.imapSecret(user)
+		if err != nil {
+			return nil, err
+		}
+		return u.imapProvider.GetEmailsByIDs(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, ids)
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	result := make(map[string]*emaildomain.Email, len(ids))
+	sem := make(chan struct{}, kanbanFetchWorkers)
+	for _, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			email, err := u.getEmailByID(user.ID, id)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil || email == nil {
+				log.Printf("Failed to load email %s of user %s: %v", id, user.ID, err)
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			result[id] = email
+		}(id)
+	}
+	wg.Wait()
+
+	if len(result) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+	return result, nil
+}
+
+// getInboxColumn pages through INBOX for emails that haven't been moved to another
+// column, until the requested window is full, INBOX runs out or maxInboxColumnScan
+// emails have been read. The total is exact when all of INBOX was read. Otherwise
+// it is INBOX's size less the emails sorted into other columns, which undercounts
+// when some of those have left INBOX.
+func (u *emailUsecase) getInboxColumn(userID string, limit, offset int) ([]*emaildomain.Email, int, error) {
+	pageSize := limit
+	if pageSize < inboxColumnPageSize {
+		pageSize = inboxColumnPageSize
+	}
+
+	var column []*emaildomain.Email
+	matched, scanned, inboxTotal := 0, 0, 0
+	exhausted := false
+	for scanned < maxInboxColumnScan {
+		emails, total, _, err := u.getEmailsByMailbox(userID, "INBOX", pageSize, scanned, "", "")
+		if err != nil {
+			return nil, 0, err
+		}
+		inboxTotal = total
+
+		for _, email := range emails {
+			if status, ok := u.getKanbanStatus(userID, email.ID); ok && status != "inbox" {
+				continue
+			}
+			if matched >= offset && len(column) < limit {
+				column = append(column, email)
+			}
+			matched++
+		}
+		scanned += len(emails)
+
+		if len(emails) < pageSize || scanned >= total {
+			exhausted = true
+			break
+		}
+		if len(column) >= limit {
+			break
+		}
+	}
+
+	if exhausted {
+		return column, matched, nil
+	}
+
+	statuses, err := u.kanbanRepo.GetStatuses(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	total := inboxTotal
+	for _, status := range statuses {
+		if status.Status != "inbox" {
+			total--
+		}
+	}
+	if total < matched {
+		total = matched
+	}
+	return column, total, nil
+}
@@ -0,0 +1,127 @@
+package usecase
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+// Moves, snoozes and the snooze checker run at once from handlers and the background
+// loop; run with -race to check the Kanban cache and the local repository
+func TestKanbanMoveAndSnoozeConcurrently(t *testing.T) {
+	local := &authdomain.User{ID: "local", Email: "local@example.com", Provider: "email"}
+	uc, _ := newTestUsecase(t, nil, gmailUser("u1"), gmailUser("u2"), local)
+
+	inbox, _, _ := uc.emailRepo.GetEmailsByMailbox("inbox", 1, 0)
+	if len(inbox) == 0 {
+		t.Fatal("the local repository has no inbox emails")
+	}
+	localID := inbox[0].ID
+
+	// Both Gmail users have an email with the same provider ID
+	if err := uc.UpdateKanbanStatus("u2", "m1", "done"); err != nil {
+		t.Fatal(err)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			for i := range 50 {
+				status := []string{"todo", "done", "inbox"}[i%3]
+				uc.UpdateKanbanStatus("u1", "m1", status)
+				uc.UpdateKanbanStatus("local", localID, status)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				uc.SnoozeEmail("u1", "m1", past)
+				uc.SnoozeEmail("local", localID, past)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				uc.checkSnoozedEmails()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				uc.getKanbanStatus("u1", "m1")
+				uc.emailRepo.GetEmailsByStatus("snoozed", 10, 0)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The snooze checker wakes u1's email, never the other user's with the same ID
+	uc.checkSnoozedEmails()
+	if status, _ := uc.getKanbanStatus("u1", "m1"); status == "snoozed" {
+		t.Error("u1's email is still snoozed after the checker ran")
+	}
+	if status, _ := uc.getKanbanStatus("u2", "m1"); status != "done" {
+		t.Errorf("u2's email is %q, want done; another user's moves leaked into it", status)
+	}
+}
+
+func TestBatchUpdateKanbanStatus(t *testing.T) {
+	uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+	for _, id := range []string{"m1", "m2", "m3"} {
+		deps.provider.emails[id] = &emaildomain.Email{ID: id}
+	}
+
+	if err := uc.BatchUpdateKanbanStatus("u1", []string{"m1", "m2", "m3"}, "done"); err != nil {
+		t.Fatalf("BatchUpdateKanbanStatus() error = %v", err)
+	}
+	for _, id := range []string{"m1", "m2", "m3"} {
+		if status, _ := uc.getKanbanStatus("u1", id); status != "done" {
+			t.Errorf("%s is %q, want done", id, status)
+		}
+		if stored := deps.kanban.statuses[kanbanKey("u1", id)]; stored == nil || stored.Status != "done" {
+			t.Errorf("%s wasn't saved as done: %+v", id, stored)
+		}
+	}
+	if deps.provider.bodyFetches != 0 {
+		t.Errorf("checking ownership downloaded %d bodies, want none", deps.provider.bodyFetches)
+	}
+}
+
+func TestBatchUpdateKanbanStatusRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		ids     []string
+		status  string
+		wantErr error
+	}{
+		{"invalid status", []string{"m1"}, "archived", ErrInvalidKanbanStatus},
+		{"no ids", nil, "done", ErrNoEmailIDs},
+		{"too many ids", make([]string, maxKanbanBatchSize+1), "done", ErrTooManyEmailIDs},
+		// Another user's email, or one that doesn't exist, fails the whole batch
+		{"unknown id", []string{"m1", "theirs"}, "done", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+			deps.provider.emails["m1"] = &emaildomain.Email{ID: "m1"}
+
+			err := uc.BatchUpdateKanbanStatus("u1", tt.ids, tt.status)
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Fatalf("BatchUpdateKanbanStatus() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(deps.kanban.statuses) != 0 {
+				t.Errorf("saved %d statuses, want none", len(deps.kanban.statuses))
+			}
+			if _, ok := uc.getKanbanStatus("u1", "m1"); ok {
+				t.Error("m1 was moved although the batch failed")
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"log"
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+// mailboxCacheTTL keeps sidebar loads off the provider while still picking up mail
+// that arrives without a push
+const mailboxCacheTTL = 30 * time.Second
+
+type cachedMailboxes struct {
+	mailboxes []*emaildomain.Mailbox
+	expiresAt time.Time
+}
+
+// cachedMailboxes returns a copy of the user's cached mailbox list, so callers can
+// annotate it freely
+func (u *emailUsecase) cachedMailboxes(userID string) ([]*emaildomain.Mailbox, bool) {
+	u.mailboxMu.Lock()
+	defer u.mailboxMu.Unlock()
+	cached, ok := u.mailboxCache[userID]
+	if !ok || time.Now().After(cached.expiresAt) {
+		return nil, false
+	}
+	return cloneMailboxes(cached.mailboxes), true
+}
+
+func (u *emailUsecase) mailboxGeneration(userID string) uint64 {
+	u.mailboxMu.Lock()
+	defer u.mailboxMu.Unlock()
+	return u.mailboxGen[userID]
+}
+
+// cacheMailboxes stores a list fetched at generation gen, unless the cache was
+// invalidated while it was being fetched
+func (u *emailUsecase) cacheMailboxes(userID string, gen uint64, mailboxes []*emaildomain.Mailbox) {
+	u.mailboxMu.Lock()
+	defer u.mailboxMu.Unlock()
+	if u.mailboxGen[userID] != gen {
+		return
+	}
+	u.mailboxCache[userID] = &cachedMailboxes{
+		mailboxes: cloneMailboxes(mailboxes),
+		expiresAt: time.Now().Add(mailboxCacheTTL),
+	}
+}
+
+// invalidateMailboxes drops the user's cached mailbox list after an action that
+// changes unread counts
+func (u *emailUsecase) invalidateMailboxes(userID string) {
+	u.mailboxMu.Lock()
+	defer u.mailboxMu.Unlock()
+	delete(u.mailboxCache, userID)
+	u.mailboxGen[userID]++
+}
+
+func cloneMailboxes(mailboxes []*emaildomain.Mailbox) []*emaildomain.Mailbox {
+	clones := make([]*emaildomain.Mailbox, len(mailboxes))
+	for i, mailbox := range mailboxes {
+		clone := *mailbox
+		clones[i] = &clone
+	}
+	return clones
+}
+
+// publishMailboxCounts pushes the user's unread count per mailbox as a "mailbox_counts"
+// event, so sidebar badges follow read/unread and move actions without a refetch. It
+// runs in the background so the action doesn't wait on the provider.
+func (u *emailUsecase) publishMailboxCounts(userID string) {
+	u.invalidateMailboxes(userID)
+	if u.notify == nil {
+		return
+	}
+
+	go func() {
+		mailboxes, err := u.getAllMailboxes(userID)
+		if err != nil {
+			log.Printf("Failed to refresh mailbox counts for user %s: %v", userID, err)
+			return
+		}
+
+		counts := make(map[string]int, len(mailboxes))
+		for _, mailbox := range mailboxes {
+			counts[mailbox.ID] = mailbox.Count
+		}
+		u.notify(userID, "mailbox_counts", map[string]interface{}{"counts": counts})
+	}()
+}
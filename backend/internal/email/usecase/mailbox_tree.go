@@ -0,0 +1,68 @@
+package usecase
+
+import (
+	"strings"
+
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+// splitMailboxPath splits a folder name on its hierarchy delimiter, ignoring empty segments
+func splitMailboxPath(name, delimiter string) []string {
+	if delimiter == "" {
+		return []string{name}
+	}
+	var segments []string
+	for _, segment := range strings.Split(name, delimiter) {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	if len(segments) == 0 {
+		return []string{name}
+	}
+	return segments
+}
+
+// applyHierarchy sets ParentID and Depth on each mailbox from its name and delimiter.
+// When the parent folder isn't in the list (e.g. a \Noselect root), its path is used as ParentID.
+func applyHierarchy(mailboxes []*emaildomain.Mailbox) {
+	idByName := make(map[string]string, len(mailboxes))
+	for _, mb := range mailboxes {
+		idByName[mb.Name] = mb.ID
+	}
+
+	for _, mb := range mailboxes {
+		segments := splitMailboxPath(mb.Name, mb.Delimiter)
+		mb.Depth = len(segments) - 1
+		mb.ParentID = ""
+		if mb.Depth == 0 {
+			continue
+		}
+		parentName := strings.Join(segments[:len(segments)-1], mb.Delimiter)
+		if id, ok := idByName[parentName]; ok {
+			mb.ParentID = id
+		} else {
+			mb.ParentID = parentName
+		}
+	}
+}
+
+// buildMailboxTree nests mailboxes under their parents. Mailboxes whose parent is
+// missing from the list are returned at the root.
+func buildMailboxTree(mailboxes []*emaildomain.Mailbox) []*emaildomain.Mailbox {
+	byID := make(map[string]*emaildomain.Mailbox, len(mailboxes))
+	for _, mb := range mailboxes {
+		mb.Children = nil
+		byID[mb.ID] = mb
+	}
+
+	var roots []*emaildomain.Mailbox
+	for _, mb := range mailboxes {
+		if parent, ok := byID[mb.ParentID]; ok && mb.ParentID != "" && parent != mb {
+			parent.Children = append(parent.Children, mb)
+		} else {
+			roots = append(roots, mb)
+		}
+	}
+	return roots
+}
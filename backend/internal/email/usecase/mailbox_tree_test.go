@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"slices"
+	"testing"
+
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+func TestSplitMailboxPath(t *testing.T) {
+	tests := []struct {
+		name, delimiter string
+		want            []string
+	}{
+		{"[Gmail]/Sent Mail", "/", []string{"[Gmail]", "Sent Mail"}},
+		{"Work/ProjectA/Q1", "/", []string{"Work", "ProjectA", "Q1"}},
+		{"INBOX.Archive.2024", ".", []string{"INBOX", "Archive", "2024"}},
+		{"/Work//ProjectA/", "/", []string{"Work", "ProjectA"}},
+		{"Notes/Ideas", "", []string{"Notes/Ideas"}},
+		{"/", "/", []string{"/"}},
+	}
+	for _, tt := range tests {
+		if got := splitMailboxPath(tt.name, tt.delimiter); !slices.Equal(got, tt.want) {
+			t.Errorf("splitMailboxPath(%q, %q) = %q, want %q", tt.name, tt.delimiter, got, tt.want)
+		}
+	}
+}
+
+func TestApplyHierarchy(t *testing.T) {
+	mailboxes := []*emaildomain.Mailbox{
+		{ID: "INBOX", Name: "INBOX", Delimiter: "."},
+		{ID: "INBOX.Receipts", Name: "INBOX.Receipts", Delimiter: "."},
+		// The [Gmail] root is \Noselect, so it isn't listed
+		{ID: "SENT", Name: "[Gmail]/Sent Mail", Delimiter: "/"},
+		{ID: "Label_1", Name: "Work", Delimiter: "/"},
+		{ID: "Label_2", Name: "Work/ProjectA", Delimiter: "/"},
+		{ID: "Label_3", Name: "Work/ProjectA/Q1", Delimiter: "/"},
+	}
+	applyHierarchy(mailboxes)
+
+	want := map[string]struct {
+		parent string
+		depth  int
+	}{
+		"INBOX":          {"", 0},
+		"INBOX.Receipts": {"INBOX", 1},
+		"SENT":           {"[Gmail]", 1},
+		"Label_1":        {"", 0},
+		"Label_2":        {"Label_1", 1},
+		"Label_3":        {"Label_2", 2},
+	}
+	for _, mb := range mailboxes {
+		if w := want[mb.ID]; mb.ParentID != w.parent || mb.Depth != w.depth {
+			t.Errorf("%s: (ParentID, Depth) = (%q, %d), want (%q, %d)", mb.ID, mb.ParentID, mb.Depth, w.parent, w.depth)
+		}
+	}
+}
+
+func TestGetMailboxTree(t *testing.T) {
+	uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+	deps.provider.mailboxes = []*emaildomain.Mailbox{
+		{ID: "INBOX", Name: "INBOX", Delimiter: "/"},
+		{ID: "Label_2", Name: "Work/ProjectA", Delimiter: "/"},
+		{ID: "Label_1", Name: "Work", Delimiter: "/"},
+		{ID: "Label_3", Name: "Personal/Travel", Delimiter: "/"},
+	}
+
+	roots, err := uc.GetMailboxTree("u1")
+	if err != nil {
+		t.Fatalf("GetMailboxTree() error = %v", err)
+	}
+	var ids []string
+	for _, root := range roots {
+		ids = append(ids, root.ID)
+	}
+	// Personal isn't a label of its own, so Personal/Travel stays at the root
+	if !slices.Equal(ids, []string{"INBOX", "Label_1", "Label_3"}) {
+		t.Errorf("roots = %v", ids)
+	}
+	work := roots[1]
+	if len(work.Children) != 1 || work.Children[0].ID != "Label_2" {
+		t.Errorf("Work children = %v, want ProjectA", work.Children)
+	}
+
+	// The flat list stays flat
+	flat, _ := uc.GetAllMailboxes("u1")
+	if len(flat) != 4 {
+		t.Errorf("flat list has %d mailboxes, want 4", len(flat))
+	}
+}
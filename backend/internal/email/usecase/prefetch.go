@@ -0,0 +1,178 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+const (
+	prefetchCacheTTL   = 2 * time.Minute
+	prefetchMaxPending = 500 // Cap on cached bodies across all users
+	prefetchTimeout    = 30 * time.Second
+)
+
+type cachedEmail struct {
+	email     *emaildomain.Email
+	expiresAt time.Time
+}
+
+// prefetcher holds bodies fetched ahead of time. A cached body is served once, the next
+// open goes back to the provider so flags never stay stale for long.
+type prefetcher struct {
+	mu      sync.Mutex
+	cache   map[string]*cachedEmail
+	batches map[string]*prefetchBatch // userID -> running batch
+	sem     chan struct{}             // Bounds concurrent provider fetches across users
+}
+
+type prefetchBatch struct {
+	cancel context.CancelFunc
+}
+
+func newPrefetcher(workers int) *prefetcher {
+	if workers <= 0 {
+		workers = 1
+	}
+	return &prefetcher{
+		cache:   make(map[string]*cachedEmail),
+		batches: make(map[string]*prefetchBatch),
+		sem:     make(chan struct{}, workers),
+	}
+}
+
+func prefetchKey(userID, emailID string) string {
+	return userID + ":" + emailID
+}
+
+// take returns and removes a cached body
+func (p *prefetcher) take(userID, emailID string) *emaildomain.Email {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := prefetchKey(userID, emailID)
+	cached, ok := p.cache[key]
+	if !ok {
+		return nil
+	}
+	delete(p.cache, key)
+	if time.Now().After(cached.expiresAt) {
+		return nil
+	}
+	return cached.email
+}
+
+func (p *prefetcher) has(userID, emailID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cached, ok := p.cache[prefetchKey(userID, emailID)]
+	return ok && time.Now().Before(cached.expiresAt)
+}
+
+func (p *prefetcher) put(userID, emailID string, email *emaildomain.Email) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if len(p.cache) >= prefetchMaxPending {
+		for key, cached := range p.cache {
+			if now.After(cached.expiresAt) {
+				delete(p.cache, key)
+			}
+		}
+		if len(p.cache) >= prefetchMaxPending {
+			return
+		}
+	}
+	p.cache[prefetchKey(userID, emailID)] = &cachedEmail{email: email, expiresAt: now.Add(prefetchCacheTTL)}
+}
+
+func (p *prefetcher) invalidate(userID, emailID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.cache, prefetchKey(userID, emailID))
+}
+
+// start registers a new batch for the user, cancelling the previous one since the user
+// has scrolled past it
+func (p *prefetcher) start(userID string) (context.Context, func()) {
+	ctx, cancel := context.WithTimeout(context.Background(), prefetchTimeout)
+	batch := &prefetchBatch{cancel: cancel}
+
+	p.mu.Lock()
+	if prev, ok := p.batches[userID]; ok {
+		prev.cancel()
+	}
+	p.batches[userID] = batch
+	p.mu.Unlock()
+
+	done := func() {
+		cancel()
+		p.mu.Lock()
+		// Only clear our own entry, a newer batch may have replaced it
+		if p.batches[userID] == batch {
+			delete(p.batches, userID)
+		}
+		p.mu.Unlock()
+	}
+	return ctx, done
+}
+
+// PrefetchEmails fetches the bodies of the given emails in the background so opening
+// them is instant. It returns how many were queued.
+func (u *emailUsecase) PrefetchEmails(userID string, emailIDs []string) int {
+	limit := u.config.PrefetchMaxEmails
+	var queue []string
+	seen := make(map[string]bool)
+	for _, id := range emailIDs {
+		if len(queue) >= limit {
+			break
+		}
+		if id == "" || seen[id] || u.prefetch.has(userID, id) {
+			continue
+		}
+		seen[id] = true
+		queue = append(queue, id)
+	}
+	if len(queue) == 0 {
+		return 0
+	}
+
+	ctx, done := u.prefetch.start(userID)
+	go func() {
+		defer done()
+		var wg sync.WaitGroup
+		for _, id := range queue {
+			select {
+			case <-ctx.Done():
+				wg.Wait()
+				return
+			case u.prefetch.sem <- struct{}{}:
+			}
+
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				defer func() { <-u.prefetch.sem }()
+				if ctx.Err() != nil {
+					return
+				}
+
+				email, err := u.getEmailByID(userID, id)
+				if err != nil || email == nil {
+					log.Printf("Prefetch of email %s failed: %v", id, err)
+					return
+				}
+				if ctx.Err() == nil {
+					u.prefetch.put(userID, id, email)
+				}
+			}(id)
+		}
+		wg.Wait()
+	}()
+
+	return len(queue)
+}
@@ -0,0 +1,139 @@
+package usecase
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/pkg/config"
+)
+
+// waitPrefetched waits until the background prefetch has cached every id
+func waitPrefetched(t *testing.T, uc *emailUsecase, userID string, ids ...string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for _, id := range ids {
+		for !uc.prefetch.has(userID, id) {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s wasn't prefetched in time", id)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
+
+func TestPrefetchedEmailsServedFromCache(t *testing.T) {
+	uc, deps := newTestUsecase(t, &config.Config{PrefetchWorkers: 2, PrefetchMaxEmails: 10}, gmailUser("u1"))
+	for _, id := range []string{"m1", "m2", "m3"} {
+		deps.provider.emails[id] = &emaildomain.Email{ID: id, Body: "Body of " + id}
+	}
+
+	if queued := uc.PrefetchEmails("u1", []string{"m1", "m2", "m2", "", "m3"}); queued != 3 {
+		t.Fatalf("PrefetchEmails() queued %d, want 3", queued)
+	}
+	waitPrefetched(t, uc, "u1", "m1", "m2", "m3")
+	if deps.provider.bodyFetches != 3 {
+		t.Fatalf("prefetch made %d fetches, want 3", deps.provider.bodyFetches)
+	}
+
+	// Already cached emails aren't fetched again
+	if queued := uc.PrefetchEmails("u1", []string{"m2"}); queued != 0 {
+		t.Errorf("PrefetchEmails() queued %d cached emails, want 0", queued)
+	}
+
+	for _, id := range []string{"m1", "m2", "m3"} {
+		email, err := uc.GetEmailByID("u1", id)
+		if err != nil || email.Body != "Body of "+id {
+			t.Fatalf("GetEmailByID(%s) = %v, %v", id, email, err)
+		}
+	}
+	if deps.provider.bodyFetches != 3 {
+		t.Errorf("opening prefetched emails made %d more fetches, want none", deps.provider.bodyFetches-3)
+	}
+
+	// A cached body is served once, the next open goes back to the provider
+	uc.GetEmailByID("u1", "m1")
+	if deps.provider.bodyFetches != 4 {
+		t.Errorf("reopening made %d fetches, want 1", deps.provider.bodyFetches-3)
+	}
+}
+
+func TestPrefetchIsBoundedAndPerUser(t *testing.T) {
+	uc, deps := newTestUsecase(t, &config.Config{PrefetchWorkers: 2, PrefetchMaxEmails: 4}, gmailUser("u1"), gmailUser("u2"))
+	deps.provider.fetchDelay = 20 * time.Millisecond
+	var ids []string
+	for i := range 6 {
+		id := fmt.Sprintf("m%d", i)
+		ids = append(ids, id)
+		deps.provider.emails[id] = &emaildomain.Email{ID: id}
+	}
+
+	if queued := uc.PrefetchEmails("u1", ids); queued != 4 {
+		t.Fatalf("PrefetchEmails() queued %d, want the limit of 4", queued)
+	}
+	waitPrefetched(t, uc, "u1", ids[:4]...)
+
+	deps.provider.mu.Lock()
+	maxAtOnce := deps.provider.fetchMaxAtOnce
+	deps.provider.mu.Unlock()
+	if maxAtOnce > 2 {
+		t.Errorf("%d fetches ran at once, want at most 2 workers", maxAtOnce)
+	}
+	if uc.prefetch.has("u1", "m4") {
+		t.Error("m4 was prefetched past the limit")
+	}
+	if uc.prefetch.has("u2", "m0") {
+		t.Error("u1's prefetch was cached for u2")
+	}
+}
+
+func TestPrefetchInvalidatedByChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(uc *emailUsecase) error
+	}{
+		{"marked read", func(uc *emailUsecase) error { return uc.MarkEmailAsRead("u1", "m1") }},
+		{"marked unread", func(uc *emailUsecase) error { return uc.MarkEmailAsUnread("u1", "m1") }},
+		{"archived", func(uc *emailUsecase) error { return uc.ArchiveEmail("u1", "m1") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc, deps := newTestUsecase(t, &config.Config{PrefetchWorkers: 1, PrefetchMaxEmails: 10}, gmailUser("u1"))
+			deps.provider.emails["m1"] = &emaildomain.Email{ID: "m1", IsRead: true}
+
+			uc.PrefetchEmails("u1", []string{"m1"})
+			waitPrefetched(t, uc, "u1", "m1")
+
+			if err := tt.change(uc); err != nil {
+				t.Fatal(err)
+			}
+			if uc.prefetch.has("u1", "m1") {
+				t.Error("the prefetched copy with the old flags is still cached")
+			}
+		})
+	}
+}
+
+func TestPrefetchNewBatchCancelsPrevious(t *testing.T) {
+	p := newPrefetcher(1)
+	first, doneFirst := p.start("u1")
+	second, doneSecond := p.start("u1")
+	defer doneSecond()
+
+	if first.Err() == nil {
+		t.Error("the first batch is still running after the user scrolled on")
+	}
+	if second.Err() != nil {
+		t.Error("the new batch was cancelled")
+	}
+
+	// The finished first batch mustn't unregister the second
+	doneFirst()
+	p.mu.Lock()
+	_, ok := p.batches["u1"]
+	p.mu.Unlock()
+	if !ok {
+		t.Error("finishing the old batch removed the new one")
+	}
+}
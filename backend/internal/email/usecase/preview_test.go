@@ -0,0 +1,92 @@
+package usecase
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+func TestPreviewEmailMatchesSent(t *testing.T) {
+	original := &emaildomain.Email{
+		ID:         "m1",
+		ThreadID:   "t1",
+		From:       "Alice <alice@example.com>",
+		To:         []string{"u1@example.com", "bob@example.com"},
+		Cc:         []string{"carol@example.com"},
+		Subject:    "Lunch",
+		Body:       "<p>Lunch at noon?</p>",
+		IsHTML:     true,
+		ReceivedAt: time.Date(2024, time.March, 5, 14, 30, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name                                 string
+		replyToID                            string
+		fromName, to, cc, bcc, subject, body string
+		plainText, replyAll                  bool
+	}{
+		{name: "new message", to: "dave@example.com", cc: "erin@example.com", bcc: "boss@example.com", subject: "Report", body: "<p>Attached</p>"},
+		{name: "custom display name", fromName: " Support\r\nBcc: x@evil.test ", to: "dave@example.com", subject: "Hi", body: "Hi"},
+		{name: "reply", replyToID: "m1", body: "<p>Sounds good</p>"},
+		{name: "plain text reply all", replyToID: "m1", body: "Sounds good\nSee you", plainText: true, replyAll: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := gmailUser("u1")
+			user.Email = "u1@example.com"
+			user.Signature = "Test User\nACME Corp"
+			uc, deps := newTestUsecase(t, nil, user)
+			deps.provider.emails["m1"] = original
+			deps.provider.threads = map[string][]*emaildomain.Email{"t1": {original}}
+
+			preview, err := uc.PreviewEmail("u1", tt.replyToID, tt.fromName, tt.to, tt.cc, tt.bcc, tt.subject, tt.body, tt.plainText, tt.replyAll)
+			if err != nil {
+				t.Fatalf("PreviewEmail() error = %v", err)
+			}
+
+			if tt.replyToID != "" {
+				_, err = uc.ReplyEmail("u1", tt.replyToID, tt.fromName, tt.body, tt.plainText, tt.replyAll, nil)
+			} else {
+				err = uc.SendEmail("u1", tt.fromName, tt.to, tt.cc, tt.bcc, tt.subject, tt.body, nil)
+			}
+			if err != nil {
+				t.Fatalf("sending error = %v", err)
+			}
+			if len(deps.provider.sent) != 1 {
+				t.Fatalf("sent %d emails, want 1", len(deps.provider.sent))
+			}
+			sent := deps.provider.sent[0]
+			got := emaildomain.ComposedEmail{FromName: sent.fromName, From: preview.From, To: sent.to, Cc: sent.cc, Bcc: sent.bcc, Subject: sent.subject, Body: sent.body}
+			if *preview != got {
+				t.Errorf("preview =\n%+v\nsent =\n%+v", *preview, got)
+			}
+			if preview.From != "u1@example.com" {
+				t.Errorf("preview From = %q, want the account address", preview.From)
+			}
+			// Replies are composed, not passed through
+			if tt.replyToID != "" && (preview.Subject != "Re: Lunch" || !strings.Contains(preview.Body, "ACME Corp") || !strings.Contains(preview.Body, "Lunch at noon?")) {
+				t.Errorf("reply preview = %+v, want the subject, signature and quote added", *preview)
+			}
+			if tt.replyAll && (!strings.Contains(preview.Cc, "bob@example.com") || !strings.Contains(preview.Cc, "carol@example.com")) {
+				t.Errorf("reply all Cc = %q, want the other recipients", preview.Cc)
+			}
+		})
+	}
+}
+
+func TestPreviewEmailDoesNotSend(t *testing.T) {
+	uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+	deps.provider.emails["m1"] = &emaildomain.Email{ID: "m1", From: "alice@example.com", Subject: "Lunch"}
+
+	if _, err := uc.PreviewEmail("u1", "", "", "dave@example.com", "", "", "Hi", "Hi", false, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.PreviewEmail("u1", "m1", "", "", "", "", "", "Sure", false, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(deps.provider.sent) != 0 {
+		t.Errorf("previewing sent %d emails", len(deps.provider.sent))
+	}
+}
@@ -0,0 +1,257 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+	"mime/multipart"
+	"strings"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+	emaildto "ga03-backend/internal/email/dto"
+	"ga03-backend/pkg/utils/mailutil"
+)
+
+// ReplyEmail sends a reply to the sender of emailID, and to its other recipients when
+// replyAll is set. The original is quoted according to the user's reply settings and
+// the reply carries threading headers so clients keep it in the same conversation.
+// It reports whether the original was archived afterwards.
+func (u *emailUsecase) ReplyEmail(userID, emailID, fromName, body string, plainText, replyAll bool, files []*multipart.FileHeader) (bool, error) {
+	user, original, err := u.loadReplyContext(userID, emailID)
+	if err != nil {
+		return false, err
+	}
+
+	msg, err := u.composeReplyEmail(user, original, fromName, body, plainText, replyAll)
+	if err != nil {
+		return false, err
+	}
+
+	if err := u.sendComposed(user, msg, files, replyHeaders(original)); err != nil {
+		return false, err
+	}
+
+	if !user.ArchiveOnReply {
+		return false, nil
+	}
+	// The reply already went out, a failed archive shouldn't report the send as failed
+	if err := u.ArchiveEmail(userID, emailID); err != nil {
+		log.Printf("Failed to archive email %s after reply: %v", emailID, err)
+		return false, nil
+	}
+	return true, nil
+}
+
+// composeReplyEmail builds the reply ReplyEmail sends: the body with signature and
+// quote, a "Re:" subject and the recipients picked by replyRecipients
+func (u *emailUsecase) composeReplyEmail(user *authdomain.User, original *emaildomain.Email, fromName, body string, plainText, replyAll bool) (*emaildomain.ComposedEmail, error) {
+	subject := original.Subject
+	if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+		subject = "Re: " + subject
+	}
+
+	to, cc := replyRecipients(user.Email, original, replyAll)
+	if to == "" {
+		return nil, fmt.Errorf("original email has no sender to reply to")
+	}
+
+	return composeEmail(user, fromName, to, cc, "", subject, u.composeReply(user, original, body, plainText)), nil
+}
+
+// replyHeaders builds In-Reply-To/References from the original's Message-ID
+func replyHeaders(original *emaildomain.Email) *emaildomain.ReplyHeaders {
+	reply := &emaildomain.ReplyHeaders{ThreadID: original.ThreadID}
+	if original.MessageID != "" {
+		reply.InReplyTo = original.MessageID
+		reply.References = strings.TrimSpace(original.References + " " + original.MessageID)
+	}
+	return reply
+}
+
+// replyRecipients addresses the reply to the original sender. Replying to one's own
+// message goes to its recipients instead. With replyAll the other recipients are copied,
+// leaving out the user and anyone already in To.
+func replyRecipients(self string, original *emaildomain.Email, replyAll bool) (string, string) {
+	selfKey := mailutil.NormalizeAddress(self)
+
+	toList := mailutil.ParseAddresses([]string{original.From})
+	fromSelf := len(toList) > 0 && mailutil.NormalizeAddress(toList[0].Address) == selfKey
+	if fromSelf {
+		toList = mailutil.ParseAddresses(original.To)
+	}
+
+	seen := make(map[string]bool)
+	var to, cc []string
+	for _, addr := range toList {
+		key := mailutil.NormalizeAddress(addr.Address)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		to = append(to, addr.String())
+	}
+	seen[selfKey] = true
+
+	if replyAll {
+		others := append(append([]string{}, original.To...), original.Cc...)
+		if fromSelf {
+			others = original.Cc
+		}
+		for _, addr := range mailutil.ParseAddresses(others) {
+			key := mailutil.NormalizeAddress(addr.Address)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			cc = append(cc, addr.String())
+		}
+	}
+
+	return strings.Join(to, ", "), strings.Join(cc, ", ")
+}
+
+// PreviewReply returns the body ReplyEmail would send, without sending it
+func (u *emailUsecase) PreviewReply(userID, emailID, body string, plainText bool) (string, error) {
+	user, original, err := u.loadReplyContext(userID, emailID)
+	if err != nil {
+		return "", err
+	}
+	return u.composeReply(user, original, body, plainText), nil
+}
+
+// PreviewEmail returns the message SendEmail, or ReplyEmail when replyToID is set,
+// would send for the same input, without sending it
+func (u *emailUsecase) PreviewEmail(userID, replyToID, fromName, to, cc, bcc, subject, body string, plainText, replyAll bool) (*emaildomain.ComposedEmail, error) {
+	if replyToID != "" {
+		user, original, err := u.loadReplyContext(userID, replyToID)
+		if err != nil {
+			return nil, err
+		}
+		return u.composeReplyEmail(user, original, fromName, body, plainText, replyAll)
+	}
+
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, authdomain.ErrUserNotFound
+	}
+	return composeEmail(user, fromName, to, cc, bcc, subject, body), nil
+}
+
+func (u *emailUsecase) loadReplyContext(userID, emailID string) (*authdomain.User, *emaildomain.Email, error) {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, authdomain.ErrUserNotFound
+	}
+
+	original, err := u.GetEmailByID(userID, emailID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load original email: %w", err)
+	}
+	if original == nil {
+		return nil, nil, emaildomain.ErrEmailNotFound
+	}
+	return user, original, nil
+}
+
+// composeReply adds the user's signature and the quoted original to a reply body
+func (u *emailUsecase) composeReply(user *authdomain.User, original *emaildomain.Email, body string, plainText bool) string {
+	if u.shouldSign(user, original) {
+		sep := "<br>\n"
+		if plainText {
+			sep = "\n\n"
+		}
+		body = body + sep + mailutil.FormatSignature(user.Signature, !plainText)
+	}
+
+	quotedBody := original.Body
+	if user.StripQuotedSignatures {
+		quotedBody = mailutil.StripSignatures(quotedBody, original.IsHTML)
+	}
+
+	// From already holds the full "Name <address>" header value
+	quoted := mailutil.QuotedMessage{
+		From:   original.From,
+		Date:   original.ReceivedAt,
+		Body:   quotedBody,
+		IsHTML: original.IsHTML,
+	}
+	opts := mailutil.ReplyOptions{
+		TopPost:         user.ReplyTopPost,
+		IncludeOriginal: !user.ReplyOmitOriginal,
+	}
+
+	// Outgoing mail is sent as text/html, so plain text replies are converted after quoting
+	replyBody := mailutil.BuildReplyBody(body, !plainText, quoted, opts)
+	if plainText {
+		replyBody = mailutil.TextToHTML(replyBody)
+	}
+	return replyBody
+}
+
+// shouldSign reports whether a reply gets the user's signature. With
+// SignatureFirstInThreadOnly it is left off once the user has already written in the thread.
+func (u *emailUsecase) shouldSign(user *authdomain.User, original *emaildomain.Email) bool {
+	if strings.TrimSpace(user.Signature) == "" {
+		return false
+	}
+	if !user.SignatureFirstInThreadOnly {
+		return true
+	}
+
+	threadID := original.ThreadID
+	if threadID == "" {
+		threadID = original.ID
+	}
+	thread, err := u.GetThread(user.ID, threadID)
+	if err != nil {
+		// Better a repeated signature than a missing one
+		return true
+	}
+
+	self := mailutil.NormalizeAddress(user.Email)
+	for _, email := range thread {
+		for _, addr := range mailutil.ParseAddresses([]string{email.From}) {
+			if mailutil.NormalizeAddress(addr.Address) == self {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// UpdateReplySettings changes the user's reply defaults; nil fields are left unchanged
+func (u *emailUsecase) UpdateReplySettings(userID string, req *emaildto.ReplySettingsRequest) error {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return authdomain.ErrUserNotFound
+	}
+
+	if req.TopPost != nil {
+		user.ReplyTopPost = *req.TopPost
+	}
+	if req.IncludeOriginal != nil {
+		user.ReplyOmitOriginal = !*req.IncludeOriginal
+	}
+	if req.ArchiveOnReply != nil {
+		user.ArchiveOnReply = *req.ArchiveOnReply
+	}
+	if req.Signature != nil {
+		user.Signature = *req.Signature
+	}
+	if req.SignatureFirstInThreadOnly != nil {
+		user.SignatureFirstInThreadOnly = *req.SignatureFirstInThreadOnly
+	}
+	if req.StripQuotedSignatures != nil {
+		user.StripQuotedSignatures = *req.StripQuotedSignatures
+	}
+	return u.userRepo.Update(user)
+}
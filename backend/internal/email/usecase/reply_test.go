@@ -0,0 +1,199 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+	emaildto "ga03-backend/internal/email/dto"
+)
+
+func TestReplyEmailQuotesOriginal(t *testing.T) {
+	topPost, omit := true, false
+	tests := []struct {
+		name      string
+		settings  *emaildto.ReplySettingsRequest
+		plainText bool
+		check     func(t *testing.T, body string)
+	}{
+		{
+			"html bottom post by default", nil, false,
+			func(t *testing.T, body string) {
+				quote := strings.Index(body, "<blockquote")
+				if !strings.HasPrefix(body, `<div class="quote-attribution">On Tue, Mar 5, 2024 at 2:30 PM, Alice &lt;alice@example.com&gt; wrote:</div>`) || quote < 0 || quote > strings.Index(body, "Sounds good") {
+					t.Errorf("body = %q, want the attributed quote above the reply", body)
+				}
+			},
+		},
+		{
+			"plain text top post", &emaildto.ReplySettingsRequest{TopPost: &topPost}, true,
+			func(t *testing.T, body string) {
+				// Plain replies are quoted with ">" and then sent as HTML
+				if !strings.HasPrefix(body, "Sounds good<br>\n<br>\nOn Tue, Mar 5, 2024") || !strings.Contains(body, "&gt; Lunch at noon?") {
+					t.Errorf("body = %q, want the reply above a >-quoted original", body)
+				}
+			},
+		},
+		{
+			"original left out", &emaildto.ReplySettingsRequest{IncludeOriginal: &omit}, false,
+			func(t *testing.T, body string) {
+				if body != "Sounds good" {
+					t.Errorf("body = %q, want only the reply", body)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+			deps.provider.emails["m1"] = &emaildomain.Email{
+				ID:         "m1",
+				From:       "Alice <alice@example.com>",
+				Subject:    "Lunch",
+				Body:       "Lunch at noon?",
+				ReceivedAt: time.Date(2024, time.March, 5, 14, 30, 0, 0, time.UTC),
+			}
+			if tt.settings != nil {
+				if err := uc.UpdateReplySettings("u1", tt.settings); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, err := uc.ReplyEmail("u1", "m1", "", "Sounds good", tt.plainText, false, nil); err != nil {
+				t.Fatalf("ReplyEmail() error = %v", err)
+			}
+			if len(deps.provider.sent) != 1 {
+				t.Fatalf("sent %d emails, want 1", len(deps.provider.sent))
+			}
+			sent := deps.provider.sent[0]
+			if sent.to != `"Alice" <alice@example.com>` || sent.subject != "Re: Lunch" {
+				t.Errorf("sent to %q with subject %q, want a reply to Alice", sent.to, sent.subject)
+			}
+			tt.check(t, sent.body)
+		})
+	}
+}
+
+func TestReplyEmailArchiveOnReply(t *testing.T) {
+	enabled, disabled := true, false
+	tests := []struct {
+		name         string
+		setting      *bool
+		archiveErr   error
+		wantArchived bool
+	}{
+		{"enabled", &enabled, nil, true},
+		{"disabled", &disabled, nil, false},
+		{"off by default", nil, nil, false},
+		// The reply already went out, so a failed archive isn't a failed send
+		{"archive fails", &enabled, errors.New("googleapi: Error 500"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+			deps.provider.emails["m1"] = &emaildomain.Email{ID: "m1", From: "alice@example.com", Subject: "Lunch"}
+			deps.provider.archiveErr = tt.archiveErr
+			if tt.setting != nil {
+				if err := uc.UpdateReplySettings("u1", &emaildto.ReplySettingsRequest{ArchiveOnReply: tt.setting}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			archived, err := uc.ReplyEmail("u1", "m1", "", "Sounds good", false, false, nil)
+			if err != nil {
+				t.Fatalf("ReplyEmail() error = %v", err)
+			}
+			if len(deps.provider.sent) != 1 {
+				t.Fatalf("sent %d emails, want 1", len(deps.provider.sent))
+			}
+			if archived != tt.wantArchived {
+				t.Errorf("ReplyEmail() archived = %v, want %v", archived, tt.wantArchived)
+			}
+			if got := slices.Equal(deps.provider.archived, []string{"m1"}); got != tt.wantArchived {
+				t.Errorf("provider archived %v, want the original archived: %v", deps.provider.archived, tt.wantArchived)
+			}
+		})
+	}
+}
+
+func TestReplyEmailFailedSendDoesNotArchive(t *testing.T) {
+	user := gmailUser("u1")
+	user.ArchiveOnReply = true
+	uc, deps := newTestUsecase(t, nil, user)
+	// No sender to reply to, so nothing is sent
+	deps.provider.emails["m1"] = &emaildomain.Email{ID: "m1", Subject: "Lunch"}
+
+	if _, err := uc.ReplyEmail("u1", "m1", "", "Sounds good", false, false, nil); err == nil {
+		t.Fatal("ReplyEmail() error = nil for an original without a sender")
+	}
+	if len(deps.provider.archived) != 0 {
+		t.Errorf("archived %v after a failed reply, want nothing", deps.provider.archived)
+	}
+}
+
+func TestReplySignatureOncePerThread(t *testing.T) {
+	for _, strip := range []bool{true, false} {
+		t.Run(fmt.Sprint("strip quoted signatures ", strip), func(t *testing.T) {
+			user := gmailUser("u1")
+			user.Signature = "Bob\nACME Corp"
+			user.SignatureFirstInThreadOnly = true
+			user.StripQuotedSignatures = strip
+			uc, deps := newTestUsecase(t, nil, user)
+
+			// Alice starts the thread
+			first := &emaildomain.Email{ID: "m1", ThreadID: "t1", From: "alice@example.com", Subject: "Lunch", Body: "Lunch?"}
+			deps.provider.emails["m1"] = first
+			deps.provider.threads = map[string][]*emaildomain.Email{"t1": {first}}
+
+			reply, err := uc.PreviewReply("u1", "m1", "<p>Sure</p>", false)
+			if err != nil {
+				t.Fatalf("PreviewReply() error = %v", err)
+			}
+			if n := strings.Count(reply, "ACME Corp"); n != 1 {
+				t.Fatalf("first reply has %d signatures, want 1:\n%s", n, reply)
+			}
+
+			// The reply is sent, then Alice answers quoting it
+			sent := &emaildomain.Email{ID: "m2", ThreadID: "t1", From: "Test User <u1@example.com>", Body: reply, IsHTML: true}
+			answer := &emaildomain.Email{ID: "m3", ThreadID: "t1", From: "alice@example.com", Subject: "Re: Lunch", Body: "<p>Great!</p><blockquote>" + reply + "</blockquote>", IsHTML: true}
+			deps.provider.emails["m3"] = answer
+			deps.provider.threads["t1"] = []*emaildomain.Email{first, sent, answer}
+
+			reply, err = uc.PreviewReply("u1", "m3", "<p>See you</p>", false)
+			if err != nil {
+				t.Fatalf("PreviewReply() error = %v", err)
+			}
+			// No new signature, and the quoted one only survives when it isn't stripped
+			want := 0
+			if !strip {
+				want = 1
+			}
+			if n := strings.Count(reply, "ACME Corp"); n != want {
+				t.Errorf("second reply has %d signatures, want %d:\n%s", n, want, reply)
+			}
+			if !strings.Contains(reply, "<p>See you</p>") || !strings.Contains(reply, "<p>Great!</p>") {
+				t.Errorf("second reply = %s, want the new text and the quote", reply)
+			}
+		})
+	}
+}
+
+func TestReplySignatureEveryMessage(t *testing.T) {
+	user := gmailUser("u1")
+	user.Signature = "Bob"
+	uc, deps := newTestUsecase(t, nil, user)
+	deps.provider.emails["m1"] = &emaildomain.Email{ID: "m1", ThreadID: "t1", From: "alice@example.com", Body: "Lunch?"}
+
+	// Without the first-in-thread rule the thread isn't even looked up
+	reply, err := uc.PreviewReply("u1", "m1", "Sure", true)
+	if err != nil {
+		t.Fatalf("PreviewReply() error = %v", err)
+	}
+	if !strings.HasSuffix(reply, "Sure<br>\n<br>\n-- <br>\nBob") {
+		t.Errorf("reply = %q, want the signature below the text", reply)
+	}
+}
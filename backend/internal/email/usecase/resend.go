@@ -0,0 +1,121 @@
+package usecase
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/pkg/utils/mailutil"
+)
+
+// maxResendRecipients is how many recipients a resend may reach without explicit confirmation
+const maxResendRecipients = 20
+
+// ErrTooManyRecipients is returned when a resend needs confirmation because of its audience size
+var ErrTooManyRecipients = errors.New("too many recipients, confirm to resend")
+
+// ResendEmail sends a copy of one of the user's sent messages through the normal send
+// path, so it goes out with a new Message-ID. Empty to/cc/bcc keep the original recipients.
+func (u *emailUsecase) ResendEmail(userID, emailID, to, cc, bcc string, confirm bool) error {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return authdomain.ErrUserNotFound
+	}
+
+	original, err := u.GetEmailByID(userID, emailID)
+	if err != nil {
+		return fmt.Errorf("failed to load original email: %w", err)
+	}
+	if original == nil {
+		return emaildomain.ErrEmailNotFound
+	}
+
+	senders := mailutil.ParseAddresses([]string{original.From})
+	if len(senders) == 0 || mailutil.NormalizeAddress(senders[0].Address) != mailutil.NormalizeAddress(user.Email) {
+		return fmt.Errorf("only messages sent by you can be resent")
+	}
+
+	if to == "" {
+		to = joinAddresses(original.To)
+	}
+	if cc == "" {
+		cc = joinAddresses(original.Cc)
+	}
+	if to == "" {
+		return fmt.Errorf("original message has no recipients")
+	}
+
+	recipients := len(mailutil.ParseAddresses([]string{to, cc, bcc}))
+	if recipients > maxResendRecipients && !confirm {
+		return ErrTooManyRecipients
+	}
+
+	var files []*multipart.FileHeader
+	if len(original.Attachments) > 0 {
+		files, err = u.loadAttachmentFiles(userID, emailID, original.Attachments)
+		if err != nil {
+			return err
+		}
+	}
+
+	return u.SendEmail(userID, senders[0].Name, to, cc, bcc, original.Subject, original.Body, files)
+}
+
+func joinAddresses(values []string) string {
+	addrs := mailutil.ParseAddresses(values)
+	list := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		list = append(list, addr.String())
+	}
+	return strings.Join(list, ", ")
+}
+
+// loadAttachmentFiles downloads the attachments of a message and wraps them as
+// multipart file headers, which is what the send path takes
+func (u *emailUsecase) loadAttachmentFiles(userID, emailID string, attachments []emaildomain.Attachment) ([]*multipart.FileHeader, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	for _, att := range attachments {
+		meta, data, err := u.GetAttachment(userID, emailID, att.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load attachment %s: %w", att.Name, err)
+		}
+		if data == nil {
+			continue
+		}
+
+		mimeType := att.MimeType
+		if meta != nil && meta.MimeType != "" {
+			mimeType = meta.MimeType
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files"; filename=%q`, att.Name))
+		header.Set("Content-Type", mimeType)
+
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	form, err := multipart.NewReader(&buf, writer.Boundary()).ReadForm(32 << 20)
+	if err != nil {
+		return nil, err
+	}
+	return form.File["files"], nil
+}
@@ -0,0 +1,114 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+// sentMessage is a message u1 sent earlier, with one attachment
+func sentMessage() *emaildomain.Email {
+	return &emaildomain.Email{
+		ID:          "m1",
+		From:        "Test User <u1@example.com>",
+		To:          []string{"Alice <alice@example.com>"},
+		Cc:          []string{"bob@example.com"},
+		Subject:     "Quarterly report",
+		Body:        "<p>Report attached.</p>",
+		Attachments: []emaildomain.Attachment{{ID: "a1", Name: "report.csv", MimeType: "text/csv"}},
+	}
+}
+
+func TestResendEmail(t *testing.T) {
+	uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+	deps.provider.emails["m1"] = sentMessage()
+	deps.provider.attachments = map[string][]byte{"a1": []byte("q,total\nQ1,42\n")}
+
+	if err := uc.ResendEmail("u1", "m1", "", "", "", false); err != nil {
+		t.Fatalf("ResendEmail() error = %v", err)
+	}
+	if len(deps.provider.sent) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(deps.provider.sent))
+	}
+	sent := deps.provider.sent[0]
+	if sent.to != `"Alice" <alice@example.com>` || sent.cc != "<bob@example.com>" || sent.bcc != "" {
+		t.Errorf("recipients = (%q, %q, %q), want the original ones", sent.to, sent.cc, sent.bcc)
+	}
+	if sent.fromName != "Test User" || sent.subject != "Quarterly report" || sent.body != "<p>Report attached.</p>" {
+		t.Errorf("sent %+v, want the original sender name, subject and body", sent)
+	}
+
+	if len(sent.files) != 1 || sent.files[0].Filename != "report.csv" || sent.files[0].Header.Get("Content-Type") != "text/csv" {
+		t.Fatalf("files = %+v, want report.csv", sent.files)
+	}
+	f, err := sent.files[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if data, _ := io.ReadAll(f); string(data) != "q,total\nQ1,42\n" {
+		t.Errorf("attachment = %q, want the original content", data)
+	}
+}
+
+func TestResendEmailNewRecipients(t *testing.T) {
+	uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+	original := sentMessage()
+	original.Attachments = nil
+	deps.provider.emails["m1"] = original
+
+	if err := uc.ResendEmail("u1", "m1", "carol@example.com", "", "dave@example.com", false); err != nil {
+		t.Fatalf("ResendEmail() error = %v", err)
+	}
+	sent := deps.provider.sent[0]
+	if sent.to != "carol@example.com" || sent.cc != "<bob@example.com>" || sent.bcc != "dave@example.com" {
+		t.Errorf("recipients = (%q, %q, %q), want the new to and bcc with the original cc", sent.to, sent.cc, sent.bcc)
+	}
+}
+
+func TestResendEmailRejects(t *testing.T) {
+	many := make([]string, maxResendRecipients+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("user%d@example.com", i)
+	}
+
+	t.Run("someone else's message", func(t *testing.T) {
+		uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+		received := sentMessage()
+		received.From = "Alice <alice@example.com>"
+		deps.provider.emails["m1"] = received
+
+		if err := uc.ResendEmail("u1", "m1", "", "", "", false); err == nil {
+			t.Error("ResendEmail() error = nil for a message the user didn't send")
+		}
+		if len(deps.provider.sent) != 0 {
+			t.Errorf("sent %d emails, want none", len(deps.provider.sent))
+		}
+	})
+
+	t.Run("too many recipients", func(t *testing.T) {
+		uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+		original := sentMessage()
+		original.Attachments = nil
+		deps.provider.emails["m1"] = original
+
+		err := uc.ResendEmail("u1", "m1", strings.Join(many, ", "), "", "", false)
+		if !errors.Is(err, ErrTooManyRecipients) {
+			t.Fatalf("ResendEmail() error = %v, want ErrTooManyRecipients", err)
+		}
+		if len(deps.provider.sent) != 0 {
+			t.Fatalf("sent %d emails before confirmation, want none", len(deps.provider.sent))
+		}
+
+		if err := uc.ResendEmail("u1", "m1", strings.Join(many, ", "), "", "", true); err != nil {
+			t.Fatalf("ResendEmail() with confirm error = %v", err)
+		}
+		if len(deps.provider.sent) != 1 {
+			t.Errorf("sent %d emails after confirmation, want 1", len(deps.provider.sent))
+		}
+	})
+}
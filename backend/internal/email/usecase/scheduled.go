@@ -0,0 +1,244 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"github.com/google/uuid"
+)
+
+const (
+	scheduledCheckInterval = 30 * time.Second
+	scheduledBatchSize     = 50
+	// A failed send is retried after 1, 2, 4 and 8 minutes before it is marked failed
+	maxScheduledAttempts        = 5
+	scheduledRetryDelay         = time.Minute
+	maxScheduledAttachmentBytes = 25 << 20 // Gmail's message size limit
+)
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// ScheduleEmail stores a composed message, attachments included, to be sent at sendAt
+func (u *emailUsecase) ScheduleEmail(userID, fromName, to, cc, bcc, subject, body string, files []*multipart.FileHeader, sendAt time.Time) (*emaildomain.ScheduledEmail, error) {
+	if !sendAt.After(time.Now()) {
+		return nil, fmt.Errorf("send_at must be in the future")
+	}
+	return u.queueEmail(userID, fromName, to, cc, bcc, subject, body, files, sendAt, false)
+}
+
+// queueEmail stores a message for the scheduled sender to pick up at sendAt
+func (u *emailUsecase) queueEmail(userID, fromName, to, cc, bcc, subject, body string, files []*multipart.FileHeader, sendAt time.Time, undoSend bool) (*emaildomain.ScheduledEmail, error) {
+	scheduled := &emaildomain.ScheduledEmail{
+		ID:            uuid.New().String(),
+		UserID:        userID,
+		FromName:      fromName,
+		To:            to,
+		Cc:            cc,
+		Bcc:           bcc,
+		Subject:       subject,
+		Body:          body,
+		SendAt:        sendAt,
+		Status:        emaildomain.ScheduledPending,
+		NextAttemptAt: sendAt,
+		UndoSend:      undoSend,
+	}
+
+	var total int64
+	for _, file := range files {
+		total += file.Size
+		if total > maxScheduledAttachmentBytes {
+			return nil, fmt.Errorf("attachments exceed %d MB", maxScheduledAttachmentBytes>>20)
+		}
+		data, err := readFileHeader(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read attachment %s: %w", file.Filename, err)
+		}
+		contentType := file.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		scheduled.Attachments = append(scheduled.Attachments, emaildomain.ScheduledAttachment{
+			Filename:    file.Filename,
+			ContentType: contentType,
+			Size:        int64(len(data)),
+			Data:        data,
+		})
+	}
+
+	if err := u.scheduledRepo.Create(scheduled); err != nil {
+		return nil, fmt.Errorf("failed to schedule email: %w", err)
+	}
+	return scheduled, nil
+}
+
+// ListScheduledEmails returns the user's emails that are waiting to be sent or gave up.
+// Sends still inside their undo window are left out, they belong to the compose view.
+func (u *emailUsecase) ListScheduledEmails(userID string) ([]*emaildomain.ScheduledEmail, error) {
+	emails, err := u.scheduledRepo.GetByUser(userID, []string{emaildomain.ScheduledPending, emaildomain.ScheduledSending, emaildomain.ScheduledFailed})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*emaildomain.ScheduledEmail, 0, len(emails))
+	for _, email := range emails {
+		if email.UndoSend && email.Status != emaildomain.ScheduledFailed {
+			continue
+		}
+		result = append(result, email)
+	}
+	return result, nil
+}
+
+// CancelScheduledEmail stops a pending email from being sent
+func (u *emailUsecase) CancelScheduledEmail(userID, id string) error {
+	scheduled, err := u.scheduledRepo.GetByID(userID, id)
+	if err != nil {
+		return err
+	}
+	if scheduled == nil {
+		return fmt.Errorf("scheduled email not found")
+	}
+
+	// Failed emails can be cancelled too, to clear them from the list
+	for _, from := range []string{emaildomain.ScheduledPending, emaildomain.ScheduledFailed} {
+		ok, err := u.scheduledRepo.Transition(id, from, emaildomain.ScheduledCancelled)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("scheduled email is already %s", scheduled.Status)
+}
+
+func (u *emailUsecase) startScheduledSender(ctx context.Context) {
+	// A send interrupted by a restart is retried rather than left stuck
+	if err := u.scheduledRepo.ResetSending(); err != nil {
+		log.Printf("Failed to reset interrupted scheduled emails: %v", err)
+	}
+
+	u.every(ctx, scheduledCheckInterval, u.sendDueScheduled)
+}
+
+func (u *emailUsecase) sendDueScheduled() {
+	due, err := u.scheduledRepo.GetDue(time.Now(), scheduledBatchSize)
+	if err != nil {
+		log.Printf("Failed to load due scheduled emails: %v", err)
+		return
+	}
+	for _, scheduled := range due {
+		u.dispatchScheduled(scheduled)
+	}
+}
+
+// dispatchScheduled sends one due email through the user's provider. A failure is
+// retried with exponential backoff, and every outcome is pushed to the user.
+func (u *emailUsecase) dispatchScheduled(scheduled *emaildomain.ScheduledEmail) {
+	// Claim it first so a slow send is never picked up twice
+	claimed, err := u.scheduledRepo.Transition(scheduled.ID, emaildomain.ScheduledPending, emaildomain.ScheduledSending)
+	if err != nil || !claimed {
+		return
+	}
+
+	files, err := scheduledFiles(scheduled.Attachments)
+	if err == nil {
+		err = u.sendEmail(scheduled.UserID, scheduled.FromName, scheduled.To, scheduled.Cc, scheduled.Bcc, scheduled.Subject, scheduled.Body, files, nil)
+	}
+	scheduled.Attempts++
+
+	if err == nil {
+		now := time.Now()
+		scheduled.Status = emaildomain.ScheduledSent
+		scheduled.SentAt = &now
+		scheduled.LastError = ""
+		if err := u.scheduledRepo.Save(scheduled); err != nil {
+			log.Printf("Failed to mark scheduled email %s as sent: %v", scheduled.ID, err)
+		}
+		u.notifyScheduled(scheduled, "scheduled_sent")
+		return
+	}
+
+	log.Printf("Scheduled email %s failed (attempt %d): %v", scheduled.ID, scheduled.Attempts, err)
+	scheduled.LastError = err.Error()
+	if scheduled.Attempts >= maxScheduledAttempts {
+		scheduled.Status = emaildomain.ScheduledFailed
+	} else {
+		scheduled.Status = emaildomain.ScheduledPending
+		scheduled.NextAttemptAt = time.Now().Add(scheduledRetryDelay << (scheduled.Attempts - 1))
+	}
+	if err := u.scheduledRepo.Save(scheduled); err != nil {
+		log.Printf("Failed to save scheduled email %s: %v", scheduled.ID, err)
+	}
+	u.notifyScheduled(scheduled, "scheduled_failed")
+}
+
+func (u *emailUsecase) notifyScheduled(scheduled *emaildomain.ScheduledEmail, eventType string) {
+	if u.notify == nil {
+		return
+	}
+	payload := map[string]interface{}{
+		"id":       scheduled.ID,
+		"subject":  scheduled.Subject,
+		"status":   scheduled.Status,
+		"attempts": scheduled.Attempts,
+	}
+	if eventType == "scheduled_failed" {
+		payload["error"] = scheduled.LastError
+		payload["will_retry"] = scheduled.Status == emaildomain.ScheduledPending
+		if scheduled.Status == emaildomain.ScheduledPending {
+			payload["next_attempt_at"] = scheduled.NextAttemptAt
+		}
+	}
+	u.notify(scheduled.UserID, eventType, payload)
+}
+
+func readFileHeader(file *multipart.FileHeader) ([]byte, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// scheduledFiles turns stored attachments back into the file headers the providers'
+// SendEmail takes, by writing them as a multipart form and parsing it again
+func scheduledFiles(attachments []emaildomain.ScheduledAttachment) ([]*multipart.FileHeader, error) {
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, att := range attachments {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files"; filename="%s"`, quoteEscaper.Replace(att.Filename)))
+		header.Set("Content-Type", att.ContentType)
+		part, err := w.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(att.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	// Keep everything in memory, the size was capped when the email was scheduled
+	form, err := multipart.NewReader(&buf, w.Boundary()).ReadForm(int64(buf.Len()) + 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore attachments: %w", err)
+	}
+	return form.File["files"], nil
+}
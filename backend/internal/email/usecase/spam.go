@@ -0,0 +1,54 @@
+package usecase
+
+import (
+	"context"
+
+	authdomain "ga03-backend/internal/auth/domain"
+)
+
+// MarkAsSpam moves an email to Spam: the SPAM label in Gmail, the Junk folder in IMAP
+func (u *emailUsecase) MarkAsSpam(userID, id string) error {
+	return u.setSpam(userID, id, true)
+}
+
+// MarkNotSpam moves an email from Spam back to INBOX
+func (u *emailUsecase) MarkNotSpam(userID, id string) error {
+	return u.setSpam(userID, id, false)
+}
+
+func (u *emailUsecase) setSpam(userID, id string, spam bool) error {
+	defer u.invalidateStats(userID)
+	defer u.publishMailboxCounts(userID)
+	defer u.prefetch.invalidate(userID, id)
+
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return authdomain.ErrUserNotFound
+	}
+
+	ctx := context.Background()
+	if user.Provider == "imap" {
+		decryptedPass, err := u.imapSecret(user)
+		if err != nil {
+			return err
+		}
+		if spam {
+			return u.imapProvider.MarkAsSpam(ctx, user.ImapServer, user.ImapPort, user.Email, decryptedPass, id)
+		}
+		return u.imapProvider.MarkNotSpam(ctx, user.ImapServer, user.ImapPort, user.Email, decryptedPass, id)
+	}
+
+	if user.AccessToken == "" {
+		// Local storage has no spam folder, like trash and archive
+		return nil
+	}
+
+	onTokenRefresh := u.makeTokenUpdateCallback(userID)
+	if spam {
+		return u.mailProvider.MarkAsSpam(ctx, user.AccessToken, user.RefreshToken, id, onTokenRefresh)
+	}
+	return u.mailProvider.MarkNotSpam(ctx, user.AccessToken, user.RefreshToken, id, onTokenRefresh)
+}
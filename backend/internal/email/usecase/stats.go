@@ -0,0 +1,275 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+const (
+	statsCacheTTL    = 1 * time.Minute
+	statsSampleSize  = 100
+	statsTopSenders  = 5
+	statsDefaultDays = 7
+	statsMaxDays     = 90
+	// statsCountWorkers bounds the concurrent Gmail searches counting mail per day
+	statsCountWorkers = 5
+	// Counts of past days barely change, so they outlive the stats built from them
+	statsDayCountTTL = 15 * time.Minute
+	// statsUnreadQuery counts unread mail the user can see in some folder; Gmail
+	// search already leaves out spam and trash
+	statsUnreadQuery = "is:unread -in:sent -in:draft"
+)
+
+// statsUnreadSkipped are mailbox types left out of the unread total. They either hold
+// no incoming mail or show mail that is also in another mailbox.
+var statsUnreadSkipped = map[string]bool{
+	"sent": true, "drafts": true, "trash": true, "spam": true,
+	"starred": true, "important": true, "all": true,
+	"todo": true, "done": true, "snoozed": true,
+}
+
+type cachedStats struct {
+	stats     *emaildomain.Stats
+	expiresAt time.Time
+}
+
+type cachedDayCount struct {
+	count     int
+	expiresAt time.Time
+}
+
+// GetStats returns aggregated dashboard statistics for the last `days` days
+func (u *emailUsecase) GetStats(userID string, days int) (*emaildomain.Stats, error) {
+	if days <= 0 {
+		days = statsDefaultDays
+	}
+	if days > statsMaxDays {
+		days = statsMaxDays
+	}
+
+	cacheKey := fmt.Sprintf("%s:%d", userID, days)
+	u.statsMu.Lock()
+	if cached, ok := u.statsCache[cacheKey]; ok && time.Now().Before(cached.expiresAt) {
+		u.statsMu.Unlock()
+		return cached.stats, nil
+	}
+	u.statsMu.Unlock()
+
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, authdomain.ErrUserNotFound
+	}
+
+	mailboxes, err := u.GetAllMailboxes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	since := today.AddDate(0, 0, -(days - 1))
+
+	stats := &emaildomain.Stats{
+		Days:          days,
+		Mailboxes:     mailboxes,
+		KanbanColumns: make(map[string]int),
+		GeneratedAt:   now,
+	}
+	for _, mb := range mailboxes {
+		if !statsUnreadSkipped[strings.ToLower(mb.Type)] {
+			stats.UnreadTotal += mb.Count
+		}
+	}
+
+	// Sample recent inbox emails for per-day counts, top senders and Kanban distribution
+	var sample []*emaildomain.Email
+	accessToken, refreshToken, _ := u.getUserTokens(userID)
+
+	switch {
+	case user.Provider == "imap":
+		decryptedPass, err := u.imapSecret(user)
+		if err != nil {
+			return nil, err
+		}
+		sample, _, err = u.imapProvider.GetEmails(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, "INBOX", statsSampleSize, 0)
+		if err != nil {
+			return nil, err
+		}
+	case accessToken == "":
+		sample, _, err = u.emailRepo.GetEmailsByMailbox("inbox", statsSampleSize, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, status := range []string{"inbox", "todo", "done", "snoozed"} {
+			_, total, err := u.emailRepo.GetEmailsByStatus(status, 1, 0)
+			if err != nil {
+				return nil, err
+			}
+			stats.KanbanColumns[status] = total
+		}
+	default:
+		ctx := context.Background()
+		sample, _, err = u.mailProvider.GetEmails(ctx, accessToken, refreshToken, "INBOX", statsSampleSize, 0, fmt.Sprintf("newer_than:%dd", days), u.makeTokenUpdateCallback(userID))
+		if err != nil {
+			return nil, err
+		}
+		// Gmail labels overlap, so their unread counts can't be added up
+		stats.UnreadTotal, err = u.mailProvider.CountEmails(ctx, accessToken, refreshToken, statsUnreadQuery, u.makeTokenUpdateCallback(userID))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	perDay := make(map[string]int)
+	senders := make(map[string]*emaildomain.SenderCount)
+	for _, email := range sample {
+		if email.ReceivedAt.Before(since) {
+			continue
+		}
+		perDay[email.ReceivedAt.Format("2006-01-02")]++
+
+		key := strings.ToLower(email.From)
+		if sc, ok := senders[key]; ok {
+			sc.Count++
+		} else {
+			senders[key] = &emaildomain.SenderCount{Email: email.From, Name: email.FromName, Count: 1}
+		}
+
+		if accessToken != "" || user.Provider == "imap" {
+			status, ok := u.getKanbanStatus(userID, email.ID)
+			if !ok {
+				status = "inbox"
+			}
+			stats.KanbanColumns[status]++
+		}
+	}
+
+	// Gmail can count matches server-side, which is exact beyond the sample size
+	if user.Provider != "imap" && accessToken != "" {
+		counts, err := u.countPerDay(userID, accessToken, refreshToken, since, days, today)
+		if err != nil {
+			return nil, err
+		}
+		for date, count := range counts {
+			perDay[date] = count
+		}
+	}
+
+	for d := 0; d < days; d++ {
+		date := since.AddDate(0, 0, d).Format("2006-01-02")
+		stats.ReceivedPerDay = append(stats.ReceivedPerDay, emaildomain.DayCount{Date: date, Count: perDay[date]})
+	}
+
+	for _, sc := range senders {
+		stats.TopSenders = append(stats.TopSenders, *sc)
+	}
+	sort.Slice(stats.TopSenders, func(i, j int) bool {
+		if stats.TopSenders[i].Count != stats.TopSenders[j].Count {
+			return stats.TopSenders[i].Count > stats.TopSenders[j].Count
+		}
+		return stats.TopSenders[i].Email < stats.TopSenders[j].Email
+	})
+	if len(stats.TopSenders) > statsTopSenders {
+		stats.TopSenders = stats.TopSenders[:statsTopSenders]
+	}
+
+	u.statsMu.Lock()
+	u.statsCache[cacheKey] = &cachedStats{stats: stats, expiresAt: now.Add(statsCacheTTL)}
+	u.statsMu.Unlock()
+
+	return stats, nil
+}
+
+// countPerDay asks Gmail how many inbox emails arrived on each of the days from since,
+// a few searches at a time. Past days are cached for statsDayCountTTL; today is always
+// counted again since mail is still arriving.
+func (u *emailUsecase) countPerDay(userID, accessToken, refreshToken string, since time.Time, days int, today time.Time) (map[string]int, error) {
+	counts := make(map[string]int, days)
+	var missing []time.Time
+	now := time.Now()
+
+	u.statsMu.Lock()
+	for d := 0; d < days; d++ {
+		day := since.AddDate(0, 0, d)
+		date := day.Format("2006-01-02")
+		if cached, ok := u.dayCounts[userID+":"+date]; ok && day.Before(today) && now.Before(cached.expiresAt) {
+			counts[date] = cached.count
+			continue
+		}
+		missing = append(missing, day)
+	}
+	u.statsMu.Unlock()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sem := make(chan struct{}, statsCountWorkers)
+	for _, day := range missing {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(day time.Time) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			query := fmt.Sprintf("in:inbox after:%s before:%s", day.Format("2006/01/02"), day.AddDate(0, 0, 1).Format("2006/01/02"))
+			count, err := u.mailProvider.CountEmails(ctx, accessToken, refreshToken, query, u.makeTokenUpdateCallback(userID))
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+					cancel() // The stats fail as a whole, don't start more searches
+				}
+				return
+			}
+			counts[day.Format("2006-01-02")] = count
+		}(day)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	u.statsMu.Lock()
+	for _, day := range missing {
+		date := day.Format("2006-01-02")
+		u.dayCounts[userID+":"+date] = &cachedDayCount{count: counts[date], expiresAt: now.Add(statsDayCountTTL)}
+	}
+	u.statsMu.Unlock()
+	return counts, nil
+}
+
+// invalidateStats drops cached stats for a user after a mutation changes their counts
+func (u *emailUsecase) invalidateStats(userID string) {
+	// Whatever changes the stats changes unread counts too
+	u.invalidateMailboxes(userID)
+
+	prefix := userID + ":"
+	u.statsMu.Lock()
+	for key := range u.statsCache {
+		if strings.HasPrefix(key, prefix) {
+			delete(u.statsCache, key)
+		}
+	}
+	// A move or delete can change the count of any day
+	for key := range u.dayCounts {
+		if strings.HasPrefix(key, prefix) {
+			delete(u.dayCounts, key)
+		}
+	}
+	u.statsMu.Unlock()
+}
@@ -0,0 +1,160 @@
+package usecase
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+// The stats of a local account are aggregated from the in-memory repository
+func TestStatsFromLocalRepository(t *testing.T) {
+	local := &authdomain.User{ID: "u1", Email: "u1@example.com", Provider: "email"}
+	uc, _ := newTestUsecase(t, nil, local)
+	const days = 14
+
+	stats, err := uc.GetStats("u1", days)
+	if err != nil {
+		t.Fatalf("GetStats() error = %v", err)
+	}
+
+	// Expected values straight from the repository
+	inbox, _, _ := uc.emailRepo.GetEmailsByMailbox("inbox", statsSampleSize, 0)
+	now := time.Now()
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -(days - 1))
+	wantPerDay := make(map[string]int)
+	senders := make(map[string]int)
+	for _, email := range inbox {
+		if !email.ReceivedAt.Before(since) {
+			wantPerDay[email.ReceivedAt.Format("2006-01-02")]++
+			senders[strings.ToLower(email.From)]++
+		}
+	}
+
+	if len(stats.ReceivedPerDay) != days {
+		t.Fatalf("got %d days, want %d", len(stats.ReceivedPerDay), days)
+	}
+	for i, day := range stats.ReceivedPerDay {
+		if want := since.AddDate(0, 0, i).Format("2006-01-02"); day.Date != want {
+			t.Errorf("day %d is %s, want %s", i, day.Date, want)
+		}
+		if day.Count != wantPerDay[day.Date] {
+			t.Errorf("%s: %d received, want %d", day.Date, day.Count, wantPerDay[day.Date])
+		}
+	}
+
+	for _, status := range []string{"inbox", "todo", "done", "snoozed"} {
+		_, want, _ := uc.emailRepo.GetEmailsByStatus(status, 1, 0)
+		if stats.KanbanColumns[status] != want {
+			t.Errorf("kanban column %s = %d, want %d", status, stats.KanbanColumns[status], want)
+		}
+	}
+
+	wantUnread := 0
+	for _, mb := range stats.Mailboxes {
+		if mb.Type == "inbox" || mb.Type == "archive" {
+			wantUnread += mb.Count
+		}
+	}
+	if stats.UnreadTotal != wantUnread {
+		t.Errorf("UnreadTotal = %d, want %d from inbox and archive only", stats.UnreadTotal, wantUnread)
+	}
+
+	if len(stats.TopSenders) > statsTopSenders {
+		t.Errorf("got %d top senders, want at most %d", len(stats.TopSenders), statsTopSenders)
+	}
+	for i, sender := range stats.TopSenders {
+		if sender.Count != senders[strings.ToLower(sender.Email)] {
+			t.Errorf("%s: %d emails, want %d", sender.Email, sender.Count, senders[strings.ToLower(sender.Email)])
+		}
+		if i > 0 && sender.Count > stats.TopSenders[i-1].Count {
+			t.Errorf("top senders aren't sorted: %+v", stats.TopSenders)
+		}
+	}
+}
+
+func TestStatsCached(t *testing.T) {
+	local := &authdomain.User{ID: "u1", Email: "u1@example.com", Provider: "email"}
+	uc, _ := newTestUsecase(t, nil, local)
+
+	first, _ := uc.GetStats("u1", 7)
+	if again, _ := uc.GetStats("u1", 7); again != first {
+		t.Error("stats weren't served from the cache")
+	}
+	uc.invalidateStats("u1")
+	if fresh, _ := uc.GetStats("u1", 7); fresh == first {
+		t.Error("invalidated stats were served from the cache")
+	}
+}
+
+func dayQuery(day time.Time) string {
+	return fmt.Sprintf("in:inbox after:%s before:%s", day.Format("2006/01/02"), day.AddDate(0, 0, 1).Format("2006/01/02"))
+}
+
+func TestStatsGmailCounts(t *testing.T) {
+	uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+	const days, unread = 30, 4
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	deps.provider.counts = map[string]int{
+		statsUnreadQuery:                  unread,
+		dayQuery(today):                   7,
+		dayQuery(today.AddDate(0, 0, -3)): 2,
+	}
+	// Labels overlap: the unread inbox mail is also in a category and under UNREAD
+	deps.provider.mailboxes = []*emaildomain.Mailbox{
+		{ID: "INBOX", Type: "inbox", Count: 3},
+		{ID: "CATEGORY_SOCIAL", Type: "category_social", Count: 3},
+		{ID: "UNREAD", Type: "unread", Count: 3},
+	}
+	deps.provider.countDelay = 2 * time.Millisecond
+
+	stats, err := uc.GetStats("u1", days)
+	if err != nil {
+		t.Fatalf("GetStats() error = %v", err)
+	}
+	if stats.UnreadTotal != unread {
+		t.Errorf("UnreadTotal = %d, want Gmail's unread count %d", stats.UnreadTotal, unread)
+	}
+	last := stats.ReceivedPerDay[days-1]
+	if last.Date != today.Format("2006-01-02") || last.Count != 7 {
+		t.Errorf("today = %+v, want 7", last)
+	}
+	if got := stats.ReceivedPerDay[days-4].Count; got != 2 {
+		t.Errorf("three days ago = %d, want 2", got)
+	}
+	if deps.provider.countMaxAtOnce > statsCountWorkers {
+		t.Errorf("%d searches ran at once, want at most %d", deps.provider.countMaxAtOnce, statsCountWorkers)
+	}
+	if n := len(deps.provider.countQueries); n != days+1 {
+		t.Errorf("%d searches, want one per day plus the unread count", n)
+	}
+
+	// Once the stats expire, only today is counted again
+	uc.statsMu.Lock()
+	clear(uc.statsCache)
+	uc.statsMu.Unlock()
+	deps.provider.countQueries = nil
+	if _, err := uc.GetStats("u1", days); err != nil {
+		t.Fatalf("GetStats() error = %v", err)
+	}
+	for _, query := range deps.provider.countQueries {
+		if query != statsUnreadQuery && query != dayQuery(today) {
+			t.Errorf("searched %q again, want past days from the cache", query)
+		}
+	}
+
+	// A change to the mailbox drops the cached days too
+	uc.invalidateStats("u1")
+	deps.provider.countQueries = nil
+	if _, err := uc.GetStats("u1", days); err != nil {
+		t.Fatalf("GetStats() error = %v", err)
+	}
+	if n := len(deps.provider.countQueries); n != days+1 {
+		t.Errorf("%d searches after invalidation, want %d", n, days+1)
+	}
+}
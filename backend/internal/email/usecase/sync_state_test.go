@@ -0,0 +1,65 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+func TestSyncMailboxAdvancesLastSynced(t *testing.T) {
+	local := &authdomain.User{ID: "local", Email: "local@example.com", Provider: "email"}
+	uc, deps := newTestUsecase(t, nil, local)
+
+	first, err := uc.SyncMailbox("local", "inbox")
+	if err != nil {
+		t.Fatalf("SyncMailbox() error = %v", err)
+	}
+	newest, _, _ := uc.emailRepo.GetEmailsByMailbox("inbox", 1, 0)
+	if first.Watermark != newest[0].ID || first.LastSyncedAt.IsZero() {
+		t.Errorf("state = %+v, want the newest email %s as watermark", first, newest[0].ID)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	second, err := uc.SyncMailbox("local", "inbox")
+	if err != nil {
+		t.Fatalf("SyncMailbox() error = %v", err)
+	}
+	if !second.LastSyncedAt.After(first.LastSyncedAt) {
+		t.Errorf("LastSyncedAt = %v after a second sync, want later than %v", second.LastSyncedAt, first.LastSyncedAt)
+	}
+	stored, _ := deps.sync.GetSyncState("local", "inbox")
+	if stored == nil || !stored.LastSyncedAt.Equal(second.LastSyncedAt) {
+		t.Errorf("stored state = %+v, want the second sync persisted", stored)
+	}
+
+	// The mailbox listing shows when each mailbox was synced
+	mailboxes, err := uc.GetAllMailboxes("local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mailbox := range mailboxes {
+		switch {
+		case mailbox.ID == "inbox" && (mailbox.LastSyncedAt == nil || !mailbox.LastSyncedAt.Equal(second.LastSyncedAt)):
+			t.Errorf("inbox LastSyncedAt = %v, want %v", mailbox.LastSyncedAt, second.LastSyncedAt)
+		case mailbox.ID != "inbox" && mailbox.LastSyncedAt != nil:
+			t.Errorf("%s was never synced but has LastSyncedAt %v", mailbox.ID, mailbox.LastSyncedAt)
+		}
+	}
+}
+
+// Syncing an empty mailbox keeps the last known watermark
+func TestSyncEmptyMailboxKeepsWatermark(t *testing.T) {
+	local := &authdomain.User{ID: "local", Email: "local@example.com", Provider: "email"}
+	uc, deps := newTestUsecase(t, nil, local)
+	deps.sync.SaveSyncState(&emaildomain.MailboxSyncState{UserID: "local", MailboxID: "trash", Watermark: "old"})
+
+	state, err := uc.SyncMailbox("local", "trash")
+	if err != nil {
+		t.Fatalf("SyncMailbox() error = %v", err)
+	}
+	if state.Watermark != "old" || state.LastSyncedAt.IsZero() {
+		t.Errorf("state = %+v, want the old watermark and a new sync time", state)
+	}
+}
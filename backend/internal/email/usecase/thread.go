@@ -0,0 +1,241 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/pkg/utils/mailutil"
+)
+
+// GetThread returns the messages of a conversation, oldest first
+func (u *emailUsecase) GetThread(userID, threadID string) ([]*emaildomain.Email, error) {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, authdomain.ErrUserNotFound
+	}
+
+	// IMAP Handler
+	if user.Provider == "imap" {
+		decryptedPass, err := u.imapSecret(user)
+		if err != nil {
+			return nil, err
+		}
+		return u.imapProvider.GetThread(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, threadID)
+	}
+
+	accessToken, refreshToken, err := u.getUserTokens(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if accessToken == "" {
+		// Local storage has no threads, each email is its own conversation
+		email, err := u.emailRepo.GetEmailByID(threadID)
+		if err != nil {
+			return nil, err
+		}
+		if email == nil {
+			return nil, emaildomain.ErrEmailNotFound
+		}
+		return []*emaildomain.Email{email}, nil
+	}
+
+	ctx := context.Background()
+	return u.mailProvider.GetThread(ctx, accessToken, refreshToken, threadID, u.makeTokenUpdateCallback(userID))
+}
+
+// GetThreadParticipants returns every distinct address in a thread's From/To/Cc headers
+func (u *emailUsecase) GetThreadParticipants(userID, threadID string) ([]*emaildomain.Participant, error) {
+	emails, err := u.GetThread(userID, threadID)
+	if err != nil {
+		return nil, err
+	}
+	return collectParticipants(emails), nil
+}
+
+// threadSnippetRadius is how many characters of context a search snippet keeps around a match
+const threadSnippetRadius = 80
+
+// SearchThread returns the messages of a thread whose subject, sender or body contain
+// every word of query, oldest first
+func (u *emailUsecase) SearchThread(userID, threadID, query string) ([]*emaildomain.ThreadMatch, error) {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("query is required")
+	}
+
+	emails, err := u.GetThread(userID, threadID)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []*emaildomain.ThreadMatch{}
+	for _, email := range emails {
+		if email == nil {
+			continue
+		}
+		// Threads are fetched as metadata, so load each message for its body
+		full, err := u.getEmailByID(userID, email.ID)
+		if err != nil || full == nil {
+			full = email
+		}
+
+		body := plainBody(full)
+		if body == "" {
+			body = full.Preview
+		}
+		if !matchesAllTerms(terms, full.Subject, full.From, body) {
+			continue
+		}
+
+		snippet := mailutil.Snippet(body, terms, threadSnippetRadius)
+		if snippet == "" {
+			snippet = mailutil.Snippet(full.Subject, terms, threadSnippetRadius)
+		}
+		if snippet == "" {
+			snippet = mailutil.Snippet(full.From, terms, threadSnippetRadius)
+		}
+		matches = append(matches, &emaildomain.ThreadMatch{
+			EmailID:    email.ID,
+			From:       full.From,
+			ReceivedAt: full.ReceivedAt,
+			Snippet:    snippet,
+		})
+	}
+	return matches, nil
+}
+
+// matchesAllTerms reports whether each term occurs, case-insensitively, in any of fields
+func matchesAllTerms(terms []string, fields ...string) bool {
+	for _, term := range terms {
+		term = strings.ToLower(term)
+		found := false
+		for _, field := range fields {
+			if strings.Contains(strings.ToLower(field), term) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// collectParticipants dedups addresses by their normalized form. A participant's
+// message count is the number of messages they appear on in any role.
+func collectParticipants(emails []*emaildomain.Email) []*emaildomain.Participant {
+	byAddress := make(map[string]*emaildomain.Participant)
+	var order []string
+
+	for _, email := range emails {
+		if email == nil {
+			continue
+		}
+		seen := make(map[string]bool)
+
+		add := func(values []string, isSender bool) {
+			for _, addr := range mailutil.ParseAddresses(values) {
+				key := mailutil.NormalizeAddress(addr.Address)
+				if key == "" {
+					continue
+				}
+				p, ok := byAddress[key]
+				if !ok {
+					p = &emaildomain.Participant{Email: key}
+					byAddress[key] = p
+					order = append(order, key)
+				}
+				if p.Name == "" {
+					p.Name = addr.Name
+				}
+				if isSender {
+					p.IsSender = true
+				}
+				if !seen[key] {
+					seen[key] = true
+					p.MessageCount++
+				}
+			}
+		}
+
+		add([]string{email.From}, true)
+		add(email.To, false)
+		add(email.Cc, false)
+	}
+
+	participants := make([]*emaildomain.Participant, 0, len(order))
+	for _, key := range order {
+		participants = append(participants, byAddress[key])
+	}
+	// Senders first, then the most active participants
+	sort.SliceStable(participants, func(i, j int) bool {
+		if participants[i].IsSender != participants[j].IsSender {
+			return participants[i].IsSender
+		}
+		return participants[i].MessageCount > participants[j].MessageCount
+	})
+	return participants
+}
+
+type imapThreadFunc func(ctx context.Context, server string, port int, emailAddr, password, messageID string) error
+type gmailThreadFunc func(ctx context.Context, accessToken, refreshToken, threadID string, onTokenRefresh emaildomain.TokenUpdateFunc) error
+
+// applyToThread runs a thread-wide action on the user's provider. Local storage has no
+// threads, so the per-message action is used instead.
+func (u *emailUsecase) applyToThread(userID, threadID string, imapFn imapThreadFunc, gmailFn gmailThreadFunc, localFn func(userID, id string) error) error {
+	defer u.invalidateStats(userID)
+
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return authdomain.ErrUserNotFound
+	}
+
+	// IMAP Handler
+	if user.Provider == "imap" {
+		decryptedPass, err := u.imapSecret(user)
+		if err != nil {
+			return err
+		}
+		return imapFn(context.Background(), user.ImapServer, user.ImapPort, user.Email, decryptedPass, threadID)
+	}
+
+	accessToken, refreshToken, err := u.getUserTokens(userID)
+	if err != nil {
+		return err
+	}
+
+	if accessToken == "" {
+		return localFn(userID, threadID)
+	}
+
+	ctx := context.Background()
+	return gmailFn(ctx, accessToken, refreshToken, threadID, u.makeTokenUpdateCallback(userID))
+}
+
+func (u *emailUsecase) MarkThreadAsRead(userID, threadID string) error {
+	return u.applyToThread(userID, threadID, u.imapProvider.MarkThreadAsRead, u.mailProvider.MarkThreadAsRead, u.MarkEmailAsRead)
+}
+
+func (u *emailUsecase) MarkThreadAsUnread(userID, threadID string) error {
+	return u.applyToThread(userID, threadID, u.imapProvider.MarkThreadAsUnread, u.mailProvider.MarkThreadAsUnread, u.MarkEmailAsUnread)
+}
+
+func (u *emailUsecase) ToggleThreadStar(userID, threadID string) error {
+	return u.applyToThread(userID, threadID, u.imapProvider.ToggleThreadStar, u.mailProvider.ToggleThreadStar, u.ToggleStar)
+}
+
+func (u *emailUsecase) TrashThread(userID, threadID string) error {
+	return u.applyToThread(userID, threadID, u.imapProvider.TrashThread, u.mailProvider.TrashThread, u.TrashEmail)
+}
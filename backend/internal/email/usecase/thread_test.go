@@ -0,0 +1,116 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+func TestGetThreadParticipants(t *testing.T) {
+	uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+	deps.provider.threads = map[string][]*emaildomain.Email{
+		"t1": {
+			{ID: "m1", From: "Alice <Alice@Example.com>", To: []string{"u1@example.com", "Bob <bob@example.com>"}},
+			// Alice is listed twice on this message but it counts once
+			{ID: "m2", From: "u1@example.com", To: []string{"alice@example.com"}, Cc: []string{" ALICE@example.com ", "carol@example.com"}},
+			{ID: "m3", From: "Bob <bob@example.com>", To: []string{"Alice <alice@example.com>"}},
+		},
+	}
+
+	participants, err := uc.GetThreadParticipants("u1", "t1")
+	if err != nil {
+		t.Fatalf("GetThreadParticipants() error = %v", err)
+	}
+
+	// Senders first, ties in the order they appear in the thread
+	want := []emaildomain.Participant{
+		{Email: "alice@example.com", Name: "Alice", IsSender: true, MessageCount: 3},
+		{Email: "u1@example.com", IsSender: true, MessageCount: 2},
+		{Email: "bob@example.com", Name: "Bob", IsSender: true, MessageCount: 2},
+		{Email: "carol@example.com", MessageCount: 1},
+	}
+	if len(participants) != len(want) {
+		t.Fatalf("got %d participants, want %d: %+v", len(participants), len(want), participants)
+	}
+	for i, p := range participants {
+		if *p != want[i] {
+			t.Errorf("participant %d = %+v, want %+v", i, *p, want[i])
+		}
+	}
+}
+
+func TestGetThreadParticipantsUnknownThread(t *testing.T) {
+	uc, _ := newTestUsecase(t, nil, gmailUser("u1"))
+	if _, err := uc.GetThreadParticipants("u1", "missing"); err == nil {
+		t.Error("GetThreadParticipants() error = nil for an unknown thread")
+	}
+}
+
+func TestGetThreadUnknownLocalEmail(t *testing.T) {
+	uc, _ := newTestUsecase(t, nil, &authdomain.User{ID: "local", Email: "local@example.com", Provider: "email"})
+	emails, err := uc.GetThread("local", "missing")
+	if !errors.Is(err, emaildomain.ErrEmailNotFound) || emails != nil {
+		t.Errorf("GetThread() = (%v, %v), want ErrEmailNotFound", emails, err)
+	}
+}
+
+func TestSearchThread(t *testing.T) {
+	uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+	// The thread is metadata only; bodies come from fetching each message
+	deps.provider.threads = map[string][]*emaildomain.Email{
+		"t1": {
+			{ID: "m1", Subject: "Offsite", From: "alice@example.com"},
+			{ID: "m2", Subject: "Re: Offsite", From: "bob@example.com"},
+			{ID: "m3", Subject: "Re: Offsite", From: "Carol <carol@example.com>"},
+			{ID: "m4", Subject: "Re: Offsite", From: "alice@example.com", Preview: "Booked the Venue for Friday"},
+		},
+	}
+	deps.provider.emails["m1"] = &emaildomain.Email{ID: "m1", Subject: "Offsite", From: "alice@example.com", Body: "Where should we hold it?"}
+	deps.provider.emails["m2"] = &emaildomain.Email{ID: "m2", Subject: "Re: Offsite", From: "bob@example.com", IsHTML: true, Body: "<p>The <b>venue</b> by the lake, on Friday</p>"}
+	deps.provider.emails["m3"] = &emaildomain.Email{ID: "m3", Subject: "Re: Offsite", From: "Carol <carol@example.com>", Body: "Friday works for me"}
+
+	tests := []struct {
+		query string
+		want  map[string]string // Snippet by email ID
+	}{
+		{"venue friday", map[string]string{
+			"m2": "The <mark>venue</mark> by the lake, on <mark>Friday</mark>",
+			// m4 isn't in the provider, so its preview is searched
+			"m4": "Booked the <mark>Venue</mark> for <mark>Friday</mark>",
+		}},
+		// A term may match the sender while the snippet comes from the body
+		{"carol friday", map[string]string{"m3": "<mark>Friday</mark> works for me"}},
+		{"offsite", map[string]string{"m1": "<mark>Offsite</mark>", "m2": "Re: <mark>Offsite</mark>", "m3": "Re: <mark>Offsite</mark>", "m4": "Re: <mark>Offsite</mark>"}},
+		{"budget", map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			matches, err := uc.SearchThread("u1", "t1", tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(matches) != len(tt.want) {
+				t.Fatalf("got %d matches, want %d: %+v", len(matches), len(tt.want), matches)
+			}
+			for _, m := range matches {
+				if want, ok := tt.want[m.EmailID]; !ok || m.Snippet != want {
+					t.Errorf("match %s snippet = %q, want %q", m.EmailID, m.Snippet, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchThreadRejects(t *testing.T) {
+	uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+	deps.provider.threads = map[string][]*emaildomain.Email{"t1": {{ID: "m1"}}}
+
+	if _, err := uc.SearchThread("u1", "t1", "   "); err == nil {
+		t.Error("SearchThread() error = nil for an empty query")
+	}
+	if _, err := uc.SearchThread("u1", "missing", "lunch"); err == nil {
+		t.Error("SearchThread() error = nil for an unknown thread")
+	}
+}
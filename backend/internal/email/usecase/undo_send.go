@@ -0,0 +1,127 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"sync"
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+var (
+	// ErrPendingSendNotFound is returned for a send that isn't waiting in an undo window
+	ErrPendingSendNotFound = errors.New("pending send not found")
+	// ErrSendNotCancellable is returned once a send has started, or was already cancelled
+	ErrSendNotCancellable = errors.New("email can no longer be cancelled")
+)
+
+// undoSends holds the timers that dispatch sends once their undo window closes.
+// The queued email is also in the scheduled table, so after a restart the
+// scheduled sender delivers anything whose timer was lost.
+type undoSends struct {
+	mu      sync.Mutex
+	timers  map[string]*time.Timer
+	stopped bool           // Set on shutdown; timers added after it are stopped right away
+	running sync.WaitGroup // Sends whose timer fired and that are going out
+}
+
+func (p *undoSends) add(id string, timer *time.Timer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		timer.Stop()
+		return
+	}
+	p.timers[id] = timer
+}
+
+// fire claims id's timer when it goes off. It reports false if the send was cancelled
+// or shutdown stopped it; otherwise the caller must call running.Done when it's sent.
+func (p *undoSends) fire(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.timers[id]; !ok {
+		return false
+	}
+	delete(p.timers, id)
+	p.running.Add(1)
+	return true
+}
+
+// stop stops every waiting timer, leaving those sends queued for after the restart
+func (p *undoSends) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped = true
+	for id, timer := range p.timers {
+		timer.Stop()
+		delete(p.timers, id)
+	}
+}
+
+// take removes and returns the timer for id, if it is still waiting
+func (p *undoSends) take(id string) (*time.Timer, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	timer, ok := p.timers[id]
+	delete(p.timers, id)
+	return timer, ok
+}
+
+// SendEmailWithUndo queues the message and sends it once the configured undo delay
+// has passed, returning the ID CancelSend takes. With no delay configured it sends
+// right away and returns an empty ID.
+func (u *emailUsecase) SendEmailWithUndo(userID, fromName, to, cc, bcc, subject, body string, files []*multipart.FileHeader) (string, time.Time, error) {
+	delay := u.config.UndoSendDelay
+	if delay <= 0 {
+		return "", time.Time{}, u.SendEmail(userID, fromName, to, cc, bcc, subject, body, files)
+	}
+
+	pending, err := u.queueEmail(userID, fromName, to, cc, bcc, subject, body, files, time.Now().Add(delay), true)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	id := pending.ID
+	u.undoSends.add(id, time.AfterFunc(delay, func() {
+		if !u.undoSends.fire(id) {
+			return
+		}
+		defer u.undoSends.running.Done()
+		// Reload so a cancel that raced the timer is seen
+		scheduled, err := u.scheduledRepo.GetByID(userID, id)
+		if err != nil || scheduled == nil {
+			log.Printf("Failed to load pending send %s: %v", id, err)
+			return
+		}
+		u.dispatchScheduled(scheduled)
+	}))
+	return id, pending.SendAt, nil
+}
+
+// CancelSend stops a send made with SendEmailWithUndo while its undo window is open
+func (u *emailUsecase) CancelSend(userID, pendingSendID string) error {
+	pending, err := u.scheduledRepo.GetByID(userID, pendingSendID)
+	if err != nil {
+		return err
+	}
+	if pending == nil || !pending.UndoSend {
+		return ErrPendingSendNotFound
+	}
+
+	if timer, ok := u.undoSends.take(pendingSendID); ok {
+		timer.Stop()
+	}
+	// The claim in dispatchScheduled makes this fail once sending has started
+	ok, err := u.scheduledRepo.Transition(pendingSendID, emaildomain.ScheduledPending, emaildomain.ScheduledCancelled)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w, it is already %s", ErrSendNotCancellable, pending.Status)
+	}
+	return nil
+}
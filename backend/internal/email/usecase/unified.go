@@ -0,0 +1,123 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	authdomain "ga03-backend/internal/auth/domain"
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"golang.org/x/oauth2"
+)
+
+// maxUnifiedDepth bounds offset+limit in the unified inbox. Every account is read from
+// the top down to that depth, so deep pages get expensive quickly.
+const maxUnifiedDepth = 200
+
+// ErrUnifiedPageTooDeep is returned for unified inbox pages past maxUnifiedDepth
+var ErrUnifiedPageTooDeep = fmt.Errorf("the unified inbox only goes %d emails deep", maxUnifiedDepth)
+
+// accountInbox is one account's part of a unified inbox page
+type accountInbox struct {
+	accountID string
+	emails    []*emaildomain.Email
+	total     int
+	err       error
+}
+
+// GetUnifiedInbox merges the inboxes of the user's own account and every linked account
+// by ReceivedAt. Accounts that fail are left out and listed in FailedAccounts, so one
+// broken account doesn't hide the others.
+func (u *emailUsecase) GetUnifiedInbox(userID string, limit, offset int) (*emaildomain.UnifiedInbox, error) {
+	// Any account could fill the requested page, so each is read from its newest email
+	depth := offset + limit
+	if depth > maxUnifiedDepth {
+		return nil, ErrUnifiedPageTooDeep
+	}
+
+	accounts, err := u.userRepo.FindLinkedAccounts(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	inboxes := make([]accountInbox, len(accounts)+1)
+	var wg sync.WaitGroup
+	wg.Add(len(inboxes))
+	go func() {
+		defer wg.Done()
+		// The user's own account goes through the usual path, like any single-account listing
+		emails, total, _, err := u.getEmailsByMailbox(userID, "INBOX", depth, 0, "", "")
+		inboxes[0] = accountInbox{accountID: authdomain.PrimaryAccountID, emails: emails, total: total, err: err}
+	}()
+	for i, account := range accounts {
+		go func(i int, account *authdomain.LinkedAccount) {
+			defer wg.Done()
+			emails, total, err := u.getLinkedInbox(account, depth)
+			inboxes[i+1] = accountInbox{accountID: account.ID, emails: emails, total: total, err: err}
+		}(i, account)
+	}
+	wg.Wait()
+
+	result := &emaildomain.UnifiedInbox{Limit: limit, Offset: offset}
+	var merged []*emaildomain.Email
+	for _, inbox := range inboxes {
+		if inbox.err != nil {
+			log.Printf("Unified inbox: failed to read account %s of user %s: %v", inbox.accountID, userID, inbox.err)
+			result.FailedAccounts = append(result.FailedAccounts, inbox.accountID)
+			continue
+		}
+		for _, email := range inbox.emails {
+			email.AccountID = inbox.accountID
+		}
+		merged = append(merged, inbox.emails...)
+		result.Total += inbox.total
+	}
+	if len(result.FailedAccounts) == len(inboxes) {
+		return nil, inboxes[0].err
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].ReceivedAt.After(merged[j].ReceivedAt)
+	})
+	if offset > len(merged) {
+		offset = len(merged)
+	}
+	end := offset + limit
+	if end > len(merged) {
+		end = len(merged)
+	}
+	result.Emails = merged[offset:end]
+	return result, nil
+}
+
+// getLinkedInbox reads the newest emails of a linked account's inbox
+func (u *emailUsecase) getLinkedInbox(account *authdomain.LinkedAccount, limit int) ([]*emaildomain.Email, int, error) {
+	ctx := context.Background()
+	switch account.Provider {
+	case "imap":
+		password, err := u.config.Keyring.Decrypt(account.ImapPassword)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to decrypt password: %w", err)
+		}
+		return u.imapProvider.GetEmails(ctx, account.ImapServer, account.ImapPort, account.Email, password, "INBOX", limit, 0)
+	case "google":
+		return u.mailProvider.GetEmails(ctx, account.AccessToken, account.RefreshToken, "INBOX", limit, 0, "", u.makeLinkedTokenCallback(account))
+	default:
+		return nil, 0, fmt.Errorf("unsupported provider %q", account.Provider)
+	}
+}
+
+// makeLinkedTokenCallback stores refreshed Google tokens on the linked account
+func (u *emailUsecase) makeLinkedTokenCallback(account *authdomain.LinkedAccount) emaildomain.TokenUpdateFunc {
+	return func(token *oauth2.Token) error {
+		account.AccessToken = token.AccessToken
+		if token.RefreshToken != "" {
+			account.RefreshToken = token.RefreshToken
+		}
+		account.TokenExpiry = token.Expiry
+		return u.userRepo.SaveLinkedAccount(account)
+	}
+}
@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/pkg/safehttp"
+	"ga03-backend/pkg/utils/mailutil"
+)
+
+var (
+	ErrNoUnsubscribe          = errors.New("email has no unsubscribe information")
+	ErrUnsubscribeNotOneClick = errors.New("sender does not support one-click unsubscribe, use the unsubscribe link instead")
+	ErrUnsubscribeBlocked     = errors.New("unsubscribe link points at an address that isn't allowed")
+	ErrUnsubscribeFailed      = errors.New("the sender's unsubscribe request failed")
+)
+
+// Unsubscribe performs the List-Unsubscribe action advertised by the email's sender.
+// One-click HTTP unsubscribe (RFC 8058) is preferred; otherwise the mailto address is used.
+// The sender picks the URL, so the request may only reach public hosts.
+func (u *emailUsecase) Unsubscribe(userID, emailID string) error {
+	email, err := u.GetEmailByID(userID, emailID)
+	if err != nil {
+		return err
+	}
+	if email == nil {
+		return emaildomain.ErrEmailNotFound
+	}
+
+	if email.UnsubscribeURL != "" && email.UnsubscribeOneClick {
+		if err := safehttp.ValidateURL(email.UnsubscribeURL); err != nil {
+			return ErrUnsubscribeBlocked
+		}
+		resp, err := u.linkClient.Post(email.UnsubscribeURL, "application/x-www-form-urlencoded", strings.NewReader("List-Unsubscribe=One-Click"))
+		if err != nil {
+			// Redirects and resolved addresses are checked as they are followed
+			if errors.Is(err, safehttp.ErrBlockedURL) {
+				return ErrUnsubscribeBlocked
+			}
+			log.Printf("Unsubscribe request to %s failed: %v", email.UnsubscribeURL, err)
+			return ErrUnsubscribeFailed
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("Unsubscribe request to %s failed with status %d", email.UnsubscribeURL, resp.StatusCode)
+			return ErrUnsubscribeFailed
+		}
+		return nil
+	}
+
+	if email.UnsubscribeMailto != "" {
+		to, subject, body, err := mailutil.ParseMailto(email.UnsubscribeMailto)
+		if err != nil || to == "" {
+			return fmt.Errorf("invalid unsubscribe mailto %s: %w", email.UnsubscribeMailto, ErrNoUnsubscribe)
+		}
+		if subject == "" {
+			subject = "unsubscribe"
+		}
+		return u.SendEmail(userID, "", to, "", "", subject, body, nil)
+	}
+
+	if email.UnsubscribeURL != "" {
+		return ErrUnsubscribeNotOneClick
+	}
+	return ErrNoUnsubscribe
+}
@@ -0,0 +1,76 @@
+package usecase
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+func TestUnsubscribeRejectsInternalURLs(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"loopback test server", server.URL + "/unsubscribe"},
+		{"cloud metadata", "http://169.254.169.254/latest/meta-data/"},
+		{"private network", "http://10.0.0.5/unsubscribe"},
+		{"localhost name", "http://localhost:8080/api/auth/logout-all"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+			deps.provider.emails["m1"] = &emaildomain.Email{ID: "m1", UnsubscribeURL: tt.url, UnsubscribeOneClick: true}
+
+			if err := uc.Unsubscribe("u1", "m1"); !errors.Is(err, ErrUnsubscribeBlocked) {
+				t.Fatalf("Unsubscribe() error = %v, want ErrUnsubscribeBlocked", err)
+			}
+		})
+	}
+	if n := hits.Load(); n != 0 {
+		t.Errorf("internal server got %d requests, want none", n)
+	}
+}
+
+func TestUnsubscribeMailto(t *testing.T) {
+	uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+	deps.provider.emails["m1"] = &emaildomain.Email{
+		ID:                "m1",
+		UnsubscribeURL:    "https://example.com/unsub",
+		UnsubscribeMailto: "mailto:leave@lists.example.com?subject=remove%20me",
+	}
+
+	// Without One-Click the mailto is used, even if there is a URL
+	if err := uc.Unsubscribe("u1", "m1"); err != nil {
+		t.Fatalf("Unsubscribe() error = %v", err)
+	}
+	if len(deps.provider.sent) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(deps.provider.sent))
+	}
+	sent := deps.provider.sent[0]
+	if sent.to != "leave@lists.example.com" || sent.subject != "remove me" {
+		t.Errorf("sent to %q with subject %q", sent.to, sent.subject)
+	}
+}
+
+func TestUnsubscribeWithoutInfo(t *testing.T) {
+	uc, deps := newTestUsecase(t, nil, gmailUser("u1"))
+	deps.provider.emails["plain"] = &emaildomain.Email{ID: "plain"}
+	deps.provider.emails["link"] = &emaildomain.Email{ID: "link", UnsubscribeURL: "https://example.com/unsub"}
+
+	if err := uc.Unsubscribe("u1", "plain"); !errors.Is(err, ErrNoUnsubscribe) {
+		t.Errorf("no headers: error = %v, want ErrNoUnsubscribe", err)
+	}
+	if err := uc.Unsubscribe("u1", "link"); !errors.Is(err, ErrUnsubscribeNotOneClick) {
+		t.Errorf("link without One-Click: error = %v, want ErrUnsubscribeNotOneClick", err)
+	}
+}
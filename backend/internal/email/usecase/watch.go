@@ -0,0 +1,87 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+)
+
+const (
+	// Gmail watches lapse after about 7 days, and nothing is pushed after that
+	watchCheckInterval = time.Hour
+	watchRenewBefore   = 24 * time.Hour
+)
+
+func (u *emailUsecase) startWatchRenewer(ctx context.Context) {
+	// Check right away so watches that lapsed while the server was down resume
+	u.background.Add(1)
+	go func() {
+		defer u.background.Done()
+		u.renewExpiringWatches()
+	}()
+	u.every(ctx, watchCheckInterval, u.renewExpiringWatches)
+}
+
+// renewExpiringWatches re-calls Watch for every user whose watch lapses within a day.
+// A failed renewal is retried on the next check.
+func (u *emailUsecase) renewExpiringWatches() {
+	users, err := u.userRepo.FindWatchesExpiringBefore(time.Now().Add(watchRenewBefore))
+	if err != nil {
+		log.Printf("Failed to load expiring Gmail watches: %v", err)
+		return
+	}
+	for _, user := range users {
+		if err := u.WatchMailbox(user.ID); err != nil {
+			log.Printf("Failed to renew Gmail watch for user %s: %v", user.ID, err)
+			continue
+		}
+		log.Printf("Renewed Gmail watch for user %s (was expiring %s)", user.ID, user.WatchExpiration.Format(time.RFC3339))
+	}
+}
+
+// GetNewGmailMessages returns the inbox arrivals since the user's last processed history
+// ID and moves it forward to historyID, the one a push notification carried. Without a
+// usable starting point nothing is returned and tracking starts from historyID.
+func (u *emailUsecase) GetNewGmailMessages(userID string, historyID uint64) ([]*emaildomain.Email, error) {
+	u.historyMu.Lock()
+	defer u.historyMu.Unlock()
+
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	if user.AccessToken == "" || user.LastHistoryID >= historyID {
+		return nil, nil
+	}
+
+	var emails []*emaildomain.Email
+	latest := historyID
+	if user.LastHistoryID != 0 {
+		var read uint64
+		emails, read, err = u.mailProvider.GetNewMessages(context.Background(), user.AccessToken, user.RefreshToken, user.LastHistoryID, u.makeTokenUpdateCallback(userID))
+		if err != nil && !errors.Is(err, emaildomain.ErrWatermarkExpired) {
+			return nil, err
+		}
+		if read > latest {
+			latest = read
+		}
+
+		// Reload since the token callback may have updated the user
+		if user, err = u.userRepo.FindByID(userID); err != nil || user == nil {
+			return nil, err
+		}
+	}
+
+	user.LastHistoryID = latest
+	if err := u.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	if len(emails) > 0 {
+		u.invalidateStats(userID)
+		go u.recordReceivedContacts(userID, emails)
+	}
+	return emails, nil
+}
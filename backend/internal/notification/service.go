@@ -5,55 +5,161 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	authrepo "ga03-backend/internal/auth/repository"
+	emaildomain "ga03-backend/internal/email/domain"
 	"ga03-backend/pkg/sse"
+	"ga03-backend/pkg/utils/mailutil"
 
 	"cloud.google.com/go/pubsub"
 	"google.golang.org/api/option"
 )
 
+// Wait before restarting a listener that stopped on its own; tests shorten them
+var (
+	receiveMinBackoff = 5 * time.Second
+	receiveMaxBackoff = 5 * time.Minute
+)
+
+// newPubsubClient creates the Pub/Sub client; tests swap it for one on a fake server
+var newPubsubClient = pubsub.NewClient
+
 type GmailNotification struct {
 	EmailAddress string `json:"emailAddress"`
 	HistoryID    uint64 `json:"historyId"`
 }
 
+// NewMessagesFunc returns a user's messages that arrived up to a Gmail history ID
+type NewMessagesFunc func(userID string, historyID uint64) ([]*emaildomain.Email, error)
+
 type Service struct {
-	pubsubClient *pubsub.Client
-	sseManager   *sse.Manager
-	userRepo     authrepo.UserRepository
-	projectID    string
-	topicName    string
-	subName      string
+	sseManager  *sse.Manager
+	userRepo    authrepo.UserRepository
+	projectID   string
+	topicName   string
+	subName     string
+	newMessages NewMessagesFunc
+
+	// Credentials rotation: the key file is re-read on SIGHUP or when its mtime changes
+	credentialsFile  string
+	checkInterval    time.Duration
+	credentialsMTime time.Time
+
+	mu            sync.Mutex
+	pubsubClient  *pubsub.Client
+	receiving     *pubsub.Client // Client the running Receive uses
+	cancelReceive context.CancelFunc
+	reloaded      chan struct{}
+}
+
+func NewService(projectID, topicName string, sseManager *sse.Manager, userRepo authrepo.UserRepository, credentialsFile string, checkInterval time.Duration) (*Service, error) {
+	s := &Service{
+		sseManager:      sseManager,
+		userRepo:        userRepo,
+		projectID:       projectID,
+		topicName:       topicName,
+		subName:         topicName + "-sub", // Convention: topic-sub
+		credentialsFile: credentialsFile,
+		checkInterval:   checkInterval,
+		reloaded:        make(chan struct{}, 1),
+	}
+	s.credentialsMTime = s.credentialsModTime()
+
+	client, err := s.newClient(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	s.pubsubClient = client
+	return s, nil
 }
 
-func NewService(projectID, topicName string, sseManager *sse.Manager, userRepo authrepo.UserRepository, credentialsFile string) (*Service, error) {
-	ctx := context.Background()
-	
+// SetNewMessagesFunc makes notifications push each new message as a "new_email"
+// event, so clients can show it without refetching
+func (s *Service) SetNewMessagesFunc(fn NewMessagesFunc) {
+	s.mu.Lock()
+	s.newMessages = fn
+	s.mu.Unlock()
+}
+
+func (s *Service) newClient(ctx context.Context) (*pubsub.Client, error) {
 	var opts []option.ClientOption
-	if credentialsFile != "" {
-		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	if s.credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(s.credentialsFile))
 	}
 
-	client, err := pubsub.NewClient(ctx, projectID, opts...)
+	client, err := newPubsubClient(ctx, s.projectID, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create pubsub client: %v", err)
 	}
-
-	return &Service{
-		pubsubClient: client,
-		sseManager:   sseManager,
-		userRepo:     userRepo,
-		projectID:    projectID,
-		topicName:    topicName,
-		subName:      topicName + "-sub", // Convention: topic-sub
-	}, nil
+	return client, nil
 }
 
+// Start listens for Gmail notifications until ctx is done. When the credentials are
+// reloaded it restarts the listener on the new client, and a listener that stops on
+// its own is restarted with backoff.
 func (s *Service) Start(ctx context.Context) {
+	go s.watchCredentials(ctx)
+
+	backoff := receiveMinBackoff
+	for {
+		recvCtx, cancel := context.WithCancel(ctx)
+		s.mu.Lock()
+		client := s.pubsubClient
+		s.receiving = client
+		s.cancelReceive = cancel
+		s.mu.Unlock()
+
+		started := time.Now()
+		s.receive(recvCtx, client)
+		cancel()
+
+		// A listener that stayed up for a while was healthy, start over with a short wait
+		if time.Since(started) > receiveMaxBackoff {
+			backoff = receiveMinBackoff
+		}
+
+		// Receive only returns once every in-flight callback has acked, so the old
+		// client can be closed without losing acks
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.reloaded:
+			if err := client.Close(); err != nil {
+				log.Printf("Error closing old pubsub client: %v", err)
+			}
+			backoff = receiveMinBackoff
+		case <-time.After(backoff):
+			log.Printf("Restarting pubsub listener on %s", s.subName)
+			backoff = min(backoff*2, receiveMaxBackoff)
+		}
+	}
+}
+
+// CheckSubscription reports an error unless the Gmail notification subscription
+// exists and the current client can reach it
+func (s *Service) CheckSubscription(ctx context.Context) error {
+	s.mu.Lock()
+	client := s.pubsubClient
+	s.mu.Unlock()
+
+	exists, err := client.Subscription(s.subName).Exists(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check subscription %s: %w", s.subName, err)
+	}
+	if !exists {
+		return fmt.Errorf("subscription %s does not exist", s.subName)
+	}
+	return nil
+}
+
+func (s *Service) receive(ctx context.Context, client *pubsub.Client) {
 	// Ensure subscription exists
-	sub := s.pubsubClient.Subscription(s.subName)
+	sub := client.Subscription(s.subName)
 	exists, err := sub.Exists(ctx)
 	if err != nil {
 		log.Printf("Error checking subscription existence: %v", err)
@@ -61,8 +167,8 @@ func (s *Service) Start(ctx context.Context) {
 	}
 
 	if !exists {
-		topic := s.pubsubClient.Topic(s.topicName)
-		sub, err = s.pubsubClient.CreateSubscription(ctx, s.subName, pubsub.SubscriptionConfig{
+		topic := client.Topic(s.topicName)
+		sub, err = client.CreateSubscription(ctx, s.subName, pubsub.SubscriptionConfig{
 			Topic:       topic,
 			AckDeadline: 10 * time.Second,
 		})
@@ -83,6 +189,81 @@ func (s *Service) Start(ctx context.Context) {
 	}
 }
 
+// watchCredentials reloads the client on SIGHUP, and when the credentials file changes
+// if a check interval is configured
+func (s *Service) watchCredentials(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if s.checkInterval > 0 && s.credentialsFile != "" {
+		ticker := time.NewTicker(s.checkInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Printf("SIGHUP received, reloading pubsub credentials")
+			s.Reload(ctx)
+		case <-tick:
+			modTime := s.credentialsModTime()
+			if modTime.IsZero() || modTime.Equal(s.credentialsMTime) {
+				continue
+			}
+			s.credentialsMTime = modTime
+			log.Printf("Credentials file %s changed, reloading pubsub client", s.credentialsFile)
+			s.Reload(ctx)
+		}
+	}
+}
+
+func (s *Service) credentialsModTime() time.Time {
+	if s.credentialsFile == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(s.credentialsFile)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// Reload creates a client from the current credentials and restarts the listener on it.
+// If the new client can't be created the current one is kept.
+func (s *Service) Reload(ctx context.Context) {
+	client, err := s.newClient(ctx)
+	if err != nil {
+		log.Printf("Keeping current pubsub client: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	replaced := s.pubsubClient
+	// A client from an earlier reload that never started receiving; the receiving one
+	// is closed by Start once its Receive has drained
+	unused := replaced != nil && replaced != s.receiving
+	s.pubsubClient = client
+	cancel := s.cancelReceive
+	s.mu.Unlock()
+
+	if unused {
+		replaced.Close()
+	}
+
+	select {
+	case s.reloaded <- struct{}{}:
+	default:
+	}
+	if cancel != nil {
+		cancel()
+	}
+}
+
 func (s *Service) handleMessage(ctx context.Context, msg *pubsub.Message) {
 	var notification GmailNotification
 	if err := json.Unmarshal(msg.Data, &notification); err != nil {
@@ -103,7 +284,29 @@ func (s *Service) handleMessage(ctx context.Context, msg *pubsub.Message) {
 		return
 	}
 
-	// Notify user via SSE
+	s.mu.Lock()
+	newMessages := s.newMessages
+	s.mu.Unlock()
+	if newMessages != nil {
+		emails, err := newMessages(user.ID, notification.HistoryID)
+		if err != nil {
+			log.Printf("Failed to load new messages for %s: %v", notification.EmailAddress, err)
+		}
+		for _, email := range emails {
+			// Remote images stay blocked here too, until the user opens the email and loads them
+			if email.IsHTML {
+				view := *email
+				view.Body, view.ImagesBlocked = mailutil.BlockRemoteImages(email.Body)
+				email = &view
+			}
+			s.sseManager.SendToUser(user.ID, "new_email", map[string]interface{}{
+				"message":   email,
+				"historyId": notification.HistoryID,
+			})
+		}
+	}
+
+	// Labels, reads and deletions still only get the generic ping
 	s.sseManager.SendToUser(user.ID, "email_update", map[string]interface{}{
 		"email":     notification.EmailAddress,
 		"historyId": notification.HistoryID,
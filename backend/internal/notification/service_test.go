@@ -0,0 +1,218 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func fakePubsub(t *testing.T) *pubsub.Client {
+	t.Helper()
+	server := pstest.NewServer()
+	t.Cleanup(func() { server.Close() })
+
+	conn, err := grpc.NewClient(server.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := pubsub.NewClient(t.Context(), "project", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// A listener that gives up while Start is still running, here because the topic
+// doesn't exist yet, is retried instead of waiting for a reload that never comes
+func TestStartRetriesReceive(t *testing.T) {
+	savedMin, savedMax := receiveMinBackoff, receiveMaxBackoff
+	receiveMinBackoff, receiveMaxBackoff = 10*time.Millisecond, 50*time.Millisecond
+	t.Cleanup(func() { receiveMinBackoff, receiveMaxBackoff = savedMin, savedMax })
+
+	client := fakePubsub(t)
+	s := &Service{
+		pubsubClient: client,
+		topicName:    "gmail",
+		subName:      "gmail-sub",
+		reloaded:     make(chan struct{}, 1),
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		s.Start(ctx)
+		close(done)
+	}()
+
+	// Let the first attempts fail, then create the topic
+	time.Sleep(30 * time.Millisecond)
+	if _, err := client.CreateTopic(t.Context(), "gmail"); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if exists, _ := client.Subscription("gmail-sub").Exists(t.Context()); exists {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the listener wasn't restarted once the topic existed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start didn't return after its context was cancelled")
+	}
+}
+
+// fakeClients makes newPubsubClient hand out clients on one fake server. It returns the
+// clients created so far, and fail, which makes the next ones fail to connect.
+func fakeClients(t *testing.T) (clients func() []*pubsub.Client, fail func()) {
+	t.Helper()
+	server := pstest.NewServer()
+	t.Cleanup(func() { server.Close() })
+
+	var mu sync.Mutex
+	var created []*pubsub.Client
+	failing := false
+
+	saved := newPubsubClient
+	newPubsubClient = func(ctx context.Context, projectID string, _ ...option.ClientOption) (*pubsub.Client, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failing {
+			return nil, errors.New("invalid credentials")
+		}
+		conn, err := grpc.NewClient(server.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return nil, err
+		}
+		t.Cleanup(func() { conn.Close() })
+		client, err := pubsub.NewClient(ctx, projectID, option.WithGRPCConn(conn))
+		if err == nil {
+			created = append(created, client)
+		}
+		return client, err
+	}
+	t.Cleanup(func() { newPubsubClient = saved })
+
+	clients = func() []*pubsub.Client {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*pubsub.Client(nil), created...)
+	}
+	fail = func() {
+		mu.Lock()
+		failing = true
+		mu.Unlock()
+	}
+	return clients, fail
+}
+
+// waitReceiving waits until Start's listener runs on client
+func waitReceiving(t *testing.T, s *Service, client func() *pubsub.Client) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.Lock()
+		receiving := s.receiving
+		s.mu.Unlock()
+		if want := client(); want != nil && receiving == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the listener didn't start on the expected client")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCredentialsChangeRecreatesClient(t *testing.T) {
+	clients, _ := fakeClients(t)
+	keyFile := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(keyFile, []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewService("project", "gmail", nil, nil, keyFile, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clients()[0].CreateTopic(t.Context(), "gmail"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		s.Start(ctx)
+		close(done)
+	}()
+	nth := func(i int) func() *pubsub.Client {
+		return func() *pubsub.Client {
+			if c := clients(); len(c) > i {
+				return c[i]
+			}
+			return nil
+		}
+	}
+	waitReceiving(t, s, nth(0))
+
+	// An untouched key file doesn't cause reloads
+	time.Sleep(50 * time.Millisecond)
+	if n := len(clients()); n != 1 {
+		t.Fatalf("%d clients were created without a credentials change, want 1", n)
+	}
+
+	rotated := time.Now().Add(time.Hour)
+	if err := os.Chtimes(keyFile, rotated, rotated); err != nil {
+		t.Fatal(err)
+	}
+	waitReceiving(t, s, nth(1))
+	time.Sleep(50 * time.Millisecond)
+	if n := len(clients()); n != 2 {
+		t.Errorf("one key change created %d clients, want 2 in total", n)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start didn't return after its context was cancelled")
+	}
+}
+
+func TestReloadKeepsClientOnError(t *testing.T) {
+	clients, fail := fakeClients(t)
+	s, err := NewService("project", "gmail", nil, nil, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancelled := false
+	s.cancelReceive = func() { cancelled = true }
+
+	fail()
+	s.Reload(t.Context())
+
+	if s.pubsubClient != clients()[0] {
+		t.Error("the working client was replaced after the new one failed")
+	}
+	if cancelled || len(s.reloaded) != 0 {
+		t.Error("the listener was restarted though nothing was reloaded")
+	}
+}
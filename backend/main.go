@@ -2,20 +2,26 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 
 	api "ga03-backend/cmd/api"
 	authdomain "ga03-backend/internal/auth/domain"
 	authRepo "ga03-backend/internal/auth/repository"
 	authUsecase "ga03-backend/internal/auth/usecase"
+	emaildomain "ga03-backend/internal/email/domain"
 	emailRepo "ga03-backend/internal/email/repository"
 	emailUsecase "ga03-backend/internal/email/usecase"
 	"ga03-backend/internal/notification"
 	"ga03-backend/pkg/config"
 	"ga03-backend/pkg/database"
 	"ga03-backend/pkg/gmail"
+	"ga03-backend/pkg/health"
 	"ga03-backend/pkg/imap"
 	"ga03-backend/pkg/sse"
 )
@@ -23,6 +29,11 @@ import (
 func main() {
 	// Load configuration
 	cfg := config.Load()
+	keyring, err := cfg.NewKeyring()
+	if err != nil {
+		log.Fatal("Invalid encryption key configuration:", err)
+	}
+	cfg.Keyring = keyring
 
 	// Initialize database
 	db, err := database.NewPostgresConnection(cfg)
@@ -30,21 +41,62 @@ func main() {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
+	// Refresh tokens used to be keyed by the token itself, one per user. Existing tokens
+	// become sessions with new IDs, so nobody is signed out.
+	legacySessions, err := authRepo.MigrateLegacyRefreshTokens(db)
+	if err != nil {
+		log.Fatal("Failed to migrate refresh tokens:", err)
+	}
+
+	// Google users from before the scope check were all asked for mail access at sign-in
+	backfillGmailScope := !db.Migrator().HasColumn(&authdomain.User{}, "HasGmailScope")
+
 	// Auto-migrate database schemas
-	if err := db.AutoMigrate(&authdomain.User{}, &authdomain.RefreshToken{}); err != nil {
+	if err := db.AutoMigrate(&authdomain.User{}, &authdomain.RefreshToken{}, &authdomain.UsedResetToken{}, &emaildomain.MailboxSyncState{}, &emaildomain.KanbanStatus{}, &emaildomain.ScheduledEmail{}, &emaildomain.ScheduledAttachment{}, &emaildomain.EmailSummary{}, &emaildomain.Contact{}, &authdomain.LinkedAccount{}); err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
+	if legacySessions {
+		if err := authRepo.BackfillLegacySessions(db); err != nil {
+			log.Fatal("Failed to migrate refresh tokens:", err)
+		}
+	}
+	if backfillGmailScope {
+		if err := db.Model(&authdomain.User{}).Where("provider = ?", "google").Update("has_gmail_scope", true).Error; err != nil {
+			log.Fatal("Failed to migrate database:", err)
+		}
+	}
 
 	// Initialize repositories (dependency injection)
 	userRepo := authRepo.NewUserRepository(db)
 	emailRepository := emailRepo.NewEmailRepository()
+	syncStateRepository := emailRepo.NewSyncStateRepository(db)
+	kanbanRepository := emailRepo.NewKanbanRepository(db)
+	scheduledRepository := emailRepo.NewScheduledEmailRepository(db)
+	summaryRepository := emailRepo.NewSummaryRepository(db)
+	contactRepository := emailRepo.NewContactRepository(db)
+
+	// SIGINT/SIGTERM cancel ctx, which stops the listeners and background jobs and
+	// starts a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	var background sync.WaitGroup
 
 	// Initialize SSE Manager
-	sseManager := sse.NewManager()
-	go sseManager.Run()
+	sseManager := sse.NewManager(cfg.SSEBroadcastWorkers, cfg.SSEOverflowPolicy, cfg.SSESendTimeout, cfg.SSEHeartbeat, cfg.SSEMaxPerUser)
+
+	// Readiness covers the database and, when configured, the Gmail Pub/Sub subscription
+	healthChecker := health.NewChecker()
+	healthChecker.Add("database", func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
 
 	// Initialize Notification Service (Pub/Sub)
 	// Only start if project ID is configured
+	var notifService *notification.Service
 	if cfg.GoogleProjectID != "" {
 		// Extract short topic name from full resource name if necessary
 		topicName := cfg.GooglePubSubTopic
@@ -55,11 +107,20 @@ func main() {
 			topicName = "gmail-updates"
 		}
 
-		notifService, err := notification.NewService(cfg.GoogleProjectID, topicName, sseManager, userRepo, cfg.GoogleCredentials)
+		notifService, err = notification.NewService(cfg.GoogleProjectID, topicName, sseManager, userRepo, cfg.GoogleCredentials, cfg.GoogleCredsCheck)
 		if err != nil {
 			log.Printf("Failed to initialize notification service: %v", err)
+			initErr := err
+			healthChecker.Add("pubsub", func(context.Context) error {
+				return fmt.Errorf("notification service failed to start: %w", initErr)
+			})
 		} else {
-			go notifService.Start(context.Background())
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				notifService.Start(ctx)
+			}()
+			healthChecker.Add("pubsub", notifService.CheckSubscription)
 		}
 	}
 
@@ -71,10 +132,37 @@ func main() {
 
 	// Initialize use cases (dependency injection)
 	authUsecaseInstance := authUsecase.NewAuthUsecase(userRepo, cfg)
-	emailUsecaseInstance := emailUsecase.NewEmailUsecase(emailRepository, userRepo, gmailService, imapService, cfg, cfg.GooglePubSubTopic)
+	emailUsecaseInstance := emailUsecase.NewEmailUsecase(emailRepository, syncStateRepository, kanbanRepository, scheduledRepository, summaryRepository, contactRepository, userRepo, gmailService, imapService, cfg, cfg.GooglePubSubTopic)
+
+	// A key change that can't read the stored IMAP passwords would lock those users out
+	if err := authUsecaseInstance.CheckEncryptionKey(); err != nil {
+		log.Fatal("Encryption key check failed:", err)
+	}
+
+	// "rotate-encryption-key" moves every stored password to the current key and exits
+	if len(os.Args) > 1 && os.Args[1] == "rotate-encryption-key" {
+		rotated, err := authUsecaseInstance.RotateEncryptionKey()
+		log.Printf("Re-encrypted %d IMAP passwords with key version %d", rotated, cfg.EncryptionVersion)
+		if err != nil {
+			log.Fatal("Key rotation incomplete:", err)
+		}
+		return
+	}
+
+	// IMAP users get new mail pushed through IDLE instead of Pub/Sub
+	emailUsecaseInstance.SetNotifier(sseManager.SendToUser)
+	if notifService != nil {
+		notifService.SetNewMessagesFunc(emailUsecaseInstance.GetNewGmailMessages)
+	}
+	authUsecaseInstance.OnLogout(emailUsecaseInstance.StopIdle)
+	authUsecaseInstance.OnImapCredentialsChange(emailUsecaseInstance.RestartIdle)
+	// One IDLE watcher serves all of a user's tabs, and only while one is open
+	sseManager.OnPresence(emailUsecaseInstance.StartIdle, emailUsecaseInstance.StopIdle)
+	go sseManager.Run()
+	emailUsecaseInstance.Start(ctx)
 
 	// Initialize HTTP handler
-	handler := api.NewHandler(authUsecaseInstance, emailUsecaseInstance, sseManager, cfg)
+	handler := api.NewHandler(authUsecaseInstance, emailUsecaseInstance, sseManager, cfg, healthChecker)
 
 	// Start server
 
@@ -85,7 +173,25 @@ func main() {
 	}
 
 	log.Printf("Server starting on port %s", port)
-	if err := handler.Start(":" + port); err != nil {
+	if err := handler.Start(ctx, ":"+port); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
+
+	// The server is down; let the jobs and Pub/Sub callbacks already running finish
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := emailUsecaseInstance.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Background jobs still running at shutdown: %v", err)
+	}
+	notifDone := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(notifDone)
+	}()
+	select {
+	case <-notifDone:
+	case <-shutdownCtx.Done():
+		log.Printf("Notification listener still running at shutdown")
+	}
+	log.Println("Server stopped")
 }
@@ -0,0 +1,97 @@
+package apierror
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+// Codes are the machine-readable part of an error response. Clients branch on the
+// code; the message is for people and may change.
+const (
+	CodeBadRequest      = "bad_request"
+	CodeUnauthorized    = "unauthorized"
+	CodeReauthRequired  = "reauth_required" // The mail provider no longer accepts the stored credentials
+	CodeForbidden       = "forbidden"
+	CodeNotFound        = "not_found"
+	CodeConflict        = "conflict"
+	CodeQuotaExceeded   = "quota_exceeded"
+	CodeRateLimited     = "rate_limited" // This server's own throttle, see Retry-After
+	CodeProviderFailure = "provider_failure"
+	CodeUnavailable     = "unavailable"
+	CodeInternal        = "internal_error"
+)
+
+// Mapping translates a sentinel error, matched with errors.Is, into a response
+type Mapping struct {
+	Err    error
+	Status int
+	Code   string
+}
+
+// Respond writes err as {"error": message, "code": code}. Errors matching one of
+// mappings keep their message. Mail provider failures are classified from the Google
+// API and OAuth errors they wrap. Anything else is logged and answered with a generic
+// 500, so internal details such as decryption or database failures don't reach clients.
+func Respond(c *gin.Context, err error, mappings []Mapping) {
+	status, code, message := Classify(err, mappings)
+	if status == http.StatusInternalServerError {
+		log.Printf("%s %s: %v", c.Request.Method, c.FullPath(), err)
+	}
+	Write(c, status, code, message)
+}
+
+// Write writes the {"error": message, "code": code} shape for an error a handler
+// detected itself
+func Write(c *gin.Context, status int, code, message string) {
+	c.JSON(status, gin.H{"error": message, "code": code})
+}
+
+// Classify returns the status, code and client-facing message for err
+func Classify(err error, mappings []Mapping) (int, string, string) {
+	for _, m := range mappings {
+		if errors.Is(err, m.Err) {
+			return m.Status, m.Code, m.Err.Error()
+		}
+	}
+
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		if retrieveErr.ErrorCode == "invalid_grant" {
+			return http.StatusForbidden, CodeReauthRequired, "mail provider access has expired, please sign in again"
+		}
+		return http.StatusBadGateway, CodeProviderFailure, "mail provider sign-in failed"
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return classifyGoogleError(apiErr)
+	}
+
+	return http.StatusInternalServerError, CodeInternal, "internal server error"
+}
+
+func classifyGoogleError(apiErr *googleapi.Error) (int, string, string) {
+	switch apiErr.Code {
+	case http.StatusUnauthorized:
+		return http.StatusForbidden, CodeReauthRequired, "mail provider access has expired, please sign in again"
+	case http.StatusNotFound:
+		return http.StatusNotFound, CodeNotFound, "not found at the mail provider"
+	case http.StatusTooManyRequests:
+		return http.StatusTooManyRequests, CodeQuotaExceeded, "mail provider quota exceeded, try again later"
+	case http.StatusForbidden:
+		// Gmail reports rate limits as 403 with a reason
+		for _, item := range apiErr.Errors {
+			switch item.Reason {
+			case "rateLimitExceeded", "userRateLimitExceeded", "dailyLimitExceeded", "quotaExceeded":
+				return http.StatusTooManyRequests, CodeQuotaExceeded, "mail provider quota exceeded, try again later"
+			}
+		}
+		return http.StatusForbidden, CodeForbidden, "the mail provider refused the request"
+	}
+	return http.StatusBadGateway, CodeProviderFailure, "the mail provider failed, try again later"
+}
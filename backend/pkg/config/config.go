@@ -1,31 +1,85 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"ga03-backend/pkg/utils/crypto"
+
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Config struct {
-	Port               string
-	JWTSecret          string
-	JWTAccessExpiry    time.Duration
-	JWTRefreshExpiry   time.Duration
-	GoogleClientID     string
-	GoogleClientSecret string
-	GoogleRedirectURI  string
-	GoogleProjectID    string
-	GooglePubSubTopic  string
-	GoogleCredentials  string // Path to service account JSON
-	DBHost             string
-	DBPort             string
-	DBUser             string
-	DBPassword         string
-	DBName             string
-	DBSSLMode          string
-	GeminiApiKey       string
-	EncryptionKey      string // 32-byte key for AES encryption
+	Port                string
+	JWTSecret           string
+	JWTAccessExpiry     time.Duration
+	JWTRefreshExpiry    time.Duration
+	GoogleClientID      string
+	GoogleClientSecret  string
+	GoogleRedirectURI   string
+	GoogleProjectID     string
+	GooglePubSubTopic   string
+	GoogleCredentials   string        // Path to service account JSON
+	GoogleCredsCheck    time.Duration // How often the credentials file is checked for rotation, 0 disables
+	DBHost              string
+	DBPort              string
+	DBUser              string
+	DBPassword          string
+	DBName              string
+	DBSSLMode           string
+	GeminiApiKey        string
+	GeminiModel         string          // e.g. gemini-2.5-flash, or gemini-2.5-pro for better quality at a higher cost
+	GeminiTemperature   float64         // Negative keeps the model's default
+	GeminiMaxTokens     int             // Max output tokens per answer, 0 keeps the model's default
+	EncryptionKey       string          // 32-byte key for AES encryption
+	EncryptionVersion   int             // Generation of EncryptionKey, stored with every ciphertext
+	OldEncryptionKeys   []string        // "version:key" pairs still needed to read older ciphertext
+	Keyring             *crypto.Keyring // Built from the keys above at startup, see NewKeyring
+	BcryptCost          int
+	SSEBroadcastWorkers int           // Max concurrent SSE broadcast deliveries
+	SSEOverflowPolicy   string        // drop_client, drop_oldest or block_with_timeout
+	SSESendTimeout      time.Duration // Wait before dropping a client under block_with_timeout
+	SSEHeartbeat        time.Duration // Keepalive comment interval so proxies don't close idle streams, 0 disables
+	SSEMaxPerUser       int           // Open streams per user; the oldest is closed past it, 0 is unlimited
+	AttachmentKeywords  []string      // Phrases that suggest a message should carry an attachment
+	PrefetchWorkers     int           // Max concurrent background body fetches
+	PrefetchMaxEmails   int           // Max emails per prefetch request
+	PublicURL           string        // Origin this API is reachable at from the browser
+	ImageProxyMaxBytes  int           // Largest remote image the proxy will serve
+	ImageProxyCacheTTL  time.Duration // How long proxied images are cached
+	ImageProxyCacheMax  int           // Total bytes of proxied images kept in memory
+	ImageProxySecret    string        // Signs proxied image URLs; random per process when empty
+	AIRateLimit         int           // AI requests a user may burst, refilled over AIRateWindow; 0 disables
+	AIRateWindow        time.Duration
+	AuthRateLimit       int // Sign-in and password requests an IP may burst, refilled over AuthRateWindow; 0 disables
+	AuthRateWindow      time.Duration
+	LoginRateLimit      int // Sign-in attempts an account may burst, refilled over LoginRateWindow; 0 disables
+	LoginRateWindow     time.Duration
+	EmailRateLimit      int // Email API requests a user may burst, refilled over EmailRateWindow; 0 disables
+	EmailRateWindow     time.Duration
+	TrustedProxies      []string      // Proxies whose X-Forwarded-For is believed; none by default
+	CookieDomain        string        // Refresh token cookie domain, empty for the API host only
+	CookieSecure        bool          // Send the cookie over HTTPS only; disable for local HTTP
+	CookieSameSite      string        // none, lax or strict
+	UndoSendDelay       time.Duration // How long a send with undo waits before going out, 0 sends immediately
+	FrontendURL         string        // Origin of the web app, for links in mail the server sends
+	MailServer          string        // SMTP server for the server's own mail (password resets)
+	MailPort            int
+	MailUsername        string
+	MailPassword        string
+	MailFrom            string        // Sender address, defaults to MailUsername
+	PasswordResetExpiry time.Duration // How long a password reset link stays valid
+	ShutdownTimeout     time.Duration // How long in-flight requests and jobs get to finish on SIGTERM
+}
+
+// English and Vietnamese phrases; override with a comma-separated ATTACHMENT_KEYWORDS
+var defaultAttachmentKeywords = []string{
+	"attached", "attachment", "enclosed", "see attached",
+	"đính kèm", "tệp đính kèm", "file đính kèm", "gửi kèm",
 }
 
 func Load() *Config {
@@ -46,25 +100,108 @@ func Load() *Config {
 		}
 	}
 
+	// Hashing and the rehash-on-login check must agree on the cost, so a value bcrypt
+	// can't use is clamped once here instead of being replaced differently by each
+	bcryptCost := min(max(getEnvInt("BCRYPT_COST", 10), bcrypt.MinCost), bcrypt.MaxCost)
+
 	return &Config{
-		Port:               getEnv("PORT", "8080"),
-		JWTSecret:          getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
-		JWTAccessExpiry:    accessExpiry,
-		JWTRefreshExpiry:   refreshExpiry,
-		GoogleClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
-		GoogleClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
-		GoogleRedirectURI:  os.Getenv("GOOGLE_REDIRECT_URI"),
-		GoogleProjectID:    getEnv("GOOGLE_PROJECT_ID", "gomailclient"),
-		GooglePubSubTopic:  getEnv("GOOGLE_PUBSUB_TOPIC", "projects/gomailclient/topics/gmail-updates"),
-		GoogleCredentials:  os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"),
-		DBHost:             os.Getenv("DB_HOST"),
-		DBPort:             getEnv("DB_PORT", "5432"),
-		DBUser:             getEnv("DB_USER", "postgres"),
-		DBPassword:         getEnv("DB_PASSWORD", "postgres"),
-		DBName:             getEnv("DB_NAME", "email_dashboard"),
-		DBSSLMode:          getEnv("DB_SSLMODE", "disable"),
-		GeminiApiKey:       os.Getenv("GEMINI_API_KEY"),
-		EncryptionKey:      getEnv("ENCRYPTION_KEY", "12345678901234567890123456789012"), // Default for dev only
+		Port:                getEnv("PORT", "8080"),
+		JWTSecret:           getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
+		JWTAccessExpiry:     accessExpiry,
+		JWTRefreshExpiry:    refreshExpiry,
+		GoogleClientID:      os.Getenv("GOOGLE_CLIENT_ID"),
+		GoogleClientSecret:  os.Getenv("GOOGLE_CLIENT_SECRET"),
+		GoogleRedirectURI:   os.Getenv("GOOGLE_REDIRECT_URI"),
+		GoogleProjectID:     getEnv("GOOGLE_PROJECT_ID", "gomailclient"),
+		GooglePubSubTopic:   getEnv("GOOGLE_PUBSUB_TOPIC", "projects/gomailclient/topics/gmail-updates"),
+		GoogleCredentials:   os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"),
+		GoogleCredsCheck:    getEnvDuration("GOOGLE_CREDENTIALS_CHECK_INTERVAL", time.Minute),
+		DBHost:              os.Getenv("DB_HOST"),
+		DBPort:              getEnv("DB_PORT", "5432"),
+		DBUser:              getEnv("DB_USER", "postgres"),
+		DBPassword:          getEnv("DB_PASSWORD", "postgres"),
+		DBName:              getEnv("DB_NAME", "email_dashboard"),
+		DBSSLMode:           getEnv("DB_SSLMODE", "disable"),
+		GeminiApiKey:        os.Getenv("GEMINI_API_KEY"),
+		GeminiModel:         getEnv("GEMINI_MODEL", "gemini-2.5-flash"),
+		GeminiTemperature:   getEnvFloat("GEMINI_TEMPERATURE", -1),
+		GeminiMaxTokens:     getEnvInt("GEMINI_MAX_OUTPUT_TOKENS", 0),
+		EncryptionKey:       getEnv("ENCRYPTION_KEY", "12345678901234567890123456789012"), // Default for dev only
+		EncryptionVersion:   getEnvInt("ENCRYPTION_KEY_VERSION", 1),
+		OldEncryptionKeys:   getEnvList("ENCRYPTION_OLD_KEYS", nil),
+		BcryptCost:          bcryptCost,
+		SSEBroadcastWorkers: getEnvInt("SSE_BROADCAST_WORKERS", 4),
+		SSEOverflowPolicy:   getEnv("SSE_OVERFLOW_POLICY", "drop_oldest"),
+		SSESendTimeout:      getEnvDuration("SSE_SEND_TIMEOUT", 100*time.Millisecond),
+		SSEHeartbeat:        getEnvDuration("SSE_HEARTBEAT_INTERVAL", 20*time.Second),
+		SSEMaxPerUser:       getEnvInt("SSE_MAX_CONNECTIONS_PER_USER", 5),
+		AttachmentKeywords:  getEnvList("ATTACHMENT_KEYWORDS", defaultAttachmentKeywords),
+		PrefetchWorkers:     getEnvInt("PREFETCH_WORKERS", 3),
+		PrefetchMaxEmails:   getEnvInt("PREFETCH_MAX_EMAILS", 10),
+		PublicURL:           getEnv("PUBLIC_URL", "http://localhost:8080"),
+		ImageProxyMaxBytes:  getEnvInt("IMAGE_PROXY_MAX_BYTES", 5*1024*1024),
+		ImageProxyCacheTTL:  getEnvDuration("IMAGE_PROXY_CACHE_TTL", time.Hour),
+		ImageProxyCacheMax:  getEnvInt("IMAGE_PROXY_CACHE_BYTES", 64*1024*1024),
+		ImageProxySecret:    getEnv("IMAGE_PROXY_SECRET", ""),
+		AIRateLimit:         getEnvInt("AI_RATE_LIMIT", 5),
+		AIRateWindow:        getEnvDuration("AI_RATE_WINDOW", time.Minute),
+		AuthRateLimit:       getEnvInt("AUTH_RATE_LIMIT", 10),
+		AuthRateWindow:      getEnvDuration("AUTH_RATE_WINDOW", time.Minute),
+		LoginRateLimit:      getEnvInt("LOGIN_RATE_LIMIT", 5),
+		LoginRateWindow:     getEnvDuration("LOGIN_RATE_WINDOW", 15*time.Minute),
+		EmailRateLimit:      getEnvInt("EMAIL_RATE_LIMIT", 120),
+		EmailRateWindow:     getEnvDuration("EMAIL_RATE_WINDOW", time.Minute),
+		TrustedProxies:      getEnvList("TRUSTED_PROXIES", nil),
+		CookieDomain:        os.Getenv("COOKIE_DOMAIN"),
+		CookieSecure:        getEnvBool("COOKIE_SECURE", true),
+		CookieSameSite:      getEnv("COOKIE_SAMESITE", "none"),
+		UndoSendDelay:       getEnvDuration("UNDO_SEND_DELAY", 10*time.Second),
+		FrontendURL:         getEnv("FRONTEND_URL", "http://localhost:5173"),
+		MailServer:          os.Getenv("MAIL_SMTP_SERVER"),
+		MailPort:            getEnvInt("MAIL_SMTP_PORT", 587),
+		MailUsername:        os.Getenv("MAIL_SMTP_USERNAME"),
+		MailPassword:        os.Getenv("MAIL_SMTP_PASSWORD"),
+		MailFrom:            os.Getenv("MAIL_FROM"),
+		PasswordResetExpiry: getEnvDuration("PASSWORD_RESET_EXPIRY", 30*time.Minute),
+		ShutdownTimeout:     getEnvDuration("SHUTDOWN_TIMEOUT", 15*time.Second),
+	}
+}
+
+// NewKeyring builds the key ring for stored secrets from the configured key
+// generations. Old keys are "version:key" pairs.
+func (c *Config) NewKeyring() (*crypto.Keyring, error) {
+	oldKeys := make(map[int]string, len(c.OldEncryptionKeys))
+	for _, pair := range c.OldEncryptionKeys {
+		versionStr, key, found := strings.Cut(pair, ":")
+		version, err := strconv.Atoi(versionStr)
+		if !found || err != nil {
+			return nil, fmt.Errorf("old encryption key must be \"version:key\", got %q", versionStr)
+		}
+		oldKeys[version] = key
+	}
+	return crypto.NewKeyring(c.EncryptionVersion, c.EncryptionKey, oldKeys)
+}
+
+// Features reports which optional integrations this server can offer, so the
+// frontend can hide what isn't configured
+type Features struct {
+	GoogleEnabled    bool `json:"google_enabled"`
+	IMAPEnabled      bool `json:"imap_enabled"`
+	MicrosoftEnabled bool `json:"microsoft_enabled"`
+	AIEnabled        bool `json:"ai_enabled"`
+	PushEnabled      bool `json:"push_enabled"`
+}
+
+func (c *Config) Features() Features {
+	return Features{
+		GoogleEnabled: c.GoogleClientID != "" && c.GoogleClientSecret != "",
+		// IMAP passwords are stored encrypted
+		IMAPEnabled: c.EncryptionKey != "",
+		// There is no Microsoft provider yet
+		MicrosoftEnabled: false,
+		AIEnabled:        c.GeminiApiKey != "",
+		// Gmail push needs both Google sign-in and a Pub/Sub project
+		PushEnabled: c.GoogleProjectID != "" && c.GoogleClientID != "",
 	}
 }
 
@@ -74,3 +211,56 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	if len(list) == 0 {
+		return defaultValue
+	}
+	return list
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoadClampsBcryptCost(t *testing.T) {
+	tests := []struct {
+		env  string
+		want int
+	}{
+		{"", 10},
+		{"12", 12},
+		{"1", bcrypt.MinCost},
+		{"-5", bcrypt.MinCost},
+		{"99", bcrypt.MaxCost},
+		{"not-a-number", 10},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Quote(tt.env), func(t *testing.T) {
+			t.Setenv("BCRYPT_COST", tt.env)
+			if got := Load().BcryptCost; got != tt.want {
+				t.Errorf("BcryptCost = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadAttachmentKeywords(t *testing.T) {
+	t.Setenv("ATTACHMENT_KEYWORDS", "")
+	if got := Load().AttachmentKeywords; !slices.Contains(got, "attached") || !slices.Contains(got, "đính kèm") {
+		t.Errorf("default AttachmentKeywords = %q, want English and Vietnamese phrases", got)
+	}
+
+	t.Setenv("ATTACHMENT_KEYWORDS", " pièce jointe, ,ci-joint ")
+	if got := Load().AttachmentKeywords; !slices.Equal(got, []string{"pièce jointe", "ci-joint"}) {
+		t.Errorf("AttachmentKeywords = %q, want the configured phrases", got)
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want Features
+	}{
+		{"nothing configured", Config{}, Features{}},
+		{
+			"everything configured",
+			Config{GoogleClientID: "id", GoogleClientSecret: "secret", EncryptionKey: "key", GeminiApiKey: "gemini", GoogleProjectID: "project"},
+			Features{GoogleEnabled: true, IMAPEnabled: true, AIEnabled: true, PushEnabled: true},
+		},
+		{"google without a secret", Config{GoogleClientID: "id"}, Features{}},
+		// Pub/Sub alone can't push anything without Google sign-in
+		{"push without google", Config{GoogleProjectID: "project"}, Features{}},
+		{"imap and ai only", Config{EncryptionKey: "key", GeminiApiKey: "gemini"}, Features{IMAPEnabled: true, AIEnabled: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.Features(); got != tt.want {
+				t.Errorf("Features() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,28 +4,177 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 )
 
+// ErrNotConfigured is returned by every call when no API key is set
+var ErrNotConfigured = errors.New("Gemini not configured")
+
 type GeminiService struct {
-	ApiKey string
+	ApiKey          string
+	Model           string
+	Temperature     float64 // Negative keeps the model's default
+	MaxOutputTokens int     // 0 keeps the model's default
 }
 
-func NewGeminiService(apiKey string) *GeminiService {
-	return &GeminiService{ApiKey: apiKey}
+func NewGeminiService(apiKey, model string, temperature float64, maxOutputTokens int) *GeminiService {
+	if model == "" {
+		model = "gemini-2.5-flash"
+	}
+	return &GeminiService{ApiKey: apiKey, Model: model, Temperature: temperature, MaxOutputTokens: maxOutputTokens}
 }
 
+// maxPromptChars caps the text sent per request; long threads would otherwise make
+// oversized (and expensive) requests
+const maxPromptChars = 12000
+
 func (g *GeminiService) SummarizeEmail(ctx context.Context, emailText string) (string, error) {
-	// Use gemini-2.5-pro as requested
-	url := "https://generativelanguage.googleapis.com/v1beta/models/gemini-2.5-flash:generateContent?key=" + g.ApiKey
+	summary, err := g.callGemini(ctx, emailText, false)
+	if err != nil {
+		return "", err
+	}
+	if summary == "" {
+		return "", fmt.Errorf("no summary returned")
+	}
+	return summary, nil
+}
+
+// Categories CategorizeEmail sorts mail into
+var Categories = []string{"work", "personal", "promotion", "finance", "urgent"}
+
+// Uncategorized is returned when Gemini's answer can't be used
+const Uncategorized = "uncategorized"
+
+const categorizePrompt = `Classify the email below. Answer with JSON only, in the form {"category": "...", "priority": N}.
+category is one of: %s.
+priority is 1 (can be ignored) to 5 (needs action now).
+
+%s`
+
+// CategorizeEmail asks Gemini for the email's category and a 1-5 priority. An answer
+// that isn't the expected JSON gives Uncategorized with priority 0 instead of an error.
+func (g *GeminiService) CategorizeEmail(ctx context.Context, emailText string) (string, int, error) {
+	prompt := fmt.Sprintf(categorizePrompt, strings.Join(Categories, ", "), emailText)
+	text, err := g.callGemini(ctx, prompt, true)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var result struct {
+		Category string `json:"category"`
+		Priority int    `json:"priority"`
+	}
+	if err := json.Unmarshal([]byte(stripFence(text)), &result); err != nil {
+		return Uncategorized, 0, nil
+	}
+
+	category := strings.ToLower(strings.TrimSpace(result.Category))
+	known := false
+	for _, c := range Categories {
+		if c == category {
+			known = true
+			break
+		}
+	}
+	if !known || result.Priority < 1 || result.Priority > 5 {
+		return Uncategorized, 0, nil
+	}
+	return category, result.Priority, nil
+}
+
+const suggestRepliesPrompt = `Suggest three short replies the recipient could send to the email below, in the email's language.
+Answer with a JSON array of three strings only.
+
+%s`
+
+// SuggestReplies asks Gemini for three short replies to an email
+func (g *GeminiService) SuggestReplies(ctx context.Context, emailText string) ([]string, error) {
+	text, err := g.callGemini(ctx, fmt.Sprintf(suggestRepliesPrompt, emailText), true)
+	if err != nil {
+		return nil, err
+	}
+
+	var replies []string
+	if err := json.Unmarshal([]byte(stripFence(text)), &replies); err != nil {
+		return nil, fmt.Errorf("unexpected reply suggestions from Gemini")
+	}
+	suggestions := make([]string, 0, 3)
+	for _, reply := range replies {
+		if reply = strings.TrimSpace(reply); reply != "" && len(suggestions) < 3 {
+			suggestions = append(suggestions, reply)
+		}
+	}
+	if len(suggestions) == 0 {
+		return nil, fmt.Errorf("no reply suggestions returned")
+	}
+	return suggestions, nil
+}
+
+const translatePrompt = `Translate the email text below into the language with the code %q. Keep its formatting:
+paragraphs, line breaks, lists and links. Answer with JSON only, in the form
+{"source_language": "<code of the language the text is written in>", "translation": "..."}.
+
+%s`
+
+// TranslateEmail asks Gemini to translate text into targetLang, a language code such
+// as "vi" or "en", and returns the code of the language it detected along with the translation
+func (g *GeminiService) TranslateEmail(ctx context.Context, text, targetLang string) (string, string, error) {
+	answer, err := g.callGemini(ctx, fmt.Sprintf(translatePrompt, targetLang, text), true)
+	if err != nil {
+		return "", "", err
+	}
 
+	var result struct {
+		SourceLanguage string `json:"source_language"`
+		Translation    string `json:"translation"`
+	}
+	if err := json.Unmarshal([]byte(stripFence(answer)), &result); err != nil || result.Translation == "" {
+		return "", "", fmt.Errorf("no translation returned")
+	}
+	return strings.ToLower(strings.TrimSpace(result.SourceLanguage)), result.Translation, nil
+}
+
+// stripFence removes the markdown code fence models sometimes wrap JSON in
+// despite the JSON mime type
+func stripFence(text string) string {
+	text = strings.TrimSpace(text)
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "```json"), "```")
+	return strings.TrimSpace(strings.TrimSuffix(text, "```"))
+}
+
+// callGemini sends a prompt, cut to maxPromptChars, and returns the first candidate's
+// text. jsonOutput asks for a JSON answer.
+func (g *GeminiService) callGemini(ctx context.Context, prompt string, jsonOutput bool) (string, error) {
+	if g.ApiKey == "" {
+		return "", ErrNotConfigured
+	}
+	url := "https://generativelanguage.googleapis.com/v1beta/models/" + g.Model + ":generateContent?key=" + g.ApiKey
+
+	if runes := []rune(prompt); len(runes) > maxPromptChars {
+		prompt = string(runes[:maxPromptChars])
+	}
 	payload := map[string]interface{}{
 		"contents": []map[string]interface{}{
-			{"parts": []map[string]string{{"text": emailText}}},
+			{"parts": []map[string]string{{"text": prompt}}},
 		},
 	}
+	generationConfig := map[string]interface{}{}
+	if jsonOutput {
+		generationConfig["responseMimeType"] = "application/json"
+	}
+	if g.Temperature >= 0 {
+		generationConfig["temperature"] = g.Temperature
+	}
+	if g.MaxOutputTokens > 0 {
+		generationConfig["maxOutputTokens"] = g.MaxOutputTokens
+	}
+	if len(generationConfig) > 0 {
+		payload["generationConfig"] = generationConfig
+	}
 
 	body, _ := json.Marshal(payload)
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
@@ -52,7 +201,7 @@ func (g *GeminiService) SummarizeEmail(ctx context.Context, emailText string) (s
 		return "", err
 	}
 
-	// Parse summary from response
+	// Parse the text from the response
 	if c, ok := result["candidates"].([]interface{}); ok && len(c) > 0 {
 		if cand, ok := c[0].(map[string]interface{}); ok {
 			if content, ok := cand["content"].(map[string]interface{}); ok {
@@ -66,5 +215,5 @@ func (g *GeminiService) SummarizeEmail(ctx context.Context, emailText string) (s
 			}
 		}
 	}
-	return "", fmt.Errorf("no summary returned")
+	return "", nil
 }
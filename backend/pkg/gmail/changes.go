@@ -0,0 +1,196 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// maxChangedSince caps how many new messages a timestamp-only change query lists
+const maxChangedSince = 1000
+
+// maxNewMessages caps how many arrivals GetNewMessages fetches in full
+const maxNewMessages = 20
+
+var errStopPaging = errors.New("stop paging")
+
+type changeKind int
+
+const (
+	changeModified changeKind = iota + 1
+	changeAdded
+	changeDeleted
+)
+
+// GetChanges reports what changed in the mailbox. With a watermark (a Gmail
+// history ID from an earlier call) it walks the History API and reports added,
+// modified (label or read state) and deleted messages. With only a timestamp it
+// can list arrivals after it, so the result is marked incomplete.
+func (s *Service) GetChanges(ctx context.Context, accessToken, refreshToken, watermark string, since time.Time, onTokenRefresh TokenUpdateFunc) (*emaildomain.Changes, error) {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	if watermark != "" {
+		startID, err := strconv.ParseUint(watermark, 10, 64)
+		if err != nil {
+			return nil, emaildomain.ErrInvalidWatermark
+		}
+		return historyChanges(ctx, srv, startID)
+	}
+
+	// Read the current history ID before listing so nothing that arrives
+	// in between is missed by the next call
+	profile, err := srv.Users.GetProfile("me").Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve profile: %w", err)
+	}
+
+	changes := &emaildomain.Changes{
+		Added:     []string{},
+		Modified:  []string{},
+		Deleted:   []string{},
+		Watermark: strconv.FormatUint(profile.HistoryId, 10),
+	}
+	call := srv.Users.Messages.List("me").Q(fmt.Sprintf("after:%d", since.Unix())).MaxResults(500)
+	err = call.Pages(ctx, func(resp *gmail.ListMessagesResponse) error {
+		for _, msg := range resp.Messages {
+			if len(changes.Added) == maxChangedSince {
+				return errStopPaging
+			}
+			changes.Added = append(changes.Added, msg.Id)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopPaging) {
+		return nil, fmt.Errorf("unable to list messages: %w", err)
+	}
+	return changes, nil
+}
+
+func historyChanges(ctx context.Context, srv *gmail.Service, startID uint64) (*emaildomain.Changes, error) {
+	kinds := make(map[string]changeKind)
+	var order []string
+	mark := func(id string, kind changeKind) {
+		prev, seen := kinds[id]
+		if !seen {
+			order = append(order, id)
+		}
+		// A deletion wins over everything and an arrival over a later label
+		// change, since the client has to fetch a new message in full anyway
+		if kind > prev {
+			kinds[id] = kind
+		}
+	}
+
+	latest := startID
+	call := srv.Users.History.List("me").StartHistoryId(startID).
+		HistoryTypes("messageAdded", "messageDeleted", "labelAdded", "labelRemoved")
+	err := call.Pages(ctx, func(resp *gmail.ListHistoryResponse) error {
+		for _, h := range resp.History {
+			for _, m := range h.MessagesAdded {
+				mark(m.Message.Id, changeAdded)
+			}
+			for _, m := range h.LabelsAdded {
+				mark(m.Message.Id, changeModified)
+			}
+			for _, m := range h.LabelsRemoved {
+				mark(m.Message.Id, changeModified)
+			}
+			for _, m := range h.MessagesDeleted {
+				mark(m.Message.Id, changeDeleted)
+			}
+		}
+		if resp.HistoryId > latest {
+			latest = resp.HistoryId
+		}
+		return nil
+	})
+	if err != nil {
+		// Gmail keeps about a week of history; older start IDs are rejected
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+			return nil, emaildomain.ErrWatermarkExpired
+		}
+		return nil, fmt.Errorf("unable to list history: %w", err)
+	}
+
+	changes := &emaildomain.Changes{
+		Added:     []string{},
+		Modified:  []string{},
+		Deleted:   []string{},
+		Watermark: strconv.FormatUint(latest, 10),
+		Complete:  true,
+	}
+	for _, id := range order {
+		switch kinds[id] {
+		case changeAdded:
+			changes.Added = append(changes.Added, id)
+		case changeModified:
+			changes.Modified = append(changes.Modified, id)
+		case changeDeleted:
+			changes.Deleted = append(changes.Deleted, id)
+		}
+	}
+	return changes, nil
+}
+
+// GetNewMessages returns the messages added to the inbox since startHistoryID, newest
+// last, along with the history ID it read up to. Messages deleted again before they
+// could be fetched are skipped.
+func (s *Service) GetNewMessages(ctx context.Context, accessToken, refreshToken string, startHistoryID uint64, onTokenRefresh TokenUpdateFunc) ([]*emaildomain.Email, uint64, error) {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	seen := make(map[string]bool)
+	var ids []string
+	latest := startHistoryID
+	call := srv.Users.History.List("me").StartHistoryId(startHistoryID).
+		HistoryTypes("messageAdded").LabelId("INBOX")
+	err = call.Pages(ctx, func(resp *gmail.ListHistoryResponse) error {
+		for _, h := range resp.History {
+			for _, m := range h.MessagesAdded {
+				if !seen[m.Message.Id] {
+					seen[m.Message.Id] = true
+					ids = append(ids, m.Message.Id)
+				}
+			}
+		}
+		if resp.HistoryId > latest {
+			latest = resp.HistoryId
+		}
+		return nil
+	})
+	if err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+			return nil, 0, emaildomain.ErrWatermarkExpired
+		}
+		return nil, 0, fmt.Errorf("unable to list history: %w", err)
+	}
+
+	// Only the most recent arrivals are worth pushing one by one
+	if len(ids) > maxNewMessages {
+		ids = ids[len(ids)-maxNewMessages:]
+	}
+	emails := make([]*emaildomain.Email, 0, len(ids))
+	for _, id := range ids {
+		msg, err := srv.Users.Messages.Get("me", id).Format("full").Context(ctx).Do()
+		if err != nil {
+			continue
+		}
+		emails = append(emails, convertGmailMessageToEmail(msg))
+	}
+	return emails, latest, nil
+}
@@ -0,0 +1,121 @@
+package gmail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func messageList(ids ...string) []*gmail.Message {
+	var msgs []*gmail.Message
+	for _, id := range ids {
+		msgs = append(msgs, &gmail.Message{Id: id})
+	}
+	return msgs
+}
+
+func TestGetChangesFromHistory(t *testing.T) {
+	var startIDs []string
+	ctx := fakeGmail(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/users/me/history") {
+			http.NotFound(w, r)
+			return
+		}
+		startIDs = append(startIDs, r.URL.Query().Get("startHistoryId"))
+
+		// Two pages, the second changing messages from the first again
+		resp := &gmail.ListHistoryResponse{HistoryId: 120}
+		if r.URL.Query().Get("pageToken") == "" {
+			resp.NextPageToken = "p2"
+			resp.History = []*gmail.History{
+				{MessagesAdded: []*gmail.HistoryMessageAdded{{Message: &gmail.Message{Id: "m1"}}}},
+				{LabelsAdded: []*gmail.HistoryLabelAdded{{Message: &gmail.Message{Id: "m2"}, LabelIds: []string{"STARRED"}}}},
+				{MessagesAdded: []*gmail.HistoryMessageAdded{{Message: &gmail.Message{Id: "m3"}}}},
+			}
+		} else {
+			resp.History = []*gmail.History{
+				// A new message that was then labelled is still reported as added
+				{LabelsRemoved: []*gmail.HistoryLabelRemoved{{Message: &gmail.Message{Id: "m1"}, LabelIds: []string{"UNREAD"}}}},
+				// One that arrived and was deleted only as deleted
+				{MessagesDeleted: []*gmail.HistoryMessageDeleted{{Message: &gmail.Message{Id: "m3"}}}},
+				{LabelsRemoved: []*gmail.HistoryLabelRemoved{{Message: &gmail.Message{Id: "m4"}, LabelIds: []string{"INBOX"}}}},
+				{MessagesDeleted: []*gmail.HistoryMessageDeleted{{Message: &gmail.Message{Id: "m5"}}}},
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	changes, err := NewService("", "").GetChanges(ctx, "access", "", "90", time.Time{}, nil)
+	if err != nil {
+		t.Fatalf("GetChanges() error = %v", err)
+	}
+	want := &emaildomain.Changes{
+		Added:     []string{"m1"},
+		Modified:  []string{"m2", "m4"},
+		Deleted:   []string{"m3", "m5"},
+		Watermark: "120",
+		Complete:  true,
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("GetChanges() = %+v, want %+v", changes, want)
+	}
+	if len(startIDs) != 2 || startIDs[0] != "90" {
+		t.Errorf("history requests started at %q, want both pages from 90", startIDs)
+	}
+}
+
+func TestGetChangesExpiredWatermark(t *testing.T) {
+	ctx := fakeGmail(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error": {"code": 404, "message": "Requested entity was not found."}}`)
+	})
+
+	_, err := NewService("", "").GetChanges(ctx, "access", "", "5", time.Time{}, nil)
+	if !errors.Is(err, emaildomain.ErrWatermarkExpired) {
+		t.Errorf("GetChanges() error = %v, want ErrWatermarkExpired", err)
+	}
+
+	_, err = NewService("", "").GetChanges(ctx, "access", "", "not-a-history-id", time.Time{}, nil)
+	if !errors.Is(err, emaildomain.ErrInvalidWatermark) {
+		t.Errorf("GetChanges() error = %v, want ErrInvalidWatermark", err)
+	}
+}
+
+func TestGetChangesSinceTimestamp(t *testing.T) {
+	since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var query string
+	ctx := fakeGmail(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/users/me/profile"):
+			json.NewEncoder(w).Encode(&gmail.Profile{HistoryId: 77})
+		case strings.HasSuffix(r.URL.Path, "/users/me/messages"):
+			query = r.URL.Query().Get("q")
+			json.NewEncoder(w).Encode(&gmail.ListMessagesResponse{Messages: messageList("m8", "m9")})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	changes, err := NewService("", "").GetChanges(ctx, "access", "", "", since, nil)
+	if err != nil {
+		t.Fatalf("GetChanges() error = %v", err)
+	}
+	if want := fmt.Sprintf("after:%d", since.Unix()); query != want {
+		t.Errorf("messages were listed with q=%q, want %q", query, want)
+	}
+	// Only arrivals can be told from a timestamp
+	want := &emaildomain.Changes{Added: []string{"m8", "m9"}, Modified: []string{}, Deleted: []string{}, Watermark: "77"}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("GetChanges() = %+v, want %+v", changes, want)
+	}
+}
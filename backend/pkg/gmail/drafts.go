@@ -0,0 +1,115 @@
+package gmail
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+func draftMessage(msg *emaildomain.ComposedEmail) (*gmail.Message, error) {
+	raw, err := buildRawMessage(msg.FromName, msg.From, msg.To, msg.Cc, msg.Bcc, msg.Subject, msg.Body, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &gmail.Message{Raw: base64.URLEncoding.EncodeToString(raw)}, nil
+}
+
+// draftError maps a missing draft to ErrDraftNotFound
+func draftError(action string, err error) error {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		return emaildomain.ErrDraftNotFound
+	}
+	return fmt.Errorf("unable to %s draft: %w", action, err)
+}
+
+// CreateDraft saves a new draft and returns its draft ID
+func (s *Service) CreateDraft(ctx context.Context, accessToken, refreshToken string, msg *emaildomain.ComposedEmail, onTokenRefresh TokenUpdateFunc) (string, error) {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return "", err
+	}
+
+	message, err := draftMessage(msg)
+	if err != nil {
+		return "", err
+	}
+	draft, err := srv.Users.Drafts.Create("me", &gmail.Draft{Message: message}).Do()
+	if err != nil {
+		return "", draftError("create", err)
+	}
+	return draft.Id, nil
+}
+
+// UpdateDraft replaces the content of a draft, keeping its draft ID
+func (s *Service) UpdateDraft(ctx context.Context, accessToken, refreshToken, draftID string, msg *emaildomain.ComposedEmail, onTokenRefresh TokenUpdateFunc) error {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return err
+	}
+
+	message, err := draftMessage(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := srv.Users.Drafts.Update("me", draftID, &gmail.Draft{Id: draftID, Message: message}).Do(); err != nil {
+		return draftError("update", err)
+	}
+	return nil
+}
+
+// DeleteDraft discards a draft. Drafts skip Trash, so this is permanent.
+func (s *Service) DeleteDraft(ctx context.Context, accessToken, refreshToken, draftID string, onTokenRefresh TokenUpdateFunc) error {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return err
+	}
+
+	if err := srv.Users.Drafts.Delete("me", draftID).Do(); err != nil {
+		return draftError("delete", err)
+	}
+	return nil
+}
+
+// SendDraft sends a draft as it was last saved. Gmail removes the draft afterwards.
+func (s *Service) SendDraft(ctx context.Context, accessToken, refreshToken, draftID string, onTokenRefresh TokenUpdateFunc) error {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return err
+	}
+
+	if _, err := srv.Users.Drafts.Send("me", &gmail.Draft{Id: draftID}).Do(); err != nil {
+		return draftError("send", err)
+	}
+	return nil
+}
+
+// ListDraftIDs maps the message ID of every draft to its draft ID. Listing the DRAFT
+// label only returns message IDs, which the Drafts API doesn't accept.
+func (s *Service) ListDraftIDs(ctx context.Context, accessToken, refreshToken string, onTokenRefresh TokenUpdateFunc) (map[string]string, error) {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]string)
+	err = srv.Users.Drafts.List("me").MaxResults(500).Pages(ctx, func(resp *gmail.ListDraftsResponse) error {
+		for _, d := range resp.Drafts {
+			if d.Message != nil {
+				ids[d.Message.Id] = d.Id
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list drafts: %w", err)
+	}
+	return ids, nil
+}
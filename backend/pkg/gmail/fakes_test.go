@@ -0,0 +1,34 @@
+package gmail
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+// fakeGmail serves the Gmail API from handler. The returned context makes the
+// service's OAuth client send every request there instead of to Google.
+func fakeGmail(t *testing.T, handler http.HandlerFunc) context.Context {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	target, _ := url.Parse(server.URL)
+	client := &http.Client{Transport: redirectTransport{target: target}}
+	return context.WithValue(context.Background(), oauth2.HTTPClient, client)
+}
+
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (rt redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = rt.target.Scheme
+	req.URL.Host = rt.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
@@ -0,0 +1,146 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// ListFilters returns the user's Gmail filters in the provider-neutral shape
+func (s *Service) ListFilters(ctx context.Context, accessToken, refreshToken string, onTokenRefresh TokenUpdateFunc) ([]*emaildomain.MailFilter, error) {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := srv.Users.Settings.Filters.List("me").Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list filters: %w", err)
+	}
+
+	filters := make([]*emaildomain.MailFilter, 0, len(resp.Filter))
+	for _, f := range resp.Filter {
+		filters = append(filters, FilterFromGmail(f))
+	}
+	return filters, nil
+}
+
+// CreateFilter creates a Gmail filter from the provider-neutral shape
+func (s *Service) CreateFilter(ctx context.Context, accessToken, refreshToken string, filter *emaildomain.MailFilter, onTokenRefresh TokenUpdateFunc) (*emaildomain.MailFilter, error) {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := srv.Users.Settings.Filters.Create("me", FilterToGmail(filter)).Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create filter: %w", err)
+	}
+	return FilterFromGmail(created), nil
+}
+
+// DeleteFilter deletes a Gmail filter
+func (s *Service) DeleteFilter(ctx context.Context, accessToken, refreshToken, filterID string, onTokenRefresh TokenUpdateFunc) error {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return err
+	}
+
+	if err := srv.Users.Settings.Filters.Delete("me", filterID).Do(); err != nil {
+		return fmt.Errorf("unable to delete filter: %w", err)
+	}
+	return nil
+}
+
+// FilterFromGmail maps a Gmail filter to the neutral shape. System labels that stand
+// for an action (STARRED, TRASH, IMPORTANT, UNREAD, INBOX) become action flags.
+func FilterFromGmail(f *gmail.Filter) *emaildomain.MailFilter {
+	filter := &emaildomain.MailFilter{ID: f.Id}
+
+	if c := f.Criteria; c != nil {
+		filter.Criteria = emaildomain.FilterCriteria{
+			From:          c.From,
+			To:            c.To,
+			Subject:       c.Subject,
+			Query:         c.Query,
+			NegatedQuery:  c.NegatedQuery,
+			HasAttachment: c.HasAttachment,
+			SizeBytes:     c.Size,
+		}
+		if c.Size > 0 {
+			filter.Criteria.SizeCompare = c.SizeComparison
+		}
+	}
+
+	if a := f.Action; a != nil {
+		filter.Action.Forward = a.Forward
+		for _, label := range a.AddLabelIds {
+			switch label {
+			case "STARRED":
+				filter.Action.Star = true
+			case "TRASH":
+				filter.Action.Trash = true
+			case "IMPORTANT":
+				filter.Action.Important = true
+			default:
+				filter.Action.AddLabels = append(filter.Action.AddLabels, label)
+			}
+		}
+		for _, label := range a.RemoveLabelIds {
+			switch label {
+			case "UNREAD":
+				filter.Action.MarkRead = true
+			case "INBOX":
+				filter.Action.Archive = true
+			default:
+				filter.Action.RemoveLabels = append(filter.Action.RemoveLabels, label)
+			}
+		}
+	}
+
+	return filter
+}
+
+// FilterToGmail is the inverse of FilterFromGmail
+func FilterToGmail(filter *emaildomain.MailFilter) *gmail.Filter {
+	c := filter.Criteria
+	criteria := &gmail.FilterCriteria{
+		From:          c.From,
+		To:            c.To,
+		Subject:       c.Subject,
+		Query:         c.Query,
+		NegatedQuery:  c.NegatedQuery,
+		HasAttachment: c.HasAttachment,
+		Size:          c.SizeBytes,
+	}
+	if c.SizeBytes > 0 {
+		criteria.SizeComparison = c.SizeCompare
+	}
+
+	a := filter.Action
+	action := &gmail.FilterAction{
+		Forward:        a.Forward,
+		AddLabelIds:    append([]string{}, a.AddLabels...),
+		RemoveLabelIds: append([]string{}, a.RemoveLabels...),
+	}
+	if a.Star {
+		action.AddLabelIds = append(action.AddLabelIds, "STARRED")
+	}
+	if a.Trash {
+		action.AddLabelIds = append(action.AddLabelIds, "TRASH")
+	}
+	if a.Important {
+		action.AddLabelIds = append(action.AddLabelIds, "IMPORTANT")
+	}
+	if a.MarkRead {
+		action.RemoveLabelIds = append(action.RemoveLabelIds, "UNREAD")
+	}
+	if a.Archive {
+		action.RemoveLabelIds = append(action.RemoveLabelIds, "INBOX")
+	}
+
+	return &gmail.Filter{Id: filter.ID, Criteria: criteria, Action: action}
+}
@@ -0,0 +1,89 @@
+package gmail
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// sampleFilter is shaped like a filter returned by users.settings.filters.list
+func sampleFilter() *gmail.Filter {
+	return &gmail.Filter{
+		Id: "ANe1BmhX",
+		Criteria: &gmail.FilterCriteria{
+			From:           "billing@example.com",
+			Subject:        "Invoice",
+			HasAttachment:  true,
+			Size:           1048576,
+			SizeComparison: "larger",
+		},
+		Action: &gmail.FilterAction{
+			AddLabelIds:    []string{"Label_7", "STARRED", "IMPORTANT"},
+			RemoveLabelIds: []string{"INBOX", "UNREAD"},
+			Forward:        "accounts@example.com",
+		},
+	}
+}
+
+func TestFilterFromGmail(t *testing.T) {
+	want := &emaildomain.MailFilter{
+		ID: "ANe1BmhX",
+		Criteria: emaildomain.FilterCriteria{
+			From:          "billing@example.com",
+			Subject:       "Invoice",
+			HasAttachment: true,
+			SizeBytes:     1048576,
+			SizeCompare:   "larger",
+		},
+		Action: emaildomain.FilterAction{
+			AddLabels: []string{"Label_7"},
+			Forward:   "accounts@example.com",
+			MarkRead:  true,
+			Archive:   true,
+			Star:      true,
+			Important: true,
+		},
+	}
+	if got := FilterFromGmail(sampleFilter()); !reflect.DeepEqual(got, want) {
+		t.Errorf("FilterFromGmail() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestFilterFromGmailIgnoresSizeComparisonWithoutSize(t *testing.T) {
+	got := FilterFromGmail(&gmail.Filter{Id: "f1", Criteria: &gmail.FilterCriteria{Query: "is:chat", SizeComparison: "unspecified"}})
+	if got.Criteria.Query != "is:chat" || got.Criteria.SizeCompare != "" {
+		t.Errorf("criteria = %+v, want the query without a size comparison", got.Criteria)
+	}
+}
+
+func TestFilterToGmailRoundTrip(t *testing.T) {
+	neutral := FilterFromGmail(sampleFilter())
+	back := FilterFromGmail(FilterToGmail(neutral))
+	if !reflect.DeepEqual(back, neutral) {
+		t.Errorf("round trip =\n%+v\nwant\n%+v", back, neutral)
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	ctx := fakeGmail(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/users/me/settings/filters") {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(&gmail.ListFiltersResponse{Filter: []*gmail.Filter{sampleFilter()}})
+	})
+
+	filters, err := NewService("", "").ListFilters(ctx, "access", "", nil)
+	if err != nil {
+		t.Fatalf("ListFilters() error = %v", err)
+	}
+	if len(filters) != 1 || filters[0].ID != "ANe1BmhX" || !filters[0].Action.Archive {
+		t.Errorf("filters = %+v, want the mapped sample filter", filters)
+	}
+}
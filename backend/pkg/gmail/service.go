@@ -4,16 +4,24 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
 	"io"
 	"log"
 	"mime/multipart"
+	"net/http"
+	"net/url"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/pkg/utils/emailid"
+	"ga03-backend/pkg/utils/ical"
+	"ga03-backend/pkg/utils/mailutil"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
@@ -21,6 +29,9 @@ import (
 	"google.golang.org/api/option"
 )
 
+// messageFetchWorkers bounds the concurrent message Get calls made for one page
+const messageFetchWorkers = 8
+
 // TokenUpdateFunc is a callback function that handles token updates
 type TokenUpdateFunc = emaildomain.TokenUpdateFunc
 
@@ -90,7 +101,7 @@ func (s *Service) GetGmailService(ctx context.Context, accessToken, refreshToken
 
 	srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
 	if err != nil {
-		return nil, fmt.Errorf("unable to create Gmail service: %v", err)
+		return nil, fmt.Errorf("unable to create Gmail service: %w", err)
 	}
 
 	return srv, nil
@@ -106,7 +117,7 @@ func (s *Service) GetMailboxes(ctx context.Context, accessToken, refreshToken st
 	user := "me"
 	labelsResp, err := srv.Users.Labels.List(user).Do()
 	if err != nil {
-		return nil, fmt.Errorf("unable to retrieve labels: %v", err)
+		return nil, fmt.Errorf("unable to retrieve labels: %w", err)
 	}
 
 	mailboxes := make([]*emaildomain.Mailbox, 0)
@@ -121,10 +132,11 @@ func (s *Service) GetMailboxes(ctx context.Context, accessToken, refreshToken st
 			}
 
 			mailbox := &emaildomain.Mailbox{
-				ID:    label.Id,
-				Name:  label.Name,
-				Type:  mailboxType,
-				Count: int(label.MessagesUnread),
+				ID:        label.Id,
+				Name:      label.Name,
+				Type:      mailboxType,
+				Count:     int(label.MessagesUnread),
+				Delimiter: "/", // Nested Gmail labels are named "Parent/Child"
 			}
 			mailboxes = append(mailboxes, mailbox)
 		}
@@ -135,10 +147,44 @@ func (s *Service) GetMailboxes(ctx context.Context, accessToken, refreshToken st
 
 // GetEmails retrieves emails from a specific mailbox/label
 func (s *Service) GetEmails(ctx context.Context, accessToken, refreshToken string, labelID string, limit, offset int, queryStr string, onTokenRefresh TokenUpdateFunc) ([]*emaildomain.Email, int, error) {
-	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	emails := make([]*emaildomain.Email, 0)
+	total, _, err := s.streamEmails(ctx, accessToken, refreshToken, labelID, limit, offset, "", queryStr, func(email *emaildomain.Email) {
+		emails = append(emails, email)
+	}, onTokenRefresh)
 	if err != nil {
 		return nil, 0, err
 	}
+	return emails, total, nil
+}
+
+// GetEmailsPage retrieves the page of emails starting at pageToken (empty for the first
+// page) and returns the token of the next page, empty on the last one. Paging forward
+// this way costs a single List call, where an offset has to list every skipped message.
+func (s *Service) GetEmailsPage(ctx context.Context, accessToken, refreshToken string, labelID string, limit int, pageToken, queryStr string, onTokenRefresh TokenUpdateFunc) ([]*emaildomain.Email, int, string, error) {
+	emails := make([]*emaildomain.Email, 0)
+	total, nextPageToken, err := s.streamEmails(ctx, accessToken, refreshToken, labelID, limit, 0, pageToken, queryStr, func(email *emaildomain.Email) {
+		emails = append(emails, email)
+	}, onTokenRefresh)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	return emails, total, nextPageToken, nil
+}
+
+// StreamEmails retrieves emails from a mailbox/label like GetEmails, but hands each email
+// to onEmail as soon as it is fetched. Returns the estimated total.
+func (s *Service) StreamEmails(ctx context.Context, accessToken, refreshToken string, labelID string, limit, offset int, queryStr string, onEmail emaildomain.EmailFunc, onTokenRefresh TokenUpdateFunc) (int, error) {
+	total, _, err := s.streamEmails(ctx, accessToken, refreshToken, labelID, limit, offset, "", queryStr, onEmail, onTokenRefresh)
+	return total, err
+}
+
+// streamEmails lists a page starting at pageToken, or at offset when no token is given,
+// and returns the estimated total and the next page token
+func (s *Service) streamEmails(ctx context.Context, accessToken, refreshToken string, labelID string, limit, offset int, pageToken, queryStr string, onEmail emaildomain.EmailFunc, onTokenRefresh TokenUpdateFunc) (int, string, error) {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return 0, "", err
+	}
 
 	user := "me"
 
@@ -158,8 +204,7 @@ func (s *Service) GetEmails(ctx context.Context, accessToken, refreshToken strin
 	}
 
 	// Handle offset by advancing page token
-	pageToken := ""
-	if offset > 0 {
+	if pageToken == "" && offset > 0 {
 		skipped := 0
 		for skipped < offset {
 			toSkip := offset - skipped
@@ -170,7 +215,7 @@ func (s *Service) GetEmails(ctx context.Context, accessToken, refreshToken strin
 			// Just fetch IDs to skip
 			resp, err := srv.Users.Messages.List(user).Q(q).MaxResults(int64(toSkip)).PageToken(pageToken).Do()
 			if err != nil {
-				return nil, 0, fmt.Errorf("unable to skip messages: %v", err)
+				return 0, "", fmt.Errorf("unable to skip messages: %w", err)
 			}
 
 			skipped += len(resp.Messages)
@@ -188,23 +233,64 @@ func (s *Service) GetEmails(ctx context.Context, accessToken, refreshToken strin
 
 	messagesResp, err := query.Do()
 	if err != nil {
-		return nil, 0, fmt.Errorf("unable to retrieve messages: %v", err)
+		return 0, "", fmt.Errorf("unable to retrieve messages: %w", err)
 	}
 
-	emails := make([]*emaildomain.Email, 0)
+	// Get full message details in parallel, but hand them to onEmail in list order
+	results := make([]chan *gmail.Message, len(messagesResp.Messages))
+	for i := range results {
+		results[i] = make(chan *gmail.Message, 1)
+	}
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i := range messagesResp.Messages {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for w := 0; w < messageFetchWorkers && w < len(results); w++ {
+		go func() {
+			for i := range jobs {
+				fullMsg, err := srv.Users.Messages.Get(user, messagesResp.Messages[i].Id).Format("full").Context(ctx).Do()
+				if err != nil {
+					fullMsg = nil // Skip messages we can't fetch
+				}
+				results[i] <- fullMsg
+			}
+		}()
+	}
 
-	// Get full message details for each message
-	for _, msg := range messagesResp.Messages {
-		fullMsg, err := srv.Users.Messages.Get(user, msg.Id).Format("full").Do()
-		if err != nil {
-			continue // Skip messages we can't fetch
+	for _, result := range results {
+		select {
+		case fullMsg := <-result:
+			if fullMsg != nil {
+				onEmail(convertGmailMessageToEmail(fullMsg))
+			}
+		case <-ctx.Done():
+			return 0, "", ctx.Err()
 		}
+	}
 
-		email := convertGmailMessageToEmail(fullMsg)
-		emails = append(emails, email)
+	return int(messagesResp.ResultSizeEstimate), messagesResp.NextPageToken, nil
+}
+
+// CountEmails returns Gmail's estimate of how many messages match a search query
+func (s *Service) CountEmails(ctx context.Context, accessToken, refreshToken, query string, onTokenRefresh TokenUpdateFunc) (int, error) {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := srv.Users.Messages.List("me").Q(query).MaxResults(1).Do()
+	if err != nil {
+		return 0, fmt.Errorf("unable to count messages: %w", err)
 	}
 
-	return emails, int(messagesResp.ResultSizeEstimate), nil
+	return int(resp.ResultSizeEstimate), nil
 }
 
 // GetAttachment retrieves an attachment from a message
@@ -219,7 +305,7 @@ func (s *Service) GetAttachment(ctx context.Context, accessToken, refreshToken,
 	// Fetch message to get attachment metadata
 	msg, err := srv.Users.Messages.Get(user, messageID).Format("full").Do()
 	if err != nil {
-		return nil, nil, fmt.Errorf("unable to retrieve message details: %v", err)
+		return nil, nil, fmt.Errorf("unable to retrieve message details: %w", err)
 	}
 
 	// Find attachment metadata
@@ -242,12 +328,12 @@ func (s *Service) GetAttachment(ctx context.Context, accessToken, refreshToken,
 	// Fetch attachment data
 	attachPart, err := srv.Users.Messages.Attachments.Get(user, messageID, attachmentID).Do()
 	if err != nil {
-		return nil, nil, fmt.Errorf("unable to retrieve attachment: %v", err)
+		return nil, nil, fmt.Errorf("unable to retrieve attachment: %w", err)
 	}
 
 	data, err := base64.URLEncoding.DecodeString(attachPart.Data)
 	if err != nil {
-		return nil, nil, fmt.Errorf("unable to decode attachment data: %v", err)
+		return nil, nil, fmt.Errorf("unable to decode attachment data: %w", err)
 	}
 
 	return &emaildomain.Attachment{
@@ -268,12 +354,53 @@ func (s *Service) GetEmailByID(ctx context.Context, accessToken, refreshToken, e
 	user := "me"
 	msg, err := srv.Users.Messages.Get(user, emailID).Format("full").Do()
 	if err != nil {
-		return nil, fmt.Errorf("unable to retrieve message: %v", err)
+		return nil, fmt.Errorf("unable to retrieve message: %w", err)
 	}
 
 	return convertGmailMessageToEmail(msg), nil
 }
 
+// GetEmailMetadata retrieves only the headers and labels of an email, without the body
+func (s *Service) GetEmailMetadata(ctx context.Context, accessToken, refreshToken, emailID string, onTokenRefresh TokenUpdateFunc) (*emaildomain.Email, error) {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	user := "me"
+	msg, err := srv.Users.Messages.Get(user, emailID).Format("metadata").Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve message: %w", err)
+	}
+
+	email := convertGmailMessageToEmail(msg)
+	// No body is fetched in metadata mode, fall back to Gmail's snippet for the preview
+	email.Preview = html.UnescapeString(msg.Snippet)
+	return email, nil
+}
+
+// GetThread retrieves the metadata of every message in a thread, oldest first
+func (s *Service) GetThread(ctx context.Context, accessToken, refreshToken, threadID string, onTokenRefresh TokenUpdateFunc) ([]*emaildomain.Email, error) {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	user := "me"
+	thread, err := srv.Users.Threads.Get(user, threadID).Format("metadata").Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve thread: %w", err)
+	}
+
+	emails := make([]*emaildomain.Email, 0, len(thread.Messages))
+	for _, msg := range thread.Messages {
+		email := convertGmailMessageToEmail(msg)
+		email.Preview = html.UnescapeString(msg.Snippet)
+		emails = append(emails, email)
+	}
+	return emails, nil
+}
+
 // MarkAsRead marks an email as read
 func (s *Service) MarkAsRead(ctx context.Context, accessToken, refreshToken, emailID string, onTokenRefresh TokenUpdateFunc) error {
 	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
@@ -288,7 +415,7 @@ func (s *Service) MarkAsRead(ctx context.Context, accessToken, refreshToken, ema
 
 	_, err = srv.Users.Messages.Modify(user, emailID, modifyReq).Do()
 	if err != nil {
-		return fmt.Errorf("unable to mark message as read: %v", err)
+		return fmt.Errorf("unable to mark message as read: %w", err)
 	}
 
 	return nil
@@ -308,7 +435,7 @@ func (s *Service) MarkAsUnread(ctx context.Context, accessToken, refreshToken, e
 
 	_, err = srv.Users.Messages.Modify(user, emailID, modifyReq).Do()
 	if err != nil {
-		return fmt.Errorf("unable to mark message as unread: %v", err)
+		return fmt.Errorf("unable to mark message as unread: %w", err)
 	}
 
 	return nil
@@ -316,6 +443,22 @@ func (s *Service) MarkAsUnread(ctx context.Context, accessToken, refreshToken, e
 
 // ToggleStar toggles the star status of an email
 func (s *Service) ToggleStar(ctx context.Context, accessToken, refreshToken, emailID string, onTokenRefresh TokenUpdateFunc) error {
+	if err := s.toggleLabel(ctx, accessToken, refreshToken, emailID, "STARRED", onTokenRefresh); err != nil {
+		return fmt.Errorf("unable to toggle star: %w", err)
+	}
+	return nil
+}
+
+// ToggleImportant toggles the IMPORTANT label of an email
+func (s *Service) ToggleImportant(ctx context.Context, accessToken, refreshToken, emailID string, onTokenRefresh TokenUpdateFunc) error {
+	if err := s.toggleLabel(ctx, accessToken, refreshToken, emailID, "IMPORTANT", onTokenRefresh); err != nil {
+		return fmt.Errorf("unable to toggle important: %w", err)
+	}
+	return nil
+}
+
+// toggleLabel removes labelID from a message that has it and adds it otherwise
+func (s *Service) toggleLabel(ctx context.Context, accessToken, refreshToken, emailID, labelID string, onTokenRefresh TokenUpdateFunc) error {
 	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
 	if err != nil {
 		return err
@@ -323,41 +466,23 @@ func (s *Service) ToggleStar(ctx context.Context, accessToken, refreshToken, ema
 
 	user := "me"
 
-	// Get current message to check star status
+	// Get current message to check the label
 	msg, err := srv.Users.Messages.Get(user, emailID).Format("minimal").Do()
 	if err != nil {
-		return fmt.Errorf("unable to get message: %v", err)
-	}
-
-	isStarred := false
-	for _, labelID := range msg.LabelIds {
-		if labelID == "STARRED" {
-			isStarred = true
-			break
-		}
+		return fmt.Errorf("unable to get message: %w", err)
 	}
 
-	var modifyReq *gmail.ModifyMessageRequest
-	if isStarred {
-		modifyReq = &gmail.ModifyMessageRequest{
-			RemoveLabelIds: []string{"STARRED"},
-		}
-	} else {
-		modifyReq = &gmail.ModifyMessageRequest{
-			AddLabelIds: []string{"STARRED"},
-		}
+	modifyReq := &gmail.ModifyMessageRequest{AddLabelIds: []string{labelID}}
+	if hasLabel(msg.LabelIds, labelID) {
+		modifyReq = &gmail.ModifyMessageRequest{RemoveLabelIds: []string{labelID}}
 	}
 
 	_, err = srv.Users.Messages.Modify(user, emailID, modifyReq).Do()
-	if err != nil {
-		return fmt.Errorf("unable to toggle star: %v", err)
-	}
-
-	return nil
+	return err
 }
 
-// SendEmail sends an email
-func (s *Service) SendEmail(ctx context.Context, accessToken, refreshToken, fromName, fromEmail, to, cc, bcc, subject, body string, files []*multipart.FileHeader, onTokenRefresh TokenUpdateFunc) error {
+// SendEmail sends an email. reply is nil for a new conversation.
+func (s *Service) SendEmail(ctx context.Context, accessToken, refreshToken, fromName, fromEmail, to, cc, bcc, subject, body string, files []*multipart.FileHeader, reply *emaildomain.ReplyHeaders, onTokenRefresh TokenUpdateFunc) error {
 	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
 	if err != nil {
 		return err
@@ -365,14 +490,38 @@ func (s *Service) SendEmail(ctx context.Context, accessToken, refreshToken, from
 
 	user := "me"
 
+	raw, err := buildRawMessage(fromName, fromEmail, to, cc, bcc, subject, body, files, reply)
+	if err != nil {
+		return err
+	}
+
+	msg := &gmail.Message{
+		Raw: base64.URLEncoding.EncodeToString(raw),
+	}
+	// Gmail only threads a reply when both the headers and ThreadId match
+	if reply != nil {
+		msg.ThreadId = reply.ThreadID
+	}
+
+	_, err = srv.Users.Messages.Send(user, msg).Do()
+	if err != nil {
+		return fmt.Errorf("unable to send message: %w", err)
+	}
+
+	return nil
+}
+
+// buildRawMessage writes an HTML message with attachments as RFC 5322 bytes. Gmail
+// drops the Bcc header itself when sending.
+func buildRawMessage(fromName, fromEmail, to, cc, bcc, subject, body string, files []*multipart.FileHeader, reply *emaildomain.ReplyHeaders) ([]byte, error) {
 	var emailMsg bytes.Buffer
 	boundary := "foo_bar_baz"
 
 	// Headers
-	if fromName != "" && fromEmail != "" {
-		encodedName := fmt.Sprintf("=?utf-8?B?%s?=", base64.StdEncoding.EncodeToString([]byte(fromName)))
-		emailMsg.WriteString(fmt.Sprintf("From: %s <%s>\r\n", encodedName, fromEmail))
+	if fromEmail != "" {
+		emailMsg.WriteString(fmt.Sprintf("From: %s\r\n", mailutil.FormatAddress(fromName, fromEmail)))
 	}
+	emailMsg.WriteString(fmt.Sprintf("Message-ID: %s\r\n", mailutil.NewMessageID(fromEmail)))
 	emailMsg.WriteString(fmt.Sprintf("To: %s\r\n", to))
 	if cc != "" {
 		emailMsg.WriteString(fmt.Sprintf("Cc: %s\r\n", cc))
@@ -380,6 +529,7 @@ func (s *Service) SendEmail(ctx context.Context, accessToken, refreshToken, from
 	if bcc != "" {
 		emailMsg.WriteString(fmt.Sprintf("Bcc: %s\r\n", bcc))
 	}
+	writeReplyHeaders(&emailMsg, reply)
 	// Encode subject to handle non-ASCII characters (RFC 2047)
 	encodedSubject := fmt.Sprintf("=?utf-8?B?%s?=", base64.StdEncoding.EncodeToString([]byte(subject)))
 	emailMsg.WriteString(fmt.Sprintf("Subject: %s\r\n", encodedSubject))
@@ -396,13 +546,12 @@ func (s *Service) SendEmail(ctx context.Context, accessToken, refreshToken, from
 	for _, file := range files {
 		f, err := file.Open()
 		if err != nil {
-			return fmt.Errorf("unable to open file: %v", err)
+			return nil, fmt.Errorf("unable to open file: %w", err)
 		}
-		defer f.Close()
-
 		content, err := io.ReadAll(f)
+		f.Close()
 		if err != nil {
-			return fmt.Errorf("unable to read file: %v", err)
+			return nil, fmt.Errorf("unable to read file: %w", err)
 		}
 
 		encodedContent := base64.StdEncoding.EncodeToString(content)
@@ -423,20 +572,26 @@ func (s *Service) SendEmail(ctx context.Context, accessToken, refreshToken, from
 	}
 
 	emailMsg.WriteString(fmt.Sprintf("--%s--", boundary))
+	return emailMsg.Bytes(), nil
+}
 
-	msg := &gmail.Message{
-		Raw: base64.URLEncoding.EncodeToString(emailMsg.Bytes()),
-	}
-
-	_, err = srv.Users.Messages.Send(user, msg).Do()
+// TrashEmail moves an email to trash
+// SendRawEmail sends a fully formed RFC 5322 message, e.g. an iMIP calendar reply
+func (s *Service) SendRawEmail(ctx context.Context, accessToken, refreshToken string, raw []byte, onTokenRefresh TokenUpdateFunc) error {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
 	if err != nil {
-		return fmt.Errorf("unable to send message: %v", err)
+		return err
 	}
 
+	msg := &gmail.Message{
+		Raw: base64.URLEncoding.EncodeToString(raw),
+	}
+	if _, err := srv.Users.Messages.Send("me", msg).Do(); err != nil {
+		return fmt.Errorf("unable to send message: %w", err)
+	}
 	return nil
 }
 
-// TrashEmail moves an email to trash
 func (s *Service) TrashEmail(ctx context.Context, accessToken, refreshToken, emailID string, onTokenRefresh TokenUpdateFunc) error {
 	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
 	if err != nil {
@@ -450,12 +605,79 @@ func (s *Service) TrashEmail(ctx context.Context, accessToken, refreshToken, ema
 
 	_, err = srv.Users.Messages.Modify(user, emailID, modifyReq).Do()
 	if err != nil {
-		return fmt.Errorf("unable to trash message: %v", err)
+		return fmt.Errorf("unable to trash message: %w", err)
 	}
 
 	return nil
 }
 
+// MarkAsSpam moves a message to Spam, taking it out of INBOX
+func (s *Service) MarkAsSpam(ctx context.Context, accessToken, refreshToken, emailID string, onTokenRefresh TokenUpdateFunc) error {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return err
+	}
+
+	modifyReq := &gmail.ModifyMessageRequest{
+		AddLabelIds:    []string{"SPAM"},
+		RemoveLabelIds: []string{"INBOX"},
+	}
+	if _, err := srv.Users.Messages.Modify("me", emailID, modifyReq).Do(); err != nil {
+		return fmt.Errorf("unable to mark message as spam: %w", err)
+	}
+	return nil
+}
+
+// MarkNotSpam moves a message out of Spam and back to INBOX
+func (s *Service) MarkNotSpam(ctx context.Context, accessToken, refreshToken, emailID string, onTokenRefresh TokenUpdateFunc) error {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return err
+	}
+
+	modifyReq := &gmail.ModifyMessageRequest{
+		AddLabelIds:    []string{"INBOX"},
+		RemoveLabelIds: []string{"SPAM"},
+	}
+	if _, err := srv.Users.Messages.Modify("me", emailID, modifyReq).Do(); err != nil {
+		return fmt.Errorf("unable to mark message as not spam: %w", err)
+	}
+	return nil
+}
+
+// folderLabels are the system labels Gmail treats as folders; a message sits in at
+// most one of them
+var folderLabels = []string{"INBOX", "SPAM", "TRASH"}
+
+// MoveEmail adds the target label and removes the folder label the message is in,
+// the way Gmail's "Move to" does. Other labels are left alone.
+func (s *Service) MoveEmail(ctx context.Context, accessToken, refreshToken, emailID, targetLabelID string, onTokenRefresh TokenUpdateFunc) error {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return err
+	}
+
+	msg, err := srv.Users.Messages.Get("me", emailID).Format("minimal").Do()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve message: %w", err)
+	}
+
+	var remove []string
+	for _, label := range msg.LabelIds {
+		if label != targetLabelID && slices.Contains(folderLabels, label) {
+			remove = append(remove, label)
+		}
+	}
+	modifyReq := &gmail.ModifyMessageRequest{
+		AddLabelIds:    []string{targetLabelID},
+		RemoveLabelIds: remove,
+	}
+	if _, err := srv.Users.Messages.Modify("me", emailID, modifyReq).Do(); err != nil {
+		return fmt.Errorf("unable to move message: %w", err)
+	}
+	return nil
+}
+
 // ArchiveEmail archives an email (removes INBOX label)
 func (s *Service) ArchiveEmail(ctx context.Context, accessToken, refreshToken, emailID string, onTokenRefresh TokenUpdateFunc) error {
 	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
@@ -470,19 +692,96 @@ func (s *Service) ArchiveEmail(ctx context.Context, accessToken, refreshToken, e
 
 	_, err = srv.Users.Messages.Modify(user, emailID, modifyReq).Do()
 	if err != nil {
-		return fmt.Errorf("unable to archive message: %v", err)
+		return fmt.Errorf("unable to archive message: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteEmail permanently deletes a message that is in Trash or Spam. It needs the
+// full https://mail.google.com/ scope.
+func (s *Service) DeleteEmail(ctx context.Context, accessToken, refreshToken, emailID string, onTokenRefresh TokenUpdateFunc) error {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return err
+	}
+
+	msg, err := srv.Users.Messages.Get("me", emailID).Format("minimal").Do()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve message: %w", err)
+	}
+	if !hasLabel(msg.LabelIds, "TRASH") && !hasLabel(msg.LabelIds, "SPAM") {
+		return emaildomain.ErrNotInTrash
 	}
 
+	if err := srv.Users.Messages.Delete("me", emailID).Do(); err != nil {
+		return fmt.Errorf("unable to delete message: %w", err)
+	}
 	return nil
 }
 
-// Watch sets up push notifications for the user's mailbox
-func (s *Service) Watch(ctx context.Context, accessToken, refreshToken string, topicName string, onTokenRefresh TokenUpdateFunc) error {
+// EmptyTrash permanently deletes every message in Trash and returns how many there were
+func (s *Service) EmptyTrash(ctx context.Context, accessToken, refreshToken string, onTokenRefresh TokenUpdateFunc) (int, error) {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []string
+	call := srv.Users.Messages.List("me").LabelIds("TRASH").IncludeSpamTrash(true).MaxResults(500)
+	err = call.Pages(ctx, func(resp *gmail.ListMessagesResponse) error {
+		for _, msg := range resp.Messages {
+			ids = append(ids, msg.Id)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("unable to list trash: %w", err)
+	}
+
+	// BatchDelete takes at most 1000 IDs per call
+	deleted := 0
+	for start := 0; start < len(ids); start += 1000 {
+		end := start + 1000
+		if end > len(ids) {
+			end = len(ids)
+		}
+		req := &gmail.BatchDeleteMessagesRequest{Ids: ids[start:end]}
+		if err := srv.Users.Messages.BatchDelete("me", req).Do(); err != nil {
+			return deleted, fmt.Errorf("unable to delete messages: %w", err)
+		}
+		deleted = end
+	}
+	return deleted, nil
+}
+
+// BatchModify adds and removes labels on up to 1000 messages in one request. Gmail
+// applies it to all of them or none.
+func (s *Service) BatchModify(ctx context.Context, accessToken, refreshToken string, messageIDs, addLabels, removeLabels []string, onTokenRefresh TokenUpdateFunc) error {
 	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
 	if err != nil {
 		return err
 	}
 
+	req := &gmail.BatchModifyMessagesRequest{
+		Ids:            messageIDs,
+		AddLabelIds:    addLabels,
+		RemoveLabelIds: removeLabels,
+	}
+	if err := srv.Users.Messages.BatchModify("me", req).Do(); err != nil {
+		return fmt.Errorf("unable to modify messages: %w", err)
+	}
+	return nil
+}
+
+// Watch sets up push notifications for the user's mailbox and returns when the
+// watch expires, along with the mailbox history ID it starts from
+func (s *Service) Watch(ctx context.Context, accessToken, refreshToken string, topicName string, onTokenRefresh TokenUpdateFunc) (time.Time, uint64, error) {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
 	// Try to stop any existing watch first to avoid "Only one user push notification client allowed" error
 	// We ignore the error here because if there's no watch, it might fail, or if it succeeds, great.
 	// But strictly speaking, we just want to ensure we clear the state if possible.
@@ -498,11 +797,11 @@ func (s *Service) Watch(ctx context.Context, accessToken, refreshToken string, t
 	resp, err := srv.Users.Watch("me", req).Do()
 	if err != nil {
 		log.Printf("Gmail Watch API error: %v", err)
-		return fmt.Errorf("unable to watch mailbox: %v", err)
+		return time.Time{}, 0, fmt.Errorf("unable to watch mailbox: %w", err)
 	}
 	log.Printf("Watch started successfully. Expiration: %d, HistoryId: %d", resp.Expiration, resp.HistoryId)
 
-	return nil
+	return time.UnixMilli(resp.Expiration), resp.HistoryId, nil
 }
 
 // Stop stops push notifications for the user's mailbox
@@ -514,7 +813,7 @@ func (s *Service) Stop(ctx context.Context, accessToken, refreshToken string, on
 
 	err = srv.Users.Stop("me").Do()
 	if err != nil {
-		return fmt.Errorf("unable to stop mailbox watch: %v", err)
+		return fmt.Errorf("unable to stop mailbox watch: %w", err)
 	}
 
 	return nil
@@ -530,12 +829,12 @@ func convertGmailMessageToEmail(msg *gmail.Message) *emaildomain.Email {
 		fromName = strings.TrimSpace(from[:idx])
 	}
 
-	// Convert To header to array
-	toHeader := getHeader(msg.Payload.Headers, "To")
-	toArray := []string{}
-	if toHeader != "" {
-		toArray = []string{toHeader}
+	// One entry per recipient
+	toArray := mailutil.AddressList(getHeader(msg.Payload.Headers, "To"))
+	if toArray == nil {
+		toArray = []string{}
 	}
+	ccArray := mailutil.AddressList(getHeader(msg.Payload.Headers, "Cc"))
 
 	body, isHTML := getEmailBody(msg.Payload)
 	preview := body
@@ -556,18 +855,27 @@ func convertGmailMessageToEmail(msg *gmail.Message) *emaildomain.Email {
 	preview = strings.Join(strings.Fields(preview), " ")
 
 	// Truncate for preview
-	if len(preview) > 200 {
-		preview = preview[:200] + "..."
+	preview = mailutil.Truncate(preview, 200)
+
+	attachments := getAttachments(msg.Payload, body)
+	if isHTML {
+		body = mailutil.SanitizeHTML(body)
 	}
 
-	attachments := getAttachments(msg.Payload)
+	unsubscribeURL, unsubscribeMailto, oneClick := mailutil.ParseListUnsubscribe(
+		getHeader(msg.Payload.Headers, "List-Unsubscribe"),
+		getHeader(msg.Payload.Headers, "List-Unsubscribe-Post"),
+	)
 
 	email := &emaildomain.Email{
 		ID:          msg.Id,
+		StableID:    emailid.New(emailid.ProviderGmail, msg.Id),
+		ThreadID:    msg.ThreadId,
 		Subject:     getHeader(msg.Payload.Headers, "Subject"),
 		From:        from,
 		FromName:    fromName,
 		To:          toArray,
+		Cc:          ccArray,
 		Preview:     preview,
 		Body:        body,
 		IsHTML:      isHTML,
@@ -576,11 +884,72 @@ func convertGmailMessageToEmail(msg *gmail.Message) *emaildomain.Email {
 		IsStarred:   hasLabel(msg.LabelIds, "STARRED"),
 		MailboxID:   getMailboxID(msg.LabelIds),
 		Attachments: attachments,
+
+		UnsubscribeURL:      unsubscribeURL,
+		UnsubscribeMailto:   unsubscribeMailto,
+		UnsubscribeOneClick: oneClick,
+
+		Priority: mailutil.ParsePriority(func(name string) string {
+			return getHeaderFold(msg.Payload.Headers, name)
+		}),
+
+		MessageID:  getHeaderFold(msg.Payload.Headers, "Message-ID"),
+		References: getHeaderFold(msg.Payload.Headers, "References"),
 	}
 
+	applyCalendarInvite(email, msg.Payload)
+
 	return email
 }
 
+// applyCalendarInvite marks emails carrying a text/calendar part or .ics attachment as
+// invites. Inline event data is parsed right away, otherwise the attachment ID is kept
+// so the event can be fetched on demand.
+func applyCalendarInvite(email *emaildomain.Email, payload *gmail.MessagePart) {
+	var visit func(part *gmail.MessagePart) bool
+	visit = func(part *gmail.MessagePart) bool {
+		isCalendar := part.MimeType == "text/calendar" || part.MimeType == "application/ics" ||
+			strings.HasSuffix(strings.ToLower(part.Filename), ".ics")
+		if isCalendar && part.Body != nil {
+			email.IsInvite = true
+			if part.Body.Data != "" {
+				if data, err := base64.URLEncoding.DecodeString(part.Body.Data); err == nil {
+					if event, err := ical.Parse(data); err == nil {
+						email.Invite = event
+						return true
+					}
+				}
+			}
+			if part.Body.AttachmentId != "" && email.InviteAttachmentID == "" {
+				email.InviteAttachmentID = part.Body.AttachmentId
+			}
+		}
+		for _, child := range part.Parts {
+			if visit(child) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if payload != nil {
+		visit(payload)
+	}
+}
+
+// writeReplyHeaders adds In-Reply-To and References when answering a message
+func writeReplyHeaders(b *bytes.Buffer, reply *emaildomain.ReplyHeaders) {
+	if reply == nil {
+		return
+	}
+	if reply.InReplyTo != "" {
+		b.WriteString(fmt.Sprintf("In-Reply-To: %s\r\n", reply.InReplyTo))
+	}
+	if reply.References != "" {
+		b.WriteString(fmt.Sprintf("References: %s\r\n", reply.References))
+	}
+}
+
 func getHeader(headers []*gmail.MessagePartHeader, name string) string {
 	for _, header := range headers {
 		if header.Name == name {
@@ -590,6 +959,17 @@ func getHeader(headers []*gmail.MessagePartHeader, name string) string {
 	return ""
 }
 
+// getHeaderFold is getHeader with a case-insensitive name, senders disagree on
+// spellings such as Message-ID and Message-Id
+func getHeaderFold(headers []*gmail.MessagePartHeader, name string) string {
+	for _, header := range headers {
+		if strings.EqualFold(header.Name, name) {
+			return header.Value
+		}
+	}
+	return ""
+}
+
 func getEmailBody(payload *gmail.MessagePart) (string, bool) {
 	// If the payload itself is the body
 	if payload.Body != nil && payload.Body.Data != "" {
@@ -635,7 +1015,9 @@ func getEmailBody(payload *gmail.MessagePart) (string, bool) {
 	return plainBody, false
 }
 
-func getAttachments(payload *gmail.MessagePart) []emaildomain.Attachment {
+// getAttachments lists parts with a filename. body is used to tell cid-referenced
+// inline images apart from real attachments.
+func getAttachments(payload *gmail.MessagePart, body string) []emaildomain.Attachment {
 	var attachments []emaildomain.Attachment
 
 	var findAttachments func(parts []*gmail.MessagePart)
@@ -651,6 +1033,7 @@ func getAttachments(payload *gmail.MessagePart) []emaildomain.Attachment {
 					Size:      int64(part.Body.Size),
 					MimeType:  part.MimeType,
 					ContentID: contentID,
+					IsInline:  mailutil.IsInlinePart(getHeader(part.Headers, "Content-Disposition"), contentID, body),
 				})
 			}
 
@@ -715,6 +1098,33 @@ func getIconForLabel(name string) string {
 	return "folder"
 }
 
+// GetTokenScopes returns the OAuth scopes granted to an access token
+func (s *Service) GetTokenScopes(ctx context.Context, accessToken string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://oauth2.googleapis.com/tokeninfo?access_token="+url.QueryEscape(accessToken), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch token info: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("invalid or expired access token")
+	}
+
+	var info struct {
+		Scope string `json:"scope"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("unable to decode token info: %w", err)
+	}
+
+	return strings.Fields(info.Scope), nil
+}
+
 // ValidateToken validates the access token by making a simple API call
 func (s *Service) ValidateToken(ctx context.Context, accessToken, refreshToken string, onTokenRefresh TokenUpdateFunc) error {
 	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
@@ -0,0 +1,242 @@
+package gmail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/mail"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestGetEmailMetadataRequestsMetadataFormat(t *testing.T) {
+	var formats []string
+	ctx := fakeGmail(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/users/me/messages/m1") {
+			http.NotFound(w, r)
+			return
+		}
+		formats = append(formats, r.URL.Query().Get("format"))
+		json.NewEncoder(w).Encode(&gmail.Message{
+			Id:       "m1",
+			ThreadId: "t1",
+			LabelIds: []string{"INBOX", "UNREAD"},
+			Snippet:  "Noon at the usual place?",
+			Payload: &gmail.MessagePart{
+				MimeType: "text/plain",
+				Headers: []*gmail.MessagePartHeader{
+					{Name: "Subject", Value: "Lunch"},
+					{Name: "From", Value: "Alice <alice@example.com>"},
+				},
+			},
+		})
+	})
+
+	email, err := NewService("", "").GetEmailMetadata(ctx, "access", "", "m1", nil)
+	if err != nil {
+		t.Fatalf("GetEmailMetadata() error = %v", err)
+	}
+	if len(formats) != 1 || formats[0] != "metadata" {
+		t.Errorf("requested formats %v, want only metadata", formats)
+	}
+	if email.Subject != "Lunch" || email.Body != "" || email.Preview != "Noon at the usual place?" {
+		t.Errorf("email = (%q, %q, %q), want the headers and snippet without a body", email.Subject, email.Body, email.Preview)
+	}
+}
+
+func TestBuildRawMessageEncodesFromName(t *testing.T) {
+	raw, err := buildRawMessage("Đội Hỗ trợ", "support@example.com", "to@example.com", "", "", "Hi", "<p>Hi</p>", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := msg.Header.AddressList("From")
+	if err != nil || len(from) != 1 {
+		t.Fatalf("From = %q: %v", msg.Header.Get("From"), err)
+	}
+	if from[0].Name != "Đội Hỗ trợ" || from[0].Address != "support@example.com" {
+		t.Errorf("From parsed as (%q, %q)", from[0].Name, from[0].Address)
+	}
+	if strings.Contains(msg.Header.Get("From"), "Đội") {
+		t.Errorf("From = %q, want the name RFC 2047 encoded", msg.Header.Get("From"))
+	}
+}
+
+// Messages are fetched concurrently but handed over one by one in list order
+func TestStreamEmailsInListOrder(t *testing.T) {
+	ids := []string{"m1", "m2", "m3", "m4", "m5"}
+	ctx := fakeGmail(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/users/me/messages") {
+			list := &gmail.ListMessagesResponse{ResultSizeEstimate: 42}
+			for _, id := range ids {
+				list.Messages = append(list.Messages, &gmail.Message{Id: id})
+			}
+			json.NewEncoder(w).Encode(list)
+			return
+		}
+		id := path.Base(r.URL.Path)
+		// Earlier messages answer last
+		n, _ := strconv.Atoi(strings.TrimPrefix(id, "m"))
+		time.Sleep(time.Duration(len(ids)-n) * 5 * time.Millisecond)
+		json.NewEncoder(w).Encode(&gmail.Message{
+			Id:      id,
+			Payload: &gmail.MessagePart{MimeType: "text/plain", Headers: []*gmail.MessagePartHeader{{Name: "Subject", Value: "Subject " + id}}},
+		})
+	})
+
+	var got []string
+	total, err := NewService("", "").StreamEmails(ctx, "access", "", "INBOX", len(ids), 0, "", func(email *emaildomain.Email) {
+		got = append(got, email.ID)
+	}, nil)
+	if err != nil {
+		t.Fatalf("StreamEmails() error = %v", err)
+	}
+	if total != 42 {
+		t.Errorf("total = %d, want Gmail's estimate 42", total)
+	}
+	if !slices.Equal(got, ids) {
+		t.Errorf("emails arrived as %v, want %v", got, ids)
+	}
+}
+
+func TestApplyCalendarInvite(t *testing.T) {
+	ics := "BEGIN:VCALENDAR\r\nMETHOD:REQUEST\r\nBEGIN:VEVENT\r\nUID:abc\r\nSUMMARY:Planning\r\nDTSTART:20240305T090000Z\r\nDTEND:20240305T100000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+	t.Run("inline calendar part", func(t *testing.T) {
+		email := &emaildomain.Email{}
+		applyCalendarInvite(email, &gmail.MessagePart{
+			MimeType: "multipart/alternative",
+			Parts: []*gmail.MessagePart{
+				{MimeType: "text/plain", Body: &gmail.MessagePartBody{Data: base64.URLEncoding.EncodeToString([]byte("Join us"))}},
+				{MimeType: "text/calendar", Body: &gmail.MessagePartBody{Data: base64.URLEncoding.EncodeToString([]byte(ics))}},
+			},
+		})
+		if !email.IsInvite || email.Invite == nil || email.Invite.Summary != "Planning" || email.Invite.Method != "REQUEST" {
+			t.Errorf("email = (invite %v, %+v), want the parsed event", email.IsInvite, email.Invite)
+		}
+	})
+
+	t.Run("ics attachment", func(t *testing.T) {
+		email := &emaildomain.Email{}
+		applyCalendarInvite(email, &gmail.MessagePart{
+			MimeType: "multipart/mixed",
+			Parts: []*gmail.MessagePart{
+				{MimeType: "text/html", Body: &gmail.MessagePartBody{Data: base64.URLEncoding.EncodeToString([]byte("<p>Join us</p>"))}},
+				{MimeType: "application/octet-stream", Filename: "Invite.ICS", Body: &gmail.MessagePartBody{AttachmentId: "att-1", Size: 4096}},
+			},
+		})
+		// Attached data isn't in the message, it is fetched when the invite is opened
+		if !email.IsInvite || email.Invite != nil || email.InviteAttachmentID != "att-1" {
+			t.Errorf("email = (invite %v, %+v, %q), want an invite pointing at att-1", email.IsInvite, email.Invite, email.InviteAttachmentID)
+		}
+	})
+
+	t.Run("no calendar", func(t *testing.T) {
+		email := &emaildomain.Email{}
+		applyCalendarInvite(email, &gmail.MessagePart{MimeType: "text/plain", Body: &gmail.MessagePartBody{Data: "SGk"}})
+		if email.IsInvite {
+			t.Error("a plain message was marked as an invite")
+		}
+	})
+}
+
+func TestGetAttachmentsClassifiesInline(t *testing.T) {
+	payload := &gmail.MessagePart{
+		MimeType: "multipart/mixed",
+		Parts: []*gmail.MessagePart{
+			{
+				MimeType: "multipart/related",
+				Parts: []*gmail.MessagePart{
+					{MimeType: "text/html", Body: &gmail.MessagePartBody{Data: "PGltZz4"}},
+					{
+						MimeType: "image/png",
+						Filename: "logo.png",
+						Headers: []*gmail.MessagePartHeader{
+							{Name: "Content-ID", Value: "<logo@example.com>"},
+							{Name: "Content-Disposition", Value: `inline; filename="logo.png"`},
+						},
+						Body: &gmail.MessagePartBody{AttachmentId: "att-logo", Size: 1200},
+					},
+				},
+			},
+			{
+				MimeType: "application/pdf",
+				Filename: "report.pdf",
+				Headers:  []*gmail.MessagePartHeader{{Name: "Content-Disposition", Value: `attachment; filename="report.pdf"`}},
+				Body:     &gmail.MessagePartBody{AttachmentId: "att-pdf", Size: 52000},
+			},
+		},
+	}
+
+	attachments := getAttachments(payload, `<p>Hi</p><img src="cid:logo@example.com">`)
+	if len(attachments) != 2 {
+		t.Fatalf("got %d attachments, want 2: %+v", len(attachments), attachments)
+	}
+	logo, pdf := attachments[0], attachments[1]
+	if logo.ID != "att-logo" || !logo.IsInline || logo.ContentID != "logo@example.com" {
+		t.Errorf("logo = %+v, want an inline part with its content id", logo)
+	}
+	if pdf.ID != "att-pdf" || pdf.IsInline || pdf.Name != "report.pdf" || pdf.Size != 52000 {
+		t.Errorf("pdf = %+v, want a real attachment", pdf)
+	}
+}
+
+func TestPreviewKeepsAccentedCharacters(t *testing.T) {
+	text := "Chào, " + strings.Repeat("Hẹn gặp lúc trưa ", 15)
+	email := convertGmailMessageToEmail(&gmail.Message{
+		Id: "m1",
+		Payload: &gmail.MessagePart{
+			MimeType: "text/plain",
+			Body:     &gmail.MessagePartBody{Data: base64.URLEncoding.EncodeToString([]byte(text))},
+		},
+	})
+
+	// Cutting at byte 200 would split the "ặ" of a "gặp"
+	kept, ok := strings.CutSuffix(email.Preview, "...")
+	if !ok || !utf8.ValidString(email.Preview) || !strings.HasPrefix(text, kept+" ") {
+		t.Errorf("Preview = %q, want whole words of the body followed by ...", email.Preview)
+	}
+}
+
+func TestConvertSplitsRecipients(t *testing.T) {
+	email := convertGmailMessageToEmail(&gmail.Message{
+		Id: "m1",
+		Payload: &gmail.MessagePart{
+			MimeType: "text/plain",
+			Headers: []*gmail.MessagePartHeader{
+				{Name: "To", Value: `"Doe, Jane" <jane@example.com>, Alice <alice@example.com>`},
+				{Name: "Cc", Value: `bob@example.com, "Carol \"CJ\" Jones" <carol@example.com>`},
+			},
+		},
+	})
+
+	wantTo := []string{`"Doe, Jane" <jane@example.com>`, "Alice <alice@example.com>"}
+	wantCc := []string{"bob@example.com", `"Carol \"CJ\" Jones" <carol@example.com>`}
+	if !slices.Equal(email.To, wantTo) {
+		t.Errorf("To = %q, want %q", email.To, wantTo)
+	}
+	if !slices.Equal(email.Cc, wantCc) {
+		t.Errorf("Cc = %q, want %q", email.Cc, wantCc)
+	}
+}
+
+func TestConvertWithoutRecipients(t *testing.T) {
+	email := convertGmailMessageToEmail(&gmail.Message{Id: "m1", Payload: &gmail.MessagePart{MimeType: "text/plain"}})
+	// To stays an empty list rather than null in the JSON
+	if email.To == nil || len(email.To) != 0 || email.Cc != nil {
+		t.Errorf("recipients = (%#v, %#v), want an empty To and no Cc", email.To, email.Cc)
+	}
+}
@@ -0,0 +1,73 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func (s *Service) modifyThread(ctx context.Context, accessToken, refreshToken, threadID string, add, remove []string, onTokenRefresh TokenUpdateFunc) error {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return err
+	}
+
+	req := &gmail.ModifyThreadRequest{
+		AddLabelIds:    add,
+		RemoveLabelIds: remove,
+	}
+	if _, err := srv.Users.Threads.Modify("me", threadID, req).Do(); err != nil {
+		return fmt.Errorf("unable to modify thread: %w", err)
+	}
+	return nil
+}
+
+// MarkThreadAsRead marks every message in a thread as read
+func (s *Service) MarkThreadAsRead(ctx context.Context, accessToken, refreshToken, threadID string, onTokenRefresh TokenUpdateFunc) error {
+	return s.modifyThread(ctx, accessToken, refreshToken, threadID, nil, []string{"UNREAD"}, onTokenRefresh)
+}
+
+// MarkThreadAsUnread marks every message in a thread as unread
+func (s *Service) MarkThreadAsUnread(ctx context.Context, accessToken, refreshToken, threadID string, onTokenRefresh TokenUpdateFunc) error {
+	return s.modifyThread(ctx, accessToken, refreshToken, threadID, []string{"UNREAD"}, nil, onTokenRefresh)
+}
+
+// ToggleThreadStar unstars the whole thread if any message is starred, otherwise stars all of it
+func (s *Service) ToggleThreadStar(ctx context.Context, accessToken, refreshToken, threadID string, onTokenRefresh TokenUpdateFunc) error {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return err
+	}
+
+	thread, err := srv.Users.Threads.Get("me", threadID).Format("minimal").Do()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve thread: %w", err)
+	}
+
+	starred := false
+	for _, msg := range thread.Messages {
+		if hasLabel(msg.LabelIds, "STARRED") {
+			starred = true
+			break
+		}
+	}
+
+	if starred {
+		return s.modifyThread(ctx, accessToken, refreshToken, threadID, nil, []string{"STARRED"}, onTokenRefresh)
+	}
+	return s.modifyThread(ctx, accessToken, refreshToken, threadID, []string{"STARRED"}, nil, onTokenRefresh)
+}
+
+// TrashThread moves every message in a thread to the trash
+func (s *Service) TrashThread(ctx context.Context, accessToken, refreshToken, threadID string, onTokenRefresh TokenUpdateFunc) error {
+	srv, err := s.GetGmailService(ctx, accessToken, refreshToken, onTokenRefresh)
+	if err != nil {
+		return err
+	}
+
+	if _, err := srv.Users.Threads.Trash("me", threadID).Do(); err != nil {
+		return fmt.Errorf("unable to trash thread: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package gmail
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// threadRequest is one call the fake Gmail API received for thread t1
+type threadRequest struct {
+	method, action string
+	add, remove    []string
+}
+
+// fakeThreadAPI serves thread t1, whose messages carry the given labels, and records
+// the calls made against it
+func fakeThreadAPI(t *testing.T, labels ...[]string) (context.Context, *[]threadRequest) {
+	t.Helper()
+	var requests []threadRequest
+	ctx := fakeGmail(t, func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, "/gmail/v1/users/me/threads/t1")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		req := threadRequest{method: r.Method, action: strings.TrimPrefix(rest, "/")}
+		if req.action == "modify" {
+			var body gmail.ModifyThreadRequest
+			json.NewDecoder(r.Body).Decode(&body)
+			req.add, req.remove = body.AddLabelIds, body.RemoveLabelIds
+		}
+		requests = append(requests, req)
+
+		thread := &gmail.Thread{Id: "t1"}
+		for i, l := range labels {
+			thread.Messages = append(thread.Messages, &gmail.Message{Id: string(rune('a' + i)), ThreadId: "t1", LabelIds: l})
+		}
+		json.NewEncoder(w).Encode(thread)
+	})
+	return ctx, &requests
+}
+
+func TestThreadLabelChanges(t *testing.T) {
+	s := NewService("", "")
+	tests := []struct {
+		name   string
+		labels [][]string
+		action func(ctx context.Context, accessToken, refreshToken, threadID string, onTokenRefresh TokenUpdateFunc) error
+		add    []string
+		remove []string
+	}{
+		{"read", nil, s.MarkThreadAsRead, nil, []string{"UNREAD"}},
+		{"unread", nil, s.MarkThreadAsUnread, []string{"UNREAD"}, nil},
+		{"star", [][]string{{"INBOX"}, {"INBOX", "UNREAD"}}, s.ToggleThreadStar, []string{"STARRED"}, nil},
+		// Any starred message makes the toggle unstar the whole thread
+		{"unstar", [][]string{{"INBOX"}, {"INBOX", "STARRED"}}, s.ToggleThreadStar, nil, []string{"STARRED"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, requests := fakeThreadAPI(t, tt.labels...)
+			if err := tt.action(ctx, "access", "", "t1", nil); err != nil {
+				t.Fatalf("error = %v", err)
+			}
+
+			// One threads.modify call changes every message of the thread
+			last := (*requests)[len(*requests)-1]
+			if last.method != http.MethodPost || last.action != "modify" || !slices.Equal(last.add, tt.add) || !slices.Equal(last.remove, tt.remove) {
+				t.Errorf("last request = %+v, want a thread modify adding %v and removing %v", last, tt.add, tt.remove)
+			}
+		})
+	}
+}
+
+func TestTrashThreadUsesThreadsTrash(t *testing.T) {
+	ctx, requests := fakeThreadAPI(t)
+	if err := NewService("", "").TrashThread(ctx, "access", "", "t1", nil); err != nil {
+		t.Fatalf("TrashThread() error = %v", err)
+	}
+	if len(*requests) != 1 || (*requests)[0].method != http.MethodPost || (*requests)[0].action != "trash" {
+		t.Errorf("requests = %+v, want one threads.trash call", *requests)
+	}
+}
@@ -0,0 +1,78 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkTimeout bounds each readiness check, so a hung dependency reads as down
+// before the load balancer's own probe times out
+const checkTimeout = 3 * time.Second
+
+// Check reports whether one dependency works; nil means it does
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker serves the liveness and readiness probes
+type Checker struct {
+	mu     sync.Mutex
+	checks []namedCheck
+}
+
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// Add registers a dependency that /readyz checks under name
+func (h *Checker) Add(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, namedCheck{name: name, check: check})
+}
+
+// Live answers GET /healthz: the process is up and serving requests
+func (h *Checker) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Ready answers GET /readyz. Every dependency is checked concurrently; any failure
+// makes it 503 with each dependency's error in "checks".
+func (h *Checker) Ready(c *gin.Context) {
+	h.mu.Lock()
+	checks := append([]namedCheck(nil), h.checks...)
+	h.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), checkTimeout)
+	defer cancel()
+
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			errs[i] = check(ctx)
+		}(i, check.check)
+	}
+	wg.Wait()
+
+	status, code := "ok", http.StatusOK
+	results := make(map[string]string, len(checks))
+	for i, check := range checks {
+		if errs[i] != nil {
+			status, code = "degraded", http.StatusServiceUnavailable
+			results[check.name] = errs[i].Error()
+			continue
+		}
+		results[check.name] = "ok"
+	}
+	c.JSON(code, gin.H{"status": status, "checks": results})
+}
@@ -0,0 +1,206 @@
+package imageproxy
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"ga03-backend/pkg/safehttp"
+)
+
+var (
+	ErrBlockedURL       = safehttp.ErrBlockedURL
+	ErrNotImage         = errors.New("remote resource is not an image")
+	ErrImageTooLarge    = errors.New("image is too large")
+	ErrInvalidSignature = errors.New("invalid image url signature")
+	// ErrFetchFailed wraps network and upstream errors, whose details stay server-side
+	ErrFetchFailed = errors.New("could not fetch the image")
+)
+
+// Image is a fetched remote image
+type Image struct {
+	ContentType string
+	Data        []byte
+}
+
+type cachedImage struct {
+	image     *Image
+	expiresAt time.Time
+}
+
+// Proxy fetches remote images on behalf of the user, so senders never see the
+// user's IP or learn when a message was opened. Only public http(s) hosts are reachable.
+type Proxy struct {
+	client     *http.Client
+	secret     []byte
+	publicURL  string
+	maxBytes   int64
+	cacheBytes int64
+	cacheTTL   time.Duration
+
+	mu        sync.Mutex
+	cache     map[string]*cachedImage
+	cacheSize int64 // Bytes of image data in cache
+}
+
+// NewProxy creates an image proxy. secret signs proxied URLs so the endpoint can't be
+// used as an open proxy; when empty, a random key is used and links last until restart.
+// publicURL is the API origin images are served from, and cacheBytes caps the memory
+// the cache holds.
+func NewProxy(secret, publicURL string, maxBytes, cacheBytes int64, cacheTTL time.Duration) *Proxy {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	return &Proxy{
+		client:     safehttp.NewClient(15*time.Second, 2),
+		secret:     key,
+		publicURL:  strings.TrimRight(publicURL, "/"),
+		maxBytes:   maxBytes,
+		cacheBytes: cacheBytes,
+		cacheTTL:   cacheTTL,
+		cache:      make(map[string]*cachedImage),
+	}
+}
+
+// Sign returns the signature the proxy endpoint expects for rawURL in the given email
+func (p *Proxy) Sign(emailID, rawURL string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(emailID + "\n" + rawURL))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature produced by Sign
+func (p *Proxy) Verify(emailID, rawURL, signature string) bool {
+	return hmac.Equal([]byte(p.Sign(emailID, rawURL)), []byte(signature))
+}
+
+// ProxyURL returns the signed proxy endpoint URL that serves rawURL from the given email
+func (p *Proxy) ProxyURL(emailID, rawURL string) string {
+	q := url.Values{}
+	q.Set("src", rawURL)
+	q.Set("sig", p.Sign(emailID, rawURL))
+	return p.publicURL + "/api/emails/" + url.PathEscape(emailID) + "/proxy-image?" + q.Encode()
+}
+
+// Fetch downloads an image, serving it from the cache when possible
+func (p *Proxy) Fetch(ctx context.Context, rawURL string) (*Image, error) {
+	if img := p.cached(rawURL); img != nil {
+		return img, nil
+	}
+
+	if err := safehttp.ValidateURL(rawURL); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, ErrBlockedURL
+	}
+	req.Header.Set("Accept", "image/*")
+	req.Header.Set("User-Agent", "ga03-image-proxy")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrBlockedURL) {
+			return nil, ErrBlockedURL
+		}
+		return nil, fmt.Errorf("%w: %v", ErrFetchFailed, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", ErrFetchFailed, resp.StatusCode)
+	}
+
+	contentType := strings.ToLower(strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0]))
+	// SVG can carry scripts
+	if !strings.HasPrefix(contentType, "image/") || contentType == "image/svg+xml" {
+		return nil, ErrNotImage
+	}
+	if resp.ContentLength > p.maxBytes {
+		return nil, ErrImageTooLarge
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, p.maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading the body: %v", ErrFetchFailed, err)
+	}
+	if int64(len(data)) > p.maxBytes {
+		return nil, ErrImageTooLarge
+	}
+	// The header is the sender's claim, check the bytes as well. Formats the sniffer
+	// doesn't know come back as octet-stream and keep the declared type.
+	if sniffed := http.DetectContentType(data); strings.HasPrefix(sniffed, "image/") {
+		contentType = sniffed
+	} else if sniffed != "application/octet-stream" {
+		return nil, ErrNotImage
+	}
+
+	img := &Image{ContentType: contentType, Data: data}
+	p.store(rawURL, img)
+	return img, nil
+}
+
+func (p *Proxy) cached(rawURL string) *Image {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry, ok := p.cache[rawURL]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(entry.expiresAt) {
+		p.evictLocked(rawURL)
+		return nil
+	}
+	return entry.image
+}
+
+func (p *Proxy) store(rawURL string, img *Image) {
+	size := int64(len(img.Data))
+	if p.cacheTTL <= 0 || size > p.cacheBytes {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.evictLocked(rawURL)
+	if p.cacheSize+size > p.cacheBytes {
+		now := time.Now()
+		for key, entry := range p.cache {
+			if now.After(entry.expiresAt) {
+				p.evictLocked(key)
+			}
+		}
+	}
+	// Still full: drop the entries closest to expiring until the image fits
+	for p.cacheSize+size > p.cacheBytes {
+		var oldest string
+		for key, entry := range p.cache {
+			if oldest == "" || entry.expiresAt.Before(p.cache[oldest].expiresAt) {
+				oldest = key
+			}
+		}
+		p.evictLocked(oldest)
+	}
+	p.cache[rawURL] = &cachedImage{image: img, expiresAt: time.Now().Add(p.cacheTTL)}
+	p.cacheSize += size
+}
+
+func (p *Proxy) evictLocked(rawURL string) {
+	if entry, ok := p.cache[rawURL]; ok {
+		p.cacheSize -= int64(len(entry.image.Data))
+		delete(p.cache, rawURL)
+	}
+}
@@ -0,0 +1,95 @@
+package imageproxy
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSignatureIsBoundToEmail(t *testing.T) {
+	p := NewProxy("key", "http://api.test/", 1024, 1024, time.Hour)
+	src := "https://cdn.example.com/logo.png"
+
+	sig := p.Sign("e1", src)
+	if !p.Verify("e1", src, sig) {
+		t.Fatal("a signature doesn't verify")
+	}
+	if p.Verify("e2", src, sig) {
+		t.Error("a signature verified for another email")
+	}
+	if p.Verify("e1", src+"?x", sig) {
+		t.Error("a signature verified for another image")
+	}
+	if other := NewProxy("other key", "", 1024, 1024, time.Hour); other.Verify("e1", src, sig) {
+		t.Error("a signature verified under another key")
+	}
+}
+
+func TestRandomKeyWhenUnset(t *testing.T) {
+	a := NewProxy("", "", 1024, 1024, time.Hour)
+	b := NewProxy("", "", 1024, 1024, time.Hour)
+	if a.Sign("e1", "https://x.test/a.png") == b.Sign("e1", "https://x.test/a.png") {
+		t.Error("proxies without a secret share a signing key")
+	}
+}
+
+func TestProxyURL(t *testing.T) {
+	p := NewProxy("key", "http://api.test/", 1024, 1024, time.Hour)
+	src := "https://cdn.example.com/a.png?w=1&h=2"
+
+	got, err := url.Parse(p.ProxyURL("e/1", src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Host != "api.test" || got.EscapedPath() != "/api/emails/e%2F1/proxy-image" {
+		t.Errorf("ProxyURL() = %s, want the email's proxy-image route", got)
+	}
+	if got.Query().Get("src") != src || !p.Verify("e/1", src, got.Query().Get("sig")) {
+		t.Errorf("ProxyURL() query = %v, want src and a valid sig", got.Query())
+	}
+}
+
+func TestCacheBoundedByBytes(t *testing.T) {
+	p := NewProxy("key", "", 100, 100, time.Hour)
+	image := func(size int) *Image { return &Image{ContentType: "image/png", Data: make([]byte, size)} }
+
+	for i := range 4 {
+		p.store(fmt.Sprintf("https://x.test/%d.png", i), image(30))
+		time.Sleep(time.Millisecond)
+	}
+	if p.cacheSize > 100 {
+		t.Errorf("cache holds %d bytes, want at most 100", p.cacheSize)
+	}
+	if p.cached("https://x.test/0.png") != nil {
+		t.Error("the oldest image wasn't evicted")
+	}
+	if p.cached("https://x.test/3.png") == nil {
+		t.Error("the newest image isn't cached")
+	}
+
+	// An image bigger than the whole cache is served but never kept
+	p.store("https://x.test/big.png", image(101))
+	if p.cached("https://x.test/big.png") != nil || p.cacheSize > 100 {
+		t.Error("an image larger than the cache was cached")
+	}
+
+	// Storing the same URL again replaces it rather than counting it twice
+	p.store("https://x.test/3.png", image(30))
+	if p.cacheSize != 90 {
+		t.Errorf("cache holds %d bytes, want 90", p.cacheSize)
+	}
+}
+
+func TestFetchRejectsPrivateHosts(t *testing.T) {
+	p := NewProxy("key", "", 1024, 1024, time.Hour)
+	for _, src := range []string{"http://127.0.0.1/a.png", "http://10.0.0.1/a.png", "file:///etc/passwd"} {
+		if _, err := p.Fetch(t.Context(), src); !errors.Is(err, ErrBlockedURL) {
+			t.Errorf("Fetch(%q) error = %v, want ErrBlockedURL", src, err)
+		}
+	}
+	if len(p.cache) != 0 {
+		t.Error("a blocked URL was cached")
+	}
+}
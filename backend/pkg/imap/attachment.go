@@ -0,0 +1,104 @@
+package imap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-message"
+	"github.com/emersion/go-message/mail"
+)
+
+// GetAttachment re-fetches a message and returns the decoded content of one of its
+// parts. attachmentID is the part index parseBody assigned to the attachment.
+func (s *IMAPService) GetAttachment(ctx context.Context, server string, port int, emailAddr, password, messageID, attachmentID string) (*emaildomain.Attachment, []byte, error) {
+	mailboxName, uid, err := decodeEmailID(messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c, release, err := s.acquire(server, port, emailAddr, password)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	if _, err := s.selectMailbox(c, accountKey(server, emailAddr), mailboxName, true); err != nil {
+		return nil, nil, err
+	}
+
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uid)
+	section := &imap.BodySectionName{Peek: true}
+	messages := make(chan *imap.Message, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.UidFetch(seqset, []imap.FetchItem{imap.FetchUid, section.FetchItem()}, messages)
+	}()
+
+	msg := <-messages
+	if err := <-done; err != nil {
+		return nil, nil, err
+	}
+	if msg == nil {
+		return nil, nil, emaildomain.ErrEmailNotFound
+	}
+	r := msg.GetBody(section)
+	if r == nil {
+		return nil, nil, fmt.Errorf("email body not returned")
+	}
+	return readPart(r, attachmentID)
+}
+
+// readPart walks the message parts in the same order as parseBody and returns the one
+// at the index named by attachmentID
+func readPart(r io.Reader, attachmentID string) (*emaildomain.Attachment, []byte, error) {
+	mr, err := mail.CreateReader(r)
+	if err != nil && !message.IsUnknownCharset(err) {
+		return nil, nil, err
+	}
+
+	for index := 0; ; index++ {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, nil, emaildomain.ErrAttachmentNotFound
+		}
+		if message.IsUnknownEncoding(err) {
+			continue
+		}
+		if err != nil && !message.IsUnknownCharset(err) {
+			return nil, nil, err
+		}
+		if fmt.Sprintf("%d", index) != attachmentID {
+			continue
+		}
+
+		data, err := io.ReadAll(p.Body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read attachment: %w", err)
+		}
+
+		var ct, filename, contentID string
+		switch h := p.Header.(type) {
+		case *mail.InlineHeader:
+			ct, _, _ = h.ContentType()
+			_, params, _ := h.ContentDisposition()
+			filename, contentID = params["filename"], h.Get("Content-ID")
+		case *mail.AttachmentHeader:
+			ct, _, _ = h.ContentType()
+			filename, _ = h.Filename()
+			contentID = h.Get("Content-ID")
+		}
+		return &emaildomain.Attachment{
+			ID:        attachmentID,
+			Name:      filename,
+			Size:      int64(len(data)),
+			MimeType:  ct,
+			ContentID: strings.Trim(contentID, "<>"),
+		}, data, nil
+	}
+}
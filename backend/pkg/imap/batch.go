@@ -0,0 +1,226 @@
+package imap
+
+import (
+	"context"
+
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// mailboxBatch is the part of a batch that lives in one mailbox
+type mailboxBatch struct {
+	mailbox string
+	ids     map[uint32][]string // UID -> email IDs given for it
+}
+
+func (b *mailboxBatch) seqSet() *imap.SeqSet {
+	seqset := new(imap.SeqSet)
+	for uid := range b.ids {
+		seqset.AddNum(uid)
+	}
+	return seqset
+}
+
+// fail records err for every ID in the batch
+func (b *mailboxBatch) fail(results map[string]error, err error) {
+	for _, ids := range b.ids {
+		for _, id := range ids {
+			results[id] = err
+		}
+	}
+}
+
+// groupByMailbox decodes email IDs and groups them by mailbox. IDs that don't
+// decode get their error in results.
+func groupByMailbox(ids []string, results map[string]error) []*mailboxBatch {
+	var batches []*mailboxBatch
+	byMailbox := make(map[string]*mailboxBatch)
+	for _, id := range ids {
+		mailbox, uid, err := decodeEmailID(id)
+		if err != nil {
+			results[id] = err
+			continue
+		}
+		batch, ok := byMailbox[mailbox]
+		if !ok {
+			batch = &mailboxBatch{mailbox: mailbox, ids: make(map[uint32][]string)}
+			byMailbox[mailbox] = batch
+			batches = append(batches, batch)
+		}
+		batch.ids[uid] = append(batch.ids[uid], id)
+		results[id] = nil
+	}
+	return batches
+}
+
+// keepExisting drops UIDs that are gone from the selected mailbox, failing their IDs,
+// since STORE and COPY silently skip them
+func keepExisting(c *client.Client, batch *mailboxBatch, results map[string]error) error {
+	messages := make(chan *imap.Message, len(batch.ids))
+	done := make(chan error, 1)
+	go func() {
+		done <- c.UidFetch(batch.seqSet(), []imap.FetchItem{imap.FetchUid}, messages)
+	}()
+
+	found := make(map[uint32]bool, len(batch.ids))
+	for msg := range messages {
+		found[msg.Uid] = true
+	}
+	if err := <-done; err != nil {
+		return err
+	}
+
+	for uid, ids := range batch.ids {
+		if found[uid] {
+			continue
+		}
+		for _, id := range ids {
+			results[id] = emaildomain.ErrEmailNotFound
+		}
+		delete(batch.ids, uid)
+	}
+	return nil
+}
+
+// StoreFlags adds or removes flags on many emails with one UID STORE per mailbox.
+// The result maps every ID to its error, nil when it succeeded.
+func (s *IMAPService) StoreFlags(ctx context.Context, server string, port int, emailAddr, password string, ids []string, flags []interface{}, add bool) map[string]error {
+	results := make(map[string]error, len(ids))
+	batches := groupByMailbox(ids, results)
+	if len(batches) == 0 {
+		return results
+	}
+
+	c, release, err := s.acquire(server, port, emailAddr, password)
+	if err != nil {
+		for _, batch := range batches {
+			batch.fail(results, err)
+		}
+		return results
+	}
+	defer release()
+
+	item := imap.FormatFlagsOp(imap.AddFlags, true)
+	if !add {
+		item = imap.FormatFlagsOp(imap.RemoveFlags, true)
+	}
+
+	account := accountKey(server, emailAddr)
+	for _, batch := range batches {
+		if _, err := s.selectMailbox(c, account, batch.mailbox, false); err != nil {
+			batch.fail(results, err)
+			continue
+		}
+		if err := keepExisting(c, batch, results); err != nil {
+			batch.fail(results, err)
+			continue
+		}
+		if len(batch.ids) == 0 {
+			continue
+		}
+		if err := c.UidStore(batch.seqSet(), item, flags, nil); err != nil {
+			batch.fail(results, err)
+		}
+	}
+	return results
+}
+
+// MoveEmails copies many emails to the target mailbox and flags the originals as
+// deleted, with one UID COPY per source mailbox. "TRASH" and "ALL" resolve the same
+// way as TrashEmail and ArchiveEmail.
+func (s *IMAPService) MoveEmails(ctx context.Context, server string, port int, emailAddr, password string, ids []string, targetMailboxID string) map[string]error {
+	results := make(map[string]error, len(ids))
+	batches := groupByMailbox(ids, results)
+	if len(batches) == 0 {
+		return results
+	}
+
+	failAll := func(err error) map[string]error {
+		for _, batch := range batches {
+			batch.fail(results, err)
+		}
+		return results
+	}
+
+	c, release, err := s.acquire(server, port, emailAddr, password)
+	if err != nil {
+		return failAll(err)
+	}
+	defer release()
+
+	account := accountKey(server, emailAddr)
+	target, err := s.resolveMailboxName(c, account, targetMailboxID)
+	if err != nil {
+		return failAll(err)
+	}
+
+	for _, batch := range batches {
+		if batch.mailbox == target {
+			continue
+		}
+		if _, err := s.selectMailbox(c, account, batch.mailbox, false); err != nil {
+			batch.fail(results, err)
+			continue
+		}
+		if err := keepExisting(c, batch, results); err != nil {
+			batch.fail(results, err)
+			continue
+		}
+		if len(batch.ids) == 0 {
+			continue
+		}
+		seqset := batch.seqSet()
+		if err := c.UidCopy(seqset, target); err != nil {
+			// The cached target may be stale
+			s.names.invalidate(account)
+			batch.fail(results, err)
+			continue
+		}
+		item := imap.FormatFlagsOp(imap.AddFlags, true)
+		if err := c.UidStore(seqset, item, []interface{}{imap.DeletedFlag}, nil); err != nil {
+			batch.fail(results, err)
+		}
+	}
+	return results
+}
+
+// GetEmailsByIDs fetches many emails with one UID FETCH per mailbox, without marking
+// them read. Emails that no longer exist are missing from the result.
+func (s *IMAPService) GetEmailsByIDs(ctx context.Context, server string, port int, emailAddr, password string, ids []string) (map[string]*emaildomain.Email, error) {
+	c, release, err := s.acquire(server, port, emailAddr, password)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	decodeErrs := make(map[string]error, len(ids))
+	account := accountKey(server, emailAddr)
+	result := make(map[string]*emaildomain.Email, len(ids))
+	for _, batch := range groupByMailbox(ids, decodeErrs) {
+		if _, err := s.selectMailbox(c, account, batch.mailbox, true); err != nil {
+			// The mailbox was removed or renamed, its emails are gone with it
+			continue
+		}
+
+		section := &imap.BodySectionName{Peek: true}
+		items := []imap.FetchItem{imap.FetchEnvelope, imap.FetchFlags, imap.FetchInternalDate, imap.FetchUid, section.FetchItem()}
+		messages := make(chan *imap.Message, len(batch.ids))
+		done := make(chan error, 1)
+		go func() {
+			done <- c.UidFetch(batch.seqSet(), items, messages)
+		}()
+
+		for msg := range messages {
+			email := s.messageToEmail(msg, section, batch.mailbox, batch.mailbox)
+			for _, id := range batch.ids[msg.Uid] {
+				result[id] = email
+			}
+		}
+		if err := <-done; err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,337 @@
+package imap
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/responses"
+)
+
+// statusHighestModSeq is the CONDSTORE STATUS item (RFC 7162)
+const statusHighestModSeq imap.StatusItem = "HIGHESTMODSEQ"
+
+// mailboxMark is where a folder stood at the last change query. UIDs are only
+// comparable while UIDValidity stays the same.
+type mailboxMark struct {
+	UIDValidity uint32 `json:"v"`
+	UIDNext     uint32 `json:"n"`
+	ModSeq      uint64 `json:"m,omitempty"`
+}
+
+func encodeWatermark(marks map[string]mailboxMark) (string, error) {
+	data, err := json.Marshal(marks)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeWatermark(watermark string) (map[string]mailboxMark, error) {
+	data, err := base64.RawURLEncoding.DecodeString(watermark)
+	if err != nil {
+		return nil, emaildomain.ErrInvalidWatermark
+	}
+	marks := make(map[string]mailboxMark)
+	if err := json.Unmarshal(data, &marks); err != nil {
+		return nil, emaildomain.ErrInvalidWatermark
+	}
+	return marks, nil
+}
+
+// GetChanges reports what changed in every selectable folder since watermark, an
+// opaque value returned by an earlier call. New arrivals are found by UID; flag
+// changes need CONDSTORE and deletions need QRESYNC, so on servers without them
+// the result is marked incomplete. Without a watermark only messages received
+// after since are reported.
+func (s *IMAPService) GetChanges(ctx context.Context, server string, port int, emailAddr, password, watermark string, since time.Time) (*emaildomain.Changes, error) {
+	var marks map[string]mailboxMark
+	if watermark != "" {
+		var err error
+		if marks, err = decodeWatermark(watermark); err != nil {
+			return nil, err
+		}
+	}
+
+	// QRESYNC changes how expunges are reported for the rest of the session, so
+	// this runs on its own connection rather than a pooled one
+	c, err := s.connect(server, port, emailAddr, password)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Logout()
+
+	condstore, _ := c.Support("CONDSTORE")
+	qresync, _ := c.Support("QRESYNC")
+	if qresync {
+		if _, err := c.Enable([]string{"QRESYNC"}); err != nil {
+			qresync = false
+		}
+	}
+
+	names, err := selectableMailboxes(c)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []imap.StatusItem{imap.StatusUidNext, imap.StatusUidValidity}
+	if condstore {
+		items = append(items, statusHighestModSeq)
+	}
+
+	changes := &emaildomain.Changes{
+		Added:    []string{},
+		Modified: []string{},
+		Deleted:  []string{},
+		Complete: watermark != "" && condstore && qresync,
+	}
+	next := make(map[string]mailboxMark, len(names))
+	for _, name := range names {
+		status, err := c.Status(name, items)
+		if err != nil {
+			// Keep the old mark so the folder is retried next time
+			if prev, ok := marks[name]; ok {
+				next[name] = prev
+			}
+			changes.Complete = false
+			continue
+		}
+		mark := mailboxMark{UIDValidity: status.UidValidity, UIDNext: status.UidNext}
+		if condstore {
+			mark.ModSeq = parseModSeq(status.Items[statusHighestModSeq])
+		}
+		next[name] = mark
+
+		prev, known := marks[name]
+		if !known || prev.UIDValidity != mark.UIDValidity {
+			// A folder we haven't seen, or one whose UIDs were reset: all that can
+			// be told is what arrived after since
+			if watermark != "" {
+				changes.Complete = false
+			}
+			if since.IsZero() {
+				continue
+			}
+			uids, err := receivedSince(c, name, since)
+			if err != nil {
+				changes.Complete = false
+				continue
+			}
+			for _, uid := range uids {
+				changes.Added = append(changes.Added, encodeEmailID(name, uid))
+			}
+			continue
+		}
+		if prev == mark && condstore {
+			continue
+		}
+
+		if _, err := c.Select(name, true); err != nil {
+			next[name] = prev
+			changes.Complete = false
+			continue
+		}
+		if mark.UIDNext > prev.UIDNext {
+			uids, err := uidsFrom(c, prev.UIDNext)
+			if err != nil {
+				next[name] = prev
+				changes.Complete = false
+				continue
+			}
+			for _, uid := range uids {
+				changes.Added = append(changes.Added, encodeEmailID(name, uid))
+			}
+		}
+		if !condstore || prev.UIDNext <= 1 {
+			continue
+		}
+		if prev.ModSeq == 0 {
+			// The server didn't report a mod-sequence last time
+			changes.Complete = false
+			continue
+		}
+		modified, vanished, err := changedSince(c, prev.UIDNext-1, prev.ModSeq, qresync)
+		if err != nil {
+			next[name] = prev
+			changes.Complete = false
+			continue
+		}
+		for _, uid := range modified {
+			changes.Modified = append(changes.Modified, encodeEmailID(name, uid))
+		}
+		for _, uid := range vanished {
+			changes.Deleted = append(changes.Deleted, encodeEmailID(name, uid))
+		}
+	}
+
+	if changes.Watermark, err = encodeWatermark(next); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func selectableMailboxes(c *client.Client) ([]string, error) {
+	infos, err := listMailboxes(c)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, m := range infos {
+		if isSelectable(m) {
+			names = append(names, m.Name)
+		}
+	}
+	return names, nil
+}
+
+// receivedSince returns the UIDs of messages in name whose internal date is after since.
+// SEARCH SINCE only has day granularity, so the dates are checked again.
+func receivedSince(c *client.Client, name string, since time.Time) ([]uint32, error) {
+	if _, err := c.Select(name, true); err != nil {
+		return nil, err
+	}
+	criteria := imap.NewSearchCriteria()
+	criteria.Since = since
+	uids, err := c.UidSearch(criteria)
+	if err != nil || len(uids) == 0 {
+		return nil, err
+	}
+
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uids...)
+	messages := make(chan *imap.Message, len(uids))
+	done := make(chan error, 1)
+	go func() {
+		done <- c.UidFetch(seqset, []imap.FetchItem{imap.FetchUid, imap.FetchInternalDate}, messages)
+	}()
+
+	var result []uint32
+	for msg := range messages {
+		if msg.InternalDate.After(since) {
+			result = append(result, msg.Uid)
+		}
+	}
+	return result, <-done
+}
+
+// uidsFrom returns the UIDs at or above first in the selected mailbox. "first:*"
+// always matches the highest UID even when it is below first, hence the filter.
+func uidsFrom(c *client.Client, first uint32) ([]uint32, error) {
+	criteria := imap.NewSearchCriteria()
+	criteria.Uid = new(imap.SeqSet)
+	criteria.Uid.AddRange(first, 0)
+	uids, err := c.UidSearch(criteria)
+	if err != nil {
+		return nil, err
+	}
+	var result []uint32
+	for _, uid := range uids {
+		if uid >= first {
+			result = append(result, uid)
+		}
+	}
+	return result, nil
+}
+
+// changedSince runs UID FETCH 1:lastUID (FLAGS) (CHANGEDSINCE modseq [VANISHED])
+// on the selected mailbox and returns the UIDs whose flags changed and, with
+// QRESYNC enabled, the UIDs that were expunged
+func changedSince(c *client.Client, lastUID uint32, modSeq uint64, qresync bool) (modified, vanished []uint32, err error) {
+	seqset := new(imap.SeqSet)
+	seqset.AddRange(1, lastUID)
+	modifiers := []interface{}{imap.RawString("CHANGEDSINCE"), imap.RawString(strconv.FormatUint(modSeq, 10))}
+	if qresync {
+		modifiers = append(modifiers, imap.RawString("VANISHED"))
+	}
+	cmd := &imap.Command{
+		Name:      "UID",
+		Arguments: []interface{}{imap.RawString("FETCH"), seqset, []interface{}{imap.RawString("UID"), imap.RawString("FLAGS")}, modifiers},
+	}
+
+	handler := responses.HandlerFunc(func(resp imap.Resp) error {
+		name, fields, ok := imap.ParseNamedResp(resp)
+		if !ok {
+			return responses.ErrUnhandled
+		}
+		switch name {
+		case "FETCH":
+			if len(fields) < 2 {
+				return responses.ErrUnhandled
+			}
+			list, ok := fields[1].([]interface{})
+			if !ok {
+				return responses.ErrUnhandled
+			}
+			msg := &imap.Message{}
+			if err := msg.Parse(list); err != nil {
+				return err
+			}
+			if msg.Uid != 0 && msg.Uid <= lastUID {
+				modified = append(modified, msg.Uid)
+			}
+			return nil
+		case "VANISHED":
+			// "* VANISHED (EARLIER) 41,43:116"; the set is always the last field
+			if len(fields) == 0 {
+				return responses.ErrUnhandled
+			}
+			raw, ok := fields[len(fields)-1].(string)
+			if !ok {
+				return responses.ErrUnhandled
+			}
+			set, err := imap.ParseSeqSet(raw)
+			if err != nil {
+				return err
+			}
+			vanished = append(vanished, expandSeqSet(set, lastUID)...)
+			return nil
+		}
+		return responses.ErrUnhandled
+	})
+
+	status, err := c.Execute(cmd, handler)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := status.Err(); err != nil {
+		return nil, nil, err
+	}
+	return modified, vanished, nil
+}
+
+// expandSeqSet lists the numbers in set, treating "*" as max
+func expandSeqSet(set *imap.SeqSet, max uint32) []uint32 {
+	var nums []uint32
+	for _, seq := range set.Set {
+		start, stop := seq.Start, seq.Stop
+		if start == 0 {
+			start = max
+		}
+		if stop == 0 {
+			stop = max
+		}
+		if start > stop {
+			start, stop = stop, start
+		}
+		for n := start; n <= stop && n <= max; n++ {
+			nums = append(nums, n)
+		}
+	}
+	return nums
+}
+
+func parseModSeq(v interface{}) uint64 {
+	s, ok := v.(string)
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+	return n
+}
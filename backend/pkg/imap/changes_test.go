@@ -0,0 +1,116 @@
+package imap
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"github.com/emersion/go-imap"
+)
+
+func TestGetChangesReportsArrivals(t *testing.T) {
+	ts := newTestServer(t)
+	since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ts.addMessage(plainMessage, since.Add(-time.Hour))
+	// The memory backend's SEARCH SINCE leaves out the day of since itself, so the
+	// recent message arrives the day after
+	recent := ts.addMessage(strings.Replace(plainMessage, "<lunch@", "<recent@", 1), since.Add(26*time.Hour))
+	s := NewService()
+	ctx := context.Background()
+
+	// First sync: only what arrived after since, and nothing else can be told
+	first, err := s.GetChanges(ctx, ts.host, ts.port, testUser, testPassword, "", since)
+	if err != nil {
+		t.Fatalf("GetChanges() error = %v", err)
+	}
+	if !reflect.DeepEqual(first.Added, []string{recent}) || first.Complete || first.Watermark == "" {
+		t.Fatalf("first sync = %+v, want only the recent message and a watermark", first)
+	}
+
+	// Nothing new since the watermark
+	unchanged, err := s.GetChanges(ctx, ts.host, ts.port, testUser, testPassword, first.Watermark, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unchanged.Added)+len(unchanged.Modified)+len(unchanged.Deleted) != 0 {
+		t.Errorf("unchanged mailbox reported %+v", unchanged)
+	}
+
+	arrived := ts.addMessage(strings.Replace(plainMessage, "<lunch@", "<later@", 1), since.Add(-48*time.Hour))
+	changes, err := s.GetChanges(ctx, ts.host, ts.port, testUser, testPassword, unchanged.Watermark, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Found by UID, so an old internal date doesn't hide it
+	if !reflect.DeepEqual(changes.Added, []string{arrived}) {
+		t.Errorf("Added = %q, want the new message %q", changes.Added, arrived)
+	}
+	// The test server has no CONDSTORE, so flag changes and deletions can't be known
+	if changes.Complete {
+		t.Error("Complete = true from a server without CONDSTORE and QRESYNC")
+	}
+	if changes.Watermark == unchanged.Watermark {
+		t.Error("the watermark didn't move past the new message")
+	}
+}
+
+func TestGetChangesInvalidWatermark(t *testing.T) {
+	ts := newTestServer(t)
+	for _, watermark := range []string{"not base64!", "bm90IGpzb24"} {
+		_, err := NewService().GetChanges(context.Background(), ts.host, ts.port, testUser, testPassword, watermark, time.Time{})
+		if !errors.Is(err, emaildomain.ErrInvalidWatermark) {
+			t.Errorf("GetChanges(%q) error = %v, want ErrInvalidWatermark", watermark, err)
+		}
+	}
+}
+
+func TestWatermarkRoundTrip(t *testing.T) {
+	marks := map[string]mailboxMark{
+		"INBOX":      {UIDValidity: 1, UIDNext: 42, ModSeq: 9001},
+		"Sent Items": {UIDValidity: 7, UIDNext: 3},
+	}
+	watermark, err := encodeWatermark(marks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := decodeWatermark(watermark)
+	if err != nil || !reflect.DeepEqual(decoded, marks) {
+		t.Errorf("decodeWatermark(encodeWatermark()) = %+v, %v, want %+v", decoded, err, marks)
+	}
+}
+
+func TestExpandSeqSet(t *testing.T) {
+	tests := []struct {
+		set  string
+		max  uint32
+		want []uint32
+	}{
+		{"41,43:45", 100, []uint32{41, 43, 44, 45}},
+		{"5:3", 100, []uint32{3, 4, 5}},
+		{"8:*", 10, []uint32{8, 9, 10}},
+		// UIDs past the last one known are left out
+		{"9:12", 10, []uint32{9, 10}},
+	}
+	for _, tt := range tests {
+		set, err := imap.ParseSeqSet(tt.set)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := expandSeqSet(set, tt.max); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandSeqSet(%q, %d) = %v, want %v", tt.set, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestParseModSeq(t *testing.T) {
+	for v, want := range map[interface{}]uint64{"12345": 12345, " 7 ": 7, "x": 0, 42: 0} {
+		if got := parseModSeq(v); got != want {
+			t.Errorf("parseModSeq(%v) = %d, want %d", v, got, want)
+		}
+	}
+}
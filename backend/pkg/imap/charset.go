@@ -0,0 +1,14 @@
+package imap
+
+import (
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-message/charset"
+)
+
+// go-imap decodes RFC 2047 encoded-words in envelope subjects and names, and go-message
+// decodes body parts, but both only know UTF-8 and ASCII on their own. Anything else,
+// e.g. ISO-8859-1, Windows-1258 or Shift_JIS, was left encoded. Importing the charset
+// package registers its reader with go-message; go-imap needs it set explicitly.
+func init() {
+	imap.CharsetReader = charset.Reader
+}
@@ -2,25 +2,52 @@ package imap
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"strings"
+	"time"
 
 	"github.com/emersion/go-imap/client"
 )
 
-// ConnectAndLogin connects to an IMAP server and logs in
-func ConnectAndLogin(server string, port int, email, password string) (*client.Client, error) {
+var (
+	// ErrServerUnreachable is returned when no IMAP connection could be made
+	ErrServerUnreachable = errors.New("could not connect to the IMAP server")
+	// ErrAuthFailed is returned when the server rejects the credentials
+	ErrAuthFailed = errors.New("IMAP server rejected the email or password")
+	// ErrAppPasswordRequired is returned when the account needs an app password, as
+	// Gmail does with 2-Step Verification on
+	ErrAppPasswordRequired = errors.New("this account needs an app password for IMAP: create one in your Google Account under Security > App passwords (https://myaccount.google.com/apppasswords) and sign in with it instead of your normal password")
+)
+
+const dialTimeout = 30 * time.Second
+
+// loginError classifies a rejected LOGIN, keeping the server's text for the log
+func loginError(err error) error {
+	text := strings.ToLower(err.Error())
+	if strings.Contains(text, "application-specific password") || strings.Contains(text, "app password") {
+		return fmt.Errorf("%w (%v)", ErrAppPasswordRequired, err)
+	}
+	return fmt.Errorf("%w (%v)", ErrAuthFailed, err)
+}
+
+// ConnectAndLogin connects to an IMAP server and logs in. With an OAuth2 access token
+// it authenticates with XOAUTH2, otherwise with the password.
+func ConnectAndLogin(server string, port int, email, password, accessToken string) (*client.Client, error) {
 	addr := fmt.Sprintf("%s:%d", server, port)
 	log.Printf("Connecting to IMAP server: %s", addr)
 
 	// Connect to server
-	c, err := client.DialTLS(addr, nil)
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	c, err := client.DialWithDialerTLS(dialer, addr, nil)
 	if err != nil {
 		// Try non-TLS if TLS fails, though usually 993 is TLS
 		log.Printf("TLS connection failed, trying plain/STARTTLS: %v", err)
-		c, err = client.Dial(addr)
+		c, err = client.DialWithDialer(dialer, addr)
 		if err != nil {
-			return nil, fmt.Errorf("failed to connect to IMAP server: %w", err)
+			return nil, fmt.Errorf("%w: %v", ErrServerUnreachable, err)
 		}
         
         // Check if STARTTLS is supported and use it if possible
@@ -34,8 +61,18 @@ func ConnectAndLogin(server string, port int, email, password string) (*client.C
 	log.Println("Connected to IMAP server")
 
 	// Login
-	if err := c.Login(email, password); err != nil {
-		return nil, fmt.Errorf("failed to login to IMAP server: %w", err)
+	if accessToken != "" {
+		if ok, _ := c.SupportAuth("XOAUTH2"); !ok {
+			c.Logout()
+			return nil, fmt.Errorf("IMAP server %s does not support OAuth2 sign-in", server)
+		}
+		if err := c.Authenticate(NewXoauth2Client(email, accessToken)); err != nil {
+			c.Logout()
+			return nil, fmt.Errorf("%w (%v)", ErrAuthFailed, err)
+		}
+	} else if err := c.Login(email, password); err != nil {
+		c.Logout()
+		return nil, loginError(err)
 	}
 
 	log.Println("Logged in to IMAP server")
@@ -0,0 +1,54 @@
+package imap
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestConnectAndLogin(t *testing.T) {
+	ts := newTestServer(t)
+
+	c, err := ConnectAndLogin(ts.host, ts.port, testUser, testPassword, "")
+	if err != nil {
+		t.Fatalf("ConnectAndLogin() error = %v", err)
+	}
+	c.Logout()
+
+	if _, err := ConnectAndLogin(ts.host, ts.port, testUser, "typo", ""); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("ConnectAndLogin() with a wrong password error = %v, want ErrAuthFailed", err)
+	}
+}
+
+func TestConnectAndLoginUnreachable(t *testing.T) {
+	// A port nothing listens on any more
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().(*net.TCPAddr)
+	listener.Close()
+
+	if _, err := ConnectAndLogin("127.0.0.1", addr.Port, testUser, testPassword, ""); !errors.Is(err, ErrServerUnreachable) {
+		t.Errorf("ConnectAndLogin() error = %v, want ErrServerUnreachable", err)
+	}
+}
+
+func TestLoginError(t *testing.T) {
+	tests := []struct {
+		server string
+		want   error
+	}{
+		// What Gmail answers when 2-Step Verification is on
+		{"[ALERT] Application-specific password required: https://support.google.com/accounts/answer/185833 (Failure)", ErrAppPasswordRequired},
+		{"Please use an App Password to sign in", ErrAppPasswordRequired},
+		{"[AUTHENTICATIONFAILED] Invalid credentials (Failure)", ErrAuthFailed},
+		{"LOGIN failed.", ErrAuthFailed},
+	}
+	for _, tt := range tests {
+		err := loginError(errors.New(tt.server))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("loginError(%q) = %v, want %v", tt.server, err, tt.want)
+		}
+	}
+}
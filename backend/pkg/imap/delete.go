@@ -0,0 +1,85 @@
+package imap
+
+import (
+	"context"
+
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"github.com/emersion/go-imap"
+)
+
+// DeleteEmail permanently removes a message from the Trash or Spam folder. Other
+// folders are refused so a stray call can't wipe mail from INBOX.
+func (s *IMAPService) DeleteEmail(ctx context.Context, server string, port int, emailAddr, password, messageID string) error {
+	mailboxName, uid, err := decodeEmailID(messageID)
+	if err != nil {
+		return err
+	}
+
+	c, release, err := s.acquire(server, port, emailAddr, password)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	account := accountKey(server, emailAddr)
+	trash, err := s.findMoveTarget(c, account, "trash")
+	if err != nil {
+		return err
+	}
+	names, err := s.resolveFolders(c, account)
+	if err != nil {
+		return err
+	}
+	if mailboxName != trash && mailboxName != names["SPAM"] {
+		return emaildomain.ErrNotInTrash
+	}
+
+	if _, err := s.selectMailbox(c, account, mailboxName, false); err != nil {
+		return err
+	}
+
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uid)
+	item := imap.FormatFlagsOp(imap.AddFlags, true)
+	if err := c.UidStore(seqset, item, []interface{}{imap.DeletedFlag}, nil); err != nil {
+		return err
+	}
+	// Without UIDPLUS this expunges every \Deleted message in the folder, which
+	// in Trash or Spam only removes mail that was marked for deletion anyway
+	return c.Expunge(nil)
+}
+
+// EmptyTrash permanently deletes everything in the Trash folder and returns how many
+// messages it held
+func (s *IMAPService) EmptyTrash(ctx context.Context, server string, port int, emailAddr, password string) (int, error) {
+	c, release, err := s.acquire(server, port, emailAddr, password)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	account := accountKey(server, emailAddr)
+	trash, err := s.findMoveTarget(c, account, "trash")
+	if err != nil {
+		return 0, err
+	}
+	mbox, err := s.selectMailbox(c, account, trash, false)
+	if err != nil {
+		return 0, err
+	}
+	if mbox.Messages == 0 {
+		return 0, nil
+	}
+
+	seqset := new(imap.SeqSet)
+	seqset.AddRange(1, 0)
+	item := imap.FormatFlagsOp(imap.AddFlags, true)
+	if err := c.Store(seqset, item, []interface{}{imap.DeletedFlag}, nil); err != nil {
+		return 0, err
+	}
+	if err := c.Expunge(nil); err != nil {
+		return 0, err
+	}
+	return int(mbox.Messages), nil
+}
@@ -0,0 +1,217 @@
+package imap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-message/textproto"
+)
+
+// openDraft selects the Drafts folder and returns it with the draft's UID. IDs from
+// other folders are reported as ErrDraftNotFound.
+func (s *IMAPService) openDraft(c *client.Client, account, draftID string) (string, uint32, error) {
+	drafts, err := s.resolveMailboxName(c, account, "DRAFT")
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := s.selectMailbox(c, account, drafts, false); err != nil {
+		return "", 0, err
+	}
+	if draftID == "" {
+		return drafts, 0, nil
+	}
+
+	mailboxName, uid, err := decodeEmailID(draftID)
+	if err != nil {
+		return "", 0, err
+	}
+	if mailboxName != drafts {
+		return "", 0, emaildomain.ErrDraftNotFound
+	}
+	found, err := hasUID(c, uid)
+	if err != nil {
+		return "", 0, err
+	}
+	if !found {
+		return "", 0, emaildomain.ErrDraftNotFound
+	}
+	return drafts, uid, nil
+}
+
+func hasUID(c *client.Client, uid uint32) (bool, error) {
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uid)
+	messages := make(chan *imap.Message, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.UidFetch(seqset, []imap.FetchItem{imap.FetchUid}, messages)
+	}()
+
+	found := false
+	for range messages {
+		found = true
+	}
+	return found, <-done
+}
+
+// removeDraft expunges a draft from the selected Drafts folder
+func removeDraft(c *client.Client, uid uint32) error {
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uid)
+	item := imap.FormatFlagsOp(imap.AddFlags, true)
+	if err := c.UidStore(seqset, item, []interface{}{imap.DeletedFlag}, nil); err != nil {
+		return err
+	}
+	return c.Expunge(nil)
+}
+
+// SaveDraft appends msg to the Drafts folder flagged \Draft and returns its email ID.
+// Passing an existing draft's ID replaces that draft, so repeated autosaves don't pile
+// up copies. IMAP messages can't be edited in place, so the returned ID changes on
+// every save.
+func (s *IMAPService) SaveDraft(ctx context.Context, server string, port int, emailAddr, password, draftID string, msg *emaildomain.ComposedEmail) (string, error) {
+	raw, messageID, err := buildMessage(emailAddr, msg.FromName, msg.To, msg.Cc, msg.Bcc, msg.Subject, msg.Body, nil, nil, true)
+	if err != nil {
+		return "", err
+	}
+
+	c, release, err := s.acquire(server, port, emailAddr, password)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	account := accountKey(server, emailAddr)
+	drafts, oldUID, err := s.openDraft(c, account, draftID)
+	if err != nil {
+		return "", err
+	}
+
+	flags := []string{imap.DraftFlag, imap.SeenFlag}
+	if err := c.Append(drafts, flags, time.Now(), bytes.NewReader(raw)); err != nil {
+		return "", err
+	}
+
+	// Without UIDPLUS the APPEND doesn't say which UID it got, so look it up
+	// by the Message-ID we just generated
+	if _, err := s.selectMailbox(c, account, drafts, false); err != nil {
+		return "", err
+	}
+	criteria := imap.NewSearchCriteria()
+	criteria.Header.Add("Message-Id", messageID)
+	uids, err := c.UidSearch(criteria)
+	if err != nil {
+		return "", err
+	}
+	if len(uids) == 0 {
+		return "", fmt.Errorf("saved draft not found")
+	}
+	uid := uids[0]
+	for _, u := range uids[1:] {
+		if u > uid {
+			uid = u
+		}
+	}
+
+	if oldUID != 0 {
+		if err := removeDraft(c, oldUID); err != nil {
+			return "", err
+		}
+	}
+	return encodeEmailID(drafts, uid), nil
+}
+
+// DeleteDraft discards a draft for good, it doesn't go through Trash
+func (s *IMAPService) DeleteDraft(ctx context.Context, server string, port int, emailAddr, password, draftID string) error {
+	if draftID == "" {
+		return emaildomain.ErrDraftNotFound
+	}
+
+	c, release, err := s.acquire(server, port, emailAddr, password)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	_, uid, err := s.openDraft(c, accountKey(server, emailAddr), draftID)
+	if err != nil {
+		return err
+	}
+	return removeDraft(c, uid)
+}
+
+// SendDraft sends a draft as it was last saved, over SMTP, and removes it from the
+// Drafts folder. The stored Bcc header becomes envelope recipients and is dropped
+// from the message.
+func (s *IMAPService) SendDraft(ctx context.Context, server string, port int, emailAddr, password string, smtpCfg SMTPSettings, draftID string) error {
+	if draftID == "" {
+		return emaildomain.ErrDraftNotFound
+	}
+
+	c, release, err := s.acquire(server, port, emailAddr, password)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	_, uid, err := s.openDraft(c, accountKey(server, emailAddr), draftID)
+	if err != nil {
+		return err
+	}
+
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uid)
+	section := &imap.BodySectionName{Peek: true}
+	messages := make(chan *imap.Message, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.UidFetch(seqset, []imap.FetchItem{section.FetchItem()}, messages)
+	}()
+
+	var literal imap.Literal
+	for msg := range messages {
+		literal = msg.GetBody(section)
+	}
+	if err := <-done; err != nil {
+		return err
+	}
+	if literal == nil {
+		return emaildomain.ErrDraftNotFound
+	}
+
+	br := bufio.NewReader(literal)
+	header, err := textproto.ReadHeader(br)
+	if err != nil {
+		return fmt.Errorf("failed to parse draft: %w", err)
+	}
+	body, err := io.ReadAll(br)
+	if err != nil {
+		return err
+	}
+
+	rcpt := recipients(header.Get("To"), header.Get("Cc"), header.Get("Bcc"))
+	if len(rcpt) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+	header.Del("Bcc")
+	header.Set("Date", time.Now().Format(time.RFC1123Z))
+
+	var raw bytes.Buffer
+	if err := textproto.WriteHeader(&raw, header); err != nil {
+		return err
+	}
+	raw.Write(body)
+
+	if err := s.SendRawEmail(ctx, server, port, emailAddr, password, smtpCfg, rcpt, raw.Bytes()); err != nil {
+		return err
+	}
+	return removeDraft(c, uid)
+}
@@ -0,0 +1,136 @@
+package imap
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend"
+	"github.com/emersion/go-imap/backend/memory"
+	"github.com/emersion/go-imap/server"
+)
+
+// Tests log in to an in-memory IMAP server as testUser
+const (
+	testUser     = "username"
+	testPassword = "password"
+)
+
+// testServer is an in-memory IMAP server. It records the items every FETCH asks
+// for, so tests can tell what was downloaded.
+type testServer struct {
+	host  string
+	port  int
+	user  backend.User
+	inbox *memory.Mailbox
+
+	mu      sync.Mutex
+	fetches [][]imap.FetchItem
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	be := memory.New()
+	user, err := be.Login(nil, testUser, testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inbox, err := user.GetMailbox("INBOX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := &testServer{user: user, inbox: inbox.(*memory.Mailbox)}
+	ts.inbox.Messages = nil
+
+	srv := server.New(recordingBackend{Backend: be, ts: ts})
+	srv.AllowInsecureAuth = true
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(listener)
+	t.Cleanup(func() { srv.Close() })
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	ts.host = host
+	ts.port, _ = strconv.Atoi(port)
+	return ts
+}
+
+// addMessage appends a raw RFC 822 message to INBOX and returns its email ID
+func (ts *testServer) addMessage(raw string, internalDate time.Time, flags ...string) string {
+	uid := uint32(len(ts.inbox.Messages) + 1)
+	ts.inbox.Messages = append(ts.inbox.Messages, &memory.Message{
+		Uid:   uid,
+		Date:  internalDate,
+		Flags: flags,
+		Size:  uint32(len(raw)),
+		Body:  []byte(raw),
+	})
+	return encodeEmailID("INBOX", uid)
+}
+
+// createMailbox adds an empty folder next to INBOX
+func (ts *testServer) createMailbox(t *testing.T, name string) *memory.Mailbox {
+	t.Helper()
+	if err := ts.user.CreateMailbox(name); err != nil {
+		t.Fatal(err)
+	}
+	mailbox, err := ts.user.GetMailbox(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mailbox.(*memory.Mailbox)
+}
+
+// fetched returns every item requested by the FETCH commands so far
+func (ts *testServer) fetched() []imap.FetchItem {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	var items []imap.FetchItem
+	for _, fetch := range ts.fetches {
+		items = append(items, fetch...)
+	}
+	return items
+}
+
+type recordingBackend struct {
+	backend.Backend
+	ts *testServer
+}
+
+func (b recordingBackend) Login(info *imap.ConnInfo, username, password string) (backend.User, error) {
+	user, err := b.Backend.Login(info, username, password)
+	if err != nil {
+		return nil, err
+	}
+	return recordingUser{User: user, ts: b.ts}, nil
+}
+
+type recordingUser struct {
+	backend.User
+	ts *testServer
+}
+
+func (u recordingUser) GetMailbox(name string) (backend.Mailbox, error) {
+	mailbox, err := u.User.GetMailbox(name)
+	if err != nil {
+		return nil, err
+	}
+	return recordingMailbox{Mailbox: mailbox, ts: u.ts}, nil
+}
+
+type recordingMailbox struct {
+	backend.Mailbox
+	ts *testServer
+}
+
+func (m recordingMailbox) ListMessages(uid bool, seqSet *imap.SeqSet, items []imap.FetchItem, ch chan<- *imap.Message) error {
+	m.ts.mu.Lock()
+	m.ts.fetches = append(m.ts.fetches, items)
+	m.ts.mu.Unlock()
+	return m.Mailbox.ListMessages(uid, seqSet, items, ch)
+}
@@ -0,0 +1,112 @@
+package imap
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/emersion/go-imap/client"
+)
+
+const (
+	// Servers end IDLE after about 30 minutes, so it is restarted before that
+	idleRestartInterval = 25 * time.Minute
+	// Used when the server doesn't support IDLE
+	idlePollInterval = time.Minute
+
+	idleMinBackoff = 5 * time.Second
+	idleMaxBackoff = 5 * time.Minute
+)
+
+// StartIdle watches INBOX with IMAP IDLE and calls onNewMessage when new mail arrives.
+// Dropped connections are re-established with backoff. secret is asked for the password
+// on every connect, so a refreshed access token is picked up. It blocks until ctx is cancelled.
+func (s *IMAPService) StartIdle(ctx context.Context, server string, port int, email string, secret func() (string, error), onNewMessage func(mailbox string)) error {
+	backoff := idleMinBackoff
+	for {
+		started := time.Now()
+		password, err := secret()
+		if err == nil {
+			err = s.idleOnce(ctx, server, port, email, password, onNewMessage)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// A session that stayed up for a while was healthy, start over with a short wait
+		if time.Since(started) > idleMaxBackoff {
+			backoff = idleMinBackoff
+		}
+		log.Printf("IMAP IDLE for %s stopped: %v, reconnecting in %s", email, err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > idleMaxBackoff {
+			backoff = idleMaxBackoff
+		}
+	}
+}
+
+// idleOnce runs a single IDLE session on INBOX until the connection drops or ctx is cancelled
+func (s *IMAPService) idleOnce(ctx context.Context, server string, port int, email, password string, onNewMessage func(mailbox string)) error {
+	c, err := s.connect(server, port, email, password)
+	if err != nil {
+		return err
+	}
+	defer c.Logout()
+
+	mbox, err := c.Select("INBOX", true)
+	if err != nil {
+		return fmt.Errorf("failed to select INBOX: %w", err)
+	}
+	messages := mbox.Messages
+
+	// Updates must be drained or the client blocks
+	updates := make(chan client.Update, 10)
+	c.Updates = updates
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Idle(stop, &client.IdleOptions{
+			LogoutTimeout: idleRestartInterval,
+			PollInterval:  idlePollInterval,
+		})
+	}()
+
+	for {
+		select {
+		case update := <-updates:
+			mu, ok := update.(*client.MailboxUpdate)
+			if !ok || mu.Mailbox == nil {
+				continue
+			}
+			// EXISTS also follows expunges, only a higher count means new mail
+			if mu.Mailbox.Messages > messages {
+				onNewMessage(mu.Mailbox.Name)
+			}
+			messages = mu.Mailbox.Messages
+		case err := <-done:
+			if err == nil {
+				err = fmt.Errorf("IDLE ended by server")
+			}
+			return err
+		case <-c.LoggedOut():
+			return fmt.Errorf("connection closed by server")
+		case <-ctx.Done():
+			close(stop)
+			for {
+				select {
+				case <-updates:
+				case <-done:
+					return ctx.Err()
+				}
+			}
+		}
+	}
+}
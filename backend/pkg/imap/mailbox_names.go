@@ -0,0 +1,253 @@
+package imap
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// mailboxNamesTTL bounds how long a resolved folder mapping is trusted, so renamed
+// or newly created folders are picked up without a restart
+const mailboxNamesTTL = 10 * time.Minute
+
+// standardAttributes maps RFC 6154 special-use attributes to our standard mailbox IDs
+var standardAttributes = map[string]string{
+	"\\Sent":      "SENT",
+	"\\Trash":     "TRASH",
+	"\\Drafts":    "DRAFT",
+	"\\Junk":      "SPAM",
+	"\\Flagged":   "STARRED",
+	"\\Starred":   "STARRED",
+	"\\Important": "IMPORTANT",
+	"\\All":       "ALL",
+}
+
+// standardFolder describes one standard mailbox
+type standardFolder struct {
+	id       string
+	typ      string
+	hints    []string
+	fallback string
+}
+
+// inboxFolder is matched by name alone, INBOX being the one name every server shares
+var inboxFolder = standardFolder{id: "INBOX", typ: "inbox"}
+
+// standardFolders lists the standard mailbox IDs with the mailbox type shown for them,
+// the fallback name hints for servers without special-use attributes (matched against
+// the lowercased folder name) and the Gmail name to try when nothing matches. Adding
+// a standard folder only takes a line here.
+var standardFolders = []standardFolder{
+	{"SENT", "sent", []string{"sent", "thư đã gửi"}, ""},
+	{"TRASH", "trash", []string{"trash", "bin", "thùng rác"}, "[Gmail]/Trash"},
+	{"DRAFT", "drafts", []string{"draft", "thư nháp"}, ""},
+	{"SPAM", "spam", []string{"spam", "junk", "thư rác"}, "[Gmail]/Spam"},
+	{"STARRED", "starred", []string{"starred", "có gắn dấu sao"}, ""},
+	{"IMPORTANT", "important", []string{"important", "quan trọng"}, ""},
+	{"ALL", "all", []string{"all mail", "tất cả thư"}, "[Gmail]/All Mail"},
+}
+
+// moveTargets maps the mailbox types moveEmail accepts to standard mailbox IDs
+var moveTargets = map[string]string{
+	"trash":   "TRASH",
+	"archive": "ALL",
+	"spam":    "SPAM",
+	"inbox":   "INBOX",
+}
+
+type cachedMailboxNames struct {
+	names     map[string]string
+	expiresAt time.Time
+}
+
+// mailboxNameCache remembers, per account, which real folder backs each standard
+// mailbox ID, so resolving "SENT" doesn't cost a LIST on every request
+type mailboxNameCache struct {
+	mu       sync.Mutex
+	accounts map[string]*cachedMailboxNames
+}
+
+func newMailboxNameCache() *mailboxNameCache {
+	return &mailboxNameCache{accounts: make(map[string]*cachedMailboxNames)}
+}
+
+func accountKey(server, email string) string {
+	return strings.ToLower(server) + "|" + strings.ToLower(email)
+}
+
+func (m *mailboxNameCache) get(account string) map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.accounts[account]
+	if !ok || time.Now().After(entry.expiresAt) {
+		delete(m.accounts, account)
+		return nil
+	}
+	return entry.names
+}
+
+func (m *mailboxNameCache) store(account string, names map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accounts[account] = &cachedMailboxNames{names: names, expiresAt: time.Now().Add(mailboxNamesTTL)}
+}
+
+func (m *mailboxNameCache) invalidate(account string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.accounts, account)
+}
+
+// standardMailboxNames maps standard IDs to real folder names. A special-use attribute
+// wins over a name match, otherwise the first matching folder is used. INBOX maps to
+// the folder named INBOX in any case.
+func standardMailboxNames(mailboxes []*imap.MailboxInfo) map[string]string {
+	byAttribute := make(map[string]string)
+	byName := make(map[string]string)
+
+	for _, m := range mailboxes {
+		if strings.EqualFold(m.Name, inboxFolder.id) {
+			byAttribute[inboxFolder.id] = m.Name
+			continue
+		}
+
+		for _, attr := range m.Attributes {
+			if id, ok := standardAttributes[attr]; ok {
+				if _, seen := byAttribute[id]; !seen {
+					byAttribute[id] = m.Name
+				}
+			}
+		}
+
+		lowerName := strings.ToLower(m.Name)
+		for _, folder := range standardFolders {
+			if _, seen := byName[folder.id]; seen {
+				continue
+			}
+			for _, hint := range folder.hints {
+				if strings.Contains(lowerName, hint) {
+					byName[folder.id] = m.Name
+					break
+				}
+			}
+		}
+	}
+
+	for id, name := range byAttribute {
+		byName[id] = name
+	}
+	return byName
+}
+
+// standardTypes maps real folder names to the standard folder they back. A folder
+// backing several IDs takes the first in standardFolders order.
+func standardTypes(names map[string]string) map[string]standardFolder {
+	types := make(map[string]standardFolder, len(names))
+	if inbox, ok := names[inboxFolder.id]; ok {
+		types[inbox] = inboxFolder
+	}
+	for _, folder := range standardFolders {
+		name, ok := names[folder.id]
+		if !ok {
+			continue
+		}
+		if _, taken := types[name]; !taken {
+			types[name] = folder
+		}
+	}
+	return types
+}
+
+// listMailboxes runs LIST "" "*"
+func listMailboxes(c *client.Client) ([]*imap.MailboxInfo, error) {
+	mailboxes := make(chan *imap.MailboxInfo, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.List("", "*", mailboxes)
+	}()
+
+	var infos []*imap.MailboxInfo
+	for m := range mailboxes {
+		infos = append(infos, m)
+	}
+	if err := <-done; err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+func isSelectable(m *imap.MailboxInfo) bool {
+	for _, attr := range m.Attributes {
+		if attr == imap.NoSelectAttr {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveFolders returns the account's complete standard ID -> real folder name map,
+// listing the server's folders only when nothing fresh is cached
+func (s *IMAPService) resolveFolders(c *client.Client, account string) (map[string]string, error) {
+	if names := s.names.get(account); names != nil {
+		return names, nil
+	}
+
+	infos, err := listMailboxes(c)
+	if err != nil {
+		return nil, err
+	}
+	names := standardMailboxNames(infos)
+	s.names.store(account, names)
+	return names, nil
+}
+
+// resolveMailboxName maps a standard mailbox ID such as "SENT" to the account's real
+// folder name. Any other ID is already a real name. A standard ID without a folder
+// falls back to its Gmail name, or to the ID itself.
+func (s *IMAPService) resolveMailboxName(c *client.Client, account, mailboxID string) (string, error) {
+	fallback, standard := mailboxID, mailboxID == inboxFolder.id
+	for _, folder := range standardFolders {
+		if folder.id == mailboxID {
+			standard = true
+			if folder.fallback != "" {
+				fallback = folder.fallback
+			}
+		}
+	}
+	if !standard {
+		return mailboxID, nil
+	}
+
+	names, err := s.resolveFolders(c, account)
+	if err != nil {
+		return "", err
+	}
+	if name, ok := names[mailboxID]; ok {
+		return name, nil
+	}
+	return fallback, nil
+}
+
+// findMoveTarget resolves the real name of the trash, archive, spam or inbox mailbox
+func (s *IMAPService) findMoveTarget(c *client.Client, account, targetMailboxType string) (string, error) {
+	id, ok := moveTargets[targetMailboxType]
+	if !ok {
+		return "", fmt.Errorf("unknown move target: %s", targetMailboxType)
+	}
+	return s.resolveMailboxName(c, account, id)
+}
+
+// selectMailbox selects a folder and drops the account's cached mapping if that
+// fails, since the folder may have been renamed or removed
+func (s *IMAPService) selectMailbox(c *client.Client, account, name string, readOnly bool) (*imap.MailboxStatus, error) {
+	mbox, err := c.Select(name, readOnly)
+	if err != nil {
+		s.names.invalidate(account)
+		return nil, err
+	}
+	return mbox, nil
+}
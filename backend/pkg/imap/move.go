@@ -0,0 +1,77 @@
+package imap
+
+import (
+	"context"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// MoveEmail moves one message to the target mailbox with UID COPY, then flags the
+// original as deleted and expunges it. "TRASH" and "ALL" resolve the same way as
+// TrashEmail and ArchiveEmail.
+func (s *IMAPService) MoveEmail(ctx context.Context, server string, port int, emailAddr, password, messageID, targetMailboxID string) error {
+	mailboxName, uid, err := decodeEmailID(messageID)
+	if err != nil {
+		return err
+	}
+
+	c, release, err := s.acquire(server, port, emailAddr, password)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	account := accountKey(server, emailAddr)
+	target, err := s.resolveMailboxName(c, account, targetMailboxID)
+	if err != nil {
+		return err
+	}
+	if target == mailboxName {
+		return nil
+	}
+
+	if _, err := s.selectMailbox(c, account, mailboxName, false); err != nil {
+		return err
+	}
+	// COPY silently skips a UID that is gone
+	batch := &mailboxBatch{mailbox: mailboxName, ids: map[uint32][]string{uid: {messageID}}}
+	results := make(map[string]error, 1)
+	if err := keepExisting(c, batch, results); err != nil {
+		return err
+	}
+	if err := results[messageID]; err != nil {
+		return err
+	}
+
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uid)
+	if err := c.UidCopy(seqset, target); err != nil {
+		// The cached target may be stale
+		s.names.invalidate(account)
+		return err
+	}
+	item := imap.FormatFlagsOp(imap.AddFlags, true)
+	if err := c.UidStore(seqset, item, []interface{}{imap.DeletedFlag}, nil); err != nil {
+		return err
+	}
+	return expungeUID(c, seqset)
+}
+
+// expungeUID removes the given \Deleted messages from the selected mailbox. Without
+// UIDPLUS it falls back to EXPUNGE, which also removes any other message already
+// flagged \Deleted there.
+func expungeUID(c *client.Client, seqset *imap.SeqSet) error {
+	if ok, _ := c.Support("UIDPLUS"); !ok {
+		return c.Expunge(nil)
+	}
+	cmd := &imap.Command{
+		Name:      "UID",
+		Arguments: []interface{}{imap.RawString("EXPUNGE"), seqset},
+	}
+	status, err := c.Execute(cmd, nil)
+	if err != nil {
+		return err
+	}
+	return status.Err()
+}
@@ -0,0 +1,132 @@
+package imap
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+const (
+	maxIdleConnsPerAccount = 4
+	// Servers drop idle sessions after 30 minutes at the earliest (RFC 3501), stay well below
+	connIdleTimeout = 5 * time.Minute
+)
+
+type idleConn struct {
+	c     *client.Client
+	since time.Time
+}
+
+// connPool keeps logged-in clients for reuse. A client is handed to one caller at a
+// time: it leaves the pool on take and only comes back on put, so two goroutines
+// never share a connection or its selected mailbox.
+type connPool struct {
+	mu   sync.Mutex
+	idle map[string][]*idleConn
+}
+
+func newConnPool() *connPool {
+	return &connPool{idle: make(map[string][]*idleConn)}
+}
+
+// poolKey identifies the login a connection was made with. The password is part of it
+// so that only a caller holding the right credentials gets an authenticated client.
+func poolKey(server string, port int, email, password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return fmt.Sprintf("%s:%d|%s|%s", strings.ToLower(server), port, strings.ToLower(email), hex.EncodeToString(sum[:]))
+}
+
+// take returns the most recently used idle client for key, or nil
+func (p *connPool) take(key string) *client.Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.evictExpired()
+
+	conns := p.idle[key]
+	if len(conns) == 0 {
+		return nil
+	}
+	last := conns[len(conns)-1]
+	if len(conns) == 1 {
+		delete(p.idle, key)
+	} else {
+		p.idle[key] = conns[:len(conns)-1]
+	}
+	return last.c
+}
+
+// put returns a client to the pool, or logs it out if it's unusable or the pool is full
+func (p *connPool) put(key string, c *client.Client) {
+	state := c.State()
+	if state != imap.AuthenticatedState && state != imap.SelectedState {
+		c.Terminate()
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.evictExpired()
+
+	if len(p.idle[key]) >= maxIdleConnsPerAccount {
+		go c.Logout()
+		return
+	}
+	p.idle[key] = append(p.idle[key], &idleConn{c: c, since: time.Now()})
+}
+
+// evictExpired logs out clients idle for longer than connIdleTimeout. Callers hold p.mu.
+func (p *connPool) evictExpired() {
+	cutoff := time.Now().Add(-connIdleTimeout)
+	for key, conns := range p.idle {
+		kept := conns[:0]
+		for _, conn := range conns {
+			if conn.since.Before(cutoff) {
+				go conn.c.Logout()
+				continue
+			}
+			kept = append(kept, conn)
+		}
+		if len(kept) == 0 {
+			delete(p.idle, key)
+		} else {
+			p.idle[key] = kept
+		}
+	}
+}
+
+// acquire returns a logged-in client for the account, reusing a pooled one when it
+// still answers NOOP. The returned release func must be called exactly once when the
+// caller is done; it hands the client back to the pool instead of logging out.
+func (s *IMAPService) acquire(server string, port int, email, password string) (*client.Client, func(), error) {
+	key := poolKey(server, port, email, password)
+
+	for {
+		c := s.pool.take(key)
+		if c == nil {
+			break
+		}
+		if err := c.Noop(); err == nil {
+			return c, s.releaser(key, c), nil
+		}
+		c.Terminate()
+	}
+
+	c, err := s.connect(server, port, email, password)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, s.releaser(key, c), nil
+}
+
+func (s *IMAPService) releaser(key string, c *client.Client) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() { s.pool.put(key, c) })
+	}
+}
@@ -0,0 +1,231 @@
+package imap
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"github.com/emersion/go-imap"
+)
+
+// searchDateLayouts are the date formats accepted by after:/before:, Gmail's first
+var searchDateLayouts = []string{"2006/01/02", "2006-01-02", "2006/1/2"}
+
+// ParseSearchQuery maps a Gmail-style query such as `from:alice subject:"q3 invoice" is:unread`
+// onto IMAP SEARCH criteria. Supported operators are from:, to:, cc:, subject:, body:,
+// is:unread/read/starred/unstarred and after:/since:/before:; other words match anywhere
+// in the headers or body.
+func ParseSearchQuery(query string) *imap.SearchCriteria {
+	criteria := imap.NewSearchCriteria()
+
+	for _, term := range splitSearchTerms(query) {
+		key, value, ok := strings.Cut(term, ":")
+		if !ok || value == "" {
+			criteria.Text = append(criteria.Text, unquote(term))
+			continue
+		}
+		value = unquote(value)
+
+		switch strings.ToLower(key) {
+		case "from":
+			criteria.Header.Add("From", value)
+		case "to":
+			criteria.Header.Add("To", value)
+		case "cc":
+			criteria.Header.Add("Cc", value)
+		case "subject":
+			criteria.Header.Add("Subject", value)
+		case "body":
+			criteria.Body = append(criteria.Body, value)
+		case "is":
+			switch strings.ToLower(value) {
+			case "unread":
+				criteria.WithoutFlags = append(criteria.WithoutFlags, imap.SeenFlag)
+			case "read":
+				criteria.WithFlags = append(criteria.WithFlags, imap.SeenFlag)
+			case "starred":
+				criteria.WithFlags = append(criteria.WithFlags, imap.FlaggedFlag)
+			case "unstarred":
+				criteria.WithoutFlags = append(criteria.WithoutFlags, imap.FlaggedFlag)
+			}
+		case "after", "since":
+			if date, ok := parseSearchDate(value); ok {
+				criteria.Since = date
+			}
+		case "before":
+			if date, ok := parseSearchDate(value); ok {
+				criteria.Before = date
+			}
+		default:
+			// Not an operator we know, e.g. a URL or a time like 10:30
+			criteria.Text = append(criteria.Text, unquote(term))
+		}
+	}
+	return criteria
+}
+
+// splitSearchTerms splits on spaces, keeping double-quoted phrases together
+func splitSearchTerms(query string) []string {
+	var terms []string
+	var current strings.Builder
+	inQuotes := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			current.WriteRune(r)
+		case r == ' ' && !inQuotes:
+			if current.Len() > 0 {
+				terms = append(terms, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		terms = append(terms, current.String())
+	}
+	return terms
+}
+
+func unquote(value string) string {
+	return strings.Trim(value, `"`)
+}
+
+func parseSearchDate(value string) (time.Time, bool) {
+	for _, layout := range searchDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// SearchEmails runs criteria against a mailbox and returns one page of matches, newest
+// first, along with the total number of matches
+func (s *IMAPService) SearchEmails(ctx context.Context, server string, port int, emailAddr, password, mailboxID string, criteria *imap.SearchCriteria, limit, offset int) ([]*emaildomain.Email, int, error) {
+	c, release, err := s.acquire(server, port, emailAddr, password)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer release()
+
+	account := accountKey(server, emailAddr)
+	realMailboxName, err := s.resolveMailboxName(c, account, mailboxID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if _, err := s.selectMailbox(c, account, realMailboxName, true); err != nil {
+		return nil, 0, err
+	}
+
+	uids, err := c.UidSearch(criteria)
+	if err != nil {
+		return nil, 0, err
+	}
+	total := len(uids)
+
+	// UIDs grow with arrival, so the highest ones are the newest
+	sort.Slice(uids, func(i, j int) bool { return uids[i] > uids[j] })
+	if offset >= total {
+		return []*emaildomain.Email{}, total, nil
+	}
+	uids = uids[offset:]
+	if len(uids) > limit {
+		uids = uids[:limit]
+	}
+
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uids...)
+
+	messages := make(chan *imap.Message, len(uids))
+	done := make(chan error, 1)
+
+	section := &imap.BodySectionName{Peek: true}
+	items := []imap.FetchItem{imap.FetchEnvelope, imap.FetchFlags, imap.FetchInternalDate, imap.FetchUid, section.FetchItem()}
+
+	go func() {
+		done <- c.UidFetch(seqset, items, messages)
+	}()
+
+	byUID := make(map[uint32]*emaildomain.Email, len(uids))
+	for msg := range messages {
+		byUID[msg.Uid] = s.messageToEmail(msg, section, realMailboxName, mailboxID)
+	}
+	if err := <-done; err != nil {
+		return nil, 0, err
+	}
+
+	result := make([]*emaildomain.Email, 0, len(uids))
+	for _, uid := range uids {
+		if email, ok := byUID[uid]; ok {
+			result = append(result, email)
+		}
+	}
+	return result, total, nil
+}
+
+// FindByMessageID returns the current "Mailbox:UID" email ID of the message with the given
+// Message-ID header. INBOX and All Mail are tried first, then every other folder.
+func (s *IMAPService) FindByMessageID(ctx context.Context, server string, port int, emailAddr, password, messageID string) (string, error) {
+	c, release, err := s.acquire(server, port, emailAddr, password)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	account := accountKey(server, emailAddr)
+	names, err := s.resolveFolders(c, account)
+	if err != nil {
+		return "", err
+	}
+
+	criteria := imap.NewSearchCriteria()
+	criteria.Header.Add("Message-ID", messageID)
+	find := func(mailbox string) (string, bool) {
+		if _, err := c.Select(mailbox, true); err != nil {
+			return "", false
+		}
+		uids, err := c.UidSearch(criteria)
+		if err != nil || len(uids) == 0 {
+			return "", false
+		}
+		return base64.URLEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%d", mailbox, uids[0]))), true
+	}
+
+	tried := map[string]bool{"INBOX": true}
+	if id, ok := find("INBOX"); ok {
+		return id, nil
+	}
+	if all, ok := names["ALL"]; ok {
+		tried[all] = true
+		if id, ok := find(all); ok {
+			return id, nil
+		}
+	}
+
+	infos, err := listMailboxes(c)
+	if err != nil {
+		return "", err
+	}
+	var rest []string
+	for _, m := range infos {
+		if isSelectable(m) && !tried[m.Name] {
+			rest = append(rest, m.Name)
+		}
+	}
+
+	for _, mailbox := range rest {
+		if id, ok := find(mailbox); ok {
+			return id, nil
+		}
+	}
+	return "", emaildomain.ErrEmailNotFound
+}
@@ -0,0 +1,65 @@
+package imap
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/pkg/utils/emailid"
+
+	"github.com/emersion/go-imap/backend/memory"
+)
+
+func TestStableIDSurvivesMove(t *testing.T) {
+	ts := newTestServer(t)
+	ts.addMessage(inviteMessage, time.Now())
+	id := ts.addMessage(plainMessage, time.Now())
+	s := NewService()
+	ctx := context.Background()
+
+	email, err := s.GetEmailByID(ctx, ts.host, ts.port, testUser, testPassword, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider, messageID, ok := emailid.Parse(email.StableID)
+	if !ok || provider != emailid.ProviderIMAP || messageID != "<lunch@example.com>" {
+		t.Fatalf("StableID %q parses as %q, %q, %v, want the Message-ID", email.StableID, provider, messageID, ok)
+	}
+	if got, err := s.FindByMessageID(ctx, ts.host, ts.port, testUser, testPassword, messageID); err != nil || got != id {
+		t.Errorf("FindByMessageID() = %q, %v, want %q", got, err, id)
+	}
+
+	// Move the message to another folder, which changes its Mailbox:UID ID
+	projects := ts.createMailbox(t, "Projects")
+	moved := ts.inbox.Messages[1]
+	ts.inbox.Messages = ts.inbox.Messages[:1]
+	moved.Uid = 7
+	projects.Messages = []*memory.Message{moved}
+
+	newID, err := s.FindByMessageID(ctx, ts.host, ts.port, testUser, testPassword, messageID)
+	if err != nil {
+		t.Fatalf("FindByMessageID() after the move error = %v", err)
+	}
+	if newID != encodeEmailID("Projects", 7) {
+		t.Errorf("FindByMessageID() = %q, want the message's ID in Projects", newID)
+	}
+	email, err = s.GetEmailByID(ctx, ts.host, ts.port, testUser, testPassword, newID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email.StableID != emailid.New(emailid.ProviderIMAP, messageID) {
+		t.Errorf("StableID changed to %q after the move", email.StableID)
+	}
+}
+
+func TestFindByMessageIDUnknown(t *testing.T) {
+	ts := newTestServer(t)
+	ts.addMessage(plainMessage, time.Now())
+
+	_, err := NewService().FindByMessageID(context.Background(), ts.host, ts.port, testUser, testPassword, "<nobody@example.com>")
+	if !errors.Is(err, emaildomain.ErrEmailNotFound) {
+		t.Errorf("FindByMessageID() error = %v, want ErrEmailNotFound", err)
+	}
+}
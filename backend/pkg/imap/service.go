@@ -1,261 +1,138 @@
 package imap
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/base64"
 	"fmt"
 	"io"
-	"net/smtp"
+	"mime"
+	"mime/multipart"
+	"path/filepath"
 	"strings"
+	"time"
 
 	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/pkg/utils/emailid"
+	"ga03-backend/pkg/utils/ical"
+	"ga03-backend/pkg/utils/mailutil"
 
 	"github.com/emersion/go-imap"
 	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-message"
 	"github.com/emersion/go-message/mail"
+	"github.com/emersion/go-message/textproto"
 )
 
-type IMAPService struct{}
+type IMAPService struct {
+	names *mailboxNameCache
+	pool  *connPool
+}
 
 func NewService() *IMAPService {
-	return &IMAPService{}
+	return &IMAPService{names: newMailboxNameCache(), pool: newConnPool()}
 }
 
-// Helper to connect
+// connect opens a dedicated connection; regular operations go through acquire.
+// password may be an OAuthSecret.
 func (s *IMAPService) connect(server string, port int, email, password string) (*client.Client, error) {
-	return ConnectAndLogin(server, port, email, password)
+	password, accessToken := splitSecret(password)
+	return ConnectAndLogin(server, port, email, password, accessToken)
 }
 
-func (s *IMAPService) GetMailboxes(ctx context.Context, server string, port int, email, password string) ([]*emaildomain.Mailbox, error) {
+// ValidateCredentials checks that the server is reachable and the credentials are accepted
+func (s *IMAPService) ValidateCredentials(ctx context.Context, server string, port int, email, password string) error {
 	c, err := s.connect(server, port, email, password)
+	if err != nil {
+		return err
+	}
+	return c.Logout()
+}
+
+func (s *IMAPService) GetMailboxes(ctx context.Context, server string, port int, email, password string) ([]*emaildomain.Mailbox, error) {
+	c, release, err := s.acquire(server, port, email, password)
 	if err != nil {
 		return nil, err
 	}
-	defer c.Logout()
+	defer release()
 
-	mailboxes := make(chan *imap.MailboxInfo, 10)
-	done := make(chan error, 1)
-	go func() {
-		done <- c.List("", "*", mailboxes)
-	}()
+	infos, err := listMailboxes(c)
+	if err != nil {
+		return nil, err
+	}
+	// This listing is as good as the one resolveFolders would make, keep it
+	names := standardMailboxNames(infos)
+	s.names.store(accountKey(server, email), names)
+	types := standardTypes(names)
 
 	var result []*emaildomain.Mailbox
-	for m := range mailboxes {
+	for _, m := range infos {
 		// Skip [Gmail] root folder or folders that cannot be selected
-		isNoSelect := false
-		for _, attr := range m.Attributes {
-			if attr == "\\Noselect" {
-				isNoSelect = true
-				break
-			}
-		}
-		if isNoSelect || m.Name == "[Gmail]" {
+		if !isSelectable(m) || m.Name == "[Gmail]" {
 			continue
 		}
 
-		// Map IMAP attributes to our domain
-		id := m.Name
-		name := m.Name
-		type_ := "user" // Default to user folder
-
-		// Check attributes for standard folders (RFC 6154)
-		for _, attr := range m.Attributes {
-			switch attr {
-			case "\\Sent":
-				type_ = "sent"
-				id = "SENT"
-			case "\\Trash":
-				type_ = "trash"
-				id = "TRASH"
-			case "\\Drafts":
-				type_ = "drafts"
-				id = "DRAFT"
-			case "\\Junk":
-				type_ = "spam"
-				id = "SPAM"
-			case "\\Flagged", "\\Starred": // Some servers use \Starred
-				type_ = "starred"
-				id = "STARRED"
-			case "\\Important":
-				type_ = "important"
-				id = "IMPORTANT"
-			case "\\All":
-				type_ = "all"
-				id = "ALL"
-			}
+		// Standard folders get the same IDs Gmail uses ("SENT", "TRASH"...), which
+		// resolveMailboxName maps back to the real name
+		mailbox := &emaildomain.Mailbox{
+			ID:        m.Name,
+			Name:      m.Name,
+			Type:      "user",
+			Delimiter: m.Delimiter,
 		}
-
-		// Fallback to name matching if attributes are missing (common in some servers)
-		if type_ == "user" {
-			lowerName := strings.ToLower(name)
-			if lowerName == "inbox" {
-				type_ = "inbox"
-				id = "INBOX"
-			} else if strings.Contains(lowerName, "sent") || strings.Contains(lowerName, "thư đã gửi") {
-				type_ = "sent"
-				id = "SENT"
-			} else if strings.Contains(lowerName, "trash") || strings.Contains(lowerName, "bin") || strings.Contains(lowerName, "thùng rác") {
-				type_ = "trash"
-				id = "TRASH"
-			} else if strings.Contains(lowerName, "draft") || strings.Contains(lowerName, "thư nháp") {
-				type_ = "drafts"
-				id = "DRAFT"
-			} else if strings.Contains(lowerName, "spam") || strings.Contains(lowerName, "junk") || strings.Contains(lowerName, "thư rác") {
-				type_ = "spam"
-				id = "SPAM"
-			} else if strings.Contains(lowerName, "starred") || strings.Contains(lowerName, "có gắn dấu sao") {
-				type_ = "starred"
-				id = "STARRED"
-			} else if strings.Contains(lowerName, "important") || strings.Contains(lowerName, "quan trọng") {
-				type_ = "important"
-				id = "IMPORTANT"
-			}
+		if folder, ok := types[m.Name]; ok {
+			mailbox.ID, mailbox.Type = folder.id, folder.typ
 		}
-		
-		// If ID was normalized to a standard ID, we still need the original name to Select the mailbox later.
-		// But wait, if we change the ID returned to frontend, the frontend will send back "SENT".
-		// We need to map "SENT" back to "[Gmail]/Sent Mail" (or whatever the real name is) when fetching emails.
-		// This requires state or a lookup. Since we don't have persistent state for mailbox mapping,
-		// we can't easily do this without querying the list again or encoding the real name in the ID.
-		
-		// Alternative: Use the real name as ID, but ensure it's URL safe?
-		// The user wants the structure to match Google OAuth.
-		// Google OAuth returns ID="SENT", Name="SENT".
-		// If we return ID="SENT", we MUST be able to fetch emails using ID="SENT".
-		
-		// Solution: When fetching emails, if the ID is a standard one (SENT, TRASH, etc.), 
-		// we need to find the corresponding real mailbox name.
-		// We can do this by listing mailboxes again and finding the one with the matching attribute/name.
-		// This adds overhead but ensures correctness and compatibility.
-		
-		// For now, let's keep the ID as the real name for non-standard folders, 
-		// but for standard ones, we might need a way to handle the mapping.
-		
-		// Actually, simpler approach for MVP:
-		// Return the real name as ID, but set the TYPE correctly.
-		// The frontend likely uses the TYPE to display icons/names.
-		// The user's complaint is about the ID structure too?
-		// "Với Outh2 ... id: SENT ... Với imap ... id: [Gmail]/Thư đã gửi"
-		// The frontend probably relies on ID="SENT" to filter or route.
-		
-		// Let's try to map standard IDs.
-		// We will need to handle the reverse mapping in GetEmails.
-
-		// Get mailbox status (Unread count)
-		var count int
-		status, err := c.Status(m.Name, []imap.StatusItem{imap.StatusUnseen})
-		if err == nil {
-			count = int(status.Unseen)
-		}
-		
-		result = append(result, &emaildomain.Mailbox{
-			ID:    id, // Normalized ID if standard, else real name
-			Name:  name,
-			Type:  type_,
-			Count: count,
-		})
+		result = append(result, mailbox)
 	}
 
-	if err := <-done; err != nil {
-		return nil, err
+	// Unread counts, a Count stays 0 when its STATUS fails
+	mailboxNames := make([]string, len(result))
+	for i, mailbox := range result {
+		mailboxNames[i] = mailbox.Name
+	}
+	for i, count := range s.unseenCounts(c, server, port, email, password, mailboxNames) {
+		result[i].Count = count
 	}
 	return result, nil
 }
 
-func (s *IMAPService) resolveMailboxName(c *client.Client, mailboxID string) (string, error) {
-	// If mailboxID is a standard ID, we need to find the real name
-	// If it's not one of our standard IDs, assume it's the real name
-	
-	standardIDs := map[string]bool{
-		"INBOX": true, "SENT": true, "TRASH": true, "DRAFT": true, "SPAM": true, "STARRED": true, "IMPORTANT": true, "ALL": true,
-	}
-	
-	if !standardIDs[mailboxID] {
-		return mailboxID, nil
-	}
-	
-	if mailboxID == "INBOX" {
-		return "INBOX", nil
-	}
-
-	// List all mailboxes to find the match
-	mailboxes := make(chan *imap.MailboxInfo, 10)
-	done := make(chan error, 1)
-	go func() {
-		done <- c.List("", "*", mailboxes)
-	}()
-
-	var realName string
-	found := false
-
-	for m := range mailboxes {
-		// Check attributes first
-		for _, attr := range m.Attributes {
-			switch attr {
-			case "\\Sent":
-				if mailboxID == "SENT" { realName = m.Name; found = true }
-			case "\\Trash":
-				if mailboxID == "TRASH" { realName = m.Name; found = true }
-			case "\\Drafts":
-				if mailboxID == "DRAFT" { realName = m.Name; found = true }
-			case "\\Junk":
-				if mailboxID == "SPAM" { realName = m.Name; found = true }
-			case "\\Flagged":
-				if mailboxID == "STARRED" { realName = m.Name; found = true }
-			case "\\Important":
-				if mailboxID == "IMPORTANT" { realName = m.Name; found = true }
-			case "\\All":
-				if mailboxID == "ALL" { realName = m.Name; found = true }
-			}
-		}
-		
-		if found {
-			continue // Drain channel
-		}
+// parsedMessage holds the parts of a raw message that we surface on an Email
+type parsedMessage struct {
+	Body     string
+	TextBody string
+	IsHTML   bool
+	Header   mail.Header
+	Calendar []byte // Raw text/calendar part or .ics attachment, if any
 
-		// Fallback to name matching
-		lowerName := strings.ToLower(m.Name)
-		if mailboxID == "SENT" && (strings.Contains(lowerName, "sent") || strings.Contains(lowerName, "thư đã gửi")) {
-			realName = m.Name; found = true
-		} else if mailboxID == "TRASH" && (strings.Contains(lowerName, "trash") || strings.Contains(lowerName, "bin") || strings.Contains(lowerName, "thùng rác")) {
-			realName = m.Name; found = true
-		} else if mailboxID == "DRAFT" && (strings.Contains(lowerName, "draft") || strings.Contains(lowerName, "thư nháp")) {
-			realName = m.Name; found = true
-		} else if mailboxID == "SPAM" && (strings.Contains(lowerName, "spam") || strings.Contains(lowerName, "junk") || strings.Contains(lowerName, "thư rác")) {
-			realName = m.Name; found = true
-		} else if mailboxID == "STARRED" && (strings.Contains(lowerName, "starred") || strings.Contains(lowerName, "có gắn dấu sao")) {
-			realName = m.Name; found = true
-		} else if mailboxID == "IMPORTANT" && (strings.Contains(lowerName, "important") || strings.Contains(lowerName, "quan trọng")) {
-			realName = m.Name; found = true
-		}
-	}
-
-	if err := <-done; err != nil {
-		return "", err
-	}
-
-	if found {
-		return realName, nil
-	}
-	
-	// If not found, maybe the ID is the name itself (fallback)
-	return mailboxID, nil
+	Attachments []emaildomain.Attachment
 }
 
-func (s *IMAPService) parseBody(r io.Reader) (string, string, bool) {
+func (s *IMAPService) parseBody(r io.Reader) *parsedMessage {
+	result := &parsedMessage{}
+
+	// go-message decodes the transfer encoding and converts the charset to UTF-8. A
+	// charset it doesn't know is reported alongside a still usable reader.
 	mr, err := mail.CreateReader(r)
-	if err != nil {
-		return "", "", false
+	if err != nil && !message.IsUnknownCharset(err) {
+		return result
 	}
+	result.Header = mr.Header
 
 	var htmlBody, textBody string
+	var dispositions []string // Content-Disposition of each entry in result.Attachments
 
-	for {
+	for index := 0; ; index++ {
 		p, err := mr.NextPart()
 		if err == io.EOF {
 			break
 		}
-		if err != nil {
+		if message.IsUnknownEncoding(err) {
+			// Only this part is unreadable, the ones after it may still be fine
+			continue
+		}
+		if err != nil && !message.IsUnknownCharset(err) {
 			break
 		}
 
@@ -267,30 +144,171 @@ func (s *IMAPService) parseBody(r io.Reader) (string, string, bool) {
 				htmlBody = string(b)
 			} else if ct == "text/plain" {
 				textBody = string(b)
+			} else if isCalendarPart(ct, "") && result.Calendar == nil {
+				result.Calendar = b
+			} else if contentID := h.Get("Content-ID"); contentID != "" {
+				// Embedded content such as images referenced from the HTML body
+				_, params, _ := h.ContentDisposition()
+				result.Attachments = append(result.Attachments, emaildomain.Attachment{
+					ID:        fmt.Sprintf("%d", index),
+					Name:      params["filename"],
+					Size:      int64(len(b)),
+					MimeType:  ct,
+					ContentID: strings.Trim(contentID, "<>"),
+				})
+				dispositions = append(dispositions, h.Get("Content-Disposition"))
+			}
+		case *mail.AttachmentHeader:
+			ct, _, _ := h.ContentType()
+			filename, _ := h.Filename()
+			b, _ := io.ReadAll(p.Body)
+			if isCalendarPart(ct, filename) && result.Calendar == nil {
+				result.Calendar = b
 			}
+			result.Attachments = append(result.Attachments, emaildomain.Attachment{
+				ID:        fmt.Sprintf("%d", index),
+				Name:      filename,
+				Size:      int64(len(b)),
+				MimeType:  ct,
+				ContentID: strings.Trim(h.Get("Content-ID"), "<>"),
+			})
+			dispositions = append(dispositions, h.Get("Content-Disposition"))
 		}
 	}
 
+	// Classify once the HTML body is known, parts can come before the body that references them
+	for i := range result.Attachments {
+		result.Attachments[i].IsInline = mailutil.IsInlinePart(dispositions[i], result.Attachments[i].ContentID, htmlBody)
+	}
+
+	result.TextBody = textBody
 	if htmlBody != "" {
-		return htmlBody, textBody, true
+		result.Body = mailutil.SanitizeHTML(htmlBody)
+		result.IsHTML = true
+	} else {
+		result.Body = textBody
 	}
-	return textBody, textBody, false
+	return result
+}
+
+// resolveReceivedAt returns the message date normalized to UTC. Malformed messages can
+// have a zero envelope date, in which case the Date header and then INTERNALDATE are used.
+func resolveReceivedAt(msg *imap.Message, header mail.Header) time.Time {
+	if msg.Envelope != nil && !msg.Envelope.Date.IsZero() {
+		return msg.Envelope.Date.UTC()
+	}
+	if date, err := header.Date(); err == nil && !date.IsZero() {
+		return date.UTC()
+	}
+	return msg.InternalDate.UTC()
+}
+
+func isCalendarPart(contentType, filename string) bool {
+	return contentType == "text/calendar" || contentType == "application/ics" ||
+		strings.HasSuffix(strings.ToLower(filename), ".ics")
+}
+
+// applyHeaderInfo copies header-derived fields (e.g. List-Unsubscribe) onto the email
+func applyHeaderInfo(email *emaildomain.Email, header mail.Header) {
+	email.Priority = mailutil.ParsePriority(header.Get)
+	if references := header.Get("References"); references != "" {
+		email.References = references
+	}
+	email.UnsubscribeURL, email.UnsubscribeMailto, email.UnsubscribeOneClick = mailutil.ParseListUnsubscribe(
+		header.Get("List-Unsubscribe"),
+		header.Get("List-Unsubscribe-Post"),
+	)
+}
+
+// applyCalendarInvite parses a calendar part found while reading the body
+func applyCalendarInvite(email *emaildomain.Email, parsed *parsedMessage) {
+	if parsed == nil || parsed.Calendar == nil {
+		return
+	}
+	email.IsInvite = true
+	if event, err := ical.Parse(parsed.Calendar); err == nil {
+		email.Invite = event
+	}
+}
+
+// messageToEmail converts a message fetched with an envelope, flags and the full body section
+func (s *IMAPService) messageToEmail(msg *imap.Message, section *imap.BodySectionName, realMailboxName, mailboxID string) *emaildomain.Email {
+	// Parse email
+	subject := msg.Envelope.Subject
+	from := ""
+	if len(msg.Envelope.From) > 0 {
+		from = formatEnvelopeAddress(msg.Envelope.From[0])
+	}
+	
+	to := []string{}
+	for _, addr := range msg.Envelope.To {
+		to = append(to, formatEnvelopeAddress(addr))
+	}
+	
+	body := ""
+	snippet := ""
+	isHTML := false
+	var header mail.Header
+	var parsed *parsedMessage
+	
+	r := msg.GetBody(section)
+	if r != nil {
+		parsed = s.parseBody(r)
+		body, isHTML, header = parsed.Body, parsed.IsHTML, parsed.Header
+		snippet = mailutil.Truncate(parsed.TextBody, 100)
+	}
+
+	isRead := false
+	isStarred := false
+	for _, f := range msg.Flags {
+		if f == imap.SeenFlag {
+			isRead = true
+		}
+		if f == imap.FlaggedFlag {
+			isStarred = true
+		}
+	}
+
+	email := &emaildomain.Email{
+		ID:         base64.URLEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%d", realMailboxName, msg.Uid))), // Encode Mailbox:UID
+		StableID:   emailid.New(emailid.ProviderIMAP, msg.Envelope.MessageId),
+		MessageID:  msg.Envelope.MessageId,
+		Subject:    subject,
+		From:       from,
+		To:         to,
+		Preview:    snippet,
+		Body:       body,
+		IsHTML:     isHTML,
+		ReceivedAt: resolveReceivedAt(msg, header),
+		IsRead:     isRead,
+		IsStarred:  isStarred,
+		MailboxID:  mailboxID,
+	}
+	email.IsImportant = hasFlag(msg.Flags, importantFlag)
+	applyHeaderInfo(email, header)
+	applyThreadID(email, realMailboxName, header)
+	applyCalendarInvite(email, parsed)
+	if parsed != nil {
+		email.Attachments = parsed.Attachments
+	}
+	return email
 }
 
 func (s *IMAPService) GetEmails(ctx context.Context, server string, port int, emailAddr, password, mailboxID string, limit, offset int) ([]*emaildomain.Email, int, error) {
-	c, err := s.connect(server, port, emailAddr, password)
+	c, release, err := s.acquire(server, port, emailAddr, password)
 	if err != nil {
 		return nil, 0, err
 	}
-	defer c.Logout()
+	defer release()
 
 	// Resolve real mailbox name from ID
-	realMailboxName, err := s.resolveMailboxName(c, mailboxID)
+	account := accountKey(server, emailAddr)
+	realMailboxName, err := s.resolveMailboxName(c, account, mailboxID)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	mbox, err := c.Select(realMailboxName, true)
+	mbox, err := s.selectMailbox(c, account, realMailboxName, true)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -329,57 +347,8 @@ func (s *IMAPService) GetEmails(ctx context.Context, server string, port int, em
 
 	var result []*emaildomain.Email
 	for msg := range messages {
-		// Parse email
-		subject := msg.Envelope.Subject
-		from := ""
-		if len(msg.Envelope.From) > 0 {
-			from = fmt.Sprintf("%s <%s@%s>", msg.Envelope.From[0].PersonalName, msg.Envelope.From[0].MailboxName, msg.Envelope.From[0].HostName)
-		}
-		
-		to := []string{}
-		for _, addr := range msg.Envelope.To {
-			to = append(to, fmt.Sprintf("%s <%s@%s>", addr.PersonalName, addr.MailboxName, addr.HostName))
-		}
-		
-		body := ""
-		snippet := ""
-		isHTML := false
-		
-		r := msg.GetBody(section)
-		if r != nil {
-			var textBody string
-			body, textBody, isHTML = s.parseBody(r)
-			if len(textBody) > 100 {
-				snippet = textBody[:100] + "..."
-			} else {
-				snippet = textBody
-			}
-		}
-
-		isRead := false
-		isStarred := false
-		for _, f := range msg.Flags {
-			if f == imap.SeenFlag {
-				isRead = true
-			}
-			if f == imap.FlaggedFlag {
-				isStarred = true
-			}
-		}
-
-		result = append(result, &emaildomain.Email{
-			ID:         base64.URLEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%d", realMailboxName, msg.Uid))), // Encode Mailbox:UID
-			Subject:    subject,
-			From:       from,
-			To:         to,
-			Preview:    snippet,
-			Body:       body,
-			IsHTML:     isHTML,
-			ReceivedAt: msg.Envelope.Date,
-			IsRead:     isRead,
-			IsStarred:  isStarred,
-			MailboxID:  mailboxID,
-		})
+		email := s.messageToEmail(msg, section, realMailboxName, mailboxID)
+		result = append(result, email)
 	}
 
 	// Reverse result to show newest first
@@ -391,32 +360,28 @@ func (s *IMAPService) GetEmails(ctx context.Context, server string, port int, em
 }
 
 func (s *IMAPService) GetEmailByID(ctx context.Context, server string, port int, emailAddr, password, messageID string) (*emaildomain.Email, error) {
+	return s.getEmail(ctx, server, port, emailAddr, password, messageID, false)
+}
+
+// GetEmailMetadata fetches only the envelope and flags of an email, skipping the body
+func (s *IMAPService) GetEmailMetadata(ctx context.Context, server string, port int, emailAddr, password, messageID string) (*emaildomain.Email, error) {
+	return s.getEmail(ctx, server, port, emailAddr, password, messageID, true)
+}
+
+func (s *IMAPService) getEmail(ctx context.Context, server string, port int, emailAddr, password, messageID string, metadataOnly bool) (*emaildomain.Email, error) {
 	// Decode ID to get Mailbox and UID
-	decodedBytes, err := base64.URLEncoding.DecodeString(messageID)
+	mailboxName, uid, err := decodeEmailID(messageID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid email ID format")
-	}
-	decoded := string(decodedBytes)
-	parts := strings.Split(decoded, ":")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid email ID format")
-	}
-	mailboxName := parts[0]
-	uidStr := parts[1]
-	
-	var uid uint32
-	_, err = fmt.Sscanf(uidStr, "%d", &uid)
-	if err != nil {
-		return nil, fmt.Errorf("invalid UID format")
+		return nil, err
 	}
 
-	c, err := s.connect(server, port, emailAddr, password)
+	c, release, err := s.acquire(server, port, emailAddr, password)
 	if err != nil {
 		return nil, err
 	}
-	defer c.Logout()
+	defer release()
 
-	_, err = c.Select(mailboxName, false)
+	_, err = c.Select(mailboxName, metadataOnly)
 	if err != nil {
 		return nil, err
 	}
@@ -428,7 +393,12 @@ func (s *IMAPService) GetEmailByID(ctx context.Context, server string, port int,
 	done := make(chan error, 1)
 	
 	section := &imap.BodySectionName{}
-	items := []imap.FetchItem{imap.FetchEnvelope, imap.FetchFlags, imap.FetchInternalDate, imap.FetchUid, section.FetchItem()}
+	items := []imap.FetchItem{imap.FetchEnvelope, imap.FetchFlags, imap.FetchInternalDate, imap.FetchUid}
+	if !metadataOnly {
+		items = append(items, section.FetchItem())
+	} else {
+		items = append(items, threadHeaders.FetchItem())
+	}
 
 	go func() {
 		done <- c.UidFetch(seqset, items, messages)
@@ -436,7 +406,7 @@ func (s *IMAPService) GetEmailByID(ctx context.Context, server string, port int,
 
 	msg := <-messages
 	if msg == nil {
-		return nil, fmt.Errorf("email not found")
+		return nil, emaildomain.ErrEmailNotFound
 	}
 
 	if err := <-done; err != nil {
@@ -447,12 +417,12 @@ func (s *IMAPService) GetEmailByID(ctx context.Context, server string, port int,
 	subject := msg.Envelope.Subject
 	from := ""
 	if len(msg.Envelope.From) > 0 {
-		from = fmt.Sprintf("%s <%s@%s>", msg.Envelope.From[0].PersonalName, msg.Envelope.From[0].MailboxName, msg.Envelope.From[0].HostName)
+		from = formatEnvelopeAddress(msg.Envelope.From[0])
 	}
 	
 	to := []string{}
 	for _, addr := range msg.Envelope.To {
-		to = append(to, fmt.Sprintf("%s <%s@%s>", addr.PersonalName, addr.MailboxName, addr.HostName))
+		to = append(to, formatEnvelopeAddress(addr))
 	}
 	
 	// Get Body
@@ -460,14 +430,16 @@ func (s *IMAPService) GetEmailByID(ctx context.Context, server string, port int,
 	body := ""
 	isHTML := false
 	snippet := ""
+	var header mail.Header
+	var parsed *parsedMessage
 	
 	if r != nil {
-		var textBody string
-		body, textBody, isHTML = s.parseBody(r)
-		if len(textBody) > 100 {
-			snippet = textBody[:100] + "..."
-		} else {
-			snippet = textBody
+		parsed = s.parseBody(r)
+		body, isHTML, header = parsed.Body, parsed.IsHTML, parsed.Header
+		snippet = mailutil.Truncate(parsed.TextBody, 100)
+	} else if r := msg.GetBody(threadHeaders); r != nil {
+		if h, err := textproto.ReadHeader(bufio.NewReader(r)); err == nil {
+			header = mail.Header{Header: message.Header{Header: h}}
 		}
 	}
 
@@ -482,46 +454,150 @@ func (s *IMAPService) GetEmailByID(ctx context.Context, server string, port int,
 		}
 	}
 
-	return &emaildomain.Email{
+	var cc []string
+	for _, addr := range msg.Envelope.Cc {
+		cc = append(cc, formatEnvelopeAddress(addr))
+	}
+
+	email := &emaildomain.Email{
 		ID:         messageID,
+		StableID:   emailid.New(emailid.ProviderIMAP, msg.Envelope.MessageId),
 		Subject:    subject,
 		From:       from,
 		To:         to,
+		Cc:         cc,
+		MessageID:  msg.Envelope.MessageId,
 		Body:       body,
 		Preview:    snippet,
 		IsHTML:     isHTML,
-		ReceivedAt: msg.Envelope.Date,
+		ReceivedAt: resolveReceivedAt(msg, header),
 		IsRead:     isRead,
 		IsStarred:  isStarred,
 		MailboxID:  mailboxName, // Or map back to standard ID if needed
-	}, nil
+	}
+	email.IsImportant = hasFlag(msg.Flags, importantFlag)
+	applyHeaderInfo(email, header)
+	applyThreadID(email, mailboxName, header)
+	applyCalendarInvite(email, parsed)
+	if parsed != nil {
+		email.Attachments = parsed.Attachments
+	}
+
+	return email, nil
 }
 
-func (s *IMAPService) SendEmail(ctx context.Context, server string, port int, emailAddr, password string, to, subject, body string) error {
-	// Need SMTP server. Usually imap.gmail.com -> smtp.gmail.com
-	// We need to infer SMTP settings or ask user.
-	// For Gmail: smtp.gmail.com:587
-	
-	smtpServer := "smtp.gmail.com"
-	smtpPort := "587"
-	
-	// Simple heuristic for common providers
-	if strings.Contains(server, "outlook") {
-		smtpServer = "smtp.office365.com"
-		smtpPort = "587"
+// SendEmail sends through the account's SMTP server. reply is nil for a new conversation.
+func (s *IMAPService) SendEmail(ctx context.Context, server string, port int, emailAddr, password string, smtpCfg SMTPSettings, fromName, to, cc, bcc, subject, body string, files []*multipart.FileHeader, reply *emaildomain.ReplyHeaders) error {
+	raw, _, err := buildMessage(emailAddr, fromName, to, cc, bcc, subject, body, files, reply, false)
+	if err != nil {
+		return err
 	}
-	
-	auth := smtp.PlainAuth("", emailAddr, password, smtpServer)
-	
-	msg := []byte(fmt.Sprintf("To: %s\r\n"+
-		"Subject: %s\r\n"+
-		"MIME-Version: 1.0\r\n"+
-		"Content-Type: text/html; charset=\"UTF-8\"\r\n"+
-		"\r\n"+
-		"%s\r\n", to, subject, body))
-		
-	addr := fmt.Sprintf("%s:%s", smtpServer, smtpPort)
-	return smtp.SendMail(addr, auth, emailAddr, []string{to}, msg)
+
+	rcpt := recipients(to, cc, bcc)
+	if len(rcpt) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+	return s.SendRawEmail(ctx, server, port, emailAddr, password, smtpCfg, rcpt, raw)
+}
+
+// buildMessage writes an HTML message with attachments and returns it along with its
+// Message-ID. keepBcc keeps the Bcc header, which only a stored draft should carry.
+func buildMessage(emailAddr, fromName, to, cc, bcc, subject, body string, files []*multipart.FileHeader, reply *emaildomain.ReplyHeaders, keepBcc bool) ([]byte, string, error) {
+	var emailMsg bytes.Buffer
+	boundary := fmt.Sprintf("ga03_%d", time.Now().UnixNano())
+
+	messageID := mailutil.NewMessageID(emailAddr)
+
+	// Headers. A sent message leaves Bcc out, its recipients only appear in the envelope.
+	emailMsg.WriteString(fmt.Sprintf("From: %s\r\n", mailutil.FormatAddress(fromName, emailAddr)))
+	emailMsg.WriteString(fmt.Sprintf("Message-ID: %s\r\n", messageID))
+	emailMsg.WriteString(fmt.Sprintf("To: %s\r\n", to))
+	if cc != "" {
+		emailMsg.WriteString(fmt.Sprintf("Cc: %s\r\n", cc))
+	}
+	if keepBcc && bcc != "" {
+		emailMsg.WriteString(fmt.Sprintf("Bcc: %s\r\n", bcc))
+	}
+	if reply != nil && reply.InReplyTo != "" {
+		emailMsg.WriteString(fmt.Sprintf("In-Reply-To: %s\r\n", reply.InReplyTo))
+	}
+	if reply != nil && reply.References != "" {
+		emailMsg.WriteString(fmt.Sprintf("References: %s\r\n", reply.References))
+	}
+	// Encode subject to handle non-ASCII characters (RFC 2047)
+	encodedSubject := fmt.Sprintf("=?utf-8?B?%s?=", base64.StdEncoding.EncodeToString([]byte(subject)))
+	emailMsg.WriteString(fmt.Sprintf("Subject: %s\r\n", encodedSubject))
+	emailMsg.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123Z)))
+	emailMsg.WriteString("MIME-Version: 1.0\r\n")
+	emailMsg.WriteString(fmt.Sprintf("Content-Type: multipart/mixed; boundary=\"%s\"\r\n\r\n", boundary))
+
+	// Body
+	emailMsg.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+	emailMsg.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n\r\n")
+	emailMsg.WriteString(body)
+	emailMsg.WriteString("\r\n")
+
+	// Attachments
+	for _, file := range files {
+		f, err := file.Open()
+		if err != nil {
+			return nil, "", fmt.Errorf("unable to open file: %v", err)
+		}
+		content, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, "", fmt.Errorf("unable to read file: %v", err)
+		}
+
+		encodedContent := base64.StdEncoding.EncodeToString(content)
+
+		// Browsers leave the type empty for unknown extensions
+		contentType := file.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = mime.TypeByExtension(filepath.Ext(file.Filename))
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		emailMsg.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+		emailMsg.WriteString(fmt.Sprintf("Content-Type: %s; name=\"%s\"\r\n", contentType, file.Filename))
+		emailMsg.WriteString("Content-Transfer-Encoding: base64\r\n")
+		emailMsg.WriteString(fmt.Sprintf("Content-Disposition: attachment; filename=\"%s\"\r\n\r\n", file.Filename))
+
+		// Split base64 into lines of 76 characters
+		for i := 0; i < len(encodedContent); i += 76 {
+			end := i + 76
+			if end > len(encodedContent) {
+				end = len(encodedContent)
+			}
+			emailMsg.WriteString(encodedContent[i:end] + "\r\n")
+		}
+	}
+
+	emailMsg.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
+
+	return emailMsg.Bytes(), messageID, nil
+}
+
+// recipients lists every To, Cc and Bcc address once, each gets its own RCPT
+func recipients(to, cc, bcc string) []string {
+	var rcpt []string
+	seen := make(map[string]bool)
+	for _, addr := range mailutil.ParseAddresses([]string{to, cc, bcc}) {
+		key := mailutil.NormalizeAddress(addr.Address)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		rcpt = append(rcpt, addr.Address)
+	}
+	return rcpt
+}
+
+// SendRawEmail sends a fully formed RFC 5322 message, e.g. an iMIP calendar reply
+func (s *IMAPService) SendRawEmail(ctx context.Context, server string, port int, emailAddr, password string, smtpCfg SMTPSettings, to []string, raw []byte) error {
+	return sendMail(smtpSettings(server, smtpCfg), emailAddr, password, to, raw)
 }
 
 func (s *IMAPService) modifyFlags(ctx context.Context, server string, port int, emailAddr, password, messageID string, flags []interface{}, add bool) error {
@@ -544,11 +620,11 @@ func (s *IMAPService) modifyFlags(ctx context.Context, server string, port int,
 		return fmt.Errorf("invalid UID format")
 	}
 
-	c, err := s.connect(server, port, emailAddr, password)
+	c, release, err := s.acquire(server, port, emailAddr, password)
 	if err != nil {
 		return err
 	}
-	defer c.Logout()
+	defer release()
 
 	_, err = c.Select(mailboxName, false)
 	if err != nil {
@@ -575,31 +651,26 @@ func (s *IMAPService) MarkAsUnread(ctx context.Context, server string, port int,
 }
 
 func (s *IMAPService) ToggleStar(ctx context.Context, server string, port int, emailAddr, password, messageID string) error {
-	// Need to check current state first to toggle
-	// Decode ID
-	decodedBytes, err := base64.URLEncoding.DecodeString(messageID)
-	if err != nil {
-		return fmt.Errorf("invalid email ID format")
-	}
-	decoded := string(decodedBytes)
-	parts := strings.Split(decoded, ":")
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid email ID format")
-	}
-	mailboxName := parts[0]
-	uidStr := parts[1]
-	
-	var uid uint32
-	_, err = fmt.Sscanf(uidStr, "%d", &uid)
+	return s.toggleFlag(ctx, server, port, emailAddr, password, messageID, imap.FlaggedFlag)
+}
+
+// ToggleImportant toggles the $Important keyword
+func (s *IMAPService) ToggleImportant(ctx context.Context, server string, port int, emailAddr, password, messageID string) error {
+	return s.toggleFlag(ctx, server, port, emailAddr, password, messageID, importantFlag)
+}
+
+// toggleFlag removes flag from a message that has it and adds it otherwise
+func (s *IMAPService) toggleFlag(ctx context.Context, server string, port int, emailAddr, password, messageID, flag string) error {
+	mailboxName, uid, err := decodeEmailID(messageID)
 	if err != nil {
-		return fmt.Errorf("invalid UID format")
+		return err
 	}
 
-	c, err := s.connect(server, port, emailAddr, password)
+	c, release, err := s.acquire(server, port, emailAddr, password)
 	if err != nil {
 		return err
 	}
-	defer c.Logout()
+	defer release()
 
 	_, err = c.Select(mailboxName, false)
 	if err != nil {
@@ -618,26 +689,31 @@ func (s *IMAPService) ToggleStar(ctx context.Context, server string, port int, e
 
 	msg := <-messages
 	if msg == nil {
-		return fmt.Errorf("email not found")
+		return emaildomain.ErrEmailNotFound
 	}
 	if err := <-done; err != nil {
 		return err
 	}
 
-	isStarred := false
-	for _, f := range msg.Flags {
-		if f == imap.FlaggedFlag {
-			isStarred = true
-			break
-		}
-	}
-
 	item := imap.FormatFlagsOp(imap.AddFlags, true)
-	if isStarred {
+	if hasFlag(msg.Flags, flag) {
 		item = imap.FormatFlagsOp(imap.RemoveFlags, true)
 	}
 
-	return c.UidStore(seqset, item, []interface{}{imap.FlaggedFlag}, nil)
+	return c.UidStore(seqset, item, []interface{}{flag}, nil)
+}
+
+// importantFlag is the keyword mail clients use for important messages (RFC 8457)
+const importantFlag = "$Important"
+
+// hasFlag reports whether flags holds flag; flags and keywords are case-insensitive
+func hasFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if strings.EqualFold(f, flag) {
+			return true
+		}
+	}
+	return false
 }
 
 func (s *IMAPService) moveEmail(ctx context.Context, server string, port int, emailAddr, password, messageID string, targetMailboxType string) error {
@@ -660,56 +736,20 @@ func (s *IMAPService) moveEmail(ctx context.Context, server string, port int, em
 		return fmt.Errorf("invalid UID format")
 	}
 
-	c, err := s.connect(server, port, emailAddr, password)
+	c, release, err := s.acquire(server, port, emailAddr, password)
 	if err != nil {
 		return err
 	}
-	defer c.Logout()
+	defer release()
 
 	// Find target mailbox name
-	mailboxes := make(chan *imap.MailboxInfo, 10)
-	done := make(chan error, 1)
-	go func() {
-		done <- c.List("", "*", mailboxes)
-	}()
-
-	var targetMailboxName string
-	found := false
-
-	for m := range mailboxes {
-		for _, attr := range m.Attributes {
-			if (targetMailboxType == "trash" && attr == "\\Trash") ||
-			   (targetMailboxType == "archive" && attr == "\\All") { // Archive usually means All Mail in Gmail
-				targetMailboxName = m.Name
-				found = true
-				break
-			}
-		}
-		if found { continue } // Drain
-		
-		// Fallback name matching
-		lowerName := strings.ToLower(m.Name)
-		if targetMailboxType == "trash" && (strings.Contains(lowerName, "trash") || strings.Contains(lowerName, "bin") || strings.Contains(lowerName, "thùng rác")) {
-			targetMailboxName = m.Name; found = true
-		} else if targetMailboxType == "archive" && (strings.Contains(lowerName, "all mail") || strings.Contains(lowerName, "tất cả thư")) {
-			targetMailboxName = m.Name; found = true
-		}
-	}
-	
-	if err := <-done; err != nil {
+	account := accountKey(server, emailAddr)
+	targetMailboxName, err := s.findMoveTarget(c, account, targetMailboxType)
+	if err != nil {
 		return err
 	}
 
-	if !found {
-		// Fallback defaults
-		if targetMailboxType == "trash" {
-			targetMailboxName = "[Gmail]/Trash"
-		} else {
-			targetMailboxName = "[Gmail]/All Mail"
-		}
-	}
-
-	_, err = c.Select(mailboxName, false)
+	_, err = s.selectMailbox(c, account, mailboxName, false)
 	if err != nil {
 		return err
 	}
@@ -720,6 +760,8 @@ func (s *IMAPService) moveEmail(ctx context.Context, server string, port int, em
 	// Copy to target
 	err = c.UidCopy(seqset, targetMailboxName)
 	if err != nil {
+		// The cached target may be stale
+		s.names.invalidate(account)
 		return err
 	}
 
@@ -743,3 +785,13 @@ func (s *IMAPService) TrashEmail(ctx context.Context, server string, port int, e
 func (s *IMAPService) ArchiveEmail(ctx context.Context, server string, port int, emailAddr, password, messageID string) error {
 	return s.moveEmail(ctx, server, port, emailAddr, password, messageID, "archive")
 }
+
+// MarkAsSpam moves a message to the Junk folder
+func (s *IMAPService) MarkAsSpam(ctx context.Context, server string, port int, emailAddr, password, messageID string) error {
+	return s.moveEmail(ctx, server, port, emailAddr, password, messageID, "spam")
+}
+
+// MarkNotSpam moves a message back to INBOX
+func (s *IMAPService) MarkNotSpam(ctx context.Context, server string, port int, emailAddr, password, messageID string) error {
+	return s.moveEmail(ctx, server, port, emailAddr, password, messageID, "inbox")
+}
@@ -0,0 +1,462 @@
+package imap
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	emaildomain "ga03-backend/internal/email/domain"
+
+	"github.com/emersion/go-imap"
+	gomail "github.com/emersion/go-message/mail"
+)
+
+const plainMessage = "From: Alice <alice@example.com>\r\n" +
+	"To: username@example.com\r\n" +
+	"Subject: Lunch\r\n" +
+	"Date: Wed, 11 May 2016 14:31:59 +0000\r\n" +
+	"Message-ID: <lunch@example.com>\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"Noon at the usual place?"
+
+func TestGetEmailMetadataSkipsBody(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.addMessage(plainMessage, time.Now())
+	s := NewService()
+
+	email, err := s.GetEmailMetadata(context.Background(), ts.host, ts.port, testUser, testPassword, id)
+	if err != nil {
+		t.Fatalf("GetEmailMetadata() error = %v", err)
+	}
+	if email.Subject != "Lunch" || !strings.Contains(email.From, "alice@example.com") {
+		t.Errorf("metadata = (%q, %q), want the envelope", email.Subject, email.From)
+	}
+	if email.Body != "" {
+		t.Errorf("Body = %q, want none in metadata mode", email.Body)
+	}
+	for _, item := range ts.fetched() {
+		if item == "BODY[]" || item == "BODY.PEEK[]" || item == "RFC822" {
+			t.Errorf("metadata mode fetched %s", item)
+		}
+	}
+
+	// The full fetch still downloads the body
+	full, err := s.GetEmailByID(context.Background(), ts.host, ts.port, testUser, testPassword, id)
+	if err != nil {
+		t.Fatalf("GetEmailByID() error = %v", err)
+	}
+	if !strings.Contains(full.Body, "Noon at the usual place?") {
+		t.Errorf("Body = %q, want the message text", full.Body)
+	}
+}
+
+func TestBuildMessageEncodesFromName(t *testing.T) {
+	raw, _, err := buildMessage("support@example.com", "Đội Hỗ trợ", "to@example.com", "", "", "Hi", "<p>Hi</p>", nil, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := msg.Header.AddressList("From")
+	if err != nil || len(from) != 1 {
+		t.Fatalf("From = %q: %v", msg.Header.Get("From"), err)
+	}
+	if from[0].Name != "Đội Hỗ trợ" || from[0].Address != "support@example.com" {
+		t.Errorf("From parsed as (%q, %q)", from[0].Name, from[0].Address)
+	}
+	if strings.Contains(msg.Header.Get("From"), "Đội") {
+		t.Errorf("From = %q, want the name RFC 2047 encoded", msg.Header.Get("From"))
+	}
+}
+
+// uploadedFile returns content as a file uploaded in a form, the way the send handler receives it
+func uploadedFile(t *testing.T, filename, contentType string, content []byte) *multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="files"; filename="`+filename+`"`)
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	part, err := w.CreatePart(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	w.Close()
+
+	form, err := multipart.NewReader(&body, w.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	return form.File["files"][0]
+}
+
+func TestBuildMessageIncludesAttachments(t *testing.T) {
+	pdf := make([]byte, 1024)
+	for i := range pdf {
+		pdf[i] = byte(i * 7)
+	}
+	copy(pdf, "%PDF-1.4\n")
+	files := []*multipart.FileHeader{
+		uploadedFile(t, "report.pdf", "application/pdf", pdf),
+		// Browsers send no type for extensions they don't know
+		uploadedFile(t, "notes.txt", "", []byte("see attached")),
+		uploadedFile(t, "data.unknownext", "", []byte{0, 1, 2}),
+	}
+
+	raw, _, err := buildMessage("me@example.com", "", "to@example.com", "", "", "Report", "<p>Attached</p>", files, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type = %q, want multipart/mixed", msg.Header.Get("Content-Type"))
+	}
+
+	type attachment struct {
+		contentType, disposition string
+		content                  []byte
+	}
+	var attachments []attachment
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if part.Header.Get("Content-Disposition") == "" {
+			continue // The body
+		}
+		encoded, _ := io.ReadAll(part)
+		for _, line := range strings.Split(strings.TrimSpace(string(encoded)), "\r\n") {
+			if len(line) > 76 {
+				t.Errorf("base64 line of %d characters, want at most 76", len(line))
+			}
+		}
+		content, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(encoded), "\r\n", ""))
+		if err != nil {
+			t.Fatalf("attachment isn't valid base64: %v", err)
+		}
+		attachments = append(attachments, attachment{part.Header.Get("Content-Type"), part.Header.Get("Content-Disposition"), content})
+	}
+
+	if len(attachments) != 3 {
+		t.Fatalf("got %d attachments, want 3", len(attachments))
+	}
+	if !bytes.Equal(attachments[0].content, pdf) {
+		t.Error("the PDF didn't survive encoding intact")
+	}
+	wantTypes := []string{"application/pdf", "text/plain; charset=utf-8", "application/octet-stream"}
+	for i, file := range files {
+		if want := wantTypes[i] + `; name="` + file.Filename + `"`; attachments[i].contentType != want {
+			t.Errorf("Content-Type = %q, want %q", attachments[i].contentType, want)
+		}
+		if want := `attachment; filename="` + file.Filename + `"`; attachments[i].disposition != want {
+			t.Errorf("Content-Disposition = %q, want %q", attachments[i].disposition, want)
+		}
+	}
+}
+
+func TestResolveReceivedAt(t *testing.T) {
+	envelope := time.Date(2024, 3, 1, 9, 0, 0, 0, time.FixedZone("ICT", 7*3600))
+	headerDate := time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)
+	internal := time.Date(2024, 1, 1, 9, 0, 0, 0, time.FixedZone("EST", -5*3600))
+
+	var dated gomail.Header
+	dated.SetDate(headerDate)
+
+	tests := []struct {
+		name   string
+		msg    *imap.Message
+		header gomail.Header
+		want   time.Time
+	}{
+		{"envelope", &imap.Message{Envelope: &imap.Envelope{Date: envelope}, InternalDate: internal}, dated, envelope},
+		{"zero envelope uses the Date header", &imap.Message{Envelope: &imap.Envelope{}, InternalDate: internal}, dated, headerDate},
+		{"no Date header uses INTERNALDATE", &imap.Message{Envelope: &imap.Envelope{}, InternalDate: internal}, gomail.Header{}, internal},
+		{"no envelope", &imap.Message{InternalDate: internal}, gomail.Header{}, internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolveReceivedAt(tt.msg, tt.header)
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Errorf("resolveReceivedAt() = %v, want %v in UTC", got, tt.want)
+			}
+		})
+	}
+}
+
+// A message without a usable date sorts by when the server received it, not as 1970
+func TestUndatedMessageUsesInternalDate(t *testing.T) {
+	ts := newTestServer(t)
+	ts.addMessage(plainMessage, time.Now())
+	undated := strings.Replace(plainMessage, "Date: Wed, 11 May 2016 14:31:59 +0000\r\n", "", 1)
+	received := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	id := ts.addMessage(undated, received)
+
+	emails, _, err := NewService().GetEmails(context.Background(), ts.host, ts.port, testUser, testPassword, "INBOX", 10, 0)
+	if err != nil {
+		t.Fatalf("GetEmails() error = %v", err)
+	}
+	if len(emails) != 2 {
+		t.Fatalf("got %d emails, want 2", len(emails))
+	}
+	if emails[0].ID != id || !emails[0].ReceivedAt.Equal(received) {
+		t.Errorf("newest email = (%s, %v), want the undated one received %v", emails[0].ID, emails[0].ReceivedAt, received)
+	}
+	if !emails[0].ReceivedAt.After(emails[1].ReceivedAt) {
+		t.Errorf("the undated email (%v) sorts before the dated one (%v)", emails[0].ReceivedAt, emails[1].ReceivedAt)
+	}
+
+	email, err := NewService().GetEmailByID(context.Background(), ts.host, ts.port, testUser, testPassword, id)
+	if err != nil {
+		t.Fatalf("GetEmailByID() error = %v", err)
+	}
+	if !email.ReceivedAt.Equal(received) {
+		t.Errorf("GetEmailByID() ReceivedAt = %v, want %v", email.ReceivedAt, received)
+	}
+}
+
+const inviteMessage = "From: Alice <alice@example.com>\r\n" +
+	"To: username@example.com\r\n" +
+	"Subject: Invitation: Planning\r\n" +
+	"Date: Wed, 11 May 2016 14:31:59 +0000\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=outer\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"You have been invited to Planning.\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/ics; name=invite.ics\r\n" +
+	"Content-Disposition: attachment; filename=invite.ics\r\n" +
+	"\r\n" +
+	"BEGIN:VCALENDAR\r\n" +
+	"METHOD:REQUEST\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:planning@example.com\r\n" +
+	"SUMMARY:Planning\r\n" +
+	"LOCATION:Room 4\r\n" +
+	"DTSTART:20240305T090000Z\r\n" +
+	"DTEND:20240305T100000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n" +
+	"--outer--\r\n"
+
+func TestGetEmailByIDParsesInvite(t *testing.T) {
+	ts := newTestServer(t)
+	invite := ts.addMessage(inviteMessage, time.Now())
+	plain := ts.addMessage(plainMessage, time.Now())
+	s := NewService()
+
+	email, err := s.GetEmailByID(context.Background(), ts.host, ts.port, testUser, testPassword, invite)
+	if err != nil {
+		t.Fatalf("GetEmailByID() error = %v", err)
+	}
+	if !email.IsInvite || email.Invite == nil {
+		t.Fatalf("email = (invite %v, %+v), want a parsed invite", email.IsInvite, email.Invite)
+	}
+	event := email.Invite
+	start := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+	if event.Summary != "Planning" || event.Location != "Room 4" || !event.Start.Equal(start) || !event.End.Equal(start.Add(time.Hour)) {
+		t.Errorf("event = %+v, want Planning in Room 4 from 09:00 to 10:00 UTC", event)
+	}
+
+	email, err = s.GetEmailByID(context.Background(), ts.host, ts.port, testUser, testPassword, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email.IsInvite || email.Invite != nil {
+		t.Error("a plain message was marked as an invite")
+	}
+}
+
+const relatedMessage = "From: Alice <alice@example.com>\r\n" +
+	"To: username@example.com\r\n" +
+	"Subject: Quarterly report\r\n" +
+	"Date: Wed, 11 May 2016 14:31:59 +0000\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=outer\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/related; boundary=inner\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html\r\n" +
+	"\r\n" +
+	"<p>Report attached.</p><img src=\"cid:logo@example.com\">\r\n" +
+	"--inner\r\n" +
+	"Content-Type: image/png\r\n" +
+	"Content-ID: <logo@example.com>\r\n" +
+	"Content-Disposition: inline; filename=logo.png\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"iVBORw0KGgo=\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/pdf\r\n" +
+	"Content-Disposition: attachment; filename=report.pdf\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"JVBERi0xLjQK\r\n" +
+	"--outer--\r\n"
+
+func TestGetEmailByIDClassifiesInline(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.addMessage(relatedMessage, time.Now())
+
+	email, err := NewService().GetEmailByID(context.Background(), ts.host, ts.port, testUser, testPassword, id)
+	if err != nil {
+		t.Fatalf("GetEmailByID() error = %v", err)
+	}
+	if len(email.Attachments) != 2 {
+		t.Fatalf("got %d attachments, want 2: %+v", len(email.Attachments), email.Attachments)
+	}
+	byName := make(map[string]*emaildomain.Attachment)
+	for i := range email.Attachments {
+		byName[email.Attachments[i].Name] = &email.Attachments[i]
+	}
+	if logo := byName["logo.png"]; logo == nil || !logo.IsInline || logo.ContentID != "logo@example.com" || logo.MimeType != "image/png" {
+		t.Errorf("logo = %+v, want an inline image", logo)
+	}
+	if pdf := byName["report.pdf"]; pdf == nil || pdf.IsInline || pdf.MimeType != "application/pdf" {
+		t.Errorf("pdf = %+v, want a real attachment", pdf)
+	}
+}
+
+func TestSnippetKeepsAccentedCharacters(t *testing.T) {
+	ts := newTestServer(t)
+	text := strings.Repeat("Hẹn gặp lúc trưa ", 10)
+	message := strings.Replace(plainMessage, "Content-Type: text/plain", "Content-Type: text/plain; charset=utf-8", 1)
+	message = strings.Replace(message, "Noon at the usual place?", text, 1)
+	id := ts.addMessage(message, time.Now())
+
+	email, err := NewService().GetEmailByID(context.Background(), ts.host, ts.port, testUser, testPassword, id)
+	if err != nil {
+		t.Fatalf("GetEmailByID() error = %v", err)
+	}
+	// Cutting at byte 100 would split the "ặ" of a "gặp"
+	kept, ok := strings.CutSuffix(email.Preview, "...")
+	if !ok || !utf8.ValidString(email.Preview) || !strings.HasPrefix(text, kept+" ") {
+		t.Errorf("Preview = %q, want whole words of the body followed by ...", email.Preview)
+	}
+}
+
+func TestParseBodyDecodesParts(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		wantText string
+		wantHTML string
+	}{
+		{
+			"quoted-printable",
+			"Content-Type: text/plain; charset=utf-8\r\n" +
+				"Content-Transfer-Encoding: quoted-printable\r\n" +
+				"\r\n" +
+				"Noon =E2=80=94 the usual place",
+			"Noon — the usual place", "",
+		},
+		{
+			// The part is still read, only left in its original bytes
+			"quoted-printable in an unknown charset",
+			"Content-Type: text/plain; charset=x-unknown\r\n" +
+				"Content-Transfer-Encoding: quoted-printable\r\n" +
+				"\r\n" +
+				"Noon =E2=80=94 the usual place",
+			"Noon — the usual place", "",
+		},
+		{
+			"windows-1252 html",
+			"Content-Type: multipart/alternative; boundary=b\r\n" +
+				"\r\n" +
+				"--b\r\n" +
+				"Content-Type: text/plain; charset=utf-8\r\n" +
+				"\r\n" +
+				"Noon\r\n" +
+				"--b\r\n" +
+				"Content-Type: text/html; charset=windows-1252\r\n" +
+				"Content-Transfer-Encoding: quoted-printable\r\n" +
+				"\r\n" +
+				"<p>Noon =96 caf=E9</p>\r\n" +
+				"--b--\r\n",
+			"Noon", "<p>Noon – café</p>",
+		},
+		{
+			"unknown transfer encoding skips only that part",
+			"Content-Type: multipart/alternative; boundary=b\r\n" +
+				"\r\n" +
+				"--b\r\n" +
+				"Content-Type: text/plain; charset=utf-8\r\n" +
+				"Content-Transfer-Encoding: x-unknown\r\n" +
+				"\r\n" +
+				"garbled\r\n" +
+				"--b\r\n" +
+				"Content-Type: text/html; charset=utf-8\r\n" +
+				"\r\n" +
+				"<p>Noon</p>\r\n" +
+				"--b--\r\n",
+			"", "<p>Noon</p>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := "From: alice@example.com\r\nSubject: Lunch\r\n" + tt.raw
+			parsed := NewService().parseBody(strings.NewReader(raw))
+			if parsed.TextBody != tt.wantText {
+				t.Errorf("TextBody = %q, want %q", parsed.TextBody, tt.wantText)
+			}
+			if tt.wantHTML != "" && (!parsed.IsHTML || !strings.Contains(parsed.Body, tt.wantHTML)) {
+				t.Errorf("Body = %q, want HTML containing %q", parsed.Body, tt.wantHTML)
+			}
+		})
+	}
+}
+
+func TestGetEmailByIDSplitsRecipients(t *testing.T) {
+	ts := newTestServer(t)
+	message := strings.Replace(plainMessage, "To: username@example.com\r\n",
+		"To: \"Doe, Jane\" <jane@example.com>, username@example.com\r\n"+
+			"Cc: \"Carol \\\"CJ\\\" Jones\" <carol@example.com>, =?UTF-8?Q?Nguy=E1=BB=85n?= <nguyen@example.com>\r\n", 1)
+	id := ts.addMessage(message, time.Now())
+
+	email, err := NewService().GetEmailByID(context.Background(), ts.host, ts.port, testUser, testPassword, id)
+	if err != nil {
+		t.Fatalf("GetEmailByID() error = %v", err)
+	}
+	wantTo := []string{`"Doe, Jane" <jane@example.com>`, "username@example.com"}
+	wantCc := []string{`"Carol \"CJ\" Jones" <carol@example.com>`, "Nguyễn <nguyen@example.com>"}
+	if !slices.Equal(email.To, wantTo) {
+		t.Errorf("To = %q, want %q", email.To, wantTo)
+	}
+	if !slices.Equal(email.Cc, wantCc) {
+		t.Errorf("Cc = %q, want %q", email.Cc, wantCc)
+	}
+	if email.From != "Alice <alice@example.com>" {
+		t.Errorf("From = %q, want Alice <alice@example.com>", email.From)
+	}
+}
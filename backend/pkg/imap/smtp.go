@@ -0,0 +1,131 @@
+package imap
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTP TLS modes
+const (
+	SMTPImplicitTLS = "tls"      // TLS from the first byte, usually port 465
+	SMTPStartTLS    = "starttls" // Plain connection upgraded with STARTTLS, usually port 587
+)
+
+const smtpDialTimeout = 30 * time.Second
+
+// SMTPSettings says where an IMAP account sends its mail. Empty fields are filled in
+// by smtpSettings.
+type SMTPSettings struct {
+	Server string
+	Port   int
+	TLS    string // SMTPImplicitTLS, SMTPStartTLS, or "" to choose by port
+}
+
+// smtpSettings completes an account's SMTP settings. Without a stored server it is
+// derived from the IMAP host, e.g. imap.example.com -> smtp.example.com.
+func smtpSettings(imapServer string, cfg SMTPSettings) SMTPSettings {
+	if cfg.Server == "" {
+		cfg.Server, cfg.Port = deriveSMTPServer(imapServer, cfg.Port)
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+		if cfg.TLS == SMTPImplicitTLS {
+			cfg.Port = 465
+		}
+	}
+	if cfg.TLS == "" {
+		cfg.TLS = SMTPStartTLS
+		if cfg.Port == 465 {
+			cfg.TLS = SMTPImplicitTLS
+		}
+	}
+	return cfg
+}
+
+func deriveSMTPServer(imapServer string, port int) (string, int) {
+	host := strings.ToLower(strings.TrimSpace(imapServer))
+	switch {
+	case strings.Contains(host, "gmail") || strings.Contains(host, "googlemail"):
+		return GoogleSMTPServer, port
+	case strings.Contains(host, "outlook") || strings.Contains(host, "office365") || strings.Contains(host, "hotmail"):
+		return "smtp.office365.com", port
+	case strings.Contains(host, "yahoo"):
+		if port == 0 {
+			port = 465
+		}
+		return "smtp.mail.yahoo.com", port
+	case strings.HasPrefix(host, "imap."):
+		return "smtp." + strings.TrimPrefix(host, "imap."), port
+	}
+	// Hosts like mail.example.com usually serve both protocols
+	return host, port
+}
+
+// sendMail delivers raw to rcpt. STARTTLS is required rather than attempted, so
+// credentials never go over a plain connection.
+func sendMail(cfg SMTPSettings, from, password string, rcpt []string, raw []byte) error {
+	addr := net.JoinHostPort(cfg.Server, strconv.Itoa(cfg.Port))
+	tlsConfig := &tls.Config{ServerName: cfg.Server}
+	dialer := &net.Dialer{Timeout: smtpDialTimeout}
+
+	var conn net.Conn
+	var err error
+	if cfg.TLS == SMTPImplicitTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server %s: %w", addr, err)
+	}
+
+	c, err := smtp.NewClient(conn, cfg.Server)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to SMTP server %s: %w", addr, err)
+	}
+	defer c.Close()
+
+	if cfg.TLS != SMTPImplicitTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP server %s does not support STARTTLS", addr)
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+
+	var auth smtp.Auth
+	if password, accessToken := splitSecret(password); accessToken != "" {
+		auth = &xoauth2SMTPAuth{username: from, accessToken: accessToken}
+	} else {
+		auth = smtp.PlainAuth("", from, password, cfg.Server)
+	}
+	if err := c.Auth(auth); err != nil {
+		return fmt.Errorf("SMTP authentication failed: %w", err)
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, to := range rcpt {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,51 @@
+package imap
+
+import (
+	"sync"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// statusWorkers bounds the connections GetMailboxes uses for STATUS, matching what
+// the pool keeps idle per account
+const statusWorkers = maxIdleConnsPerAccount
+
+// unseenCounts runs STATUS (UNSEEN) for each mailbox, spread over c and up to
+// statusWorkers-1 more pooled connections, since one connection answers one
+// command at a time. Counts are in the order of names; a failed STATUS leaves 0.
+func (s *IMAPService) unseenCounts(c *client.Client, server string, port int, email, password string, names []string) []int {
+	counts := make([]int, len(names))
+	jobs := make(chan int, len(names))
+	for i := range names {
+		jobs <- i
+	}
+	close(jobs)
+
+	work := func(c *client.Client) {
+		for i := range jobs {
+			status, err := c.Status(names[i], []imap.StatusItem{imap.StatusUnseen})
+			if err == nil {
+				counts[i] = int(status.Unseen)
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for w := 1; w < statusWorkers && w < len(names); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			extra, release, err := s.acquire(server, port, email, password)
+			if err != nil {
+				// The other workers pick up its share
+				return
+			}
+			defer release()
+			work(extra)
+		}()
+	}
+	work(c)
+	wg.Wait()
+	return counts
+}
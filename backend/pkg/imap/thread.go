@@ -0,0 +1,385 @@
+package imap
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	emaildomain "ga03-backend/internal/email/domain"
+	"ga03-backend/pkg/utils/emailid"
+	"ga03-backend/pkg/utils/mailutil"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-message/mail"
+	"github.com/emersion/go-message/textproto"
+)
+
+// decodeEmailID splits an encoded "Mailbox:UID" email ID
+func decodeEmailID(messageID string) (string, uint32, error) {
+	decodedBytes, err := base64.URLEncoding.DecodeString(messageID)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid email ID format")
+	}
+	parts := strings.Split(string(decodedBytes), ":")
+	if len(parts) != 2 {
+		return "", 0, fmt.Errorf("invalid email ID format")
+	}
+
+	var uid uint32
+	if _, err := fmt.Sscanf(parts[1], "%d", &uid); err != nil {
+		return "", 0, fmt.Errorf("invalid UID format")
+	}
+	return parts[0], uid, nil
+}
+
+// encodeEmailID builds the "Mailbox:UID" email ID decoded by decodeEmailID
+func encodeEmailID(mailboxName string, uid uint32) string {
+	return base64.URLEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%d", mailboxName, uid)))
+}
+
+func formatEnvelopeAddress(addr *imap.Address) string {
+	return mailutil.DisplayAddress(addr.PersonalName, addr.Address())
+}
+
+// A thread ID names a conversation by its mailbox and the Message-ID it started with.
+// Message-IDs hold no whitespace, so a newline separates the two.
+func encodeThreadID(mailboxName, rootID string) string {
+	return base64.URLEncoding.EncodeToString([]byte(mailboxName + "\n" + rootID))
+}
+
+func decodeThreadID(threadID string) (string, string, bool) {
+	decoded, err := base64.URLEncoding.DecodeString(threadID)
+	if err != nil {
+		return "", "", false
+	}
+	mailboxName, rootID, found := strings.Cut(string(decoded), "\n")
+	if !found || rootID == "" {
+		return "", "", false
+	}
+	return mailboxName, rootID, true
+}
+
+// threadRoot returns the Message-ID, without angle brackets, a message's conversation
+// started with: the first References entry, else In-Reply-To, else the message's own ID
+func threadRoot(messageID, inReplyTo, references string) string {
+	root := messageID
+	if refs := strings.Fields(references); len(refs) > 0 {
+		root = refs[0]
+	} else if parents := strings.Fields(inReplyTo); len(parents) > 0 {
+		root = parents[0]
+	}
+	return strings.Trim(strings.TrimSpace(root), "<>")
+}
+
+// applyThreadID sets the thread ID of a message in mailboxName from its headers
+func applyThreadID(email *emaildomain.Email, mailboxName string, header mail.Header) {
+	root := threadRoot(email.MessageID, header.Get("In-Reply-To"), header.Get("References"))
+	if root != "" {
+		email.ThreadID = encodeThreadID(mailboxName, root)
+	}
+}
+
+// threadHeaders is the header section fetched to link messages into threads
+var threadHeaders = &imap.BodySectionName{
+	BodyPartName: imap.BodyPartName{
+		Specifier: imap.HeaderSpecifier,
+		Fields:    []string{"Message-Id", "In-Reply-To", "References"},
+	},
+	Peek: true,
+}
+
+// maxThreadRounds bounds how many reply generations GetThread follows when messages
+// don't carry the full References chain
+const maxThreadRounds = 10
+
+// GetThread returns the messages of a conversation, oldest first. threadID is a thread
+// ID or the email ID of any message in the thread. Servers vary on THREAD support, so
+// the thread is built by following Message-ID, In-Reply-To and References within the
+// message's mailbox.
+func (s *IMAPService) GetThread(ctx context.Context, server string, port int, emailAddr, password, threadID string) ([]*emaildomain.Email, error) {
+	c, release, err := s.acquire(server, port, emailAddr, password)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	mailboxName, root, ok := decodeThreadID(threadID)
+	if !ok {
+		var uid uint32
+		mailboxName, uid, err = decodeEmailID(threadID)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := c.Select(mailboxName, true); err != nil {
+			return nil, err
+		}
+		seqset := new(imap.SeqSet)
+		seqset.AddNum(uid)
+		linked, err := fetchThreadLinks(c, seqset)
+		if err != nil {
+			return nil, err
+		}
+		if len(linked) == 0 {
+			return nil, emaildomain.ErrEmailNotFound
+		}
+		root = linked[0].root
+		if root == "" {
+			// Without a Message-ID the message is a thread of its own
+			seqset := new(imap.SeqSet)
+			seqset.AddNum(uid)
+			return fetchThreadEmails(c, seqset, mailboxName, "")
+		}
+	} else if _, err := c.Select(mailboxName, true); err != nil {
+		return nil, err
+	}
+
+	// Replies normally list the root in References, so the first round finds the whole
+	// thread. Later rounds pick up replies that only point at their direct parent.
+	found := make(map[uint32]bool)
+	searched := map[string]bool{root: true}
+	frontier := []string{root}
+	for round := 0; round < maxThreadRounds && len(frontier) > 0; round++ {
+		newUIDs := new(imap.SeqSet)
+		for _, id := range frontier {
+			uids, err := c.UidSearch(linkedTo(id))
+			if err != nil {
+				return nil, err
+			}
+			for _, uid := range uids {
+				if !found[uid] {
+					found[uid] = true
+					newUIDs.AddNum(uid)
+				}
+			}
+		}
+		frontier = nil
+		if newUIDs.Empty() {
+			break
+		}
+
+		linked, err := fetchThreadLinks(c, newUIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range linked {
+			if l.messageID != "" && !searched[l.messageID] {
+				searched[l.messageID] = true
+				frontier = append(frontier, l.messageID)
+			}
+		}
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("thread not found")
+	}
+
+	seqset := new(imap.SeqSet)
+	for uid := range found {
+		seqset.AddNum(uid)
+	}
+	return fetchThreadEmails(c, seqset, mailboxName, encodeThreadID(mailboxName, root))
+}
+
+// linkedTo matches the message with Message-ID id and every message replying to it.
+// HEADER searches match substrings, so the brackets keep one ID from matching another.
+func linkedTo(id string) *imap.SearchCriteria {
+	id = "<" + id + ">"
+	self := imap.NewSearchCriteria()
+	self.Header.Add("Message-Id", id)
+	references := imap.NewSearchCriteria()
+	references.Header.Add("References", id)
+	inReplyTo := imap.NewSearchCriteria()
+	inReplyTo.Header.Add("In-Reply-To", id)
+
+	replies := imap.NewSearchCriteria()
+	replies.Or = [][2]*imap.SearchCriteria{{references, inReplyTo}}
+	criteria := imap.NewSearchCriteria()
+	criteria.Or = [][2]*imap.SearchCriteria{{self, replies}}
+	return criteria
+}
+
+type threadLink struct {
+	messageID string
+	root      string
+}
+
+// fetchThreadLinks reads the threading headers of the given UIDs in the selected mailbox
+func fetchThreadLinks(c *client.Client, seqset *imap.SeqSet) ([]threadLink, error) {
+	messages := make(chan *imap.Message, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.UidFetch(seqset, []imap.FetchItem{imap.FetchUid, threadHeaders.FetchItem()}, messages)
+	}()
+
+	var links []threadLink
+	for msg := range messages {
+		var header textproto.Header
+		if r := msg.GetBody(threadHeaders); r != nil {
+			if h, err := textproto.ReadHeader(bufio.NewReader(r)); err == nil {
+				header = h
+			}
+		}
+		messageID := header.Get("Message-Id")
+		links = append(links, threadLink{
+			messageID: threadRoot(messageID, "", ""),
+			root:      threadRoot(messageID, header.Get("In-Reply-To"), header.Get("References")),
+		})
+	}
+	return links, <-done
+}
+
+// fetchThreadEmails loads the envelope and flags of the given UIDs, oldest first
+func fetchThreadEmails(c *client.Client, seqset *imap.SeqSet, mailboxName, threadID string) ([]*emaildomain.Email, error) {
+	messages := make(chan *imap.Message, 10)
+	done := make(chan error, 1)
+	items := []imap.FetchItem{imap.FetchEnvelope, imap.FetchFlags, imap.FetchInternalDate, imap.FetchUid}
+
+	go func() {
+		done <- c.UidFetch(seqset, items, messages)
+	}()
+
+	var result []*emaildomain.Email
+	for msg := range messages {
+		if msg.Envelope == nil {
+			continue
+		}
+
+		email := &emaildomain.Email{
+			ID:         encodeEmailID(mailboxName, msg.Uid),
+			StableID:   emailid.New(emailid.ProviderIMAP, msg.Envelope.MessageId),
+			MessageID:  msg.Envelope.MessageId,
+			ThreadID:   threadID,
+			Subject:    msg.Envelope.Subject,
+			ReceivedAt: resolveReceivedAt(msg, mail.Header{}),
+			MailboxID:  mailboxName,
+		}
+		if len(msg.Envelope.From) > 0 {
+			email.From = formatEnvelopeAddress(msg.Envelope.From[0])
+		}
+		for _, addr := range msg.Envelope.To {
+			email.To = append(email.To, formatEnvelopeAddress(addr))
+		}
+		for _, addr := range msg.Envelope.Cc {
+			email.Cc = append(email.Cc, formatEnvelopeAddress(addr))
+		}
+		for _, f := range msg.Flags {
+			switch f {
+			case imap.SeenFlag:
+				email.IsRead = true
+			case imap.FlaggedFlag:
+				email.IsStarred = true
+			}
+		}
+		result = append(result, email)
+	}
+
+	if err := <-done; err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ReceivedAt.Before(result[j].ReceivedAt)
+	})
+	return result, nil
+}
+
+// threadUIDs returns the mailbox and UIDs of every message in messageID's thread, and
+// whether any of them is starred
+func (s *IMAPService) threadUIDs(ctx context.Context, server string, port int, emailAddr, password, messageID string) (string, *imap.SeqSet, bool, error) {
+	emails, err := s.GetThread(ctx, server, port, emailAddr, password, messageID)
+	if err != nil {
+		return "", nil, false, err
+	}
+	if len(emails) == 0 {
+		return "", nil, false, fmt.Errorf("thread not found")
+	}
+	mailboxName := emails[0].MailboxID
+
+	seqset := new(imap.SeqSet)
+	starred := false
+	for _, email := range emails {
+		_, uid, err := decodeEmailID(email.ID)
+		if err != nil {
+			continue
+		}
+		seqset.AddNum(uid)
+		starred = starred || email.IsStarred
+	}
+	if seqset.Empty() {
+		return "", nil, false, fmt.Errorf("thread not found")
+	}
+	return mailboxName, seqset, starred, nil
+}
+
+func (s *IMAPService) modifyThreadFlags(ctx context.Context, server string, port int, emailAddr, password, messageID string, flags []interface{}, add bool) error {
+	mailboxName, seqset, _, err := s.threadUIDs(ctx, server, port, emailAddr, password, messageID)
+	if err != nil {
+		return err
+	}
+
+	c, release, err := s.acquire(server, port, emailAddr, password)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if _, err := c.Select(mailboxName, false); err != nil {
+		return err
+	}
+
+	item := imap.FormatFlagsOp(imap.AddFlags, true)
+	if !add {
+		item = imap.FormatFlagsOp(imap.RemoveFlags, true)
+	}
+	return c.UidStore(seqset, item, flags, nil)
+}
+
+func (s *IMAPService) MarkThreadAsRead(ctx context.Context, server string, port int, emailAddr, password, messageID string) error {
+	return s.modifyThreadFlags(ctx, server, port, emailAddr, password, messageID, []interface{}{imap.SeenFlag}, true)
+}
+
+func (s *IMAPService) MarkThreadAsUnread(ctx context.Context, server string, port int, emailAddr, password, messageID string) error {
+	return s.modifyThreadFlags(ctx, server, port, emailAddr, password, messageID, []interface{}{imap.SeenFlag}, false)
+}
+
+// ToggleThreadStar unstars the whole thread if any message is starred, otherwise stars all of it
+func (s *IMAPService) ToggleThreadStar(ctx context.Context, server string, port int, emailAddr, password, messageID string) error {
+	_, _, starred, err := s.threadUIDs(ctx, server, port, emailAddr, password, messageID)
+	if err != nil {
+		return err
+	}
+	return s.modifyThreadFlags(ctx, server, port, emailAddr, password, messageID, []interface{}{imap.FlaggedFlag}, !starred)
+}
+
+// TrashThread moves every message of the thread to the trash
+func (s *IMAPService) TrashThread(ctx context.Context, server string, port int, emailAddr, password, messageID string) error {
+	mailboxName, seqset, _, err := s.threadUIDs(ctx, server, port, emailAddr, password, messageID)
+	if err != nil {
+		return err
+	}
+
+	c, release, err := s.acquire(server, port, emailAddr, password)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	account := accountKey(server, emailAddr)
+	targetMailboxName, err := s.findMoveTarget(c, account, "trash")
+	if err != nil {
+		return err
+	}
+	if _, err := s.selectMailbox(c, account, mailboxName, false); err != nil {
+		return err
+	}
+
+	if err := c.UidCopy(seqset, targetMailboxName); err != nil {
+		s.names.invalidate(account)
+		return err
+	}
+	item := imap.FormatFlagsOp(imap.AddFlags, true)
+	return c.UidStore(seqset, item, []interface{}{imap.DeletedFlag}, nil)
+}
@@ -0,0 +1,110 @@
+package imap
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap"
+)
+
+// addThread adds a three-message conversation and an unrelated message, and returns
+// the email IDs of the conversation followed by the unrelated one
+func addThread(ts *testServer) ([]string, string) {
+	message := func(id, subject, references string) string {
+		raw := "From: Alice <alice@example.com>\r\n" +
+			"To: username@example.com\r\n" +
+			"Subject: " + subject + "\r\n" +
+			"Date: Wed, 11 May 2016 14:31:59 +0000\r\n" +
+			"Message-ID: <" + id + ">\r\n"
+		if references != "" {
+			raw += "In-Reply-To: " + references + "\r\nReferences: " + references + "\r\n"
+		}
+		return raw + "Content-Type: text/plain\r\n\r\nHello"
+	}
+
+	start := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+	thread := []string{
+		ts.addMessage(message("root@example.com", "Lunch", ""), start),
+		ts.addMessage(message("reply1@example.com", "Re: Lunch", "<root@example.com>"), start.Add(time.Hour), imap.FlaggedFlag),
+		ts.addMessage(message("reply2@example.com", "Re: Lunch", "<root@example.com> <reply1@example.com>"), start.Add(2*time.Hour)),
+	}
+	other := ts.addMessage(message("other@example.com", "Invoice", ""), start, imap.SeenFlag)
+	return thread, other
+}
+
+// flagsOf fetches the thread containing id and reports each message's read and starred flags
+func flagsOf(t *testing.T, s *IMAPService, ts *testServer, id string) []string {
+	t.Helper()
+	emails, err := s.GetThread(context.Background(), ts.host, ts.port, testUser, testPassword, id)
+	if err != nil {
+		t.Fatalf("GetThread() error = %v", err)
+	}
+	var flags []string
+	for _, email := range emails {
+		flags = append(flags, fmt.Sprintf("read=%v starred=%v", email.IsRead, email.IsStarred))
+	}
+	return flags
+}
+
+func TestThreadActionsAffectEveryMessage(t *testing.T) {
+	ts := newTestServer(t)
+	thread, other := addThread(ts)
+	s := NewService()
+	ctx := context.Background()
+
+	if got := flagsOf(t, s, ts, thread[2]); len(got) != 3 {
+		t.Fatalf("thread has %d messages, want 3", len(got))
+	}
+
+	steps := []struct {
+		name   string
+		action func(ctx context.Context, server string, port int, emailAddr, password, messageID string) error
+		want   []string
+	}{
+		{"read", s.MarkThreadAsRead, []string{"read=true starred=false", "read=true starred=true", "read=true starred=false"}},
+		// One message is starred, so the toggle unstars the whole thread
+		{"unstar", s.ToggleThreadStar, []string{"read=true starred=false", "read=true starred=false", "read=true starred=false"}},
+		{"star", s.ToggleThreadStar, []string{"read=true starred=true", "read=true starred=true", "read=true starred=true"}},
+		{"unread", s.MarkThreadAsUnread, []string{"read=false starred=true", "read=false starred=true", "read=false starred=true"}},
+	}
+	for _, step := range steps {
+		// Any message of the thread names it
+		if err := step.action(ctx, ts.host, ts.port, testUser, testPassword, thread[1]); err != nil {
+			t.Fatalf("%s: error = %v", step.name, err)
+		}
+		if got := flagsOf(t, s, ts, thread[0]); !slices.Equal(got, step.want) {
+			t.Errorf("after %s the thread has %q, want %q", step.name, got, step.want)
+		}
+	}
+
+	if got := flagsOf(t, s, ts, other); len(got) != 1 || got[0] != "read=true starred=false" {
+		t.Errorf("unrelated message has %v, want it untouched", got)
+	}
+}
+
+func TestTrashThread(t *testing.T) {
+	ts := newTestServer(t)
+	trash := ts.createMailbox(t, "Trash")
+	thread, other := addThread(ts)
+	s := NewService()
+
+	if err := s.TrashThread(context.Background(), ts.host, ts.port, testUser, testPassword, thread[0]); err != nil {
+		t.Fatalf("TrashThread() error = %v", err)
+	}
+	if len(trash.Messages) != 3 {
+		t.Errorf("trash has %d messages, want the 3 of the thread", len(trash.Messages))
+	}
+	for _, msg := range ts.inbox.Messages {
+		deleted := false
+		for _, flag := range msg.Flags {
+			deleted = deleted || flag == imap.DeletedFlag
+		}
+		isOther := encodeEmailID("INBOX", msg.Uid) == other
+		if deleted == isOther {
+			t.Errorf("message %d deleted = %v, want only the thread's messages deleted", msg.Uid, deleted)
+		}
+	}
+}
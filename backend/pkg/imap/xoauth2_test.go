@@ -0,0 +1,31 @@
+package imap
+
+import "testing"
+
+func TestSplitSecret(t *testing.T) {
+	if password, token := splitSecret("hunter2"); password != "hunter2" || token != "" {
+		t.Errorf("password: splitSecret() = (%q, %q)", password, token)
+	}
+	if password, token := splitSecret(OAuthSecret("ya29.token")); password != "" || token != "ya29.token" {
+		t.Errorf("token: splitSecret() = (%q, %q)", password, token)
+	}
+}
+
+func TestGoogleGrantSecret(t *testing.T) {
+	if !IsGoogleGrantSecret(GoogleGrantSecret()) {
+		t.Error("the grant marker isn't recognized")
+	}
+	for _, secret := range []string{"", "hunter2", OAuthSecret("ya29.token"), OAuthSecret("")} {
+		if IsGoogleGrantSecret(secret) {
+			t.Errorf("IsGoogleGrantSecret(%q) = true", secret)
+		}
+	}
+}
+
+func TestXoauth2Response(t *testing.T) {
+	got := string(xoauth2Response("me@example.com", "ya29.token"))
+	want := "user=me@example.com\x01auth=Bearer ya29.token\x01\x01"
+	if got != want {
+		t.Errorf("xoauth2Response() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+package mailer
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"ga03-backend/pkg/utils/mailutil"
+)
+
+// Mailer sends the server's own mail, such as password reset links, through one
+// SMTP account. It has nothing to do with the mail accounts users connect.
+type Mailer struct {
+	server   string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+// New creates a Mailer. Port 465 uses implicit TLS, other ports STARTTLS when the
+// server offers it.
+func New(server string, port int, username, password, from string) *Mailer {
+	if from == "" {
+		from = username
+	}
+	return &Mailer{server: server, port: port, username: username, password: password, from: from}
+}
+
+// Configured reports whether there is a server to send through
+func (m *Mailer) Configured() bool {
+	return m.server != "" && m.from != ""
+}
+
+// Send sends a plain text message
+func (m *Mailer) Send(to, subject, body string) error {
+	if !m.Configured() {
+		return fmt.Errorf("mailer is not configured")
+	}
+
+	var msg strings.Builder
+	msg.WriteString("From: " + m.from + "\r\n")
+	msg.WriteString("To: " + to + "\r\n")
+	msg.WriteString("Subject: " + subject + "\r\n")
+	msg.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("Message-ID: " + mailutil.NewMessageID(m.from) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=\"UTF-8\"\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	addr := net.JoinHostPort(m.server, strconv.Itoa(m.port))
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.server)
+	}
+	if m.port != 465 {
+		return smtp.SendMail(addr, auth, m.from, []string{to}, []byte(msg.String()))
+	}
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: m.server})
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	c, err := smtp.NewClient(conn, m.server)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	defer c.Close()
+	if auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+	if err := c.Mail(m.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(msg.String())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,341 @@
+package pdf
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// maxImages caps the images drawn from one HTML body; the rest keep their alt text
+const maxImages = 30
+
+// indentStep is how far blockquotes and lists are indented, in points
+const indentStep = 18.0
+
+var headingSizes = map[atom.Atom]float64{
+	atom.H1: 18,
+	atom.H2: 16,
+	atom.H3: 14,
+	atom.H4: 12,
+	atom.H5: 11,
+	atom.H6: 10,
+}
+
+// paragraphs are blocks set off by a gap above and below
+var paragraphs = map[atom.Atom]bool{
+	atom.P: true, atom.Blockquote: true, atom.Ul: true, atom.Ol: true, atom.Dl: true,
+	atom.Table: true, atom.Pre: true, atom.Figure: true,
+}
+
+// blocks start on a line of their own
+var blocks = map[atom.Atom]bool{
+	atom.Div: true, atom.Section: true, atom.Article: true, atom.Header: true, atom.Footer: true,
+	atom.Main: true, atom.Nav: true, atom.Aside: true, atom.Center: true, atom.Address: true,
+	atom.Tr: true, atom.Li: true, atom.Dt: true, atom.Dd: true, atom.Caption: true,
+	atom.Figcaption: true, atom.Details: true, atom.Summary: true,
+}
+
+// ImageLoader returns the data of an image an HTML body refers to by src. On an error
+// the image is left out and its alt text shown instead.
+type ImageLoader func(src string) ([]byte, error)
+
+// HTML writes an HTML body: paragraphs, headings, lists, quotes, tables row by row,
+// bold, underlined and linked text, and the images load returns. It expects markup
+// that has been through mailutil.SanitizeHTML; CSS is ignored. A nil load shows alt
+// text for every image.
+func (d *Document) HTML(body string, load ImageLoader) {
+	root, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		d.Text(body)
+		return
+	}
+
+	r := &htmlRenderer{doc: d, load: load, size: textSize, lineStart: true, gap: true}
+	r.walk(root)
+	r.newline()
+	d.f.SetFont(fontFamily, "", textSize)
+	d.f.SetTextColor(0, 0, 0)
+}
+
+// htmlRenderer flows the nodes of an HTML tree onto the document
+type htmlRenderer struct {
+	doc  *Document
+	load ImageLoader
+
+	bold      int
+	underline int
+	pre       int
+	size      float64
+	link      string
+	indent    float64
+	lists     []int // Next number of each open ordered list, 0 for an unordered one
+	images    int
+
+	lineStart bool // Nothing is on the current line yet
+	space     bool // Collapsed whitespace is waiting to be written before the next word
+	gap       bool // The last thing written was a paragraph gap
+}
+
+func (r *htmlRenderer) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		r.text(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		r.children(n)
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Head, atom.Style, atom.Script, atom.Title, atom.Template, atom.Noscript:
+		return
+	case atom.Br:
+		r.lineBreak()
+		return
+	case atom.Hr:
+		r.newline()
+		r.doc.Rule()
+		r.gap = false
+		return
+	case atom.Img:
+		r.image(n)
+		return
+	}
+
+	switch {
+	case headingSizes[n.DataAtom] > 0:
+		r.paragraph()
+		size := r.size
+		r.size = headingSizes[n.DataAtom]
+		r.bold++
+		r.children(n)
+		r.newline()
+		r.bold--
+		r.size = size
+		r.paragraph()
+	case paragraphs[n.DataAtom]:
+		r.paragraph()
+		r.container(n)
+		r.paragraph()
+	case blocks[n.DataAtom]:
+		r.newline()
+		r.container(n)
+		r.newline()
+	default:
+		r.inline(n)
+	}
+}
+
+func (r *htmlRenderer) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.walk(c)
+	}
+}
+
+// container writes a block element's content, with the indent, list marker or
+// preformatting its tag calls for
+func (r *htmlRenderer) container(n *html.Node) {
+	switch n.DataAtom {
+	case atom.Blockquote, atom.Dd:
+		r.setIndent(r.indent + indentStep)
+		r.children(n)
+		r.newline()
+		r.setIndent(r.indent - indentStep)
+	case atom.Ul, atom.Ol:
+		next := 0
+		if n.DataAtom == atom.Ol {
+			next = 1
+			if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+				next = start
+			}
+		}
+		r.lists = append(r.lists, next)
+		r.setIndent(r.indent + indentStep)
+		r.children(n)
+		r.newline()
+		r.setIndent(r.indent - indentStep)
+		r.lists = r.lists[:len(r.lists)-1]
+	case atom.Li:
+		marker := "•"
+		if len(r.lists) > 0 {
+			if next := r.lists[len(r.lists)-1]; next > 0 {
+				marker = strconv.Itoa(next) + "."
+				r.lists[len(r.lists)-1]++
+			}
+		}
+		r.write(marker + " ")
+		r.children(n)
+	case atom.Pre:
+		r.pre++
+		r.children(n)
+		r.pre--
+	default:
+		r.children(n)
+	}
+}
+
+// inline writes an inline element's content in the style its tag calls for
+func (r *htmlRenderer) inline(n *html.Node) {
+	switch n.DataAtom {
+	case atom.B, atom.Strong, atom.Th:
+		r.bold++
+		defer func() { r.bold-- }()
+	case atom.U, atom.Ins:
+		r.underline++
+		defer func() { r.underline-- }()
+	case atom.A:
+		if href := attr(n, "href"); linkable(href) {
+			link := r.link
+			r.link = href
+			defer func() { r.link = link }()
+		}
+	}
+
+	// Cells of a row are written one after another, a few spaces apart
+	if (n.DataAtom == atom.Td || n.DataAtom == atom.Th) && !r.lineStart {
+		r.write("   ")
+		r.space = false
+	}
+	r.children(n)
+}
+
+// text writes a text node, collapsing its whitespace as a browser would outside <pre>
+func (r *htmlRenderer) text(s string) {
+	if r.pre > 0 {
+		for i, line := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+			if i > 0 {
+				r.lineBreak()
+			}
+			if line != "" {
+				r.write(line)
+			}
+		}
+		return
+	}
+
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		if s != "" {
+			r.space = true
+		}
+		return
+	}
+	text := strings.Join(words, " ")
+	if r.space || strings.TrimLeft(s, " \t\r\n\f") != s {
+		text = " " + text
+	}
+	if r.lineStart {
+		text = strings.TrimLeft(text, " ")
+	}
+	r.write(text)
+	r.space = strings.TrimRight(s, " \t\r\n\f") != s
+}
+
+func (r *htmlRenderer) write(text string) {
+	style := ""
+	if r.bold > 0 {
+		style += "B"
+	}
+	if r.underline > 0 || r.link != "" {
+		style += "U"
+	}
+	f := r.doc.f
+	f.SetFont(fontFamily, style, r.size)
+	if r.link != "" {
+		f.SetTextColor(17, 85, 204)
+		f.WriteLinkString(r.lineHeight(), clean(text), r.link)
+		f.SetTextColor(0, 0, 0)
+	} else {
+		f.Write(r.lineHeight(), clean(text))
+	}
+	r.lineStart, r.space, r.gap = false, false, false
+}
+
+func (r *htmlRenderer) lineHeight() float64 {
+	return r.size * lineFactor
+}
+
+// newline ends the current line, if anything is on it
+func (r *htmlRenderer) newline() {
+	if !r.lineStart {
+		r.doc.f.Ln(r.lineHeight())
+		r.lineStart = true
+	}
+	r.space = false
+}
+
+// lineBreak is a <br>: it ends the current line, or adds an empty one
+func (r *htmlRenderer) lineBreak() {
+	r.doc.f.Ln(r.lineHeight())
+	r.lineStart, r.space, r.gap = true, false, false
+}
+
+// paragraph ends the current line and leaves a gap, unless there is one already
+func (r *htmlRenderer) paragraph() {
+	r.newline()
+	if !r.gap {
+		r.doc.f.Ln(textSize * 0.6)
+		r.gap = true
+	}
+}
+
+func (r *htmlRenderer) setIndent(indent float64) {
+	r.indent = indent
+	r.doc.f.SetLeftMargin(margin + indent)
+	if r.lineStart {
+		r.doc.f.SetX(margin + indent)
+	}
+}
+
+// image draws an <img> on its own lines, or writes its alt text when there's no loader,
+// the image can't be loaded, or the body already had maxImages
+func (r *htmlRenderer) image(n *html.Node) {
+	src := strings.TrimSpace(attr(n, "src"))
+	if src != "" && r.load != nil && r.images < maxImages {
+		if data, err := r.load(src); err == nil {
+			// CSS pixels are 3/4 of a point
+			width := pixels(attr(n, "width")) * 0.75
+			height := pixels(attr(n, "height")) * 0.75
+			// Tracking pixels and spacers take no room
+			if (width > 0 && width <= 2) || (height > 0 && height <= 2) {
+				return
+			}
+			r.newline()
+			if r.doc.image(data, width, height) {
+				r.images++
+				r.lineStart, r.space, r.gap = true, false, false
+				return
+			}
+		}
+	}
+	if alt := strings.TrimSpace(attr(n, "alt")); alt != "" {
+		r.text(" [" + alt + "] ")
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// pixels parses a width or height attribute, which may end in "px"
+func pixels(value string) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "px"), 64)
+	if err != nil || v < 0 {
+		return 0
+	}
+	return v
+}
+
+// linkable reports whether href can be a link in the PDF
+func linkable(href string) bool {
+	lower := strings.ToLower(strings.TrimSpace(href))
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "mailto:")
+}
@@ -0,0 +1,223 @@
+// Package pdf renders documents such as exported emails as PDF. Text is set in DejaVu
+// Sans, embedded in the binary, which covers Latin (Vietnamese included), Greek and
+// Cyrillic; characters the font lacks, such as CJK, show as its missing-glyph box.
+package pdf
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+)
+
+//go:embed fonts/DejaVuSans.ttf
+var regularFont []byte
+
+//go:embed fonts/DejaVuSans-Bold.ttf
+var boldFont []byte
+
+const (
+	fontFamily = "DejaVu"
+	// Margins of an A4 page, in points
+	margin = 50.0
+	// Body text size, in points
+	textSize   = 10.0
+	lineFactor = 1.35
+	// maxImagePixels keeps a small file that decodes into a huge bitmap out
+	maxImagePixels = 4000 * 4000
+)
+
+// Document is a multi-page document. Content is laid out top to bottom, wraps at the
+// right margin and continues on a new page when the bottom margin is reached.
+type Document struct {
+	f      *fpdf.Fpdf
+	images int // Images registered so far, which names the next one
+}
+
+func NewDocument() *Document {
+	f := fpdf.New("P", "pt", "A4", "")
+	f.SetMargins(margin, margin, margin)
+	f.SetAutoPageBreak(true, margin)
+	f.AddUTF8FontFromBytes(fontFamily, "", regularFont)
+	f.AddUTF8FontFromBytes(fontFamily, "B", boldFont)
+	f.SetFont(fontFamily, "", textSize)
+	d := &Document{f: f}
+	d.NewPage()
+	return d
+}
+
+// NewPage starts a new page, unless the current one is still empty
+func (d *Document) NewPage() {
+	if d.f.PageNo() > 0 && d.f.GetX() == margin && d.f.GetY() == margin {
+		return
+	}
+	d.f.AddPage()
+}
+
+// Heading writes a bold paragraph in a larger size
+func (d *Document) Heading(text string) {
+	d.f.SetFont(fontFamily, "B", 14)
+	d.f.MultiCell(0, 14*lineFactor, clean(text), "", "L", false)
+	d.f.SetFont(fontFamily, "", textSize)
+	d.Space(4)
+}
+
+// Field writes a "label: value" header line
+func (d *Document) Field(label, value string) {
+	d.f.SetFont(fontFamily, "", textSize)
+	d.f.MultiCell(0, textSize*lineFactor, clean(label+": "+value), "", "L", false)
+}
+
+// Text writes plain text, keeping its line breaks
+func (d *Document) Text(text string) {
+	d.f.SetFont(fontFamily, "", textSize)
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		d.f.Write(textSize*lineFactor, clean(line))
+		d.f.Ln(textSize * lineFactor)
+	}
+}
+
+// Space adds vertical space below the current line
+func (d *Document) Space(points float64) {
+	d.f.Ln(points)
+}
+
+// Rule draws a horizontal line across the text area
+func (d *Document) Rule() {
+	pageWidth, pageHeight := d.f.GetPageSize()
+	y := d.f.GetY() + 4
+	if y+6 > pageHeight-margin {
+		d.f.AddPage()
+		y = margin
+	}
+	d.f.SetDrawColor(153, 153, 153)
+	d.f.Line(margin, y, pageWidth-margin, y)
+	d.f.SetDrawColor(0, 0, 0)
+	d.f.SetY(y + 6)
+}
+
+// Bytes renders the document
+func (d *Document) Bytes() ([]byte, error) {
+	var out bytes.Buffer
+	if err := d.f.Output(&out); err != nil {
+		return nil, fmt.Errorf("failed to render pdf: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// image places a JPEG, PNG or GIF on its own lines, at the given size in points or,
+// when that's zero, at its own size as CSS pixels. It shrinks to fit the text area.
+// It reports whether the data was an image it could draw.
+func (d *Document) image(data []byte, width, height float64) bool {
+	name, info := d.register(data)
+	if info == nil {
+		return false
+	}
+
+	// CSS pixels are 3/4 of a point
+	natural, naturalHeight := info.Width()*0.75, info.Height()*0.75
+	switch {
+	case width > 0 && height > 0:
+	case width > 0:
+		height = naturalHeight * width / natural
+	case height > 0:
+		width = natural * height / naturalHeight
+	default:
+		width, height = natural, naturalHeight
+	}
+
+	left, _, right, _ := d.f.GetMargins()
+	pageWidth, pageHeight := d.f.GetPageSize()
+	if maxWidth := pageWidth - left - right; width > maxWidth {
+		height *= maxWidth / width
+		width = maxWidth
+	}
+	if maxHeight := pageHeight - 2*margin; height > maxHeight {
+		width *= maxHeight / height
+		height = maxHeight
+	}
+
+	y := d.f.GetY()
+	if y+height > pageHeight-margin {
+		d.f.AddPage()
+		y = d.f.GetY()
+	}
+	d.f.ImageOptions(name, left, y, width, height, false, fpdf.ImageOptions{}, 0, "")
+	d.f.SetY(y + height + 4)
+	return true
+}
+
+// register adds an image to the document and returns its name, or nil info when the
+// data isn't an image fpdf can embed
+func (d *Document) register(data []byte) (string, *fpdf.ImageInfoType) {
+	if d.f.Err() {
+		return "", nil
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width*cfg.Height > maxImagePixels {
+		return "", nil
+	}
+
+	if format != "png" || plainPNG(data) {
+		if name, info := d.add(format, data); info != nil {
+			return name, info
+		}
+	}
+
+	// fpdf reads PNGs only when they are 8-bit and not interlaced, so other PNGs, and
+	// anything it rejects, are redrawn as one
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", nil
+	}
+	flat := image.NewNRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Src)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, flat); err != nil {
+		return "", nil
+	}
+	return d.add("png", buf.Bytes())
+}
+
+// add registers image data of the given format. A failure is cleared, as fpdf would
+// otherwise fail the whole document over one image.
+func (d *Document) add(format string, data []byte) (string, *fpdf.ImageInfoType) {
+	d.images++
+	name := fmt.Sprintf("img%d", d.images)
+	info := d.f.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: format}, bytes.NewReader(data))
+	if d.f.Err() {
+		d.f.ClearError()
+		return "", nil
+	}
+	return name, info
+}
+
+// plainPNG reports whether a PNG has 8-bit or smaller channels and no interlacing,
+// going by the IHDR chunk that follows the signature
+func plainPNG(data []byte) bool {
+	const bitDepth, interlace = 24, 28
+	return len(data) > interlace && data[bitDepth] <= 8 && data[interlace] == 0
+}
+
+// clean prepares text for the font: tabs become spaces, other control characters are
+// dropped, and characters outside the Basic Multilingual Plane, which the embedded
+// font can't map, become U+FFFD
+func clean(text string) string {
+	text = strings.ReplaceAll(text, "\t", "    ")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r < 32 || r == 127:
+			return -1
+		case r > 0xFFFF:
+			return '\uFFFD'
+		}
+		return r
+	}, text)
+}
@@ -0,0 +1,236 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// render returns the document uncompressed, so its text can be searched
+func render(t *testing.T, doc *Document) []byte {
+	t.Helper()
+	doc.f.SetCompression(false)
+	out, err := doc.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// shown is text as the embedded font writes it: a UTF-16 string in parentheses
+func shown(text string) []byte {
+	var b bytes.Buffer
+	for _, u := range utf16.Encode([]rune(text)) {
+		for _, c := range []byte{byte(u >> 8), byte(u)} {
+			if c == '(' || c == ')' || c == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(c)
+		}
+	}
+	return b.Bytes()
+}
+
+func pngImage(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBytesWellFormed(t *testing.T) {
+	doc := NewDocument()
+	doc.Heading("Lunch")
+	doc.Field("From", "alice@example.com")
+	doc.Rule()
+	doc.Text("Noon at the usual place?")
+	out := render(t, doc)
+
+	if !bytes.HasPrefix(out, []byte("%PDF-")) || !bytes.HasSuffix(bytes.TrimSpace(out), []byte("%%EOF")) {
+		t.Fatalf("missing PDF header or trailer")
+	}
+	for _, want := range []string{"Lunch", "From: alice@example.com", "Noon at the usual place?"} {
+		if !bytes.Contains(out, shown(want)) {
+			t.Errorf("document lacks %q", want)
+		}
+	}
+	if !bytes.Contains(out, []byte("/Count 1")) {
+		t.Error("document isn't a single page")
+	}
+	if !bytes.Contains(out, []byte("/FontFile2")) {
+		t.Error("the font isn't embedded")
+	}
+}
+
+func TestUnicodeText(t *testing.T) {
+	tests := []string{
+		"Chào bạn, hẹn gặp lúc 12 giờ",
+		"Đường đến trường",
+		"Привет, Ελληνικά",
+		"“Quoted” – it’s fine",
+	}
+	for _, text := range tests {
+		doc := NewDocument()
+		doc.Text(text)
+		if out := render(t, doc); !bytes.Contains(out, shown(text)) {
+			t.Errorf("document doesn't show %q as written", text)
+		}
+	}
+}
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Hẹn gặp", "Hẹn gặp"},
+		{"a\tb", "a    b"},
+		{"bell\a", "bell"},
+		{"party 🎉", "party \uFFFD"},
+	}
+	for _, tt := range tests {
+		if got := clean(tt.text); got != tt.want {
+			t.Errorf("clean(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestPagination(t *testing.T) {
+	doc := NewDocument()
+	doc.NewPage()
+	if n := doc.f.PageCount(); n != 1 {
+		t.Errorf("NewPage on an empty page made %d pages, want 1", n)
+	}
+
+	doc.Text(strings.Repeat("line\n", 100))
+	pages := doc.f.PageCount()
+	if pages < 2 {
+		t.Fatalf("100 lines fit on %d page, want them to overflow", pages)
+	}
+	doc.NewPage()
+	if n := doc.f.PageCount(); n != pages+1 {
+		t.Errorf("NewPage after text made %d pages, want %d", n, pages+1)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	doc := NewDocument()
+	doc.HTML(`<html><head><title>Ignored title</title></head><body>
+		<h1>Agenda</h1>
+		<p>Hello <b>team</b>,<br>see   the
+		list:</p>
+		<ol><li>Budget</li><li>Hiring</li></ol>
+		<ul><li>Snacks</li></ul>
+		<blockquote>Quoted reply</blockquote>
+		<table><tr><td>Room</td><td>4B</td></tr></table>
+		<pre>  two  spaces</pre>
+		<p><a href="https://example.com/notes">the notes</a></p>
+	</body></html>`, nil)
+	out := render(t, doc)
+
+	for _, want := range []string{"Agenda", "Hello", " team", "see the list:", "1. ", "Budget", "2. ", "Hiring", "• ", "Quoted reply", "Room", "4B", "  two  spaces", "the notes"} {
+		if !bytes.Contains(out, shown(want)) {
+			t.Errorf("document lacks %q", want)
+		}
+	}
+	if bytes.Contains(out, shown("Ignored title")) {
+		t.Error("the head was written")
+	}
+	if !bytes.Contains(out, []byte("/URI (https://example.com/notes)")) {
+		t.Error("the link isn't clickable")
+	}
+}
+
+func TestHTMLImages(t *testing.T) {
+	logo := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		logo.Set(x, 5, color.RGBA{R: 200, A: 255})
+	}
+	var photo bytes.Buffer
+	if err := jpeg.Encode(&photo, logo, nil); err != nil {
+		t.Fatal(err)
+	}
+	// fpdf can't read 16-bit PNGs itself, so this one is redrawn
+	deep := image.NewRGBA64(image.Rect(0, 0, 10, 10))
+
+	images := map[string][]byte{
+		"cid:logo":             pngImage(t, logo),
+		"cid:photo":            photo.Bytes(),
+		"cid:deep":             pngImage(t, deep),
+		"cid:broken":           []byte("not an image"),
+		"https://t.example/px": pngImage(t, image.NewRGBA(image.Rect(0, 0, 1, 1))),
+	}
+	var loaded []string
+	load := func(src string) ([]byte, error) {
+		loaded = append(loaded, src)
+		if data, ok := images[src]; ok {
+			return data, nil
+		}
+		return nil, errors.New("blocked")
+	}
+
+	doc := NewDocument()
+	doc.HTML(`<p>Logo: <img src="cid:logo" alt="Logo"></p>
+		<img src="cid:photo" width="200">
+		<img src="cid:deep">
+		<img src="cid:broken" alt="Broken">
+		<img src="https://remote.example/banner.png" alt="Banner">
+		<img src="https://t.example/px" width="1" height="1" alt="Pixel">`, load)
+	out := render(t, doc)
+
+	// Each drawn image is painted with one Do; a transparent one also adds a mask object
+	if n := bytes.Count(out, []byte(" Do Q")); n != 3 {
+		t.Errorf("document has %d images, want the logo, photo and 16-bit PNG", n)
+	}
+	for _, want := range []string{"[Broken]", "[Banner]"} {
+		if !bytes.Contains(out, shown(want)) {
+			t.Errorf("document lacks the alt text %q", want)
+		}
+	}
+	for _, unwanted := range []string{"[Logo]", "[Pixel]"} {
+		if bytes.Contains(out, shown(unwanted)) {
+			t.Errorf("document shows the alt text %q of an image it drew or skipped", unwanted)
+		}
+	}
+	if len(loaded) != 6 {
+		t.Errorf("loaded %v, want every image asked for", loaded)
+	}
+}
+
+func TestHTMLWithoutLoader(t *testing.T) {
+	doc := NewDocument()
+	doc.HTML(`<p>Before <img src="cid:logo" alt="Logo"> after</p>`, nil)
+	out := render(t, doc)
+
+	if bytes.Contains(out, []byte(" Do Q")) {
+		t.Error("an image was drawn without a loader")
+	}
+	if !bytes.Contains(out, shown("[Logo]")) {
+		t.Error("the alt text is missing")
+	}
+}
+
+func TestPlainPNG(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"8-bit", pngImage(t, image.NewRGBA(image.Rect(0, 0, 2, 2))), true},
+		{"16-bit", pngImage(t, image.NewRGBA64(image.Rect(0, 0, 2, 2))), false},
+		{"truncated", []byte("\x89PNG\r\n"), false},
+	}
+	for _, tt := range tests {
+		if got := plainPNG(tt.data); got != tt.want {
+			t.Errorf("plainPNG(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
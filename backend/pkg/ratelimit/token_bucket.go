@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Limiter is a token-bucket rate limiter with a bucket per key. A bucket holds up to
+// limit tokens and refills at limit per window, so a key can burst limit requests at
+// once and then continues at the refill rate.
+type Limiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens   *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewLimiter creates a limiter. A limit <= 0 disables limiting.
+func NewLimiter(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		limit:     limit,
+		window:    window,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from key's bucket if it has one. When it doesn't, it returns
+// false and how long until the next token is added.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l.limit <= 0 || l.window <= 0 {
+		return true, 0
+	}
+
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: rate.NewLimiter(rate.Every(l.window/time.Duration(l.limit)), l.limit)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	// A rejected request takes no token, so retrying early doesn't push the wait back
+	if tokens := b.tokens.TokensAt(now); tokens < 1 {
+		wait := time.Duration((1 - tokens) / float64(b.tokens.Limit()) * float64(time.Second))
+		return false, wait
+	}
+	b.tokens.AllowN(now, 1)
+	return true, 0
+}
+
+// LimitError is returned by code that enforces a Limiter itself rather than through
+// middleware. It says when the caller may try again.
+type LimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return "too many requests, please slow down"
+}
+
+// sweep drops idle buckets once per window so the map doesn't grow without bound.
+// A bucket unused for a whole window has refilled, so dropping it changes nothing.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) >= l.window {
+			delete(l.buckets, key)
+		}
+	}
+}
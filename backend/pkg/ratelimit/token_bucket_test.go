@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterTokenBucket(t *testing.T) {
+	// Two tokens, refilled at one per 50ms
+	l := NewLimiter(2, 100*time.Millisecond)
+
+	for i := range 2 {
+		if ok, _ := l.Allow("u1"); !ok {
+			t.Fatalf("request %d of the burst was throttled", i+1)
+		}
+	}
+	ok, retryAfter := l.Allow("u1")
+	if ok {
+		t.Fatal("the request past the burst was allowed")
+	}
+	if retryAfter <= 0 || retryAfter > 50*time.Millisecond {
+		t.Errorf("retryAfter = %v, want at most the time to refill one token", retryAfter)
+	}
+
+	// Keys are throttled independently
+	if ok, _ := l.Allow("u2"); !ok {
+		t.Error("another key was throttled")
+	}
+
+	// One token comes back, not the whole burst
+	time.Sleep(retryAfter + 5*time.Millisecond)
+	if ok, _ := l.Allow("u1"); !ok {
+		t.Error("still throttled after a token was refilled")
+	}
+	if ok, _ := l.Allow("u1"); ok {
+		t.Error("a second request was allowed after refilling one token")
+	}
+
+	// An idle key refills up to the full burst
+	time.Sleep(110 * time.Millisecond)
+	for i := range 2 {
+		if ok, _ := l.Allow("u1"); !ok {
+			t.Fatalf("request %d of the refilled burst was throttled", i+1)
+		}
+	}
+}
+
+func TestLimiterRejectedRequestsDontCount(t *testing.T) {
+	l := NewLimiter(1, 200*time.Millisecond)
+	l.Allow("u1")
+	time.Sleep(50 * time.Millisecond)
+	if ok, _ := l.Allow("u1"); ok {
+		t.Fatal("the second request was allowed")
+	}
+
+	// The token is back 200ms after the first request, however often it was retried
+	time.Sleep(160 * time.Millisecond)
+	if ok, _ := l.Allow("u1"); !ok {
+		t.Error("a throttled request delayed the refill")
+	}
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	for _, l := range []*Limiter{NewLimiter(0, time.Minute), NewLimiter(5, 0)} {
+		for range 10 {
+			if ok, _ := l.Allow("u1"); !ok {
+				t.Fatalf("a disabled limiter (limit %d, window %v) throttled a request", l.limit, l.window)
+			}
+		}
+	}
+}
+
+func TestLimiterSweepsIdleKeys(t *testing.T) {
+	l := NewLimiter(1, 10*time.Millisecond)
+	l.Allow("u1")
+	time.Sleep(15 * time.Millisecond)
+	l.Allow("u2")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.buckets["u1"]; ok {
+		t.Error("the idle key was kept after its bucket refilled")
+	}
+}
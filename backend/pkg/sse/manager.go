@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,16 +20,57 @@ type Event struct {
 type Client struct {
 	UserID string
 	Send   chan []byte
+
+	// done is closed when the client is removed. Send itself is never closed, so a
+	// delivery racing the removal can't panic and needs no lock on the manager.
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newClient(userID string, buffer int) *Client {
+	return &Client{
+		UserID: userID,
+		Send:   make(chan []byte, buffer),
+		done:   make(chan struct{}),
+	}
 }
 
+// close ends the client's stream; closing it again is a no-op
+func (c *Client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// Overflow policies for when a client's Send buffer is full
+const (
+	OverflowDropClient       = "drop_client"        // Disconnect the client
+	OverflowDropOldest       = "drop_oldest"        // Discard the oldest queued event to make room
+	OverflowBlockWithTimeout = "block_with_timeout" // Wait up to sendTimeout, then drop the client
+)
+
+// clientBuffer is how many events a stream can fall behind before the overflow policy applies
+const clientBuffer = 256
+
 // Manager manages SSE connections
 type Manager struct {
-	clients    map[*Client]bool
+	clients     map[*Client]bool
 	userClients map[string][]*Client // Map userID to list of clients (multiple tabs/devices)
-	register   chan *Client
-	unregister chan *Client
-	broadcast  chan *BroadcastMessage
-	mutex      sync.RWMutex
+	register    chan *Client
+	unregister  chan *Client
+	broadcast   chan *BroadcastMessage
+	workers     chan struct{}                  // Semaphore bounding concurrent broadcast deliveries
+	queues      map[string][]*BroadcastMessage // Per user broadcasts waiting for delivery; present while being drained
+	queueMu     sync.Mutex
+	overflow    string
+	sendTimeout time.Duration
+	heartbeat   time.Duration
+	maxPerUser  int
+	mutex       sync.RWMutex
+	done        chan struct{} // Closed by Shutdown to end every open stream
+	closeOnce   sync.Once
+	onFirst     func(userID string) // See OnPresence
+	onLast      func(userID string)
 }
 
 type BroadcastMessage struct {
@@ -36,14 +78,37 @@ type BroadcastMessage struct {
 	Message []byte
 }
 
-// NewManager creates a new SSE manager
-func NewManager() *Manager {
+// NewManager creates a new SSE manager.
+// broadcastWorkers limits how many broadcasts are delivered concurrently, overflowPolicy
+// selects what happens when a client's buffer is full (defaults to drop_oldest) and
+// sendTimeout bounds the wait for block_with_timeout. Idle streams get a comment line
+// every heartbeat so proxies don't close them; 0 disables it. A user gets at most
+// maxPerUser streams, connecting past that closes their oldest one; 0 is unlimited.
+func NewManager(broadcastWorkers int, overflowPolicy string, sendTimeout, heartbeat time.Duration, maxPerUser int) *Manager {
+	if broadcastWorkers <= 0 {
+		broadcastWorkers = 1
+	}
+	switch overflowPolicy {
+	case OverflowDropClient, OverflowDropOldest, OverflowBlockWithTimeout:
+	default:
+		if overflowPolicy != "" {
+			log.Printf("Unknown SSE overflow policy %q, using %s", overflowPolicy, OverflowDropOldest)
+		}
+		overflowPolicy = OverflowDropOldest
+	}
 	return &Manager{
 		clients:     make(map[*Client]bool),
 		userClients: make(map[string][]*Client),
 		register:    make(chan *Client),
 		unregister:  make(chan *Client),
 		broadcast:   make(chan *BroadcastMessage),
+		workers:     make(chan struct{}, broadcastWorkers),
+		queues:      make(map[string][]*BroadcastMessage),
+		overflow:    overflowPolicy,
+		sendTimeout: sendTimeout,
+		heartbeat:   heartbeat,
+		maxPerUser:  maxPerUser,
+		done:        make(chan struct{}),
 	}
 }
 
@@ -53,57 +118,213 @@ func (m *Manager) Run() {
 		select {
 		case client := <-m.register:
 			m.mutex.Lock()
+			first := len(m.userClients[client.UserID]) == 0
+			// A client reconnecting in a loop can't pile up streams; removing the oldest
+			// one ends its ServeHTTP
+			for m.maxPerUser > 0 && len(m.userClients[client.UserID]) >= m.maxPerUser {
+				log.Printf("Too many connections for %s, closing the oldest", client.UserID)
+				m.removeClientLocked(m.userClients[client.UserID][0])
+			}
 			m.clients[client] = true
 			m.userClients[client.UserID] = append(m.userClients[client.UserID], client)
+			total := len(m.clients)
 			m.mutex.Unlock()
-			log.Printf("Client connected: %s", client.UserID)
+			log.Printf("Client connected: %s (%d open)", client.UserID, total)
+			if first && m.onFirst != nil {
+				m.onFirst(client.UserID)
+			}
 
 		case client := <-m.unregister:
 			m.mutex.Lock()
-			if _, ok := m.clients[client]; ok {
-				delete(m.clients, client)
-				close(client.Send)
-				
-				// Remove from userClients
-				clients := m.userClients[client.UserID]
-				for i, c := range clients {
-					if c == client {
-						m.userClients[client.UserID] = append(clients[:i], clients[i+1:]...)
-						break
-					}
-				}
-				if len(m.userClients[client.UserID]) == 0 {
-					delete(m.userClients, client.UserID)
-				}
-			}
+			last := m.removeClientLocked(client)
+			total := len(m.clients)
 			m.mutex.Unlock()
-			log.Printf("Client disconnected: %s", client.UserID)
+			log.Printf("Client disconnected: %s (%d open)", client.UserID, total)
+			if last && m.onLast != nil {
+				m.onLast(client.UserID)
+			}
 
 		case message := <-m.broadcast:
 			m.mutex.RLock()
-			clients, ok := m.userClients[message.UserID]
+			connected := len(m.userClients[message.UserID]) > 0
 			m.mutex.RUnlock()
-			
-			if ok {
-				for _, client := range clients {
-					select {
-					case client.Send <- message.Message:
-					default:
-						close(client.Send)
-						delete(m.clients, client)
-					}
-				}
+
+			if connected {
+				m.enqueue(message)
 			}
 		}
 	}
 }
 
+// enqueue queues a broadcast behind the user's earlier ones. One goroutine at a time
+// delivers a user's queue, so their events arrive in the order they were sent, and it
+// waits for a worker slot itself, so busy workers never block the Run loop.
+func (m *Manager) enqueue(message *BroadcastMessage) {
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+
+	pending, draining := m.queues[message.UserID]
+	if len(pending) >= clientBuffer {
+		log.Printf("Dropping queued event for %s, too many pending", message.UserID)
+		pending = pending[1:]
+	}
+	m.queues[message.UserID] = append(pending, message)
+	if !draining {
+		go m.drain(message.UserID)
+	}
+}
+
+// drain delivers a user's queued broadcasts in order until the queue is empty. The
+// worker slot is taken per message so one slow user can't hold it for their whole queue.
+func (m *Manager) drain(userID string) {
+	for {
+		m.queueMu.Lock()
+		pending := m.queues[userID]
+		if len(pending) == 0 {
+			delete(m.queues, userID)
+			m.queueMu.Unlock()
+			return
+		}
+		message := pending[0]
+		m.queues[userID] = pending[1:]
+		m.queueMu.Unlock()
+
+		m.mutex.RLock()
+		clients := append([]*Client(nil), m.userClients[userID]...)
+		m.mutex.RUnlock()
+
+		m.workers <- struct{}{}
+		m.deliver(clients, message.Message)
+		<-m.workers
+	}
+}
+
+// deliver sends a message to each client. Clients that can't keep up under the
+// configured overflow policy are considered too slow and are dropped. No manager lock
+// is held while sending, so waiting on a slow client never stalls connects and disconnects.
+func (m *Manager) deliver(clients []*Client, message []byte) {
+	var slow []*Client
+	for _, client := range clients {
+		if !m.send(client, message) {
+			slow = append(slow, client)
+		}
+	}
+
+	if len(slow) == 0 {
+		return
+	}
+
+	// Removal goes through the unregister path so the Run loop stays the single owner
+	// of the client maps. Sending from a separate goroutine avoids deadlocking with Run
+	// while it waits for a free worker slot.
+	for _, client := range slow {
+		log.Printf("Dropping slow client: %s", client.UserID)
+		go func(client *Client) {
+			m.unregister <- client
+		}(client)
+	}
+}
+
+// send enqueues a message for a client, applying the overflow policy when its buffer is full.
+// Returns false if the client should be dropped. A client removed in the meantime
+// (snapshots can be stale) is skipped.
+func (m *Manager) send(client *Client, message []byte) bool {
+	select {
+	case <-client.done:
+		return true
+	default:
+	}
+
+	select {
+	case client.Send <- message:
+		return true
+	default:
+	}
+
+	switch m.overflow {
+	case OverflowDropOldest:
+		// Make room by discarding the oldest event. The reader may drain concurrently,
+		// so neither step is guaranteed; if we still can't enqueue, skip this event.
+		select {
+		case <-client.Send:
+		default:
+		}
+		select {
+		case client.Send <- message:
+		default:
+			log.Printf("Dropping event for slow client: %s", client.UserID)
+		}
+		return true
+
+	case OverflowBlockWithTimeout:
+		timer := time.NewTimer(m.sendTimeout)
+		defer timer.Stop()
+		select {
+		case client.Send <- message:
+			return true
+		case <-client.done:
+			return true
+		case <-timer.C:
+			return false
+		}
+
+	default: // OverflowDropClient
+		return false
+	}
+}
+
+// removeClientLocked removes a client and ends its stream, and reports whether it was the
+// user's last one. Caller must hold the write lock. Removing an already-removed client
+// is a no-op.
+func (m *Manager) removeClientLocked(client *Client) bool {
+	if _, ok := m.clients[client]; !ok {
+		return false
+	}
+	delete(m.clients, client)
+	client.close()
+
+	// Remove from userClients
+	clients := m.userClients[client.UserID]
+	for i, c := range clients {
+		if c == client {
+			m.userClients[client.UserID] = append(clients[:i], clients[i+1:]...)
+			break
+		}
+	}
+	if len(m.userClients[client.UserID]) == 0 {
+		delete(m.userClients, client.UserID)
+		return true
+	}
+	return false
+}
+
+// OnPresence registers functions called when a user opens their first stream and when
+// their last one closes. Both run in order on the Run loop, so they must not block, and
+// are set before Run starts.
+func (m *Manager) OnPresence(first, last func(userID string)) {
+	m.onFirst = first
+	m.onLast = last
+}
+
+// Connections returns how many streams are open across all users
+func (m *Manager) Connections() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return len(m.clients)
+}
+
+// Shutdown ends every open stream, and any opened afterwards, so a server shutdown
+// isn't held up by them. EventSource clients reconnect to the next instance on their
+// own. Run keeps going so pending unregisters and broadcasts don't block.
+func (m *Manager) Shutdown() {
+	m.closeOnce.Do(func() {
+		close(m.done)
+	})
+}
+
 // ServeHTTP handles the SSE endpoint
 func (m *Manager) ServeHTTP(c *gin.Context, userID string) {
-	client := &Client{
-		UserID: userID,
-		Send:   make(chan []byte, 256),
-	}
+	client := newClient(userID, clientBuffer)
 
 	m.register <- client
 
@@ -122,21 +343,48 @@ func (m *Manager) ServeHTTP(c *gin.Context, userID string) {
 		m.unregister <- client
 	}()
 
-	notify := c.Writer.CloseNotify()
+	var heartbeat <-chan time.Time
+	if m.heartbeat > 0 {
+		ticker := time.NewTicker(m.heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	// The request context ends when the client disconnects. A client that goes away
+	// in the middle of a write shows up as a write error instead.
+	done := c.Request.Context().Done()
 	for {
 		select {
-		case <-notify:
+		case <-done:
+			return
+		case <-m.done:
+			return
+		case <-heartbeat:
+			if !writeFlush(c.Writer, heartbeatComment) {
+				return
+			}
+		case <-client.done:
 			return
-		case message, ok := <-client.Send:
-			if !ok {
+		case message := <-client.Send:
+			if !writeFlush(c.Writer, message) {
 				return
 			}
-			c.Writer.Write(message)
-			c.Writer.Flush()
 		}
 	}
 }
 
+// heartbeatComment is an SSE comment line, which EventSource ignores
+var heartbeatComment = []byte(": ping\n\n")
+
+// writeFlush writes and flushes a message, reporting whether the client is still there
+func writeFlush(w gin.ResponseWriter, message []byte) bool {
+	if _, err := w.Write(message); err != nil {
+		return false
+	}
+	w.Flush()
+	return true
+}
+
 // SendToUser sends a message to a specific user
 func (m *Manager) SendToUser(userID string, eventType string, payload interface{}) {
 	data, err := json.Marshal(Event{
@@ -0,0 +1,279 @@
+package sse
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fill queues messages until the client's buffer is full
+func fill(client *Client) {
+	for i := 0; len(client.Send) < cap(client.Send); i++ {
+		client.Send <- []byte(fmt.Sprintf("old-%d", i))
+	}
+}
+
+func drain(client *Client) []string {
+	var got []string
+	for {
+		select {
+		case message := <-client.Send:
+			got = append(got, string(message))
+		default:
+			return got
+		}
+	}
+}
+
+func TestSendDropClient(t *testing.T) {
+	m := NewManager(1, OverflowDropClient, 0, 0, 0)
+	client := newClient("u1", 2)
+
+	if !m.send(client, []byte("a")) {
+		t.Fatal("send with room in the buffer should succeed")
+	}
+	fill(client)
+	if m.send(client, []byte("b")) {
+		t.Error("send to a full buffer should drop the client")
+	}
+}
+
+func TestSendDropOldest(t *testing.T) {
+	m := NewManager(1, OverflowDropOldest, 0, 0, 0)
+	client := newClient("u1", 2)
+	fill(client)
+
+	if !m.send(client, []byte("new")) {
+		t.Fatal("drop_oldest should keep the client")
+	}
+	got := drain(client)
+	want := []string{"old-1", "new"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("buffer = %v, want %v", got, want)
+	}
+}
+
+func TestSendBlockWithTimeout(t *testing.T) {
+	m := NewManager(1, OverflowBlockWithTimeout, 50*time.Millisecond, 0, 0)
+
+	t.Run("reader catches up", func(t *testing.T) {
+		client := newClient("u1", 1)
+		fill(client)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			<-client.Send
+		}()
+		if !m.send(client, []byte("new")) {
+			t.Error("send should succeed once the reader frees a slot")
+		}
+	})
+
+	t.Run("reader stuck", func(t *testing.T) {
+		client := newClient("u1", 1)
+		fill(client)
+		start := time.Now()
+		if m.send(client, []byte("new")) {
+			t.Error("send to a stuck client should drop it")
+		}
+		if waited := time.Since(start); waited < 50*time.Millisecond {
+			t.Errorf("gave up after %s, before the timeout", waited)
+		}
+	})
+
+	t.Run("client removed while waiting", func(t *testing.T) {
+		client := newClient("u1", 1)
+		fill(client)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			client.close()
+		}()
+		start := time.Now()
+		m.send(client, []byte("new"))
+		if waited := time.Since(start); waited >= 50*time.Millisecond {
+			t.Errorf("send waited %s for a removed client", waited)
+		}
+	})
+}
+
+func TestSendToRemovedClient(t *testing.T) {
+	m := NewManager(1, OverflowDropClient, 0, 0, 0)
+	client := newClient("u1", 1)
+	client.close()
+	client.close() // Closing twice is allowed
+
+	if !m.send(client, []byte("a")) {
+		t.Error("a removed client isn't slow and shouldn't be dropped again")
+	}
+	if len(client.Send) != 0 {
+		t.Error("nothing should be queued for a removed client")
+	}
+}
+
+// A client stuck under block_with_timeout must not hold up anyone else's connect
+func TestSlowClientDoesNotStallRegister(t *testing.T) {
+	m := NewManager(1, OverflowBlockWithTimeout, time.Second, 0, 0)
+	go m.Run()
+
+	stuck := newClient("slow", 0) // Unbuffered and never read
+	m.register <- stuck
+	m.SendToUser("slow", "email_update", nil)
+	time.Sleep(20 * time.Millisecond) // Let the delivery start waiting on the stuck client
+
+	other := newClient("other", 1)
+	select {
+	case m.register <- other:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("register blocked behind a slow client's delivery")
+	}
+	// The unregister goes through the Run loop as well
+	select {
+	case m.unregister <- other:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("unregister blocked behind a slow client's delivery")
+	}
+}
+
+// Broadcasts to one user arrive in order even with several workers delivering
+func TestBroadcastsKeepOrderPerUser(t *testing.T) {
+	m := NewManager(4, OverflowBlockWithTimeout, time.Second, 0, 0)
+	go m.Run()
+
+	const events = 200
+	clients := []*Client{newClient("u1", events), newClient("u1", events), newClient("u2", events)}
+	for _, client := range clients {
+		m.register <- client
+	}
+	for i := 0; i < events; i++ {
+		m.SendToUser("u1", "seq", i)
+		m.SendToUser("u2", "seq", i)
+	}
+
+	for _, client := range clients {
+		for i := 0; i < events; i++ {
+			want := fmt.Sprintf("data: {\"type\":\"seq\",\"payload\":%d}\n\n", i)
+			select {
+			case got := <-client.Send:
+				if string(got) != want {
+					t.Fatalf("client of %s: event %d = %q, want %q", client.UserID, i, got, want)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("client of %s: event %d never arrived", client.UserID, i)
+			}
+		}
+	}
+}
+
+// With every worker busy, the Run loop keeps taking broadcasts and registrations
+func TestBusyWorkersDoNotBlockRun(t *testing.T) {
+	m := NewManager(1, OverflowBlockWithTimeout, time.Second, 0, 0)
+	go m.Run()
+
+	stuck := newClient("slow", 0)
+	m.register <- stuck
+	m.SendToUser("slow", "email_update", nil) // Takes the only worker for up to a second
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			m.SendToUser("slow", "email_update", i)
+		}
+		m.register <- newClient("other", 1)
+	}()
+	select {
+	case <-done:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("Run blocked waiting for a free worker")
+	}
+}
+
+// Run with -race: clients connect, get dropped as slow and disconnect while broadcasts
+// are in flight. No client may be closed twice or written after removal.
+func TestConcurrentRegisterBroadcastUnregister(t *testing.T) {
+	for _, policy := range []string{OverflowDropClient, OverflowDropOldest, OverflowBlockWithTimeout} {
+		t.Run(policy, func(t *testing.T) {
+			m := NewManager(4, policy, time.Millisecond, 0, 3)
+			go m.Run()
+
+			var wg sync.WaitGroup
+			for u := 0; u < 8; u++ {
+				userID := fmt.Sprintf("user-%d", u)
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < 50; i++ {
+						client := newClient(userID, 2) // Small so overflow happens often
+						m.register <- client
+						// Read a little, then leave, sometimes after being dropped already
+						for j := 0; j < i%3; j++ {
+							select {
+							case <-client.Send:
+							case <-client.done:
+							case <-time.After(time.Millisecond):
+							}
+						}
+						m.unregister <- client
+					}
+				}()
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < 200; i++ {
+						m.SendToUser(userID, "email_update", i)
+					}
+				}()
+			}
+			wg.Wait()
+
+			deadline := time.Now().Add(time.Second)
+			for m.Connections() != 0 {
+				if time.Now().After(deadline) {
+					t.Fatalf("%d connections left open", m.Connections())
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+		})
+	}
+}
+
+// The presence hooks see a user come online with their first stream and go offline
+// with their last, in order, however many tabs they open in between
+func TestPresenceHooks(t *testing.T) {
+	m := NewManager(1, OverflowDropOldest, 0, 0, 2)
+	events := make(chan string, 10)
+	m.OnPresence(
+		func(userID string) { events <- "first " + userID },
+		func(userID string) { events <- "last " + userID },
+	)
+	go m.Run()
+
+	a, b, c := newClient("u1", 1), newClient("u1", 1), newClient("u1", 1)
+	m.register <- a
+	m.register <- b
+	m.register <- c // Over the limit, closes a
+	m.unregister <- a
+	m.unregister <- b
+	m.unregister <- c
+	m.unregister <- c // Removing twice isn't a second disconnect
+	m.register <- newClient("u1", 1)
+
+	want := []string{"first u1", "last u1", "first u1"}
+	for _, w := range want {
+		select {
+		case got := <-events:
+			if got != w {
+				t.Fatalf("hook = %q, want %q", got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("hook %q never ran", w)
+		}
+	}
+	select {
+	case got := <-events:
+		t.Errorf("unexpected hook %q", got)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
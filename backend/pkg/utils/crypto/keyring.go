@@ -0,0 +1,78 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// legacyVersion is the generation of ciphertext written before versions were added
+const legacyVersion = 1
+
+// ErrUnknownKeyVersion is returned for ciphertext sealed with a key the ring doesn't hold
+var ErrUnknownKeyVersion = errors.New("ciphertext was encrypted with an unknown key version")
+
+// Keyring holds every key generation that stored secrets may still use. New ciphertext
+// is sealed with the current key and prefixed with its version, e.g. "v2:<base64>",
+// so older generations stay readable while they are rotated out.
+type Keyring struct {
+	current int
+	keys    map[int]string
+}
+
+// NewKeyring builds a ring from the current key and its version plus any older keys
+func NewKeyring(currentVersion int, currentKey string, oldKeys map[int]string) (*Keyring, error) {
+	if currentVersion < 1 {
+		return nil, fmt.Errorf("key version must be positive, got %d", currentVersion)
+	}
+
+	keys := map[int]string{currentVersion: currentKey}
+	for version, key := range oldKeys {
+		if version == currentVersion {
+			return nil, fmt.Errorf("key version %d is configured twice", version)
+		}
+		keys[version] = key
+	}
+	for version, key := range keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key version %d must be 32 bytes (256 bits)", version)
+		}
+	}
+	return &Keyring{current: currentVersion, keys: keys}, nil
+}
+
+// CurrentVersion is the key generation new ciphertext is written with
+func (k *Keyring) CurrentVersion() int {
+	return k.current
+}
+
+// Encrypt seals text with the current key
+func (k *Keyring) Encrypt(text string) (string, error) {
+	sealed, err := Encrypt(text, k.keys[k.current])
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("v%d:%s", k.current, sealed), nil
+}
+
+// Decrypt opens ciphertext written with any key generation in the ring
+func (k *Keyring) Decrypt(cryptoText string) (string, error) {
+	version, sealed := Version(cryptoText)
+	key, ok := k.keys[version]
+	if !ok {
+		return "", fmt.Errorf("%w: v%d", ErrUnknownKeyVersion, version)
+	}
+	return Decrypt(sealed, key)
+}
+
+// Version splits versioned ciphertext into its key generation and the sealed data
+func Version(cryptoText string) (int, string) {
+	if prefix, sealed, found := strings.Cut(cryptoText, ":"); found && strings.HasPrefix(prefix, "v") {
+		if version, err := strconv.Atoi(prefix[1:]); err == nil {
+			return version, sealed
+		}
+	}
+	// Base64 has no ':', so anything unprefixed predates versions
+	return legacyVersion, cryptoText
+}
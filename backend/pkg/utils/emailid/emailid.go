@@ -0,0 +1,47 @@
+// Package emailid builds email IDs that don't change when a message moves between
+// mailboxes. A stable ID is "<provider>.<key>": the Gmail message ID, the IMAP
+// Message-ID header (base64url encoded) or the local repository ID.
+package emailid
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+const (
+	ProviderGmail = "gmail"
+	ProviderIMAP  = "imap"
+	ProviderLocal = "local"
+)
+
+// New returns the stable ID for a provider's key, or "" if key is empty
+func New(provider, key string) string {
+	if key == "" {
+		return ""
+	}
+	if provider == ProviderIMAP {
+		key = base64.RawURLEncoding.EncodeToString([]byte(key))
+	}
+	return provider + "." + key
+}
+
+// Parse splits a stable ID into its provider and key. ok is false for anything else,
+// including the provider-specific IDs used before stable IDs existed.
+func Parse(id string) (provider, key string, ok bool) {
+	provider, key, found := strings.Cut(id, ".")
+	if !found || key == "" {
+		return "", "", false
+	}
+
+	switch provider {
+	case ProviderGmail, ProviderLocal:
+		return provider, key, true
+	case ProviderIMAP:
+		decoded, err := base64.RawURLEncoding.DecodeString(key)
+		if err != nil || len(decoded) == 0 {
+			return "", "", false
+		}
+		return provider, string(decoded), true
+	}
+	return "", "", false
+}
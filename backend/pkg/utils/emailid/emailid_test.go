@@ -0,0 +1,49 @@
+package emailid
+
+import "testing"
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		provider, key string
+	}{
+		{ProviderGmail, "18c2f0a1b2c3d4e5"},
+		{ProviderIMAP, "<CAF=x.y+z@mail.example.com>"},
+		{ProviderIMAP, "<lunch@example.com>"},
+		{ProviderLocal, "6f1c2b9e-8a4d-4c1e-9f3a-2b7d5e6a1c0f"},
+	}
+	for _, tt := range tests {
+		id := New(tt.provider, tt.key)
+		provider, key, ok := Parse(id)
+		if !ok || provider != tt.provider || key != tt.key {
+			t.Errorf("Parse(New(%q, %q)) = %q, %q, %v", tt.provider, tt.key, provider, key, ok)
+		}
+	}
+}
+
+func TestNewEncodesIMAPKeys(t *testing.T) {
+	if got := New(ProviderIMAP, "<a.b@example.com>"); got != "imap.PGEuYkBleGFtcGxlLmNvbT4" {
+		t.Errorf("New() = %q, want the Message-ID base64url encoded without padding", got)
+	}
+	if got := New(ProviderGmail, "abc"); got != "gmail.abc" {
+		t.Errorf("New() = %q, want gmail.abc", got)
+	}
+	if got := New(ProviderIMAP, ""); got != "" {
+		t.Errorf("New() = %q for an empty key, want none", got)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, id := range []string{
+		"",
+		"18c2f0a1b2c3d4e5",   // A bare Gmail ID
+		"SU5CT1g6NDI=",       // A legacy IMAP "Mailbox:UID" ID
+		"gmail.",             // No key
+		"outlook.abc",        // Unknown provider
+		"imap.not base64!",   // Undecodable key
+		"imap.PGEuYkBleA==x", // Padding isn't used
+	} {
+		if provider, key, ok := Parse(id); ok {
+			t.Errorf("Parse(%q) = %q, %q, want it rejected", id, provider, key)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Package ical reads the parts of an iCalendar (RFC 5545) invite that the mail client
+// shows on an RSVP card
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is the first VEVENT of a calendar object
+type Event struct {
+	Method        string    `json:"method,omitempty"` // REQUEST, CANCEL, REPLY...
+	UID           string    `json:"uid"`
+	Sequence      int       `json:"sequence"`
+	Summary       string    `json:"summary"`
+	Description   string    `json:"description,omitempty"`
+	Location      string    `json:"location,omitempty"`
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	AllDay        bool      `json:"all_day"`
+	Organizer     string    `json:"organizer,omitempty"`
+	OrganizerName string    `json:"organizer_name,omitempty"`
+	Status        string    `json:"status,omitempty"`
+}
+
+// property is one content line: NAME;PARAM=VALUE:value
+type property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Parse extracts the calendar method and first event from ICS data
+func Parse(data []byte) (*Event, error) {
+	event := &Event{}
+	inEvent, found := false, false
+	depth := 0 // nesting inside the VEVENT (e.g. VALARM)
+
+	for _, line := range unfold(data) {
+		prop, ok := parseLine(line)
+		if !ok {
+			continue
+		}
+
+		switch prop.Name {
+		case "BEGIN":
+			if inEvent {
+				depth++
+			} else if strings.EqualFold(prop.Value, "VEVENT") && !found {
+				inEvent = true
+			}
+			continue
+		case "END":
+			if inEvent && depth > 0 {
+				depth--
+			} else if inEvent && strings.EqualFold(prop.Value, "VEVENT") {
+				inEvent, found = false, true
+			}
+			continue
+		case "METHOD":
+			if !inEvent {
+				event.Method = strings.ToUpper(prop.Value)
+			}
+			continue
+		}
+
+		if !inEvent || depth > 0 {
+			continue
+		}
+
+		switch prop.Name {
+		case "UID":
+			event.UID = prop.Value
+		case "SEQUENCE":
+			event.Sequence, _ = strconv.Atoi(prop.Value)
+		case "SUMMARY":
+			event.Summary = unescapeText(prop.Value)
+		case "DESCRIPTION":
+			event.Description = unescapeText(prop.Value)
+		case "LOCATION":
+			event.Location = unescapeText(prop.Value)
+		case "STATUS":
+			event.Status = strings.ToUpper(prop.Value)
+		case "ORGANIZER":
+			event.Organizer = stripMailto(prop.Value)
+			event.OrganizerName = strings.Trim(prop.Params["CN"], `"`)
+		case "DTSTART":
+			start, allDay, err := parseDateTime(prop)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DTSTART: %w", err)
+			}
+			event.Start, event.AllDay = start, allDay
+		case "DTEND":
+			end, _, err := parseDateTime(prop)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DTEND: %w", err)
+			}
+			event.End = end
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("no VEVENT found")
+	}
+	if event.End.IsZero() && event.AllDay {
+		event.End = event.Start.AddDate(0, 0, 1)
+	}
+	return event, nil
+}
+
+// unfold joins continuation lines (those starting with a space or tab) onto the previous line
+func unfold(data []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func parseLine(line string) (property, bool) {
+	// The value starts at the first colon that isn't inside a quoted parameter
+	inQuotes := false
+	sep := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		} else if r == ':' && !inQuotes {
+			sep = i
+			break
+		}
+	}
+	if sep <= 0 {
+		return property{}, false
+	}
+
+	head := strings.Split(line[:sep], ";")
+	prop := property{
+		Name:   strings.ToUpper(head[0]),
+		Params: make(map[string]string),
+		Value:  line[sep+1:],
+	}
+	for _, param := range head[1:] {
+		if key, value, ok := strings.Cut(param, "="); ok {
+			prop.Params[strings.ToUpper(key)] = value
+		}
+	}
+	return prop, true
+}
+
+func parseDateTime(prop property) (time.Time, bool, error) {
+	value := prop.Value
+	if strings.EqualFold(prop.Params["VALUE"], "DATE") || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, time.UTC)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+
+	loc := time.UTC
+	if tzid := strings.Trim(prop.Params["TZID"], `"`); tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t.UTC(), false, err
+}
+
+func unescapeText(value string) string {
+	replacer := strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return replacer.Replace(value)
+}
+
+func stripMailto(value string) string {
+	if len(value) >= 7 && strings.EqualFold(value[:7], "mailto:") {
+		return value[7:]
+	}
+	return value
+}
@@ -0,0 +1,100 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// sampleInvite is shaped like a Google Calendar invitation: CRLF line endings, a folded
+// description, a TZID start and a reminder nested in the event
+var sampleInvite = strings.ReplaceAll(`BEGIN:VCALENDAR
+PRODID:-//Google Inc//Google Calendar 70.9054//EN
+VERSION:2.0
+METHOD:REQUEST
+BEGIN:VTIMEZONE
+TZID:Asia/Ho_Chi_Minh
+END:VTIMEZONE
+BEGIN:VEVENT
+DTSTART;TZID=Asia/Ho_Chi_Minh:20240305T090000
+DTEND;TZID=Asia/Ho_Chi_Minh:20240305T100000
+ORGANIZER;CN="Alice, PM":mailto:alice@example.com
+UID:abc123@google.com
+SEQUENCE:2
+SUMMARY:Sprint planning\, week 10
+LOCATION:Room 4\; 2nd floor
+DESCRIPTION:Agenda:\n1. Review the board\n2. Pick tickets for the next
+  sprint
+STATUS:CONFIRMED
+BEGIN:VALARM
+ACTION:DISPLAY
+DESCRIPTION:This is an event reminder
+TRIGGER:-P0DT0H10M0S
+END:VALARM
+END:VEVENT
+END:VCALENDAR
+`, "\n", "\r\n")
+
+func TestParse(t *testing.T) {
+	event, err := Parse([]byte(sampleInvite))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := Event{
+		Method:        "REQUEST",
+		UID:           "abc123@google.com",
+		Sequence:      2,
+		Summary:       "Sprint planning, week 10",
+		Description:   "Agenda:\n1. Review the board\n2. Pick tickets for the next sprint",
+		Location:      "Room 4; 2nd floor",
+		Start:         time.Date(2024, 3, 5, 2, 0, 0, 0, time.UTC),
+		End:           time.Date(2024, 3, 5, 3, 0, 0, 0, time.UTC),
+		Organizer:     "alice@example.com",
+		OrganizerName: "Alice, PM",
+		Status:        "CONFIRMED",
+	}
+	if *event != want {
+		t.Errorf("Parse() =\n%+v\nwant\n%+v", *event, want)
+	}
+}
+
+func TestParseAllDay(t *testing.T) {
+	event, err := Parse([]byte("BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:holiday\nSUMMARY:Tết\nDTSTART;VALUE=DATE:20250129\nEND:VEVENT\nEND:VCALENDAR\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	start := time.Date(2025, 1, 29, 0, 0, 0, 0, time.UTC)
+	if !event.AllDay || !event.Start.Equal(start) || !event.End.Equal(start.AddDate(0, 0, 1)) {
+		t.Errorf("event = (all day %v, %v - %v), want a one-day event on 29 Jan", event.AllDay, event.Start, event.End)
+	}
+}
+
+func TestParseUTCAndFirstEventOnly(t *testing.T) {
+	data := "BEGIN:VEVENT\nUID:first\nDTSTART:20240305T090000Z\nEND:VEVENT\nBEGIN:VEVENT\nUID:second\nEND:VEVENT\n"
+	event, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if event.UID != "first" || !event.Start.Equal(time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("event = (%q, %v), want the first event starting 09:00 UTC", event.UID, event.Start)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"no event", "BEGIN:VCALENDAR\nBEGIN:VTODO\nSUMMARY:Chores\nEND:VTODO\nEND:VCALENDAR\n"},
+		{"bad start", "BEGIN:VEVENT\nDTSTART:next tuesday\nEND:VEVENT\n"},
+		{"not ics", "Hello, this is a plain text file."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.data)); err == nil {
+				t.Error("Parse() error = nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package ical
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"ga03-backend/pkg/utils/mailutil"
+
+	"github.com/google/uuid"
+)
+
+// Participation statuses for an ATTENDEE (RFC 5545 section 3.2.12)
+const (
+	PartStatAccepted  = "ACCEPTED"
+	PartStatDeclined  = "DECLINED"
+	PartStatTentative = "TENTATIVE"
+)
+
+// BuildReply creates a METHOD:REPLY calendar object (RFC 5546) answering event on
+// behalf of the attendee
+func BuildReply(event *Event, attendeeEmail, attendeeName, partStat string, now time.Time) []byte {
+	var b bytes.Buffer
+	writeLine(&b, "BEGIN:VCALENDAR")
+	writeLine(&b, "PRODID:-//ga03//Mail Client//EN")
+	writeLine(&b, "VERSION:2.0")
+	writeLine(&b, "METHOD:REPLY")
+	writeLine(&b, "BEGIN:VEVENT")
+	writeLine(&b, "UID:"+event.UID)
+	writeLine(&b, fmt.Sprintf("SEQUENCE:%d", event.Sequence))
+	writeLine(&b, "DTSTAMP:"+formatUTC(now))
+	if !event.Start.IsZero() {
+		writeLine(&b, "DTSTART"+formatDateValue(event.Start, event.AllDay))
+	}
+	if !event.End.IsZero() {
+		writeLine(&b, "DTEND"+formatDateValue(event.End, event.AllDay))
+	}
+	if event.Summary != "" {
+		writeLine(&b, "SUMMARY:"+escapeText(event.Summary))
+	}
+	if event.Organizer != "" {
+		writeLine(&b, "ORGANIZER"+cnParam(event.OrganizerName)+":mailto:"+event.Organizer)
+	}
+	writeLine(&b, "ATTENDEE;PARTSTAT="+partStat+cnParam(attendeeName)+":mailto:"+attendeeEmail)
+	writeLine(&b, "END:VEVENT")
+	writeLine(&b, "END:VCALENDAR")
+	return b.Bytes()
+}
+
+// BuildIMIPMessage wraps a calendar reply in an iMIP (RFC 6047) email with a short
+// plain text explanation for clients that don't understand calendars
+func BuildIMIPMessage(fromHeader, fromEmail, to, subject, text string, ics []byte) []byte {
+	boundary := "imip_" + uuid.NewString()
+
+	var b bytes.Buffer
+	b.WriteString(fmt.Sprintf("From: %s\r\n", fromHeader))
+	b.WriteString(fmt.Sprintf("To: %s\r\n", to))
+	b.WriteString(fmt.Sprintf("Message-ID: %s\r\n", mailutil.NewMessageID(fromEmail)))
+	b.WriteString(fmt.Sprintf("Subject: =?utf-8?B?%s?=\r\n", base64.StdEncoding.EncodeToString([]byte(subject))))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString(fmt.Sprintf("Content-Type: multipart/alternative; boundary=\"%s\"\r\n\r\n", boundary))
+
+	b.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+	b.WriteString("Content-Type: text/plain; charset=\"UTF-8\"\r\n\r\n")
+	b.WriteString(text)
+	b.WriteString("\r\n")
+
+	b.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+	b.WriteString("Content-Type: text/calendar; method=REPLY; charset=\"UTF-8\"\r\n")
+	b.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString(ics)
+	for i := 0; i < len(encoded); i += 76 {
+		end := i + 76
+		if end > len(encoded) {
+			end = len(encoded)
+		}
+		b.WriteString(encoded[i:end] + "\r\n")
+	}
+	b.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
+	return b.Bytes()
+}
+
+// writeLine writes a content line folded at 75 octets, without splitting UTF-8 sequences
+func writeLine(b *bytes.Buffer, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = 74 // Continuation lines start with a space
+	}
+	b.WriteString(line + "\r\n")
+}
+
+func isRuneStart(c byte) bool {
+	return c&0xC0 != 0x80
+}
+
+func formatUTC(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+func formatDateValue(t time.Time, allDay bool) string {
+	if allDay {
+		return ";VALUE=DATE:" + t.Format("20060102")
+	}
+	return ":" + formatUTC(t)
+}
+
+func cnParam(name string) string {
+	name = strings.NewReplacer(`"`, "", "\r", "", "\n", "").Replace(name)
+	if name == "" {
+		return ""
+	}
+	return fmt.Sprintf(";CN=\"%s\"", name)
+}
+
+func escapeText(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return replacer.Replace(value)
+}
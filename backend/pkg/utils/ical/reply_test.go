@@ -0,0 +1,133 @@
+package ical
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+var replyNow = time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+
+func planningEvent() *Event {
+	return &Event{
+		Method:        "REQUEST",
+		UID:           "abc123@google.com",
+		Sequence:      2,
+		Summary:       "Sprint planning, week 10",
+		Start:         time.Date(2024, 3, 5, 2, 0, 0, 0, time.UTC),
+		End:           time.Date(2024, 3, 5, 3, 0, 0, 0, time.UTC),
+		Organizer:     "alice@example.com",
+		OrganizerName: "Alice",
+	}
+}
+
+func TestBuildReply(t *testing.T) {
+	ics := string(BuildReply(planningEvent(), "bob@example.com", "Bob", PartStatAccepted, replyNow))
+
+	for _, line := range []string{
+		"METHOD:REPLY",
+		"UID:abc123@google.com",
+		"SEQUENCE:2",
+		"DTSTAMP:20240301T080000Z",
+		"DTSTART:20240305T020000Z",
+		"DTEND:20240305T030000Z",
+		`SUMMARY:Sprint planning\, week 10`,
+		`ORGANIZER;CN="Alice":mailto:alice@example.com`,
+		`ATTENDEE;PARTSTAT=ACCEPTED;CN="Bob":mailto:bob@example.com`,
+	} {
+		if !strings.Contains(ics, line+"\r\n") {
+			t.Errorf("reply is missing %q:\n%s", line, ics)
+		}
+	}
+
+	// The organizer's calendar matches the reply to the event by UID and sequence
+	event, err := Parse([]byte(ics))
+	if err != nil {
+		t.Fatalf("Parse() of the reply error = %v", err)
+	}
+	original := planningEvent()
+	if event.Method != "REPLY" || event.UID != original.UID || event.Sequence != original.Sequence ||
+		event.Summary != original.Summary || !event.Start.Equal(original.Start) || event.Organizer != original.Organizer {
+		t.Errorf("parsed reply = %+v, want the original event as a REPLY", event)
+	}
+}
+
+func TestBuildReplyAllDay(t *testing.T) {
+	event := &Event{UID: "holiday", Start: time.Date(2025, 1, 29, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 1, 30, 0, 0, 0, 0, time.UTC), AllDay: true}
+	ics := string(BuildReply(event, "bob@example.com", "", PartStatDeclined, replyNow))
+
+	for _, line := range []string{"DTSTART;VALUE=DATE:20250129", "DTEND;VALUE=DATE:20250130", "ATTENDEE;PARTSTAT=DECLINED:mailto:bob@example.com"} {
+		if !strings.Contains(ics, line+"\r\n") {
+			t.Errorf("reply is missing %q:\n%s", line, ics)
+		}
+	}
+}
+
+func TestBuildReplyFoldsLongLines(t *testing.T) {
+	event := planningEvent()
+	event.Summary = strings.Repeat("Họp kế hoạch ", 12)
+	// Quotes and line breaks in a name would break the parameter
+	ics := BuildReply(event, "bob@example.com", "Bob \"The\"\r\nBuilder", PartStatTentative, replyNow)
+
+	for _, line := range strings.Split(strings.TrimSuffix(string(ics), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line is %d octets, want at most 75: %q", len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line splits a UTF-8 sequence: %q", line)
+		}
+	}
+
+	parsed, err := Parse(ics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Summary != event.Summary {
+		t.Errorf("unfolded summary = %q, want %q", parsed.Summary, event.Summary)
+	}
+	if !bytes.Contains(ics, []byte(`ATTENDEE;PARTSTAT=TENTATIVE;CN="Bob TheBuilder":mailto:bob@example.com`)) {
+		t.Errorf("reply = %s, want the attendee name cleaned", ics)
+	}
+}
+
+func TestBuildIMIPMessage(t *testing.T) {
+	ics := BuildReply(planningEvent(), "bob@example.com", "Bob", PartStatAccepted, replyNow)
+	raw := BuildIMIPMessage("Bob <bob@example.com>", "bob@example.com", "alice@example.com", "Accepted: Sprint planning", "Bob has accepted", ics)
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if msg.Header.Get("To") != "alice@example.com" || subject != "Accepted: Sprint planning" || !strings.HasSuffix(msg.Header.Get("Message-ID"), "@example.com>") {
+		t.Errorf("headers = %v, want a reply to the organizer", msg.Header)
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", mediaType)
+	}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	if part, err := reader.NextPart(); err != nil || !strings.HasPrefix(part.Header.Get("Content-Type"), "text/plain") {
+		t.Fatalf("first part = %v, %v; want the text explanation", part, err)
+	}
+	part, err := reader.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, _ = mime.ParseMediaType(part.Header.Get("Content-Type"))
+	if mediaType != "text/calendar" || params["method"] != "REPLY" {
+		t.Errorf("calendar part Content-Type = %q, want text/calendar with method=REPLY", part.Header.Get("Content-Type"))
+	}
+	data, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+	if !bytes.Equal(data, ics) {
+		t.Errorf("calendar part = %q, want the reply ICS", data)
+	}
+}
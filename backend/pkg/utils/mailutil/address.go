@@ -0,0 +1,102 @@
+package mailutil
+
+import (
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+
+	"github.com/emersion/go-message/charset"
+	"github.com/google/uuid"
+)
+
+// addressParser also decodes RFC 2047 names in charsets other than UTF-8 and ASCII
+var addressParser = mail.AddressParser{WordDecoder: &mime.WordDecoder{CharsetReader: charset.Reader}}
+
+const maxDisplayNameLength = 64
+
+// SanitizeDisplayName strips characters that could break or inject into a From header
+// and caps the length of the name.
+func SanitizeDisplayName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '\r', '\n', '\t', '<', '>', '"':
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.Join(strings.Fields(name), " ")
+
+	runes := []rune(name)
+	if len(runes) > maxDisplayNameLength {
+		name = strings.TrimSpace(string(runes[:maxDisplayNameLength]))
+	}
+	return name
+}
+
+// FormatAddress formats a "Name <email>" header value, RFC 2047 encoding non-ASCII names
+func FormatAddress(name, email string) string {
+	addr := mail.Address{Name: SanitizeDisplayName(name), Address: email}
+	return addr.String()
+}
+
+// ParseAddresses parses address header values, each of which may hold a comma-separated
+// list. Entries that don't parse are kept as bare addresses.
+func ParseAddresses(values []string) []*mail.Address {
+	var result []*mail.Address
+	for _, value := range values {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		if list, err := addressParser.ParseList(value); err == nil {
+			result = append(result, list...)
+			continue
+		}
+		for _, part := range strings.Split(value, ",") {
+			if addr, err := addressParser.Parse(part); err == nil {
+				result = append(result, addr)
+			} else if part = strings.Trim(strings.TrimSpace(part), "<>"); part != "" {
+				result = append(result, &mail.Address{Address: part})
+			}
+		}
+	}
+	return result
+}
+
+// DisplayAddress formats "Name <email>", or the bare address when there is no name.
+// Unlike FormatAddress the name is left readable instead of RFC 2047 encoded, and is
+// only quoted when it holds characters that would split it when parsed back.
+func DisplayAddress(name, email string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return email
+	}
+	if strings.ContainsAny(name, `()<>[]:;@\,."`) {
+		name = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
+	}
+	return name + " <" + email + ">"
+}
+
+// AddressList splits an address-list header such as To or Cc into one DisplayAddress
+// entry per recipient
+func AddressList(header string) []string {
+	var list []string
+	for _, addr := range ParseAddresses([]string{header}) {
+		list = append(list, DisplayAddress(addr.Name, addr.Address))
+	}
+	return list
+}
+
+// NormalizeAddress lowercases and trims an email address for comparison
+func NormalizeAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// NewMessageID generates a unique Message-ID header value in the sender's domain
+func NewMessageID(fromEmail string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(fromEmail, "@"); at >= 0 && at < len(fromEmail)-1 {
+		domain = fromEmail[at+1:]
+	}
+	return fmt.Sprintf("<%s@%s>", uuid.NewString(), domain)
+}
@@ -0,0 +1,93 @@
+package mailutil
+
+import (
+	"net/mail"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeDisplayName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "Support Team", "Support Team"},
+		{"header injection", "Support\r\nBcc: victim@example.com", "SupportBcc: victim@example.com"},
+		{"address characters", `"Boss" <ceo@example.com>`, "Boss ceo@example.com"},
+		{"collapsed whitespace", "  Support \t  Team ", "Support Team"},
+		{"too long", strings.Repeat("ă", 70), strings.Repeat("ă", maxDisplayNameLength)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeDisplayName(tt.in); got != tt.want {
+				t.Errorf("SanitizeDisplayName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatAddressEncodesNonASCII(t *testing.T) {
+	header := FormatAddress("Đội Hỗ trợ", "support@example.com")
+	for _, r := range header {
+		if r > 127 {
+			t.Fatalf("FormatAddress() = %q, want an ASCII header", header)
+		}
+	}
+	if !strings.HasPrefix(header, "=?utf-8?") {
+		t.Errorf("FormatAddress() = %q, want an RFC 2047 encoded name", header)
+	}
+
+	addr, err := mail.ParseAddress(header)
+	if err != nil {
+		t.Fatalf("the header doesn't parse: %v", err)
+	}
+	if addr.Name != "Đội Hỗ trợ" || addr.Address != "support@example.com" {
+		t.Errorf("parsed back as (%q, %q)", addr.Name, addr.Address)
+	}
+}
+
+func TestDisplayAddress(t *testing.T) {
+	tests := []struct {
+		name, email, want string
+	}{
+		{"Alice", "alice@example.com", "Alice <alice@example.com>"},
+		{"  ", "bob@example.com", "bob@example.com"},
+		{"Nguyễn Văn A", "a@example.com", "Nguyễn Văn A <a@example.com>"},
+		{"Doe, Jane", "jane@example.com", `"Doe, Jane" <jane@example.com>`},
+		{`Bob "the builder"`, "bob@example.com", `"Bob \"the builder\"" <bob@example.com>`},
+	}
+	for _, tt := range tests {
+		if got := DisplayAddress(tt.name, tt.email); got != tt.want {
+			t.Errorf("DisplayAddress(%q, %q) = %q, want %q", tt.name, tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestAddressList(t *testing.T) {
+	header := `"Doe, Jane" <jane@example.com>, Alice <alice@example.com>, bob@example.com, ` +
+		`"Bob \"the builder\"" <builder@example.com>, =?ISO-8859-1?Q?Andr=E9?= <andre@example.com>`
+	want := []string{
+		`"Doe, Jane" <jane@example.com>`,
+		"Alice <alice@example.com>",
+		"bob@example.com",
+		`"Bob \"the builder\"" <builder@example.com>`,
+		"André <andre@example.com>",
+	}
+
+	got := AddressList(header)
+	if !slices.Equal(got, want) {
+		t.Fatalf("AddressList() =\n%q\nwant\n%q", got, want)
+	}
+	// Every entry is a valid address on its own
+	for _, entry := range got {
+		if _, err := mail.ParseAddress(entry); err != nil {
+			t.Errorf("entry %q doesn't parse back: %v", entry, err)
+		}
+	}
+
+	if list := AddressList(""); list != nil {
+		t.Errorf("AddressList(\"\") = %q, want none", list)
+	}
+}
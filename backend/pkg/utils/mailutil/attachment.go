@@ -0,0 +1,25 @@
+package mailutil
+
+import "strings"
+
+// MentionsAttachment reports whether the body contains one of the given phrases,
+// ignoring case, HTML markup and lines quoted from an earlier message.
+// It returns the first phrase found.
+func MentionsAttachment(body string, phrases []string) (string, bool) {
+	var own []string
+	for _, line := range strings.Split(HTMLToText(body), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), ">") {
+			continue
+		}
+		own = append(own, line)
+	}
+	text := strings.ToLower(strings.Join(own, "\n"))
+
+	for _, phrase := range phrases {
+		phrase = strings.ToLower(strings.TrimSpace(phrase))
+		if phrase != "" && strings.Contains(text, phrase) {
+			return phrase, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,29 @@
+package mailutil
+
+import "testing"
+
+func TestMentionsAttachment(t *testing.T) {
+	phrases := []string{"attached", "attachment", "đính kèm", "gửi kèm"}
+	tests := []struct {
+		name   string
+		body   string
+		want   string
+		wantOK bool
+	}{
+		{"english", "Hi, please see the ATTACHED report.", "attached", true},
+		{"vietnamese", "Chào anh, em gửi file Đính Kèm báo cáo.", "đính kèm", true},
+		{"vietnamese html", "<p>Em <b>gửi kèm</b> hợp đồng</p>", "gửi kèm", true},
+		{"english html", "<div>The attachment is below</div>", "attachment", true},
+		{"no mention", "Lunch at noon?", "", false},
+		{"vietnamese no mention", "Hẹn gặp anh lúc 12 giờ nhé.", "", false},
+		{"only in the quoted original", "Got it, thanks!\n\nAlice wrote:\n> See attached.", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := MentionsAttachment(tt.body, phrases)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("MentionsAttachment(%q) = (%q, %v), want (%q, %v)", tt.body, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
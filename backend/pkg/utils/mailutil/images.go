@@ -0,0 +1,52 @@
+package mailutil
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var imgTagRe = regexp.MustCompile(`(?is)<img\b[^>]*>`)
+
+// srcAttrRe matches a src attribute with a double-quoted, single-quoted or bare value
+var srcAttrRe = regexp.MustCompile(`(?is)(\s)src\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+
+// srcsetAttrRe matches srcset, which would load remote images on its own
+var srcsetAttrRe = regexp.MustCompile(`(?is)\ssrcset\s*=\s*(?:"[^"]*"|'[^']*'|[^\s>]+)`)
+
+// RewriteRemoteImages replaces the src of every <img> that points at an http(s) URL
+// with rewrite(src). An empty result removes the src. Inline (cid:) and data: images are
+// left alone. It returns the new body and how many images were rewritten.
+func RewriteRemoteImages(body string, rewrite func(src string) string) (string, int) {
+	count := 0
+	body = imgTagRe.ReplaceAllStringFunc(body, func(tag string) string {
+		m := srcAttrRe.FindStringSubmatch(tag)
+		if m == nil {
+			return tag
+		}
+		src := html.UnescapeString(strings.TrimSpace(m[2] + m[3] + m[4]))
+		lower := strings.ToLower(src)
+		if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") && !strings.HasPrefix(lower, "//") {
+			return tag
+		}
+		if strings.HasPrefix(src, "//") {
+			src = "https:" + src
+		}
+
+		count++
+		tag = srcsetAttrRe.ReplaceAllString(tag, "")
+		replacement := ""
+		if newSrc := rewrite(src); newSrc != "" {
+			replacement = m[1] + `src="` + html.EscapeString(newSrc) + `"`
+		}
+		return srcAttrRe.ReplaceAllLiteralString(tag, replacement)
+	})
+	return body, count
+}
+
+// BlockRemoteImages removes the src of every remote image in an HTML body, so showing
+// it doesn't reach the sender. It reports whether there were any.
+func BlockRemoteImages(body string) (string, bool) {
+	body, count := RewriteRemoteImages(body, func(string) string { return "" })
+	return body, count > 0
+}
@@ -0,0 +1,44 @@
+package mailutil
+
+import "testing"
+
+func TestRewriteRemoteImages(t *testing.T) {
+	proxy := func(src string) string { return "/proxy?src=" + src }
+	tests := []struct {
+		name      string
+		body      string
+		want      string
+		wantCount int
+	}{
+		{"double quoted", `<img src="http://a.test/x.png">`, `<img src="/proxy?src=http://a.test/x.png">`, 1},
+		{"single quoted", `<img alt='' src='https://a.test/x.png'>`, `<img alt='' src="/proxy?src=https://a.test/x.png">`, 1},
+		{"bare", `<IMG SRC=https://a.test/x.png>`, `<IMG src="/proxy?src=https://a.test/x.png">`, 1},
+		{"protocol relative", `<img src="//a.test/x.png">`, `<img src="/proxy?src=https://a.test/x.png">`, 1},
+		{"entity in url", `<img src="https://a.test/x.png?a=1&amp;b=2">`, `<img src="/proxy?src=https://a.test/x.png?a=1&amp;b=2">`, 1},
+		{"srcset dropped", `<img srcset="https://a.test/2x.png 2x" src="https://a.test/x.png">`, `<img src="/proxy?src=https://a.test/x.png">`, 1},
+		{"inline image", `<img src="cid:logo@example.com">`, `<img src="cid:logo@example.com">`, 0},
+		{"data image", `<img src="data:image/png;base64,AAAA">`, `<img src="data:image/png;base64,AAAA">`, 0},
+		{"no src", `<img alt="x">`, `<img alt="x">`, 0},
+		{
+			"several",
+			`<p><img src="https://a.test/1.png"></p><img src="cid:x"><img src="http://b.test/2.gif">`,
+			`<p><img src="/proxy?src=https://a.test/1.png"></p><img src="cid:x"><img src="/proxy?src=http://b.test/2.gif">`,
+			2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, count := RewriteRemoteImages(tt.body, proxy)
+			if got != tt.want || count != tt.wantCount {
+				t.Errorf("RewriteRemoteImages() = %q, %d, want %q, %d", got, count, tt.want, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestRewriteRemoteImagesRemovesSrc(t *testing.T) {
+	got, count := RewriteRemoteImages(`<img width="1" src="https://tracker.test/open.gif" height="1">`, func(string) string { return "" })
+	if want := `<img width="1" height="1">`; got != want || count != 1 {
+		t.Errorf("RewriteRemoteImages() = %q, %d, want %q, 1", got, count, want)
+	}
+}
@@ -0,0 +1,19 @@
+package mailutil
+
+import "strings"
+
+// IsInlinePart reports whether a MIME part is embedded content (e.g. a logo referenced
+// by cid: from the HTML body) rather than a file the user attached. A part whose
+// Content-ID is referenced by the body is always inline; otherwise it must have an
+// inline disposition and a Content-ID.
+func IsInlinePart(disposition, contentID, body string) bool {
+	contentID = strings.Trim(strings.TrimSpace(contentID), "<>")
+	if contentID == "" {
+		return false
+	}
+	if strings.Contains(body, "cid:"+contentID) {
+		return true
+	}
+	disposition = strings.ToLower(strings.TrimSpace(disposition))
+	return strings.HasPrefix(disposition, "inline")
+}
@@ -0,0 +1,27 @@
+package mailutil
+
+import "testing"
+
+func TestIsInlinePart(t *testing.T) {
+	body := `<p>Hi</p><img src="cid:logo@example.com">`
+	tests := []struct {
+		name        string
+		disposition string
+		contentID   string
+		want        bool
+	}{
+		{"referenced by the body", "", "<logo@example.com>", true},
+		{"referenced but marked attachment", `attachment; filename="logo.png"`, "logo@example.com", true},
+		{"inline with a content id", `Inline; filename="sig.png"`, "<sig@example.com>", true},
+		{"real attachment", `attachment; filename="report.pdf"`, "", false},
+		{"inline without a content id", "inline", "", false},
+		{"unreferenced attachment with a content id", "attachment", "<other@example.com>", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsInlinePart(tt.disposition, tt.contentID, body); got != tt.want {
+				t.Errorf("IsInlinePart(%q, %q) = %v, want %v", tt.disposition, tt.contentID, got, tt.want)
+			}
+		})
+	}
+}
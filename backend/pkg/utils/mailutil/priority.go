@@ -0,0 +1,60 @@
+package mailutil
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Normalized message priorities
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+// ParsePriority normalizes the priority headers senders use. header returns the value
+// of a header by name. X-Priority wins over Importance, then Priority and
+// X-MSMail-Priority; without any of them the message is normal priority.
+func ParsePriority(header func(name string) string) string {
+	// "1 (Highest)", "2 (High)", "3 (Normal)", "4 (Low)", "5 (Lowest)" or just the digit
+	if value := strings.TrimSpace(header("X-Priority")); value != "" {
+		// A value of only separators, like "(", leaves no fields; the other headers decide
+		fields := strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == '(' })
+		if len(fields) > 0 {
+			if n, err := strconv.Atoi(fields[0]); err == nil {
+				switch {
+				case n <= 2:
+					return PriorityHigh
+				case n >= 4:
+					return PriorityLow
+				default:
+					return PriorityNormal
+				}
+			}
+		}
+	}
+
+	for _, name := range []string{"Importance", "Priority", "X-MSMail-Priority"} {
+		switch strings.ToLower(strings.TrimSpace(header(name))) {
+		case "high", "urgent":
+			return PriorityHigh
+		case "low", "non-urgent":
+			return PriorityLow
+		case "normal":
+			return PriorityNormal
+		}
+	}
+	return PriorityNormal
+}
+
+// PriorityRank orders priorities from high (0) to low (2); unknown values count as normal
+func PriorityRank(priority string) int {
+	switch priority {
+	case PriorityHigh:
+		return 0
+	case PriorityLow:
+		return 2
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,52 @@
+package mailutil
+
+import (
+	"net/textproto"
+	"testing"
+)
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"no headers", nil, PriorityNormal},
+		{"x-priority digit high", map[string]string{"X-Priority": "1"}, PriorityHigh},
+		{"x-priority with label", map[string]string{"X-Priority": "2 (High)"}, PriorityHigh},
+		{"x-priority label without space", map[string]string{"X-Priority": "5(Lowest)"}, PriorityLow},
+		{"x-priority normal", map[string]string{"X-Priority": "3 (Normal)"}, PriorityNormal},
+		{"x-priority low", map[string]string{"X-Priority": " 4 "}, PriorityLow},
+		{"x-priority separators only", map[string]string{"X-Priority": "("}, PriorityNormal},
+		{"x-priority blank label", map[string]string{"X-Priority": " ( "}, PriorityNormal},
+		{"x-priority garbage falls back to importance", map[string]string{"X-Priority": "urgent!", "Importance": "high"}, PriorityHigh},
+		{"x-priority separators falls back to importance", map[string]string{"X-Priority": "(", "Importance": "low"}, PriorityLow},
+		{"x-priority wins over importance", map[string]string{"X-Priority": "5", "Importance": "High"}, PriorityLow},
+		{"importance mixed case", map[string]string{"Importance": "HIGH"}, PriorityHigh},
+		{"importance low", map[string]string{"Importance": "low"}, PriorityLow},
+		{"priority urgent", map[string]string{"Priority": "urgent"}, PriorityHigh},
+		{"priority non-urgent", map[string]string{"Priority": "non-urgent"}, PriorityLow},
+		{"ms mail priority", map[string]string{"X-MSMail-Priority": "High"}, PriorityHigh},
+		{"unknown importance", map[string]string{"Importance": "whenever"}, PriorityNormal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := textproto.MIMEHeader{}
+			for name, value := range tt.headers {
+				header.Set(name, value)
+			}
+			if got := ParsePriority(header.Get); got != tt.want {
+				t.Errorf("ParsePriority(%v) = %q, want %q", tt.headers, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPriorityRank(t *testing.T) {
+	if !(PriorityRank(PriorityHigh) < PriorityRank(PriorityNormal) && PriorityRank(PriorityNormal) < PriorityRank(PriorityLow)) {
+		t.Error("PriorityRank should order high, normal, low")
+	}
+	if PriorityRank("") != PriorityRank(PriorityNormal) {
+		t.Error("unknown priority should rank as normal")
+	}
+}
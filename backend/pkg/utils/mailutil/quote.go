@@ -0,0 +1,116 @@
+package mailutil
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// QuotedMessage is the part of the original message that is quoted in a reply
+type QuotedMessage struct {
+	From   string
+	Date   time.Time
+	Body   string
+	IsHTML bool
+}
+
+// ReplyOptions controls where the original message goes in a reply
+type ReplyOptions struct {
+	TopPost         bool
+	IncludeOriginal bool
+}
+
+// Attribution returns the "On <date>, <from> wrote:" line that introduces a quote
+func Attribution(from string, date time.Time) string {
+	if date.IsZero() {
+		return fmt.Sprintf("%s wrote:", from)
+	}
+	return fmt.Sprintf("On %s, %s wrote:", date.Format("Mon, Jan 2, 2006 at 3:04 PM"), from)
+}
+
+// QuoteHTML wraps the original message in a blockquote preceded by the attribution.
+// Plain text originals are escaped so they render as written.
+func QuoteHTML(original QuotedMessage) string {
+	body := original.Body
+	if !original.IsHTML {
+		body = TextToHTML(body)
+	}
+	return fmt.Sprintf(
+		"<div class=\"quote-attribution\">%s</div>\n<blockquote style=\"margin:0 0 0 .8ex;border-left:1px solid #ccc;padding-left:1ex\">%s</blockquote>",
+		html.EscapeString(Attribution(original.From, original.Date)),
+		body,
+	)
+}
+
+// QuoteText prefixes every line of the original message with "> " after the attribution
+func QuoteText(original QuotedMessage) string {
+	body := original.Body
+	if original.IsHTML {
+		body = HTMLToText(body)
+	}
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+
+	lines := strings.Split(strings.TrimRight(body, "\n"), "\n")
+	var b strings.Builder
+	b.WriteString(Attribution(original.From, original.Date))
+	for _, line := range lines {
+		b.WriteString("\n>")
+		// Already-quoted lines nest without an extra space (">> text")
+		if line != "" && !strings.HasPrefix(line, ">") {
+			b.WriteString(" ")
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// BuildReplyBody combines the reply with the quoted original according to opts
+func BuildReplyBody(reply string, isHTML bool, original QuotedMessage, opts ReplyOptions) string {
+	if !opts.IncludeOriginal {
+		return reply
+	}
+
+	quote, sep := QuoteText(original), "\n\n"
+	if isHTML {
+		quote, sep = QuoteHTML(original), "\n<br>\n"
+	}
+
+	if opts.TopPost {
+		return reply + sep + quote
+	}
+	return quote + sep + reply
+}
+
+// TextToHTML escapes plain text and keeps its line breaks
+func TextToHTML(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ReplaceAll(html.EscapeString(text), "\n", "<br>\n")
+}
+
+// HTMLToText is a rough tag stripper used when quoting HTML into a plain text reply
+func HTMLToText(s string) string {
+	for _, tag := range []string{"<br>", "<br/>", "<br />", "</p>", "</div>", "</li>", "</tr>"} {
+		s = strings.ReplaceAll(s, tag, tag+"\n")
+		s = strings.ReplaceAll(s, strings.ToUpper(tag), strings.ToUpper(tag)+"\n")
+	}
+
+	var b strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+		case !inTag:
+			b.WriteRune(r)
+		}
+	}
+
+	lines := strings.Split(html.UnescapeString(b.String()), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
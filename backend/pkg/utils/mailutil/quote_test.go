@@ -0,0 +1,90 @@
+package mailutil
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var quoteDate = time.Date(2024, time.March, 5, 14, 30, 0, 0, time.UTC)
+
+func TestAttribution(t *testing.T) {
+	if got, want := Attribution("Alice <alice@example.com>", quoteDate), "On Tue, Mar 5, 2024 at 2:30 PM, Alice <alice@example.com> wrote:"; got != want {
+		t.Errorf("Attribution() = %q, want %q", got, want)
+	}
+	if got, want := Attribution("alice@example.com", time.Time{}), "alice@example.com wrote:"; got != want {
+		t.Errorf("Attribution() without a date = %q, want %q", got, want)
+	}
+}
+
+func TestQuoteText(t *testing.T) {
+	tests := []struct {
+		name     string
+		original QuotedMessage
+		want     string
+	}{
+		{
+			"plain",
+			QuotedMessage{From: "Alice", Date: quoteDate, Body: "Lunch?\r\n\r\nNoon works.\n"},
+			"On Tue, Mar 5, 2024 at 2:30 PM, Alice wrote:\n> Lunch?\n>\n> Noon works.",
+		},
+		{
+			"nested quote",
+			QuotedMessage{From: "Alice", Body: "Sure\n> Lunch?"},
+			"Alice wrote:\n> Sure\n>> Lunch?",
+		},
+		{
+			"html original",
+			QuotedMessage{From: "Alice", Body: "<p>Fish &amp; chips</p><p>at <b>noon</b></p>", IsHTML: true},
+			"Alice wrote:\n> Fish & chips\n> at noon",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := QuoteText(tt.original); got != tt.want {
+				t.Errorf("QuoteText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteHTML(t *testing.T) {
+	t.Run("html original", func(t *testing.T) {
+		got := QuoteHTML(QuotedMessage{From: "Alice <alice@example.com>", Date: quoteDate, Body: "<p>Lunch?</p>", IsHTML: true})
+		if !strings.Contains(got, "On Tue, Mar 5, 2024 at 2:30 PM, Alice &lt;alice@example.com&gt; wrote:") {
+			t.Errorf("QuoteHTML() = %q, want the escaped attribution", got)
+		}
+		if !strings.Contains(got, "<p>Lunch?</p></blockquote>") {
+			t.Errorf("QuoteHTML() = %q, want the original inside the blockquote", got)
+		}
+	})
+
+	t.Run("plain original", func(t *testing.T) {
+		got := QuoteHTML(QuotedMessage{From: "Alice", Body: "<script>\nbye"})
+		if !strings.Contains(got, ">&lt;script&gt;<br>\nbye</blockquote>") {
+			t.Errorf("QuoteHTML() = %q, want the text escaped with its line breaks kept", got)
+		}
+	})
+}
+
+func TestBuildReplyBody(t *testing.T) {
+	original := QuotedMessage{From: "Alice", Body: "Lunch?"}
+	tests := []struct {
+		name   string
+		isHTML bool
+		opts   ReplyOptions
+		want   string
+	}{
+		{"bottom post", false, ReplyOptions{IncludeOriginal: true}, "Alice wrote:\n> Lunch?\n\nSure"},
+		{"top post", false, ReplyOptions{TopPost: true, IncludeOriginal: true}, "Sure\n\nAlice wrote:\n> Lunch?"},
+		{"without the original", false, ReplyOptions{TopPost: true}, "Sure"},
+		{"html top post", true, ReplyOptions{TopPost: true, IncludeOriginal: true}, "Sure\n<br>\n" + QuoteHTML(original)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BuildReplyBody("Sure", tt.isHTML, original, tt.opts); got != tt.want {
+				t.Errorf("BuildReplyBody() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
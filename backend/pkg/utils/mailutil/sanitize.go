@@ -0,0 +1,208 @@
+package mailutil
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// droppedElements are removed together with everything inside them: they run code,
+// embed other documents or change how the rest of the page is interpreted
+var droppedElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Noscript: true,
+	atom.Iframe:   true,
+	atom.Frame:    true,
+	atom.Frameset: true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Applet:   true,
+	atom.Base:     true,
+	atom.Meta:     true,
+	atom.Link:     true,
+	atom.Svg:      true,
+	atom.Math:     true,
+	atom.Template: true,
+	atom.Title:    true,
+}
+
+// voidElements never have an end tag, so dropping one must not skip what follows it
+var voidElements = map[atom.Atom]bool{
+	atom.Embed: true,
+	atom.Base:  true,
+	atom.Meta:  true,
+	atom.Link:  true,
+}
+
+// rawTextElements put the tokenizer into raw-text mode even when written
+// self-closing, so "<style/>" still swallows everything up to "</style>"
+var rawTextElements = map[atom.Atom]bool{
+	atom.Iframe:    true,
+	atom.Noembed:   true,
+	atom.Noframes:  true,
+	atom.Noscript:  true,
+	atom.Plaintext: true,
+	atom.Script:    true,
+	atom.Style:     true,
+	atom.Textarea:  true,
+	atom.Title:     true,
+	atom.Xmp:       true,
+}
+
+// strippedElements lose their tags but keep their content
+var strippedElements = map[atom.Atom]bool{
+	atom.Form:   true,
+	atom.Input:  true,
+	atom.Button: true,
+	atom.Select: true,
+	atom.Option: true,
+	atom.Html:   true,
+	atom.Head:   true,
+	atom.Body:   true,
+}
+
+// urlAttributes hold URLs, which must not use a scheme that runs code
+var urlAttributes = map[string]bool{
+	"href":       true,
+	"src":        true,
+	"action":     true,
+	"formaction": true,
+	"background": true,
+	"poster":     true,
+	"cite":       true,
+	"longdesc":   true,
+	"xlink:href": true,
+}
+
+// unsafeStyleMarkers are CSS constructs that run script in some engines
+var unsafeStyleMarkers = []string{"expression(", "javascript:", "vbscript:", "behavior:", "-moz-binding"}
+
+// SanitizeHTML removes active content from an HTML email body: scripts, embedded
+// documents, event handler attributes, script URLs and CSS that can run code. Markup
+// and inline styles used for layout are kept. Remote images are left for
+// RewriteRemoteImages to block or proxy.
+func SanitizeHTML(body string) string {
+	z := html.NewTokenizer(strings.NewReader(body))
+	var b strings.Builder
+	b.Grow(len(body))
+	// depth of droppedElements the tokenizer is inside; their content is skipped
+	dropDepth := 0
+	var dropping atom.Atom
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return b.String()
+		}
+		// Token unescapes in place, so the raw text has to be copied first
+		raw := string(z.Raw())
+		token := z.Token()
+
+		if dropDepth > 0 {
+			switch {
+			case opensElement(tt, token.DataAtom) && token.DataAtom == dropping:
+				dropDepth++
+			case tt == html.EndTagToken && token.DataAtom == dropping:
+				dropDepth--
+			}
+			continue
+		}
+
+		switch tt {
+		case html.CommentToken, html.DoctypeToken:
+			// Conditional comments can carry markup for old clients; nothing needs them
+			continue
+		case html.TextToken:
+			b.WriteString(raw)
+			continue
+		}
+
+		if droppedElements[token.DataAtom] {
+			if opensElement(tt, token.DataAtom) && !voidElements[token.DataAtom] {
+				dropping = token.DataAtom
+				dropDepth = 1
+			}
+			continue
+		}
+		if strippedElements[token.DataAtom] {
+			continue
+		}
+		if token.DataAtom == atom.Style && opensElement(tt, token.DataAtom) {
+			// Style content is raw text; the tokenizer returns it as the next token
+			if z.Next() == html.TextToken {
+				css := string(z.Raw())
+				// Markup has no place in a stylesheet; it only shows up when a
+				// stray tag was swallowed as raw text
+				if !unsafeCSS(css) && !strings.Contains(strings.ToLower(css), "@import") && !strings.Contains(css, "<") {
+					b.WriteString("<style>" + css + "</style>")
+				}
+			}
+			continue
+		}
+		if token.DataAtom == atom.Style {
+			continue
+		}
+
+		token.Attr = sanitizeAttributes(token.Attr)
+		b.WriteString(token.String())
+	}
+}
+
+// opensElement reports whether a tag starts content that runs to a matching end
+// tag. A self-closing raw-text tag does, since the tokenizer treats what follows it
+// as raw text either way.
+func opensElement(tt html.TokenType, a atom.Atom) bool {
+	return tt == html.StartTagToken || (tt == html.SelfClosingTagToken && rawTextElements[a])
+}
+
+func sanitizeAttributes(attrs []html.Attribute) []html.Attribute {
+	kept := attrs[:0]
+	for _, attr := range attrs {
+		key := strings.ToLower(attr.Key)
+		if attr.Namespace != "" {
+			key = attr.Namespace + ":" + key
+		}
+		switch {
+		case strings.HasPrefix(key, "on"):
+			continue
+		case key == "srcdoc":
+			continue
+		case urlAttributes[key] && unsafeURL(attr.Val):
+			continue
+		case key == "style" && unsafeCSS(attr.Val):
+			continue
+		}
+		kept = append(kept, attr)
+	}
+	return kept
+}
+
+// unsafeURL reports whether a URL uses a scheme that runs code. data: is only
+// allowed for images.
+func unsafeURL(value string) bool {
+	// Browsers ignore whitespace and control characters inside the scheme
+	compact := strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(value))
+	switch {
+	case strings.HasPrefix(compact, "javascript:"), strings.HasPrefix(compact, "vbscript:"):
+		return true
+	case strings.HasPrefix(compact, "data:"):
+		return !strings.HasPrefix(compact, "data:image/") || strings.HasPrefix(compact, "data:image/svg")
+	}
+	return false
+}
+
+func unsafeCSS(value string) bool {
+	lower := strings.ToLower(strings.Join(strings.Fields(value), ""))
+	for _, marker := range unsafeStyleMarkers {
+		if strings.Contains(lower, strings.ReplaceAll(marker, " ", "")) {
+			return true
+		}
+	}
+	return false
+}
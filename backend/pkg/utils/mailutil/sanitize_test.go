@@ -0,0 +1,146 @@
+package mailutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeHTMLKeepsFullDocuments(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "head with meta and title",
+			body: `<!DOCTYPE html><html><head><meta http-equiv="Content-Type" content="text/html; charset=utf-8"><title>x</title></head><body><p>Hello world</p></body></html>`,
+			want: `<p>Hello world</p>`,
+		},
+		{
+			name: "link between paragraphs",
+			body: `<p>a</p><link rel="stylesheet" href="https://example.com/a.css"><p>b</p>`,
+			want: `<p>a</p><p>b</p>`,
+		},
+		{
+			name: "self-closing meta and base",
+			body: `<html><head><meta charset="utf-8" /><base href="https://example.com/" /></head><body><div>kept</div></body></html>`,
+			want: `<div>kept</div>`,
+		},
+		{
+			name: "embed without end tag",
+			body: `<p>before</p><embed src="https://example.com/a.swf"><p>after</p>`,
+			want: `<p>before</p><p>after</p>`,
+		},
+		{
+			name: "newsletter layout",
+			body: `<html><head><meta name="viewport" content="width=device-width"><style>td { color: #333; }</style></head>` +
+				`<body><table><tr><td style="padding: 8px">Weekly news</td></tr></table></body></html>`,
+			want: `<style>td { color: #333; }</style><table><tr><td style="padding: 8px">Weekly news</td></tr></table>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeHTML(tt.body); got != tt.want {
+				t.Errorf("SanitizeHTML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeHTMLRemovesActiveContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		notWant string
+	}{
+		{
+			name:    "script in a full document",
+			body:    `<html><head><script>alert(1)</script></head><body><p>Hi</p><script src="x.js"></script></body></html>`,
+			want:    `<p>Hi</p>`,
+			notWant: "alert",
+		},
+		{
+			name:    "event handler",
+			body:    `<img src="cid:logo" onerror="alert(1)">`,
+			want:    `<img src="cid:logo">`,
+			notWant: "onerror",
+		},
+		{
+			name:    "javascript URL",
+			body:    `<a href=" java&#10;script:alert(1)">click</a>`,
+			want:    `<a>click</a>`,
+			notWant: "script:",
+		},
+		{
+			name:    "nested objects",
+			body:    `<object><object data="a.swf"></object><p>hidden</p></object><p>shown</p>`,
+			want:    `<p>shown</p>`,
+			notWant: "hidden",
+		},
+		{
+			name:    "css expression",
+			body:    `<div style="width: expression(alert(1))">x</div>`,
+			want:    `<div>x</div>`,
+			notWant: "expression",
+		},
+		{
+			name:    "svg data image",
+			body:    `<img src="data:image/svg+xml;base64,PHN2Zz4=">`,
+			want:    `<img>`,
+			notWant: "svg",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SanitizeHTML(tt.body)
+			if got != tt.want {
+				t.Errorf("SanitizeHTML() = %q, want %q", got, tt.want)
+			}
+			if strings.Contains(got, tt.notWant) {
+				t.Errorf("SanitizeHTML() = %q still contains %q", got, tt.notWant)
+			}
+		})
+	}
+}
+
+func TestSanitizeHTMLSelfClosingRawTextTags(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "style",
+			body: `<style/><img src=x onerror=alert(1)></style><p>after</p>`,
+			want: `<p>after</p>`,
+		},
+		{
+			name: "script",
+			body: `<script/><img src=x onerror=alert(1)></script><p>after</p>`,
+			want: `<p>after</p>`,
+		},
+		{
+			name: "title",
+			body: `<title/><img src=x onerror=alert(1)></title><p>after</p>`,
+			want: `<p>after</p>`,
+		},
+		{
+			name: "noscript",
+			body: `<noscript/><img src=x onerror=alert(1)></noscript><p>after</p>`,
+			want: `<p>after</p>`,
+		},
+		{
+			name: "iframe",
+			body: `<iframe/><img src=x onerror=alert(1)></iframe><p>after</p>`,
+			want: `<p>after</p>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeHTML(tt.body); got != tt.want {
+				t.Errorf("SanitizeHTML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package mailutil
+
+import (
+	"regexp"
+	"strings"
+)
+
+// signatureDelimiter is the RFC 3676 signature separator line
+const signatureDelimiter = "-- "
+
+// signatureDivRe finds the opening tag of signature blocks written by this app or Gmail
+var signatureDivRe = regexp.MustCompile(`(?i)<div[^>]*class="[^"]*\b(?:gmail_)?signature\b[^"]*"[^>]*>`)
+
+var divTagRe = regexp.MustCompile(`(?i)<(/?)div\b[^>]*>`)
+
+// FormatSignature renders a plain text signature below a delimiter, as HTML when the
+// message body is HTML
+func FormatSignature(signature string, isHTML bool) string {
+	signature = strings.TrimSpace(signature)
+	if signature == "" {
+		return ""
+	}
+	if isHTML {
+		return "<div class=\"signature\">" + TextToHTML(signatureDelimiter+"\n"+signature) + "</div>"
+	}
+	return signatureDelimiter + "\n" + signature
+}
+
+// AppendSignature adds a signature to the end of an HTML body, on its own line. The
+// body is returned unchanged when the signature is empty.
+func AppendSignature(body, signature string) string {
+	formatted := FormatSignature(signature, true)
+	if formatted == "" {
+		return body
+	}
+	return body + "<br>\n" + formatted
+}
+
+// StripSignatures removes signature blocks from a message, so quoting a long thread
+// doesn't repeat everyone's signature
+func StripSignatures(body string, isHTML bool) string {
+	if isHTML {
+		return stripHTMLSignatures(body)
+	}
+	return stripTextSignatures(body)
+}
+
+// stripTextSignatures drops each "-- " block, including ones inside quoted text. A block
+// ends where the quote depth changes.
+func stripTextSignatures(body string) string {
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	var kept []string
+	sigDepth := -1
+	for _, line := range lines {
+		depth, content := quoteDepth(line)
+		if sigDepth >= 0 && depth == sigDepth {
+			continue
+		}
+		sigDepth = -1
+		// Many clients drop the trailing space of the delimiter
+		if strings.TrimRight(content, " ") == "--" {
+			sigDepth = depth
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.TrimRight(strings.Join(kept, "\n"), "\n")
+}
+
+// quoteDepth counts the leading ">" markers of a line and returns the rest of it
+func quoteDepth(line string) (int, string) {
+	depth := 0
+	for {
+		trimmed := strings.TrimLeft(line, " ")
+		if !strings.HasPrefix(trimmed, ">") {
+			return depth, line
+		}
+		depth++
+		line = strings.TrimPrefix(trimmed[1:], " ")
+	}
+}
+
+// stripHTMLSignatures removes each signature div together with its nested content
+func stripHTMLSignatures(body string) string {
+	for {
+		loc := signatureDivRe.FindStringIndex(body)
+		if loc == nil {
+			return body
+		}
+
+		depth := 1
+		end := len(body)
+		for _, tag := range divTagRe.FindAllStringSubmatchIndex(body[loc[1]:], -1) {
+			closing := tag[3] > tag[2]
+			if closing {
+				depth--
+			} else {
+				depth++
+			}
+			if depth == 0 {
+				end = loc[1] + tag[1]
+				break
+			}
+		}
+		body = body[:loc[0]] + body[end:]
+	}
+}
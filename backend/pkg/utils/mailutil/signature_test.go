@@ -0,0 +1,48 @@
+package mailutil
+
+import "testing"
+
+func TestFormatSignature(t *testing.T) {
+	if got, want := FormatSignature("  Bob\nACME  ", false), "-- \nBob\nACME"; got != want {
+		t.Errorf("FormatSignature(text) = %q, want %q", got, want)
+	}
+	if got, want := FormatSignature("Bob & Co", true), "<div class=\"signature\">-- <br>\nBob &amp; Co</div>"; got != want {
+		t.Errorf("FormatSignature(html) = %q, want %q", got, want)
+	}
+	if got := AppendSignature("<p>Hi</p>", " \n"); got != "<p>Hi</p>" {
+		t.Errorf("AppendSignature() with a blank signature = %q, want the body unchanged", got)
+	}
+}
+
+func TestStripSignaturesText(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"own signature", "See you at noon\n-- \nBob\nACME", "See you at noon"},
+		{"delimiter without the space", "See you\n--\nBob", "See you"},
+		{
+			"quoted signatures",
+			"Great!\n\nBob wrote:\n> See you\n>\n> Alice wrote:\n>> Lunch?\n>> --\n>> Alice\n> -- \n> Bob\n\nMore below",
+			"Great!\n\nBob wrote:\n> See you\n>\n> Alice wrote:\n>> Lunch?\n\nMore below",
+		},
+		{"a dash line in text", "Scores: 3 -- 1\nok", "Scores: 3 -- 1\nok"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripSignatures(tt.body, false); got != tt.want {
+				t.Errorf("StripSignatures() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripSignaturesHTML(t *testing.T) {
+	body := `<p>Great!</p><blockquote><p>See you</p>` + FormatSignature("Bob", true) +
+		`<blockquote><p>Lunch?</p><div dir="ltr" class="gmail_signature"><div>Alice</div><div>Sales</div></div></blockquote></blockquote><p>end</p>`
+	want := `<p>Great!</p><blockquote><p>See you</p><blockquote><p>Lunch?</p></blockquote></blockquote><p>end</p>`
+	if got := StripSignatures(body, true); got != want {
+		t.Errorf("StripSignatures() =\n%s\nwant\n%s", got, want)
+	}
+}
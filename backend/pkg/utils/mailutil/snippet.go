@@ -0,0 +1,111 @@
+package mailutil
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Snippet returns the part of text around the first occurrence of any term, with every
+// occurrence wrapped in <mark>. The result is HTML-escaped. radius is the number of
+// characters kept on each side of the first match. It returns "" when no term occurs.
+func Snippet(text string, terms []string, radius int) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	// marked[i] is set for every rune inside a match
+	marked := make([]bool, len(runes))
+	first, firstEnd := -1, -1
+	for _, term := range terms {
+		needle := []rune(strings.ToLower(term))
+		if len(needle) == 0 {
+			continue
+		}
+		for i := 0; i+len(needle) <= len(lower); i++ {
+			if !hasRunePrefix(lower[i:], needle) {
+				continue
+			}
+			for j := i; j < i+len(needle); j++ {
+				marked[j] = true
+			}
+			if first == -1 || i < first {
+				first, firstEnd = i, i+len(needle)
+			}
+		}
+	}
+	if first == -1 {
+		return ""
+	}
+
+	start := first - radius
+	if start < 0 {
+		start = 0
+	}
+	end := first + radius
+	// A match longer than radius is still kept whole
+	if end < firstEnd {
+		end = firstEnd
+	}
+	if end > len(runes) {
+		end = len(runes)
+	}
+	// Don't cut words in half
+	for start > 0 && runes[start-1] != ' ' && !marked[start] {
+		start++
+	}
+	for end < len(runes) && runes[end] != ' ' && !marked[end-1] {
+		end--
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		j := i
+		for j < end && marked[j] == marked[i] {
+			j++
+		}
+		part := html.EscapeString(string(runes[i:j]))
+		if marked[i] {
+			b.WriteString("<mark>" + part + "</mark>")
+		} else {
+			b.WriteString(part)
+		}
+		i = j
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func hasRunePrefix(s, prefix []rune) bool {
+	for i, r := range prefix {
+		if s[i] != r {
+			return false
+		}
+	}
+	return true
+}
+
+// Truncate shortens text to at most limit characters followed by "...", cutting at
+// the last space when there is one in the second half so words aren't split.
+// Counting runes rather than bytes keeps multi-byte characters intact.
+func Truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	end := limit
+	for i := limit; i > limit/2; i-- {
+		if unicode.IsSpace(runes[i]) {
+			end = i
+			break
+		}
+	}
+	return strings.TrimRightFunc(string(runes[:end]), unicode.IsSpace) + "..."
+}
@@ -0,0 +1,73 @@
+package mailutil
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		terms  []string
+		radius int
+		want   string
+	}{
+		{"whole text", "Lunch at noon?", []string{"noon"}, 80, "Lunch at <mark>noon</mark>?"},
+		{"case insensitive", "Budget REVIEW on Friday", []string{"review"}, 80, "Budget <mark>REVIEW</mark> on Friday"},
+		{"every occurrence", "the plan and the other plan", []string{"plan"}, 80, "the <mark>plan</mark> and the other <mark>plan</mark>"},
+		{"several terms", "Invoice attached for March", []string{"march", "invoice"}, 80, "<mark>Invoice</mark> attached for <mark>March</mark>"},
+		{"whitespace collapsed", "Hi\n\n  there,\tteam", []string{"team"}, 80, "Hi there, <mark>team</mark>"},
+		{"escaped", "<b>deadline</b> & more", []string{"deadline"}, 80, "&lt;b&gt;<mark>deadline</mark>&lt;/b&gt; &amp; more"},
+		{"accented", "Hẹn gặp lúc TRƯA nhé", []string{"trưa"}, 80, "Hẹn gặp lúc <mark>TRƯA</mark> nhé"},
+		{"cut at words", "one two three four five six seven", []string{"four"}, 8, "…three <mark>four</mark>…"},
+		{"no match", "Lunch at noon?", []string{"dinner"}, 80, ""},
+		{"empty term", "Lunch", []string{""}, 80, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Snippet(tt.text, tt.terms, tt.radius); got != tt.want {
+				t.Errorf("Snippet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnippetKeepsLongMatch(t *testing.T) {
+	text := strings.Repeat("filler ", 50) + "supercalifragilistic " + strings.Repeat("tail ", 50)
+	got := Snippet(text, []string{"supercalifragilistic"}, 5)
+	if !strings.Contains(got, "<mark>supercalifragilistic</mark>") {
+		t.Errorf("Snippet() = %q, want the whole match kept", got)
+	}
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") {
+		t.Errorf("Snippet() = %q, want ellipses on both sides", got)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  string
+	}{
+		{"short", "Hẹn gặp", 10, "Hẹn gặp"},
+		{"exactly the limit", "Hẹn gặp", 7, "Hẹn gặp"},
+		{"at a word boundary", "Hẹn gặp lúc trưa nhé", 14, "Hẹn gặp lúc..."},
+		// An accented character sits right at the limit and isn't split
+		{"no space to cut at", "Trưaaaaaaaa", 3, "Trư..."},
+		{"space in the first half ignored", "Hi Đđđđđđđđđđđ", 10, "Hi Đđđđđđđ..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Truncate(tt.text, tt.limit)
+			if got != tt.want {
+				t.Errorf("Truncate() = %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("Truncate() = %q is not valid UTF-8", got)
+			}
+		})
+	}
+}
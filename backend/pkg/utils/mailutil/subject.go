@@ -0,0 +1,25 @@
+package mailutil
+
+import "strings"
+
+var replyPrefixes = []string{"re:", "fw:", "fwd:", "tr:", "aw:", "wg:"}
+
+// NormalizeSubject strips reply/forward prefixes (possibly repeated) and surrounding
+// whitespace so messages of one conversation share a subject
+func NormalizeSubject(subject string) string {
+	subject = strings.TrimSpace(subject)
+	for {
+		lower := strings.ToLower(subject)
+		trimmed := false
+		for _, prefix := range replyPrefixes {
+			if strings.HasPrefix(lower, prefix) {
+				subject = strings.TrimSpace(subject[len(prefix):])
+				trimmed = true
+				break
+			}
+		}
+		if !trimmed {
+			return subject
+		}
+	}
+}
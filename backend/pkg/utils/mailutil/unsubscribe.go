@@ -0,0 +1,58 @@
+package mailutil
+
+import (
+	"net/url"
+	"strings"
+)
+
+// ParseListUnsubscribe extracts the first HTTP(S) URL and the first mailto URI from a
+// List-Unsubscribe header (RFC 2369) and reports whether the sender supports
+// one-click unsubscribe via List-Unsubscribe-Post (RFC 8058).
+func ParseListUnsubscribe(header, postHeader string) (httpURL, mailto string, oneClick bool) {
+	for _, entry := range strings.Split(header, ",") {
+		entry = strings.TrimSpace(entry)
+		entry = strings.TrimPrefix(entry, "<")
+		entry = strings.TrimSuffix(entry, ">")
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		lower := strings.ToLower(entry)
+		switch {
+		case strings.HasPrefix(lower, "mailto:"):
+			if mailto == "" {
+				mailto = entry
+			}
+		case strings.HasPrefix(lower, "https://"), strings.HasPrefix(lower, "http://"):
+			if httpURL == "" {
+				httpURL = entry
+			}
+		}
+	}
+
+	oneClick = httpURL != "" && strings.Contains(strings.ToLower(postHeader), "list-unsubscribe=one-click")
+	return httpURL, mailto, oneClick
+}
+
+// ParseMailto splits a mailto URI into recipient, subject and body
+func ParseMailto(mailto string) (to, subject, body string, err error) {
+	u, err := url.Parse(mailto)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	to = u.Opaque
+	if to == "" {
+		to = u.Path
+	}
+	to, err = url.PathUnescape(to)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	query := u.Query()
+	subject = query.Get("subject")
+	body = query.Get("body")
+	return to, subject, body, nil
+}
@@ -0,0 +1,77 @@
+package mailutil
+
+import "testing"
+
+func TestParseListUnsubscribe(t *testing.T) {
+	tests := []struct {
+		name         string
+		header, post string
+		wantURL      string
+		wantMailto   string
+		wantOneClick bool
+	}{
+		{
+			name:       "mailto only",
+			header:     "<mailto:unsubscribe@example.com?subject=unsubscribe>",
+			wantMailto: "mailto:unsubscribe@example.com?subject=unsubscribe",
+		},
+		{
+			name:    "url only without post header",
+			header:  "<https://example.com/unsub?id=42>",
+			wantURL: "https://example.com/unsub?id=42",
+		},
+		{
+			name:         "url and mailto with one-click",
+			header:       "<mailto:leave@example.com>, <https://example.com/u/abc>",
+			post:         "List-Unsubscribe=One-Click",
+			wantURL:      "https://example.com/u/abc",
+			wantMailto:   "mailto:leave@example.com",
+			wantOneClick: true,
+		},
+		{
+			name:         "one-click is case insensitive",
+			header:       "<https://example.com/u>",
+			post:         "list-unsubscribe=one-click",
+			wantURL:      "https://example.com/u",
+			wantOneClick: true,
+		},
+		{
+			name:       "one-click without a url",
+			header:     "<mailto:leave@example.com>",
+			post:       "List-Unsubscribe=One-Click",
+			wantMailto: "mailto:leave@example.com",
+		},
+		{
+			name:       "first of each kind wins",
+			header:     "<http://a.example.com/u>,<https://b.example.com/u>, <MAILTO:a@example.com>,<mailto:b@example.com>",
+			wantURL:    "http://a.example.com/u",
+			wantMailto: "MAILTO:a@example.com",
+		},
+		{
+			name:   "other schemes ignored",
+			header: "<javascript:alert(1)>, <ftp://example.com/u>",
+		},
+		{
+			name: "empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotURL, gotMailto, gotOneClick := ParseListUnsubscribe(tt.header, tt.post)
+			if gotURL != tt.wantURL || gotMailto != tt.wantMailto || gotOneClick != tt.wantOneClick {
+				t.Errorf("ParseListUnsubscribe() = (%q, %q, %v), want (%q, %q, %v)",
+					gotURL, gotMailto, gotOneClick, tt.wantURL, tt.wantMailto, tt.wantOneClick)
+			}
+		})
+	}
+}
+
+func TestParseMailto(t *testing.T) {
+	to, subject, body, err := ParseMailto("mailto:list%2Bleave@example.com?subject=Unsubscribe%20me&body=please")
+	if err != nil {
+		t.Fatalf("ParseMailto() error = %v", err)
+	}
+	if to != "list+leave@example.com" || subject != "Unsubscribe me" || body != "please" {
+		t.Errorf("ParseMailto() = (%q, %q, %q)", to, subject, body)
+	}
+}
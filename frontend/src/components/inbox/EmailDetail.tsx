@@ -1,3 +1,4 @@
+import { useState } from "react";
 import { useQuery, useMutation, useQueryClient } from "@tanstack/react-query";
 import { emailService } from "@/services/email.service";
 import { format } from "date-fns";
@@ -29,9 +30,13 @@ export default function EmailDetail({
     const queryClient = useQueryClient();
     const { user } = useAppSelector((state) => state.auth);
 
+    // Remote images are blocked until the user asks for them, per email
+    const [imagesLoadedFor, setImagesLoadedFor] = useState<string | null>(null);
+    const loadImages = !!emailId && imagesLoadedFor === emailId;
+
     const { data: email, isLoading } = useQuery<Email>({
         queryKey: ["email", emailId],
-        queryFn: () => emailService.getEmailById(emailId!),
+        queryFn: () => emailService.getEmailById(emailId!, loadImages),
         enabled: !!emailId,
     });
 
@@ -529,6 +534,23 @@ export default function EmailDetail({
                         </div>
                     </div>
 
+                    {email.images_blocked && !loadImages && (
+                        <div className="flex items-center justify-between gap-2 rounded-md border border-gray-200 bg-gray-50 px-3 py-2 mb-4 text-sm text-gray-700">
+                            <span>Hình ảnh bên ngoài đã bị chặn để bảo vệ quyền riêng tư.</span>
+                            <Button
+                                variant="outline"
+                                size="sm"
+                                onClick={async () => {
+                                    setImagesLoadedFor(emailId);
+                                    const loaded = await emailService.getEmailById(emailId!, true);
+                                    queryClient.setQueryData(["email", emailId], loaded);
+                                }}
+                            >
+                                Tải hình ảnh
+                            </Button>
+                        </div>
+                    )}
+
                     {/* Email Body */}
                     <div className="prose prose-sm max-w-none text-gray-900 leading-relaxed mb-4">
                         {email.is_html ? (
@@ -29,6 +29,8 @@ export default function EmailList({
     const [currentPage, setCurrentPage] = useState(1);
     const [cachedData, setCachedData] = useState<EmailsResponse | null>(null);
     const [selectedIds, setSelectedIds] = useState<Set<string>>(new Set());
+    // Gmail page tokens by "mailbox|query|page", so paging forward needs no offset scan
+    const [pageTokens, setPageTokens] = useState<Record<string, string>>({});
     const queryClient = useQueryClient();
     const offset = (currentPage - 1) * ITEMS_PER_PAGE;
     const cacheKey = `emails-${mailboxId}-${offset}-${debouncedSearchQuery}`;
@@ -53,12 +55,18 @@ export default function EmailList({
     const { data, isLoading, isFetching, refetch } = useQuery({
         queryKey: ["emails", mailboxId, offset, debouncedSearchQuery],
         queryFn: async () => {
+            const pageKey = (page: number) => `${mailboxId}|${debouncedSearchQuery}|${page}`;
             const result = await emailService.getEmailsByMailbox(
                 mailboxId!,
                 ITEMS_PER_PAGE,
                 offset,
-                debouncedSearchQuery
+                debouncedSearchQuery,
+                pageTokens[pageKey(currentPage)]
             );
+            const nextPageToken = result.next_page_token;
+            if (nextPageToken) {
+                setPageTokens((prev) => ({ ...prev, [pageKey(currentPage + 1)]: nextPageToken }));
+            }
             saveToCache(cacheKey, result);
             return result;
         },
@@ -46,6 +46,16 @@ const refreshAccessToken = async (): Promise<string> => {
         return newAccessToken;
     } catch (error) {
         setAccessToken(null);
+        // A reused refresh token revokes the session everywhere, so sign out the other tabs too
+        if (
+            axios.isAxiosError(error) &&
+            (error.response?.data as { reason?: string } | undefined)?.reason ===
+                "refresh_token_reused"
+        ) {
+            const channel = new BroadcastChannel("auth_channel");
+            channel.postMessage({ type: "LOGOUT" });
+            channel.close();
+        }
         throw error;
     }
 };
@@ -61,6 +71,8 @@ apiClient.interceptors.response.use(
         const isAuthEndpoint =
             originalRequest.url?.includes("/auth/login") ||
             originalRequest.url?.includes("/auth/register") ||
+            originalRequest.url?.includes("/auth/imap") ||
+            originalRequest.url?.includes("/auth/change-password") ||
             originalRequest.url?.includes("/auth/refresh") ||
             originalRequest.url?.includes("/auth/logout") ||
             originalRequest.url?.includes("/auth/google");
@@ -104,4 +116,31 @@ apiClient.interceptors.response.use(
     }
 );
 
+// Machine-readable error codes the API sends alongside the message in `error`
+export type ApiErrorCode =
+    | "bad_request"
+    | "unauthorized"
+    | "reauth_required"
+    | "forbidden"
+    | "not_found"
+    | "conflict"
+    | "quota_exceeded"
+    | "provider_failure"
+    | "unavailable"
+    | "internal_error";
+
+export interface ApiErrorBody {
+    error: string;
+    code?: ApiErrorCode;
+}
+
+// getApiErrorCode returns the code of a failed API call, so callers can tell e.g. an
+// expired mail provider sign-in (reauth_required) from a transient failure
+export const getApiErrorCode = (error: unknown): ApiErrorCode | undefined => {
+    if (!axios.isAxiosError(error)) {
+        return undefined;
+    }
+    return (error.response?.data as ApiErrorBody | undefined)?.code;
+};
+
 export default apiClient;
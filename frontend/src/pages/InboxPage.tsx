@@ -5,7 +5,7 @@ import { logout } from "@/store/authSlice";
 import { authService } from "@/services/auth.service";
 import { emailService } from "@/services/email.service";
 import { getAccessToken } from "@/lib/api-client";
-import type { Email } from "@/types/email";
+import type { Email, EmailsResponse, Mailbox } from "@/types/email";
 import MailboxList from "@/components/inbox/MailboxList";
 import EmailList from "@/components/inbox/EmailList";
 import EmailDetail from "@/components/inbox/EmailDetail";
@@ -89,7 +89,24 @@ export default function InboxPage() {
       eventSource.onmessage = (event) => {
         try {
           const data = JSON.parse(event.data);
-          if (data.type === "email_update") {
+          if (data.type === "new_email") {
+            // Gmail arrival pushed in full, prepend it to the first inbox page
+            const message: Email | undefined = data.payload?.message;
+            if (!message) return;
+            queryClient.setQueriesData<EmailsResponse>(
+              {
+                queryKey: ["emails"],
+                predicate: (query) =>
+                  String(query.queryKey[1]).toLowerCase() === "inbox" &&
+                  query.queryKey[2] === 0 &&
+                  !query.queryKey[3],
+              },
+              (old) =>
+                old && !old.emails.some((e) => e.id === message.id)
+                  ? { ...old, emails: [message, ...old.emails], total: old.total + 1 }
+                  : old
+            );
+          } else if (data.type === "email_update") {
             console.log("Received email update:", data.payload);
 
             // Ignore SSE updates for 3 seconds after user actions to prevent conflicts
@@ -108,6 +125,14 @@ export default function InboxPage() {
               queryKey: ["mailboxes"],
               refetchType: "none",
             });
+          } else if (data.type === "mailbox_counts") {
+            // Unread badges after read/unread/move actions, no refetch needed
+            const counts: Record<string, number> = data.payload?.counts ?? {};
+            queryClient.setQueryData<Mailbox[]>(["mailboxes"], (old) =>
+              old?.map((mb) =>
+                counts[mb.id] !== undefined ? { ...mb, count: counts[mb.id] } : mb
+              )
+            );
           }
         } catch (error) {
           console.error("Error parsing SSE message:", error);
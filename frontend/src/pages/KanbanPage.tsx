@@ -106,7 +106,7 @@ export default function KanbanPage() {
   // Snooze mutation
   const snoozeEmailMutation = useMutation({
     mutationFn: async (emailId: string) => {
-      await emailService.moveEmailToMailbox(emailId, "snoozed");
+      await emailService.updateKanbanStatus(emailId, "snoozed");
     },
     onSuccess: () => {
       queryClient.invalidateQueries({ queryKey: ["emails"] });
@@ -116,7 +116,7 @@ export default function KanbanPage() {
   // Wake up mutation
   const wakeUpEmailMutation = useMutation({
     mutationFn: async (emailId: string) => {
-      await emailService.moveEmailToMailbox(emailId, "inbox");
+      await emailService.updateKanbanStatus(emailId, "inbox");
     },
     onSuccess: () => {
       queryClient.invalidateQueries({ queryKey: ["emails"] });
@@ -132,7 +132,7 @@ export default function KanbanPage() {
       emailId: string;
       mailboxId: string;
     }) => {
-      await emailService.moveEmailToMailbox(emailId, mailboxId);
+      await emailService.updateKanbanStatus(emailId, mailboxId);
     },
     onSuccess: () => {
       queryClient.invalidateQueries({ queryKey: ["emails"] });
@@ -412,6 +412,11 @@ export default function KanbanPage() {
               queryKey: ["mailboxes"],
               refetchType: "none",
             });
+          } else if (data.type === "email_unsnoozed") {
+            // A snooze ran out on the server, move the card back to Inbox
+            queryClient.invalidateQueries({
+              queryKey: ["emails", "kanban"],
+            });
           }
         } catch (error) {
           console.error("Error parsing SSE message:", error);
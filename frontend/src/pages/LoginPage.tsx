@@ -26,6 +26,11 @@ const imapLoginSchema = z.object({
   password: z.string().min(1, "Password is required"),
   imapServer: z.string().min(1, "IMAP server is required").trim(),
   imapPort: z.union([z.string(), z.number()]).transform((val) => Number(val)),
+  smtpServer: z.string().trim().optional(),
+  smtpPort: z
+    .union([z.string(), z.number()])
+    .optional()
+    .transform((val) => (val === "" || val === undefined ? undefined : Number(val))),
 });
 
 type LoginFormData = z.infer<typeof loginSchema>;
@@ -111,6 +116,14 @@ export default function LoginPage() {
           code: codeResponse.code,
           scope: codeResponse.scope ? codeResponse.scope.split(" ") : [],
         });
+        if (data.needs_gmail_consent) {
+          // Signed in, but the inbox needs mail access; the button asks for it again
+          const consentMessage =
+            "Vui lòng cấp quyền truy cập Gmail để sử dụng hộp thư. Hãy đăng nhập Google lại và chọn cho phép.";
+          setError(consentMessage);
+          toast.error(consentMessage, { id: loadingToast });
+          return;
+        }
         toast.success("Đăng nhập thành công!", { id: loadingToast });
         dispatch(setUser(data.user));
         navigate("/inbox");
@@ -129,7 +142,7 @@ export default function LoginPage() {
       toast.error(errorMessage);
     },
     scope:
-      "email profile https://www.googleapis.com/auth/gmail.readonly https://www.googleapis.com/auth/gmail.modify",
+      "email profile https://www.googleapis.com/auth/gmail.readonly https://www.googleapis.com/auth/gmail.modify https://www.googleapis.com/auth/gmail.settings.basic https://mail.google.com/",
   });
 
   return (
@@ -346,6 +359,41 @@ export default function LoginPage() {
                   </div>
                 </div>
 
+                <div className="grid grid-cols-2 gap-4">
+                  <div className="space-y-2">
+                    <Label htmlFor="smtp-server" className="text-gray-200">
+                      SMTP Server (optional)
+                    </Label>
+                    <Input
+                      id="smtp-server"
+                      type="text"
+                      placeholder="smtp.gmail.com"
+                      {...registerImap("smtpServer")}
+                      disabled={isSubmittingImap}
+                      className="bg-gray-700 border-gray-600 text-white placeholder:text-gray-500 focus:border-blue-500 focus:ring-blue-500"
+                    />
+                  </div>
+
+                  <div className="space-y-2">
+                    <Label htmlFor="smtp-port" className="text-gray-200">
+                      Port
+                    </Label>
+                    <Input
+                      id="smtp-port"
+                      type="text"
+                      placeholder="587"
+                      {...registerImap("smtpPort")}
+                      disabled={isSubmittingImap}
+                      className="bg-gray-700 border-gray-600 text-white placeholder:text-gray-500 focus:border-blue-500 focus:ring-blue-500"
+                    />
+                    {errorsImap.smtpPort && (
+                      <p className="text-sm text-red-400">
+                        {errorsImap.smtpPort.message}
+                      </p>
+                    )}
+                  </div>
+                </div>
+
                 <div className="p-3 bg-blue-900/20 border border-blue-800 rounded-lg">
                   <p className="text-xs text-blue-300">
                     <strong>Common IMAP Servers:</strong>
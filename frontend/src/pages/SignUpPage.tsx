@@ -79,6 +79,13 @@ export default function SignUpPage() {
           code: codeResponse.code,
           scope: codeResponse.scope ? codeResponse.scope.split(" ") : [],
         });
+        if (data.needs_gmail_consent) {
+          // Signed up, but the inbox needs mail access; the button asks for it again
+          const consentMessage = "Vui lòng cấp quyền truy cập Gmail để sử dụng hộp thư. Hãy đăng ký Google lại và chọn cho phép.";
+          setError(consentMessage);
+          toast.error(consentMessage, { id: loadingToast });
+          return;
+        }
         toast.success('Đăng ký thành công!', { id: loadingToast });
         dispatch(setUser(data.user));
         navigate("/inbox");
@@ -95,7 +102,7 @@ export default function SignUpPage() {
       toast.error(errorMessage);
     },
     scope:
-      "email profile https://www.googleapis.com/auth/gmail.readonly https://www.googleapis.com/auth/gmail.modify",
+      "email profile https://www.googleapis.com/auth/gmail.readonly https://www.googleapis.com/auth/gmail.modify https://www.googleapis.com/auth/gmail.settings.basic https://mail.google.com/",
   });
 
   return (
@@ -7,6 +7,9 @@ import type {
   GoogleSignInRequest,
   RefreshTokenRequest,
   ImapLoginRequest,
+  LinkedAccount,
+  LinkImapAccountRequest,
+  Session,
   User,
 } from "@/types/auth";
 
@@ -44,11 +47,104 @@ export const authService = {
     return response.data;
   },
 
+  // Always resolves the same way, whether or not the account exists
+  forgotPassword: async (email: string): Promise<void> => {
+    await apiClient.post("/auth/forgot-password", { email });
+  },
+
+  // token comes from the emailed /reset-password?token=... link
+  resetPassword: async (token: string, password: string): Promise<void> => {
+    await apiClient.post("/auth/reset-password", { token, password });
+  },
+
+  // Signs out other sessions; this one continues with the returned tokens
+  changePassword: async (
+    oldPassword: string,
+    newPassword: string
+  ): Promise<TokenResponse> => {
+    const response = await apiClient.post<TokenResponse>(
+      "/auth/change-password",
+      { old_password: oldPassword, new_password: newPassword }
+    );
+    setAccessToken(response.data.access_token);
+    return response.data;
+  },
+
   getMe: async (): Promise<{ user: User }> => {
     const response = await apiClient.get<{ user: User }>("/auth/me");
     return { user: response.data.user };
   },
 
+  getSignature: async (): Promise<string> => {
+    const response = await apiClient.get<{ signature: string }>(
+      "/auth/signature"
+    );
+    return response.data.signature;
+  },
+
+  updateSignature: async (signature: string): Promise<string> => {
+    const response = await apiClient.put<{ signature: string }>(
+      "/auth/signature",
+      { signature }
+    );
+    return response.data.signature;
+  },
+
+  getLinkedAccounts: async (): Promise<LinkedAccount[]> => {
+    const response = await apiClient.get<{ accounts: LinkedAccount[] }>(
+      "/auth/accounts"
+    );
+    return response.data.accounts;
+  },
+
+  linkImapAccount: async (
+    data: LinkImapAccountRequest
+  ): Promise<LinkedAccount> => {
+    const response = await apiClient.post<LinkedAccount>(
+      "/auth/accounts/imap",
+      data
+    );
+    return response.data;
+  },
+
+  linkGoogleAccount: async (
+    code: string,
+    scope: string[],
+    displayName?: string
+  ): Promise<LinkedAccount> => {
+    const response = await apiClient.post<LinkedAccount>(
+      "/auth/accounts/google",
+      { code, scope, displayName }
+    );
+    return response.data;
+  },
+
+  unlinkAccount: async (id: string): Promise<void> => {
+    await apiClient.delete(`/auth/accounts/${id}`);
+  },
+
+  getSessions: async (): Promise<Session[]> => {
+    const response = await apiClient.get<{ sessions: Session[] }>(
+      "/auth/sessions"
+    );
+    return response.data.sessions;
+  },
+
+  revokeSession: async (id: string): Promise<void> => {
+    await apiClient.delete(`/auth/sessions/${id}`);
+  },
+
+  logoutAll: async (): Promise<void> => {
+    try {
+      await apiClient.post("/auth/logout-all", {});
+    } finally {
+      setAccessToken(null);
+      const channel = new BroadcastChannel("auth_channel");
+      channel.postMessage({ type: "LOGOUT" });
+      channel.close();
+    }
+  },
+
   logout: async (): Promise<void> => {
     try {
       await apiClient.post("/auth/logout", {});
@@ -1,7 +1,36 @@
 import apiClient from "@/lib/api-client";
-import type { Mailbox, Email, EmailsResponse } from "@/types/email";
+import type {
+  Mailbox,
+  Email,
+  EmailsResponse,
+  UnifiedInboxResponse,
+  BatchAction,
+  BatchResponse,
+  Draft,
+  EmailCategory,
+  EmailTranslation,
+  Contact,
+} from "@/types/email";
 
 export const emailService = {
+  getUnifiedInbox: async (
+    limit = 20,
+    offset = 0
+  ): Promise<UnifiedInboxResponse> => {
+    const response = await apiClient.get<UnifiedInboxResponse>(
+      "/emails/unified",
+      { params: { limit, offset } }
+    );
+    return response.data;
+  },
+
+  searchContacts: async (query: string, limit = 10): Promise<Contact[]> => {
+    const response = await apiClient.get<{ contacts: Contact[] }>("/contacts", {
+      params: { q: query, limit },
+    });
+    return response.data.contacts;
+  },
+
   getEmailsByStatus: async (
     status: string,
     limit = 50,
@@ -15,12 +44,39 @@ export const emailService = {
     );
     return response.data;
   },
-  getEmailSummary: async (emailId: string): Promise<string> => {
+  getEmailSummary: async (emailId: string, force = false): Promise<string> => {
     const response = await apiClient.get<{ summary: string }>(
-      `/emails/${emailId}/summary`
+      `/emails/${emailId}/summary`,
+      { params: force ? { force: true } : undefined }
     );
     return response.data.summary;
   },
+  translateEmail: async (
+    emailId: string,
+    lang: string
+  ): Promise<EmailTranslation> => {
+    const response = await apiClient.get<EmailTranslation>(
+      `/emails/${emailId}/translate`,
+      { params: { lang } }
+    );
+    return response.data;
+  },
+  suggestReplies: async (emailId: string): Promise<string[]> => {
+    const response = await apiClient.get<{ suggestions: string[] }>(
+      `/emails/${emailId}/suggest-replies`
+    );
+    return response.data.suggestions;
+  },
+  categorizeEmail: async (
+    emailId: string,
+    apply = false
+  ): Promise<EmailCategory> => {
+    const response = await apiClient.get<EmailCategory>(
+      `/emails/${emailId}/categorize`,
+      { params: apply ? { apply: true } : undefined }
+    );
+    return response.data;
+  },
   moveEmailToMailbox: async (
     emailId: string,
     mailboxId: string
@@ -29,6 +85,9 @@ export const emailService = {
       mailbox_id: mailboxId,
     });
   },
+  updateKanbanStatus: async (emailId: string, status: string): Promise<void> => {
+    await apiClient.patch(`/emails/${emailId}/status`, { status });
+  },
   snoozeEmail: async (emailId: string, snoozeUntil: Date): Promise<void> => {
     await apiClient.post(`/emails/${emailId}/snooze`, {
       snooze_until: snoozeUntil.toISOString(),
@@ -50,19 +109,22 @@ export const emailService = {
     mailboxId: string,
     limit = 50,
     offset = 0,
-    q = ""
+    q = "",
+    pageToken?: string
   ): Promise<EmailsResponse> => {
     const response = await apiClient.get<EmailsResponse>(
       `/emails/mailboxes/${mailboxId}/emails`,
       {
-        params: { limit, offset, q },
+        params: pageToken ? { limit, offset, q, pageToken } : { limit, offset, q },
       }
     );
     return response.data;
   },
 
-  getEmailById: async (id: string): Promise<Email> => {
-    const response = await apiClient.get<Email>(`/emails/${id}`);
+  getEmailById: async (id: string, loadImages = false): Promise<Email> => {
+    const response = await apiClient.get<Email>(`/emails/${id}`, {
+      params: loadImages ? { images: "load" } : undefined,
+    });
     return response.data;
   },
 
@@ -79,13 +141,18 @@ export const emailService = {
     return response.data;
   },
 
+  toggleImportant: async (id: string): Promise<void> => {
+    await apiClient.patch(`/emails/${id}/important`);
+  },
+
   sendEmail: async (
     to: string,
     cc: string,
     bcc: string,
     subject: string,
     body: string,
-    files: File[] = []
+    files: File[] = [],
+    sign = false // Append the saved signature server-side
   ): Promise<void> => {
     const formData = new FormData();
     formData.append("to", to);
@@ -93,6 +160,9 @@ export const emailService = {
     formData.append("bcc", bcc);
     formData.append("subject", subject);
     formData.append("body", body);
+    if (sign) {
+      formData.append("sign", "true");
+    }
     files.forEach((file) => {
       formData.append("files", file);
     });
@@ -112,6 +182,64 @@ export const emailService = {
     await apiClient.post(`/emails/${id}/archive`);
   },
 
+  markAsSpam: async (id: string): Promise<void> => {
+    await apiClient.post(`/emails/${id}/spam`);
+  },
+
+  markNotSpam: async (id: string): Promise<void> => {
+    await apiClient.post(`/emails/${id}/not-spam`);
+  },
+
+  // Permanent, only allowed for emails already in Trash or Spam
+  deleteEmail: async (id: string): Promise<void> => {
+    await apiClient.delete(`/emails/${id}`);
+  },
+
+  emptyTrash: async (): Promise<number> => {
+    const response = await apiClient.post<{ deleted: number }>(
+      "/emails/trash/empty"
+    );
+    return response.data.deleted;
+  },
+
+  // Messages of a conversation, oldest first
+  getThread: async (threadId: string): Promise<Email[]> => {
+    const response = await apiClient.get<{ emails: Email[] }>(
+      `/emails/threads/${threadId}`
+    );
+    return response.data.emails;
+  },
+
+  // Returns the draft's ID, which changes on every save for IMAP accounts
+  saveDraft: async (draft: Draft): Promise<string> => {
+    const response = await apiClient.post<{ draft_id: string }>(
+      "/emails/drafts",
+      draft
+    );
+    return response.data.draft_id;
+  },
+
+  deleteDraft: async (draftId: string): Promise<void> => {
+    await apiClient.delete(`/emails/drafts/${draftId}`);
+  },
+
+  sendDraft: async (draftId: string): Promise<void> => {
+    await apiClient.post(`/emails/drafts/${draftId}/send`);
+  },
+
+  batchModify: async (
+    action: BatchAction,
+    ids: string[],
+    mailbox?: string
+  ): Promise<BatchResponse> => {
+    const response = await apiClient.post<BatchResponse>("/emails/batch", {
+      action,
+      ids,
+      mailbox,
+    });
+    return response.data;
+  },
+
   watchMailbox: async (): Promise<void> => {
     await apiClient.post("/emails/watch");
   },
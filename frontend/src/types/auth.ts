@@ -4,6 +4,7 @@ export interface User {
   name: string;
   avatar_url?: string;
   provider: string;
+  has_gmail_scope?: boolean; // False for Google users who didn't grant mail access
   created_at: string;
   updated_at: string;
 }
@@ -23,6 +24,8 @@ export interface TokenResponse {
   access_token: string;
   refresh_token: string;
   user: User;
+  needs_gmail_consent?: boolean; // Google sign-in without mail access; ask for required_scopes again
+  required_scopes?: string[];
 }
 
 export interface AuthResponse {
@@ -30,6 +33,35 @@ export interface AuthResponse {
   message: string;
 }
 
+export interface Session {
+  id: string;
+  user_agent: string;
+  ip_address: string;
+  created_at: string;
+  last_used_at: string;
+  expires_at: string;
+  current: boolean; // The session this browser is using
+}
+
+export interface LinkedAccount {
+  id: string;
+  email: string;
+  display_name: string;
+  provider: "google" | "imap";
+  imap_server?: string;
+  imap_port?: number;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface LinkImapAccountRequest {
+  email: string;
+  password: string;
+  imapServer: string;
+  imapPort: number;
+  displayName?: string;
+}
+
 export interface RefreshTokenRequest {
   refresh_token: string;
 }
@@ -41,7 +73,11 @@ export interface GoogleSignInRequest {
 
 export interface ImapLoginRequest {
   email: string;
-  password: string;
+  password?: string; // Required unless accessToken is given
+  accessToken?: string; // OAuth2 token, signs in with XOAUTH2 instead of the password
   imapServer: string;
   imapPort: number;
+  smtpServer?: string; // Derived from the IMAP server when omitted
+  smtpPort?: number;
+  smtpTls?: "tls" | "starttls"; // Chosen by port when omitted (465 is implicit TLS)
 }
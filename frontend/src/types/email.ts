@@ -16,6 +16,9 @@ export interface Attachment {
 
 export interface Email {
   id: string;
+  stable_id?: string; // Unchanged when the email moves between mailboxes
+  thread_id?: string; // Shared by every message of a conversation
+  draft_id?: string; // Set on drafts, used to update, delete or send them
   mailbox_id: string;
   from: string;
   from_name: string;
@@ -25,9 +28,12 @@ export interface Email {
   preview: string;
   body: string;
   is_html: boolean;
+  images_blocked?: boolean;
+  account_id?: string; // Unified inbox only: "primary" or a linked account's ID
   is_read: boolean;
   is_starred: boolean;
   is_important: boolean;
+  priority?: "high" | "normal" | "low";
   attachments?: Attachment[];
   received_at: string;
   created_at: string;
@@ -38,4 +44,72 @@ export interface EmailsResponse {
   limit: number;
   offset: number;
   total: number;
+  next_page_token?: string;
+}
+
+export interface UnifiedInboxResponse {
+  emails: Email[];
+  limit: number;
+  offset: number;
+  total: number;
+  failed_accounts?: string[]; // Accounts that couldn't be read; their mail is missing
+}
+
+export type BatchAction =
+  | "read"
+  | "unread"
+  | "star"
+  | "unstar"
+  | "trash"
+  | "archive"
+  | "move";
+
+export interface BatchResult {
+  id: string;
+  success: boolean;
+  error?: string;
+}
+
+export interface BatchResponse {
+  action: BatchAction;
+  results: BatchResult[];
+  succeeded: number;
+  failed: number;
+}
+
+export interface Draft {
+  draft_id?: string; // Set to replace an existing draft
+  from_name?: string;
+  to: string;
+  cc?: string;
+  bcc?: string;
+  subject: string;
+  body: string;
+}
+
+export interface EmailCategory {
+  email_id: string;
+  category:
+    | "work"
+    | "personal"
+    | "promotion"
+    | "finance"
+    | "urgent"
+    | "uncategorized";
+  priority: number; // 1-5, 0 when uncategorized
+  status?: string; // Kanban status it was moved to with apply
+}
+
+export interface EmailTranslation {
+  email_id: string;
+  source_language: string; // Detected language code of the original
+  target_language: string;
+  translation: string;
+}
+
+export interface Contact {
+  email: string;
+  name: string;
+  last_seen: string;
+  frequency: number; // Messages seen to or from the address
 }